@@ -136,6 +136,8 @@ func (e *ExprBuilder) RootRef(ref Reference) *fieldRefBuilder {
 
 // Cast returns a builder for constructing a Cast expression. The failure
 // behavior can be specified by calling FailBehavior before calling Build.
+// The input is built using the BaseSchema of the ExprBuilder, so any
+// field references are checked against it.
 func (e *ExprBuilder) Cast(from Builder, to types.Type) *castBuilder {
 	return &castBuilder{
 		toType: to, input: from,
@@ -172,11 +174,7 @@ func (cb *castBuilder) Build() (*Cast, error) {
 		return nil, err
 	}
 
-	return &Cast{
-		Type:            cb.toType,
-		Input:           in,
-		FailureBehavior: cb.failureBehavior,
-	}, nil
+	return NewCast(in, cb.toType, cb.failureBehavior)
 }
 
 // FailBehavior sets the failure behavior for the resulting Cast expression
@@ -233,6 +231,7 @@ type windowFuncBuilder struct {
 	partitions []Builder
 	sortList   []SortField
 
+	boundsType             WindowBoundsType
 	lowerBound, upperBound Bound
 }
 
@@ -258,6 +257,7 @@ func (wb *windowFuncBuilder) Build() (*WindowFunction, error) {
 	}
 
 	wf.Sorts, wf.LowerBound, wf.UpperBound = wb.sortList, wb.lowerBound, wb.upperBound
+	wf.BoundsType = wb.boundsType
 	return wf, nil
 }
 
@@ -311,6 +311,13 @@ func (wb *windowFuncBuilder) Bounds(lower, upper Bound) *windowFuncBuilder {
 	return wb
 }
 
+// BoundsType sets whether the bounds of the resulting WindowFunction
+// are a number of rows or a range of values of the sort field.
+func (wb *windowFuncBuilder) BoundsType(t WindowBoundsType) *windowFuncBuilder {
+	wb.boundsType = t
+	return wb
+}
+
 type aggregateFuncBuilder struct {
 	b *ExprBuilder
 
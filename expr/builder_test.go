@@ -7,6 +7,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	substraitgo "github.com/substrait-io/substrait-go"
 	"github.com/substrait-io/substrait-go/expr"
 	"github.com/substrait-io/substrait-go/extensions"
 	"github.com/substrait-io/substrait-go/types"
@@ -83,3 +84,54 @@ func TestExprBuilder(t *testing.T) {
 		})
 	}
 }
+
+func TestNewCast(t *testing.T) {
+	reg := expr.NewEmptyExtensionRegistry(&extensions.DefaultCollection)
+	input := expr.MustExpr(expr.NewRootFieldRef(expr.NewStructFieldRef(5), &boringSchema.Struct))
+
+	tests := []struct {
+		behavior expr.CastFailBehavior
+		expected types.Type
+		str      string
+	}{
+		{expr.CastFailReturnNull, &types.Int32Type{Nullability: types.NullabilityNullable},
+			"cast(.field(5) => i64 AS i32?, fail: FAILURE_BEHAVIOR_RETURN_NULL)"},
+		{expr.CastFailThrowException, &types.Int32Type{Nullability: types.NullabilityRequired},
+			"cast(.field(5) => i64 AS i32, fail: FAILURE_BEHAVIOR_THROW_EXCEPTION)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.behavior.String(), func(t *testing.T) {
+			cast, err := expr.NewCast(input, &types.Int32Type{Nullability: types.NullabilityRequired}, tt.behavior)
+			require.NoError(t, err)
+			assert.Equal(t, tt.str, cast.String())
+			assert.True(t, tt.expected.Equals(cast.GetType()))
+
+			p := cast.ToProto()
+			assert.Equal(t, tt.behavior, p.GetCast().FailureBehavior)
+
+			out, err := expr.ExprFromProto(p, &boringSchema.Struct, reg)
+			require.NoError(t, err)
+			assert.Truef(t, cast.Equals(out), "expected: %s\ngot: %s", cast, out)
+			assert.True(t, tt.expected.Equals(out.GetType()))
+		})
+	}
+
+	_, err := expr.NewCast(nil, &types.Int32Type{}, expr.CastFailReturnNull)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidExpr)
+
+	_, err = expr.NewCast(input, nil, expr.CastFailReturnNull)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidType)
+
+	_, err = expr.NewCast(input, &types.Int32Type{}, expr.CastFailBehavior(10))
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+
+	b := expr.ExprBuilder{Reg: reg, BaseSchema: &boringSchema.Struct}
+	cast, err := b.Cast(b.RootRef(expr.NewStructFieldRef(5)), &types.Int32Type{}).
+		FailBehavior(expr.CastFailReturnNull).Build()
+	require.NoError(t, err)
+	assert.Equal(t, types.NullabilityNullable, cast.GetType().GetNullability())
+
+	_, err = b.Cast(b.RootRef(expr.NewStructFieldRef(20)), &types.Int32Type{}).Build()
+	assert.Error(t, err)
+}
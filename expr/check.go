@@ -0,0 +1,391 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package expr
+
+import (
+	"fmt"
+
+	substraitgo "github.com/substrait-io/substrait-go"
+	"github.com/substrait-io/substrait-go/extensions"
+	"github.com/substrait-io/substrait-go/types"
+	"github.com/substrait-io/substrait-go/types/parser"
+)
+
+// CheckType resolves the root field references of the expression against
+// schema rather than the schema the expression was built with, and
+// returns the type the expression would have when evaluated against it.
+// This allows checking whether an expression built for one relation can
+// be reused for another.
+//
+// The type of each expression is derived again from the resolved types
+// of its children, such as the output types of scalar and window
+// functions from their arguments, the type of an IfThen or SwitchExpr
+// from its branches and the type of a nested expression from its
+// fields. An error is returned if a field reference is out of range for
+// the schema, if an argument no longer matches the function's
+// declaration, if the children no longer fit together, such as an
+// IfThen whose branches have different types, or if the type of an
+// outer reference is unknown. Outer references can't be resolved
+// against the schema and keep the type they were built with.
+func CheckType(e Expression, schema types.StructType) (types.Type, error) {
+	return checkType(e, &schema)
+}
+
+func checkType(e Expression, schema *types.StructType) (types.Type, error) {
+	switch e := e.(type) {
+	case nil:
+		return nil, fmt.Errorf("%w: cannot check the type of a nil expression",
+			substraitgo.ErrInvalidExpr)
+	case Literal:
+		return e.GetType(), nil
+	case *Parameter:
+		return e.Type, nil
+	case *FieldReference:
+		return checkFieldRefType(e, schema)
+	case *ScalarFunction:
+		argTypes, err := checkArgTypes(e.args, schema)
+		if err != nil || e.declaration == nil {
+			return e.outputType, err
+		}
+		if err := checkValueArgTypes(e.declaration.Args(), argTypes); err != nil {
+			return nil, err
+		}
+		return e.declaration.ResolveType(argTypes)
+	case *WindowFunction:
+		if err := checkChildTypes(e, schema); err != nil {
+			return nil, err
+		}
+		argTypes, err := checkArgTypes(e.args, schema)
+		if err != nil || e.declaration == nil {
+			return e.outputType, err
+		}
+		if err := checkValueArgTypes(e.declaration.Args(), argTypes); err != nil {
+			return nil, err
+		}
+		return e.declaration.ResolveType(argTypes)
+	case *IfThen:
+		return checkIfThenType(e, schema)
+	case *SwitchExpr:
+		return checkSwitchType(e, schema)
+	case *Cast:
+		// the type is the target of the cast
+		if _, err := checkType(e.Input, schema); err != nil {
+			return nil, err
+		}
+		return e.Type, nil
+	case *StructExpr:
+		fields, err := checkTypes(e.Fields, schema)
+		if err != nil {
+			return nil, err
+		}
+		return &types.StructType{
+			Nullability:      getNullability(e.Nullable),
+			TypeVariationRef: e.TypeVariationRef,
+			Types:            fields,
+		}, nil
+	case *ListExpr:
+		values, err := checkTypes(e.Values, schema)
+		if err != nil {
+			return nil, err
+		}
+		elem, err := commonType("list element", values)
+		if err != nil {
+			return nil, err
+		}
+		return &types.ListType{
+			Nullability:      getNullability(e.Nullable),
+			TypeVariationRef: e.TypeVariationRef,
+			Type:             elem,
+		}, nil
+	case *MapExpr:
+		keys := make([]types.Type, len(e.KeyValues))
+		values := make([]types.Type, len(e.KeyValues))
+		for i, kv := range e.KeyValues {
+			var err error
+			if keys[i], err = checkType(kv.Key, schema); err != nil {
+				return nil, err
+			}
+			if values[i], err = checkType(kv.Value, schema); err != nil {
+				return nil, err
+			}
+		}
+		key, err := commonType("map key", keys)
+		if err != nil {
+			return nil, err
+		}
+		value, err := commonType("map value", values)
+		if err != nil {
+			return nil, err
+		}
+		return &types.MapType{
+			Nullability:      getNullability(e.Nullable),
+			TypeVariationRef: e.TypeVariationRef,
+			Key:              key,
+			Value:            value,
+		}, nil
+	case *SingularOrList:
+		value, err := checkType(e.Value, schema)
+		if err != nil {
+			return nil, err
+		}
+		options, err := checkTypes(e.Options, schema)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := commonType("option", append([]types.Type{value}, options...)); err != nil {
+			return nil, err
+		}
+		return e.GetType(), nil
+	case *MultiOrList:
+		values, err := checkTypes(e.Value, schema)
+		if err != nil {
+			return nil, err
+		}
+		for _, o := range e.Options {
+			options, err := checkTypes(o, schema)
+			if err != nil {
+				return nil, err
+			}
+			for i := range options {
+				if i < len(values) && !types.EqualsIgnoreNullability(values[i], options[i]) {
+					return nil, fmt.Errorf("%w: option of type %s doesn't match value of type %s",
+						substraitgo.ErrInvalidType, options[i], values[i])
+				}
+			}
+		}
+		return e.GetType(), nil
+	case *InPredicate:
+		nullability := types.NullabilityRequired
+		for _, n := range e.Needles {
+			t, err := checkType(n, schema)
+			if err != nil {
+				return nil, err
+			}
+			if t.GetNullability() == types.NullabilityNullable {
+				nullability = types.NullabilityNullable
+			}
+		}
+		// the columns of the haystack don't depend on schema
+		for _, c := range relOutputType(e.Haystack).Types {
+			if c.GetNullability() == types.NullabilityNullable {
+				nullability = types.NullabilityNullable
+			}
+		}
+		return &types.BooleanType{Nullability: nullability}, nil
+	}
+
+	// the type of any other expression, such as a SetPredicate, doesn't
+	// depend on its children, which only need checking
+	if err := checkChildTypes(e, schema); err != nil {
+		return nil, err
+	}
+	return e.GetType(), nil
+}
+
+// checkIfThenType returns the type of the else clause, which is nullable
+// if any of the branches is, checking that each condition is a boolean
+// and that every branch has the same type other than nullability.
+func checkIfThenType(e *IfThen, schema *types.StructType) (types.Type, error) {
+	out, err := checkType(e.elseClause, schema)
+	if err != nil {
+		return nil, err
+	}
+
+	nullable := out.GetNullability() == types.NullabilityNullable
+	for i, c := range e.ifs {
+		cond, err := checkType(c.If, schema)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := cond.(*types.BooleanType); !ok {
+			return nil, fmt.Errorf("%w: condition %d of IfThen must be a boolean, got %s",
+				substraitgo.ErrInvalidType, i, cond)
+		}
+
+		then, err := checkType(c.Then, schema)
+		if err != nil {
+			return nil, err
+		}
+		if !types.EqualsIgnoreNullability(then, out) {
+			return nil, fmt.Errorf("%w: branch %d of IfThen has type %s, expected %s",
+				substraitgo.ErrInvalidType, i, then, out)
+		}
+		nullable = nullable || then.GetNullability() == types.NullabilityNullable
+	}
+
+	if nullable {
+		return out.WithNullability(types.NullabilityNullable), nil
+	}
+	return out, nil
+}
+
+// checkSwitchType returns the common type of the branches of the switch,
+// which is nullable if any of them is or if there's no else clause,
+// checking that the match expression has the type of the cases.
+func checkSwitchType(e *SwitchExpr, schema *types.StructType) (types.Type, error) {
+	match, err := checkType(e.match, schema)
+	if err != nil {
+		return nil, err
+	}
+
+	branches := make([]types.Type, 0, len(e.ifs)+1)
+	for i, c := range e.ifs {
+		if !types.EqualsIgnoreNullability(match, c.If.GetType()) {
+			return nil, fmt.Errorf("%w: case %d of switch has type %s, expected %s",
+				substraitgo.ErrInvalidType, i, c.If.GetType(), match)
+		}
+		then, err := checkType(c.Then, schema)
+		if err != nil {
+			return nil, err
+		}
+		branches = append(branches, then)
+	}
+	if e.elseClause != nil {
+		elseType, err := checkType(e.elseClause, schema)
+		if err != nil {
+			return nil, err
+		}
+		branches = append(branches, elseType)
+	}
+
+	out, err := commonType("switch result", branches)
+	if err != nil {
+		return nil, err
+	}
+	if e.elseClause == nil {
+		return out.WithNullability(types.NullabilityNullable), nil
+	}
+	return out, nil
+}
+
+// checkTypes checks each of the expressions, returning their types.
+func checkTypes(exprs []Expression, schema *types.StructType) ([]types.Type, error) {
+	out := make([]types.Type, len(exprs))
+	for i, e := range exprs {
+		var err error
+		if out[i], err = checkType(e, schema); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// commonType returns the type shared by all of typs other than
+// nullability, which is nullable if any of them is. An error is
+// returned if they have different types or if typs is empty.
+func commonType(what string, typs []types.Type) (types.Type, error) {
+	if len(typs) == 0 {
+		return nil, fmt.Errorf("%w: no %s to derive the type from",
+			substraitgo.ErrInvalidExpr, what)
+	}
+
+	out := typs[0]
+	for _, t := range typs[1:] {
+		if !types.EqualsIgnoreNullability(out, t) {
+			return nil, fmt.Errorf("%w: %s of type %s doesn't match %s",
+				substraitgo.ErrInvalidType, what, t, out)
+		}
+		if t.GetNullability() == types.NullabilityNullable {
+			out = t
+		}
+	}
+	return out, nil
+}
+
+func checkFieldRefType(ref *FieldReference, schema *types.StructType) (types.Type, error) {
+	var rootType types.Type
+	switch root := ref.Root.(type) {
+	case nil:
+		rootType = schema
+	case Expression:
+		var err error
+		if rootType, err = checkType(root, schema); err != nil {
+			return nil, err
+		}
+	default:
+		// outer references keep the type they were built with
+		if ref.GetType() == nil {
+			return nil, fmt.Errorf("%w: the type of %s is unknown",
+				substraitgo.ErrInvalidType, ref)
+		}
+		return ref.GetType(), nil
+	}
+
+	var (
+		typ types.Type
+		err error
+	)
+	switch rt := ref.Reference.(type) {
+	case ReferenceSegment:
+		typ, err = rt.GetType(rootType)
+	case *MaskExpression:
+		typ, err = rt.GetType(rootType)
+	default:
+		return nil, substraitgo.ErrNotImplemented
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: cannot resolve %s against %s",
+			err, ref, rootType)
+	}
+	return typ, nil
+}
+
+// checkArgTypes checks the expression arguments, returning the types of
+// all the arguments as expected by ResolveType.
+func checkArgTypes(args []types.FuncArg, schema *types.StructType) ([]types.Type, error) {
+	argTypes := funcArgTypes(args)
+	for i, arg := range args {
+		if e, ok := arg.(Expression); ok {
+			t, err := checkType(e, schema)
+			if err != nil {
+				return nil, fmt.Errorf("arg #%d: %w", i, err)
+			}
+			argTypes[i] = t
+		}
+	}
+	return argTypes, nil
+}
+
+// checkValueArgTypes checks that the types of the arguments have the
+// same kind as the declared types of the function's value parameters,
+// ignoring type parameters such as the precision of a decimal.
+// Parameters of any type aren't checked.
+func checkValueArgTypes(params extensions.ArgumentList, argTypes []types.Type) error {
+	if len(params) == 0 {
+		return nil
+	}
+
+	for i, t := range argTypes {
+		p, ok := params[min(i, len(params)-1)].(extensions.ValueArg)
+		if !ok || t == nil || p.Value == nil {
+			continue
+		}
+
+		declared, ok := p.Value.Expr.(*parser.Type)
+		if !ok {
+			continue
+		}
+		if argType, err := declared.ArgType(); err != nil {
+			continue
+		} else if _, isAny := argType.(types.AnyType); isAny {
+			continue
+		}
+
+		if want := declared.ShortType(); want != "" && want != t.ShortString() {
+			return fmt.Errorf("%w: arg #%d should be of type %s, got %s",
+				substraitgo.ErrInvalidType, i, want, t)
+		}
+	}
+	return nil
+}
+
+func checkChildTypes(e Expression, schema *types.StructType) (err error) {
+	e.Visit(func(child Expression) Expression {
+		if err == nil && child != nil {
+			_, err = checkType(child, schema)
+		}
+		return child
+	})
+	return
+}
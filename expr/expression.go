@@ -52,6 +52,9 @@ func ExprFromProto(e *proto.Expression, baseSchema types.Type, reg ExtensionRegi
 
 	switch et := e.RexType.(type) {
 	case *proto.Expression_Literal_:
+		if p, ok := ParameterFromProto(et.Literal); ok {
+			return p, nil
+		}
 		return LiteralFromProto(et.Literal), nil
 	case *proto.Expression_Selection:
 		return FieldReferenceFromProto(et.Selection, baseSchema, reg)
@@ -118,6 +121,7 @@ func ExprFromProto(e *proto.Expression, baseSchema types.Type, reg ExtensionRegi
 
 			fn.Partitions = parts
 			fn.Sorts = sorts
+			fn.BoundsType = et.WindowFunction.BoundsType
 			fn.LowerBound = BoundFromProto(et.WindowFunction.LowerBound)
 			fn.UpperBound = BoundFromProto(et.WindowFunction.UpperBound)
 			return fn, nil
@@ -133,6 +137,7 @@ func ExprFromProto(e *proto.Expression, baseSchema types.Type, reg ExtensionRegi
 			invocation:  et.WindowFunction.Invocation,
 			Partitions:  parts,
 			Sorts:       sorts,
+			BoundsType:  et.WindowFunction.BoundsType,
 			LowerBound:  BoundFromProto(et.WindowFunction.LowerBound),
 			UpperBound:  BoundFromProto(et.WindowFunction.UpperBound),
 		}, nil
@@ -310,6 +315,7 @@ func ExprFromProto(e *proto.Expression, baseSchema types.Type, reg ExtensionRegi
 	case *proto.Expression_Enum_:
 		return nil, fmt.Errorf("%w: deprecated", substraitgo.ErrNotImplemented)
 	case *proto.Expression_Subquery_:
+		return subqueryFromProto(et.Subquery, baseSchema, reg)
 	}
 
 	return nil, fmt.Errorf("%w: ExprFromProto: %s", substraitgo.ErrNotImplemented, e)
@@ -554,12 +560,54 @@ func (ex *IfThen) Visit(visit VisitFunc) Expression {
 	return out
 }
 
+// CastFailBehavior controls what happens when a Cast expression is
+// unable to convert its input to the target type.
+type CastFailBehavior = types.CastFailBehavior
+
+const (
+	CastFailUnspecified    = types.BehaviorUnspecified
+	CastFailReturnNull     = types.BehaviorReturnNil
+	CastFailThrowException = types.BehaviorThrowException
+)
+
 type Cast struct {
 	Type            types.Type
 	Input           Expression
 	FailureBehavior types.CastFailBehavior
 }
 
+// NewCast constructs a Cast expression converting input to the target
+// type. If the failure behavior is CastFailReturnNull the resulting type
+// is always nullable, since a failed cast will produce a null value.
+// An error is returned if input or target are nil, or if the failure
+// behavior is not a known value.
+func NewCast(input Expression, target types.Type, failureBehavior CastFailBehavior) (*Cast, error) {
+	if input == nil {
+		return nil, fmt.Errorf("%w: cast input must not be nil",
+			substraitgo.ErrInvalidExpr)
+	}
+
+	if target == nil {
+		return nil, fmt.Errorf("%w: cast target type must not be nil",
+			substraitgo.ErrInvalidType)
+	}
+
+	switch failureBehavior {
+	case CastFailUnspecified, CastFailThrowException:
+	case CastFailReturnNull:
+		target = target.WithNullability(types.NullabilityNullable)
+	default:
+		return nil, fmt.Errorf("%w: invalid cast failure behavior: %s",
+			substraitgo.ErrInvalidArg, failureBehavior)
+	}
+
+	return &Cast{
+		Type:            target,
+		Input:           input,
+		FailureBehavior: failureBehavior,
+	}, nil
+}
+
 func (ex *Cast) String() string {
 	return fmt.Sprintf("cast(%s AS %s, fail: %s)",
 		ex.Input, ex.Type, ex.FailureBehavior)
@@ -604,7 +652,14 @@ func (ex *Cast) Equals(other Expression) bool {
 }
 
 func (ex *Cast) Visit(visit VisitFunc) Expression {
-	return visit(ex.Input)
+	input := visit(ex.Input)
+	if input == ex.Input {
+		return ex
+	}
+
+	out := *ex
+	out.Input = input
+	return &out
 }
 
 type SwitchExpr struct {
@@ -870,6 +925,31 @@ type SingularOrList struct {
 	Options []Expression
 }
 
+// NewSingularOrList constructs an expression testing whether value is
+// equal to any of the options, as in the SQL "value IN (options...)". An
+// error is returned if value is nil, there are no options, or any option
+// has a type other than that of value, ignoring nullability.
+func NewSingularOrList(value Expression, options []Expression) (*SingularOrList, error) {
+	if value == nil {
+		return nil, fmt.Errorf("%w: value of SingularOrList must not be nil", substraitgo.ErrInvalidExpr)
+	}
+	if len(options) == 0 {
+		return nil, fmt.Errorf("%w: SingularOrList must have at least one option", substraitgo.ErrInvalidExpr)
+	}
+
+	for i, o := range options {
+		switch {
+		case o == nil:
+			return nil, fmt.Errorf("%w: option %d of SingularOrList is nil", substraitgo.ErrInvalidExpr, i)
+		case !types.EqualsIgnoreNullability(value.GetType(), o.GetType()):
+			return nil, fmt.Errorf("%w: option %d of SingularOrList has type %s, expected %s",
+				substraitgo.ErrInvalidExpr, i, o.GetType(), value.GetType())
+		}
+	}
+
+	return &SingularOrList{Value: value, Options: options}, nil
+}
+
 func (ex *SingularOrList) String() string {
 	var b strings.Builder
 	b.WriteString(ex.Value.String())
@@ -973,6 +1053,46 @@ type MultiOrList struct {
 	Options [][]Expression
 }
 
+// NewMultiOrList constructs an expression testing whether the values are
+// equal to all of the expressions of any of the options, as in the SQL
+// "(a, b) IN ((1, 2), (3, 4))". An error is returned if there are no
+// values or options, or if any option doesn't have an expression of the
+// same type, ignoring nullability, for each of the values.
+func NewMultiOrList(value []Expression, options [][]Expression) (*MultiOrList, error) {
+	if len(value) == 0 {
+		return nil, fmt.Errorf("%w: MultiOrList must have at least one value", substraitgo.ErrInvalidExpr)
+	}
+	if len(options) == 0 {
+		return nil, fmt.Errorf("%w: MultiOrList must have at least one option", substraitgo.ErrInvalidExpr)
+	}
+
+	for i, v := range value {
+		if v == nil {
+			return nil, fmt.Errorf("%w: value %d of MultiOrList is nil", substraitgo.ErrInvalidExpr, i)
+		}
+	}
+
+	for i, opt := range options {
+		if len(opt) != len(value) {
+			return nil, fmt.Errorf("%w: option %d of MultiOrList has %d expressions, expected %d",
+				substraitgo.ErrInvalidExpr, i, len(opt), len(value))
+		}
+
+		for j, o := range opt {
+			switch {
+			case o == nil:
+				return nil, fmt.Errorf("%w: expression %d of option %d of MultiOrList is nil",
+					substraitgo.ErrInvalidExpr, j, i)
+			case !types.EqualsIgnoreNullability(value[j].GetType(), o.GetType()):
+				return nil, fmt.Errorf("%w: expression %d of option %d of MultiOrList has type %s, expected %s",
+					substraitgo.ErrInvalidExpr, j, i, o.GetType(), value[j].GetType())
+			}
+		}
+	}
+
+	return &MultiOrList{Value: value, Options: options}, nil
+}
+
 func (ex *MultiOrList) String() string {
 	var b strings.Builder
 	writeList := func(list []Expression) {
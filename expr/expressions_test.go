@@ -6,6 +6,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"math"
 	"os"
 	"sort"
 	"strings"
@@ -13,6 +14,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	substraitgo "github.com/substrait-io/substrait-go"
 	"github.com/substrait-io/substrait-go/expr"
 	ext "github.com/substrait-io/substrait-go/extensions"
 	"github.com/substrait-io/substrait-go/proto"
@@ -420,3 +422,326 @@ func TestRoundTripExtendedExpression(t *testing.T) {
 		assert.Truef(t, pb.Equal(&ex, out), "expected: %s\ngot: %s", &ex, out)
 	}
 }
+
+func TestNewWindowFunction(t *testing.T) {
+	reg := expr.NewEmptyExtensionRegistry(&ext.DefaultCollection)
+	decl, ok := ext.DefaultCollection.GetWindowFunc(ext.ID{
+		URI: ext.SubstraitDefaultURIPrefix + "functions_arithmetic.yaml", Name: "row_number:"})
+	require.True(t, ok)
+
+	schema := &types.StructType{
+		Nullability: types.NullabilityRequired,
+		Types: []types.Type{
+			&types.StringType{Nullability: types.NullabilityRequired},
+			&types.Int32Type{Nullability: types.NullabilityNullable},
+		},
+	}
+	part, err := expr.NewRootFieldRef(expr.NewStructFieldRef(0), schema)
+	require.NoError(t, err)
+	sortRef, err := expr.NewRootFieldRef(expr.NewStructFieldRef(1), schema)
+	require.NoError(t, err)
+
+	// row_number() OVER (PARTITION BY a ORDER BY b
+	//   ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW)
+	fn, err := expr.NewWindowFunction(reg, decl, nil, []expr.Expression{part},
+		[]expr.SortField{{Expr: sortRef, Kind: types.SortAscNullsLast}},
+		expr.WindowBounds{Type: expr.BoundsTypeRows, Lower: expr.Unbounded{}, Upper: expr.CurrentRow{}})
+	require.NoError(t, err)
+	assert.Equal(t, "row_number", fn.Name())
+	assert.Equal(t, "i64?", fn.GetType().String())
+	assert.Equal(t, types.AggPhaseInitialToResult, fn.Phase())
+
+	p := fn.ToProto()
+	wf := p.GetWindowFunction()
+	require.NotNil(t, wf)
+	assert.Len(t, wf.Partitions, 1)
+	assert.Len(t, wf.Sorts, 1)
+	assert.Equal(t, proto.Expression_WindowFunction_BOUNDS_TYPE_ROWS, wf.BoundsType)
+	assert.NotNil(t, wf.LowerBound.GetUnbounded())
+	assert.NotNil(t, wf.UpperBound.GetCurrentRow())
+
+	out, err := expr.ExprFromProto(p, schema, reg)
+	require.NoError(t, err)
+	assert.Truef(t, fn.Equals(out), "expected: %s\ngot: %s", fn, out)
+	assert.True(t, pb.Equal(p, out.ToProto()))
+
+	// a range frame with offsets on both sides
+	fn, err = expr.NewWindowFunction(reg, decl, nil, []expr.Expression{part}, nil,
+		expr.WindowBounds{Type: expr.BoundsTypeRange, Lower: expr.PrecedingBound(2), Upper: expr.FollowingBound(3)})
+	require.NoError(t, err)
+	p = fn.ToProto()
+	assert.Equal(t, proto.Expression_WindowFunction_BOUNDS_TYPE_RANGE, p.GetWindowFunction().BoundsType)
+	out, err = expr.ExprFromProto(p, schema, reg)
+	require.NoError(t, err)
+	assert.True(t, fn.Equals(out))
+	assert.Equal(t, expr.PrecedingBound(2), out.(*expr.WindowFunction).LowerBound)
+	assert.Equal(t, expr.FollowingBound(3), out.(*expr.WindowFunction).UpperBound)
+}
+
+func TestNewWindowFunctionErrors(t *testing.T) {
+	reg := expr.NewEmptyExtensionRegistry(&ext.DefaultCollection)
+	rowNumber, ok := ext.DefaultCollection.GetWindowFunc(ext.ID{
+		URI: ext.SubstraitDefaultURIPrefix + "functions_arithmetic.yaml", Name: "row_number:"})
+	require.True(t, ok)
+	ntile, ok := ext.DefaultCollection.GetWindowFunc(ext.ID{
+		URI: ext.SubstraitDefaultURIPrefix + "functions_arithmetic.yaml", Name: "ntile:i32"})
+	require.True(t, ok)
+
+	_, err := expr.NewWindowFunction(reg, nil, nil, nil, nil, expr.WindowBounds{})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidExpr)
+
+	_, err = expr.NewWindowFunction(reg, ntile, []types.FuncArg{expr.NewPrimitiveLiteral("x", false)},
+		nil, nil, expr.WindowBounds{})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "do not match window function ntile:i32")
+
+	_, err = expr.NewWindowFunction(reg, ntile, nil, nil, nil, expr.WindowBounds{})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+
+	_, err = expr.NewWindowFunction(reg, rowNumber, nil, []expr.Expression{nil}, nil, expr.WindowBounds{})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidExpr)
+	assert.ErrorContains(t, err, "partition 0 of window function row_number is nil")
+
+	_, err = expr.NewWindowFunction(reg, rowNumber, nil, nil, []expr.SortField{{}}, expr.WindowBounds{})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidExpr)
+
+	_, err = expr.NewWindowFunction(reg, rowNumber, nil, nil, nil, expr.WindowBounds{Type: 5})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "invalid bounds type 5")
+
+	_, err = expr.NewWindowFunction(reg, rowNumber, nil, nil, nil,
+		expr.WindowBounds{Type: expr.BoundsTypeRows, Lower: expr.PrecedingBound(-1)})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "preceding bound offset must not be negative")
+
+	// errors from matching the arguments are returned rather than ignored
+	const uri = "http://localhost/window.yaml"
+	var c ext.Collection
+	require.NoError(t, c.Load(uri, strings.NewReader(`---
+window_functions:
+  - name: "lag_diff"
+    impls:
+      - args:
+          - name: x
+            value: decimal<P,S>
+          - name: y
+            value: decimal<P,S>
+        window_type: PARTITION
+        return: decimal<P,S>
+`)))
+	lagDiff, ok := c.GetWindowFunc(ext.ID{URI: uri, Name: "lag_diff:dec_dec"})
+	require.True(t, ok)
+	arg := expr.NewPrimitiveLiteral(int32(0), false)
+	_, err = expr.NewWindowFunction(expr.NewEmptyExtensionRegistry(&c), lagDiff,
+		[]types.FuncArg{arg, arg}, nil, nil, expr.WindowBounds{})
+	assert.ErrorIs(t, err, substraitgo.ErrNotImplemented)
+}
+
+func TestNewOrList(t *testing.T) {
+	reg := expr.NewEmptyExtensionRegistry(&ext.DefaultCollection)
+	schema := &types.StructType{Types: []types.Type{
+		&types.Int32Type{Nullability: types.NullabilityNullable},
+		&types.StringType{Nullability: types.NullabilityRequired},
+	}}
+	x := expr.MustExpr(expr.NewRootFieldRef(expr.NewStructFieldRef(0), schema))
+	s := expr.MustExpr(expr.NewRootFieldRef(expr.NewStructFieldRef(1), schema))
+	lit := func(v int32) expr.Expression { return expr.NewPrimitiveLiteral(v, false) }
+
+	in, err := expr.NewSingularOrList(x, []expr.Expression{lit(1), lit(2), lit(3)})
+	require.NoError(t, err)
+	assert.Equal(t, "boolean", in.GetType().String())
+	out := in.ToProto()
+	require.IsType(t, &proto.Expression_SingularOrList_{}, out.RexType)
+	rt, err := expr.ExprFromProto(out, schema, reg)
+	require.NoError(t, err)
+	assert.True(t, in.Equals(rt))
+
+	multi, err := expr.NewMultiOrList([]expr.Expression{x, s}, [][]expr.Expression{
+		{lit(1), expr.NewPrimitiveLiteral("a", false)},
+		{lit(2), expr.NewPrimitiveLiteral("b", true)},
+	})
+	require.NoError(t, err)
+	out = multi.ToProto()
+	require.IsType(t, &proto.Expression_MultiOrList_{}, out.RexType)
+	rt, err = expr.ExprFromProto(out, schema, reg)
+	require.NoError(t, err)
+	assert.True(t, multi.Equals(rt))
+
+	_, err = expr.NewSingularOrList(nil, []expr.Expression{lit(1)})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidExpr)
+	_, err = expr.NewSingularOrList(x, nil)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidExpr)
+	assert.ErrorContains(t, err, "SingularOrList must have at least one option")
+	_, err = expr.NewSingularOrList(x, []expr.Expression{lit(1), expr.NewPrimitiveLiteral("a", false)})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidExpr)
+	assert.ErrorContains(t, err, "option 1 of SingularOrList has type string, expected i32?")
+
+	_, err = expr.NewMultiOrList(nil, [][]expr.Expression{{lit(1)}})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidExpr)
+	_, err = expr.NewMultiOrList([]expr.Expression{x, s}, [][]expr.Expression{{lit(1)}})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidExpr)
+	assert.ErrorContains(t, err, "option 0 of MultiOrList has 1 expressions, expected 2")
+	_, err = expr.NewMultiOrList([]expr.Expression{x, s}, [][]expr.Expression{{lit(1), lit(2)}})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidExpr)
+	assert.ErrorContains(t, err, "expression 1 of option 0 of MultiOrList has type i32, expected string")
+}
+
+func TestFold(t *testing.T) {
+	reg := expr.NewEmptyExtensionRegistry(&ext.DefaultCollection)
+	call := func(uri, name string, args ...types.FuncArg) expr.Expression {
+		return expr.MustExpr(expr.NewScalarFunc(reg, ext.ID{
+			URI: ext.SubstraitDefaultURIPrefix + uri, Name: name}, nil, args...))
+	}
+	arith := func(name string, args ...types.FuncArg) expr.Expression {
+		return call("functions_arithmetic.yaml", name, args...)
+	}
+	i32 := func(v int32) expr.Expression { return expr.NewPrimitiveLiteral(v, false) }
+
+	folded, ok := expr.Fold(arith("add", i32(1), i32(2)))
+	require.True(t, ok)
+	assert.True(t, folded.Equals(i32(3)))
+
+	folded, ok = expr.Fold(arith("multiply", arith("add", i32(1), i32(2)), arith("negate", i32(4))))
+	require.True(t, ok)
+	assert.True(t, folded.Equals(i32(-12)))
+
+	folded, ok = expr.Fold(call("functions_boolean.yaml", "and",
+		expr.NewPrimitiveLiteral(true, false), expr.NewPrimitiveLiteral(false, false)))
+	require.True(t, ok)
+	assert.True(t, folded.Equals(expr.NewPrimitiveLiteral(false, false)))
+
+	folded, ok = expr.Fold(call("functions_comparison.yaml", "lt", i32(1), i32(2)))
+	require.True(t, ok)
+	assert.True(t, folded.Equals(expr.NewPrimitiveLiteral(true, false)))
+
+	schema := &types.StructType{Types: []types.Type{&types.Int32Type{Nullability: types.NullabilityRequired}}}
+	ref := expr.MustExpr(expr.NewRootFieldRef(expr.NewStructFieldRef(0), schema))
+	for _, e := range []expr.Expression{
+		ref,
+		arith("add", ref, i32(1)),
+		arith("add", i32(math.MaxInt32), i32(1)),
+		arith("divide", i32(1), i32(0)),
+		arith("add", i32(1), &expr.NullLiteral{Type: &types.Int32Type{Nullability: types.NullabilityNullable}}),
+	} {
+		_, ok := expr.Fold(e)
+		assert.False(t, ok, e.String())
+	}
+}
+
+func TestCheckType(t *testing.T) {
+	reg := expr.NewEmptyExtensionRegistry(&ext.DefaultCollection)
+	built := &types.StructType{Types: []types.Type{
+		&types.Int32Type{Nullability: types.NullabilityRequired},
+	}}
+	ref := expr.MustExpr(expr.NewRootFieldRef(expr.NewStructFieldRef(0), built))
+	add := expr.MustExpr(expr.NewScalarFunc(reg, ext.ID{
+		URI: ext.SubstraitDefaultURIPrefix + "functions_arithmetic.yaml", Name: "add"},
+		nil, ref, expr.NewPrimitiveLiteral(int32(1), false)))
+
+	other := types.StructType{Types: []types.Type{
+		&types.Int32Type{Nullability: types.NullabilityNullable},
+		&types.StringType{Nullability: types.NullabilityRequired},
+	}}
+
+	typ, err := expr.CheckType(ref, other)
+	require.NoError(t, err)
+	assert.Equal(t, "i32?", typ.String())
+
+	typ, err = expr.CheckType(add, other)
+	require.NoError(t, err)
+	assert.Equal(t, "i32?", typ.String())
+
+	_, err = expr.CheckType(ref, types.StructType{})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidType)
+	assert.ErrorContains(t, err, "cannot resolve .field(0)")
+
+	_, err = expr.CheckType(add, types.StructType{Types: []types.Type{
+		&types.StringType{Nullability: types.NullabilityRequired},
+	}})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidType)
+}
+
+func TestCheckTypeDerivesFromChildren(t *testing.T) {
+	i32 := &types.Int32Type{Nullability: types.NullabilityRequired}
+	built := &types.StructType{Types: []types.Type{i32, &types.BooleanType{Nullability: types.NullabilityRequired}}}
+	ref := expr.MustExpr(expr.NewRootFieldRef(expr.NewStructFieldRef(0), built))
+	cond := expr.MustExpr(expr.NewRootFieldRef(expr.NewStructFieldRef(1), built))
+
+	ifThen := expr.MustExpr(expr.NewIfThen(expr.IfThenPair{If: cond, Then: ref}, expr.NewPrimitiveLiteral(int32(1), false)))
+	cast := expr.MustExpr(expr.NewCast(ref, &types.Int64Type{Nullability: types.NullabilityNullable}, expr.CastFailReturnNull))
+	list := expr.NewListExpr(false, ref, expr.NewPrimitiveLiteral(int32(1), false))
+	strct := &expr.StructExpr{Fields: []expr.Expression{ref, cond}}
+
+	nullable := types.StructType{Types: []types.Type{
+		&types.Int32Type{Nullability: types.NullabilityNullable},
+		&types.BooleanType{Nullability: types.NullabilityNullable},
+	}}
+	strs := types.StructType{Types: []types.Type{
+		&types.StringType{Nullability: types.NullabilityNullable},
+		&types.BooleanType{Nullability: types.NullabilityRequired},
+	}}
+
+	tests := []struct {
+		name     string
+		e        expr.Expression
+		expected string
+	}{
+		{"if then", ifThen, "i32?"},
+		{"cast", cast, "i64?"},
+		{"list", list, "list<i32?>"},
+		{"struct", strct, "struct<i32?, boolean?>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			typ, err := expr.CheckType(tt.e, *built)
+			require.NoError(t, err)
+			assert.True(t, typ.Equals(tt.e.GetType()), typ.String())
+
+			typ, err = expr.CheckType(tt.e, nullable)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, typ.String())
+		})
+	}
+
+	// the branches of the IfThen no longer have the same type
+	_, err := expr.CheckType(ifThen, strs)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidType)
+	assert.ErrorContains(t, err, "branch 0 of IfThen has type string?, expected i32")
+	_, err = expr.CheckType(list, strs)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidType)
+
+	// the input of the cast is still checked
+	_, err = expr.CheckType(cast, types.StructType{})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidType)
+
+	// the type of an outer reference built without a schema is unknown
+	outer, err := expr.NewOuterFieldRef(1, expr.NewStructFieldRef(0), nil)
+	require.NoError(t, err)
+	_, err = expr.CheckType(outer, *built)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidType)
+	assert.ErrorContains(t, err, "is unknown")
+}
+
+func TestParameter(t *testing.T) {
+	reg := expr.NewEmptyExtensionRegistry(&ext.DefaultCollection)
+	param, err := expr.NewParameter(1, &types.Int32Type{Nullability: types.NullabilityNullable})
+	require.NoError(t, err)
+	assert.Equal(t, "$1 => i32?", param.String())
+	assert.True(t, param.IsScalar())
+
+	fromProto, err := expr.ExprFromProto(param.ToProto(), nil, reg)
+	require.NoError(t, err)
+	assert.True(t, param.Equals(fromProto))
+
+	gt := expr.MustExpr(expr.NewScalarFunc(reg, ext.ID{
+		URI: ext.SubstraitDefaultURIPrefix + "functions_comparison.yaml", Name: "gt"},
+		nil, param, expr.NewPrimitiveLiteral(int32(1), false)))
+	fromProto, err = expr.ExprFromProto(gt.ToProto(), nil, reg)
+	require.NoError(t, err)
+	assert.True(t, gt.Equals(fromProto))
+
+	_, err = expr.NewParameter(-1, &types.Int32Type{})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidExpr)
+	_, err = expr.NewParameter(0, nil)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidExpr)
+}
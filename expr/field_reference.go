@@ -4,12 +4,14 @@ package expr
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	substraitgo "github.com/substrait-io/substrait-go"
 	"github.com/substrait-io/substrait-go/proto"
 	"github.com/substrait-io/substrait-go/types"
 	"golang.org/x/exp/slices"
+	pb "google.golang.org/protobuf/proto"
 )
 
 type RootRefType interface {
@@ -119,7 +121,7 @@ func (r *MapKeyRef) GetType(parentType types.Type) (types.Type, error) {
 		return nil, substraitgo.ErrInvalidType
 	}
 
-	if !r.MapKey.GetType().Equals(mt.Key) {
+	if !types.EqualsIgnoreNullability(r.MapKey.GetType(), mt.Key) {
 		return nil, substraitgo.ErrInvalidType
 	}
 
@@ -175,7 +177,7 @@ func (r *StructFieldRef) GetType(parentType types.Type) (types.Type, error) {
 		return nil, substraitgo.ErrInvalidType
 	}
 
-	if len(st.Types) < int(r.Field) {
+	if r.Field < 0 || int(r.Field) >= len(st.Types) {
 		return nil, substraitgo.ErrInvalidType
 	}
 
@@ -308,6 +310,14 @@ type MaskExpression struct {
 	maintainSingular bool
 }
 
+// NewMaskExpression constructs a mask expression selecting the given
+// items from a struct. If maintainSingular is false and only a single
+// field is selected, the resulting type is that of the field rather
+// than a struct containing it.
+func NewMaskExpression(sel MaskStructSelect, maintainSingular bool) *MaskExpression {
+	return &MaskExpression{sel: sel, maintainSingular: maintainSingular}
+}
+
 func (*MaskExpression) isRefType() {}
 func (e *MaskExpression) ToProto() *proto.Expression_MaskExpression {
 	return &proto.Expression_MaskExpression{
@@ -324,9 +334,43 @@ func (e *MaskExpression) Select() MaskStructSelect {
 	return slices.Clone(e.sel)
 }
 
+func (e *MaskExpression) String() string {
+	var singular string
+	if e.maintainSingular {
+		singular = "(singular)"
+	}
+	return ".mask" + singular + e.sel.String()
+}
+
+// GetType returns the type resulting from applying this mask to
+// the provided parent type, which must be a struct.
+func (e *MaskExpression) GetType(parentType types.Type) (types.Type, error) {
+	out, err := e.sel.GetType(parentType)
+	if err != nil {
+		return nil, err
+	}
+
+	if st := out.(*types.StructType); !e.maintainSingular && len(st.Types) == 1 {
+		return st.Types[0], nil
+	}
+	return out, nil
+}
+
+func (e *MaskExpression) Equals(rhs *MaskExpression) bool {
+	if e == rhs {
+		return true
+	}
+
+	if e == nil || rhs == nil {
+		return false
+	}
+
+	return pb.Equal(e.ToProto(), rhs.ToProto())
+}
+
 func MaskExpressionFromProto(p *proto.Expression_MaskExpression) *MaskExpression {
-	sel := make(MaskStructSelect, len(p.Select.StructItems))
-	for i, item := range p.Select.StructItems {
+	sel := make(MaskStructSelect, len(p.GetSelect().GetStructItems()))
+	for i, item := range p.GetSelect().GetStructItems() {
 		sel[i].field = item.Field
 		if item.Child != nil {
 			sel[i].child = maskSelectFromProto(item.Child)
@@ -356,10 +400,11 @@ func maskSelectFromProto(p *proto.Expression_MaskExpression_Select) MaskSelect {
 				selection[i] = (*MaskListSlice)(s.Slice)
 			}
 		}
-		return &MaskListSelect{
-			selection: selection,
-			child:     maskSelectFromProto(s.List.Child),
+		ret := &MaskListSelect{selection: selection}
+		if s.List.Child != nil {
+			ret.child = maskSelectFromProto(s.List.Child)
 		}
+		return ret
 	case *proto.Expression_MaskExpression_Select_Map:
 		var ret MaskMapSelect
 		if s.Map.Child != nil {
@@ -379,10 +424,39 @@ func maskSelectFromProto(p *proto.Expression_MaskExpression_Select) MaskSelect {
 	panic("unimplemented mask select type")
 }
 
+// MaskSelect is a selection within a MaskExpression, applying to
+// a struct, list or map type.
 type MaskSelect interface {
+	fmt.Stringer
 	ToProto() *proto.Expression_MaskExpression_Select
+	// GetType returns the type resulting from applying the selection
+	// to the provided parent type.
+	GetType(types.Type) (types.Type, error)
+}
+
+func maskChildType(child MaskSelect, t types.Type) (types.Type, error) {
+	if child == nil {
+		return t, nil
+	}
+	return child.GetType(t)
 }
 
+func maskChildString(child MaskSelect) string {
+	if child == nil {
+		return ""
+	}
+	return child.String()
+}
+
+func maskChildProto(child MaskSelect) *proto.Expression_MaskExpression_Select {
+	if child == nil {
+		return nil
+	}
+	return child.ToProto()
+}
+
+// MaskStructSelect selects a subset of the fields of a struct. The
+// fields must be in increasing order without duplicates.
 type MaskStructSelect []MaskStructItem
 
 func (m MaskStructSelect) toProtoStructSelect() *proto.Expression_MaskExpression_StructSelect {
@@ -403,17 +477,74 @@ func (m MaskStructSelect) ToProto() *proto.Expression_MaskExpression_Select {
 	}
 }
 
+func (m MaskStructSelect) String() string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, item := range m {
+		if i != 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.Itoa(int(item.field)))
+		b.WriteString(maskChildString(item.child))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func (m MaskStructSelect) GetType(parentType types.Type) (types.Type, error) {
+	st, ok := parentType.(*types.StructType)
+	if !ok {
+		return nil, fmt.Errorf("%w: cannot apply struct select to type %s",
+			substraitgo.ErrInvalidType, parentType)
+	}
+
+	if len(m) == 0 {
+		return nil, fmt.Errorf("%w: struct select must select at least one field",
+			substraitgo.ErrInvalidArg)
+	}
+
+	fields := make([]types.Type, len(m))
+	for i, item := range m {
+		if item.field < 0 || int(item.field) >= len(st.Types) {
+			return nil, fmt.Errorf("%w: struct select field %d out of range, only %d fields",
+				substraitgo.ErrInvalidArg, item.field, len(st.Types))
+		}
+
+		if i > 0 && item.field <= m[i-1].field {
+			return nil, fmt.Errorf("%w: struct select fields must be in increasing order, got %d after %d",
+				substraitgo.ErrInvalidArg, item.field, m[i-1].field)
+		}
+
+		var err error
+		if fields[i], err = maskChildType(item.child, st.Types[item.field]); err != nil {
+			return nil, err
+		}
+	}
+
+	return &types.StructType{
+		Nullability:      st.Nullability,
+		TypeVariationRef: st.TypeVariationRef,
+		Types:            fields,
+	}, nil
+}
+
 type MaskStructItem struct {
 	field int32
 	child MaskSelect
 }
 
+// NewMaskStructItem selects the given field of a struct, optionally
+// applying a further selection to it if child is non-nil.
+func NewMaskStructItem(field int32, child MaskSelect) MaskStructItem {
+	return MaskStructItem{field: field, child: child}
+}
+
 func (m *MaskStructItem) Field() int32      { return m.field }
 func (m *MaskStructItem) Child() MaskSelect { return m.child }
 func (m *MaskStructItem) ToProto() *proto.Expression_MaskExpression_StructItem {
 	return &proto.Expression_MaskExpression_StructItem{
 		Field: m.field,
-		Child: m.child.ToProto(),
+		Child: maskChildProto(m.child),
 	}
 }
 
@@ -422,6 +553,13 @@ type MaskListSelect struct {
 	child     MaskSelect
 }
 
+// NewMaskListSelect selects elements of a list by index or slice,
+// optionally applying a further selection to the elements if child
+// is non-nil.
+func NewMaskListSelect(child MaskSelect, selection ...MaskListSelectItem) *MaskListSelect {
+	return &MaskListSelect{selection: selection, child: child}
+}
+
 func (m *MaskListSelect) ToProto() *proto.Expression_MaskExpression_Select {
 	selection := make([]*proto.Expression_MaskExpression_ListSelect_ListSelectItem, len(m.selection))
 	for i, s := range m.selection {
@@ -432,12 +570,50 @@ func (m *MaskListSelect) ToProto() *proto.Expression_MaskExpression_Select {
 		Type: &proto.Expression_MaskExpression_Select_List{
 			List: &proto.Expression_MaskExpression_ListSelect{
 				Selection: selection,
-				Child:     m.child.ToProto(),
+				Child:     maskChildProto(m.child),
 			},
 		},
 	}
 }
 
+func (m *MaskListSelect) String() string {
+	var b strings.Builder
+	b.WriteByte('[')
+	for i, s := range m.selection {
+		if i != 0 {
+			b.WriteByte(',')
+		}
+		switch s := s.(type) {
+		case *MaskListElement:
+			b.WriteString(strconv.Itoa(int(s.Field)))
+		case *MaskListSlice:
+			fmt.Fprintf(&b, "%d:%d", s.Start, s.End)
+		}
+	}
+	b.WriteByte(']')
+	b.WriteString(maskChildString(m.child))
+	return b.String()
+}
+
+func (m *MaskListSelect) GetType(parentType types.Type) (types.Type, error) {
+	lt, ok := parentType.(*types.ListType)
+	if !ok {
+		return nil, fmt.Errorf("%w: cannot apply list select to type %s",
+			substraitgo.ErrInvalidType, parentType)
+	}
+
+	elem, err := maskChildType(m.child, lt.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.ListType{
+		Nullability:      lt.Nullability,
+		TypeVariationRef: lt.TypeVariationRef,
+		Type:             elem,
+	}, nil
+}
+
 func (m *MaskListSelect) Child() MaskSelect { return m.child }
 func (m *MaskListSelect) Selection() []MaskListSelectItem {
 	return slices.Clone(m.selection)
@@ -449,6 +625,11 @@ type MaskListSelectItem interface {
 
 type MaskListElement proto.Expression_MaskExpression_ListSelect_ListSelectItem_ListElement
 
+// NewMaskListElement selects the single list element at the given index.
+func NewMaskListElement(field int32) *MaskListElement {
+	return &MaskListElement{Field: field}
+}
+
 func (m *MaskListElement) GetField() int32 {
 	return m.Field
 }
@@ -463,6 +644,11 @@ func (m *MaskListElement) ToProto() *proto.Expression_MaskExpression_ListSelect_
 
 type MaskListSlice proto.Expression_MaskExpression_ListSelect_ListSelectItem_ListSlice
 
+// NewMaskListSlice selects the list elements in the range [start, end).
+func NewMaskListSlice(start, end int32) *MaskListSlice {
+	return &MaskListSlice{Start: start, End: end}
+}
+
 func (m *MaskListSlice) GetBounds() (start, end int32) {
 	return m.Start, m.End
 }
@@ -488,6 +674,13 @@ type MaskMapSelect struct {
 	key   string
 }
 
+// NewMaskMapSelect selects the entries of a map matching the given key
+// or key expression, depending on kind, optionally applying a further
+// selection to the values if child is non-nil.
+func NewMaskMapSelect(kind MapSelectKind, key string, child MaskSelect) *MaskMapSelect {
+	return &MaskMapSelect{kind: kind, key: key, child: child}
+}
+
 func (m *MaskMapSelect) KeyKind() MapSelectKind { return m.kind }
 func (m *MaskMapSelect) Key() string            { return m.key }
 
@@ -495,10 +688,37 @@ func (m *MaskMapSelect) Child() MaskSelect {
 	return m.child
 }
 
+func (m *MaskMapSelect) String() string {
+	if m.kind == MapSelectExpr {
+		return "[expr:" + m.key + "]" + maskChildString(m.child)
+	}
+	return "[key:" + m.key + "]" + maskChildString(m.child)
+}
+
+func (m *MaskMapSelect) GetType(parentType types.Type) (types.Type, error) {
+	mt, ok := parentType.(*types.MapType)
+	if !ok {
+		return nil, fmt.Errorf("%w: cannot apply map select to type %s",
+			substraitgo.ErrInvalidType, parentType)
+	}
+
+	value, err := maskChildType(m.child, mt.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.MapType{
+		Nullability:      mt.Nullability,
+		TypeVariationRef: mt.TypeVariationRef,
+		Key:              mt.Key,
+		Value:            value,
+	}, nil
+}
+
 func (m *MaskMapSelect) ToProto() *proto.Expression_MaskExpression_Select {
 	ret := &proto.Expression_MaskExpression_Select_Map{
 		Map: &proto.Expression_MaskExpression_MapSelect{
-			Child: m.child.ToProto(),
+			Child: maskChildProto(m.child),
 		},
 	}
 
@@ -541,31 +761,80 @@ func NewFieldRef(root RootRefType, ref Reference, baseSchema *types.StructType)
 			substraitgo.ErrInvalidExpr)
 	}
 
+	var rootType types.Type
+	if root == RootReference {
+		rootType = baseSchema
+	} else if rootExpr, ok := root.(Expression); ok {
+		rootType = rootExpr.GetType()
+	} else {
+		return nil, fmt.Errorf("%w: unknown root reference type %v",
+			substraitgo.ErrInvalidExpr, root)
+	}
+
+	var (
+		typ types.Type
+		err error
+	)
 	switch rt := ref.(type) {
 	case ReferenceSegment:
-		var rootType types.Type
-		if root == RootReference {
-			rootType = baseSchema
-		} else if rootExpr, ok := root.(Expression); ok {
-			rootType = rootExpr.GetType()
-		} else {
-			return nil, fmt.Errorf("%w: unknown root reference type %v",
-				substraitgo.ErrInvalidExpr, root)
-		}
+		typ, err = rt.GetType(rootType)
+	case *MaskExpression:
+		typ, err = rt.GetType(rootType)
+	default:
+		return nil, substraitgo.ErrNotImplemented
+	}
 
-		typ, err := rt.GetType(rootType)
-		if err != nil {
-			return nil, fmt.Errorf("error resolving ref type: %w", err)
-		}
-		return &FieldReference{
-			Reference: ref,
-			Root:      root,
-			knownType: typ,
-		}, nil
+	if err != nil {
+		return nil, fmt.Errorf("error resolving ref type: %w", err)
+	}
+	return &FieldReference{
+		Reference: ref,
+		Root:      root,
+		knownType: typ,
+	}, nil
+}
+
+// NewOuterFieldRef constructs a field reference to a field of an
+// enclosing relation, as used by correlated subqueries. stepsOut is the
+// number of subquery boundaries to step out of and must be at least 1.
+// Since the schema of the outer relation is often not available where
+// the reference is constructed, outerSchema may be nil, in which case
+// the type of the reference is unknown and GetType will return nil.
+func NewOuterFieldRef(stepsOut uint32, ref Reference, outerSchema *types.StructType) (*FieldReference, error) {
+	if stepsOut == 0 {
+		return nil, fmt.Errorf("%w: outer reference must step out at least once",
+			substraitgo.ErrInvalidArg)
+	}
+
+	out := &FieldReference{Reference: ref, Root: OuterReference(stepsOut)}
+	if outerSchema == nil {
+		return out, nil
+	}
+
+	var err error
+	switch rt := ref.(type) {
+	case ReferenceSegment:
+		out.knownType, err = rt.GetType(outerSchema)
 	case *MaskExpression:
+		out.knownType, err = rt.GetType(outerSchema)
+	default:
+		return nil, substraitgo.ErrNotImplemented
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error resolving ref type: %w", err)
 	}
+	return out, nil
+}
 
-	return nil, substraitgo.ErrNotImplemented
+// NewMaskedReference constructs a root field reference using a mask
+// expression which selects the given items from the base schema. The
+// type of the reference is the base schema reduced to the selected
+// fields, or the type of the single selected field if only one is
+// selected and maintainSingular is false. Selected fields must be in
+// range and in increasing order.
+func NewMaskedReference(sel MaskStructSelect, maintainSingular bool, baseSchema *types.StructType) (*FieldReference, error) {
+	return NewRootFieldRef(NewMaskExpression(sel, maintainSingular), baseSchema)
 }
 
 func (*FieldReference) isRootRef() {}
@@ -584,7 +853,7 @@ func (f *FieldReference) String() string {
 	if f.knownType != nil {
 		typ = " => " + f.knownType.String()
 	}
-	return b.String() + f.Reference.(ReferenceSegment).String() + typ
+	return b.String() + f.Reference.(fmt.Stringer).String() + typ
 }
 
 func (f *FieldReference) ToProtoFuncArg() *proto.FunctionArgument {
@@ -675,7 +944,7 @@ func (f *FieldReference) Equals(rhs Expression) bool {
 				return false
 			}
 
-			return ref == rhs
+			return ref.Equals(rhs)
 		}
 	}
 
@@ -698,6 +967,8 @@ func FieldReferenceFromProto(p *proto.Expression_FieldReference, baseSchema type
 		root      RootRefType
 		knownType types.Type
 		err       error
+		// schema is the type the reference is applied to, if known
+		schema types.Type
 	)
 
 	switch rt := p.RootType.(type) {
@@ -707,15 +978,16 @@ func FieldReferenceFromProto(p *proto.Expression_FieldReference, baseSchema type
 		}
 	case *proto.Expression_FieldReference_OuterReference_:
 		root = OuterReference(rt.OuterReference.StepsOut)
+		schema = reg.outerSchema(rt.OuterReference.StepsOut)
 	case *proto.Expression_FieldReference_RootReference_:
-		root = RootReference
+		root, schema = RootReference, baseSchema
 	}
 
 	switch rt := p.ReferenceType.(type) {
 	case *proto.Expression_FieldReference_DirectReference:
 		refseg := RefSegmentFromProto(rt.DirectReference)
-		if root == RootReference && baseSchema != nil {
-			knownType, err = refseg.GetType(baseSchema)
+		if schema != nil {
+			knownType, err = refseg.GetType(schema)
 			if err != nil {
 				return nil, err
 			}
@@ -729,7 +1001,20 @@ func FieldReferenceFromProto(p *proto.Expression_FieldReference, baseSchema type
 		ref = refseg
 
 	case *proto.Expression_FieldReference_MaskedReference:
-		ref = MaskExpressionFromProto(rt.MaskedReference)
+		mask := MaskExpressionFromProto(rt.MaskedReference)
+		if schema != nil {
+			knownType, err = mask.GetType(schema)
+			if err != nil {
+				return nil, err
+			}
+		} else if rootExpr, ok := root.(Expression); ok {
+			knownType, err = mask.GetType(rootExpr.GetType())
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		ref = mask
 	}
 
 	return &FieldReference{Root: root, Reference: ref, knownType: knownType}, nil
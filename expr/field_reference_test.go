@@ -0,0 +1,107 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package expr_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	substraitgo "github.com/substrait-io/substrait-go"
+	"github.com/substrait-io/substrait-go/expr"
+	"github.com/substrait-io/substrait-go/extensions"
+	"github.com/substrait-io/substrait-go/types/parser"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestMaskedReference(t *testing.T) {
+	schema, err := parser.ParseNamedStruct(
+		"NSTRUCT<a: i32, b: string?, c: list<struct<x: i64, y: fp64, z: date>>, d: map<string, struct<p: i8, q: i16>>>")
+	require.NoError(t, err)
+	reg := expr.NewEmptyExtensionRegistry(&extensions.DefaultCollection)
+
+	tests := []struct {
+		name     string
+		sel      expr.MaskStructSelect
+		singular bool
+		str      string
+		typ      string
+	}{
+		{"fields 0 and 2", expr.MaskStructSelect{
+			expr.NewMaskStructItem(0, nil), expr.NewMaskStructItem(2, nil)}, false,
+			".mask{0,2} => struct<i32, list<struct<i64, fp64, date>>>",
+			"struct<i32, list<struct<i64, fp64, date>>>"},
+		{"single unwrapped", expr.MaskStructSelect{expr.NewMaskStructItem(1, nil)}, false,
+			".mask{1} => string?", "string?"},
+		{"single maintained", expr.MaskStructSelect{expr.NewMaskStructItem(1, nil)}, true,
+			".mask(singular){1} => struct<string?>", "struct<string?>"},
+		{"nested list select", expr.MaskStructSelect{
+			expr.NewMaskStructItem(2, expr.NewMaskListSelect(
+				expr.MaskStructSelect{expr.NewMaskStructItem(0, nil), expr.NewMaskStructItem(2, nil)},
+				expr.NewMaskListElement(0), expr.NewMaskListSlice(2, 4)))}, true,
+			".mask(singular){2[0,2:4]{0,2}} => struct<list<struct<i64, date>>>",
+			"struct<list<struct<i64, date>>>"},
+		{"nested map select", expr.MaskStructSelect{
+			expr.NewMaskStructItem(3, expr.NewMaskMapSelect(expr.MapSelectKey, "foo",
+				expr.MaskStructSelect{expr.NewMaskStructItem(1, nil)}))}, false,
+			".mask{3[key:foo]{1}} => map<string, struct<i16>>", "map<string, struct<i16>>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ref, err := expr.NewMaskedReference(tt.sel, tt.singular, &schema.Struct)
+			require.NoError(t, err)
+			assert.Equal(t, tt.str, ref.String())
+			assert.Equal(t, tt.typ, ref.GetType().String())
+
+			p := ref.ToProto()
+			masked := p.GetSelection().GetMaskedReference()
+			require.NotNil(t, masked)
+			assert.Equal(t, tt.singular, masked.MaintainSingularStruct)
+			assert.Len(t, masked.GetSelect().GetStructItems(), len(tt.sel))
+
+			out, err := expr.ExprFromProto(p, &schema.Struct, reg)
+			require.NoError(t, err)
+			assert.Truef(t, ref.Equals(out), "expected: %s\ngot: %s", ref, out)
+			assert.True(t, ref.GetType().Equals(out.GetType()))
+			assert.True(t, proto.Equal(p, out.ToProto()))
+		})
+	}
+}
+
+func TestMaskedReferenceErrors(t *testing.T) {
+	schema, err := parser.ParseNamedStruct("NSTRUCT<a: i32, b: string, c: list<i8>, d: fp32>")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		sel  expr.MaskStructSelect
+		err  error
+		msg  string
+	}{
+		{"empty", expr.MaskStructSelect{}, substraitgo.ErrInvalidArg,
+			"struct select must select at least one field"},
+		{"out of range", expr.MaskStructSelect{expr.NewMaskStructItem(4, nil)}, substraitgo.ErrInvalidArg,
+			"struct select field 4 out of range, only 4 fields"},
+		{"negative", expr.MaskStructSelect{expr.NewMaskStructItem(-1, nil)}, substraitgo.ErrInvalidArg,
+			"struct select field -1 out of range, only 4 fields"},
+		{"unordered", expr.MaskStructSelect{expr.NewMaskStructItem(2, nil), expr.NewMaskStructItem(0, nil)},
+			substraitgo.ErrInvalidArg, "struct select fields must be in increasing order, got 0 after 2"},
+		{"duplicate", expr.MaskStructSelect{expr.NewMaskStructItem(1, nil), expr.NewMaskStructItem(1, nil)},
+			substraitgo.ErrInvalidArg, "struct select fields must be in increasing order, got 1 after 1"},
+		{"list select on non-list", expr.MaskStructSelect{
+			expr.NewMaskStructItem(0, expr.NewMaskListSelect(nil, expr.NewMaskListElement(0)))},
+			substraitgo.ErrInvalidType, "cannot apply list select to type i32"},
+		{"struct select on list", expr.MaskStructSelect{
+			expr.NewMaskStructItem(2, expr.MaskStructSelect{expr.NewMaskStructItem(0, nil)})},
+			substraitgo.ErrInvalidType, "cannot apply struct select to type list<i8>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := expr.NewMaskedReference(tt.sel, false, &schema.Struct)
+			assert.ErrorIs(t, err, tt.err)
+			assert.ErrorContains(t, err, tt.msg)
+		})
+	}
+}
@@ -0,0 +1,323 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package expr
+
+import (
+	"github.com/substrait-io/substrait-go/extensions"
+	"github.com/substrait-io/substrait-go/types"
+)
+
+type (
+	foldInteger interface{ int8 | int16 | int32 | int64 }
+	foldFloat   interface{ float32 | float64 }
+	foldOrdered interface {
+		foldInteger | foldFloat | string
+	}
+
+	// foldFunc evaluates the named function for the non-null literal
+	// arguments, returning the Go value of the result.
+	foldFunc func(name string, args []Literal) (any, bool)
+)
+
+// foldFuncs are the scalar functions which Fold is able to evaluate,
+// keyed by the URI of the extension declaring them and then their name.
+var foldFuncs = map[string]map[string]foldFunc{
+	extensions.SubstraitDefaultURIPrefix + "functions_arithmetic.yaml": {
+		"add":      foldArithmetic,
+		"subtract": foldArithmetic,
+		"multiply": foldArithmetic,
+		"divide":   foldArithmetic,
+		"negate":   foldArithmetic,
+	},
+	extensions.SubstraitDefaultURIPrefix + "functions_comparison.yaml": {
+		"equal":     foldComparison,
+		"not_equal": foldComparison,
+		"lt":        foldComparison,
+		"gt":        foldComparison,
+		"lte":       foldComparison,
+		"gte":       foldComparison,
+	},
+	extensions.SubstraitDefaultURIPrefix + "functions_boolean.yaml": {
+		"and": foldBoolean,
+		"or":  foldBoolean,
+		"xor": foldBoolean,
+		"not": foldBoolean,
+	},
+}
+
+// Fold evaluates an expression composed entirely of literals and calls
+// to the arithmetic (add, subtract, multiply, divide and negate),
+// comparison (equal, not_equal, lt, gt, lte and gte) and boolean (and,
+// or, xor and not) functions of the default extensions, returning the
+// resulting literal. A literal is returned as is.
+//
+// It returns (nil, false) if the expression can't be folded, such as if
+// it contains a field reference or any other function, a function call
+// sets options, an argument is null, or the result would overflow or
+// divide by zero.
+func Fold(e Expression) (Literal, bool) {
+	switch e := e.(type) {
+	case Literal:
+		return e, true
+	case *ScalarFunction:
+		if len(e.options) != 0 {
+			return nil, false
+		}
+
+		fold, ok := foldFuncs[e.ID().URI][e.Name()]
+		if !ok {
+			return nil, false
+		}
+
+		args := make([]Literal, e.NArgs())
+		for i := range args {
+			arg, ok := e.Arg(i).(Expression)
+			if !ok {
+				return nil, false
+			}
+			if args[i], ok = Fold(arg); !ok || args[i].IsNull() {
+				return nil, false
+			}
+		}
+
+		v, ok := fold(e.Name(), args)
+		if !ok {
+			return nil, false
+		}
+		return foldResult(v, e.GetType().GetNullability() == types.NullabilityNullable)
+	}
+	return nil, false
+}
+
+func foldResult(v any, nullable bool) (Literal, bool) {
+	switch v := v.(type) {
+	case bool:
+		return NewPrimitiveLiteral(v, nullable), true
+	case int8:
+		return NewPrimitiveLiteral(v, nullable), true
+	case int16:
+		return NewPrimitiveLiteral(v, nullable), true
+	case int32:
+		return NewPrimitiveLiteral(v, nullable), true
+	case int64:
+		return NewPrimitiveLiteral(v, nullable), true
+	case float32:
+		return NewPrimitiveLiteral(v, nullable), true
+	case float64:
+		return NewPrimitiveLiteral(v, nullable), true
+	}
+	return nil, false
+}
+
+// foldValues returns the values of the literals if all of them are
+// primitive literals of type T.
+func foldValues[T PrimitiveLiteralValue](args []Literal) ([]T, bool) {
+	out := make([]T, len(args))
+	for i, a := range args {
+		p, ok := a.(*PrimitiveLiteral[T])
+		if !ok {
+			return nil, false
+		}
+		out[i] = p.Value
+	}
+	return out, true
+}
+
+func foldArithmetic(name string, args []Literal) (any, bool) {
+	if len(args) == 0 {
+		return nil, false
+	}
+
+	switch args[0].(type) {
+	case *PrimitiveLiteral[int8]:
+		return foldIntArithmetic[int8](name, args)
+	case *PrimitiveLiteral[int16]:
+		return foldIntArithmetic[int16](name, args)
+	case *PrimitiveLiteral[int32]:
+		return foldIntArithmetic[int32](name, args)
+	case *PrimitiveLiteral[int64]:
+		return foldIntArithmetic[int64](name, args)
+	case *PrimitiveLiteral[float32]:
+		return foldFloatArithmetic[float32](name, args)
+	case *PrimitiveLiteral[float64]:
+		return foldFloatArithmetic[float64](name, args)
+	}
+	return nil, false
+}
+
+func foldIntArithmetic[T foldInteger](name string, args []Literal) (any, bool) {
+	vals, ok := foldValues[T](args)
+	if !ok {
+		return nil, false
+	}
+
+	if name == "negate" {
+		// the minimum value is the only one which is its own negation
+		if len(vals) != 1 || (vals[0] != 0 && -vals[0] == vals[0]) {
+			return nil, false
+		}
+		return -vals[0], true
+	}
+
+	if len(vals) != 2 {
+		return nil, false
+	}
+
+	a, b := vals[0], vals[1]
+	switch name {
+	case "add":
+		r := a + b
+		if (b > 0 && r < a) || (b < 0 && r > a) {
+			return nil, false
+		}
+		return r, true
+	case "subtract":
+		r := a - b
+		if (b > 0 && r > a) || (b < 0 && r < a) {
+			return nil, false
+		}
+		return r, true
+	case "multiply":
+		r := a * b
+		if a != 0 && (r/a != b || (a == -1 && b != 0 && r == b)) {
+			return nil, false
+		}
+		return r, true
+	case "divide":
+		// the minimum value divided by -1 overflows
+		if b == 0 || (b == -1 && a != 0 && -a == a) {
+			return nil, false
+		}
+		return a / b, true
+	}
+	return nil, false
+}
+
+func foldFloatArithmetic[T foldFloat](name string, args []Literal) (any, bool) {
+	vals, ok := foldValues[T](args)
+	if !ok {
+		return nil, false
+	}
+
+	if name == "negate" {
+		if len(vals) != 1 {
+			return nil, false
+		}
+		return -vals[0], true
+	}
+
+	if len(vals) != 2 {
+		return nil, false
+	}
+
+	a, b := vals[0], vals[1]
+	switch name {
+	case "add":
+		return a + b, true
+	case "subtract":
+		return a - b, true
+	case "multiply":
+		return a * b, true
+	case "divide":
+		if b == 0 {
+			return nil, false
+		}
+		return a / b, true
+	}
+	return nil, false
+}
+
+func foldComparison(name string, args []Literal) (any, bool) {
+	if len(args) != 2 {
+		return nil, false
+	}
+
+	switch args[0].(type) {
+	case *PrimitiveLiteral[bool]:
+		vals, ok := foldValues[bool](args)
+		if !ok {
+			return nil, false
+		}
+		switch name {
+		case "equal":
+			return vals[0] == vals[1], true
+		case "not_equal":
+			return vals[0] != vals[1], true
+		}
+		return nil, false
+	case *PrimitiveLiteral[int8]:
+		return foldCompare[int8](name, args)
+	case *PrimitiveLiteral[int16]:
+		return foldCompare[int16](name, args)
+	case *PrimitiveLiteral[int32]:
+		return foldCompare[int32](name, args)
+	case *PrimitiveLiteral[int64]:
+		return foldCompare[int64](name, args)
+	case *PrimitiveLiteral[float32]:
+		return foldCompare[float32](name, args)
+	case *PrimitiveLiteral[float64]:
+		return foldCompare[float64](name, args)
+	case *PrimitiveLiteral[string]:
+		return foldCompare[string](name, args)
+	}
+	return nil, false
+}
+
+func foldCompare[T foldOrdered](name string, args []Literal) (any, bool) {
+	vals, ok := foldValues[T](args)
+	if !ok {
+		return nil, false
+	}
+
+	a, b := vals[0], vals[1]
+	switch name {
+	case "equal":
+		return a == b, true
+	case "not_equal":
+		return a != b, true
+	case "lt":
+		return a < b, true
+	case "gt":
+		return a > b, true
+	case "lte":
+		return a <= b, true
+	case "gte":
+		return a >= b, true
+	}
+	return nil, false
+}
+
+func foldBoolean(name string, args []Literal) (any, bool) {
+	vals, ok := foldValues[bool](args)
+	if !ok {
+		return nil, false
+	}
+
+	switch name {
+	case "and":
+		for _, v := range vals {
+			if !v {
+				return false, true
+			}
+		}
+		return true, true
+	case "or":
+		for _, v := range vals {
+			if v {
+				return true, true
+			}
+		}
+		return false, true
+	case "xor":
+		if len(vals) != 2 {
+			return nil, false
+		}
+		return vals[0] != vals[1], true
+	case "not":
+		if len(vals) != 1 {
+			return nil, false
+		}
+		return !vals[0], true
+	}
+	return nil, false
+}
@@ -49,6 +49,8 @@ func FuncArgsEqual(a, b types.FuncArg) bool {
 type (
 	SortField struct {
 		Expr Expression
+		// Kind is either a types.SortDirection or the types.FunctionRef
+		// of a custom comparison function, such as for a collation.
 		Kind types.SortKind
 	}
 
@@ -60,6 +62,24 @@ type (
 	FollowingBound int64
 	CurrentRow     struct{}
 	Unbounded      struct{}
+
+	// WindowBoundsType determines whether the bounds of a window function
+	// are a number of rows or a range of values of the sort field.
+	WindowBoundsType = proto.Expression_WindowFunction_BoundsType
+
+	// WindowBounds is the frame of rows a window function is computed
+	// over, relative to the current row. A nil Lower or Upper bound
+	// leaves it unspecified.
+	WindowBounds struct {
+		Type         WindowBoundsType
+		Lower, Upper Bound
+	}
+)
+
+const (
+	BoundsTypeUnspecified = proto.Expression_WindowFunction_BOUNDS_TYPE_UNSPECIFIED
+	BoundsTypeRows        = proto.Expression_WindowFunction_BOUNDS_TYPE_ROWS
+	BoundsTypeRange       = proto.Expression_WindowFunction_BOUNDS_TYPE_RANGE
 )
 
 func (s *SortField) ToProto() *proto.SortField {
@@ -173,10 +193,14 @@ func NewCustomScalarFunc(reg ExtensionRegistry, v *extensions.ScalarFunctionVari
 
 type variant interface {
 	*extensions.ScalarFunctionVariant | *extensions.AggregateFunctionVariant | *extensions.WindowFunctionVariant
+	Args() extensions.ArgumentList
 	ResolveType([]types.Type) (types.Type, error)
 }
 
-func resolveVariant[T variant](id extensions.ID, reg ExtensionRegistry, getter func(extensions.ID) (T, bool), args []types.FuncArg) (T, types.Type, error) {
+// funcArgTypes returns the types of the arguments for resolving a function
+// variant, enum arguments have a nil type and type arguments are their
+// own type.
+func funcArgTypes(args []types.FuncArg) []types.Type {
 	argTypes := make([]types.Type, 0, len(args))
 	for _, arg := range args {
 		switch a := arg.(type) {
@@ -184,8 +208,41 @@ func resolveVariant[T variant](id extensions.ID, reg ExtensionRegistry, getter f
 			argTypes = append(argTypes, nil)
 		case Expression:
 			argTypes = append(argTypes, a.GetType())
+		case types.Type:
+			argTypes = append(argTypes, a)
 		}
 	}
+	return argTypes
+}
+
+// checkArgKinds checks that type arguments are only passed for the type
+// parameters of a function and value arguments only for its other
+// parameters, as they can't be told apart by their types alone.
+func checkArgKinds(params extensions.ArgumentList, args []types.FuncArg) error {
+	if len(params) == 0 {
+		return nil
+	}
+
+	for i, arg := range args {
+		_, typeParam := params[min(i, len(params)-1)].(extensions.TypeArg)
+		switch arg.(type) {
+		case Expression:
+			if typeParam {
+				return fmt.Errorf("%w: arg #%d should be a type, got a value",
+					substraitgo.ErrInvalidType, i)
+			}
+		case types.Type:
+			if !typeParam {
+				return fmt.Errorf("%w: arg #%d should not be a type",
+					substraitgo.ErrInvalidType, i)
+			}
+		}
+	}
+	return nil
+}
+
+func resolveVariant[T variant](id extensions.ID, reg ExtensionRegistry, getter func(extensions.ID) (T, bool), args []types.FuncArg) (T, types.Type, error) {
+	argTypes := funcArgTypes(args)
 
 	decl, found := getter(id)
 	if !found {
@@ -195,6 +252,8 @@ func resolveVariant[T variant](id extensions.ID, reg ExtensionRegistry, getter f
 				if t == nil {
 					// enum value
 					sigs[i] = "req"
+				} else if _, ok := args[i].(types.Type); ok {
+					sigs[i] = "type"
 				} else if ud, ok := t.(*types.UserDefinedType); ok {
 					id, found := reg.DecodeType(ud.TypeReference)
 					if !found {
@@ -217,6 +276,10 @@ func resolveVariant[T variant](id extensions.ID, reg ExtensionRegistry, getter f
 		}
 	}
 
+	if err := checkArgKinds(decl.Args(), args); err != nil {
+		return nil, nil, err
+	}
+
 	outType, err := decl.ResolveType(argTypes)
 	if err != nil {
 		return nil, nil, err
@@ -420,6 +483,7 @@ type WindowFunction struct {
 	invocation types.AggregationInvocation
 	Partitions []Expression
 
+	BoundsType             WindowBoundsType
 	LowerBound, UpperBound Bound
 }
 
@@ -465,6 +529,83 @@ func NewWindowFunc(reg ExtensionRegistry, id extensions.ID, opts []*types.Functi
 	}, nil
 }
 
+// NewWindowFunction constructs a window function expression for the
+// provided declaration, computed over the given partitions, sorts and
+// bounds. The argument types are validated against the declaration
+// and the output type is derived from it. The registry is used to
+// provide the function anchor.
+func NewWindowFunction(reg ExtensionRegistry, decl *extensions.WindowFunctionVariant, args []types.FuncArg, partitions []Expression, sorts []SortField, bounds WindowBounds) (*WindowFunction, error) {
+	if decl == nil {
+		return nil, fmt.Errorf("%w: must provide non-nil window function declaration",
+			substraitgo.ErrInvalidExpr)
+	}
+
+	argTypes := funcArgTypes(args)
+	if !slices.Contains(argTypes, nil) {
+		ok, err := decl.Match(argTypes)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("%w: argument types %v do not match window function %s",
+				substraitgo.ErrInvalidArg, argTypes, decl.CompoundName())
+		}
+	}
+
+	outType, err := decl.ResolveType(argTypes)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving output type of %s: %w",
+			decl.CompoundName(), err)
+	}
+
+	for i, p := range partitions {
+		if p == nil {
+			return nil, fmt.Errorf("%w: partition %d of window function %s is nil",
+				substraitgo.ErrInvalidExpr, i, decl.Name())
+		}
+	}
+
+	for i, s := range sorts {
+		if s.Expr == nil {
+			return nil, fmt.Errorf("%w: sort field %d of window function %s has nil expression",
+				substraitgo.ErrInvalidExpr, i, decl.Name())
+		}
+	}
+
+	if _, ok := proto.Expression_WindowFunction_BoundsType_name[int32(bounds.Type)]; !ok {
+		return nil, fmt.Errorf("%w: invalid bounds type %d", substraitgo.ErrInvalidArg, bounds.Type)
+	}
+
+	for _, b := range []Bound{bounds.Lower, bounds.Upper} {
+		switch b := b.(type) {
+		case PrecedingBound:
+			if b < 0 {
+				return nil, fmt.Errorf("%w: preceding bound offset must not be negative, got %d",
+					substraitgo.ErrInvalidArg, b)
+			}
+		case FollowingBound:
+			if b < 0 {
+				return nil, fmt.Errorf("%w: following bound offset must not be negative, got %d",
+					substraitgo.ErrInvalidArg, b)
+			}
+		}
+	}
+
+	return &WindowFunction{
+		funcRef:     reg.GetFuncAnchor(decl.ID()),
+		declaration: decl,
+		args:        args,
+		outputType:  outType,
+		phase:       types.AggPhaseInitialToResult,
+		invocation:  types.AggInvocationAll,
+		Partitions:  partitions,
+		Sorts:       sorts,
+		BoundsType:  bounds.Type,
+		LowerBound:  bounds.Lower,
+		UpperBound:  bounds.Upper,
+	}, nil
+}
+
 func (w *WindowFunction) Name() string                            { return w.declaration.Name() }
 func (w *WindowFunction) CompoundName() string                    { return w.declaration.CompoundName() }
 func (w *WindowFunction) ID() extensions.ID                       { return w.declaration.ID() }
@@ -473,6 +614,7 @@ func (w *WindowFunction) SessionDependant() bool                  { return w.dec
 func (w *WindowFunction) Deterministic() bool                     { return w.declaration.Deterministic() }
 func (w *WindowFunction) NArgs() int                              { return len(w.args) }
 func (w *WindowFunction) Arg(i int) types.FuncArg                 { return w.args[i] }
+func (w *WindowFunction) FuncRef() uint32                         { return w.funcRef }
 func (w *WindowFunction) Phase() types.AggregationPhase           { return w.phase }
 func (w *WindowFunction) Invocation() types.AggregationInvocation { return w.invocation }
 func (w *WindowFunction) Decomposable() extensions.DecomposeType {
@@ -554,6 +696,8 @@ func (w *WindowFunction) Equals(other Expression) bool {
 		return false
 	case w.phase != rhs.phase || w.invocation != rhs.invocation:
 		return false
+	case w.BoundsType != rhs.BoundsType:
+		return false
 	case w.LowerBound != rhs.LowerBound || w.UpperBound != rhs.UpperBound:
 		return false
 	case !slices.EqualFunc(w.options, rhs.options, func(l, r *types.FunctionOption) bool {
@@ -624,6 +768,7 @@ func (w *WindowFunction) ToProto() *proto.Expression {
 				Partitions:        parts,
 				LowerBound:        lowerBound,
 				UpperBound:        upperBound,
+				BoundsType:        w.BoundsType,
 			},
 		},
 	}
@@ -768,6 +913,7 @@ func (a *AggregateFunction) SessionDependant() bool                  { return a.
 func (a *AggregateFunction) Deterministic() bool                     { return a.declaration.Deterministic() }
 func (a *AggregateFunction) NArgs() int                              { return len(a.args) }
 func (a *AggregateFunction) Arg(i int) types.FuncArg                 { return a.args[i] }
+func (a *AggregateFunction) FuncRef() uint32                         { return a.funcRef }
 func (a *AggregateFunction) Phase() types.AggregationPhase           { return a.phase }
 func (a *AggregateFunction) Invocation() types.AggregationInvocation { return a.invocation }
 func (a *AggregateFunction) Decomposable() extensions.DecomposeType {
@@ -809,6 +955,114 @@ func (a *AggregateFunction) GetOption(name string) []string {
 
 func (a *AggregateFunction) GetType() types.Type { return a.outputType }
 
+// WithInvocation returns a copy of this function with the given
+// invocation, determining whether it is applied to all values or
+// only to the distinct values of its input.
+func (a *AggregateFunction) WithInvocation(invocation types.AggregationInvocation) *AggregateFunction {
+	out := *a
+	out.invocation = invocation
+	return &out
+}
+
+// WithPhase returns a copy of this function for the given phase of a
+// multi-stage aggregation. The phases which produce intermediate results,
+// AggPhaseInitialToIntermediate and AggPhaseIntermediateToIntermediate,
+// output the intermediate type of the function declaration rather than
+// its return type. An error is returned if the phase is invalid or the
+// output type cannot be resolved, such as for a function with a custom
+// declaration.
+func (a *AggregateFunction) WithPhase(phase types.AggregationPhase) (*AggregateFunction, error) {
+	if a.declaration == nil {
+		return nil, fmt.Errorf("%w: cannot resolve output type for phase %s without function declaration",
+			substraitgo.ErrInvalidExpr, phase)
+	}
+
+	var (
+		outType types.Type
+		err     error
+	)
+	switch phase {
+	case types.AggPhaseInitialToIntermediate, types.AggPhaseIntermediateToIntermediate:
+		outType, err = a.declaration.ResolveIntermediateType(funcArgTypes(a.args))
+	case types.AggPhaseUnspecified, types.AggPhaseInitialToResult, types.AggPhaseIntermediateToResult:
+		outType, err = a.declaration.ResolveType(funcArgTypes(a.args))
+	default:
+		return nil, fmt.Errorf("%w: invalid aggregation phase %s", substraitgo.ErrInvalidArg, phase)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error resolving output type of %s for phase %s: %w",
+			a.declaration.CompoundName(), phase, err)
+	}
+
+	out := *a
+	out.phase = phase
+	out.outputType = outType
+	return &out, nil
+}
+
+// WithSorts returns a copy of this function whose input is ordered by
+// the given sort fields before being aggregated, as for
+// string_agg(x ORDER BY y).
+func (a *AggregateFunction) WithSorts(sorts ...SortField) *AggregateFunction {
+	out := *a
+	out.Sorts = slices.Clone(sorts)
+	return &out
+}
+
+// Visit returns a copy of this function with each of its expression
+// arguments and the expression of each of its sort fields replaced by
+// the result of calling visit with it, or the function itself if none
+// of them changed. An AggregateFunction isn't an Expression, so this is
+// called directly with the measures of an aggregation.
+func (a *AggregateFunction) Visit(visit VisitFunc) *AggregateFunction {
+	var args []types.FuncArg
+	for i, arg := range a.args {
+		var after types.FuncArg
+		switch t := arg.(type) {
+		case Expression:
+			after = visit(t)
+		default:
+			after = arg
+		}
+
+		if args == nil && arg != after {
+			args = make([]types.FuncArg, len(a.args))
+			for j := 0; j < i; j++ {
+				args[j] = a.args[j]
+			}
+		}
+
+		if args != nil {
+			args[i] = after
+		}
+	}
+
+	var sorts []SortField
+	for i, s := range a.Sorts {
+		after := visit(s.Expr)
+		if sorts == nil && after != s.Expr {
+			sorts = slices.Clone(a.Sorts)
+		}
+		if sorts != nil {
+			sorts[i].Expr = after
+		}
+	}
+
+	if args == nil && sorts == nil {
+		return a
+	}
+
+	out := *a
+	if args != nil {
+		out.args = args
+	}
+	if sorts != nil {
+		out.Sorts = sorts
+	}
+	return &out
+}
+
 func (a *AggregateFunction) ToProto() *proto.AggregateFunction {
 	var (
 		args  []*proto.FunctionArgument
@@ -35,7 +35,9 @@ func (m IntervalCompoundLiteral) ToProtoLiteral() *proto.Expression_Literal {
 		intrCompPB.IntervalYearToMonth = yearToMonthProto
 	}
 
-	if m.Days != 0 || m.Seconds != 0 || m.SubSeconds != 0 {
+	// the precision is part of the type, so the day to second part is
+	// also written for a non-default precision to preserve it
+	if m.Days != 0 || m.Seconds != 0 || m.SubSeconds != 0 || m.SubSecondPrecision != types.PrecisionSeconds {
 		dayToSecondProto := &proto.Expression_Literal_IntervalDayToSecond{
 			Days:          m.Days,
 			Seconds:       m.Seconds,
@@ -108,6 +110,7 @@ func (m IntervalCompoundLiteral) ToProtoFuncArg() *proto.FunctionArgument {
 
 func (m IntervalCompoundLiteral) Visit(VisitFunc) Expression { return m }
 func (IntervalCompoundLiteral) IsScalar() bool               { return true }
+func (IntervalCompoundLiteral) IsNull() bool                 { return false }
 
 func validateIntervalDayToSecondProto(idts *proto.Expression_Literal_IntervalDayToSecond) error {
 	if idts.PrecisionMode == nil {
@@ -66,3 +66,4 @@ func (m IntervalYearToMonthLiteral) ToProtoFuncArg() *proto.FunctionArgument {
 
 func (m IntervalYearToMonthLiteral) Visit(VisitFunc) Expression { return m }
 func (IntervalYearToMonthLiteral) IsScalar() bool               { return true }
+func (IntervalYearToMonthLiteral) IsNull() bool                 { return false }
@@ -75,6 +75,8 @@ type Literal interface {
 	fmt.Stringer
 
 	IsScalar() bool
+	// IsNull returns true if this literal is a typed null
+	IsNull() bool
 	// GetType returns the full Type of the literal value
 	GetType() types.Type
 	// Equals only returns true if the rhs is a literal of the exact
@@ -91,6 +93,7 @@ type NullLiteral struct {
 }
 
 func (*NullLiteral) IsScalar() bool { return true }
+func (*NullLiteral) IsNull() bool   { return true }
 
 func (*NullLiteral) isRootRef() {}
 func (n *NullLiteral) String() string {
@@ -99,11 +102,7 @@ func (n *NullLiteral) String() string {
 
 func (n *NullLiteral) GetType() types.Type { return n.Type }
 func (n *NullLiteral) ToProtoLiteral() *proto.Expression_Literal {
-	return &proto.Expression_Literal{
-		Nullable:               true,
-		TypeVariationReference: n.Type.GetTypeVariationReference(),
-		LiteralType:            &proto.Expression_Literal_Null{Null: types.TypeToProto(n.Type)},
-	}
+	return types.NullLiteralToProto(n.Type)
 }
 
 func (n *NullLiteral) ToProto() *proto.Expression {
@@ -203,6 +202,7 @@ func (t *PrimitiveLiteral[T]) ToProtoFuncArg() *proto.FunctionArgument {
 
 func (t *PrimitiveLiteral[T]) Visit(VisitFunc) Expression { return t }
 func (*PrimitiveLiteral[T]) IsScalar() bool               { return true }
+func (*PrimitiveLiteral[T]) IsNull() bool                 { return false }
 
 // NestedLiteral is either a Struct or List literal, both of which are
 // represented as a slice of other literals.
@@ -276,6 +276,7 @@ func (t *NestedLiteral[T]) Visit(VisitFunc) Expression {
 	return t
 }
 func (*NestedLiteral[T]) IsScalar() bool { return true }
+func (*NestedLiteral[T]) IsNull() bool   { return false }
 
 // MapLiteral is represented as a slice of Key/Value structs consisting
 // of other literals.
@@ -302,8 +303,10 @@ func (t *MapLiteral) ToProtoLiteral() *proto.Expression_Literal {
 	} else {
 		kv := make([]*proto.Expression_Literal_Map_KeyValue, len(t.Value))
 		for i, v := range t.Value {
-			kv[i].Key = v.Key.ToProtoLiteral()
-			kv[i].Value = v.Value.ToProtoLiteral()
+			kv[i] = &proto.Expression_Literal_Map_KeyValue{
+				Key:   v.Key.ToProtoLiteral(),
+				Value: v.Value.ToProtoLiteral(),
+			}
 		}
 
 		lit.LiteralType = &proto.Expression_Literal_Map_{
@@ -338,6 +341,7 @@ func (t *MapLiteral) ToProtoFuncArg() *proto.FunctionArgument {
 
 func (t *MapLiteral) Visit(VisitFunc) Expression { return t }
 func (*MapLiteral) IsScalar() bool               { return true }
+func (*MapLiteral) IsNull() bool                 { return false }
 
 // ByteSliceLiteral is any literal that is represnted as a byte slice.
 // As opposed to a string literal which can be compared with ==, a byte
@@ -393,6 +397,7 @@ func (t *ByteSliceLiteral[T]) ToProtoFuncArg() *proto.FunctionArgument {
 
 func (t *ByteSliceLiteral[T]) Visit(VisitFunc) Expression { return t }
 func (*ByteSliceLiteral[T]) IsScalar() bool               { return true }
+func (*ByteSliceLiteral[T]) IsNull() bool                 { return false }
 
 // ProtoLiteral is a literal that is represented using its protobuf
 // message type such as a Decimal or UserDefinedType.
@@ -423,14 +428,19 @@ func (t *ProtoLiteral) ToProtoLiteral() *proto.Expression_Literal {
 			params[i] = p.ToProto()
 		}
 
-		v := t.Value.(*anypb.Any)
-		lit.LiteralType = &proto.Expression_Literal_UserDefined_{
-			UserDefined: &proto.Expression_Literal_UserDefined{
-				Val:            &proto.Expression_Literal_UserDefined_Value{Value: v},
-				TypeReference:  literalType.TypeReference,
-				TypeParameters: params,
-			},
+		ud := &proto.Expression_Literal_UserDefined{
+			TypeReference:  literalType.TypeReference,
+			TypeParameters: params,
+		}
+		switch v := t.Value.(type) {
+		case *anypb.Any:
+			ud.Val = &proto.Expression_Literal_UserDefined_Value{Value: v}
+		case *proto.Expression_Literal_UserDefined_Value:
+			ud.Val = v
+		case *proto.Expression_Literal_UserDefined_Struct:
+			ud.Val = v
 		}
+		lit.LiteralType = &proto.Expression_Literal_UserDefined_{UserDefined: ud}
 	case *types.IntervalYearType:
 		v := t.Value.(*types.IntervalYearToMonth)
 		lit.LiteralType = &proto.Expression_Literal_IntervalYearToMonth_{
@@ -459,25 +469,45 @@ func (t *ProtoLiteral) ToProtoLiteral() *proto.Expression_Literal {
 			},
 		}
 	case *types.PrecisionTimestampType:
-		v := t.Value.(uint64)
+		v := precisionTimestampValue(t.Value)
 		lit.LiteralType = &proto.Expression_Literal_PrecisionTimestamp_{
 			PrecisionTimestamp: &proto.Expression_Literal_PrecisionTimestamp{
 				Precision: literalType.GetPrecisionProtoVal(),
-				Value:     int64(v),
+				Value:     v,
 			},
 		}
 	case *types.PrecisionTimestampTzType:
-		v := t.Value.(uint64)
+		v := precisionTimestampValue(t.Value)
 		lit.LiteralType = &proto.Expression_Literal_PrecisionTimestampTz{
 			PrecisionTimestampTz: &proto.Expression_Literal_PrecisionTimestamp{
 				Precision: literalType.GetPrecisionProtoVal(),
-				Value:     int64(v),
+				Value:     v,
 			},
 		}
 	}
 	return lit
 }
 
+// userDefinedValue returns the value to store in a ProtoLiteral for a
+// user defined literal, which is the *anypb.Any payload unless the
+// literal uses the struct representation.
+func userDefinedValue(ud *proto.Expression_Literal_UserDefined) any {
+	if v, ok := ud.Val.(*proto.Expression_Literal_UserDefined_Value); ok {
+		return v.Value
+	}
+	return ud.Val
+}
+
+// precisionTimestampValue returns the value of a precision timestamp
+// literal, which is an int64 when constructed by this package but may
+// have been provided as a uint64.
+func precisionTimestampValue(v any) int64 {
+	if u, ok := v.(uint64); ok {
+		return int64(u)
+	}
+	return v.(int64)
+}
+
 func (t *ProtoLiteral) ToProto() *proto.Expression {
 	return &proto.Expression{RexType: &proto.Expression_Literal_{
 		Literal: t.ToProtoLiteral(),
@@ -500,6 +530,7 @@ func (t *ProtoLiteral) ToProtoFuncArg() *proto.FunctionArgument {
 
 func (t *ProtoLiteral) Visit(VisitFunc) Expression { return t }
 func (*ProtoLiteral) IsScalar() bool               { return true }
+func (*ProtoLiteral) IsNull() bool                 { return false }
 
 func getNullability(nullable bool) types.Nullability {
 	if nullable {
@@ -538,6 +569,18 @@ type (
 	ListLiteral   = NestedLiteral[ListLiteralValue]
 )
 
+// unionNullability returns the first of the provided types, marked as
+// nullable if any of the types are nullable. This is used to determine
+// the element type of a list, or the key and value types of a map.
+func unionNullability(typs []types.Type) types.Type {
+	for _, t := range typs {
+		if t.GetNullability() == types.NullabilityNullable {
+			return typs[0].WithNullability(types.NullabilityNullable)
+		}
+	}
+	return typs[0]
+}
+
 // NewNestedLiteral constructs a new literal value and marks whether the
 // type should be considered nullable. This assumes that the passed in
 // value is not empty, so len(v) MUST be > 0.
@@ -697,7 +740,7 @@ func NewLiteral[T allLiteralTypes](val T, nullable bool) (Literal, error) {
 		}
 
 		return &ProtoLiteral{
-			Value: v.Val,
+			Value: userDefinedValue(v),
 			Type: &types.UserDefinedType{
 				Nullability:    getNullability(nullable),
 				TypeReference:  v.TypeReference,
@@ -887,29 +930,34 @@ func LiteralFromProto(l *proto.Expression_Literal) Literal {
 			}}
 	case *proto.Expression_Literal_Map_:
 		ret := make(MapLiteralValue, len(lit.Map.KeyValues))
+		keyTypes := make([]types.Type, len(lit.Map.KeyValues))
+		valueTypes := make([]types.Type, len(lit.Map.KeyValues))
 		for i, kv := range lit.Map.KeyValues {
 			ret[i].Key = LiteralFromProto(kv.Key)
 			ret[i].Value = LiteralFromProto(kv.Value)
+			keyTypes[i], valueTypes[i] = ret[i].Key.GetType(), ret[i].Value.GetType()
 		}
 		return &MapLiteral{
 			Value: ret,
 			Type: &types.MapType{
 				Nullability:      nullability,
 				TypeVariationRef: l.TypeVariationReference,
-				Key:              ret[0].Key.GetType(),
-				Value:            ret[0].Value.GetType(),
+				Key:              unionNullability(keyTypes),
+				Value:            unionNullability(valueTypes),
 			}}
 	case *proto.Expression_Literal_List_:
 		ret := make(ListLiteralValue, len(lit.List.Values))
+		elemTypes := make([]types.Type, len(lit.List.Values))
 		for i, v := range lit.List.Values {
 			ret[i] = LiteralFromProto(v)
+			elemTypes[i] = ret[i].GetType()
 		}
 		return &NestedLiteral[ListLiteralValue]{
 			Value: ListLiteralValue(ret),
 			Type: &types.ListType{
 				Nullability:      nullability,
 				TypeVariationRef: l.TypeVariationReference,
-				Type:             ret[0].GetType(),
+				Type:             unionNullability(elemTypes),
 			}}
 	case *proto.Expression_Literal_EmptyList:
 		return &NestedLiteral[ListLiteralValue]{
@@ -935,7 +983,7 @@ func LiteralFromProto(l *proto.Expression_Literal) Literal {
 		}
 
 		return &ProtoLiteral{
-			Value: lit.UserDefined.Val,
+			Value: userDefinedValue(lit.UserDefined),
 			Type: &types.UserDefinedType{
 				Nullability:      nullability,
 				TypeVariationRef: l.TypeVariationReference,
@@ -0,0 +1,114 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package expr
+
+import (
+	"fmt"
+	"math"
+
+	substraitgo "github.com/substrait-io/substrait-go"
+	"github.com/substrait-io/substrait-go/proto"
+	"github.com/substrait-io/substrait-go/types"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// ParameterTypeReference is the type reference of the user defined
+// literals which represent a Parameter in protobuf. This version of
+// Substrait has no expression for dynamic parameters, so a parameter is
+// serialized as a user defined literal with this reserved reference,
+// the type of the parameter as its only type parameter and the index of
+// the parameter as its value, wrapped in a google.protobuf.Int32Value.
+//
+// No extension type is declared for this reference, so other consumers
+// reject such a literal. It only allows an expression with parameters
+// to be read back by ExprFromProto, plan.Plan.ToProto refuses to
+// serialize a plan whose parameters haven't been bound.
+const ParameterTypeReference uint32 = math.MaxUint32
+
+// Parameter is a placeholder for a value which is supplied later, such
+// as a parameter of a prepared statement. A plan containing parameters
+// is built once and then executed with different values by binding
+// them with plan.BindParameters, which replaces each parameter with the
+// literal at its index.
+type Parameter struct {
+	Index int32
+	Type  types.Type
+}
+
+// NewParameter constructs a placeholder for the parameter at the index,
+// which must be bound to a value of type t.
+func NewParameter(index int32, t types.Type) (*Parameter, error) {
+	if index < 0 {
+		return nil, fmt.Errorf("%w: parameter index must not be negative, got %d",
+			substraitgo.ErrInvalidExpr, index)
+	}
+	if t == nil {
+		return nil, fmt.Errorf("%w: parameter %d must have a type",
+			substraitgo.ErrInvalidExpr, index)
+	}
+	return &Parameter{Index: index, Type: t}, nil
+}
+
+func (ex *Parameter) String() string {
+	return fmt.Sprintf("$%d => %s", ex.Index, ex.Type)
+}
+
+func (ex *Parameter) ToProtoFuncArg() *proto.FunctionArgument {
+	return &proto.FunctionArgument{
+		ArgType: &proto.FunctionArgument_Value{Value: ex.ToProto()},
+	}
+}
+
+func (ex *Parameter) isRootRef() {}
+
+func (ex *Parameter) IsScalar() bool { return true }
+
+func (ex *Parameter) GetType() types.Type { return ex.Type }
+
+func (ex *Parameter) ToProto() *proto.Expression {
+	// marshalling an Int32Value can't fail
+	value, _ := anypb.New(wrapperspb.Int32(ex.Index))
+	return &proto.Expression{
+		RexType: &proto.Expression_Literal_{
+			Literal: &proto.Expression_Literal{
+				Nullable: ex.Type.GetNullability() == types.NullabilityNullable,
+				LiteralType: &proto.Expression_Literal_UserDefined_{
+					UserDefined: &proto.Expression_Literal_UserDefined{
+						TypeReference: ParameterTypeReference,
+						TypeParameters: []*proto.Type_Parameter{{
+							Parameter: &proto.Type_Parameter_DataType{DataType: types.TypeToProto(ex.Type)},
+						}},
+						Val: &proto.Expression_Literal_UserDefined_Value{Value: value},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (ex *Parameter) Equals(other Expression) bool {
+	rhs, ok := other.(*Parameter)
+	if !ok {
+		return false
+	}
+	return ex.Index == rhs.Index && ex.Type.Equals(rhs.Type)
+}
+
+func (ex *Parameter) Visit(VisitFunc) Expression { return ex }
+
+// ParameterFromProto returns the Parameter represented by the literal,
+// as serialized by Parameter.ToProto, if it is one.
+func ParameterFromProto(lit *proto.Expression_Literal) (*Parameter, bool) {
+	ud := lit.GetUserDefined()
+	if ud == nil || ud.TypeReference != ParameterTypeReference || len(ud.TypeParameters) != 1 {
+		return nil, false
+	}
+
+	dataType := ud.TypeParameters[0].GetDataType()
+	var index wrapperspb.Int32Value
+	if dataType == nil || ud.GetValue().UnmarshalTo(&index) != nil {
+		return nil, false
+	}
+	return &Parameter{Index: index.Value, Type: types.TypeFromProto(dataType)}, true
+}
@@ -0,0 +1,327 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package expr
+
+import (
+	"fmt"
+	"strings"
+
+	substraitgo "github.com/substrait-io/substrait-go"
+	"github.com/substrait-io/substrait-go/proto"
+	"github.com/substrait-io/substrait-go/types"
+	"golang.org/x/exp/slices"
+	pb "google.golang.org/protobuf/proto"
+)
+
+// Rel is the subset of a relation that is needed by subquery
+// expressions. It is satisfied by plan.Rel, and is declared here
+// rather than importing the plan package to avoid an import cycle.
+type Rel interface {
+	RecordType() types.StructType
+	Remap(types.StructType) types.StructType
+	ToProto() *proto.Rel
+}
+
+// relOutputType returns the record type of the relation after any
+// output mapping (emit) has been applied.
+func relOutputType(rel Rel) types.StructType {
+	return rel.Remap(rel.RecordType())
+}
+
+// RelFromProtoFunc converts a protobuf relation into a Rel so that
+// subquery expressions can be deserialized.
+type RelFromProtoFunc func(*proto.Rel, ExtensionRegistry) (Rel, error)
+
+var relFromProto RelFromProtoFunc
+
+// RegisterRelFromProto sets the function used by ExprFromProto to
+// convert the relations contained in subquery expressions. The plan
+// package registers plan.RelFromProto during initialization, so this
+// only needs to be called when providing a custom implementation.
+func RegisterRelFromProto(fn RelFromProtoFunc) { relFromProto = fn }
+
+func subqueryFromProto(s *proto.Expression_Subquery, baseSchema types.Type, reg ExtensionRegistry) (Expression, error) {
+	if relFromProto == nil {
+		return nil, fmt.Errorf("%w: no relation converter registered for subquery, import the plan package",
+			substraitgo.ErrNotImplemented)
+	}
+
+	switch st := s.SubqueryType.(type) {
+	case *proto.Expression_Subquery_InPredicate_:
+		needles := make([]Expression, len(st.InPredicate.Needles))
+		for i, n := range st.InPredicate.Needles {
+			var err error
+			if needles[i], err = ExprFromProto(n, baseSchema, reg); err != nil {
+				return nil, err
+			}
+		}
+
+		haystack, err := relFromProto(st.InPredicate.Haystack, reg.withOuterSchema(baseSchema))
+		if err != nil {
+			return nil, err
+		}
+
+		return NewInPredicate(needles, haystack)
+	case *proto.Expression_Subquery_SetPredicate_:
+		tuples, err := relFromProto(st.SetPredicate.Tuples, reg.withOuterSchema(baseSchema))
+		if err != nil {
+			return nil, err
+		}
+
+		return NewSetPredicate(st.SetPredicate.PredicateOp, tuples)
+	}
+
+	return nil, fmt.Errorf("%w: subquery: %s", substraitgo.ErrNotImplemented, s)
+}
+
+// InPredicate is a subquery expression checking whether the tuple
+// formed by its needles is present in the output of the haystack
+// relation, such as:
+//
+//	x IN (SELECT a FROM t)
+//	(x, y) IN (SELECT a, b FROM t)
+type InPredicate struct {
+	Needles  []Expression
+	Haystack Rel
+}
+
+// NewInPredicate constructs an InPredicate subquery expression. The
+// number of needles must match the number of columns output by the
+// haystack, and each needle type must match the corresponding column
+// type, ignoring nullability.
+func NewInPredicate(needles []Expression, haystack Rel) (*InPredicate, error) {
+	if haystack == nil {
+		return nil, fmt.Errorf("%w: haystack relation must not be nil",
+			substraitgo.ErrInvalidExpr)
+	}
+
+	if len(needles) == 0 {
+		return nil, fmt.Errorf("%w: in predicate must have at least one needle",
+			substraitgo.ErrInvalidExpr)
+	}
+
+	cols := relOutputType(haystack).Types
+	if len(needles) != len(cols) {
+		return nil, fmt.Errorf("%w: in predicate has %d needles but subquery outputs %d columns",
+			substraitgo.ErrInvalidExpr, len(needles), len(cols))
+	}
+
+	for i, n := range needles {
+		if n == nil {
+			return nil, fmt.Errorf("%w: in predicate needle %d is nil",
+				substraitgo.ErrInvalidExpr, i)
+		}
+
+		if n.GetType() == nil {
+			return nil, fmt.Errorf("%w: in predicate needle %d has unknown type",
+				substraitgo.ErrInvalidExpr, i)
+		}
+
+		if !types.EqualsIgnoreNullability(n.GetType(), cols[i]) {
+			return nil, fmt.Errorf("%w: in predicate needle %d has type %s, subquery column has type %s",
+				substraitgo.ErrInvalidExpr, i, n.GetType(), cols[i])
+		}
+	}
+
+	return &InPredicate{Needles: needles, Haystack: haystack}, nil
+}
+
+func (ex *InPredicate) String() string {
+	var b strings.Builder
+	if len(ex.Needles) == 1 {
+		b.WriteString(ex.Needles[0].String())
+	} else {
+		b.WriteByte('(')
+		for i, n := range ex.Needles {
+			if i != 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(n.String())
+		}
+		b.WriteByte(')')
+	}
+	rt := relOutputType(ex.Haystack)
+	b.WriteString(" IN (subquery: ")
+	b.WriteString(rt.String())
+	b.WriteByte(')')
+	return b.String()
+}
+
+func (ex *InPredicate) ToProtoFuncArg() *proto.FunctionArgument {
+	return &proto.FunctionArgument{
+		ArgType: &proto.FunctionArgument_Value{Value: ex.ToProto()},
+	}
+}
+
+func (*InPredicate) isRootRef() {}
+
+func (ex *InPredicate) IsScalar() bool {
+	for _, n := range ex.Needles {
+		if !n.IsScalar() {
+			return false
+		}
+	}
+	return true
+}
+
+// GetType returns a boolean type which is nullable if any of the
+// needles or subquery columns are nullable. A needle of unknown type is
+// assumed to be nullable.
+func (ex *InPredicate) GetType() types.Type {
+	nullability := types.NullabilityRequired
+	for _, n := range ex.Needles {
+		if t := n.GetType(); t == nil || t.GetNullability() == types.NullabilityNullable {
+			nullability = types.NullabilityNullable
+		}
+	}
+	for _, c := range relOutputType(ex.Haystack).Types {
+		if c.GetNullability() == types.NullabilityNullable {
+			nullability = types.NullabilityNullable
+		}
+	}
+
+	return &types.BooleanType{Nullability: nullability}
+}
+
+func (ex *InPredicate) ToProto() *proto.Expression {
+	needles := make([]*proto.Expression, len(ex.Needles))
+	for i, n := range ex.Needles {
+		needles[i] = n.ToProto()
+	}
+
+	return &proto.Expression{
+		RexType: &proto.Expression_Subquery_{
+			Subquery: &proto.Expression_Subquery{
+				SubqueryType: &proto.Expression_Subquery_InPredicate_{
+					InPredicate: &proto.Expression_Subquery_InPredicate{
+						Needles:  needles,
+						Haystack: ex.Haystack.ToProto(),
+					},
+				},
+			},
+		},
+	}
+}
+
+func (ex *InPredicate) Equals(other Expression) bool {
+	rhs, ok := other.(*InPredicate)
+	if !ok {
+		return false
+	}
+
+	if !slices.EqualFunc(ex.Needles, rhs.Needles, exprEqual) {
+		return false
+	}
+
+	return pb.Equal(ex.Haystack.ToProto(), rhs.Haystack.ToProto())
+}
+
+// Visit only visits the needles of the predicate, expressions within
+// the haystack relation are not visited.
+func (ex *InPredicate) Visit(visit VisitFunc) Expression {
+	var out *InPredicate
+	for i, n := range ex.Needles {
+		temp := visit(n)
+		if out == nil && temp != n {
+			out = &InPredicate{Haystack: ex.Haystack, Needles: make([]Expression, len(ex.Needles))}
+			copy(out.Needles, ex.Needles[:i])
+		}
+
+		if out != nil {
+			out.Needles[i] = temp
+		}
+	}
+
+	if out == nil {
+		return ex
+	}
+	return out
+}
+
+type SetPredicateOp = proto.Expression_Subquery_SetPredicate_PredicateOp
+
+const (
+	SetPredicateOpUnspecified = proto.Expression_Subquery_SetPredicate_PREDICATE_OP_UNSPECIFIED
+	SetPredicateOpExists      = proto.Expression_Subquery_SetPredicate_PREDICATE_OP_EXISTS
+	SetPredicateOpUnique      = proto.Expression_Subquery_SetPredicate_PREDICATE_OP_UNIQUE
+)
+
+// SetPredicate is a subquery expression which is a predicate over the
+// set of rows output by a relation, such as EXISTS or UNIQUE.
+type SetPredicate struct {
+	Op     SetPredicateOp
+	Tuples Rel
+}
+
+// NewSetPredicate constructs a SetPredicate subquery expression using
+// the given operation (SetPredicateOpExists or SetPredicateOpUnique).
+func NewSetPredicate(op SetPredicateOp, tuples Rel) (*SetPredicate, error) {
+	if tuples == nil {
+		return nil, fmt.Errorf("%w: set predicate relation must not be nil",
+			substraitgo.ErrInvalidExpr)
+	}
+
+	switch op {
+	case SetPredicateOpExists, SetPredicateOpUnique:
+	default:
+		return nil, fmt.Errorf("%w: invalid set predicate operation: %s",
+			substraitgo.ErrInvalidExpr, op)
+	}
+
+	return &SetPredicate{Op: op, Tuples: tuples}, nil
+}
+
+func (ex *SetPredicate) String() string {
+	var op string
+	switch ex.Op {
+	case SetPredicateOpExists:
+		op = "EXISTS"
+	case SetPredicateOpUnique:
+		op = "UNIQUE"
+	default:
+		op = ex.Op.String()
+	}
+
+	rt := relOutputType(ex.Tuples)
+	return op + "(subquery: " + rt.String() + ")"
+}
+
+func (ex *SetPredicate) ToProtoFuncArg() *proto.FunctionArgument {
+	return &proto.FunctionArgument{
+		ArgType: &proto.FunctionArgument_Value{Value: ex.ToProto()},
+	}
+}
+
+func (*SetPredicate) isRootRef() {}
+
+func (*SetPredicate) IsScalar() bool { return true }
+
+func (*SetPredicate) GetType() types.Type {
+	return &types.BooleanType{Nullability: types.NullabilityRequired}
+}
+
+func (ex *SetPredicate) ToProto() *proto.Expression {
+	return &proto.Expression{
+		RexType: &proto.Expression_Subquery_{
+			Subquery: &proto.Expression_Subquery{
+				SubqueryType: &proto.Expression_Subquery_SetPredicate_{
+					SetPredicate: &proto.Expression_Subquery_SetPredicate{
+						PredicateOp: ex.Op,
+						Tuples:      ex.Tuples.ToProto(),
+					},
+				},
+			},
+		},
+	}
+}
+
+func (ex *SetPredicate) Equals(other Expression) bool {
+	rhs, ok := other.(*SetPredicate)
+	if !ok {
+		return false
+	}
+
+	return ex.Op == rhs.Op && pb.Equal(ex.Tuples.ToProto(), rhs.Tuples.ToProto())
+}
+
+func (ex *SetPredicate) Visit(VisitFunc) Expression { return ex }
@@ -2,11 +2,19 @@
 
 package expr
 
-import "github.com/substrait-io/substrait-go/extensions"
+import (
+	"github.com/substrait-io/substrait-go/extensions"
+	"github.com/substrait-io/substrait-go/types"
+)
 
 type ExtensionRegistry struct {
 	extensions.Set
 	c *extensions.Collection
+
+	// outer holds the schemas of the relations enclosing the subqueries
+	// being converted by FromProto, innermost last, so that the type of
+	// an outer reference can be resolved.
+	outer []types.Type
 }
 
 func NewExtensionRegistry(extSet extensions.Set, c *extensions.Collection) ExtensionRegistry {
@@ -20,6 +28,28 @@ func NewEmptyExtensionRegistry(c *extensions.Collection) ExtensionRegistry {
 	return NewExtensionRegistry(extensions.NewSet(), c)
 }
 
+// withOuterSchema returns a copy of the registry for converting a
+// subquery within an expression evaluated against schema.
+func (e ExtensionRegistry) withOuterSchema(schema types.Type) ExtensionRegistry {
+	e.outer = append(e.outer[:len(e.outer):len(e.outer)], schema)
+	return e
+}
+
+// outerSchema returns the schema an outer reference stepping out of
+// steps subqueries refers to, or nil if it isn't known.
+func (e *ExtensionRegistry) outerSchema(steps uint32) types.Type {
+	if steps == 0 || int(steps) > len(e.outer) {
+		return nil
+	}
+	return e.outer[len(e.outer)-int(steps)]
+}
+
+// Collection returns the collection of extensions used to look up the
+// declarations of the anchors in the registry.
+func (e *ExtensionRegistry) Collection() *extensions.Collection {
+	return e.c
+}
+
 func (e *ExtensionRegistry) LookupTypeVariation(anchor uint32) (extensions.TypeVariation, bool) {
 	return e.Set.LookupTypeVariation(anchor, e.c)
 }
@@ -0,0 +1,71 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package expr
+
+import (
+	"fmt"
+
+	substraitgo "github.com/substrait-io/substrait-go"
+)
+
+// Visitor translates expressions into values of type T, such as nodes of
+// the IR of an execution engine. Visit calls the method for the kind of
+// expression it's given, and it's up to the method to call Visit on any
+// children it needs translated, so the visitor controls the order of
+// traversal and how the results of the children are combined.
+type Visitor[T any] interface {
+	VisitLiteral(Literal) T
+	VisitParameter(*Parameter) T
+	VisitFieldReference(*FieldReference) T
+	VisitScalarFunction(*ScalarFunction) T
+	VisitWindowFunction(*WindowFunction) T
+	VisitIfThen(*IfThen) T
+	VisitSwitch(*SwitchExpr) T
+	VisitSingularOrList(*SingularOrList) T
+	VisitMultiOrList(*MultiOrList) T
+	VisitCast(*Cast) T
+	VisitInPredicate(*InPredicate) T
+	VisitSetPredicate(*SetPredicate) T
+	VisitMap(*MapExpr) T
+	VisitStruct(*StructExpr) T
+	VisitList(*ListExpr) T
+}
+
+// Visit calls the method of v for the kind of e, returning its result.
+// It panics if e is nil or is not one of the kinds of expression handled
+// by Visitor.
+func Visit[T any](e Expression, v Visitor[T]) T {
+	switch e := e.(type) {
+	case *Parameter:
+		return v.VisitParameter(e)
+	case Literal:
+		return v.VisitLiteral(e)
+	case *FieldReference:
+		return v.VisitFieldReference(e)
+	case *ScalarFunction:
+		return v.VisitScalarFunction(e)
+	case *WindowFunction:
+		return v.VisitWindowFunction(e)
+	case *IfThen:
+		return v.VisitIfThen(e)
+	case *SwitchExpr:
+		return v.VisitSwitch(e)
+	case *SingularOrList:
+		return v.VisitSingularOrList(e)
+	case *MultiOrList:
+		return v.VisitMultiOrList(e)
+	case *Cast:
+		return v.VisitCast(e)
+	case *InPredicate:
+		return v.VisitInPredicate(e)
+	case *SetPredicate:
+		return v.VisitSetPredicate(e)
+	case *MapExpr:
+		return v.VisitMap(e)
+	case *StructExpr:
+		return v.VisitStruct(e)
+	case *ListExpr:
+		return v.VisitList(e)
+	}
+	panic(fmt.Errorf("%w: expr.Visit: %T", substraitgo.ErrNotImplemented, e))
+}
@@ -0,0 +1,162 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package expr_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/substrait-io/substrait-go/expr"
+	ext "github.com/substrait-io/substrait-go/extensions"
+	"github.com/substrait-io/substrait-go/types"
+)
+
+// sqlVisitor renders expressions as SQL, naming the fields of the root
+// struct with columns.
+type sqlVisitor struct {
+	columns []string
+}
+
+var sqlOperators = map[string]string{
+	"add": "+", "subtract": "-", "multiply": "*", "divide": "/",
+	"equal": "=", "not_equal": "<>", "lt": "<", "gt": ">", "lte": "<=", "gte": ">=",
+	"and": "AND", "or": "OR",
+}
+
+func (v sqlVisitor) visitAll(exprs []expr.Expression) string {
+	out := make([]string, len(exprs))
+	for i, e := range exprs {
+		out[i] = expr.Visit[string](e, v)
+	}
+	return strings.Join(out, ", ")
+}
+
+func (v sqlVisitor) VisitLiteral(l expr.Literal) string {
+	switch l := l.(type) {
+	case *expr.PrimitiveLiteral[string]:
+		return "'" + strings.ReplaceAll(l.Value, "'", "''") + "'"
+	case *expr.PrimitiveLiteral[bool]:
+		return strings.ToUpper(fmt.Sprint(l.Value))
+	case *expr.PrimitiveLiteral[int32]:
+		return fmt.Sprint(l.Value)
+	}
+	if l.IsNull() {
+		return "NULL"
+	}
+	return l.String()
+}
+
+func (v sqlVisitor) VisitParameter(p *expr.Parameter) string {
+	return fmt.Sprintf("$%d", p.Index+1)
+}
+
+func (v sqlVisitor) VisitFieldReference(ref *expr.FieldReference) string {
+	return v.columns[ref.Reference.(*expr.StructFieldRef).Field]
+}
+
+func (v sqlVisitor) VisitScalarFunction(fn *expr.ScalarFunction) string {
+	args := make([]string, fn.NArgs())
+	for i := range args {
+		args[i] = expr.Visit[string](fn.Arg(i).(expr.Expression), v)
+	}
+	if op, ok := sqlOperators[fn.Name()]; ok && len(args) == 2 {
+		return "(" + args[0] + " " + op + " " + args[1] + ")"
+	}
+	return strings.ToUpper(fn.Name()) + "(" + strings.Join(args, ", ") + ")"
+}
+
+func (v sqlVisitor) VisitWindowFunction(fn *expr.WindowFunction) string {
+	return fn.String()
+}
+
+func (v sqlVisitor) VisitIfThen(ex *expr.IfThen) string {
+	var sb strings.Builder
+	sb.WriteString("CASE")
+	for i := 0; i < ex.NIfs(); i++ {
+		pair := ex.IfPair(i)
+		sb.WriteString(" WHEN " + expr.Visit[string](pair.If, v) +
+			" THEN " + expr.Visit[string](pair.Then, v))
+	}
+	sb.WriteString(" ELSE " + expr.Visit[string](ex.Else(), v) + " END")
+	return sb.String()
+}
+
+func (v sqlVisitor) VisitSwitch(ex *expr.SwitchExpr) string {
+	var sb strings.Builder
+	sb.WriteString("CASE " + expr.Visit[string](ex.MatchExpr(), v))
+	for i := 0; i < ex.NCases(); i++ {
+		c := ex.Case(i)
+		sb.WriteString(" WHEN " + expr.Visit[string](c.If, v) +
+			" THEN " + expr.Visit[string](c.Then, v))
+	}
+	sb.WriteString(" ELSE " + expr.Visit[string](ex.Else(), v) + " END")
+	return sb.String()
+}
+
+func (v sqlVisitor) VisitSingularOrList(ex *expr.SingularOrList) string {
+	return expr.Visit[string](ex.Value, v) + " IN (" + v.visitAll(ex.Options) + ")"
+}
+
+func (v sqlVisitor) VisitMultiOrList(ex *expr.MultiOrList) string {
+	return ex.String()
+}
+
+func (v sqlVisitor) VisitCast(ex *expr.Cast) string {
+	return "CAST(" + expr.Visit[string](ex.Input, v) + " AS " + strings.ToUpper(ex.Type.ShortString()) + ")"
+}
+
+func (v sqlVisitor) VisitInPredicate(ex *expr.InPredicate) string {
+	return ex.String()
+}
+
+func (v sqlVisitor) VisitSetPredicate(ex *expr.SetPredicate) string {
+	return ex.String()
+}
+
+func (v sqlVisitor) VisitMap(ex *expr.MapExpr) string {
+	return ex.String()
+}
+
+func (v sqlVisitor) VisitStruct(ex *expr.StructExpr) string {
+	return "ROW(" + v.visitAll(ex.Fields) + ")"
+}
+
+func (v sqlVisitor) VisitList(ex *expr.ListExpr) string {
+	return "ARRAY[" + v.visitAll(ex.Values) + "]"
+}
+
+func TestVisitor(t *testing.T) {
+	reg := expr.NewEmptyExtensionRegistry(&ext.DefaultCollection)
+	schema := &types.StructType{Types: []types.Type{
+		&types.Int32Type{Nullability: types.NullabilityRequired},
+		&types.StringType{Nullability: types.NullabilityRequired},
+	}}
+	call := func(uri, name string, args ...types.FuncArg) expr.Expression {
+		return expr.MustExpr(expr.NewScalarFunc(reg, ext.ID{
+			URI: ext.SubstraitDefaultURIPrefix + uri, Name: name}, nil, args...))
+	}
+	x := expr.MustExpr(expr.NewRootFieldRef(expr.NewStructFieldRef(0), schema))
+	name := expr.MustExpr(expr.NewRootFieldRef(expr.NewStructFieldRef(1), schema))
+	param, err := expr.NewParameter(0, &types.Int32Type{Nullability: types.NullabilityRequired})
+	require.NoError(t, err)
+
+	in, err := expr.NewSingularOrList(name, []expr.Expression{
+		expr.NewPrimitiveLiteral("a", false), expr.NewPrimitiveLiteral("it's", false)})
+	require.NoError(t, err)
+	cast, err := expr.NewCast(x, &types.StringType{Nullability: types.NullabilityRequired}, expr.CastFailThrowException)
+	require.NoError(t, err)
+	ifThen, err := expr.NewIfThen(expr.IfThenPair{
+		If: call("functions_boolean.yaml", "and",
+			call("functions_comparison.yaml", "gt",
+				call("functions_arithmetic.yaml", "add", x, expr.NewPrimitiveLiteral(int32(1), false)), param),
+			in),
+		Then: cast,
+	}, name)
+	require.NoError(t, err)
+
+	assert.Equal(t, "CASE WHEN (((x + 1) > $1) AND name IN ('a', 'it''s')) THEN CAST(x AS STR) ELSE name END",
+		expr.Visit[string](ifThen, sqlVisitor{columns: []string{"x", "name"}}))
+}
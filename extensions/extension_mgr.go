@@ -3,6 +3,7 @@
 package extensions
 
 import (
+	"bytes"
 	"embed"
 	"fmt"
 	"io"
@@ -20,9 +21,9 @@ type AdvancedExtension = extensions.AdvancedExtension
 const SubstraitDefaultURIPrefix = "https://github.com/substrait-io/substrait/blob/main/extensions/"
 
 // DefaultCollection is loaded with the default Substrait extension
-// definitions with the exception of decimal arithmetic. Decimal arithmetic
-// functions are missing as the complex return type expressions are not
-// yet implemented.
+// definitions with the exception of decimal arithmetic, which is not
+// bundled. Its return type derivations are supported (see
+// parser.ReturnProgram), so it can be loaded with Collection.Load.
 var DefaultCollection Collection
 
 //go:embed definitions/*
@@ -155,14 +156,14 @@ func (c *Collection) Load(uri string, r io.Reader) error {
 			substraitgo.ErrKeyExists, uri)
 	}
 
-	c.uriSet[uri] = void
-
 	var file SimpleExtensionFile
 	dec := yaml.NewDecoder(r)
 	if err := dec.Decode(&file); err != nil {
 		return err
 	}
 
+	c.uriSet[uri] = void
+
 	id := ID{URI: uri}
 	for _, t := range file.Types {
 		id.Name = t.Name
@@ -209,6 +210,62 @@ func (c *Collection) Load(uri string, r io.Reader) error {
 	return nil
 }
 
+// NewMergedCollection returns a new collection containing the functions,
+// types and type variations of all of the provided collections, such as
+// DefaultCollection along with a collection of custom extensions, so that
+// it can be used anywhere a single collection is accepted (e.g. by
+// plan.FromProto).
+//
+// If more than one collection has a function, type or type variation with
+// the same URI and name, the one from the earliest collection is used.
+// The same applies to looking up a function by its simple name. The
+// merged collection is a copy, functions loaded into any of the provided
+// collections afterwards are not reflected in it.
+func NewMergedCollection(collections ...*Collection) *Collection {
+	var out Collection
+	out.init()
+	for _, c := range collections {
+		if c == nil || c.uriSet == nil {
+			continue
+		}
+
+		mergeMap(out.uriSet, c.uriSet)
+		mergeMap(out.simpleNameMap, c.simpleNameMap)
+		mergeMap(out.scalarMap, c.scalarMap)
+		mergeMap(out.aggregateMap, c.aggregateMap)
+		mergeMap(out.windowMap, c.windowMap)
+		mergeMap(out.typeMap, c.typeMap)
+		mergeMap(out.typeVariationMap, c.typeVariationMap)
+	}
+	return &out
+}
+
+// mergeMap adds the entries of src to dst which aren't already in it.
+func mergeMap[M ~map[K]V, K comparable, V any](dst, src M) {
+	for k, v := range src {
+		if _, ok := dst[k]; !ok {
+			dst[k] = v
+		}
+	}
+}
+
+// LoadCollection returns a new collection with the functions, types and
+// type variations of the extension YAML read from r registered under the
+// given uri. It is the same as calling Load on an empty collection.
+func LoadCollection(uri string, r io.Reader) (*Collection, error) {
+	var c Collection
+	if err := c.Load(uri, r); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// AddFromBytes is the same as Load, only reading the extension YAML
+// from data.
+func (c *Collection) AddFromBytes(uri string, data []byte) error {
+	return c.Load(uri, bytes.NewReader(data))
+}
+
 func (c *Collection) URILoaded(uri string) bool {
 	_, ok := c.uriSet[uri]
 	return ok
@@ -321,12 +378,12 @@ func (e *set) ToProto() ([]*extensions.SimpleExtensionURI, []*extensions.SimpleE
 		})
 	}
 
-	typeDecls := decls[typesCount:]
-	sort.Slice(typeDecls, func(i, j int) bool {
-		return decls[i].GetExtensionTypeVariation().TypeVariationAnchor < decls[j].GetExtensionTypeVariation().TypeVariationAnchor
+	typeVarDecls := decls[typesCount:]
+	sort.Slice(typeVarDecls, func(i, j int) bool {
+		return typeVarDecls[i].GetExtensionTypeVariation().TypeVariationAnchor < typeVarDecls[j].GetExtensionTypeVariation().TypeVariationAnchor
 	})
 
-	typeVarCount := len(decls)
+	funcsStart := len(decls)
 	for id, anchor := range e.funcs {
 		decls = append(decls, &extensions.SimpleExtensionDeclaration{
 			MappingType: &extensions.SimpleExtensionDeclaration_ExtensionFunction_{
@@ -339,9 +396,9 @@ func (e *set) ToProto() ([]*extensions.SimpleExtensionURI, []*extensions.SimpleE
 		})
 	}
 
-	typeVarDecls := decls[typeVarCount:]
-	sort.Slice(typeVarDecls, func(i, j int) bool {
-		return decls[i].GetExtensionFunction().GetFunctionAnchor() < decls[j].GetExtensionFunction().GetFunctionAnchor()
+	funcDecls := decls[funcsStart:]
+	sort.Slice(funcDecls, func(i, j int) bool {
+		return funcDecls[i].GetExtensionFunction().GetFunctionAnchor() < funcDecls[j].GetExtensionFunction().GetFunctionAnchor()
 	})
 
 	return uris, decls
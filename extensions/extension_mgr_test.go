@@ -8,6 +8,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	substraitgo "github.com/substrait-io/substrait-go"
 	"github.com/substrait-io/substrait-go/extensions"
 	"github.com/substrait-io/substrait-go/types"
 )
@@ -156,6 +157,100 @@ func TestLoadExtensionCollection(t *testing.T) {
 	})
 }
 
+const customYAML = `---
+scalar_functions:
+  - name: "haversine"
+    description: "Great circle distance between two points."
+    impls:
+      - args:
+          - name: lat1
+            value: fp64
+          - name: lon1
+            value: fp64
+          - name: lat2
+            value: fp64
+          - name: lon2
+            value: fp64
+        return: fp64
+`
+
+func TestLoadCustomCollection(t *testing.T) {
+	const uri = "http://localhost/custom.yaml"
+
+	c, err := extensions.LoadCollection(uri, strings.NewReader(customYAML))
+	require.NoError(t, err)
+	assert.True(t, c.URILoaded(uri))
+
+	fp64 := &types.Float64Type{Nullability: types.NullabilityRequired}
+	argTypes := []types.Type{fp64, fp64, fp64, fp64}
+	fn, err := c.ResolveScalar(uri, "haversine", argTypes)
+	require.NoError(t, err)
+	assert.Equal(t, "haversine:fp64_fp64_fp64_fp64", fn.CompoundName())
+	assert.Equal(t, uri, fn.URI())
+	out, err := fn.ResolveType(argTypes)
+	require.NoError(t, err)
+	assert.Equal(t, "fp64", out.String())
+
+	// registering the same uri again is an error
+	err = c.AddFromBytes(uri, []byte(customYAML))
+	assert.ErrorIs(t, err, substraitgo.ErrKeyExists)
+
+	// the custom functions can be added alongside the standard ones
+	var combined extensions.Collection
+	require.NoError(t, combined.AddFromBytes(extensions.SubstraitDefaultURIPrefix+"functions_boolean.yaml",
+		[]byte("---\nscalar_functions: []\n")))
+	require.NoError(t, combined.AddFromBytes(uri, []byte(customYAML)))
+	_, ok := combined.GetScalarFunc(extensions.ID{URI: uri, Name: "haversine"})
+	assert.True(t, ok)
+
+	// a uri which fails to parse is not registered
+	_, err = extensions.LoadCollection(uri, strings.NewReader("scalar_functions: {"))
+	assert.Error(t, err)
+	err = combined.AddFromBytes("http://localhost/bad.yaml", []byte("scalar_functions: {"))
+	assert.Error(t, err)
+	assert.False(t, combined.URILoaded("http://localhost/bad.yaml"))
+}
+
+func TestMergedCollection(t *testing.T) {
+	const uri = "http://localhost/custom.yaml"
+	custom, err := extensions.LoadCollection(uri, strings.NewReader(customYAML))
+	require.NoError(t, err)
+
+	merged := extensions.NewMergedCollection(&extensions.DefaultCollection, custom)
+	assert.True(t, merged.URILoaded(uri))
+	assert.True(t, merged.URILoaded(extensions.SubstraitDefaultURIPrefix+"functions_arithmetic.yaml"))
+
+	// only in the second collection
+	fn, ok := merged.GetScalarFunc(extensions.ID{URI: uri, Name: "haversine"})
+	require.True(t, ok)
+	assert.Equal(t, "haversine:fp64_fp64_fp64_fp64", fn.CompoundName())
+
+	// only in the first collection
+	_, ok = merged.GetScalarFunc(extensions.ID{
+		URI: extensions.SubstraitDefaultURIPrefix + "functions_arithmetic.yaml", Name: "add:i32_i32"})
+	assert.True(t, ok)
+	assert.Len(t, merged.GetAllScalarFunctions(),
+		len(extensions.DefaultCollection.GetAllScalarFunctions())+1)
+
+	// the earlier collection takes precedence on conflicts
+	other, err := extensions.LoadCollection(uri, strings.NewReader(strings.ReplaceAll(customYAML,
+		"Great circle distance between two points.", "shadowed")))
+	require.NoError(t, err)
+	merged = extensions.NewMergedCollection(custom, other)
+	fn, ok = merged.GetScalarFunc(extensions.ID{URI: uri, Name: "haversine"})
+	require.True(t, ok)
+	assert.Equal(t, "Great circle distance between two points.", fn.Description())
+
+	merged = extensions.NewMergedCollection(other, custom)
+	fn, ok = merged.GetScalarFunc(extensions.ID{URI: uri, Name: "haversine"})
+	require.True(t, ok)
+	assert.Equal(t, "shadowed", fn.Description())
+
+	// the merged collection is independent of its members
+	require.NoError(t, merged.AddFromBytes("http://localhost/more.yaml", []byte(customYAML)))
+	assert.False(t, custom.URILoaded("http://localhost/more.yaml"))
+}
+
 func TestExtensionSet(t *testing.T) {
 	const uri = "http://localhost/sample.yaml"
 
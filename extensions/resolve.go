@@ -0,0 +1,237 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package extensions
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	substraitgo "github.com/substrait-io/substrait-go"
+	"github.com/substrait-io/substrait-go/types"
+	"github.com/substrait-io/substrait-go/types/integer_parameters"
+	"github.com/substrait-io/substrait-go/types/parser"
+)
+
+type resolvable interface {
+	variants
+	Args() ArgumentList
+	Variadic() *VariadicBehavior
+	Nullability() NullabilityHandling
+}
+
+// ResolveScalar returns the variant of the scalar function with the given
+// simple name in the extension file at uri whose parameters match the
+// provided argument types, using a nil type for an enum argument. A
+// variant matches if:
+//
+//   - the number of arguments matches, taking into account the minimum and
+//     maximum occurrences of the last parameter if the variant is variadic
+//   - each argument type matches the type of its parameter, ignoring the
+//     nullability unless the variant uses discrete nullability
+//   - type parameters bind consistently across all of the arguments, such
+//     as the same type for each use of any1, or the same P for decimal<P, S>
+//   - variadic arguments which must be consistent all have the same type
+//
+// If several variants match, the one with the most arguments matched by a
+// concrete rather than a wildcard or parameterized type is returned. If
+// that still doesn't decide between them, an error wrapping
+// substraitgo.ErrInvalidArg listing the candidates is returned. If no
+// variant matches, the error wraps substraitgo.ErrNotFound.
+func (c *Collection) ResolveScalar(uri, name string, argTypes []types.Type) (*ScalarFunctionVariant, error) {
+	return resolveOverload("scalar", uri, name, c.scalarMap, argTypes)
+}
+
+// ResolveAggregate returns the variant of the aggregate function with the
+// given simple name in the extension file at uri whose arguments match
+// the provided argument types, in the same way as ResolveScalar.
+func (c *Collection) ResolveAggregate(uri, name string, argTypes []types.Type) (*AggregateFunctionVariant, error) {
+	return resolveOverload("aggregate", uri, name, c.aggregateMap, argTypes)
+}
+
+// ResolveWindow returns the variant of the window function with the given
+// simple name in the extension file at uri whose arguments match the
+// provided argument types, in the same way as ResolveScalar.
+func (c *Collection) ResolveWindow(uri, name string, argTypes []types.Type) (*WindowFunctionVariant, error) {
+	return resolveOverload("window", uri, name, c.windowMap, argTypes)
+}
+
+// resolveOverload implements the overload resolution for the Resolve
+// methods of the collection, see ResolveScalar.
+func resolveOverload[T resolvable](kind, uri, name string, m map[ID]T, argTypes []types.Type) (T, error) {
+	var candidates []T
+	for id, v := range m {
+		if id.URI == uri && v.Name() == name {
+			candidates = append(candidates, v)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("%w: %s function %s not found in %s",
+			substraitgo.ErrNotFound, kind, name, uri)
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].CompoundName() < candidates[j].CompoundName()
+	})
+
+	var (
+		best      []T
+		bestScore = -1
+	)
+	for _, v := range candidates {
+		score, ok := matchOverload(v.Nullability(), v.Args(), v.Variadic(), argTypes)
+		switch {
+		case !ok || score < bestScore:
+		case score > bestScore:
+			best, bestScore = []T{v}, score
+		default:
+			best = append(best, v)
+		}
+	}
+
+	switch len(best) {
+	case 0:
+		return nil, fmt.Errorf("%w: no variant of %s function %s in %s matches argument types (%s), candidates: %s",
+			substraitgo.ErrNotFound, kind, name, uri, typeList(argTypes), compoundNames(candidates))
+	case 1:
+		return best[0], nil
+	}
+
+	return nil, fmt.Errorf("%w: ambiguous %s function %s for argument types (%s), candidates: %s",
+		substraitgo.ErrInvalidArg, kind, name, typeList(argTypes), compoundNames(best))
+}
+
+func typeList(argTypes []types.Type) string {
+	out := make([]string, len(argTypes))
+	for i, t := range argTypes {
+		if t == nil {
+			out[i] = "enum"
+		} else {
+			out[i] = t.String()
+		}
+	}
+	return strings.Join(out, ", ")
+}
+
+func compoundNames[T variants](vs []T) string {
+	out := make([]string, len(vs))
+	for i, v := range vs {
+		out[i] = v.CompoundName()
+	}
+	return strings.Join(out, ", ")
+}
+
+// typeBindings tracks the concrete values bound to the type parameters
+// of a function declaration while matching its arguments.
+type typeBindings struct {
+	anys map[string]types.Type
+	ints map[string]int32
+}
+
+func (b *typeBindings) bindAny(name string, t types.Type) bool {
+	// a bare "any" is unconstrained, numbered ones (any1, any2, ...)
+	// must be the same type everywhere they are used
+	if name == "any" {
+		return true
+	}
+
+	if bound, ok := b.anys[name]; ok {
+		return types.EqualsIgnoreNullability(bound, t)
+	}
+	b.anys[name] = t
+	return true
+}
+
+func (b *typeBindings) bindInt(p integer_parameters.IntegerParameter, v int32) bool {
+	param, ok := p.(*integer_parameters.VariableIntParam)
+	if !ok {
+		return true
+	}
+
+	name := param.GetAbstractParamName()
+	if bound, ok := b.ints[name]; ok {
+		return bound == v
+	}
+	b.ints[name] = v
+	return true
+}
+
+func (b *typeBindings) bind(param *parser.Type, def types.FuncDefArgType, actual types.Type) bool {
+	switch def := def.(type) {
+	case types.AnyType:
+		// the argument type only has "any" as the name of any1, any2...
+		return b.bindAny(strings.TrimSuffix(param.String(), "?"), actual)
+	case *types.ParameterizedDecimalType:
+		dec, ok := actual.(*types.DecimalType)
+		return ok && b.bindInt(def.Precision, dec.Precision) && b.bindInt(def.Scale, dec.Scale)
+	}
+	return true
+}
+
+// matchOverload reports whether the argument types match the parameters
+// of a function declaration, and if so the number of arguments matched by
+// a concrete type.
+func matchOverload(nullability NullabilityHandling, params ArgumentList, variadic *VariadicBehavior, argTypes []types.Type) (int, bool) {
+	if variadic == nil {
+		if len(argTypes) != len(params) {
+			return 0, false
+		}
+	} else if len(params) == 0 || !variadic.IsValidArgumentCount(len(argTypes)-len(params)+1) {
+		return 0, false
+	}
+
+	var (
+		score    int
+		bindings = typeBindings{anys: make(map[string]types.Type), ints: make(map[string]int32)}
+	)
+	for i, actual := range argTypes {
+		param := params[min(i, len(params)-1)]
+		switch p := param.(type) {
+		case EnumArg:
+			if actual != nil {
+				return 0, false
+			}
+			score++
+		case ValueArg:
+			if actual == nil {
+				return 0, false
+			}
+
+			t, ok := p.Value.Expr.(*parser.Type)
+			if !ok {
+				return 0, false
+			}
+			def, err := t.ArgType()
+			if err != nil {
+				return 0, false
+			}
+
+			if nullability == DiscreteNullability {
+				ok = def.MatchWithNullability(actual)
+			} else {
+				ok = def.MatchWithoutNullability(actual)
+			}
+			if !ok || !bindings.bind(t, def, actual) {
+				return 0, false
+			}
+
+			if _, isAny := def.(types.AnyType); !isAny && !def.HasParameterizedParam() {
+				score++
+			}
+		default:
+			return 0, false
+		}
+	}
+
+	if variadic != nil && variadic.ParameterConsistency == ConsistentParams && len(argTypes) > len(params) {
+		first := argTypes[len(params)-1]
+		for _, t := range argTypes[len(params):] {
+			if first == nil || t == nil || !types.EqualsIgnoreNullability(first, t) {
+				return 0, false
+			}
+		}
+	}
+
+	return score, true
+}
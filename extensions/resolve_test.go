@@ -0,0 +1,154 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package extensions_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	substraitgo "github.com/substrait-io/substrait-go"
+	"github.com/substrait-io/substrait-go/extensions"
+	"github.com/substrait-io/substrait-go/types"
+)
+
+const overloadYAML = `---
+scalar_functions:
+  - name: "add"
+    impls:
+      - args:
+          - name: x
+            value: decimal<P1,S1>
+          - name: y
+            value: decimal<P2,S2>
+        return: decimal<38,S1>
+  - name: "same_scale"
+    impls:
+      - args:
+          - name: x
+            value: decimal<P1,S>
+          - name: y
+            value: decimal<P2,S>
+        return: boolean
+  - name: "pick"
+    impls:
+      - args:
+          - name: x
+            value: any1
+          - name: y
+            value: any1
+        return: any1
+      - args:
+          - name: x
+            value: any
+          - name: y
+            value: i32
+        return: i32
+  - name: "either"
+    impls:
+      - args:
+          - name: x
+            value: any
+          - name: y
+            value: i32
+        return: i32
+      - args:
+          - name: x
+            value: i32
+          - name: y
+            value: any
+        return: i32
+`
+
+func TestCollectionResolveScalar(t *testing.T) {
+	const arithURI = extensions.SubstraitDefaultURIPrefix + "functions_arithmetic.yaml"
+
+	tests := []struct {
+		name     string
+		args     []types.Type
+		expected string
+	}{
+		{"add", []types.Type{&types.Int32Type{}, &types.Int32Type{}}, "add:i32_i32"},
+		{"add", []types.Type{&types.Int64Type{}, &types.Int64Type{Nullability: types.NullabilityNullable}}, "add:i64_i64"},
+		{"add", []types.Type{&types.Float64Type{}, &types.Float64Type{}}, "add:fp64_fp64"},
+		{"abs", []types.Type{&types.Int8Type{}}, "abs:i8"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			v, err := extensions.DefaultCollection.ResolveScalar(arithURI, tt.name, tt.args)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, v.CompoundName())
+		})
+	}
+
+	// variadic arguments
+	strURI := extensions.SubstraitDefaultURIPrefix + "functions_string.yaml"
+	str := &types.StringType{}
+	v, err := extensions.DefaultCollection.ResolveScalar(strURI, "concat", []types.Type{str, str, str})
+	require.NoError(t, err)
+	assert.Equal(t, "concat:str", v.CompoundName())
+
+	_, err = extensions.DefaultCollection.ResolveScalar(strURI, "concat", []types.Type{})
+	assert.ErrorIs(t, err, substraitgo.ErrNotFound)
+
+	_, err = extensions.DefaultCollection.ResolveScalar(arithURI, "add", []types.Type{&types.Int32Type{}, &types.Int64Type{}})
+	assert.ErrorIs(t, err, substraitgo.ErrNotFound)
+	assert.ErrorContains(t, err, "matches argument types (i32, i64), candidates: add:fp32_fp32")
+
+	_, err = extensions.DefaultCollection.ResolveScalar(arithURI, "not_a_function", nil)
+	assert.ErrorIs(t, err, substraitgo.ErrNotFound)
+	assert.ErrorContains(t, err, "scalar function not_a_function not found")
+}
+
+func TestCollectionResolveTypeParameters(t *testing.T) {
+	const uri = "http://localhost/overloads.yaml"
+	var c extensions.Collection
+	require.NoError(t, c.Load(uri, strings.NewReader(overloadYAML)))
+
+	dec := func(p, s int32) types.Type { return &types.DecimalType{Precision: p, Scale: s} }
+	v, err := c.ResolveScalar(uri, "add", []types.Type{dec(10, 2), dec(12, 4)})
+	require.NoError(t, err)
+	assert.Equal(t, "add:dec_dec", v.CompoundName())
+
+	// S must bind to the same scale for both arguments
+	_, err = c.ResolveScalar(uri, "same_scale", []types.Type{dec(10, 2), dec(12, 2)})
+	assert.NoError(t, err)
+	_, err = c.ResolveScalar(uri, "same_scale", []types.Type{dec(10, 2), dec(12, 4)})
+	assert.ErrorIs(t, err, substraitgo.ErrNotFound)
+
+	// any1 must be the same type for both, the concrete i32 parameter
+	// is preferred over it when both match
+	v, err = c.ResolveScalar(uri, "pick", []types.Type{&types.StringType{}, &types.StringType{}})
+	require.NoError(t, err)
+	assert.Equal(t, "pick:any_any", v.CompoundName())
+	v, err = c.ResolveScalar(uri, "pick", []types.Type{&types.Int32Type{}, &types.Int32Type{}})
+	require.NoError(t, err)
+	assert.Equal(t, "pick:any_i32", v.CompoundName())
+	_, err = c.ResolveScalar(uri, "pick", []types.Type{&types.StringType{}, &types.DateType{}})
+	assert.ErrorIs(t, err, substraitgo.ErrNotFound)
+
+	v, err = c.ResolveScalar(uri, "either", []types.Type{&types.StringType{}, &types.Int32Type{}})
+	require.NoError(t, err)
+	assert.Equal(t, "either:any_i32", v.CompoundName())
+	_, err = c.ResolveScalar(uri, "either", []types.Type{&types.Int32Type{}, &types.Int32Type{}})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "ambiguous scalar function either for argument types (i32, i32), candidates: either:any_i32, either:i32_any")
+}
+
+func TestCollectionResolveAggregateAndWindow(t *testing.T) {
+	const arithURI = extensions.SubstraitDefaultURIPrefix + "functions_arithmetic.yaml"
+
+	agg, err := extensions.DefaultCollection.ResolveAggregate(arithURI, "sum", []types.Type{&types.Float32Type{}})
+	require.NoError(t, err)
+	assert.Equal(t, "sum:fp32", agg.CompoundName())
+
+	win, err := extensions.DefaultCollection.ResolveWindow(arithURI, "ntile", []types.Type{&types.Int64Type{}})
+	require.NoError(t, err)
+	assert.Equal(t, "ntile:i64", win.CompoundName())
+
+	win, err = extensions.DefaultCollection.ResolveWindow(arithURI, "row_number", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "row_number:", win.CompoundName())
+}
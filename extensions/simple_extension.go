@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	substraitgo "github.com/substrait-io/substrait-go"
+	"github.com/substrait-io/substrait-go/types"
 	"github.com/substrait-io/substrait-go/types/parser"
 )
 
@@ -95,6 +96,18 @@ func (TypeArg) toTypeString() string { return "type" }
 
 func (v TypeArg) argumentMarker() {}
 
+// matches reports whether t matches the declared type of the argument,
+// ignoring nullability. A declared type which isn't a concrete type,
+// such as any1 or decimal<P,S>, matches any type.
+func (v TypeArg) matches(t types.Type) bool {
+	declared, err := parser.ParseType(v.Type)
+	if err != nil {
+		return true
+	}
+	return declared.WithNullability(types.NullabilityRequired).
+		Equals(t.WithNullability(types.NullabilityRequired))
+}
+
 type ArgumentList []Argument
 
 func (a *ArgumentList) UnmarshalYAML(fn func(interface{}) error) error {
@@ -66,7 +66,14 @@ func validateType(arg Argument, actual types.Type, idx int, nullHandling Nullabi
 			}
 		}
 	case TypeArg:
-		return allNonNull, substraitgo.ErrNotImplemented
+		if actual == nil {
+			return allNonNull, fmt.Errorf("%w: arg #%d (%s) should be a type",
+				substraitgo.ErrInvalidType, idx, p.Name)
+		}
+		if !p.matches(actual) {
+			return allNonNull, fmt.Errorf("%w: arg #%d (%s) should be a type matching %s, got %s",
+				substraitgo.ErrInvalidType, idx, p.Name, p.Type, actual)
+		}
 	}
 
 	return allNonNull, nil
@@ -107,15 +114,18 @@ func EvaluateTypeExpression(nullHandling NullabilityHandling, expr parser.TypeEx
 		}
 	}
 
-	var outType types.Type
-	if t, ok := expr.Expr.(*parser.Type); ok {
-		var err error
-		outType, err = t.RetType()
-		if err != nil {
-			return nil, err
+	params := make(map[string]any)
+	for i, p := range paramTypeList {
+		if v, ok := p.(ValueArg); ok {
+			if err := parser.BindParameters(*v.Value, actualTypes[i], params); err != nil {
+				return nil, err
+			}
 		}
-	} else {
-		return nil, substraitgo.ErrNotImplemented
+	}
+
+	outType, err := expr.Evaluate(params)
+	if err != nil {
+		return nil, err
 	}
 
 	if nullHandling == MirrorNullability || nullHandling == "" {
@@ -421,6 +431,18 @@ func (s *AggregateFunctionVariant) Intermediate() (types.FuncDefArgType, error)
 	}
 	return nil, fmt.Errorf("%w: bad intermediate type expression", substraitgo.ErrInvalidType)
 }
+
+// ResolveIntermediateType returns the intermediate type of the function
+// for the provided argument types, which is the output type of the
+// function in the phases of a multi-stage aggregation which produce
+// intermediate results rather than the final result.
+func (s *AggregateFunctionVariant) ResolveIntermediateType(argumentTypes []types.Type) (types.Type, error) {
+	if s.impl.Intermediate.Expr == nil {
+		return nil, fmt.Errorf("%w: aggregate function %s has no intermediate type",
+			substraitgo.ErrInvalidType, s.CompoundName())
+	}
+	return EvaluateTypeExpression(s.impl.Nullability, s.impl.Intermediate, s.impl.Args, s.impl.Variadic, argumentTypes)
+}
 func (s *AggregateFunctionVariant) Ordered() bool { return s.impl.Ordered }
 func (s *AggregateFunctionVariant) MaxSet() int   { return s.impl.MaxSet }
 func (s *AggregateFunctionVariant) Match(argumentTypes []types.Type) (bool, error) {
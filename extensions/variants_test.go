@@ -3,6 +3,7 @@
 package extensions_test
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -153,3 +154,121 @@ func TestHasSyncParams(t *testing.T) {
 		})
 	}
 }
+
+// decimalYAML has the return type derivations of the standard decimal
+// arithmetic functions from functions_arithmetic_decimal.yaml.
+const decimalYAML = `---
+scalar_functions:
+  - name: "add"
+    impls:
+      - args:
+          - name: x
+            value: decimal<P1,S1>
+          - name: y
+            value: decimal<P2,S2>
+        return: |-
+          init_scale = max(S1,S2)
+          init_prec = init_scale + max(P1 - S1, P2 - S2) + 1
+          min_scale = min(init_scale, 6)
+          delta = init_prec - 38
+          prec = min(init_prec, 38)
+          scale_after_borrow = max(init_scale - delta, min_scale)
+          scale = init_prec > 38 ? scale_after_borrow : init_scale
+          DECIMAL<prec, scale>
+  - name: "multiply"
+    impls:
+      - args:
+          - name: x
+            value: decimal<P1,S1>
+          - name: y
+            value: decimal<P2,S2>
+        return: |-
+          init_scale = S1 + S2
+          init_prec = P1 + P2 + 1
+          min_scale = min(init_scale, 6)
+          delta = init_prec - 38
+          prec = min(init_prec, 38)
+          scale_after_borrow = max(init_scale - delta, min_scale)
+          scale = init_prec > 38 ? scale_after_borrow : init_scale
+          DECIMAL<prec, scale>
+  - name: "divide"
+    impls:
+      - args:
+          - name: x
+            value: decimal<P1,S1>
+          - name: y
+            value: decimal<P2,S2>
+        return: |-
+          init_scale = max(6, S1 + P2 + 1)
+          init_prec = P1 - S1 + P2 + init_scale
+          min_scale = min(init_scale, 6)
+          delta = init_prec - 38
+          prec = min(init_prec, 38)
+          scale_after_borrow = max(init_scale - delta, min_scale)
+          scale = init_prec > 38 ? scale_after_borrow : init_scale
+          DECIMAL<prec, scale>
+aggregate_functions:
+  - name: "sum"
+    impls:
+      - args:
+          - name: x
+            value: decimal<P,S>
+        nullability: DECLARED_OUTPUT
+        decomposable: MANY
+        intermediate: DECIMAL?<38,S>
+        return: DECIMAL?<38,S>
+`
+
+func TestResolveTypeDecimal(t *testing.T) {
+	const uri = "http://localhost/decimal.yaml"
+	var c extensions.Collection
+	require.NoError(t, c.Load(uri, strings.NewReader(decimalYAML)))
+
+	dec := func(p, s int32) *types.DecimalType {
+		return &types.DecimalType{Precision: p, Scale: s, Nullability: types.NullabilityRequired}
+	}
+
+	tests := []struct {
+		fn       string
+		args     []types.Type
+		expected types.Type
+	}{
+		{"add", []types.Type{dec(10, 2), dec(12, 4)}, dec(13, 4)},
+		{"add", []types.Type{dec(38, 10), dec(38, 10)}, dec(38, 9)},
+		{"multiply", []types.Type{dec(10, 2), dec(12, 4)}, dec(23, 6)},
+		{"multiply", []types.Type{dec(38, 10), dec(38, 10)}, dec(38, 6)},
+		{"divide", []types.Type{dec(10, 2), dec(5, 1)}, dec(21, 8)},
+		// mirrored nullability
+		{"add", []types.Type{dec(5, 0), &types.DecimalType{Precision: 5, Nullability: types.NullabilityNullable}},
+			&types.DecimalType{Precision: 6, Nullability: types.NullabilityNullable}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fn, func(t *testing.T) {
+			v, err := c.ResolveScalar(uri, tt.fn, tt.args)
+			require.NoError(t, err)
+
+			out, err := v.ResolveType(tt.args)
+			require.NoError(t, err)
+			assert.Truef(t, tt.expected.Equals(out), "expected: %s\ngot: %s", tt.expected, out)
+		})
+	}
+
+	sum, err := c.ResolveAggregate(uri, "sum", []types.Type{dec(10, 3)})
+	require.NoError(t, err)
+	out, err := sum.ResolveType([]types.Type{dec(10, 3)})
+	require.NoError(t, err)
+	assert.Equal(t, "decimal?<38,3>", out.String())
+
+	intermediate, err := sum.ResolveIntermediateType([]types.Type{dec(10, 3)})
+	require.NoError(t, err)
+	assert.Equal(t, "decimal?<38,3>", intermediate.String())
+
+	// any1 binds to the type of the argument
+	std, ok := extensions.DefaultCollection.GetAggregateFunc(extensions.ID{
+		URI: extensions.SubstraitDefaultURIPrefix + "functions_aggregate_generic.yaml", Name: "any_value"})
+	require.True(t, ok)
+	out, err = std.ResolveType([]types.Type{&types.StringType{Nullability: types.NullabilityRequired}})
+	require.NoError(t, err)
+	assert.Equal(t, "string?", out.String())
+}
@@ -2,10 +2,14 @@ package literal
 
 import (
 	"fmt"
+	"math/big"
 	"regexp"
 	"strings"
 
 	"github.com/cockroachdb/apd/v3"
+	substraitgo "github.com/substrait-io/substrait-go"
+	"github.com/substrait-io/substrait-go/expr"
+	"github.com/substrait-io/substrait-go/types"
 )
 
 var decimalPattern = regexp.MustCompile(`^[+-]?\d{0,38}(\.\d{0,38})?([eE][+-]?\d{0,38})?$`)
@@ -15,6 +19,7 @@ var decimalPattern = regexp.MustCompile(`^[+-]?\d{0,38}(\.\d{0,38})?([eE][+-]?\d
 // This function also returns the precision and scale of the decimal value.
 // The precision is the total number of digits in the decimal value. The precision is limited to 38 digits.
 // The scale is the number of digits to the right of the decimal point. The scale is limited to the precision.
+// The string may have surrounding whitespace, a leading sign and an exponent, e.g. " +1.5E3 ".
 func decimalStringToBytes(decimalStr string) ([16]byte, int32, int32, error) {
 	var (
 		result    [16]byte
@@ -22,15 +27,15 @@ func decimalStringToBytes(decimalStr string) ([16]byte, int32, int32, error) {
 		scale     int32
 	)
 
-	strings.Trim(decimalStr, " ")
+	decimalStr = strings.TrimSpace(decimalStr)
 	if !decimalPattern.MatchString(decimalStr) {
-		return result, 0, 0, fmt.Errorf("invalid decimal string")
+		return result, 0, 0, fmt.Errorf("%w: invalid decimal string %q", substraitgo.ErrInvalidArg, decimalStr)
 	}
 
 	// Parse the decimal string using apd
 	dec, cond, err := apd.NewFromString(decimalStr)
 	if err != nil || cond.Any() {
-		return result, 0, 0, fmt.Errorf("invalid decimal string: %v", err)
+		return result, 0, 0, fmt.Errorf("%w: invalid decimal string: %v", substraitgo.ErrInvalidArg, err)
 	}
 
 	if dec.Exponent > 0 {
@@ -41,7 +46,7 @@ func decimalStringToBytes(decimalStr string) ([16]byte, int32, int32, error) {
 		precision = max(int32(apd.NumDigits(&dec.Coeff)), scale+1)
 	}
 	if precision > 38 {
-		return result, precision, scale, fmt.Errorf("number exceeds maximum precision of 38")
+		return result, precision, scale, fmt.Errorf("%w: number exceeds maximum precision of 38", substraitgo.ErrInvalidArg)
 	}
 
 	coefficient := dec.Coeff
@@ -53,7 +58,7 @@ func decimalStringToBytes(decimalStr string) ([16]byte, int32, int32, error) {
 	// Convert the coefficient to a byte array
 	byteArray := coefficient.Bytes()
 	if len(byteArray) > 16 {
-		return result, 0, 0, fmt.Errorf("number exceeds 16 bytes")
+		return result, 0, 0, fmt.Errorf("%w: number exceeds 16 bytes", substraitgo.ErrInvalidArg)
 	}
 	copy(result[16-len(byteArray):], byteArray)
 
@@ -82,3 +87,50 @@ func twosComplement(bytes []byte) {
 		}
 	}
 }
+
+// DecimalToBigInt returns the unscaled value of a decimal literal,
+// decoded from its 16-byte little-endian two's complement representation,
+// along with the scale of the literal. The value of the decimal is the
+// unscaled value divided by 10^scale.
+func DecimalToBigInt(lit expr.Literal) (*big.Int, int32, error) {
+	p, ok := lit.(*expr.ProtoLiteral)
+	if !ok {
+		return nil, 0, fmt.Errorf("%w: expected a decimal literal, got %s", substraitgo.ErrInvalidArg, lit)
+	}
+	typ, ok := p.Type.(*types.DecimalType)
+	if !ok {
+		return nil, 0, fmt.Errorf("%w: expected a decimal literal, got %s", substraitgo.ErrInvalidArg, lit)
+	}
+	value, ok := p.Value.([]byte)
+	if !ok || len(value) != 16 {
+		return nil, 0, fmt.Errorf("%w: decimal literal value must be 16 bytes", substraitgo.ErrInvalidArg)
+	}
+
+	// reverse to big-endian, taking a copy to leave the literal intact
+	var be [16]byte
+	for i, b := range value {
+		be[15-i] = b
+	}
+
+	isNegative := be[0]&0x80 != 0
+	if isNegative {
+		twosComplement(be[:])
+	}
+
+	out := new(big.Int).SetBytes(be[:])
+	if isNegative {
+		out.Neg(out)
+	}
+	return out, typ.Scale, nil
+}
+
+// DecimalToString returns the value of a decimal literal as a string in
+// plain notation, with the number of digits after the decimal point given
+// by the scale of the literal, such as "-123.45".
+func DecimalToString(lit expr.Literal) (string, error) {
+	v, scale, err := DecimalToBigInt(lit)
+	if err != nil {
+		return "", err
+	}
+	return apd.NewWithBigInt(new(apd.BigInt).SetMathBigInt(v), -scale).Text('f'), nil
+}
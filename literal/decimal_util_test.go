@@ -8,6 +8,9 @@ import (
 
 	"github.com/cockroachdb/apd/v3"
 	"github.com/stretchr/testify/assert"
+	substraitgo "github.com/substrait-io/substrait-go"
+	"github.com/substrait-io/substrait-go/expr"
+	"github.com/substrait-io/substrait-go/types"
 )
 
 func Test_decimalStringToBytes(t *testing.T) {
@@ -50,6 +53,13 @@ func Test_decimalStringToBytes(t *testing.T) {
 		{"1.23e20", "00000c6d51c8f7aa0600000000000000", 21, 0, "123000000000000000000"},
 		{"1.23e35", "00000000cebde644bc05f0425eb01700", 36, 0, "123000000000000000000000000000000000"},
 		{"1.23E35", "00000000cebde644bc05f0425eb01700", 36, 0, "123000000000000000000000000000000000"},
+		{"1.5E3", "dc050000000000000000000000000000", 4, 0, "1500"},
+		{"+1.5e+3", "dc050000000000000000000000000000", 4, 0, "1500"},
+		{"-1.5E3", "24faffffffffffffffffffffffffffff", 4, 0, "-1500"},
+		{"1.5E-3", "0f000000000000000000000000000000", 5, 4, "0.0015"},
+		{"-15E-1", "f1ffffffffffffffffffffffffffffff", 2, 1, "-1.5"},
+		{" 123.45 ", "39300000000000000000000000000000", 5, 2, "123.45"},
+		{"\t+12345\n", "39300000000000000000000000000000", 5, 0, "12345"},
 	}
 
 	for _, tt := range tests {
@@ -65,11 +75,15 @@ func Test_decimalStringToBytes(t *testing.T) {
 		{"199999999999999999999999999999999999999"},
 		{"1.23e45"},
 		{"1.23E300"},
+		{"1E38"},
+		{"-9.9E38"},
+		{"1.5E"},
+		{"1 5"},
 	}
 	for _, tt := range badInputs {
 		t.Run(tt.input, func(t *testing.T) {
 			_, _, _, err := decimalStringToBytes(tt.input)
-			assert.Error(t, err, "decimalStringToBytes(%v) expected error", tt.input)
+			assert.ErrorIs(t, err, substraitgo.ErrInvalidArg, "decimalStringToBytes(%v) expected error", tt.input)
 		})
 	}
 }
@@ -120,3 +134,53 @@ func decimalBytesToString(decimalBytes [16]byte, scale int32) string {
 	apdBigInt := apd.NewBigInt(0).SetMathBigInt(intValue)
 	return apd.NewWithBigInt(apdBigInt, -scale).String()
 }
+
+func TestDecimalToString(t *testing.T) {
+	tests := []struct {
+		input    string
+		unscaled int64
+		scale    int32
+		expected string
+	}{
+		{"-123.45", -12345, 2, "-123.45"},
+		{"0", 0, 0, "0"},
+		{"0.00", 0, 2, "0.00"},
+		{"123.45", 12345, 2, "123.45"},
+		{"-0.0012345678901234", -12345678901234, 16, "-0.0012345678901234"},
+		{"1.5E3", 1500, 0, "1500"},
+		{"0.0000000001", 1, 10, "0.0000000001"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			lit, err := NewDecimalFromString(tt.input)
+			assert.NoError(t, err)
+
+			v, scale, err := DecimalToBigInt(lit)
+			assert.NoError(t, err)
+			assert.Zero(t, big.NewInt(tt.unscaled).Cmp(v), v)
+			assert.Equal(t, tt.scale, scale)
+
+			s, err := DecimalToString(lit)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, s)
+		})
+	}
+
+	largest, err := NewDecimalFromString("-99999999999999999999999999999999999999")
+	assert.NoError(t, err)
+	s, err := DecimalToString(largest)
+	assert.NoError(t, err)
+	assert.Equal(t, "-99999999999999999999999999999999999999", s)
+
+	i32, err := NewInt32(1)
+	assert.NoError(t, err)
+	_, err = DecimalToString(i32)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "expected a decimal literal, got i32(1)")
+
+	short := &expr.ProtoLiteral{Value: []byte{1, 2}, Type: &types.DecimalType{Precision: 5, Scale: 2}}
+	_, err = DecimalToString(short)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "decimal literal value must be 16 bytes")
+}
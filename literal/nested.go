@@ -0,0 +1,141 @@
+package literal
+
+import (
+	"fmt"
+
+	substraitgo "github.com/substrait-io/substrait-go"
+	"github.com/substrait-io/substrait-go/expr"
+	"github.com/substrait-io/substrait-go/types"
+)
+
+// NestedForm selects how struct, list and map values are represented
+// when serialized to protobuf.
+type NestedForm int8
+
+const (
+	// NestedLiteralForm represents nested values as struct, list and
+	// map literals, which is the form used by existing consumers.
+	NestedLiteralForm NestedForm = iota
+	// NestedExprForm represents nested values as nested expressions
+	// (Expression.Nested) whose leaves are literals.
+	NestedExprForm
+)
+
+// ToExpression returns the literal as an expression in the given form.
+// With NestedExprForm the struct, list and map literals within lit are
+// converted to the corresponding StructExpr, ListExpr and MapExpr, while
+// empty lists and maps and typed nulls are left as literals since a
+// nested expression can't carry their type. With NestedLiteralForm the
+// literal is returned unchanged.
+func ToExpression(lit expr.Literal, form NestedForm) (expr.Expression, error) {
+	switch form {
+	case NestedLiteralForm:
+		return lit, nil
+	case NestedExprForm:
+		return toNestedExpr(lit), nil
+	}
+	return nil, fmt.Errorf("%w: unknown nested form %d", substraitgo.ErrInvalidArg, form)
+}
+
+func toNestedExpr(lit expr.Literal) expr.Expression {
+	nullable := lit.GetType().GetNullability() == types.NullabilityNullable
+	typeVar := lit.GetType().GetTypeVariationReference()
+
+	switch l := lit.(type) {
+	case *expr.StructLiteral:
+		fields := make([]expr.Expression, len(l.Value))
+		for i, f := range l.Value {
+			fields[i] = toNestedExpr(f)
+		}
+		return &expr.StructExpr{Nullable: nullable, TypeVariationRef: typeVar, Fields: fields}
+	case *expr.ListLiteral:
+		if len(l.Value) == 0 {
+			return lit
+		}
+		values := make([]expr.Expression, len(l.Value))
+		for i, v := range l.Value {
+			values[i] = toNestedExpr(v)
+		}
+		return &expr.ListExpr{Nullable: nullable, TypeVariationRef: typeVar, Values: values}
+	case *expr.MapLiteral:
+		if len(l.Value) == 0 {
+			return lit
+		}
+		out := &expr.MapExpr{Nullable: nullable, TypeVariationRef: typeVar,
+			KeyValues: make([]struct{ Key, Value expr.Expression }, len(l.Value))}
+		for i, kv := range l.Value {
+			out.KeyValues[i].Key = toNestedExpr(kv.Key)
+			out.KeyValues[i].Value = toNestedExpr(kv.Value)
+		}
+		return out
+	}
+	return lit
+}
+
+// FromExpression returns the literal represented by e, which is either a
+// literal or a nested expression whose leaves are all literals, such as
+// the expressions returned by ToExpression or read from a plan using
+// either form. It returns an error if e contains any other expression.
+func FromExpression(e expr.Expression) (expr.Literal, error) {
+	var (
+		out expr.Literal
+		err error
+	)
+
+	switch e := e.(type) {
+	case expr.Literal:
+		return e, nil
+	case *expr.StructExpr:
+		fields := make([]expr.Literal, len(e.Fields))
+		for i, f := range e.Fields {
+			if fields[i], err = FromExpression(f); err != nil {
+				return nil, err
+			}
+		}
+		out, err = NewStruct(fields)
+	case *expr.ListExpr:
+		values := make([]expr.Literal, len(e.Values))
+		for i, v := range e.Values {
+			if values[i], err = FromExpression(v); err != nil {
+				return nil, err
+			}
+		}
+		out, err = NewList(values)
+	case *expr.MapExpr:
+		entries := make([]MapEntry, len(e.KeyValues))
+		for i, kv := range e.KeyValues {
+			if entries[i].Key, err = FromExpression(kv.Key); err != nil {
+				return nil, err
+			}
+			if entries[i].Value, err = FromExpression(kv.Value); err != nil {
+				return nil, err
+			}
+		}
+		out, err = NewMap(entries)
+	default:
+		return nil, fmt.Errorf("%w: expression %s is not a literal", substraitgo.ErrInvalidArg, e)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	nested := e.(expr.NestedExpr)
+	if !nested.IsNullable() && nested.TypeVariation() == 0 {
+		return out, nil
+	}
+
+	typ := out.GetType().WithNullability(getNullability(nested.IsNullable()))
+	switch t := typ.(type) {
+	case *types.StructType:
+		t.TypeVariationRef = nested.TypeVariation()
+		out.(*expr.StructLiteral).Type = t
+	case *types.ListType:
+		t.TypeVariationRef = nested.TypeVariation()
+		out.(*expr.ListLiteral).Type = t
+	case *types.MapType:
+		t.TypeVariationRef = nested.TypeVariation()
+		out.(*expr.MapLiteral).Type = t
+	}
+	return out, nil
+}
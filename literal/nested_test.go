@@ -0,0 +1,99 @@
+package literal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	substraitgo "github.com/substrait-io/substrait-go"
+	"github.com/substrait-io/substrait-go/expr"
+	"github.com/substrait-io/substrait-go/extensions"
+	"github.com/substrait-io/substrait-go/proto"
+	"github.com/substrait-io/substrait-go/types"
+)
+
+func nestedListLiteral(t *testing.T) expr.Literal {
+	inner := func(vals ...int32) expr.Literal {
+		elems := make([]expr.Literal, len(vals))
+		for i, v := range vals {
+			elems[i], _ = NewInt32(v)
+		}
+		l, err := NewList(elems)
+		require.NoError(t, err)
+		return l
+	}
+
+	empty, err := NewEmptyList(&types.Int32Type{Nullability: types.NullabilityRequired})
+	require.NoError(t, err)
+	lit, err := NewList([]expr.Literal{inner(1, 2), inner(3), empty})
+	require.NoError(t, err)
+	return lit
+}
+
+func TestNestedListRoundTrip(t *testing.T) {
+	reg := expr.NewEmptyExtensionRegistry(&extensions.DefaultCollection)
+	lit := nestedListLiteral(t)
+
+	t.Run("literal form", func(t *testing.T) {
+		e, err := ToExpression(lit, NestedLiteralForm)
+		require.NoError(t, err)
+		out := e.ToProto()
+		require.IsType(t, &proto.Expression_Literal_{}, out.RexType)
+		assert.Len(t, out.GetLiteral().GetList().Values, 3)
+
+		rt, err := expr.ExprFromProto(out, nil, reg)
+		require.NoError(t, err)
+		got, err := FromExpression(rt)
+		require.NoError(t, err)
+		assert.True(t, lit.Equals(got))
+	})
+
+	t.Run("expression form", func(t *testing.T) {
+		e, err := ToExpression(lit, NestedExprForm)
+		require.NoError(t, err)
+		out := e.ToProto()
+		values := out.GetNested().GetList().GetValues()
+		require.Len(t, values, 3)
+		assert.Len(t, values[0].GetNested().GetList().GetValues(), 2)
+		// the empty list keeps its type as an empty list literal
+		assert.NotNil(t, values[2].GetLiteral().GetEmptyList())
+
+		rt, err := expr.ExprFromProto(out, nil, reg)
+		require.NoError(t, err)
+		assert.IsType(t, &expr.ListExpr{}, rt)
+		got, err := FromExpression(rt)
+		require.NoError(t, err)
+		assert.Truef(t, lit.Equals(got), "expected: %s\ngot: %s", lit, got)
+	})
+}
+
+func TestFromExpressionNullable(t *testing.T) {
+	one, err := NewInt32(1)
+	require.NoError(t, err)
+	foo, err := NewString("foo")
+	require.NoError(t, err)
+
+	lit, err := NewStruct([]expr.Literal{one, foo})
+	require.NoError(t, err)
+	lit.(*expr.StructLiteral).Type = lit.GetType().WithNullability(types.NullabilityNullable)
+
+	e, err := ToExpression(lit, NestedExprForm)
+	require.NoError(t, err)
+	require.IsType(t, &expr.StructExpr{}, e)
+	assert.True(t, e.(*expr.StructExpr).Nullable)
+
+	got, err := FromExpression(e)
+	require.NoError(t, err)
+	assert.Equal(t, "struct?<i32, string>", got.GetType().String())
+	assert.True(t, lit.Equals(got))
+
+	ref, err := expr.NewRootFieldRef(expr.NewStructFieldRef(0),
+		&types.StructType{Types: []types.Type{&types.Int32Type{}}})
+	require.NoError(t, err)
+	_, err = FromExpression(&expr.ListExpr{Values: []expr.Expression{one, ref}})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "is not a literal")
+
+	_, err = ToExpression(lit, NestedForm(5))
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+}
@@ -2,94 +2,193 @@ package literal
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	substraitgo "github.com/substrait-io/substrait-go"
 	"github.com/substrait-io/substrait-go/expr"
 	"github.com/substrait-io/substrait-go/proto"
 	"github.com/substrait-io/substrait-go/types"
+	"google.golang.org/protobuf/types/known/anypb"
 )
 
+// Each of the constructors below creates a literal whose type is not
+// nullable. The corresponding ...Nullable variants take an additional
+// flag to mark the type of the literal as nullable, such as when the
+// literal is compared against or inserted into a nullable column.
+
 func NewBool(value bool) (expr.Literal, error) {
-	return expr.NewPrimitiveLiteral[bool](value, false), nil
+	return NewBoolNullable(value, false)
+}
+
+func NewBoolNullable(value bool, nullable bool) (expr.Literal, error) {
+	return expr.NewPrimitiveLiteral[bool](value, nullable), nil
 }
 
 func NewInt8(value int8) (expr.Literal, error) {
-	return expr.NewPrimitiveLiteral[int8](value, false), nil
+	return NewInt8Nullable(value, false)
+}
+
+func NewInt8Nullable(value int8, nullable bool) (expr.Literal, error) {
+	return expr.NewPrimitiveLiteral[int8](value, nullable), nil
 }
 
 func NewInt16(value int16) (expr.Literal, error) {
-	return expr.NewPrimitiveLiteral[int16](value, false), nil
+	return NewInt16Nullable(value, false)
+}
+
+func NewInt16Nullable(value int16, nullable bool) (expr.Literal, error) {
+	return expr.NewPrimitiveLiteral[int16](value, nullable), nil
 }
 
 func NewInt32(value int32) (expr.Literal, error) {
-	return expr.NewPrimitiveLiteral[int32](value, false), nil
+	return NewInt32Nullable(value, false)
+}
+
+func NewInt32Nullable(value int32, nullable bool) (expr.Literal, error) {
+	return expr.NewPrimitiveLiteral[int32](value, nullable), nil
 }
 
 func NewInt64(value int64) (expr.Literal, error) {
-	return expr.NewPrimitiveLiteral[int64](value, false), nil
+	return NewInt64Nullable(value, false)
+}
+
+func NewInt64Nullable(value int64, nullable bool) (expr.Literal, error) {
+	return expr.NewPrimitiveLiteral[int64](value, nullable), nil
 }
 
 func NewFloat32(value float32) (expr.Literal, error) {
-	return expr.NewPrimitiveLiteral[float32](value, false), nil
+	return NewFloat32Nullable(value, false)
+}
+
+func NewFloat32Nullable(value float32, nullable bool) (expr.Literal, error) {
+	return expr.NewPrimitiveLiteral[float32](value, nullable), nil
 }
 
 func NewFloat64(value float64) (expr.Literal, error) {
-	return expr.NewPrimitiveLiteral[float64](value, false), nil
+	return NewFloat64Nullable(value, false)
+}
+
+func NewFloat64Nullable(value float64, nullable bool) (expr.Literal, error) {
+	return expr.NewPrimitiveLiteral[float64](value, nullable), nil
 }
 
 func NewString(value string) (expr.Literal, error) {
-	return expr.NewPrimitiveLiteral[string](value, false), nil
+	return NewStringNullable(value, false)
+}
+
+func NewStringNullable(value string, nullable bool) (expr.Literal, error) {
+	return expr.NewPrimitiveLiteral[string](value, nullable), nil
+}
+
+// NewBinary creates a variable length Binary literal from the given
+// bytes. An empty or nil slice results in an empty, non-null literal.
+func NewBinary(value []byte) (expr.Literal, error) {
+	return NewBinaryNullable(value, false)
+}
+
+func NewBinaryNullable(value []byte, nullable bool) (expr.Literal, error) {
+	if value == nil {
+		value = []byte{}
+	}
+	return expr.NewByteSliceLiteral[[]byte](value, nullable), nil
 }
 
 func NewDate(days int) (expr.Literal, error) {
-	return expr.NewLiteral[types.Date](types.Date(days), false)
+	return NewDateNullable(days, false)
+}
+
+func NewDateNullable(days int, nullable bool) (expr.Literal, error) {
+	return expr.NewLiteral[types.Date](types.Date(days), nullable)
 }
 
 // NewTime creates a new Time literal from the given hours, minutes, seconds and microseconds.
 // The total microseconds should be in the range [0, 86400_000_000) to represent a valid time within a day.
+// An error wrapping substraitgo.ErrInvalidArg is returned if any component is negative or the total is out of range.
 func NewTime(hours, minutes, seconds, microseconds int32) (expr.Literal, error) {
-	duration := time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute + time.Duration(seconds)*time.Second + time.Duration(microseconds)*time.Microsecond
-	micros := duration.Microseconds()
-	if micros < 0 || micros >= (24*time.Hour).Microseconds() {
-		return nil, fmt.Errorf("invalid time value %d:%d:%d.%d", hours, minutes, seconds, microseconds)
+	return NewTimeNullable(hours, minutes, seconds, microseconds, false)
+}
+
+func NewTimeNullable(hours, minutes, seconds, microseconds int32, nullable bool) (expr.Literal, error) {
+	if hours < 0 || minutes < 0 || seconds < 0 || microseconds < 0 {
+		return nil, fmt.Errorf("%w: invalid time value %d:%d:%d.%d, components must not be negative",
+			substraitgo.ErrInvalidArg, hours, minutes, seconds, microseconds)
 	}
-	return expr.NewLiteral[types.Time](types.Time(duration.Microseconds()), false)
+
+	// the microseconds are summed directly rather than as a time.Duration,
+	// whose nanoseconds overflow for large numbers of hours. The int32
+	// components can't overflow an int64 number of microseconds.
+	micros := int64(hours)*time.Hour.Microseconds() + int64(minutes)*time.Minute.Microseconds() +
+		int64(seconds)*time.Second.Microseconds() + int64(microseconds)
+	if micros >= (24 * time.Hour).Microseconds() {
+		return nil, fmt.Errorf("%w: invalid time value %d:%d:%d.%d", substraitgo.ErrInvalidArg,
+			hours, minutes, seconds, microseconds)
+	}
+	return expr.NewLiteral[types.Time](types.Time(micros), nullable)
 }
 
 // NewTimeFromMicros creates a new Time literal from the given microseconds.
 func NewTimeFromMicros(micros int64) (expr.Literal, error) {
+	return NewTimeFromMicrosNullable(micros, false)
+}
+
+func NewTimeFromMicrosNullable(micros int64, nullable bool) (expr.Literal, error) {
 	if micros < 0 || micros >= (24*time.Hour).Microseconds() {
 		return nil, fmt.Errorf("invalid time value %d", micros)
 	}
-	return expr.NewLiteral[types.Time](types.Time(micros), false)
+	return expr.NewLiteral[types.Time](types.Time(micros), nullable)
 }
 
 // NewTimestamp creates a new Timestamp literal from a time.Time timestamp value.
 // This uses the number of microseconds elapsed since January 1, 1970 00:00:00 UTC
 func NewTimestamp(timestamp time.Time) (expr.Literal, error) {
-	return expr.NewLiteral[types.Timestamp](types.Timestamp(timestamp.UnixMicro()), false)
+	return NewTimestampNullable(timestamp, false)
+}
+
+func NewTimestampNullable(timestamp time.Time, nullable bool) (expr.Literal, error) {
+	return expr.NewLiteral[types.Timestamp](types.Timestamp(timestamp.UnixMicro()), nullable)
 }
 
 func NewTimestampFromMicros(micros int64) (expr.Literal, error) {
-	return expr.NewLiteral[types.Timestamp](types.Timestamp(micros), false)
+	return NewTimestampFromMicrosNullable(micros, false)
+}
+
+func NewTimestampFromMicrosNullable(micros int64, nullable bool) (expr.Literal, error) {
+	return expr.NewLiteral[types.Timestamp](types.Timestamp(micros), nullable)
 }
 
 // NewTimestampTZ creates a new TimestampTz literal from a time.Time timestamp value.
 // This uses the number of microseconds elapsed since January 1, 1970 00:00:00 UTC
 func NewTimestampTZ(timestamp time.Time) (expr.Literal, error) {
-	return expr.NewLiteral[types.TimestampTz](types.TimestampTz(timestamp.UnixMicro()), false)
+	return NewTimestampTZNullable(timestamp, false)
+}
+
+func NewTimestampTZNullable(timestamp time.Time, nullable bool) (expr.Literal, error) {
+	return expr.NewLiteral[types.TimestampTz](types.TimestampTz(timestamp.UnixMicro()), nullable)
 }
 
 func NewTimestampTZFromMicros(micros int64) (expr.Literal, error) {
-	return expr.NewLiteral[types.TimestampTz](types.TimestampTz(micros), false)
+	return NewTimestampTZFromMicrosNullable(micros, false)
+}
+
+func NewTimestampTZFromMicrosNullable(micros int64, nullable bool) (expr.Literal, error) {
+	return expr.NewLiteral[types.TimestampTz](types.TimestampTz(micros), nullable)
 }
 
 func NewIntervalYearsToMonth(years, months int32) (expr.Literal, error) {
-	return expr.NewLiteral[*types.IntervalYearToMonth](&types.IntervalYearToMonth{Years: years, Months: months}, false)
+	return NewIntervalYearsToMonthNullable(years, months, false)
+}
+
+func NewIntervalYearsToMonthNullable(years, months int32, nullable bool) (expr.Literal, error) {
+	return expr.NewLiteral[*types.IntervalYearToMonth](&types.IntervalYearToMonth{Years: years, Months: months}, nullable)
 }
 
 func NewIntervalDaysToSecond(days, seconds int32, micros int64) (expr.Literal, error) {
+	return NewIntervalDaysToSecondNullable(days, seconds, micros, false)
+}
+
+func NewIntervalDaysToSecondNullable(days, seconds int32, micros int64, nullable bool) (expr.Literal, error) {
 	return expr.NewLiteral[*types.IntervalDayToSecond](&types.IntervalDayToSecond{
 		Days:    days,
 		Seconds: seconds,
@@ -97,36 +196,157 @@ func NewIntervalDaysToSecond(days, seconds int32, micros int64) (expr.Literal, e
 			Precision: int32(types.PrecisionMicroSeconds),
 		},
 		Subseconds: micros,
-	}, false)
+	}, nullable)
+}
+
+// NewIntervalFromDuration creates an IntervalDayToSecond literal with
+// microsecond precision from the duration, split into whole days,
+// seconds and microseconds. Each component has the same sign as the
+// duration. Any sub-microsecond part of the duration is truncated.
+func NewIntervalFromDuration(d time.Duration) (expr.Literal, error) {
+	return NewIntervalFromDurationNullable(d, false)
+}
+
+func NewIntervalFromDurationNullable(d time.Duration, nullable bool) (expr.Literal, error) {
+	const microsPerDay = int64(24 * time.Hour / time.Microsecond)
+	const microsPerSecond = int64(time.Second / time.Microsecond)
+
+	micros := int64(d / time.Microsecond)
+	days := micros / microsPerDay
+	micros -= days * microsPerDay
+	seconds := micros / microsPerSecond
+	micros -= seconds * microsPerSecond
+
+	return NewIntervalDaysToSecondNullable(int32(days), int32(seconds), micros, nullable)
+}
+
+// NewIntervalCompound creates an IntervalCompound literal from the given
+// components. subseconds is expressed in units of the provided precision,
+// so its magnitude must be less than one second at that precision.
+func NewIntervalCompound(years, months, days, seconds int32, subseconds int64, precision types.TimePrecision) (expr.Literal, error) {
+	return NewIntervalCompoundNullable(years, months, days, seconds, subseconds, precision, false)
+}
+
+func NewIntervalCompoundNullable(years, months, days, seconds int32, subseconds int64, precision types.TimePrecision, nullable bool) (expr.Literal, error) {
+	if err := checkTimePrecision(precision); err != nil {
+		return nil, err
+	}
+
+	limit := int64(1)
+	for i := types.PrecisionSeconds; i < precision; i++ {
+		limit *= 10
+	}
+	if subseconds <= -limit || subseconds >= limit {
+		return nil, fmt.Errorf("%w: subseconds %d out of range for precision %d",
+			substraitgo.ErrInvalidArg, subseconds, precision.ToProtoVal())
+	}
+
+	return expr.IntervalCompoundLiteral{
+		Years:              years,
+		Months:             months,
+		Days:               days,
+		Seconds:            seconds,
+		SubSeconds:         subseconds,
+		SubSecondPrecision: precision,
+		Nullability:        getNullability(nullable),
+	}, nil
 }
 
 func NewUUID(guid uuid.UUID) (expr.Literal, error) {
+	return NewUUIDNullable(guid, false)
+}
+
+func NewUUIDNullable(guid uuid.UUID, nullable bool) (expr.Literal, error) {
 	bytes, err := guid.MarshalBinary()
 	if err != nil {
 		return nil, err
 	}
-	return expr.NewLiteral[types.UUID](bytes, false)
+	return expr.NewLiteral[types.UUID](bytes, nullable)
 }
 
 func NewUUIDFromBytes(value []byte) (expr.Literal, error) {
-	return expr.NewLiteral[types.UUID](value, false)
+	return NewUUIDFromBytesNullable(value, false)
+}
+
+func NewUUIDFromBytesNullable(value []byte, nullable bool) (expr.Literal, error) {
+	return expr.NewLiteral[types.UUID](value, nullable)
+}
+
+// UUIDToGoUUID returns the value of a UUID literal as a uuid.UUID. It
+// returns an error if the literal is not a UUID or its value is not
+// 16 bytes long.
+func UUIDToGoUUID(lit expr.Literal) (uuid.UUID, error) {
+	u, ok := lit.(*expr.ByteSliceLiteral[types.UUID])
+	if !ok {
+		return uuid.Nil, fmt.Errorf("%w: expected a uuid literal, got %s", substraitgo.ErrInvalidArg, lit)
+	}
+	if len(u.Value) != 16 {
+		return uuid.Nil, fmt.Errorf("%w: uuid literal value must be 16 bytes, got %d",
+			substraitgo.ErrInvalidArg, len(u.Value))
+	}
+	return uuid.UUID(u.Value), nil
 }
 
 func NewFixedChar(value string) (expr.Literal, error) {
-	return expr.NewLiteral[types.FixedChar](types.FixedChar(value), false)
+	return NewFixedCharNullable(value, false)
+}
+
+func NewFixedCharNullable(value string, nullable bool) (expr.Literal, error) {
+	return expr.NewLiteral[types.FixedChar](types.FixedChar(value), nullable)
+}
+
+// NewFixedCharN creates a FixedChar literal of the declared length,
+// right-padding the value with spaces up to the length. It returns an
+// error if the value is longer than the length.
+func NewFixedCharN(value string, length uint32) (expr.Literal, error) {
+	return NewFixedCharNNullable(value, length, false)
+}
+
+func NewFixedCharNNullable(value string, length uint32, nullable bool) (expr.Literal, error) {
+	if uint64(len(value)) > uint64(length) {
+		return nil, fmt.Errorf("%w: value of length %d exceeds fixedchar length %d",
+			substraitgo.ErrInvalidArg, len(value), length)
+	}
+	return NewFixedCharNullable(value+strings.Repeat(" ", int(length)-len(value)), nullable)
 }
 
 func NewFixedBinary(value []byte) (expr.Literal, error) {
-	return expr.NewLiteral[types.FixedBinary](value, false)
+	return NewFixedBinaryNullable(value, false)
+}
+
+func NewFixedBinaryNullable(value []byte, nullable bool) (expr.Literal, error) {
+	return expr.NewLiteral[types.FixedBinary](value, nullable)
 }
 
 func NewVarChar(value string) (expr.Literal, error) {
-	return expr.NewLiteral[*types.VarChar](&types.VarChar{Value: value, Length: uint32(len(value))}, false)
+	return NewVarCharNullable(value, false)
+}
+
+func NewVarCharNullable(value string, nullable bool) (expr.Literal, error) {
+	return expr.NewLiteral[*types.VarChar](&types.VarChar{Value: value, Length: uint32(len(value))}, nullable)
+}
+
+// NewVarCharN creates a VarChar literal with the declared maximum length,
+// returning an error if the value is longer than the length.
+func NewVarCharN(value string, length uint32) (expr.Literal, error) {
+	return NewVarCharNNullable(value, length, false)
+}
+
+func NewVarCharNNullable(value string, length uint32, nullable bool) (expr.Literal, error) {
+	if uint64(len(value)) > uint64(length) {
+		return nil, fmt.Errorf("%w: value of length %d exceeds varchar length %d",
+			substraitgo.ErrInvalidArg, len(value), length)
+	}
+	return expr.NewLiteral[*types.VarChar](&types.VarChar{Value: value, Length: length}, nullable)
 }
 
 // NewDecimalFromTwosComplement create a Decimal literal from twosComplement.
 // twosComplement is a little-endian twos-complement integer representation of complete value
 func NewDecimalFromTwosComplement(twosComplement []byte, precision, scale int32) (expr.Literal, error) {
+	return NewDecimalFromTwosComplementNullable(twosComplement, precision, scale, false)
+}
+
+func NewDecimalFromTwosComplementNullable(twosComplement []byte, precision, scale int32, nullable bool) (expr.Literal, error) {
 	if len(twosComplement) != 16 {
 		return nil, fmt.Errorf("twosComplement must be 16 bytes")
 	}
@@ -136,72 +356,411 @@ func NewDecimalFromTwosComplement(twosComplement []byte, precision, scale int32)
 	if scale < 0 || scale > precision {
 		return nil, fmt.Errorf("scale must be in range [0, precision]")
 	}
-	return expr.NewLiteral[*types.Decimal](&types.Decimal{Value: twosComplement, Precision: precision, Scale: scale}, false)
+	return expr.NewLiteral[*types.Decimal](&types.Decimal{Value: twosComplement, Precision: precision, Scale: scale}, nullable)
 
 }
 
 // NewDecimalFromString create a Decimal literal from decimal value string
 func NewDecimalFromString(value string) (expr.Literal, error) {
+	return NewDecimalFromStringNullable(value, false)
+}
+
+func NewDecimalFromStringNullable(value string, nullable bool) (expr.Literal, error) {
 	v, precision, scale, err := decimalStringToBytes(value)
 	if err != nil {
 		return nil, err
 	}
-	return expr.NewLiteral[*types.Decimal](&types.Decimal{Value: v[:16], Precision: precision, Scale: scale}, false)
+	return expr.NewLiteral[*types.Decimal](&types.Decimal{Value: v[:16], Precision: precision, Scale: scale}, nullable)
 }
 
 // NewPrecisionTimestampFromTime creates a new PrecisionTimestamp literal from a time.Time timestamp value with given precision.
 func NewPrecisionTimestampFromTime(precision types.TimePrecision, tm time.Time) (expr.Literal, error) {
-	return NewPrecisionTimestamp(precision, getTimeValueByPrecision(tm, precision))
+	return NewPrecisionTimestampFromTimeNullable(precision, tm, false)
+}
+
+func NewPrecisionTimestampFromTimeNullable(precision types.TimePrecision, tm time.Time, nullable bool) (expr.Literal, error) {
+	value, err := getTimeValueByPrecision(tm, precision)
+	if err != nil {
+		return nil, err
+	}
+	return NewPrecisionTimestampNullable(precision, value, nullable)
 }
 
 // NewPrecisionTimestamp creates a new PrecisionTimestamp literal with given precision and value.
+// An error wrapping substraitgo.ErrInvalidArg is returned if the precision
+// isn't one of the defined TimePrecision values.
 func NewPrecisionTimestamp(precision types.TimePrecision, value int64) (expr.Literal, error) {
+	return NewPrecisionTimestampNullable(precision, value, false)
+}
+
+func NewPrecisionTimestampNullable(precision types.TimePrecision, value int64, nullable bool) (expr.Literal, error) {
+	if err := checkTimePrecision(precision); err != nil {
+		return nil, err
+	}
 	return expr.NewLiteral[*types.PrecisionTimestamp](&types.PrecisionTimestamp{
 		PrecisionTimestamp: &proto.Expression_Literal_PrecisionTimestamp{
 			Precision: int32(precision),
 			Value:     value,
 		},
-	}, false)
+	}, nullable)
 }
 
 // NewPrecisionTimestampTzFromTime creates a new PrecisionTimestampTz literal from a time.Time timestamp value with given precision.
 func NewPrecisionTimestampTzFromTime(precision types.TimePrecision, tm time.Time) (expr.Literal, error) {
-	return NewPrecisionTimestampTz(precision, getTimeValueByPrecision(tm, precision))
+	return NewPrecisionTimestampTzFromTimeNullable(precision, tm, false)
+}
+
+func NewPrecisionTimestampTzFromTimeNullable(precision types.TimePrecision, tm time.Time, nullable bool) (expr.Literal, error) {
+	value, err := getTimeValueByPrecision(tm, precision)
+	if err != nil {
+		return nil, err
+	}
+	return NewPrecisionTimestampTzNullable(precision, value, nullable)
+}
+
+// NewPrecisionTimestampTzWithZone creates a new PrecisionTimestampTz literal
+// with the given precision from the wall clock time of tm interpreted in
+// the location loc, or in the location of tm if loc is nil. For example,
+// midnight of 2020-01-01 in a location 5 hours ahead of UTC is stored as
+// 2019-12-31T19:00:00Z.
+//
+// The value of a PrecisionTimestampTz is always the instant in UTC, the
+// type can't carry the location, so the literal is the same as that of
+// NewPrecisionTimestampTzFromTime for the resulting instant.
+func NewPrecisionTimestampTzWithZone(precision types.TimePrecision, tm time.Time, loc *time.Location) (expr.Literal, error) {
+	return NewPrecisionTimestampTzWithZoneNullable(precision, tm, loc, false)
+}
+
+func NewPrecisionTimestampTzWithZoneNullable(precision types.TimePrecision, tm time.Time, loc *time.Location, nullable bool) (expr.Literal, error) {
+	if loc != nil {
+		tm = time.Date(tm.Year(), tm.Month(), tm.Day(), tm.Hour(), tm.Minute(), tm.Second(), tm.Nanosecond(), loc)
+	}
+	return NewPrecisionTimestampTzFromTimeNullable(precision, tm.UTC(), nullable)
 }
 
 // NewPrecisionTimestampTz creates a new PrecisionTimestampTz literal with given precision and value.
+// An error wrapping substraitgo.ErrInvalidArg is returned if the precision
+// isn't one of the defined TimePrecision values.
 func NewPrecisionTimestampTz(precision types.TimePrecision, value int64) (expr.Literal, error) {
+	return NewPrecisionTimestampTzNullable(precision, value, false)
+}
+
+func NewPrecisionTimestampTzNullable(precision types.TimePrecision, value int64, nullable bool) (expr.Literal, error) {
+	if err := checkTimePrecision(precision); err != nil {
+		return nil, err
+	}
 	return expr.NewLiteral[*types.PrecisionTimestampTz](&types.PrecisionTimestampTz{
 		PrecisionTimestampTz: &proto.Expression_Literal_PrecisionTimestamp{
 			Precision: int32(precision),
 			Value:     value,
 		},
-	}, false)
+	}, nullable)
+}
+
+func getNullability(nullable bool) types.Nullability {
+	if nullable {
+		return types.NullabilityNullable
+	}
+	return types.NullabilityRequired
+}
+
+// checkTimePrecision returns an error wrapping substraitgo.ErrInvalidArg
+// if precision isn't one of the defined TimePrecision values.
+func checkTimePrecision(precision types.TimePrecision) error {
+	if _, err := types.ProtoToTimePrecision(precision.ToProtoVal()); err != nil {
+		return fmt.Errorf("%w: %s", substraitgo.ErrInvalidArg, err)
+	}
+	return nil
 }
 
-func getTimeValueByPrecision(tm time.Time, precision types.TimePrecision) int64 {
+func getTimeValueByPrecision(tm time.Time, precision types.TimePrecision) (int64, error) {
 	switch precision {
 	case types.PrecisionSeconds:
-		return tm.Unix()
+		return tm.Unix(), nil
 	case types.PrecisionDeciSeconds:
-		return tm.UnixMilli() / 100
+		return tm.UnixMilli() / 100, nil
 	case types.PrecisionCentiSeconds:
-		return tm.UnixMilli() / 10
+		return tm.UnixMilli() / 10, nil
 	case types.PrecisionMilliSeconds:
-		return tm.UnixMilli()
+		return tm.UnixMilli(), nil
 	case types.PrecisionEMinus4Seconds:
-		return tm.UnixMicro() / 100
+		return tm.UnixMicro() / 100, nil
 	case types.PrecisionEMinus5Seconds:
-		return tm.UnixMicro() / 10
+		return tm.UnixMicro() / 10, nil
 	case types.PrecisionMicroSeconds:
-		return tm.UnixMicro()
+		return tm.UnixMicro(), nil
 	case types.PrecisionEMinus7Seconds:
-		return tm.UnixNano() / 100
+		return tm.UnixNano() / 100, nil
 	case types.PrecisionEMinus8Seconds:
-		return tm.UnixNano() / 10
+		return tm.UnixNano() / 10, nil
 	case types.PrecisionNanoSeconds:
-		return tm.UnixNano()
+		return tm.UnixNano(), nil
 	default:
-		panic(fmt.Sprintf("unknown TimePrecision %v", precision))
+		return 0, checkTimePrecision(precision)
+	}
+}
+
+// NewUserDefined creates a literal of a user defined type, with the value
+// as an opaque protobuf Any payload. The type reference anchor of typeRef
+// must refer to a type registered in the provided extension registry.
+// If typeParams is nil, the type parameters of typeRef are used.
+func NewUserDefined(reg expr.ExtensionRegistry, typeRef types.UserDefinedType, value *anypb.Any, typeParams []types.TypeParam) (expr.Literal, error) {
+	if value == nil {
+		return nil, fmt.Errorf("%w: user defined literal value must not be nil", substraitgo.ErrInvalidArg)
+	}
+
+	if _, ok := reg.LookupType(typeRef.TypeReference); !ok {
+		return nil, fmt.Errorf("%w: user defined type with anchor %d is not registered",
+			substraitgo.ErrNotFound, typeRef.TypeReference)
+	}
+
+	if typeParams == nil {
+		typeParams = typeRef.TypeParameters
+	}
+
+	typ := typeRef
+	typ.TypeParameters = typeParams
+	return &expr.ProtoLiteral{Value: value, Type: &typ}, nil
+}
+
+// NewList creates a List literal from the provided elements. All of the
+// elements must have the same type, ignoring nullability, and the element
+// type of the list will be nullable if any of the elements are nullable.
+//
+// The element type cannot be inferred from an empty slice of elements,
+// use NewEmptyList instead to create an empty list literal.
+func NewList(elements []expr.Literal) (expr.Literal, error) {
+	if len(elements) == 0 {
+		return nil, fmt.Errorf("%w: cannot infer element type of empty list, use NewEmptyList",
+			substraitgo.ErrInvalidArg)
+	}
+
+	elemType, err := commonLiteralType(elements, func(l expr.Literal) expr.Literal { return l })
+	if err != nil {
+		return nil, fmt.Errorf("list elements: %w", err)
+	}
+
+	return &expr.ListLiteral{
+		Value: elements,
+		Type: &types.ListType{
+			Nullability: types.NullabilityRequired,
+			Type:        elemType,
+		},
+	}, nil
+}
+
+// NewEmptyList creates an empty List literal with the given element type.
+// The element type is serialized as the empty_list of the protobuf
+// literal, so it is preserved by expr.LiteralFromProto.
+func NewEmptyList(elementType types.Type) (expr.Literal, error) {
+	if elementType == nil {
+		return nil, fmt.Errorf("%w: element type for empty list must not be nil",
+			substraitgo.ErrInvalidArg)
+	}
+	return expr.NewEmptyListLiteral(elementType, false), nil
+}
+
+// commonLiteralType returns the type shared by the literals selected by
+// get from each item, ignoring nullability. The returned type is nullable
+// if any of the literals have a nullable type.
+func commonLiteralType[T any](items []T, get func(T) expr.Literal) (types.Type, error) {
+	var (
+		out      types.Type
+		base     types.Type
+		nullable bool
+	)
+
+	for i, item := range items {
+		lit := get(item)
+		if lit == nil {
+			return nil, fmt.Errorf("%w: literal at index %d is nil", substraitgo.ErrInvalidArg, i)
+		}
+
+		t := lit.GetType()
+		nullable = nullable || t.GetNullability() == types.NullabilityNullable
+		if out == nil {
+			out, base = t, t.WithNullability(types.NullabilityRequired)
+			continue
+		}
+
+		if !base.Equals(t.WithNullability(types.NullabilityRequired)) {
+			return nil, fmt.Errorf("%w: literal at index %d has type %s, expected %s",
+				substraitgo.ErrInvalidArg, i, t, out)
+		}
+	}
+
+	if nullable {
+		return out.WithNullability(types.NullabilityNullable), nil
+	}
+	return out, nil
+}
+
+// MapEntry is a single key/value pair used to construct a Map literal.
+type MapEntry struct {
+	Key   expr.Literal
+	Value expr.Literal
+}
+
+// NewMap creates a Map literal from the provided entries, preserving
+// their order. All of the keys must share a type and all of the values
+// must share a type, ignoring nullability. Duplicate keys are rejected.
+//
+// The key and value types cannot be inferred from an empty slice of
+// entries, use NewEmptyMap instead to create an empty map literal.
+func NewMap(entries []MapEntry) (expr.Literal, error) {
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%w: cannot infer key and value types of empty map, use NewEmptyMap",
+			substraitgo.ErrInvalidArg)
+	}
+
+	keyType, err := commonLiteralType(entries, func(e MapEntry) expr.Literal { return e.Key })
+	if err != nil {
+		return nil, fmt.Errorf("map keys: %w", err)
+	}
+
+	valueType, err := commonLiteralType(entries, func(e MapEntry) expr.Literal { return e.Value })
+	if err != nil {
+		return nil, fmt.Errorf("map values: %w", err)
+	}
+
+	value := make(expr.MapLiteralValue, len(entries))
+	for i, e := range entries {
+		for _, prev := range entries[:i] {
+			if e.Key.Equals(prev.Key) {
+				return nil, fmt.Errorf("%w: duplicate map key %s", substraitgo.ErrInvalidArg, e.Key)
+			}
+		}
+		value[i].Key, value[i].Value = e.Key, e.Value
+	}
+
+	return &expr.MapLiteral{
+		Value: value,
+		Type: &types.MapType{
+			Nullability: types.NullabilityRequired,
+			Key:         keyType,
+			Value:       valueType,
+		},
+	}, nil
+}
+
+// NewEmptyMap creates an empty Map literal with the given key and value types.
+// The types are serialized as the empty_map of the protobuf literal, so
+// they are preserved by expr.LiteralFromProto.
+func NewEmptyMap(keyType, valueType types.Type) (expr.Literal, error) {
+	if keyType == nil || valueType == nil {
+		return nil, fmt.Errorf("%w: key and value types for empty map must not be nil",
+			substraitgo.ErrInvalidArg)
+	}
+	return expr.NewEmptyMapLiteral(keyType, valueType, false), nil
+}
+
+// NewStruct creates a Struct literal from the provided field literals.
+// The struct type is formed from the types of the fields in order, and
+// an empty slice of fields results in an empty struct.
+func NewStruct(fields []expr.Literal) (expr.Literal, error) {
+	typeList := make([]types.Type, len(fields))
+	for i, f := range fields {
+		if f == nil {
+			return nil, fmt.Errorf("%w: struct field %d is nil", substraitgo.ErrInvalidArg, i)
+		}
+		typeList[i] = f.GetType()
+	}
+
+	return &expr.StructLiteral{
+		Value: fields,
+		Type: &types.StructType{
+			Nullability: types.NullabilityRequired,
+			Types:       typeList,
+		},
+	}, nil
+}
+
+// NewNull creates a typed null literal of the given type. The type must
+// not have a nullability of types.NullabilityRequired, since a required
+// type cannot be null.
+func NewNull(t types.Type) (expr.Literal, error) {
+	if t == nil {
+		return nil, fmt.Errorf("%w: type for null literal must not be nil", substraitgo.ErrInvalidArg)
+	}
+	if t.GetNullability() == types.NullabilityRequired {
+		return nil, fmt.Errorf("%w: cannot create null literal of required type %s",
+			substraitgo.ErrInvalidArg, t)
+	}
+	return &expr.NullLiteral{Type: t}, nil
+}
+
+// The accessors below return the Go value of a literal, such as one read
+// from a plan, along with true. They return false if the literal is null
+// or not of one of the types listed for the accessor, such as a literal
+// created by the corresponding New... constructors.
+
+// AsBool returns the value of a boolean literal.
+func AsBool(l expr.Literal) (bool, bool) {
+	if v, ok := l.(*expr.PrimitiveLiteral[bool]); ok {
+		return v.Value, true
+	}
+	return false, false
+}
+
+// AsInt64 returns the value of an i8, i16, i32 or i64 literal.
+func AsInt64(l expr.Literal) (int64, bool) {
+	switch v := l.(type) {
+	case *expr.PrimitiveLiteral[int8]:
+		return int64(v.Value), true
+	case *expr.PrimitiveLiteral[int16]:
+		return int64(v.Value), true
+	case *expr.PrimitiveLiteral[int32]:
+		return int64(v.Value), true
+	case *expr.PrimitiveLiteral[int64]:
+		return v.Value, true
+	}
+	return 0, false
+}
+
+// AsFloat64 returns the value of an fp32 or fp64 literal.
+func AsFloat64(l expr.Literal) (float64, bool) {
+	switch v := l.(type) {
+	case *expr.PrimitiveLiteral[float32]:
+		return float64(v.Value), true
+	case *expr.PrimitiveLiteral[float64]:
+		return v.Value, true
+	}
+	return 0, false
+}
+
+// AsString returns the value of a string, fixed char or varchar literal.
+// The value of a fixed char literal includes its padding.
+func AsString(l expr.Literal) (string, bool) {
+	switch v := l.(type) {
+	case *expr.PrimitiveLiteral[string]:
+		return v.Value, true
+	case *expr.PrimitiveLiteral[types.FixedChar]:
+		return string(v.Value), true
+	case *expr.ProtoLiteral:
+		if _, ok := v.Type.(*types.VarCharType); ok {
+			return v.Value.(string), true
+		}
+	}
+	return "", false
+}
+
+// AsBinary returns the value of a binary or fixed binary literal. The
+// returned slice is shared with the literal and must not be modified.
+func AsBinary(l expr.Literal) ([]byte, bool) {
+	switch v := l.(type) {
+	case *expr.ByteSliceLiteral[[]byte]:
+		return v.Value, true
+	case *expr.ByteSliceLiteral[types.FixedBinary]:
+		return v.Value, true
+	}
+	return nil, false
+}
+
+// AsDate returns the value of a date literal as the number of days
+// since the Unix epoch.
+func AsDate(l expr.Literal) (types.Date, bool) {
+	if v, ok := l.(*expr.PrimitiveLiteral[types.Date]); ok {
+		return v.Value, true
 	}
+	return 0, false
 }
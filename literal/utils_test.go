@@ -9,9 +9,15 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	substraitgo "github.com/substrait-io/substrait-go"
 	"github.com/substrait-io/substrait-go/expr"
+	"github.com/substrait-io/substrait-go/extensions"
 	"github.com/substrait-io/substrait-go/proto"
 	"github.com/substrait-io/substrait-go/types"
+	pb "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
 func TestNewBool(t *testing.T) {
@@ -177,6 +183,51 @@ func TestNewFixedChar(t *testing.T) {
 	}
 }
 
+func TestNewFixedCharN(t *testing.T) {
+	got, err := NewFixedCharN("ab", 5)
+	require.NoError(t, err)
+	assert.Equal(t, expr.NewFixedCharLiteral("ab   ", false), got)
+
+	// the declared length is kept on round trip
+	rt := expr.LiteralFromProto(got.ToProtoLiteral())
+	assert.Equal(t, "char<5>", rt.GetType().String())
+	assert.True(t, got.Equals(rt))
+
+	got, err = NewFixedCharNNullable("abcde", 5, true)
+	require.NoError(t, err)
+	assert.Equal(t, expr.NewFixedCharLiteral("abcde", true), got)
+
+	_, err = NewFixedCharN("abcdef", 5)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "value of length 6 exceeds fixedchar length 5")
+}
+
+func TestUUIDToGoUUID(t *testing.T) {
+	guid := uuid.New()
+	lit, err := NewUUID(guid)
+	require.NoError(t, err)
+
+	got, err := UUIDToGoUUID(lit)
+	require.NoError(t, err)
+	assert.Equal(t, guid, got)
+
+	got, err = UUIDToGoUUID(expr.LiteralFromProto(lit.ToProtoLiteral()))
+	require.NoError(t, err)
+	assert.Equal(t, guid, got)
+
+	short, err := NewUUIDFromBytes([]byte{1, 2, 3})
+	require.NoError(t, err)
+	_, err = UUIDToGoUUID(short)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "uuid literal value must be 16 bytes, got 3")
+
+	str, err := NewString("foo")
+	require.NoError(t, err)
+	_, err = UUIDToGoUUID(str)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "expected a uuid literal")
+}
+
 func TestNewFloat32(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -337,6 +388,41 @@ func TestNewInt8(t *testing.T) {
 	}
 }
 
+func TestNarrowIntRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		lit      func(nullable bool) (expr.Literal, error)
+		expected types.Type
+	}{
+		{"i8", func(n bool) (expr.Literal, error) { return NewInt8Nullable(math.MinInt8, n) }, &types.Int8Type{}},
+		{"i16", func(n bool) (expr.Literal, error) { return NewInt16Nullable(math.MaxInt16, n) }, &types.Int16Type{}},
+	}
+	for _, tt := range tests {
+		for _, nullable := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s nullable=%t", tt.name, nullable), func(t *testing.T) {
+				lit, err := tt.lit(nullable)
+				require.NoError(t, err)
+
+				// the value is held in an int32 field, but the literal
+				// keeps its narrow type
+				out := lit.ToProtoLiteral()
+				switch tt.name {
+				case "i8":
+					assert.EqualValues(t, math.MinInt8, out.GetI8())
+				case "i16":
+					assert.EqualValues(t, math.MaxInt16, out.GetI16())
+				}
+				assert.Equal(t, nullable, out.Nullable)
+
+				got := expr.LiteralFromProto(out)
+				assert.IsType(t, tt.expected, got.GetType())
+				assert.Equal(t, lit.GetType().GetNullability(), got.GetType().GetNullability())
+				assert.True(t, lit.Equals(got))
+			})
+		}
+	}
+}
+
 func TestNewIntervalDaysToSecond(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -363,6 +449,38 @@ func TestNewIntervalDaysToSecond(t *testing.T) {
 	}
 }
 
+func TestNewIntervalFromDuration(t *testing.T) {
+	day := 24 * time.Hour
+	tests := []struct {
+		name     string
+		duration time.Duration
+		want     expr.Literal
+	}{
+		{"0", 0, createIntervalDaysLiteral(0, 0, 0)},
+		{"1.5 seconds", 1500 * time.Millisecond, createIntervalDaysLiteral(0, 1, 500000)},
+		{"crossing a day", day + 2*time.Hour + 3*time.Second + 4*time.Microsecond, createIntervalDaysLiteral(1, 7203, 4)},
+		{"3 days", 3 * day, createIntervalDaysLiteral(3, 0, 0)},
+		{"sub-microsecond truncated", 2*time.Microsecond + 999*time.Nanosecond, createIntervalDaysLiteral(0, 0, 2)},
+		{"negative", -(1500 * time.Millisecond), createIntervalDaysLiteral(0, -1, -500000)},
+		{"negative crossing a day", -(2*day + time.Second + time.Microsecond), createIntervalDaysLiteral(-2, -1, -1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewIntervalFromDuration(tt.duration)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+
+			// summing the components of the serialized literal gives
+			// back the duration
+			p := got.ToProto().GetLiteral().GetIntervalDayToSecond()
+			require.NotNil(t, p)
+			sum := time.Duration(p.Days)*day + time.Duration(p.Seconds)*time.Second +
+				time.Duration(p.Subseconds)*time.Microsecond
+			assert.Equal(t, tt.duration.Truncate(time.Microsecond), sum)
+		})
+	}
+}
+
 func createIntervalDaysLiteral(days, seconds int32, micros int64) *expr.ProtoLiteral {
 	return &expr.ProtoLiteral{
 		Value: &types.IntervalDayToSecond{
@@ -473,11 +591,16 @@ func TestNewTime(t *testing.T) {
 		{"24 hours", 24, 0, 0, 0},
 		{"-1 hour", -1, 0, 0, 0},
 		{"1440 minutes", 0, 1440, 0, 0},
+		{"2562048 hours", 2562048, 0, 0, 0},
+		{"MaxInt32 hours", math.MaxInt32, 0, 0, 0},
+		{"-1 minute", 1, -1, 0, 0},
+		{"-1 second", 1, 0, -1, 0},
+		{"-1 microsecond", 1, 0, 0, -1},
 	}
 	for _, tt := range negTests {
 		t.Run(tt.name, func(t *testing.T) {
 			_, err := NewTime(tt.hours, tt.minutes, tt.seconds, tt.micros)
-			assert.Error(t, err)
+			assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
 		})
 	}
 }
@@ -664,6 +787,28 @@ func TestNewVarChar(t *testing.T) {
 	}
 }
 
+func TestNewVarCharN(t *testing.T) {
+	got, err := NewVarCharN("ab", 10)
+	require.NoError(t, err)
+	assert.Equal(t, &expr.ProtoLiteral{
+		Value: "ab",
+		Type:  &types.VarCharType{Length: 10, Nullability: types.NullabilityRequired},
+	}, got)
+
+	// the declared length is kept on round trip
+	rt := expr.LiteralFromProto(got.ToProtoLiteral())
+	assert.Equal(t, "varchar<10>", rt.GetType().String())
+	assert.True(t, got.Equals(rt))
+
+	got, err = NewVarCharNNullable("", 0, true)
+	require.NoError(t, err)
+	assert.Equal(t, "varchar?<0>", got.GetType().String())
+
+	_, err = NewVarCharN("abc", 2)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "value of length 3 exceeds varchar length 2")
+}
+
 func createVarCharLiteral(value string) *expr.ProtoLiteral {
 	return &expr.ProtoLiteral{
 		// TODO check if .Value should be types.VarChar instead of string
@@ -738,3 +883,567 @@ func TestNewPrecisionTimestampTz(t *testing.T) {
 		})
 	}
 }
+
+func TestPrecisionTimestampInvalidPrecision(t *testing.T) {
+	now := time.Now()
+	for _, precision := range []types.TimePrecision{types.PrecisionUnknown, 10, 42} {
+		t.Run(fmt.Sprint(precision), func(t *testing.T) {
+			constructors := map[string]func() (expr.Literal, error){
+				"NewPrecisionTimestamp": func() (expr.Literal, error) { return NewPrecisionTimestamp(precision, 0) },
+				"NewPrecisionTimestampFromTime": func() (expr.Literal, error) {
+					return NewPrecisionTimestampFromTime(precision, now)
+				},
+				"NewPrecisionTimestampTz": func() (expr.Literal, error) { return NewPrecisionTimestampTz(precision, 0) },
+				"NewPrecisionTimestampTzFromTime": func() (expr.Literal, error) {
+					return NewPrecisionTimestampTzFromTime(precision, now)
+				},
+				"NewPrecisionTimestampTzWithZone": func() (expr.Literal, error) {
+					return NewPrecisionTimestampTzWithZone(precision, now, time.UTC)
+				},
+			}
+			for name, fn := range constructors {
+				var (
+					lit expr.Literal
+					err error
+				)
+				require.NotPanics(t, func() { lit, err = fn() }, name)
+				assert.Nil(t, lit, name)
+				assert.ErrorIs(t, err, substraitgo.ErrInvalidArg, name)
+				assert.ErrorContains(t, err, fmt.Sprintf("invalid TimePrecision value %d", precision), name)
+			}
+		})
+	}
+}
+
+func TestNewPrecisionTimestampTzWithZone(t *testing.T) {
+	plus5 := time.FixedZone("+05:00", 5*60*60)
+	withOffset, err := time.Parse(time.RFC3339, "2020-01-01T00:00:00+05:00")
+	require.NoError(t, err)
+	utc := time.Date(2019, 12, 31, 19, 0, 0, 0, time.UTC)
+	want := &expr.ProtoLiteral{Value: utc.UnixMicro(), Type: &types.PrecisionTimestampTzType{PrecisionTimestampType: types.PrecisionTimestampType{Precision: types.PrecisionMicroSeconds, Nullability: types.NullabilityRequired}}}
+
+	fromOffset, err := NewPrecisionTimestampTzFromTime(types.PrecisionMicroSeconds, withOffset)
+	require.NoError(t, err)
+	fromUTC, err := NewPrecisionTimestampTzFromTime(types.PrecisionMicroSeconds, utc)
+	require.NoError(t, err)
+	assert.Equal(t, want, fromOffset)
+	assert.Equal(t, want, fromUTC)
+
+	// the wall clock time is interpreted in the given location
+	wall := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	got, err := NewPrecisionTimestampTzWithZone(types.PrecisionMicroSeconds, wall, plus5)
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	// without a location, the location of the time is used
+	got, err = NewPrecisionTimestampTzWithZone(types.PrecisionMicroSeconds, withOffset, nil)
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+}
+
+func TestNewList(t *testing.T) {
+	one, _ := NewInt32(1)
+	two, _ := NewInt32(2)
+	null := &expr.NullLiteral{Type: &types.Int32Type{Nullability: types.NullabilityNullable}}
+	str, _ := NewString("foo")
+
+	tests := []struct {
+		name     string
+		elements []expr.Literal
+		wantType types.Type
+		wantErr  assert.ErrorAssertionFunc
+	}{
+		{"int32", []expr.Literal{one, two}, &types.ListType{Nullability: types.NullabilityRequired,
+			Type: &types.Int32Type{Nullability: types.NullabilityRequired}}, assert.NoError},
+		{"with null", []expr.Literal{one, null, two}, &types.ListType{Nullability: types.NullabilityRequired,
+			Type: &types.Int32Type{Nullability: types.NullabilityNullable}}, assert.NoError},
+		{"mismatched types", []expr.Literal{one, str}, nil, assert.Error},
+		{"empty", []expr.Literal{}, nil, assert.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewList(tt.elements)
+			if !tt.wantErr(t, err, fmt.Sprintf("NewList(%v)", tt.elements)) {
+				return
+			}
+			if err != nil {
+				assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+				return
+			}
+			assert.Equal(t, tt.wantType, got.GetType())
+
+			protoLit := got.ToProtoLiteral()
+			assert.Len(t, protoLit.GetList().GetValues(), len(tt.elements))
+			assert.True(t, got.Equals(expr.LiteralFromProto(protoLit)))
+		})
+	}
+}
+
+func TestNewEmptyList(t *testing.T) {
+	got, err := NewEmptyList(&types.StringType{Nullability: types.NullabilityNullable})
+	assert.NoError(t, err)
+	assert.Equal(t, "list<string?>", got.GetType().String())
+
+	protoLit := got.ToProtoLiteral()
+	assert.NotNil(t, protoLit.GetEmptyList())
+	assert.True(t, got.Equals(expr.LiteralFromProto(protoLit)))
+
+	_, err = NewEmptyList(nil)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+}
+
+func TestEmptyCollectionTypes(t *testing.T) {
+	dec := &types.DecimalType{Nullability: types.NullabilityNullable, Precision: 10, Scale: 2}
+
+	list, err := NewEmptyList(dec)
+	require.NoError(t, err)
+	assert.Equal(t, "list<decimal?<10,2>>", list.GetType().String())
+
+	// the element type is carried by the empty list even without values
+	protoLit := list.ToProtoLiteral()
+	elem := protoLit.GetEmptyList().GetType().GetDecimal()
+	require.NotNil(t, elem)
+	assert.EqualValues(t, 10, elem.Precision)
+	assert.EqualValues(t, 2, elem.Scale)
+	assert.Equal(t, proto.Type_NULLABILITY_NULLABLE, elem.Nullability)
+
+	roundTrip := expr.LiteralFromProto(protoLit)
+	assert.True(t, list.Equals(roundTrip))
+	assert.True(t, dec.Equals(roundTrip.GetType().(*types.ListType).Type))
+
+	e, err := expr.ExprFromProto(list.ToProto(), nil, expr.NewEmptyExtensionRegistry(&extensions.DefaultCollection))
+	require.NoError(t, err)
+	assert.Equal(t, "list<decimal?<10,2>>", e.GetType().String())
+
+	m, err := NewEmptyMap(&types.StringType{}, &types.ListType{Type: dec})
+	require.NoError(t, err)
+	protoLit = m.ToProtoLiteral()
+	value := protoLit.GetEmptyMap().GetValue().GetList().GetType().GetDecimal()
+	require.NotNil(t, value)
+	assert.EqualValues(t, 10, value.Precision)
+	assert.EqualValues(t, 2, value.Scale)
+	assert.NotNil(t, protoLit.GetEmptyMap().GetKey().GetString_())
+
+	roundTrip = expr.LiteralFromProto(protoLit)
+	assert.True(t, m.Equals(roundTrip))
+	assert.Equal(t, m.GetType().String(), roundTrip.GetType().String())
+}
+
+func TestNewMap(t *testing.T) {
+	keyA, _ := NewString("a")
+	keyB, _ := NewString("b")
+	val1, _ := NewInt64(1)
+	val2, _ := NewInt64(2)
+	nullVal := &expr.NullLiteral{Type: &types.Int64Type{Nullability: types.NullabilityNullable}}
+	badVal, _ := NewInt32(3)
+
+	tests := []struct {
+		name     string
+		entries  []MapEntry
+		wantType string
+		wantErr  assert.ErrorAssertionFunc
+	}{
+		{"string to int64", []MapEntry{{keyB, val1}, {keyA, val2}}, "map<string, i64>", assert.NoError},
+		{"null value", []MapEntry{{keyA, val1}, {keyB, nullVal}}, "map<string, i64?>", assert.NoError},
+		{"duplicate key", []MapEntry{{keyA, val1}, {keyA, val2}}, "", assert.Error},
+		{"mismatched values", []MapEntry{{keyA, val1}, {keyB, badVal}}, "", assert.Error},
+		{"empty", nil, "", assert.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewMap(tt.entries)
+			if !tt.wantErr(t, err, fmt.Sprintf("NewMap(%v)", tt.entries)) {
+				return
+			}
+			if err != nil {
+				assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+				return
+			}
+			assert.Equal(t, tt.wantType, got.GetType().String())
+
+			protoLit := got.ToProtoLiteral()
+			kvs := protoLit.GetMap().GetKeyValues()
+			assert.Len(t, kvs, len(tt.entries))
+			for i, kv := range kvs {
+				assert.Equal(t, tt.entries[i].Key.ToProtoLiteral().GetString_(), kv.Key.GetString_())
+			}
+
+			roundTrip := expr.LiteralFromProto(protoLit)
+			assert.True(t, got.Equals(roundTrip))
+			for i, kv := range roundTrip.(*expr.MapLiteral).Value {
+				assert.True(t, tt.entries[i].Key.Equals(kv.Key))
+				assert.True(t, tt.entries[i].Value.Equals(kv.Value))
+			}
+		})
+	}
+}
+
+func TestNewEmptyMap(t *testing.T) {
+	got, err := NewEmptyMap(&types.StringType{}, &types.Int64Type{})
+	assert.NoError(t, err)
+	assert.Equal(t, "map<string, i64>", got.GetType().String())
+
+	protoLit := got.ToProtoLiteral()
+	assert.NotNil(t, protoLit.GetEmptyMap())
+	assert.True(t, got.Equals(expr.LiteralFromProto(protoLit)))
+
+	_, err = NewEmptyMap(nil, &types.Int64Type{})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+}
+
+func TestNewStruct(t *testing.T) {
+	i, _ := NewInt32(1)
+	s, _ := NewString("foo")
+	null := &expr.NullLiteral{Type: &types.Float64Type{Nullability: types.NullabilityNullable}}
+
+	tests := []struct {
+		name     string
+		fields   []expr.Literal
+		wantType string
+	}{
+		{"empty", []expr.Literal{}, "struct<>"},
+		{"fields", []expr.Literal{i, s, null}, "struct<i32, string, fp64?>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewStruct(tt.fields)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantType, got.GetType().String())
+
+			protoLit := got.ToProtoLiteral()
+			assert.Len(t, protoLit.GetStruct().GetFields(), len(tt.fields))
+			assert.True(t, got.Equals(expr.LiteralFromProto(protoLit)))
+		})
+	}
+
+	_, err := NewStruct([]expr.Literal{i, nil})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+}
+
+func TestNewStructInList(t *testing.T) {
+	i1, _ := NewInt32(1)
+	i2, _ := NewInt32(2)
+	s1, _ := NewString("a")
+	nullStr := &expr.NullLiteral{Type: &types.StringType{Nullability: types.NullabilityNullable}}
+
+	row1, err := NewStruct([]expr.Literal{i1, s1})
+	assert.NoError(t, err)
+	row2, err := NewStruct([]expr.Literal{i2, nullStr})
+	assert.NoError(t, err)
+
+	_, err = NewList([]expr.Literal{row1, row2})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+
+	row2, err = NewStruct([]expr.Literal{i2, s1})
+	assert.NoError(t, err)
+
+	list, err := NewList([]expr.Literal{row1, row2})
+	assert.NoError(t, err)
+	assert.Equal(t, "list<struct<i32, string>>", list.GetType().String())
+	assert.True(t, list.Equals(expr.LiteralFromProto(list.ToProtoLiteral())))
+}
+
+func TestNewNull(t *testing.T) {
+	tests := []types.Type{
+		&types.BooleanType{Nullability: types.NullabilityNullable},
+		&types.Int32Type{Nullability: types.NullabilityNullable},
+		&types.StringType{Nullability: types.NullabilityNullable},
+		&types.DateType{Nullability: types.NullabilityNullable},
+		&types.FixedCharType{Nullability: types.NullabilityNullable, Length: 5},
+		&types.DecimalType{Nullability: types.NullabilityNullable, Precision: 10, Scale: 2},
+		&types.ListType{Nullability: types.NullabilityNullable,
+			Type: &types.StringType{Nullability: types.NullabilityRequired}},
+		&types.MapType{Nullability: types.NullabilityNullable,
+			Key:   &types.StringType{Nullability: types.NullabilityRequired},
+			Value: &types.Int64Type{Nullability: types.NullabilityNullable}},
+		&types.StructType{Nullability: types.NullabilityNullable,
+			Types: []types.Type{&types.Int8Type{Nullability: types.NullabilityRequired}}},
+	}
+	for _, typ := range tests {
+		t.Run(typ.String(), func(t *testing.T) {
+			got, err := NewNull(typ)
+			assert.NoError(t, err)
+			assert.True(t, got.IsNull())
+			assert.Equal(t, typ, got.GetType())
+
+			protoLit := got.ToProtoLiteral()
+			assert.True(t, protoLit.Nullable)
+			assert.Equal(t, types.TypeToProto(typ), protoLit.GetNull())
+
+			roundTrip := expr.LiteralFromProto(protoLit)
+			assert.True(t, roundTrip.IsNull())
+			assert.True(t, typ.Equals(roundTrip.GetType()))
+		})
+	}
+
+	_, err := NewNull(&types.Int32Type{Nullability: types.NullabilityRequired})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	_, err = NewNull(nil)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+
+	notNull, _ := NewInt32(1)
+	assert.False(t, notNull.IsNull())
+}
+
+func TestNullableConstructors(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name string
+		ctor func(nullable bool) (expr.Literal, error)
+	}{
+		{"bool", func(n bool) (expr.Literal, error) { return NewBoolNullable(true, n) }},
+		{"i8", func(n bool) (expr.Literal, error) { return NewInt8Nullable(1, n) }},
+		{"i16", func(n bool) (expr.Literal, error) { return NewInt16Nullable(1, n) }},
+		{"i32", func(n bool) (expr.Literal, error) { return NewInt32Nullable(1, n) }},
+		{"i64", func(n bool) (expr.Literal, error) { return NewInt64Nullable(1, n) }},
+		{"fp32", func(n bool) (expr.Literal, error) { return NewFloat32Nullable(1.5, n) }},
+		{"fp64", func(n bool) (expr.Literal, error) { return NewFloat64Nullable(1.5, n) }},
+		{"string", func(n bool) (expr.Literal, error) { return NewStringNullable("foo", n) }},
+		{"binary", func(n bool) (expr.Literal, error) { return NewBinaryNullable([]byte("foo"), n) }},
+		{"date", func(n bool) (expr.Literal, error) { return NewDateNullable(100, n) }},
+		{"time", func(n bool) (expr.Literal, error) { return NewTimeNullable(1, 2, 3, 4, n) }},
+		{"time micros", func(n bool) (expr.Literal, error) { return NewTimeFromMicrosNullable(1000, n) }},
+		{"timestamp", func(n bool) (expr.Literal, error) { return NewTimestampNullable(ts, n) }},
+		{"timestamp micros", func(n bool) (expr.Literal, error) { return NewTimestampFromMicrosNullable(1000, n) }},
+		{"timestamp_tz", func(n bool) (expr.Literal, error) { return NewTimestampTZNullable(ts, n) }},
+		{"timestamp_tz micros", func(n bool) (expr.Literal, error) { return NewTimestampTZFromMicrosNullable(1000, n) }},
+		{"interval_year", func(n bool) (expr.Literal, error) { return NewIntervalYearsToMonthNullable(1, 2, n) }},
+		{"interval_day", func(n bool) (expr.Literal, error) { return NewIntervalDaysToSecondNullable(1, 2, 3, n) }},
+		{"interval_day duration", func(n bool) (expr.Literal, error) { return NewIntervalFromDurationNullable(time.Hour, n) }},
+		{"interval_compound", func(n bool) (expr.Literal, error) {
+			return NewIntervalCompoundNullable(1, 2, 3, 4, 5, types.PrecisionMilliSeconds, n)
+		}},
+		{"uuid", func(n bool) (expr.Literal, error) { return NewUUIDNullable(uuid.New(), n) }},
+		{"fixedchar", func(n bool) (expr.Literal, error) { return NewFixedCharNullable("foo", n) }},
+		{"fixedbinary", func(n bool) (expr.Literal, error) { return NewFixedBinaryNullable([]byte{1, 2}, n) }},
+		{"varchar", func(n bool) (expr.Literal, error) { return NewVarCharNullable("foo", n) }},
+		{"fixedchar n", func(n bool) (expr.Literal, error) { return NewFixedCharNNullable("foo", 5, n) }},
+		{"varchar n", func(n bool) (expr.Literal, error) { return NewVarCharNNullable("foo", 5, n) }},
+		{"decimal", func(n bool) (expr.Literal, error) { return NewDecimalFromStringNullable("12.34", n) }},
+		{"precision_timestamp", func(n bool) (expr.Literal, error) {
+			return NewPrecisionTimestampFromTimeNullable(types.PrecisionMilliSeconds, ts, n)
+		}},
+		{"precision_timestamp_tz", func(n bool) (expr.Literal, error) {
+			return NewPrecisionTimestampTzFromTimeNullable(types.PrecisionMilliSeconds, ts, n)
+		}},
+	}
+	for _, tt := range tests {
+		for _, nullable := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s nullable=%t", tt.name, nullable), func(t *testing.T) {
+				lit, err := tt.ctor(nullable)
+				assert.NoError(t, err)
+
+				want := types.NullabilityRequired
+				if nullable {
+					want = types.NullabilityNullable
+				}
+				assert.Equal(t, want, lit.GetType().GetNullability())
+
+				protoLit := lit.ToProtoLiteral()
+				assert.Equal(t, nullable, protoLit.Nullable)
+
+				roundTrip := expr.LiteralFromProto(protoLit)
+				assert.Equal(t, want, roundTrip.GetType().GetNullability())
+				assert.True(t, pb.Equal(protoLit, roundTrip.ToProtoLiteral()))
+			})
+		}
+	}
+}
+
+func TestNewBinary(t *testing.T) {
+	tests := []struct {
+		name  string
+		value []byte
+		want  []byte
+	}{
+		{"embedded zeros", []byte{0x00, 0x01, 0x00, 0x00, 0xff, 0x00}, []byte{0x00, 0x01, 0x00, 0x00, 0xff, 0x00}},
+		{"empty", []byte{}, []byte{}},
+		{"nil", nil, []byte{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewBinary(tt.value)
+			assert.NoError(t, err)
+			assert.False(t, got.IsNull())
+			assert.Equal(t, &types.BinaryType{Nullability: types.NullabilityRequired}, got.GetType())
+
+			protoLit := got.ToProtoLiteral()
+			assert.IsType(t, &proto.Expression_Literal_Binary{}, protoLit.LiteralType)
+			assert.Equal(t, tt.want, protoLit.GetBinary())
+
+			data, err := pb.Marshal(protoLit)
+			assert.NoError(t, err)
+			var decoded proto.Expression_Literal
+			assert.NoError(t, pb.Unmarshal(data, &decoded))
+
+			roundTrip := expr.LiteralFromProto(&decoded)
+			assert.True(t, got.Equals(roundTrip))
+			assert.Len(t, roundTrip.(*expr.ByteSliceLiteral[[]byte]).Value, len(tt.want))
+		})
+	}
+}
+
+func TestNewIntervalCompound(t *testing.T) {
+	tests := []struct {
+		name                         string
+		years, months, days, seconds int32
+		subseconds                   int64
+		precision                    types.TimePrecision
+		wantErr                      assert.ErrorAssertionFunc
+	}{
+		{"1-2 3 04:05:06.789", 1, 2, 3, 4*3600 + 5*60 + 6, 789, types.PrecisionMilliSeconds, assert.NoError},
+		{"nanoseconds", 0, 0, 1, 0, 999_999_999, types.PrecisionNanoSeconds, assert.NoError},
+		{"negative", -1, -2, -3, -4, -5, types.PrecisionMicroSeconds, assert.NoError},
+		{"only year month", 1, 2, 0, 0, 0, types.PrecisionSeconds, assert.NoError},
+		{"zero with precision", 0, 0, 0, 0, 0, types.PrecisionMicroSeconds, assert.NoError},
+		{"subseconds out of range", 0, 0, 0, 0, 1000, types.PrecisionMilliSeconds, assert.Error},
+		{"subseconds with second precision", 0, 0, 0, 0, 1, types.PrecisionSeconds, assert.Error},
+		{"invalid precision", 0, 0, 0, 0, 0, types.TimePrecision(10), assert.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewIntervalCompound(tt.years, tt.months, tt.days, tt.seconds, tt.subseconds, tt.precision)
+			if !tt.wantErr(t, err) {
+				return
+			}
+			if err != nil {
+				assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+				return
+			}
+
+			assert.Equal(t, types.NewIntervalCompoundType().WithPrecision(tt.precision).
+				WithNullability(types.NullabilityRequired), got.GetType())
+
+			protoLit := got.ToProtoLiteral()
+			assert.NotNil(t, protoLit.GetIntervalCompound())
+			assert.True(t, got.Equals(expr.LiteralFromProto(protoLit)))
+		})
+	}
+}
+
+func TestNewUserDefined(t *testing.T) {
+	pointID := extensions.ID{URI: extensions.SubstraitDefaultURIPrefix + "extension_types.yaml", Name: "point"}
+	extSet := extensions.NewSet()
+	reg := expr.NewExtensionRegistry(extSet, &extensions.DefaultCollection)
+	typeRef := types.UserDefinedType{
+		Nullability:   types.NullabilityRequired,
+		TypeReference: extSet.GetTypeAnchor(pointID),
+	}
+
+	payload, err := anypb.New(wrapperspb.Bytes([]byte{0x00, 0x01, 0x02}))
+	require.NoError(t, err)
+
+	params := []types.TypeParam{types.IntegerParameter(10), types.StringParameter("foo")}
+	lit, err := NewUserDefined(reg, typeRef, payload, params)
+	require.NoError(t, err)
+	assert.Equal(t, &types.UserDefinedType{
+		Nullability:    types.NullabilityRequired,
+		TypeReference:  typeRef.TypeReference,
+		TypeParameters: params,
+	}, lit.GetType())
+
+	protoLit := lit.ToProtoLiteral()
+	ud := protoLit.GetUserDefined()
+	require.NotNil(t, ud)
+	assert.Equal(t, typeRef.TypeReference, ud.TypeReference)
+	assert.Len(t, ud.TypeParameters, 2)
+	assert.True(t, pb.Equal(payload, ud.GetValue()))
+
+	roundTrip := expr.LiteralFromProto(protoLit)
+	assert.True(t, lit.Equals(roundTrip))
+	assert.True(t, pb.Equal(protoLit, roundTrip.ToProtoLiteral()))
+
+	anchor := roundTrip.GetType().(*types.UserDefinedType).TypeReference
+	id, ok := reg.DecodeType(anchor)
+	assert.True(t, ok)
+	assert.Equal(t, pointID, id)
+
+	unregistered := typeRef
+	unregistered.TypeReference = 42
+	_, err = NewUserDefined(reg, unregistered, payload, nil)
+	assert.ErrorIs(t, err, substraitgo.ErrNotFound)
+
+	_, err = NewUserDefined(reg, typeRef, nil, nil)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+}
+
+func TestAccessors(t *testing.T) {
+	mustLiteral := func(l expr.Literal, err error) expr.Literal {
+		require.NoError(t, err)
+		return l
+	}
+
+	i8 := mustLiteral(NewInt8(-8))
+	i32 := mustLiteral(NewInt32Nullable(32, true))
+	i64 := mustLiteral(NewInt64(math.MaxInt64))
+	fp32 := mustLiteral(NewFloat32(1.5))
+	fp64 := mustLiteral(NewFloat64(-2.25))
+	str := mustLiteral(NewString("foo"))
+	fixedChar := mustLiteral(NewFixedCharN("ab", 4))
+	varChar := mustLiteral(NewVarCharN("bar", 10))
+	boolean := mustLiteral(NewBool(true))
+	binary := mustLiteral(NewBinary([]byte{1, 2}))
+	fixedBinary := mustLiteral(NewFixedBinary([]byte{3}))
+	date := mustLiteral(NewDate(100))
+	null := mustLiteral(NewNull(&types.Int64Type{Nullability: types.NullabilityNullable}))
+
+	v, ok := AsInt64(i8)
+	assert.True(t, ok)
+	assert.Equal(t, int64(-8), v)
+	v, ok = AsInt64(i32)
+	assert.True(t, ok)
+	assert.Equal(t, int64(32), v)
+	v, ok = AsInt64(i64)
+	assert.True(t, ok)
+	assert.Equal(t, int64(math.MaxInt64), v)
+
+	f, ok := AsFloat64(fp32)
+	assert.True(t, ok)
+	assert.Equal(t, 1.5, f)
+	f, ok = AsFloat64(fp64)
+	assert.True(t, ok)
+	assert.Equal(t, -2.25, f)
+
+	s, ok := AsString(str)
+	assert.True(t, ok)
+	assert.Equal(t, "foo", s)
+	s, ok = AsString(fixedChar)
+	assert.True(t, ok)
+	assert.Equal(t, "ab  ", s)
+	s, ok = AsString(varChar)
+	assert.True(t, ok)
+	assert.Equal(t, "bar", s)
+
+	bv, ok := AsBool(boolean)
+	assert.True(t, ok)
+	assert.True(t, bv)
+
+	bin, ok := AsBinary(binary)
+	assert.True(t, ok)
+	assert.Equal(t, []byte{1, 2}, bin)
+	bin, ok = AsBinary(fixedBinary)
+	assert.True(t, ok)
+	assert.Equal(t, []byte{3}, bin)
+
+	d, ok := AsDate(date)
+	assert.True(t, ok)
+	assert.Equal(t, types.Date(100), d)
+
+	// null literals and literals of other types aren't extracted
+	for _, l := range []expr.Literal{null, str, date, nil} {
+		_, ok = AsInt64(l)
+		assert.False(t, ok, "AsInt64(%v)", l)
+	}
+	for _, l := range []expr.Literal{null, i64, fixedBinary} {
+		_, ok = AsString(l)
+		assert.False(t, ok, "AsString(%v)", l)
+	}
+	_, ok = AsFloat64(i32)
+	assert.False(t, ok)
+	_, ok = AsBool(mustLiteral(NewNull(&types.BooleanType{Nullability: types.NullabilityNullable})))
+	assert.False(t, ok)
+	_, ok = AsBinary(str)
+	assert.False(t, ok)
+	_, ok = AsDate(i32)
+	assert.False(t, ok)
+}
@@ -0,0 +1,202 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package plan
+
+import (
+	"fmt"
+
+	substraitgo "github.com/substrait-io/substrait-go"
+	"github.com/substrait-io/substrait-go/extensions"
+	"github.com/substrait-io/substrait-go/proto"
+	extensionspb "github.com/substrait-io/substrait-go/proto/extensions"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// AnchorAssigner chooses the anchors used for the extension URIs and
+// the declared functions, types and type variations of a plan when it
+// is serialized with ToProtoWithAnchors. Each method is called once for
+// every URI or declaration used by the plan. The anchors it returns must
+// be unique among the URIs and within each kind of declaration.
+type AnchorAssigner interface {
+	URIAnchor(uri string) uint32
+	FuncAnchor(id extensions.ID) uint32
+	TypeAnchor(id extensions.ID) uint32
+	TypeVariationAnchor(id extensions.ID) uint32
+}
+
+// ToProtoWithAnchors returns the protobuf representation of the plan
+// like ToProto, but with the anchors of the extension URIs and
+// declarations chosen by the assigner. Every reference to them within
+// the relations of the plan is updated to match. An error wrapping
+// substraitgo.ErrKeyExists is returned if the assigner gives the same
+// anchor to two different URIs or declarations of the same kind.
+func (p *Plan) ToProtoWithAnchors(assigner AnchorAssigner) (*proto.Plan, error) {
+	out, err := p.ToProto()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := assignAnchors(out, assigner); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// assignAnchors replaces the anchors of the extension URIs and
+// declarations of the plan, and every reference to them, with those
+// chosen by the assigner.
+func assignAnchors(out *proto.Plan, assigner AnchorAssigner) error {
+	uriAnchors, uris := anchorRemap{}, make(map[uint32]string)
+	for _, u := range out.ExtensionUris {
+		uris[u.ExtensionUriAnchor] = u.Uri
+		newAnchor := assigner.URIAnchor(u.Uri)
+		if err := uriAnchors.add("extension uri", u.Uri, u.ExtensionUriAnchor, newAnchor); err != nil {
+			return err
+		}
+		u.ExtensionUriAnchor = newAnchor
+	}
+
+	funcs, typs, typeVars := anchorRemap{}, anchorRemap{}, anchorRemap{}
+	for _, d := range out.Extensions {
+		switch m := d.MappingType.(type) {
+		case *extensionspb.SimpleExtensionDeclaration_ExtensionFunction_:
+			f := m.ExtensionFunction
+			id := extensions.ID{URI: uris[f.ExtensionUriReference], Name: f.Name}
+			newAnchor := assigner.FuncAnchor(id)
+			if err := funcs.add("function", id.Name, f.FunctionAnchor, newAnchor); err != nil {
+				return err
+			}
+			f.FunctionAnchor, f.ExtensionUriReference = newAnchor, uriAnchors.get(f.ExtensionUriReference)
+		case *extensionspb.SimpleExtensionDeclaration_ExtensionType_:
+			t := m.ExtensionType
+			id := extensions.ID{URI: uris[t.ExtensionUriReference], Name: t.Name}
+			newAnchor := assigner.TypeAnchor(id)
+			if err := typs.add("type", id.Name, t.TypeAnchor, newAnchor); err != nil {
+				return err
+			}
+			t.TypeAnchor, t.ExtensionUriReference = newAnchor, uriAnchors.get(t.ExtensionUriReference)
+		case *extensionspb.SimpleExtensionDeclaration_ExtensionTypeVariation_:
+			tv := m.ExtensionTypeVariation
+			id := extensions.ID{URI: uris[tv.ExtensionUriReference], Name: tv.Name}
+			newAnchor := assigner.TypeVariationAnchor(id)
+			if err := typeVars.add("type variation", id.Name, tv.TypeVariationAnchor, newAnchor); err != nil {
+				return err
+			}
+			tv.TypeVariationAnchor, tv.ExtensionUriReference = newAnchor, uriAnchors.get(tv.ExtensionUriReference)
+		}
+	}
+
+	remaps := [...]anchorRemap{funcAnchor: funcs, typeAnchor: typs, typeVariationAnchor: typeVars}
+	fields := make(map[protoreflect.Name]anchorRemap, len(anchorFields))
+	for name, kind := range anchorFields {
+		fields[name] = remaps[kind]
+	}
+	for _, r := range out.Relations {
+		remapAnchors(r.ProtoReflect(), fields)
+	}
+
+	return nil
+}
+
+type anchorKind int8
+
+const (
+	funcAnchor anchorKind = iota
+	typeAnchor
+	typeVariationAnchor
+)
+
+// anchorFields are the names of the fields of the relation protobuf
+// messages which refer to the anchor of an extension declaration, along
+// with the kind of declaration.
+var anchorFields = map[protoreflect.Name]anchorKind{
+	"function_reference":            funcAnchor,
+	"comparison_function_reference": funcAnchor,
+	"custom_function_reference":     funcAnchor,
+	"type_reference":                typeAnchor,
+	"user_defined_type_reference":   typeAnchor,
+	"type_variation_reference":      typeVariationAnchor,
+}
+
+// anchorRemap maps the anchors assigned when building a plan to those
+// chosen by an AnchorAssigner, and tracks which name each new anchor
+// was given to in order to detect duplicates.
+type anchorRemap struct {
+	anchors map[uint32]uint32
+	names   map[uint32]string
+}
+
+func (a *anchorRemap) add(kind, name string, old, anchor uint32) error {
+	if a.anchors == nil {
+		a.anchors, a.names = make(map[uint32]uint32), make(map[uint32]string)
+	}
+
+	if other, ok := a.names[anchor]; ok {
+		return fmt.Errorf("%w: %s anchor %d assigned to both %s and %s",
+			substraitgo.ErrKeyExists, kind, anchor, other, name)
+	}
+	a.anchors[old], a.names[anchor] = anchor, name
+	return nil
+}
+
+func (a *anchorRemap) get(old uint32) uint32 {
+	if anchor, ok := a.anchors[old]; ok {
+		return anchor
+	}
+	return old
+}
+
+// remapAnchors replaces the anchors in every field of msg,
+// and the messages nested within it, whose name is in fields.
+func remapAnchors(msg protoreflect.Message, fields map[protoreflect.Name]anchorRemap) {
+	rangeAnchors(msg, func(name protoreflect.Name, anchor uint32) (uint32, bool) {
+		m, ok := fields[name]
+		if !ok {
+			return anchor, false
+		}
+		return m.get(anchor), true
+	})
+}
+
+// rangeAnchors calls fn with the name and value of every uint32 field of
+// msg and the messages nested within it. If fn returns true, the value
+// of the field is replaced by the anchor it returns.
+//
+// A field holding anchor 0 isn't populated in proto3, so each uint32
+// field is visited whenever its message is present, not only when it is
+// populated, unless it's a member of a oneof which isn't set.
+func rangeAnchors(msg protoreflect.Message, fn func(name protoreflect.Name, anchor uint32) (uint32, bool)) {
+	fields := msg.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		switch {
+		case fd.IsList():
+			if fd.Message() == nil || !msg.Has(fd) {
+				break
+			}
+			l := msg.Get(fd).List()
+			for i := 0; i < l.Len(); i++ {
+				rangeAnchors(l.Get(i).Message(), fn)
+			}
+		case fd.IsMap():
+			if fd.MapValue().Message() == nil || !msg.Has(fd) {
+				break
+			}
+			msg.Get(fd).Map().Range(func(_ protoreflect.MapKey, v protoreflect.Value) bool {
+				rangeAnchors(v.Message(), fn)
+				return true
+			})
+		case fd.Message() != nil:
+			if msg.Has(fd) {
+				rangeAnchors(msg.Get(fd).Message(), fn)
+			}
+		case fd.Kind() == protoreflect.Uint32Kind:
+			if fd.HasPresence() && !msg.Has(fd) {
+				break
+			}
+			if anchor, ok := fn(fd.Name(), uint32(msg.Get(fd).Uint())); ok {
+				msg.Set(fd, protoreflect.ValueOfUint32(anchor))
+			}
+		}
+	}
+}
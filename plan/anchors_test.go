@@ -0,0 +1,173 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package plan_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	substraitgo "github.com/substrait-io/substrait-go"
+	"github.com/substrait-io/substrait-go/extensions"
+	"github.com/substrait-io/substrait-go/plan"
+	"google.golang.org/protobuf/proto"
+)
+
+const arithmeticURI = extensions.SubstraitDefaultURIPrefix + "functions_arithmetic.yaml"
+
+func buildArithmeticPlan(t *testing.T) *plan.Plan {
+	b := plan.NewBuilderDefault()
+	scan := b.NamedScan([]string{"test"}, baseSchema)
+	ref, err := b.RootFieldRef(scan, 1)
+	require.NoError(t, err)
+
+	abs, err := b.ScalarFn(arithmeticURI, "abs", nil, ref)
+	require.NoError(t, err)
+	add, err := b.ScalarFn(arithmeticURI, "add", nil, abs, ref)
+	require.NoError(t, err)
+	mul, err := b.ScalarFn(arithmeticURI, "multiply", nil, add, ref)
+	require.NoError(t, err)
+	sub, err := b.ScalarFn(arithmeticURI, "subtract", nil, mul, abs)
+	require.NoError(t, err)
+
+	project, err := b.Project(scan, add, mul, sub)
+	require.NoError(t, err)
+
+	p, err := b.Plan(project, []string{"a", "b", "c", "d", "e"})
+	require.NoError(t, err)
+	return p
+}
+
+func TestToProtoDeterministic(t *testing.T) {
+	p := buildArithmeticPlan(t)
+
+	marshal := func() []byte {
+		out, err := p.ToProto()
+		require.NoError(t, err)
+		data, err := proto.MarshalOptions{Deterministic: true}.Marshal(out)
+		require.NoError(t, err)
+		return data
+	}
+
+	expected := marshal()
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, expected, marshal())
+	}
+
+	out, err := p.ToProto()
+	require.NoError(t, err)
+	var anchors []uint32
+	for _, d := range out.Extensions {
+		anchors = append(anchors, d.GetExtensionFunction().FunctionAnchor)
+	}
+	assert.Equal(t, []uint32{1, 2, 3, 4}, anchors)
+}
+
+type nameAnchors map[string]uint32
+
+func (n nameAnchors) URIAnchor(uri string) uint32 { return n[uri] }
+
+func (n nameAnchors) FuncAnchor(id extensions.ID) uint32 { return n[id.Name] }
+
+func (n nameAnchors) TypeAnchor(id extensions.ID) uint32 { return n[id.Name] }
+
+func (n nameAnchors) TypeVariationAnchor(id extensions.ID) uint32 { return n[id.Name] }
+
+func TestToProtoWithAnchors(t *testing.T) {
+	p := buildArithmeticPlan(t)
+
+	assigner := nameAnchors{
+		arithmeticURI:        7,
+		"abs:fp32":           40,
+		"add:fp32_fp32":      10,
+		"multiply:fp32_fp32": 30,
+		"subtract:fp32_fp32": 20,
+	}
+
+	out, err := p.ToProtoWithAnchors(assigner)
+	require.NoError(t, err)
+
+	require.Len(t, out.ExtensionUris, 1)
+	assert.EqualValues(t, 7, out.ExtensionUris[0].ExtensionUriAnchor)
+	require.Len(t, out.Extensions, 4)
+	for _, d := range out.Extensions {
+		fn := d.GetExtensionFunction()
+		assert.Equal(t, assigner[fn.Name], fn.FunctionAnchor, fn.Name)
+		assert.EqualValues(t, 7, fn.ExtensionUriReference)
+	}
+
+	exprs := out.Relations[0].GetRoot().Input.GetProject().Expressions
+	require.Len(t, exprs, 3)
+	add := exprs[0].GetScalarFunction()
+	assert.EqualValues(t, 10, add.FunctionReference)
+	assert.EqualValues(t, 40, add.Arguments[0].GetValue().GetScalarFunction().FunctionReference)
+	assert.EqualValues(t, 30, exprs[1].GetScalarFunction().FunctionReference)
+	assert.EqualValues(t, 20, exprs[2].GetScalarFunction().FunctionReference)
+
+	// the plan read back resolves the functions through the new anchors
+	roundTrip, err := plan.FromProto(out, &extensions.DefaultCollection)
+	require.NoError(t, err)
+	require.NoError(t, roundTrip.Validate())
+	again, err := roundTrip.ToProto()
+	require.NoError(t, err)
+	assert.Truef(t, proto.Equal(out.Relations[0], again.Relations[0]),
+		"expected: %s\ngot: %s", out.Relations[0], again.Relations[0])
+
+	// the plan itself keeps its original anchors
+	orig, err := p.ToProto()
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, orig.ExtensionUris[0].ExtensionUriAnchor)
+
+	assigner["subtract:fp32_fp32"] = 10
+	_, err = p.ToProtoWithAnchors(assigner)
+	assert.ErrorIs(t, err, substraitgo.ErrKeyExists)
+	assert.ErrorContains(t, err, "function anchor 10 assigned to both")
+}
+
+func TestToProtoWithAnchorZero(t *testing.T) {
+	p := buildArithmeticPlan(t)
+
+	// a reference to function anchor 0 isn't populated in proto3
+	zero, err := p.ToProtoWithAnchors(nameAnchors{
+		arithmeticURI:        0,
+		"abs:fp32":           1,
+		"add:fp32_fp32":      0,
+		"multiply:fp32_fp32": 2,
+		"subtract:fp32_fp32": 3,
+	})
+	require.NoError(t, err)
+	exprs := zero.Relations[0].GetRoot().Input.GetProject().Expressions
+	assert.EqualValues(t, 0, exprs[0].GetScalarFunction().FunctionReference)
+
+	withZero, err := plan.FromProto(zero, &extensions.DefaultCollection)
+	require.NoError(t, err)
+
+	assigner := nameAnchors{
+		arithmeticURI:        7,
+		"abs:fp32":           40,
+		"add:fp32_fp32":      10,
+		"multiply:fp32_fp32": 30,
+		"subtract:fp32_fp32": 20,
+	}
+	out, err := withZero.ToProtoWithAnchors(assigner)
+	require.NoError(t, err)
+
+	for _, d := range out.Extensions {
+		fn := d.GetExtensionFunction()
+		assert.Equal(t, assigner[fn.Name], fn.FunctionAnchor, fn.Name)
+		assert.EqualValues(t, 7, fn.ExtensionUriReference)
+	}
+
+	exprs = out.Relations[0].GetRoot().Input.GetProject().Expressions
+	require.Len(t, exprs, 3)
+	add := exprs[0].GetScalarFunction()
+	assert.EqualValues(t, 10, add.FunctionReference)
+	assert.EqualValues(t, 40, add.Arguments[0].GetValue().GetScalarFunction().FunctionReference)
+	assert.EqualValues(t, 30, exprs[1].GetScalarFunction().FunctionReference)
+	assert.EqualValues(t, 20, exprs[2].GetScalarFunction().FunctionReference)
+
+	expected, err := p.ToProtoWithAnchors(assigner)
+	require.NoError(t, err)
+	assert.Truef(t, proto.Equal(expected.Relations[0], out.Relations[0]),
+		"expected: %s\ngot: %s", expected.Relations[0], out.Relations[0])
+}
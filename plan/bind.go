@@ -0,0 +1,204 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package plan
+
+import (
+	"fmt"
+
+	substraitgo "github.com/substrait-io/substrait-go"
+	"github.com/substrait-io/substrait-go/expr"
+	"github.com/substrait-io/substrait-go/proto"
+	"github.com/substrait-io/substrait-go/types"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// BindParameters returns a copy of the plan with each expr.Parameter in
+// the expressions of its relations replaced by the literal in values at
+// the index of the parameter. This includes the arguments and filters
+// of the measures of an AggregateRel and the expressions within the
+// relations of subqueries. The plan itself is unchanged.
+//
+// An error wrapping substraitgo.ErrInvalidArg is returned if there is no
+// value for the index of a parameter, or if the type of the value
+// differs from that of the parameter other than by nullability. A null
+// value can only be bound to a nullable parameter. It is also returned
+// if a parameter is left in the plan after binding, such as one within
+// an expression which can't be rewritten.
+func BindParameters(p *Plan, values []expr.Literal) (*Plan, error) {
+	bind := func(e expr.Expression) (expr.Expression, error) {
+		return bindExpr(e, values)
+	}
+
+	out, err := rewritePlan(p, func(root Rel) (Rel, error) {
+		return bindRel(root, bind)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range out.relations {
+		if err := checkBound(r.ToProto()); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func bindRel(rel Rel, bind RewriteFunc) (Rel, error) {
+	return Transform(rel, func(r Rel) (Rel, error) {
+		return r.CopyWithExpressionRewrite(bind, r.GetInputs()...)
+	})
+}
+
+func bindExpr(e expr.Expression, values []expr.Literal) (expr.Expression, error) {
+	if e == nil {
+		return nil, nil
+	}
+
+	var (
+		err   error
+		visit expr.VisitFunc
+	)
+	bind := func(e expr.Expression) (expr.Expression, error) {
+		return bindExpr(e, values)
+	}
+	visit = func(e expr.Expression) expr.Expression {
+		if err != nil || e == nil {
+			return e
+		}
+
+		switch ex := e.(type) {
+		case *expr.Parameter:
+			value, bindErr := bindParameter(ex, values)
+			if bindErr != nil {
+				err = bindErr
+				return e
+			}
+			return value
+		case *expr.InPredicate:
+			// the haystack isn't visited along with the needles
+			out := ex.Visit(visit).(*expr.InPredicate)
+			haystack, ok := ex.Haystack.(Rel)
+			if !ok || err != nil {
+				return out
+			}
+			var bound Rel
+			if bound, err = bindRel(haystack, bind); err != nil || bound == haystack {
+				return out
+			}
+			return &expr.InPredicate{Needles: out.Needles, Haystack: bound}
+		case *expr.SetPredicate:
+			tuples, ok := ex.Tuples.(Rel)
+			if !ok {
+				return e
+			}
+			var bound Rel
+			if bound, err = bindRel(tuples, bind); err != nil || bound == tuples {
+				return e
+			}
+			return &expr.SetPredicate{Op: ex.Op, Tuples: bound}
+		}
+		return e.Visit(visit)
+	}
+
+	out := visit(e)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// checkBound returns an error wrapping substraitgo.ErrInvalidArg if the
+// protobuf representation of a relation contains a parameter.
+func checkBound(rel *proto.PlanRel) error {
+	if param, ok := findParameter(rel.ProtoReflect()); ok {
+		return fmt.Errorf("%w: parameter %d of type %s is not bound",
+			substraitgo.ErrInvalidArg, param.Index, param.Type)
+	}
+	return nil
+}
+
+// findParameter returns the first parameter within msg or the messages
+// nested within it, which is serialized as a literal recognized by
+// expr.ParameterFromProto.
+func findParameter(msg protoreflect.Message) (param *expr.Parameter, found bool) {
+	rangeMessages(msg, func(m protoreflect.Message) bool {
+		if lit, ok := m.Interface().(*proto.Expression_Literal); ok {
+			param, found = expr.ParameterFromProto(lit)
+		}
+		return !found
+	})
+	return
+}
+
+// rangeMessages calls fn with msg and each message nested within it,
+// depth first and in the order of the fields of each message, until fn
+// returns false. It reports whether the traversal completed.
+func rangeMessages(msg protoreflect.Message, fn func(protoreflect.Message) bool) bool {
+	if !fn(msg) {
+		return false
+	}
+
+	fields := msg.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if !msg.Has(fd) {
+			continue
+		}
+
+		v := msg.Get(fd)
+		switch {
+		case fd.IsList():
+			if fd.Message() == nil {
+				continue
+			}
+			l := v.List()
+			for j := 0; j < l.Len(); j++ {
+				if !rangeMessages(l.Get(j).Message(), fn) {
+					return false
+				}
+			}
+		case fd.IsMap():
+			if fd.MapValue().Message() == nil {
+				continue
+			}
+			ok := true
+			v.Map().Range(func(_ protoreflect.MapKey, v protoreflect.Value) bool {
+				ok = rangeMessages(v.Message(), fn)
+				return ok
+			})
+			if !ok {
+				return false
+			}
+		case fd.Message() != nil:
+			if !rangeMessages(v.Message(), fn) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func bindParameter(param *expr.Parameter, values []expr.Literal) (expr.Literal, error) {
+	if int(param.Index) >= len(values) {
+		return nil, fmt.Errorf("%w: no value for parameter %d, got %d values",
+			substraitgo.ErrInvalidArg, param.Index, len(values))
+	}
+
+	value := values[param.Index]
+	if value == nil {
+		return nil, fmt.Errorf("%w: value for parameter %d is nil",
+			substraitgo.ErrInvalidArg, param.Index)
+	}
+
+	if !types.EqualsIgnoreNullability(value.GetType(), param.Type) {
+		return nil, fmt.Errorf("%w: cannot bind value of type %s to parameter %d of type %s",
+			substraitgo.ErrInvalidArg, value.GetType(), param.Index, param.Type)
+	}
+
+	if value.IsNull() && param.Type.GetNullability() != types.NullabilityNullable {
+		return nil, fmt.Errorf("%w: cannot bind null to non-nullable parameter %d of type %s",
+			substraitgo.ErrInvalidArg, param.Index, param.Type)
+	}
+	return value, nil
+}
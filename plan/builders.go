@@ -3,13 +3,17 @@
 package plan
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 
 	substraitgo "github.com/substrait-io/substrait-go"
 	"github.com/substrait-io/substrait-go/expr"
 	"github.com/substrait-io/substrait-go/extensions"
+	"github.com/substrait-io/substrait-go/proto"
 	"github.com/substrait-io/substrait-go/types"
 	"golang.org/x/exp/slices"
+	"google.golang.org/protobuf/types/known/anypb"
 )
 
 // Builder is the base object for constructing the various elements of a plan.
@@ -21,6 +25,12 @@ import (
 // constructed will have the appropriate extension anchors and definitions.
 // This will maintain consistency across the plan for the user without them
 // having to manually do so.
+//
+// A Builder is not safe for concurrent use, as building relations and
+// expressions updates its extension set. To construct plans from several
+// goroutines, create a builder for each of them with NewBuilder or
+// NewBuilderDefault, which is cheap: builders of the same collection
+// only share the collection, which they don't modify.
 type Builder interface {
 	// GetFunctionRef retrieves the function anchor reference for a given
 	// function identified by its namespace and function name. This also
@@ -32,39 +42,126 @@ type Builder interface {
 	// along with optional type parameters. It will add the type to the internal
 	// extension set if it doesn't already exist and assign it a type reference.
 	UserDefinedType(nameSpace, typeName string, params ...types.TypeParam) types.UserDefinedType
+	// ResolveUserDefinedType is like UserDefinedType, but checks that the
+	// type is declared by the collection of the builder, returning an
+	// error wrapping substraitgo.ErrNotFound if it isn't, and that the
+	// number of parameters matches its declaration, returning an error
+	// wrapping substraitgo.ErrInvalidArg if it doesn't.
+	ResolveUserDefinedType(nameSpace, typeName string, params []types.TypeParam, nullability types.Nullability) (*types.UserDefinedType, error)
+	// TypeVariation returns the reference of the type variation with the
+	// given name from the extension namespace, such as an unsigned
+	// variation of i32, to be used as the TypeVariationRef of a type or of
+	// the type of a literal. It will add the variation to the internal
+	// extension set if it doesn't already exist. An error wrapping
+	// substraitgo.ErrNotFound is returned if the collection of the builder
+	// doesn't declare the variation.
+	TypeVariation(nameSpace, name string) (uint32, error)
 	// RootFieldRef constructs a Root Field Reference to the column of the input
 	// relation indicated by the passed in index. This will ensure the output
 	// type is properly propagated based on the reference.
 	//
 	// Will return an error if the index is < 0 or > the number of output fields.
 	RootFieldRef(input Rel, index int32) (*expr.FieldReference, error)
+	// NestedFieldRef constructs a Root Field Reference to a nested field
+	// within the output of the input relation, such as a field of a struct
+	// column or an element of a list column. The segments are chained in
+	// order, with the first segment applying to the output record of the
+	// input relation (and thus typically being a StructFieldRef). The
+	// type of the reference is computed by walking the schema. The segments
+	// passed in are not modified, any existing children are ignored.
+	//
+	// Will return an error if a struct field index is out of range or if a
+	// segment is applied to a type it cannot reference, such as a list
+	// element of a non-list type.
+	NestedFieldRef(input Rel, segments []expr.ReferenceSegment) (*expr.FieldReference, error)
+	// OuterFieldRef constructs a field reference to the column indicated
+	// by field of the output of the enclosing relation outer, for use
+	// within a correlated subquery. steps is the number of subquery
+	// boundaries to step out of to reach outer. The type of the reference
+	// is the type of the column, so it can be used like any other field
+	// reference when building the subquery.
+	//
+	// Only steps and field are recorded in the reference, outer is taken
+	// in addition to them so that the field can be checked and its type
+	// known, which the inner schema of the subquery can't provide. Use
+	// expr.NewOuterFieldRef with a nil schema for a reference recording
+	// just steps and field, whose type is unknown and which therefore
+	// can't be used as a condition or as the argument of a function.
+	//
+	// Will return an error if outer is nil, if steps is <= 0 or if field
+	// is out of range for the output of outer.
+	OuterFieldRef(outer Rel, steps int32, field int32) (*expr.FieldReference, error)
 	// JoinedRecordFieldRef constructs a root field reference for the full tuple of
 	// the inputs to a join, to construct an expression that is viable to use as
 	// the condition or post join filter for a join relation.
 	JoinedRecordFieldRef(left, right Rel, index int32) (*expr.FieldReference, error)
+	// EquiJoinCondition constructs a join condition comparing each of the
+	// leftKeys columns of left to the corresponding rightKeys column of
+	// right with the "equal" function of the default comparison
+	// extension, combined with the "and" function of the default boolean
+	// extension if there are several keys. The right key indices refer to
+	// the columns of right and are offset by the number of columns of
+	// left when referencing the joined record.
+	//
+	// An error will be returned if the key lists are empty or of
+	// different lengths, if a key is out of range for its relation, or
+	// if the types of paired columns differ other than by nullability.
+	EquiJoinCondition(left, right Rel, leftKeys, rightKeys []int32) (expr.Expression, error)
 	// ScalarFn constructs a ScalarFunction from the passed in namespace and
 	// function name key. This is equivalent to calling expr.NewScalarFunc using
 	// the builder's extension registry. An error will be returned if the indicated
 	// function was not already in the extension collection the builder was created
-	// with or if the arguments of the function don't match the provided argument
-	// types.
+	// with, if the arguments of the function don't match the provided argument
+	// types, or if the options or enum arguments have values other than the
+	// ones declared by the function.
 	ScalarFn(nameSpace, key string, opts []*types.FunctionOption, args ...types.FuncArg) (*expr.ScalarFunction, error)
 	// AggregateFn constructs an AggregateFunction from the passed in namespace and
 	// function name key. This is equivalent to calling expr.NewAggregateFunc using
 	// the builder's extension registry. An error will be returned if the indicated
 	// function was not already in the extension collection the builder was created
-	// with or if the arguments of the function don't match the provided argument
-	// types.
+	// with, if the arguments of the function don't match the provided argument
+	// types, or if the options or enum arguments have values other than the
+	// ones declared by the function.
 	AggregateFn(nameSpace, key string, opts []*types.FunctionOption, args ...types.FuncArg) (*expr.AggregateFunction, error)
+	// InList constructs a boolean expression testing whether value is equal
+	// to any of the options, as in the SQL "value IN (options...)", for use
+	// as the condition of a Filter. This is equivalent to calling
+	// expr.NewSingularOrList.
+	InList(value expr.Expression, options ...expr.Expression) (*expr.SingularOrList, error)
 	// SortFields is a convenience method to construct a list of sort fields
 	// from the column indices of an existing relation. This will return an error
 	// if any of the indices are < 0 or > the number of columns in the output
 	// of the relation. This will use types.SortAscNullsLast as the sort kind
 	// for each field in the returned slice.
 	SortFields(input Rel, indices ...int32) ([]expr.SortField, error)
+	// ComparisonSortField returns a sort field ordering the records by e
+	// with a custom comparison function, such as for a collation
+	// sensitive sort, in place of a sort direction. The function is
+	// resolved like ScalarFn for two arguments of the type of e, and is
+	// referred to by its anchor, the comparison_function_reference of the
+	// protobuf sort field.
+	ComparisonSortField(e expr.Expression, nameSpace, key string) (expr.SortField, error)
 	// Measure is a convenience method to construct the input for an Aggregate Rel
 	// Consisting of the provided aggregate function and optional filter expression.
+	// The arguments of the function and the filter refer to the fields of
+	// the input of the AggregateRel, including any used for grouping, not
+	// to its output. They are checked against the input when the
+	// AggregateRel is built, the filter must yield a boolean.
 	Measure(measure *expr.AggregateFunction, filter expr.Expression) AggRelMeasure
+	// MeasureWithOptions is the same as Measure, only the aggregate
+	// function is additionally given an invocation, to aggregate either
+	// all or only the distinct values of its input (e.g. COUNT(DISTINCT x)),
+	// and optional sort fields to order its input by before aggregating
+	// (e.g. string_agg(x ORDER BY y)). The sort fields are validated
+	// against the input of the aggregate relation it is used with.
+	MeasureWithOptions(measure *expr.AggregateFunction, filter expr.Expression, invocation types.AggregationInvocation, sorts ...expr.SortField) AggRelMeasure
+	// MeasureWithPhase is the same as Measure, only the aggregate function
+	// is computed for the given phase of a multi-stage aggregation. The
+	// phases which produce intermediate results output the intermediate
+	// type of the function rather than its return type, which is reflected
+	// in the output type of the aggregate relation (see
+	// expr.AggregateFunction.WithPhase).
+	MeasureWithPhase(measure *expr.AggregateFunction, filter expr.Expression, phase types.AggregationPhase) (AggRelMeasure, error)
 
 	// The Remap variant for each method produces that type of relation
 	// with an optional output mapping to reorder or exclude specific columns
@@ -72,51 +169,247 @@ type Builder interface {
 
 	Project(input Rel, exprs ...expr.Expression) (*ProjectRel, error)
 	ProjectRemap(input Rel, remap []int32, exprs ...expr.Expression) (*ProjectRel, error)
+	// AggregateColumns groups the input by the columns with the given
+	// indices and computes the measures for each group, producing the
+	// grouping columns followed by the measures. Either the measures or
+	// the grouping columns may be empty, but not both; grouping without
+	// measures produces the distinct values of the grouping columns.
 	AggregateColumnsRemap(input Rel, remap []int32, measures []AggRelMeasure, groupByCols ...int32) (*AggregateRel, error)
 	AggregateColumns(input Rel, measures []AggRelMeasure, groupByCols ...int32) (*AggregateRel, error)
 	AggregateExprsRemap(input Rel, remap []int32, measures []AggRelMeasure, groups ...[]expr.Expression) (*AggregateRel, error)
 	AggregateExprs(input Rel, measures []AggRelMeasure, groups ...[]expr.Expression) (*AggregateRel, error)
+	// AggregateGroupingSets produces an aggregate with a grouping for each
+	// of the grouping sets, as for GROUPING SETS, ROLLUP or CUBE. A set
+	// may be empty to aggregate over all records. The output consists of
+	// a column for each distinct grouping expression across all of the
+	// sets, in the order they first appear, followed by the measures. A
+	// grouping column which is missing from some of the sets is nullable,
+	// and with more than one set the output ends with an i32 column
+	// holding the index of the grouping set of each record (see
+	// AggregateRel.RecordType).
+	AggregateGroupingSets(input Rel, sets [][]expr.Expression, measures []AggRelMeasure) (*AggregateRel, error)
+	// AggregateGroupBy produces an aggregate with a single grouping of
+	// the given expressions, which may be computed from the input such as
+	// by calling a scalar function, as for GROUP BY. Each expression is
+	// validated against the output of the input. The output consists of a
+	// column for each distinct grouping expression, in order, followed by
+	// the measures. Without any groupings the measures are computed over
+	// all records.
+	AggregateGroupBy(input Rel, groupings []expr.Expression, measures []AggRelMeasure) (*AggregateRel, error)
+	AggregateGroupingSetsRemap(input Rel, remap []int32, sets [][]expr.Expression, measures []AggRelMeasure) (*AggregateRel, error)
 	CrossRemap(left, right Rel, remap []int32) (*CrossRel, error)
 	Cross(left, right Rel) (*CrossRel, error)
-	FetchRemap(input Rel, offset, count uint64, remap []int32) (*FetchRel, error)
-	Fetch(input Rel, offset, count uint64) (*FetchRel, error)
+	// Fetch produces a relation which skips the first offset records of
+	// the input and returns the following count records. A count of
+	// FetchCountAll returns all of the remaining records. The offset must
+	// not be negative, nor the count apart from FetchCountAll.
+	Fetch(input Rel, offset, count int64) (*FetchRel, error)
+	FetchRemap(input Rel, offset, count int64, remap []int32) (*FetchRel, error)
+	// Filter produces a relation with the records of the input for which
+	// the condition is true. The condition must yield a boolean, which
+	// may be nullable: following three-valued logic, a record for which
+	// the condition is null is dropped, just like one for which it is
+	// false.
 	FilterRemap(input Rel, condition expr.Expression, remap []int32) (*FilterRel, error)
 	Filter(input Rel, condition expr.Expression) (*FilterRel, error)
+	// JoinAndFilter produces a join of the left and right inputs on the
+	// condition, whose output is then filtered by the optional post join
+	// filter. Both must yield a boolean, which may be nullable, and a
+	// null result is treated as false like for Filter, so a pair of
+	// records for which the condition is null doesn't match.
 	JoinAndFilterRemap(left, right Rel, condition, postJoinFilter expr.Expression, joinType JoinType, remap []int32) (*JoinRel, error)
 	JoinAndFilter(left, right Rel, condition, postJoinFilter expr.Expression, joinType JoinType) (*JoinRel, error)
 	JoinRemap(left, right Rel, condition expr.Expression, joinType JoinType, remap []int32) (*JoinRel, error)
+
+	// HashJoin produces a physical join which builds a hash table of the
+	// right input on the right keys and probes it with the left keys of
+	// each record of the left input. The keys must be root field
+	// references into the output of their input, and there must be the
+	// same number of left and right keys with the keys at the same
+	// position having the same type, ignoring nullability. The output
+	// record type depends on the join type in the same way as Join.
+	HashJoin(left, right Rel, leftKeys, rightKeys []expr.Expression, joinType JoinType) (*HashJoinRel, error)
+	// MergeJoin produces a physical join of two inputs which are sorted
+	// on their keys, the keys are validated in the same way as HashJoin.
+	MergeJoin(left, right Rel, leftKeys, rightKeys []expr.Expression, joinType JoinType) (*MergeJoinRel, error)
+	// NestedLoopJoin produces a physical join which evaluates the
+	// expression for every pair of records from the left and right
+	// inputs. The expression refers to the concatenation of the fields of
+	// both inputs and must yield a boolean. A nil expression produces a
+	// cartesian join.
+	NestedLoopJoin(left, right Rel, expression expr.Expression, joinType JoinType) (*NestedLoopJoinRel, error)
 	Join(left, right Rel, condition expr.Expression, joinType JoinType) (*JoinRel, error)
 	NamedScanRemap(tableName []string, schema types.NamedStruct, remap []int32) (*NamedTableReadRel, error)
 	NamedScan(tableName []string, schema types.NamedStruct) *NamedTableReadRel
+	// NamedScanSelect produces a named scan with the output mapping
+	// selecting the columns of the schema with the names in selectNames,
+	// in that order. An error is returned if a name doesn't match any of
+	// the top-level names of the schema or matches more than one.
+	NamedScanSelect(tableName []string, schema types.NamedStruct, selectNames []string) (*NamedTableReadRel, error)
+	// NamedScanWithFilter produces a named scan with the filter pushed
+	// down into the read relation. The filter is evaluated against the
+	// provided schema and must yield a boolean.
+	NamedScanWithFilter(tableName []string, schema types.NamedStruct, filter expr.Expression) (*NamedTableReadRel, error)
+	// NamedScanWithFilters produces a named scan with both an exact and a
+	// best effort filter pushed down into the read relation, for engines
+	// which can only partially apply a predicate while reading. Records
+	// not matching exact are never returned, while those not matching
+	// bestEffort may be, so it must be re-applied if needed. Either may be
+	// nil, but not both, and each must yield a boolean when evaluated
+	// against the provided schema.
+	NamedScanWithFilters(tableName []string, schema types.NamedStruct, exact, bestEffort expr.Expression) (*NamedTableReadRel, error)
+	// NamedScanWithOptions produces a named scan with the filters and
+	// projection in opts pushed down into the read relation.
+	NamedScanWithOptions(tableName []string, schema types.NamedStruct, opts ScanOptions) (*NamedTableReadRel, error)
+	NamedScanWithOptionsRemap(tableName []string, schema types.NamedStruct, opts ScanOptions, remap []int32) (*NamedTableReadRel, error)
+	// NamedScanWithAdvancedExtension produces a named scan carrying ext,
+	// such as catalog specific details like the snapshot of the table to
+	// read, as the enhancement of the advanced extension of the read
+	// relation. The payload isn't interpreted, it's only preserved when
+	// the plan is serialized. A nil ext produces a plain named scan.
+	NamedScanWithAdvancedExtension(tableName []string, schema types.NamedStruct, ext *anypb.Any) *NamedTableReadRel
 	VirtualTableRemap(fields []string, remap []int32, values ...expr.StructLiteralValue) (*VirtualTableReadRel, error)
 	VirtualTable(fields []string, values ...expr.StructLiteralValue) (*VirtualTableReadRel, error)
+	// VirtualTableScanFunc produces a virtual table with the given schema
+	// whose rowCount rows are produced by gen, which is called once with
+	// the index of each row, rather than building all of the rows up
+	// front. Each row is checked against the schema as it's generated and
+	// retained, so that a table which was constructed can always be
+	// serialized, and gen isn't called again by ToProto. The literals of
+	// a nullable field may be of the required type.
+	VirtualTableScanFunc(schema types.NamedStruct, rowCount int, gen func(i int) ([]expr.Literal, error)) (*VirtualTableReadRel, error)
 	SortRemap(input Rel, remap []int32, sorts ...expr.SortField) (*SortRel, error)
 	Sort(input Rel, sorts ...expr.SortField) (*SortRel, error)
 	SetRemap(op SetOp, remap []int32, inputs ...Rel) (*SetRel, error)
 	Set(op SetOp, inputs ...Rel) (*SetRel, error)
 
+	// NamedWrite produces a relation which performs the given operation
+	// on the named table with the records of the input, which must match
+	// the field types of the table schema. The write produces no output
+	// records, use NamedWriteWithOutput to return the modified records.
+	NamedWrite(input Rel, tableName []string, schema types.NamedStruct, op WriteOp) (*NamedTableWriteRel, error)
+	NamedWriteWithOutput(input Rel, tableName []string, schema types.NamedStruct, op WriteOp, output WriteOutputMode) (*NamedTableWriteRel, error)
+
+	// Ddl produces a relation which performs the given schema operation,
+	// such as create or drop, on the named table or view. The schema is
+	// required and describes the object after the operation. Use
+	// DdlView to provide the relation defining a view.
+	Ddl(op DdlOp, object DdlObject, schema types.NamedStruct, tableNames []string) (*DdlRel, error)
+	// DdlView is like Ddl for a view, which is defined by the given
+	// relation. The output of the definition must match the field types
+	// of the schema. The definition may be nil, such as to drop the view.
+	DdlView(op DdlOp, schema types.NamedStruct, viewNames []string, definition Rel) (*DdlRel, error)
+
+	// Exchange produces a relation which redistributes the records of the
+	// input into partitionCount partitions according to the scheme. Field
+	// indices and expressions of the scheme refer to the output of the
+	// input relation.
+	Exchange(input Rel, partitionCount int32, scheme ExchangeScheme) (*ExchangeRel, error)
+	ExchangeRemap(input Rel, partitionCount int32, scheme ExchangeScheme, remap []int32) (*ExchangeRel, error)
+
+	// Expand produces a relation which emits a duplicate of each input
+	// record for every alternative of the switching fields, appending an
+	// i64 column identifying the duplicate. All switching fields must
+	// have the same number of alternatives, each of the same type.
+	Expand(input Rel, fields []ExpandField) (*ExpandRel, error)
+	ExpandRemap(input Rel, fields []ExpandField, remap []int32) (*ExpandRel, error)
+
+	// The extension relations represent custom relational operators whose
+	// behavior is defined by the opaque detail message. Since the detail
+	// can't be inspected, the output schema of the relation must be
+	// provided. The schema is only used for building the plan and type
+	// checking, it is not part of the serialized relation.
+
+	ExtensionLeafRemap(detail *anypb.Any, schema types.NamedStruct, remap []int32) (*ExtensionLeafRel, error)
+	ExtensionLeaf(detail *anypb.Any, schema types.NamedStruct) (*ExtensionLeafRel, error)
+	ExtensionSingleRemap(input Rel, detail *anypb.Any, schema types.NamedStruct, remap []int32) (*ExtensionSingleRel, error)
+	ExtensionSingle(input Rel, detail *anypb.Any, schema types.NamedStruct) (*ExtensionSingleRel, error)
+	ExtensionMultiRemap(inputs []Rel, detail *anypb.Any, schema types.NamedStruct, remap []int32) (*ExtensionMultiRel, error)
+	ExtensionMulti(inputs []Rel, detail *anypb.Any, schema types.NamedStruct) (*ExtensionMultiRel, error)
+
+	// SetProducer, SetGitHash and SetVersion override the corresponding
+	// fields of CurrentVersion in the version recorded by the plans
+	// constructed afterwards, such as to identify the application
+	// producing the plans.
+	SetProducer(producer string)
+	SetGitHash(gitHash string)
+	SetVersion(major, minor, patch uint32)
+
+	// SetKeepIdentityMappings controls whether the relations constructed
+	// afterwards keep an output mapping which selects each of their
+	// columns in order, see IsIdentityMapping. By default such a mapping
+	// is dropped, so that the relation has direct output and is
+	// serialized with "direct" rather than "emit" by ToProto, like the
+	// plans of other producers. Passing true keeps the mapping as given.
+	SetKeepIdentityMappings(keep bool)
+
+	// AddRelation registers a relation tree to be shared by other
+	// relations of the plan, returning its ordinal in the list of plan
+	// relations to pass to Reference. AddRoot does the same for a root
+	// relation with the given output names, allowing a plan to have
+	// multiple roots. The registered relations are included, in the order
+	// they were added, before the root and other relations passed to Plan.
+	AddRelation(rel Rel) (int32, error)
+	AddRoot(input Rel, names []string) (int32, error)
+	// Reference produces a relation which refers to the registered plan
+	// relation with the given ordinal, having the same output type. The
+	// plan constructors return an error wrapping ErrInvalidRel if the
+	// references between the plan relations form a cycle, such as when a
+	// ReferenceRel produced by another builder refers back to the
+	// relation containing it.
+	Reference(ordinal int32) (*ReferenceRel, error)
+
 	// Plan constructs a new plan with the provided root relation and optionally
 	// other relations. It will use the current substrait version of this
 	// library as the plan substrait version.
+	//
+	// The rootNames must be the names of all of the fields of the output
+	// of root in depth-first order, including those of nested structs, as
+	// in the plan. For an output with nested structs, giving only the
+	// names of the top level fields or any other number of names is an
+	// error reporting the number of names expected for the flattened
+	// record type.
 	Plan(root Rel, rootNames []string, others ...Rel) (*Plan, error)
 	// PlanWithTypes is the same as Plan, only it provides the ability to set
 	// the list of expectedTypeURLs that indicate the different protobuf types
 	// that may be in use with this plan for advanced extensions, optimizations,
 	// and so on.
 	PlanWithTypes(root Rel, rootNames []string, expectedTypeURLs []string, others ...Rel) (*Plan, error)
+	// MultiRootPlan constructs a new plan with a root relation for each of
+	// the roots, in order, with the output names at the same index of names.
+	// Any relations registered with AddRelation or AddRoot are included
+	// before the roots.
+	MultiRootPlan(roots []Rel, names [][]string) (*Plan, error)
+
+	// Reset clears the extensions used by the functions and types built
+	// so far and the relations registered with AddRelation or AddRoot,
+	// so the next plan starts fresh. The collection and the version of
+	// the builder are kept.
+	//
+	// A builder accumulates these across plans: each plan constructed
+	// declares every extension used by the builder until then, and
+	// shares its extensions with the builder, so extensions used after a
+	// plan is constructed are also declared by it. To build independent
+	// plans with one builder, call Reset after constructing each of them.
+	// Expressions and relations built before a Reset refer to the
+	// previous extensions and must not be used after it.
+	Reset()
 }
 
+// NewBuilderDefault returns a new Builder using the extensions of
+// extensions.DefaultCollection, see NewBuilder.
 func NewBuilderDefault() Builder {
 	return NewBuilder(&extensions.DefaultCollection)
 }
 
+// NewBuilder returns a new Builder resolving the functions and types it
+// builds with the extensions of c, which must not be modified while the
+// builder is in use. Each builder has its own extension set, so builders
+// created by separate goroutines are independent of each other.
 func NewBuilder(c *extensions.Collection) Builder {
-	set := extensions.NewSet()
-	return &builder{
-		ext:    c,
-		extSet: set,
-		reg:    expr.NewExtensionRegistry(set, c),
-	}
+	b := &builder{ext: c}
+	b.Reset()
+	return b
 }
 
 var (
@@ -124,11 +417,32 @@ var (
 	errNilInputRel             = fmt.Errorf("%w: input Relation must not be nil", substraitgo.ErrInvalidRel)
 )
 
+// isBoolean reports whether an expression of type t yields a boolean.
+// The type of an outer reference to a relation whose schema isn't known
+// is nil, which isn't a boolean.
+func isBoolean(t types.Type) bool {
+	return types.EqualsIgnoreNullability(t, &types.BooleanType{})
+}
+
+// typeString returns the string representation of t for an error,
+// describing a nil type as unknown.
+func typeString(t types.Type) string {
+	if t == nil {
+		return "unknown type"
+	}
+	return t.String()
+}
+
 type builder struct {
 	ext    *extensions.Collection
 	extSet extensions.Set
 
 	reg expr.ExtensionRegistry
+
+	relations []Relation
+	version   *types.Version
+
+	keepIdentityMappings bool
 }
 
 func (b *builder) GetFunctionRef(nameSpace, key string) types.FunctionRef {
@@ -141,7 +455,44 @@ func (b *builder) UserDefinedType(nameSpace, typeName string, params ...types.Ty
 		Nullability:    types.NullabilityNullable,
 		TypeReference:  b.extSet.GetTypeAnchor(id),
 		TypeParameters: params,
+		Name:           typeName,
+	}
+}
+
+func (b *builder) ResolveUserDefinedType(nameSpace, typeName string, params []types.TypeParam, nullability types.Nullability) (*types.UserDefinedType, error) {
+	id := extensions.ID{URI: nameSpace, Name: typeName}
+	decl, ok := b.ext.GetType(id)
+	if !ok {
+		return nil, fmt.Errorf("%w: user defined type %s not found in %s",
+			substraitgo.ErrNotFound, typeName, nameSpace)
+	}
+
+	required := 0
+	for _, p := range decl.Parameters {
+		if !p.Optional {
+			required++
+		}
+	}
+	if len(params) < required || (len(params) > len(decl.Parameters) && !decl.Variadic) {
+		return nil, fmt.Errorf("%w: user defined type %s takes %d to %d parameters, got %d",
+			substraitgo.ErrInvalidArg, typeName, required, len(decl.Parameters), len(params))
+	}
+
+	return &types.UserDefinedType{
+		Nullability:    nullability,
+		TypeReference:  b.extSet.GetTypeAnchor(id),
+		TypeParameters: params,
+		Name:           typeName,
+	}, nil
+}
+
+func (b *builder) TypeVariation(nameSpace, name string) (uint32, error) {
+	id := extensions.ID{URI: nameSpace, Name: name}
+	if _, ok := b.ext.GetTypeVariation(id); !ok {
+		return 0, fmt.Errorf("%w: type variation %s not found in %s",
+			substraitgo.ErrNotFound, name, nameSpace)
 	}
+	return b.extSet.GetTypeVariationAnchor(id), nil
 }
 
 func (b *builder) JoinedRecordFieldRef(left, right Rel, index int32) (*expr.FieldReference, error) {
@@ -154,6 +505,58 @@ func (b *builder) JoinedRecordFieldRef(left, right Rel, index int32) (*expr.Fiel
 	return expr.NewRootFieldRef(expr.NewStructFieldRef(index), &types.StructType{Types: baseTypes})
 }
 
+func (b *builder) EquiJoinCondition(left, right Rel, leftKeys, rightKeys []int32) (expr.Expression, error) {
+	if left == nil || right == nil {
+		return nil, errNilInputRel
+	}
+
+	if len(leftKeys) == 0 || len(leftKeys) != len(rightKeys) {
+		return nil, fmt.Errorf("%w: must provide the same non-zero number of left and right keys, got %d and %d",
+			substraitgo.ErrInvalidArg, len(leftKeys), len(rightKeys))
+	}
+
+	leftTypes := left.Remap(left.RecordType()).Types
+	rightTypes := right.Remap(right.RecordType()).Types
+	joined := &types.StructType{Types: append(slices.Clip(leftTypes), rightTypes...)}
+
+	comparisons := make([]types.FuncArg, len(leftKeys))
+	for i, l := range leftKeys {
+		r := rightKeys[i]
+		if l < 0 || l >= int32(len(leftTypes)) {
+			return nil, fmt.Errorf("%w: left key %d out of range, only %d fields",
+				substraitgo.ErrInvalidArg, l, len(leftTypes))
+		}
+		if r < 0 || r >= int32(len(rightTypes)) {
+			return nil, fmt.Errorf("%w: right key %d out of range, only %d fields",
+				substraitgo.ErrInvalidArg, r, len(rightTypes))
+		}
+		if !types.EqualsIgnoreNullability(leftTypes[l], rightTypes[r]) {
+			return nil, fmt.Errorf("%w: cannot compare left key %d of type %s to right key %d of type %s",
+				substraitgo.ErrInvalidArg, l, leftTypes[l], r, rightTypes[r])
+		}
+
+		lhs, err := expr.NewRootFieldRef(expr.NewStructFieldRef(l), joined)
+		if err != nil {
+			return nil, err
+		}
+		rhs, err := expr.NewRootFieldRef(expr.NewStructFieldRef(int32(len(leftTypes))+r), joined)
+		if err != nil {
+			return nil, err
+		}
+
+		if comparisons[i], err = b.ScalarFn(extensions.SubstraitDefaultURIPrefix+"functions_comparison.yaml",
+			"equal", nil, lhs, rhs); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(comparisons) == 1 {
+		return comparisons[0].(expr.Expression), nil
+	}
+	return b.ScalarFn(extensions.SubstraitDefaultURIPrefix+"functions_boolean.yaml",
+		"and", nil, comparisons...)
+}
+
 func (b *builder) RootFieldRef(input Rel, index int32) (*expr.FieldReference, error) {
 	base := input.RecordType()
 	if index < 0 || index > int32(len(base.Types)) {
@@ -164,15 +567,164 @@ func (b *builder) RootFieldRef(input Rel, index int32) (*expr.FieldReference, er
 	return expr.NewRootFieldRef(expr.NewStructFieldRef(index), &base)
 }
 
+func (b *builder) NestedFieldRef(input Rel, segments []expr.ReferenceSegment) (*expr.FieldReference, error) {
+	if input == nil {
+		return nil, errNilInputRel
+	}
+
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("%w: must provide at least one reference segment",
+			substraitgo.ErrInvalidArg)
+	}
+
+	base := input.Remap(input.RecordType())
+	chain := make([]expr.ReferenceSegment, len(segments))
+	var cur types.Type = &base
+	for i, s := range segments {
+		switch s := s.(type) {
+		case *expr.StructFieldRef:
+			st, ok := cur.(*types.StructType)
+			if !ok {
+				return nil, fmt.Errorf("%w: segment %d: cannot reference struct field of type %s",
+					substraitgo.ErrInvalidArg, i, cur)
+			}
+
+			if s.Field < 0 || s.Field >= int32(len(st.Types)) {
+				return nil, fmt.Errorf("%w: segment %d: struct field index %d out of range, only %d fields",
+					substraitgo.ErrInvalidArg, i, s.Field, len(st.Types))
+			}
+			chain[i], cur = expr.NewStructFieldRef(s.Field), st.Types[s.Field]
+		case *expr.ListElementRef:
+			lt, ok := cur.(*types.ListType)
+			if !ok {
+				return nil, fmt.Errorf("%w: segment %d: cannot reference list element of type %s",
+					substraitgo.ErrInvalidArg, i, cur)
+			}
+			chain[i], cur = expr.NewListElemRef(s.Offset), lt.Type
+		case *expr.MapKeyRef:
+			mt, ok := cur.(*types.MapType)
+			if !ok {
+				return nil, fmt.Errorf("%w: segment %d: cannot reference map key of type %s",
+					substraitgo.ErrInvalidArg, i, cur)
+			}
+
+			if s.MapKey == nil || !types.EqualsIgnoreNullability(s.MapKey.GetType(), mt.Key) {
+				return nil, fmt.Errorf("%w: segment %d: map key %s does not match key type %s",
+					substraitgo.ErrInvalidArg, i, s.MapKey, mt.Key)
+			}
+			chain[i], cur = expr.NewMapKeyRef(s.MapKey), mt.Value
+		default:
+			return nil, fmt.Errorf("%w: segment %d: unsupported reference segment %T",
+				substraitgo.ErrInvalidArg, i, s)
+		}
+	}
+
+	return expr.NewRootFieldRef(expr.FlattenRefSegments(chain...), &base)
+}
+
+func (b *builder) OuterFieldRef(outer Rel, steps int32, field int32) (*expr.FieldReference, error) {
+	if outer == nil {
+		return nil, errNilInputRel
+	}
+
+	if steps <= 0 {
+		return nil, fmt.Errorf("%w: outer reference steps must be > 0, got %d",
+			substraitgo.ErrInvalidArg, steps)
+	}
+
+	schema := outer.Remap(outer.RecordType())
+	if field < 0 || int(field) >= len(schema.Types) {
+		return nil, fmt.Errorf("%w: cannot create field ref index %d, only %d fields in outer rel",
+			substraitgo.ErrInvalidArg, field, len(schema.Types))
+	}
+
+	return expr.NewOuterFieldRef(uint32(steps), expr.NewStructFieldRef(field), &schema)
+}
+
 func (b *builder) ScalarFn(nameSpace, key string, opts []*types.FunctionOption, args ...types.FuncArg) (*expr.ScalarFunction, error) {
 	id := extensions.ID{URI: nameSpace, Name: key}
-	return expr.NewScalarFunc(b.reg, id, opts, args...)
+	fn, err := expr.NewScalarFunc(b.reg, id, opts, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	if decl, ok := b.reg.LookupScalarFunction(fn.FuncRef()); ok {
+		if err := validateFuncCall(decl, opts, args); err != nil {
+			return nil, err
+		}
+	}
+	return fn, nil
 }
 
 func (b *builder) AggregateFn(nameSpace, key string, opts []*types.FunctionOption, args ...types.FuncArg) (*expr.AggregateFunction, error) {
 	id := extensions.ID{URI: nameSpace, Name: key}
-	return expr.NewAggregateFunc(b.reg, id, opts,
+	fn, err := expr.NewAggregateFunc(b.reg, id, opts,
 		types.AggInvocationAll, types.AggPhaseInitialToResult, nil, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	if decl, ok := b.reg.LookupAggregateFunction(fn.FuncRef()); ok {
+		if err := validateFuncCall(decl, opts, args); err != nil {
+			return nil, err
+		}
+	}
+	return fn, nil
+}
+
+func (b *builder) InList(value expr.Expression, options ...expr.Expression) (*expr.SingularOrList, error) {
+	return expr.NewSingularOrList(value, options)
+}
+
+// validateFuncCall checks that each of the options is declared by the
+// function variant and that its preferences are among the values declared
+// for it, and that each enum argument is one of the options declared for
+// the argument. As with consumers, names and values are matched case
+// insensitively.
+func validateFuncCall(decl extensions.FunctionVariant, opts []*types.FunctionOption, args []types.FuncArg) error {
+	declArgs := decl.Args()
+	for i, a := range args {
+		e, ok := a.(types.Enum)
+		if !ok || i >= len(declArgs) {
+			continue
+		}
+
+		enumArg, ok := declArgs[i].(extensions.EnumArg)
+		if !ok {
+			return fmt.Errorf("%w: argument %d of function %s is not an enum",
+				substraitgo.ErrInvalidArg, i, decl.CompoundName())
+		}
+		if !slices.ContainsFunc(enumArg.Options, func(v string) bool { return strings.EqualFold(v, string(e)) }) {
+			return fmt.Errorf("%w: invalid value %q for enum argument %d of function %s, expected one of %v",
+				substraitgo.ErrInvalidArg, string(e), i, decl.CompoundName(), enumArg.Options)
+		}
+	}
+
+	declared := decl.Options()
+	for _, o := range opts {
+		var (
+			opt   extensions.Option
+			found bool
+		)
+		for name, d := range declared {
+			if strings.EqualFold(name, o.Name) {
+				opt, found = d, true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%w: function %s has no option %q",
+				substraitgo.ErrInvalidArg, decl.CompoundName(), o.Name)
+		}
+
+		for _, p := range o.Preference {
+			if !slices.ContainsFunc(opt.Values, func(v string) bool { return strings.EqualFold(v, p) }) {
+				return fmt.Errorf("%w: invalid value %q for option %s of function %s, expected one of %v",
+					substraitgo.ErrInvalidArg, p, o.Name, decl.CompoundName(), opt.Values)
+			}
+		}
+	}
+	return nil
 }
 
 func (b *builder) Project(input Rel, exprs ...expr.Expression) (*ProjectRel, error) {
@@ -194,6 +746,7 @@ func (b *builder) ProjectRemap(input Rel, remap []int32, exprs ...expr.Expressio
 			return nil, errOutputMappingOutOfRange
 		}
 	}
+	remap = b.directMapping(remap, noutput)
 
 	return &ProjectRel{
 		RelCommon: RelCommon{mapping: remap},
@@ -209,6 +762,69 @@ func (b *builder) Measure(measure *expr.AggregateFunction, filter expr.Expressio
 	}
 }
 
+func (b *builder) MeasureWithOptions(measure *expr.AggregateFunction, filter expr.Expression, invocation types.AggregationInvocation, sorts ...expr.SortField) AggRelMeasure {
+	if measure != nil {
+		measure = measure.WithInvocation(invocation).WithSorts(sorts...)
+	}
+	return b.Measure(measure, filter)
+}
+
+func (b *builder) MeasureWithPhase(measure *expr.AggregateFunction, filter expr.Expression, phase types.AggregationPhase) (AggRelMeasure, error) {
+	if measure == nil {
+		return AggRelMeasure{}, fmt.Errorf("%w: cannot use nil measure", substraitgo.ErrInvalidRel)
+	}
+
+	measure, err := measure.WithPhase(phase)
+	if err != nil {
+		return AggRelMeasure{}, err
+	}
+	return b.Measure(measure, filter), nil
+}
+
+// checkMeasures validates the invocation and sort fields of the
+// aggregate functions of the measures against the input of the
+// aggregate relation.
+func (b *builder) checkMeasures(input Rel, measures []AggRelMeasure) error {
+	inputType := input.Remap(input.RecordType())
+	v := validator{reg: &b.reg}
+	for i, m := range measures {
+		if m.measure == nil {
+			return fmt.Errorf("%w: measure %d for AggregateRel is nil", substraitgo.ErrInvalidRel, i)
+		}
+
+		switch m.measure.Invocation() {
+		case types.AggInvocationUnspecified, types.AggInvocationAll, types.AggInvocationDistinct:
+		default:
+			return fmt.Errorf("%w: invalid invocation %s for measure %d",
+				substraitgo.ErrInvalidArg, m.measure.Invocation(), i)
+		}
+
+		for j, s := range m.measure.Sorts {
+			if s.Expr == nil {
+				return fmt.Errorf("%w: sort field %d of measure %d has nil expression",
+					substraitgo.ErrInvalidArg, j, i)
+			}
+			v.checkExpr("AggregateRel", fmt.Sprintf("measure %d sort %d", i, j), s.Expr, &inputType)
+		}
+
+		for j := 0; j < m.measure.NArgs(); j++ {
+			if arg, ok := m.measure.Arg(j).(expr.Expression); ok {
+				v.checkExpr("AggregateRel", fmt.Sprintf("measure %d argument %d", i, j), arg, &inputType)
+			}
+		}
+
+		if m.filter != nil {
+			if !isBoolean(m.filter.GetType()) {
+				return fmt.Errorf("%w: filter of measure %d for AggregateRel must yield boolean, not %s",
+					substraitgo.ErrInvalidArg, i, typeString(m.filter.GetType()))
+			}
+			v.checkExpr("AggregateRel", fmt.Sprintf("measure %d filter", i), m.filter, &inputType)
+		}
+	}
+
+	return errors.Join(v.errs...)
+}
+
 func (b *builder) AggregateColumnsRemap(input Rel, remap []int32, measures []AggRelMeasure, groupByCols ...int32) (*AggregateRel, error) {
 	if input == nil {
 		return nil, errNilInputRel
@@ -219,28 +835,38 @@ func (b *builder) AggregateColumnsRemap(input Rel, remap []int32, measures []Agg
 			substraitgo.ErrInvalidRel)
 	}
 
-	exprs := make([][]expr.Expression, len(groupByCols))
-	for i, c := range groupByCols {
-		ref, err := b.RootFieldRef(input, c)
-		if err != nil {
-			return nil, err
+	if err := b.checkMeasures(input, measures); err != nil {
+		return nil, err
+	}
+
+	var groups [][]expr.Expression
+	if len(groupByCols) > 0 {
+		exprs := make([]expr.Expression, len(groupByCols))
+		for i, c := range groupByCols {
+			ref, err := b.RootFieldRef(input, c)
+			if err != nil {
+				return nil, err
+			}
+			exprs[i] = ref
 		}
-		exprs[i] = []expr.Expression{ref}
+		groups = [][]expr.Expression{exprs}
 	}
 
-	noutput := int32(len(measures) + len(groupByCols))
+	return b.aggregateRemap(&AggregateRel{input: input, groups: groups, measures: measures}, remap)
+}
+
+// aggregateRemap validates remap against the output of the aggregate,
+// which depends on its groupings and measures, and sets it as the
+// output mapping of the aggregate.
+func (b *builder) aggregateRemap(out *AggregateRel, remap []int32) (*AggregateRel, error) {
+	noutput := int32(len(out.RecordType().Types))
 	for _, idx := range remap {
 		if idx < 0 || idx >= noutput {
 			return nil, errOutputMappingOutOfRange
 		}
 	}
-
-	return &AggregateRel{
-		RelCommon: RelCommon{mapping: remap},
-		input:     input,
-		groups:    exprs,
-		measures:  measures,
-	}, nil
+	out.mapping = b.directMapping(remap, noutput)
+	return out, nil
 }
 
 func (b *builder) AggregateColumns(input Rel, measures []AggRelMeasure, groupByCols ...int32) (*AggregateRel, error) {
@@ -263,25 +889,68 @@ func (b *builder) AggregateExprsRemap(input Rel, remap []int32, measures []AggRe
 		return nil, fmt.Errorf("%w: groupings cannot contain empty expression list or nil expression", substraitgo.ErrInvalidRel)
 	}
 
-	noutput := int32(len(measures) + len(groups))
-	for _, idx := range remap {
-		if idx < 0 || idx >= noutput {
-			return nil, errOutputMappingOutOfRange
-		}
+	if err := b.checkMeasures(input, measures); err != nil {
+		return nil, err
 	}
 
-	return &AggregateRel{
-		RelCommon: RelCommon{mapping: remap},
-		input:     input,
-		groups:    groups,
-		measures:  measures,
-	}, nil
+	return b.aggregateRemap(&AggregateRel{input: input, groups: groups, measures: measures}, remap)
 }
 
 func (b *builder) AggregateExprs(input Rel, measures []AggRelMeasure, groups ...[]expr.Expression) (*AggregateRel, error) {
 	return b.AggregateExprsRemap(input, nil, measures, groups...)
 }
 
+func (b *builder) AggregateGroupingSetsRemap(input Rel, remap []int32, sets [][]expr.Expression, measures []AggRelMeasure) (*AggregateRel, error) {
+	if input == nil {
+		return nil, errNilInputRel
+	}
+
+	if (len(measures) + len(sets)) == 0 {
+		return nil, fmt.Errorf("%w: must have at least one grouping set or measure for AggregateRel",
+			substraitgo.ErrInvalidRel)
+	}
+
+	inputType := input.Remap(input.RecordType())
+	v := validator{reg: &b.reg}
+	groups := make([][]expr.Expression, len(sets))
+	for i, set := range sets {
+		for j, e := range set {
+			if e == nil {
+				return nil, fmt.Errorf("%w: grouping set %d contains nil expression at index %d",
+					substraitgo.ErrInvalidRel, i, j)
+			}
+			v.checkExpr("AggregateRel", fmt.Sprintf("grouping set %d expression %d", i, j), e, &inputType)
+		}
+		groups[i] = slices.Clone(set)
+	}
+
+	if err := b.checkMeasures(input, measures); err != nil {
+		return nil, err
+	}
+
+	for i, m := range measures {
+		v.checkAggregate("AggregateRel", fmt.Sprintf("measure %d", i), m.measure, &inputType)
+	}
+
+	if len(v.errs) > 0 {
+		return nil, errors.Join(v.errs...)
+	}
+
+	return b.aggregateRemap(&AggregateRel{input: input, groups: groups, measures: measures}, remap)
+}
+
+func (b *builder) AggregateGroupingSets(input Rel, sets [][]expr.Expression, measures []AggRelMeasure) (*AggregateRel, error) {
+	return b.AggregateGroupingSetsRemap(input, nil, sets, measures)
+}
+
+func (b *builder) AggregateGroupBy(input Rel, groupings []expr.Expression, measures []AggRelMeasure) (*AggregateRel, error) {
+	var sets [][]expr.Expression
+	if len(groupings) > 0 {
+		sets = [][]expr.Expression{groupings}
+	}
+	return b.AggregateGroupingSets(input, sets, measures)
+}
+
 func (b *builder) CrossRemap(left, right Rel, remap []int32) (*CrossRel, error) {
 	if left == nil || right == nil {
 		return nil, errNilInputRel
@@ -293,6 +962,7 @@ func (b *builder) CrossRemap(left, right Rel, remap []int32) (*CrossRel, error)
 			return nil, errOutputMappingOutOfRange
 		}
 	}
+	remap = b.directMapping(remap, noutput)
 
 	return &CrossRel{
 		RelCommon: RelCommon{mapping: remap},
@@ -304,26 +974,37 @@ func (b *builder) Cross(left, right Rel) (*CrossRel, error) {
 	return b.CrossRemap(left, right, nil)
 }
 
-func (b *builder) FetchRemap(input Rel, offset, count uint64, remap []int32) (*FetchRel, error) {
+func (b *builder) FetchRemap(input Rel, offset, count int64, remap []int32) (*FetchRel, error) {
 	if input == nil {
 		return nil, errNilInputRel
 	}
 
+	if offset < 0 {
+		return nil, fmt.Errorf("%w: fetch offset must not be negative, got %d",
+			substraitgo.ErrInvalidArg, offset)
+	}
+
+	if count < FetchCountAll {
+		return nil, fmt.Errorf("%w: fetch count must not be negative, got %d (use FetchCountAll for all records)",
+			substraitgo.ErrInvalidArg, count)
+	}
+
 	noutput := int32(len(input.Remap(input.RecordType()).Types))
 	for _, idx := range remap {
 		if idx < 0 || idx >= noutput {
 			return nil, errOutputMappingOutOfRange
 		}
 	}
+	remap = b.directMapping(remap, noutput)
 
 	return &FetchRel{
 		RelCommon: RelCommon{mapping: remap},
 		input:     input,
-		offset:    int64(offset), count: int64(count),
+		offset:    offset, count: count,
 	}, nil
 }
 
-func (b *builder) Fetch(input Rel, offset, count uint64) (*FetchRel, error) {
+func (b *builder) Fetch(input Rel, offset, count int64) (*FetchRel, error) {
 	return b.FetchRemap(input, offset, count, nil)
 }
 
@@ -337,9 +1018,9 @@ func (b *builder) FilterRemap(input Rel, condition expr.Expression, remap []int3
 			substraitgo.ErrInvalidRel)
 	}
 
-	if !condition.GetType().WithNullability(types.NullabilityUnspecified).Equals(&types.BooleanType{}) {
+	if !isBoolean(condition.GetType()) {
 		return nil, fmt.Errorf("%w: condition for Filter Relation must yield boolean, not %s",
-			substraitgo.ErrInvalidArg, condition.GetType())
+			substraitgo.ErrInvalidArg, typeString(condition.GetType()))
 	}
 
 	noutput := int32(len(input.Remap(input.RecordType()).Types))
@@ -348,6 +1029,7 @@ func (b *builder) FilterRemap(input Rel, condition expr.Expression, remap []int3
 			return nil, errOutputMappingOutOfRange
 		}
 	}
+	remap = b.directMapping(remap, noutput)
 
 	return &FilterRel{
 		RelCommon: RelCommon{
@@ -372,9 +1054,9 @@ func (b *builder) JoinAndFilterRemap(left, right Rel, condition, postJoinFilter
 			substraitgo.ErrInvalidRel)
 	}
 
-	if !condition.GetType().WithNullability(types.NullabilityUnspecified).Equals(&types.BooleanType{}) {
+	if !isBoolean(condition.GetType()) {
 		return nil, fmt.Errorf("%w: condition for Join Relation must yield boolean, not %s",
-			substraitgo.ErrInvalidArg, condition.GetType())
+			substraitgo.ErrInvalidArg, typeString(condition.GetType()))
 	}
 
 	if joinType == JoinTypeUnspecified {
@@ -383,9 +1065,9 @@ func (b *builder) JoinAndFilterRemap(left, right Rel, condition, postJoinFilter
 	}
 
 	if postJoinFilter != nil {
-		if !postJoinFilter.GetType().WithNullability(types.NullabilityUnspecified).Equals(&types.BooleanType{}) {
+		if !isBoolean(postJoinFilter.GetType()) {
 			return nil, fmt.Errorf("%w: post join filter must be either nil or yield a boolean, not %s",
-				substraitgo.ErrInvalidArg, postJoinFilter.GetType())
+				substraitgo.ErrInvalidArg, typeString(postJoinFilter.GetType()))
 		}
 	}
 
@@ -402,6 +1084,7 @@ func (b *builder) JoinAndFilterRemap(left, right Rel, condition, postJoinFilter
 			return nil, errOutputMappingOutOfRange
 		}
 	}
+	out.mapping = b.directMapping(remap, noutput)
 
 	return out, nil
 }
@@ -410,6 +1093,122 @@ func (b *builder) JoinAndFilter(left, right Rel, condition, postJoinFilter expr.
 	return b.JoinAndFilterRemap(left, right, condition, postJoinFilter, joinType, nil)
 }
 
+func (b *builder) HashJoin(left, right Rel, leftKeys, rightKeys []expr.Expression, joinType JoinType) (*HashJoinRel, error) {
+	lk, rk, hmType, err := physicalJoinKeys("hash", left, right, leftKeys, rightKeys, joinType)
+	if err != nil {
+		return nil, err
+	}
+
+	return &HashJoinRel{
+		left: left, right: right,
+		leftKeys: lk, rightKeys: rk,
+		joinType: hmType,
+	}, nil
+}
+
+func (b *builder) MergeJoin(left, right Rel, leftKeys, rightKeys []expr.Expression, joinType JoinType) (*MergeJoinRel, error) {
+	lk, rk, hmType, err := physicalJoinKeys("merge", left, right, leftKeys, rightKeys, joinType)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MergeJoinRel{
+		left: left, right: right,
+		leftKeys: lk, rightKeys: rk,
+		joinType: hmType,
+	}, nil
+}
+
+// physicalJoinKeys validates the inputs, keys and join type of a hash or
+// merge join, returning the keys as field references.
+func physicalJoinKeys(kind string, left, right Rel, leftKeys, rightKeys []expr.Expression, joinType JoinType) (lk, rk []*expr.FieldReference, hmType HashMergeJoinType, err error) {
+	if left == nil || right == nil {
+		return nil, nil, 0, errNilInputRel
+	}
+
+	hmType, ok := hashMergeJoinTypes[joinType]
+	if !ok {
+		return nil, nil, 0, fmt.Errorf("%w: invalid join type %s for %s join",
+			substraitgo.ErrInvalidArg, joinType, kind)
+	}
+
+	if len(leftKeys) != len(rightKeys) {
+		return nil, nil, 0, fmt.Errorf("%w: mismatched number of keys for %s join, left: %d, right: %d",
+			substraitgo.ErrInvalidArg, kind, len(leftKeys), len(rightKeys))
+	}
+
+	if len(leftKeys) == 0 {
+		return nil, nil, 0, fmt.Errorf("%w: %s join requires at least one key",
+			substraitgo.ErrInvalidArg, kind)
+	}
+
+	var leftTypes, rightTypes []types.Type
+	if lk, leftTypes, err = joinKeyRefs("left", left, leftKeys); err != nil {
+		return nil, nil, 0, err
+	}
+	if rk, rightTypes, err = joinKeyRefs("right", right, rightKeys); err != nil {
+		return nil, nil, 0, err
+	}
+
+	for i := range leftTypes {
+		if !types.EqualsIgnoreNullability(leftTypes[i], rightTypes[i]) {
+			return nil, nil, 0, fmt.Errorf("%w: %s join key %d has mismatched types %s and %s",
+				substraitgo.ErrInvalidArg, kind, i, leftTypes[i], rightTypes[i])
+		}
+	}
+
+	return lk, rk, hmType, nil
+}
+
+func joinKeyRefs(side string, input Rel, keys []expr.Expression) ([]*expr.FieldReference, []types.Type, error) {
+	schema := input.Remap(input.RecordType())
+	refs := make([]*expr.FieldReference, len(keys))
+	keyTypes := make([]types.Type, len(keys))
+	for i, k := range keys {
+		ref, ok := k.(*expr.FieldReference)
+		if !ok || ref.Root != expr.RootReference {
+			return nil, nil, fmt.Errorf("%w: %s join key %d must be a root field reference, not %s",
+				substraitgo.ErrInvalidExpr, side, i, k)
+		}
+
+		seg, ok := ref.Reference.(expr.ReferenceSegment)
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: %s join key %d must be a direct field reference, not %s",
+				substraitgo.ErrInvalidExpr, side, i, k)
+		}
+
+		var err error
+		if keyTypes[i], err = seg.GetType(&schema); err != nil {
+			return nil, nil, fmt.Errorf("%w: invalid %s join key %d: %s",
+				substraitgo.ErrInvalidExpr, side, i, err)
+		}
+		refs[i] = ref
+	}
+	return refs, keyTypes, nil
+}
+
+func (b *builder) NestedLoopJoin(left, right Rel, expression expr.Expression, joinType JoinType) (*NestedLoopJoinRel, error) {
+	if left == nil || right == nil {
+		return nil, errNilInputRel
+	}
+
+	if _, ok := hashMergeJoinTypes[joinType]; !ok {
+		return nil, fmt.Errorf("%w: invalid join type %s for nested loop join",
+			substraitgo.ErrInvalidArg, joinType)
+	}
+
+	if expression != nil && !isBoolean(expression.GetType()) {
+		return nil, fmt.Errorf("%w: expression for nested loop join must yield boolean, not %s",
+			substraitgo.ErrInvalidArg, typeString(expression.GetType()))
+	}
+
+	return &NestedLoopJoinRel{
+		left: left, right: right,
+		expr:     expression,
+		joinType: joinType,
+	}, nil
+}
+
 func (b *builder) JoinRemap(left, right Rel, condition expr.Expression, joinType JoinType, remap []int32) (*JoinRel, error) {
 	return b.JoinAndFilterRemap(left, right, condition, nil, joinType, remap)
 }
@@ -419,30 +1218,118 @@ func (b *builder) Join(left, right Rel, condition expr.Expression, joinType Join
 }
 
 func (b *builder) NamedScanRemap(tableName []string, schema types.NamedStruct, remap []int32) (*NamedTableReadRel, error) {
-	noutput := int32(len(schema.Struct.Types))
-	for _, idx := range remap {
-		if idx < 0 || idx >= noutput {
-			return nil, fmt.Errorf("%w: output mapping index out of range",
-				substraitgo.ErrInvalidRel)
-		}
+	return b.NamedScanWithOptionsRemap(tableName, schema, ScanOptions{}, remap)
+}
+
+func (b *builder) NamedScan(tableName []string, schema types.NamedStruct) *NamedTableReadRel {
+	n, _ := b.NamedScanRemap(tableName, schema, nil)
+	return n
+}
+
+func (b *builder) NamedScanSelect(tableName []string, schema types.NamedStruct, selectNames []string) (*NamedTableReadRel, error) {
+	cols := namedColumns(schema)
+	if cols == nil {
+		return nil, fmt.Errorf("%w: schema has %d names, which don't match its fields",
+			substraitgo.ErrInvalidArg, len(schema.Names))
+	}
+
+	mapping, err := selectMapping(cols, selectNames)
+	if err != nil {
+		return nil, err
+	}
+	return b.NamedScanRemap(tableName, schema, mapping)
+}
+
+func (b *builder) NamedScanWithFilter(tableName []string, schema types.NamedStruct, filter expr.Expression) (*NamedTableReadRel, error) {
+	if filter == nil {
+		return nil, fmt.Errorf("%w: cannot use nil filter in read relation",
+			substraitgo.ErrInvalidRel)
+	}
+	return b.NamedScanWithOptionsRemap(tableName, schema, ScanOptions{Filter: filter}, nil)
+}
+
+func (b *builder) NamedScanWithFilters(tableName []string, schema types.NamedStruct, exact, bestEffort expr.Expression) (*NamedTableReadRel, error) {
+	if exact == nil && bestEffort == nil {
+		return nil, fmt.Errorf("%w: cannot use nil filter in read relation",
+			substraitgo.ErrInvalidRel)
+	}
+	return b.NamedScanWithOptionsRemap(tableName, schema,
+		ScanOptions{Filter: exact, BestEffortFilter: bestEffort}, nil)
+}
+
+func (b *builder) NamedScanWithOptions(tableName []string, schema types.NamedStruct, opts ScanOptions) (*NamedTableReadRel, error) {
+	return b.NamedScanWithOptionsRemap(tableName, schema, opts, nil)
+}
+
+func (b *builder) NamedScanWithOptionsRemap(tableName []string, schema types.NamedStruct, opts ScanOptions, remap []int32) (*NamedTableReadRel, error) {
+	base, err := b.readRelBase(schema, opts, remap)
+	if err != nil {
+		return nil, err
 	}
 
 	return &NamedTableReadRel{
-		baseReadRel: baseReadRel{
-			RelCommon: RelCommon{
-				mapping: remap,
-			},
-			baseSchema: schema,
-		},
-		names: tableName,
+		baseReadRel: base,
+		names:       tableName,
 	}, nil
 }
 
-func (b *builder) NamedScan(tableName []string, schema types.NamedStruct) *NamedTableReadRel {
-	n, _ := b.NamedScanRemap(tableName, schema, nil)
+func (b *builder) NamedScanWithAdvancedExtension(tableName []string, schema types.NamedStruct, ext *anypb.Any) *NamedTableReadRel {
+	n := b.NamedScan(tableName, schema)
+	if ext != nil {
+		n.baseReadRel.advExtension = &extensions.AdvancedExtension{Enhancement: ext}
+	}
 	return n
 }
 
+// readRelBase validates the scan options against the schema and
+// returns the common portion of a read relation.
+func (b *builder) readRelBase(schema types.NamedStruct, opts ScanOptions, remap []int32) (baseReadRel, error) {
+	v := validator{reg: &b.reg}
+	for _, f := range []struct {
+		what string
+		cond expr.Expression
+	}{{"filter", opts.Filter}, {"best effort filter", opts.BestEffortFilter}} {
+		if f.cond == nil {
+			continue
+		}
+
+		if !isBoolean(f.cond.GetType()) {
+			return baseReadRel{}, fmt.Errorf("%w: %s for Read Relation must yield boolean, not %s",
+				substraitgo.ErrInvalidArg, f.what, typeString(f.cond.GetType()))
+		}
+		v.checkExpr("ReadRel", f.what, f.cond, &schema.Struct)
+	}
+
+	if len(v.errs) > 0 {
+		return baseReadRel{}, errors.Join(v.errs...)
+	}
+
+	out := baseReadRel{
+		RelCommon:        RelCommon{mapping: remap},
+		baseSchema:       schema,
+		filter:           opts.Filter,
+		bestEffortFilter: opts.BestEffortFilter,
+		projection:       opts.Projection,
+	}
+
+	if opts.Projection != nil {
+		if _, err := opts.Projection.Select().GetType(&schema.Struct); err != nil {
+			return baseReadRel{}, fmt.Errorf("%w: invalid projection for Read Relation: %w",
+				substraitgo.ErrInvalidArg, err)
+		}
+	}
+
+	noutput := int32(len(out.RecordType().Types))
+	for _, idx := range remap {
+		if idx < 0 || idx >= noutput {
+			return baseReadRel{}, errOutputMappingOutOfRange
+		}
+	}
+	out.mapping = b.directMapping(remap, noutput)
+
+	return out, nil
+}
+
 func (b *builder) VirtualTableRemap(fieldNames []string, remap []int32, values ...expr.StructLiteralValue) (*VirtualTableReadRel, error) {
 	if len(values) == 0 {
 		return nil, fmt.Errorf("%w: must provide at least one set of values for virtual table", substraitgo.ErrInvalidRel)
@@ -460,6 +1347,7 @@ func (b *builder) VirtualTableRemap(fieldNames []string, remap []int32, values .
 				substraitgo.ErrInvalidRel)
 		}
 	}
+	remap = b.directMapping(remap, int32(nfields))
 
 	typeList := make([]types.Type, nfields)
 	for i, v := range values[0] {
@@ -496,6 +1384,49 @@ func (b *builder) VirtualTable(fields []string, values ...expr.StructLiteralValu
 	return b.VirtualTableRemap(fields, nil, values...)
 }
 
+func (b *builder) VirtualTableScanFunc(schema types.NamedStruct, rowCount int, gen func(i int) ([]expr.Literal, error)) (*VirtualTableReadRel, error) {
+	if gen == nil {
+		return nil, fmt.Errorf("%w: must provide a row generator for virtual table", substraitgo.ErrInvalidArg)
+	}
+	if rowCount < 0 {
+		return nil, fmt.Errorf("%w: virtual table row count must not be negative, got %d",
+			substraitgo.ErrInvalidArg, rowCount)
+	}
+
+	fields := schema.Struct.Types
+	values := make([]expr.StructLiteralValue, rowCount)
+	for i := range values {
+		row, err := gen(i)
+		if err != nil {
+			return nil, fmt.Errorf("generating row %d of virtual table: %w", i, err)
+		}
+		if len(row) != len(fields) {
+			return nil, fmt.Errorf("%w: row %d of virtual table has %d values, schema has %d fields",
+				substraitgo.ErrInvalidRel, i, len(row), len(fields))
+		}
+		for j, v := range row {
+			if v == nil {
+				return nil, fmt.Errorf("%w: nil literal in col %d of row %d of virtual table",
+					substraitgo.ErrInvalidRel, j, i)
+			}
+			t := v.GetType()
+			if fields[j].GetNullability() == types.NullabilityNullable {
+				t = t.WithNullability(types.NullabilityNullable)
+			}
+			if !t.Equals(fields[j]) {
+				return nil, fmt.Errorf("%w: found %s in col %d of row %d of virtual table, expected %s",
+					substraitgo.ErrInvalidRel, v.GetType(), j, i, fields[j])
+			}
+		}
+		values[i] = row
+	}
+
+	return &VirtualTableReadRel{
+		baseReadRel: baseReadRel{baseSchema: schema},
+		values:      values,
+	}, nil
+}
+
 func (b *builder) SortRemap(input Rel, remap []int32, sorts ...expr.SortField) (*SortRel, error) {
 	if input == nil {
 		return nil, errNilInputRel
@@ -507,6 +1438,7 @@ func (b *builder) SortRemap(input Rel, remap []int32, sorts ...expr.SortField) (
 			return nil, errOutputMappingOutOfRange
 		}
 	}
+	remap = b.directMapping(remap, noutput)
 
 	if len(sorts) == 0 {
 		return nil, fmt.Errorf("%w: must provide at least one SortField for sort relation", substraitgo.ErrInvalidRel)
@@ -535,6 +1467,18 @@ func (b *builder) SortFields(input Rel, indices ...int32) ([]expr.SortField, err
 	return out, nil
 }
 
+func (b *builder) ComparisonSortField(e expr.Expression, nameSpace, key string) (expr.SortField, error) {
+	if e == nil {
+		return expr.SortField{}, fmt.Errorf("%w: cannot sort by nil expression", substraitgo.ErrInvalidArg)
+	}
+
+	fn, err := b.ScalarFn(nameSpace, key, nil, e, e)
+	if err != nil {
+		return expr.SortField{}, fmt.Errorf("comparison function for sort field: %w", err)
+	}
+	return expr.SortField{Expr: e, Kind: types.FunctionRef(fn.FuncRef())}, nil
+}
+
 func (b *builder) SetRemap(op SetOp, remap []int32, inputs ...Rel) (*SetRel, error) {
 	if op == SetOpUnspecified {
 		return nil, fmt.Errorf("%w: operation for set relation must not be unspecified", substraitgo.ErrInvalidArg)
@@ -559,6 +1503,7 @@ func (b *builder) SetRemap(op SetOp, remap []int32, inputs ...Rel) (*SetRel, err
 			return nil, errOutputMappingOutOfRange
 		}
 	}
+	remap = b.directMapping(remap, noutput)
 
 	for _, in := range inputs[1:] {
 		t := in.Remap(in.RecordType())
@@ -579,29 +1524,434 @@ func (b *builder) Set(op SetOp, inputs ...Rel) (*SetRel, error) {
 	return b.SetRemap(op, nil, inputs...)
 }
 
-func (b *builder) PlanWithTypes(root Rel, rootNames []string, expectedTypeURLs []string, others ...Rel) (*Plan, error) {
-	if root == nil {
+func (b *builder) NamedWrite(input Rel, tableName []string, schema types.NamedStruct, op WriteOp) (*NamedTableWriteRel, error) {
+	return b.NamedWriteWithOutput(input, tableName, schema, op, WriteOutputNoOutput)
+}
+
+func (b *builder) NamedWriteWithOutput(input Rel, tableName []string, schema types.NamedStruct, op WriteOp, output WriteOutputMode) (*NamedTableWriteRel, error) {
+	if input == nil {
+		return nil, errNilInputRel
+	}
+
+	if _, ok := proto.WriteRel_WriteOp_name[int32(op)]; !ok || op == WriteOpUnspecified {
+		return nil, fmt.Errorf("%w: invalid operation %s for write relation",
+			substraitgo.ErrInvalidArg, op)
+	}
+
+	if _, ok := proto.WriteRel_OutputMode_name[int32(output)]; !ok || output == WriteOutputUnspecified {
+		return nil, fmt.Errorf("%w: invalid output mode %s for write relation",
+			substraitgo.ErrInvalidArg, output)
+	}
+
+	inputType := input.Remap(input.RecordType())
+	matches := len(inputType.Types) == len(schema.Struct.Types)
+	for i := 0; matches && i < len(inputType.Types); i++ {
+		matches = types.EqualsIgnoreNullability(inputType.Types[i], schema.Struct.Types[i])
+	}
+
+	if !matches {
+		return nil, fmt.Errorf("%w: input record type %s does not match table schema %s",
+			substraitgo.ErrInvalidRel, &inputType, &schema.Struct)
+	}
+
+	return &NamedTableWriteRel{
+		input:       input,
+		names:       tableName,
+		tableSchema: schema,
+		op:          op,
+		output:      output,
+	}, nil
+}
+
+func (b *builder) Ddl(op DdlOp, object DdlObject, schema types.NamedStruct, tableNames []string) (*DdlRel, error) {
+	return b.ddl(op, object, schema, tableNames, nil)
+}
+
+func (b *builder) DdlView(op DdlOp, schema types.NamedStruct, viewNames []string, definition Rel) (*DdlRel, error) {
+	return b.ddl(op, DdlObjectView, schema, viewNames, definition)
+}
+
+func (b *builder) ddl(op DdlOp, object DdlObject, schema types.NamedStruct, names []string, definition Rel) (*DdlRel, error) {
+	if _, ok := proto.DdlRel_DdlOp_name[int32(op)]; !ok || op == DdlOpUnspecified {
+		return nil, fmt.Errorf("%w: invalid operation %s for ddl relation",
+			substraitgo.ErrInvalidArg, op)
+	}
+
+	if _, ok := proto.DdlRel_DdlObject_name[int32(object)]; !ok || object == DdlObjectUnspecified {
+		return nil, fmt.Errorf("%w: invalid object %s for ddl relation",
+			substraitgo.ErrInvalidArg, object)
+	}
+
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%w: ddl relation requires the name of the %s",
+			substraitgo.ErrInvalidArg, object)
+	}
+
+	if len(schema.Struct.Types) == 0 {
+		return nil, fmt.Errorf("%w: ddl relation requires a schema", substraitgo.ErrInvalidRel)
+	}
+
+	if definition != nil {
+		defType := definition.Remap(definition.RecordType())
+		matches := len(defType.Types) == len(schema.Struct.Types)
+		for i := 0; matches && i < len(defType.Types); i++ {
+			matches = types.EqualsIgnoreNullability(defType.Types[i], schema.Struct.Types[i])
+		}
+
+		if !matches {
+			return nil, fmt.Errorf("%w: view definition record type %s does not match schema %s",
+				substraitgo.ErrInvalidRel, &defType, &schema.Struct)
+		}
+	}
+
+	return &DdlRel{
+		names:          names,
+		tableSchema:    schema,
+		object:         object,
+		op:             op,
+		viewDefinition: definition,
+	}, nil
+}
+
+func (b *builder) ExchangeRemap(input Rel, partitionCount int32, scheme ExchangeScheme, remap []int32) (*ExchangeRel, error) {
+	if input == nil {
+		return nil, errNilInputRel
+	}
+
+	if partitionCount < 0 {
+		return nil, fmt.Errorf("%w: partition count for exchange relation must not be negative, got %d",
+			substraitgo.ErrInvalidArg, partitionCount)
+	}
+
+	inputType := input.Remap(input.RecordType())
+	checkTarget := func(e expr.Expression) error {
+		if e == nil {
+			return fmt.Errorf("%w: target expression for exchange relation must not be nil",
+				substraitgo.ErrInvalidArg)
+		}
+
+		v := validator{reg: &b.reg}
+		v.checkExpr("ExchangeRel", "target expression", e, &inputType)
+		return errors.Join(v.errs...)
+	}
+
+	switch s := scheme.(type) {
+	case *ExchangeScatterByFields:
+		if len(s.Fields) == 0 {
+			return nil, fmt.Errorf("%w: must provide at least one field to scatter by for exchange relation",
+				substraitgo.ErrInvalidArg)
+		}
+
+		for _, f := range s.Fields {
+			if f < 0 || int(f) >= len(inputType.Types) {
+				return nil, fmt.Errorf("%w: scatter field index %d out of range for exchange relation, input has %d fields",
+					substraitgo.ErrInvalidArg, f, len(inputType.Types))
+			}
+		}
+		scheme = &ExchangeScatterByFields{Fields: slices.Clone(s.Fields)}
+	case *ExchangeSingleTarget:
+		if err := checkTarget(s.Expr); err != nil {
+			return nil, err
+		}
+	case *ExchangeMultiTarget:
+		if err := checkTarget(s.Expr); err != nil {
+			return nil, err
+		}
+	case *ExchangeRoundRobin, *ExchangeBroadcast:
+	default:
+		return nil, fmt.Errorf("%w: unknown exchange scheme %T", substraitgo.ErrInvalidArg, scheme)
+	}
+
+	noutput := int32(len(inputType.Types))
+	for _, idx := range remap {
+		if idx < 0 || idx >= noutput {
+			return nil, errOutputMappingOutOfRange
+		}
+	}
+	remap = b.directMapping(remap, noutput)
+
+	return &ExchangeRel{
+		RelCommon:      RelCommon{mapping: remap},
+		input:          input,
+		partitionCount: partitionCount,
+		scheme:         scheme,
+	}, nil
+}
+
+func (b *builder) Exchange(input Rel, partitionCount int32, scheme ExchangeScheme) (*ExchangeRel, error) {
+	return b.ExchangeRemap(input, partitionCount, scheme, nil)
+}
+
+func (b *builder) ExpandRemap(input Rel, fields []ExpandField, remap []int32) (*ExpandRel, error) {
+	if input == nil {
+		return nil, errNilInputRel
+	}
+
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("%w: must provide at least one field for expand relation",
+			substraitgo.ErrInvalidArg)
+	}
+
+	fields = slices.Clone(fields)
+	inputType := input.Remap(input.RecordType())
+	v := validator{reg: &b.reg}
+	ndups := -1
+	for i, f := range fields {
+		switch f := f.(type) {
+		case *SwitchingField:
+			if len(f.Duplicates) == 0 {
+				return nil, fmt.Errorf("%w: switching field %d for expand relation has no duplicates",
+					substraitgo.ErrInvalidArg, i)
+			}
+
+			if ndups == -1 {
+				ndups = len(f.Duplicates)
+			} else if len(f.Duplicates) != ndups {
+				return nil, fmt.Errorf("%w: switching field %d for expand relation has %d duplicates, expected %d",
+					substraitgo.ErrInvalidArg, i, len(f.Duplicates), ndups)
+			}
+
+			for j, d := range f.Duplicates {
+				if d == nil {
+					return nil, fmt.Errorf("%w: duplicate %d of switching field %d for expand relation is nil",
+						substraitgo.ErrInvalidArg, j, i)
+				}
+
+				if !types.EqualsIgnoreNullability(d.GetType(), f.Duplicates[0].GetType()) {
+					return nil, fmt.Errorf("%w: duplicates of switching field %d for expand relation must have the same type, got %s and %s",
+						substraitgo.ErrInvalidArg, i, typeString(f.Duplicates[0].GetType()), typeString(d.GetType()))
+				}
+				v.checkExpr("ExpandRel", fmt.Sprintf("field %d duplicate %d", i, j), d, &inputType)
+			}
+			fields[i] = &SwitchingField{Duplicates: slices.Clone(f.Duplicates)}
+		case *ConsistentField:
+			if f.Expr == nil {
+				return nil, fmt.Errorf("%w: consistent field %d for expand relation is nil",
+					substraitgo.ErrInvalidArg, i)
+			}
+			v.checkExpr("ExpandRel", fmt.Sprintf("field %d", i), f.Expr, &inputType)
+		default:
+			return nil, fmt.Errorf("%w: unknown expand field type %T", substraitgo.ErrInvalidArg, f)
+		}
+	}
+
+	if len(v.errs) > 0 {
+		return nil, errors.Join(v.errs...)
+	}
+
+	out := &ExpandRel{
+		RelCommon: RelCommon{mapping: remap},
+		input:     input,
+		fields:    fields,
+	}
+
+	noutput := int32(len(out.RecordType().Types))
+	for _, idx := range remap {
+		if idx < 0 || idx >= noutput {
+			return nil, errOutputMappingOutOfRange
+		}
+	}
+	out.mapping = b.directMapping(remap, noutput)
+
+	return out, nil
+}
+
+func (b *builder) Expand(input Rel, fields []ExpandField) (*ExpandRel, error) {
+	return b.ExpandRemap(input, fields, nil)
+}
+
+func checkRemap(schema types.NamedStruct, remap []int32) error {
+	noutput := int32(len(schema.Struct.Types))
+	for _, idx := range remap {
+		if idx < 0 || idx >= noutput {
+			return errOutputMappingOutOfRange
+		}
+	}
+	return nil
+}
+
+func (b *builder) ExtensionLeafRemap(detail *anypb.Any, schema types.NamedStruct, remap []int32) (*ExtensionLeafRel, error) {
+	if err := checkRemap(schema, remap); err != nil {
+		return nil, err
+	}
+	remap = b.directMapping(remap, int32(len(schema.Struct.Types)))
+
+	return &ExtensionLeafRel{
+		RelCommon: RelCommon{mapping: remap},
+		detail:    detail,
+		schema:    &schema,
+	}, nil
+}
+
+func (b *builder) ExtensionLeaf(detail *anypb.Any, schema types.NamedStruct) (*ExtensionLeafRel, error) {
+	return b.ExtensionLeafRemap(detail, schema, nil)
+}
+
+func (b *builder) ExtensionSingleRemap(input Rel, detail *anypb.Any, schema types.NamedStruct, remap []int32) (*ExtensionSingleRel, error) {
+	if input == nil {
+		return nil, errNilInputRel
+	}
+
+	if err := checkRemap(schema, remap); err != nil {
+		return nil, err
+	}
+	remap = b.directMapping(remap, int32(len(schema.Struct.Types)))
+
+	return &ExtensionSingleRel{
+		RelCommon: RelCommon{mapping: remap},
+		input:     input,
+		detail:    detail,
+		schema:    &schema,
+	}, nil
+}
+
+func (b *builder) ExtensionSingle(input Rel, detail *anypb.Any, schema types.NamedStruct) (*ExtensionSingleRel, error) {
+	return b.ExtensionSingleRemap(input, detail, schema, nil)
+}
+
+func (b *builder) ExtensionMultiRemap(inputs []Rel, detail *anypb.Any, schema types.NamedStruct, remap []int32) (*ExtensionMultiRel, error) {
+	for _, in := range inputs {
+		if in == nil {
+			return nil, errNilInputRel
+		}
+	}
+
+	if err := checkRemap(schema, remap); err != nil {
+		return nil, err
+	}
+	remap = b.directMapping(remap, int32(len(schema.Struct.Types)))
+
+	return &ExtensionMultiRel{
+		RelCommon: RelCommon{mapping: remap},
+		inputs:    slices.Clone(inputs),
+		detail:    detail,
+		schema:    &schema,
+	}, nil
+}
+
+func (b *builder) ExtensionMulti(inputs []Rel, detail *anypb.Any, schema types.NamedStruct) (*ExtensionMultiRel, error) {
+	return b.ExtensionMultiRemap(inputs, detail, schema, nil)
+}
+
+// planVersion returns the version to record in the plans.
+func (b *builder) planVersion() *types.Version {
+	if b.version == nil {
+		return &CurrentVersion
+	}
+	return b.version
+}
+
+// updateVersion replaces the version to record in the plans with a
+// modified copy, leaving the plans already constructed unchanged.
+func (b *builder) updateVersion(update func(*types.Version)) {
+	cur := b.planVersion()
+	v := &types.Version{
+		MajorNumber: cur.MajorNumber,
+		MinorNumber: cur.MinorNumber,
+		PatchNumber: cur.PatchNumber,
+		GitHash:     cur.GitHash,
+		Producer:    cur.Producer,
+	}
+	update(v)
+	b.version = v
+}
+
+func (b *builder) SetProducer(producer string) {
+	b.updateVersion(func(v *types.Version) { v.Producer = producer })
+}
+
+func (b *builder) SetGitHash(gitHash string) {
+	b.updateVersion(func(v *types.Version) { v.GitHash = gitHash })
+}
+
+func (b *builder) SetVersion(major, minor, patch uint32) {
+	b.updateVersion(func(v *types.Version) {
+		v.MajorNumber, v.MinorNumber, v.PatchNumber = major, minor, patch
+	})
+}
+
+func (b *builder) SetKeepIdentityMappings(keep bool) {
+	b.keepIdentityMappings = keep
+}
+
+// directMapping returns nil in place of an output mapping which is the
+// identity for a relation with width columns, unless the builder is set
+// to keep them, and otherwise the mapping as is.
+func (b *builder) directMapping(mapping []int32, width int32) []int32 {
+	if !b.keepIdentityMappings && IsIdentityMapping(mapping, int(width)) {
+		return nil
+	}
+	return mapping
+}
+
+func (b *builder) AddRelation(rel Rel) (int32, error) {
+	if rel == nil {
+		return 0, errNilInputRel
+	}
+
+	b.relations = append(b.relations, Relation{rel: rel})
+	return int32(len(b.relations) - 1), nil
+}
+
+func (b *builder) AddRoot(input Rel, names []string) (int32, error) {
+	root, err := newRoot(input, names)
+	if err != nil {
+		return 0, err
+	}
+
+	b.relations = append(b.relations, Relation{root: root})
+	return int32(len(b.relations) - 1), nil
+}
+
+func (b *builder) Reference(ordinal int32) (*ReferenceRel, error) {
+	if ordinal < 0 || int(ordinal) >= len(b.relations) {
+		return nil, fmt.Errorf("%w: reference ordinal %d out of range, %d relations have been added",
+			substraitgo.ErrInvalidArg, ordinal, len(b.relations))
+	}
+
+	return &ReferenceRel{
+		ordinal:    ordinal,
+		recordType: b.relations[ordinal].outputType(),
+	}, nil
+}
+
+func newRoot(input Rel, names []string) (*Root, error) {
+	if input == nil {
 		return nil, fmt.Errorf("%w: must provide non-nil root relation for plan",
 			substraitgo.ErrInvalidRel)
 	}
 
-	rec := len(root.Remap(root.RecordType()).Types)
-	if rec != len(rootNames) {
+	fields := input.Remap(input.RecordType()).Types
+	if expected := types.CountNames(fields); len(names) != expected {
+		if expected != len(fields) {
+			return nil, fmt.Errorf("%w: expected %d names for flattened record type, got %d",
+				substraitgo.ErrInvalidRel, expected, len(names))
+		}
 		return nil, fmt.Errorf("%w: mismatched number of names and result record columns, got %d expected %d",
-			substraitgo.ErrInvalidRel, len(rootNames), rec)
+			substraitgo.ErrInvalidRel, len(names), len(fields))
 	}
 
-	relations := make([]Relation, len(others)+1)
-	relations[0].root = &Root{
-		input: root, names: rootNames,
+	return &Root{input: input, names: names}, nil
+}
+
+func (b *builder) PlanWithTypes(root Rel, rootNames []string, expectedTypeURLs []string, others ...Rel) (*Plan, error) {
+	r, err := newRoot(root, rootNames)
+	if err != nil {
+		return nil, err
 	}
 
-	for i, o := range others {
-		relations[i].rel = o
+	relations := make([]Relation, 0, len(b.relations)+len(others)+1)
+	relations = append(relations, b.relations...)
+	relations = append(relations, Relation{root: r})
+	for _, o := range others {
+		relations = append(relations, Relation{rel: o})
+	}
+	if err := checkReferences(relations); err != nil {
+		return nil, err
 	}
 
 	return &Plan{
-		version:          &CurrentVersion,
+		version:          b.planVersion(),
 		extensions:       b.extSet,
 		reg:              b.reg,
 		expectedTypeURLs: expectedTypeURLs,
@@ -609,10 +1959,47 @@ func (b *builder) PlanWithTypes(root Rel, rootNames []string, expectedTypeURLs [
 	}, nil
 }
 
+func (b *builder) Reset() {
+	b.extSet = extensions.NewSet()
+	b.reg = expr.NewExtensionRegistry(b.extSet, b.ext)
+	b.relations = nil
+}
+
 func (b *builder) Plan(root Rel, rootNames []string, others ...Rel) (*Plan, error) {
 	return b.PlanWithTypes(root, rootNames, nil, others...)
 }
 
+func (b *builder) MultiRootPlan(roots []Rel, names [][]string) (*Plan, error) {
+	if len(roots) == 0 {
+		return nil, fmt.Errorf("%w: must provide at least one root relation for plan",
+			substraitgo.ErrInvalidArg)
+	}
+	if len(roots) != len(names) {
+		return nil, fmt.Errorf("%w: got %d root relations but %d lists of names",
+			substraitgo.ErrInvalidArg, len(roots), len(names))
+	}
+
+	relations := make([]Relation, 0, len(b.relations)+len(roots))
+	relations = append(relations, b.relations...)
+	for i, root := range roots {
+		r, err := newRoot(root, names[i])
+		if err != nil {
+			return nil, fmt.Errorf("root %d: %w", i, err)
+		}
+		relations = append(relations, Relation{root: r})
+	}
+	if err := checkReferences(relations); err != nil {
+		return nil, err
+	}
+
+	return &Plan{
+		version:    b.planVersion(),
+		extensions: b.extSet,
+		reg:        b.reg,
+		relations:  relations,
+	}, nil
+}
+
 var (
 	_ Builder = (*builder)(nil)
 )
@@ -0,0 +1,124 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package plan
+
+import (
+	"github.com/substrait-io/substrait-go/proto"
+	extensionspb "github.com/substrait-io/substrait-go/proto/extensions"
+	"golang.org/x/exp/slices"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Canonicalize returns a copy of the plan in a canonical form, such that
+// plans which are Equal, and have the same version and expected type
+// URLs, produce identical protobuf. The copy differs from the plan in
+// that:
+//
+//   - extension URIs and declarations which aren't referenced by any of
+//     the relations are removed
+//   - the remaining URIs and each kind of declaration are assigned
+//     anchors in sorted order, starting from 1
+//   - output mappings which select every column of a relation in order
+//     are replaced by direct output
+//   - the expected type URLs are sorted and deduplicated
+//
+// The plan itself is unchanged.
+func Canonicalize(p *Plan) (*Plan, error) {
+	clone := p.Clone()
+	for _, r := range clone.relations {
+		rel := r.rel
+		if r.IsRoot() {
+			rel = r.root.input
+		}
+		Walk(rel, func(rel Rel) bool {
+			if IsIdentityMapping(rel.OutputMapping(), len(rel.RecordType().Types)) {
+				rel.(interface{ setOutputMapping([]int32) }).setOutputMapping(nil)
+			}
+			return true
+		})
+	}
+
+	out, err := clone.ToProto()
+	if err != nil {
+		return nil, err
+	}
+
+	removeUnusedExtensions(out)
+	if err := assignAnchors(out, newCanonicalAnchors(out.ExtensionUris, out.Extensions)); err != nil {
+		return nil, err
+	}
+	sortExtensions(out)
+
+	if out.ExpectedTypeUrls != nil {
+		slices.Sort(out.ExpectedTypeUrls)
+		out.ExpectedTypeUrls = slices.Compact(out.ExpectedTypeUrls)
+	}
+
+	return FromProto(out, p.reg.Collection())
+}
+
+// IsIdentityMapping reports whether mapping selects each of the width
+// columns of a relation once, in order, such as []int32{0, 1, 2} for a
+// relation with 3 columns. An output mapping like this is equivalent to
+// the direct output of the relation. A nil mapping is not an identity
+// mapping, since it already means direct output.
+func IsIdentityMapping(mapping []int32, width int) bool {
+	if mapping == nil || len(mapping) != width {
+		return false
+	}
+	for i, m := range mapping {
+		if m != int32(i) {
+			return false
+		}
+	}
+	return true
+}
+
+// removeUnusedExtensions removes the extension declarations of the plan
+// whose anchors aren't referenced by its relations, and then the URIs
+// not referenced by any remaining declaration.
+func removeUnusedExtensions(out *proto.Plan) {
+	var used [3]map[uint32]bool
+	for i := range used {
+		used[i] = make(map[uint32]bool)
+	}
+	for _, r := range out.Relations {
+		rangeAnchors(r.ProtoReflect(), func(name protoreflect.Name, anchor uint32) (uint32, bool) {
+			if kind, ok := anchorFields[name]; ok {
+				used[kind][anchor] = true
+			}
+			return anchor, false
+		})
+	}
+
+	usedURIs := make(map[uint32]bool)
+	decls := out.Extensions[:0]
+	for _, d := range out.Extensions {
+		var kind anchorKind
+		var anchor, uri uint32
+		switch m := d.MappingType.(type) {
+		case *extensionspb.SimpleExtensionDeclaration_ExtensionFunction_:
+			kind, anchor, uri = funcAnchor, m.ExtensionFunction.FunctionAnchor, m.ExtensionFunction.ExtensionUriReference
+		case *extensionspb.SimpleExtensionDeclaration_ExtensionType_:
+			kind, anchor, uri = typeAnchor, m.ExtensionType.TypeAnchor, m.ExtensionType.ExtensionUriReference
+		case *extensionspb.SimpleExtensionDeclaration_ExtensionTypeVariation_:
+			kind, anchor, uri = typeVariationAnchor, m.ExtensionTypeVariation.TypeVariationAnchor, m.ExtensionTypeVariation.ExtensionUriReference
+		default:
+			continue
+		}
+
+		if used[kind][anchor] {
+			decls = append(decls, d)
+			usedURIs[uri] = true
+		}
+	}
+	out.Extensions = decls
+
+	uris := out.ExtensionUris[:0]
+	for _, u := range out.ExtensionUris {
+		if usedURIs[u.ExtensionUriAnchor] {
+			uris = append(uris, u)
+		}
+	}
+	out.ExtensionUris = uris
+}
@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package plan_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/substrait-io/substrait-go/extensions"
+	"github.com/substrait-io/substrait-go/plan"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestCanonicalize(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	b.SetKeepIdentityMappings(true)
+	// declares an extension from another URI which the plan never uses
+	b.GetFunctionRef(extensions.SubstraitDefaultURIPrefix+"functions_comparison.yaml", "equal:any_any")
+
+	scan, err := b.NamedScanRemap([]string{"test"}, baseSchema, []int32{0, 1})
+	require.NoError(t, err)
+	ref, err := b.RootFieldRef(scan, 1)
+	require.NoError(t, err)
+
+	// the functions are added in a different order than buildArithmeticPlan
+	abs, err := b.ScalarFn(arithmeticURI, "abs", nil, ref)
+	require.NoError(t, err)
+	_, err = b.ScalarFn(arithmeticURI, "subtract", nil, ref, abs)
+	require.NoError(t, err)
+	add, err := b.ScalarFn(arithmeticURI, "add", nil, abs, ref)
+	require.NoError(t, err)
+	mul, err := b.ScalarFn(arithmeticURI, "multiply", nil, add, ref)
+	require.NoError(t, err)
+	sub, err := b.ScalarFn(arithmeticURI, "subtract", nil, mul, abs)
+	require.NoError(t, err)
+
+	project, err := b.Project(scan, add, mul, sub)
+	require.NoError(t, err)
+	p, err := b.Plan(project, []string{"a", "b", "c", "d", "e"})
+	require.NoError(t, err)
+
+	canonical, err := plan.Canonicalize(p)
+	require.NoError(t, err)
+
+	// the identity remap of the scan becomes direct output
+	assert.Nil(t, canonical.GetRoots()[0].Input().GetInputs()[0].OutputMapping())
+	assert.Equal(t, []int32{0, 1}, p.GetRoots()[0].Input().GetInputs()[0].OutputMapping())
+
+	out, err := canonical.ToProto()
+	require.NoError(t, err)
+	require.Len(t, out.ExtensionUris, 1)
+	assert.Equal(t, arithmeticURI, out.ExtensionUris[0].Uri)
+	assert.EqualValues(t, 1, out.ExtensionUris[0].ExtensionUriAnchor)
+
+	var names []string
+	for i, d := range out.Extensions {
+		fn := d.GetExtensionFunction()
+		assert.EqualValues(t, i+1, fn.FunctionAnchor)
+		names = append(names, fn.Name)
+	}
+	assert.Equal(t, []string{"abs:fp32", "add:fp32_fp32", "multiply:fp32_fp32", "subtract:fp32_fp32"}, names)
+
+	// an equal plan built differently has the same canonical form
+	other, err := plan.Canonicalize(buildArithmeticPlan(t))
+	require.NoError(t, err)
+	otherOut, err := other.ToProto()
+	require.NoError(t, err)
+
+	marshal := proto.MarshalOptions{Deterministic: true}
+	expected, err := marshal.Marshal(otherOut)
+	require.NoError(t, err)
+	actual, err := marshal.Marshal(out)
+	require.NoError(t, err)
+	assert.Equal(t, expected, actual)
+	assert.False(t, p.Equals(canonical), "the original plan declares an unused extension")
+	assert.True(t, other.Equals(canonical))
+}
+
+func TestCanonicalizeAnchorZero(t *testing.T) {
+	// a reference to function anchor 0 isn't populated in proto3, but
+	// the function is still used
+	zero, err := buildArithmeticPlan(t).ToProtoWithAnchors(nameAnchors{
+		arithmeticURI:        0,
+		"abs:fp32":           1,
+		"add:fp32_fp32":      0,
+		"multiply:fp32_fp32": 2,
+		"subtract:fp32_fp32": 3,
+	})
+	require.NoError(t, err)
+	p, err := plan.FromProto(zero, &extensions.DefaultCollection)
+	require.NoError(t, err)
+
+	canonical, err := plan.Canonicalize(p)
+	require.NoError(t, err)
+	require.NoError(t, canonical.Validate())
+
+	other, err := plan.Canonicalize(buildArithmeticPlan(t))
+	require.NoError(t, err)
+	assert.True(t, other.Equals(canonical))
+
+	out, err := canonical.ToProto()
+	require.NoError(t, err)
+	var names []string
+	for _, d := range out.Extensions {
+		names = append(names, d.GetExtensionFunction().Name)
+	}
+	assert.Equal(t, []string{"abs:fp32", "add:fp32_fp32", "multiply:fp32_fp32", "subtract:fp32_fp32"}, names)
+}
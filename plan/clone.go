@@ -0,0 +1,463 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package plan
+
+import (
+	"github.com/substrait-io/substrait-go/expr"
+	"github.com/substrait-io/substrait-go/proto"
+	"github.com/substrait-io/substrait-go/types"
+	"golang.org/x/exp/slices"
+	pb "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// Clone returns a deep copy of the plan, which can be modified without
+// affecting this plan. Every relation tree of the plan is copied with
+// Rel.Clone. The extension set is shared by the copy, as anchors are
+// only ever added to it, so the extension references within the copied
+// relations remain valid.
+func (p *Plan) Clone() *Plan {
+	out := *p
+	out.version = cloneProto(p.version)
+	out.expectedTypeURLs = slices.Clone(p.expectedTypeURLs)
+	out.advExtension = cloneProto(p.advExtension)
+	out.relations = make([]Relation, len(p.relations))
+	for i, r := range p.relations {
+		if r.IsRoot() {
+			out.relations[i].root = &Root{
+				input: r.root.input.Clone(),
+				names: slices.Clone(r.root.names),
+			}
+		} else {
+			out.relations[i].rel = r.rel.Clone()
+		}
+	}
+	return &out
+}
+
+func cloneProto[T pb.Message](m T) T {
+	if !m.ProtoReflect().IsValid() {
+		return m
+	}
+	return pb.Clone(m).(T)
+}
+
+func cloneRel(r Rel) Rel {
+	if r == nil {
+		return nil
+	}
+	return r.Clone()
+}
+
+func cloneRels(rels []Rel) []Rel {
+	if rels == nil {
+		return nil
+	}
+	out := make([]Rel, len(rels))
+	for i, r := range rels {
+		out[i] = cloneRel(r)
+	}
+	return out
+}
+
+// cloneType returns a deep copy of the type, copying the types nested
+// within it as well.
+func cloneType(t types.Type) types.Type {
+	switch t := t.(type) {
+	case nil:
+		return nil
+	case *types.StructType:
+		out := cloneStruct(*t)
+		return &out
+	case *types.ListType:
+		out := *t
+		out.Type = cloneType(t.Type)
+		return &out
+	case *types.MapType:
+		out := *t
+		out.Key, out.Value = cloneType(t.Key), cloneType(t.Value)
+		return &out
+	case *types.UserDefinedType:
+		out := *t
+		out.TypeParameters = slices.Clone(t.TypeParameters)
+		return &out
+	}
+	return t.WithNullability(t.GetNullability())
+}
+
+func cloneStruct(s types.StructType) types.StructType {
+	if s.Types != nil {
+		typs := make([]types.Type, len(s.Types))
+		for i, t := range s.Types {
+			typs[i] = cloneType(t)
+		}
+		s.Types = typs
+	}
+	return s
+}
+
+func cloneNamedStruct(s types.NamedStruct) types.NamedStruct {
+	return types.NamedStruct{
+		Names:  slices.Clone(s.Names),
+		Struct: cloneStruct(s.Struct),
+	}
+}
+
+func cloneSchema(s *types.NamedStruct) *types.NamedStruct {
+	if s == nil {
+		return nil
+	}
+	out := cloneNamedStruct(*s)
+	return &out
+}
+
+// cloneExpr returns a deep copy of the expression. Field references,
+// whose fields are exported, are always copied along with every
+// expression containing one. Other expressions can't be modified once
+// constructed, so those without any field references may be shared.
+func cloneExpr(e expr.Expression) expr.Expression {
+	switch e := e.(type) {
+	case nil:
+		return nil
+	case *expr.FieldReference:
+		return cloneFieldRef(e)
+	}
+	return e.Visit(cloneExpr)
+}
+
+func cloneExprs(exprs []expr.Expression) []expr.Expression {
+	if exprs == nil {
+		return nil
+	}
+	out := make([]expr.Expression, len(exprs))
+	for i, e := range exprs {
+		out[i] = cloneExpr(e)
+	}
+	return out
+}
+
+func cloneFieldRef(f *expr.FieldReference) *expr.FieldReference {
+	if f == nil {
+		return nil
+	}
+	out := *f
+	if root, ok := f.Root.(expr.Expression); ok {
+		out.Root = cloneExpr(root)
+	}
+	if seg, ok := f.Reference.(expr.ReferenceSegment); ok {
+		out.Reference = cloneRefSegment(seg)
+	}
+	return &out
+}
+
+func cloneFieldRefs(refs []*expr.FieldReference) []*expr.FieldReference {
+	if refs == nil {
+		return nil
+	}
+	out := make([]*expr.FieldReference, len(refs))
+	for i, r := range refs {
+		out[i] = cloneFieldRef(r)
+	}
+	return out
+}
+
+func cloneRefSegment(seg expr.ReferenceSegment) expr.ReferenceSegment {
+	switch seg := seg.(type) {
+	case *expr.StructFieldRef:
+		out := *seg
+		out.Child = cloneRefSegment(seg.Child)
+		return &out
+	case *expr.ListElementRef:
+		out := *seg
+		out.Child = cloneRefSegment(seg.Child)
+		return &out
+	case *expr.MapKeyRef:
+		out := *seg
+		out.Child = cloneRefSegment(seg.Child)
+		return &out
+	}
+	return seg
+}
+
+func cloneSorts(sorts []expr.SortField) []expr.SortField {
+	out := slices.Clone(sorts)
+	for i, s := range out {
+		out[i].Expr = cloneExpr(s.Expr)
+	}
+	return out
+}
+
+func (rc *RelCommon) clone() RelCommon {
+	return RelCommon{
+		hint:         cloneProto(rc.hint),
+		mapping:      slices.Clone(rc.mapping),
+		advExtension: cloneProto(rc.advExtension),
+	}
+}
+
+func (b *baseReadRel) clone() baseReadRel {
+	out := *b
+	out.RelCommon = b.RelCommon.clone()
+	out.baseSchema = cloneNamedStruct(b.baseSchema)
+	out.filter = cloneExpr(b.filter)
+	out.bestEffortFilter = cloneExpr(b.bestEffortFilter)
+	out.advExtension = cloneProto(b.advExtension)
+	return out
+}
+
+func (n *NamedTableReadRel) Clone() Rel {
+	out := *n
+	out.baseReadRel = n.baseReadRel.clone()
+	out.names = slices.Clone(n.names)
+	out.advExtension = cloneProto(n.advExtension)
+	return &out
+}
+
+func (v *VirtualTableReadRel) Clone() Rel {
+	out := *v
+	out.baseReadRel = v.baseReadRel.clone()
+	if v.values != nil {
+		out.values = make([]expr.StructLiteralValue, len(v.values))
+		for i, row := range v.values {
+			out.values[i] = slices.Clone(row)
+		}
+	}
+	return &out
+}
+
+func (e *ExtensionTableReadRel) Clone() Rel {
+	out := *e
+	out.baseReadRel = e.baseReadRel.clone()
+	out.detail = cloneProto(e.detail)
+	return &out
+}
+
+func (lf *LocalFileReadRel) Clone() Rel {
+	out := *lf
+	out.baseReadRel = lf.baseReadRel.clone()
+	out.items = slices.Clone(lf.items)
+	for i, item := range out.items {
+		switch format := item.Format.(type) {
+		case *ExtensionReadOptions:
+			out.items[i].Format = (*ExtensionReadOptions)(cloneProto((*anypb.Any)(format)))
+		case *TextReadOptions:
+			out.items[i].Format = (*TextReadOptions)(cloneProto(
+				(*proto.ReadRel_LocalFiles_FileOrFiles_DelimiterSeparatedTextReadOptions)(format)))
+		}
+	}
+	out.advExtension = cloneProto(lf.advExtension)
+	return &out
+}
+
+func (p *ProjectRel) Clone() Rel {
+	out := *p
+	out.RelCommon = p.RelCommon.clone()
+	out.input = cloneRel(p.input)
+	out.exprs = cloneExprs(p.exprs)
+	out.advExtension = cloneProto(p.advExtension)
+	return &out
+}
+
+func (j *JoinRel) Clone() Rel {
+	out := *j
+	out.RelCommon = j.RelCommon.clone()
+	out.left, out.right = cloneRel(j.left), cloneRel(j.right)
+	out.expr = cloneExpr(j.expr)
+	out.postJoinFilter = cloneExpr(j.postJoinFilter)
+	out.advExtension = cloneProto(j.advExtension)
+	return &out
+}
+
+func (c *CrossRel) Clone() Rel {
+	out := *c
+	out.RelCommon = c.RelCommon.clone()
+	out.left, out.right = cloneRel(c.left), cloneRel(c.right)
+	out.advExtension = cloneProto(c.advExtension)
+	return &out
+}
+
+func (f *FetchRel) Clone() Rel {
+	out := *f
+	out.RelCommon = f.RelCommon.clone()
+	out.input = cloneRel(f.input)
+	out.advExtension = cloneProto(f.advExtension)
+	return &out
+}
+
+func (ar *AggregateRel) Clone() Rel {
+	out := *ar
+	out.RelCommon = ar.RelCommon.clone()
+	out.input = cloneRel(ar.input)
+	if ar.groups != nil {
+		out.groups = make([][]expr.Expression, len(ar.groups))
+		for i, g := range ar.groups {
+			out.groups[i] = cloneExprs(g)
+		}
+	}
+	out.measures = slices.Clone(ar.measures)
+	for i, m := range out.measures {
+		if m.measure != nil {
+			measure := *m.measure
+			measure.Sorts = cloneSorts(m.measure.Sorts)
+			out.measures[i].measure = &measure
+		}
+		out.measures[i].filter = cloneExpr(m.filter)
+	}
+	out.advExtension = cloneProto(ar.advExtension)
+	return &out
+}
+
+func (sr *SortRel) Clone() Rel {
+	out := *sr
+	out.RelCommon = sr.RelCommon.clone()
+	out.input = cloneRel(sr.input)
+	out.sorts = cloneSorts(sr.sorts)
+	out.advExtension = cloneProto(sr.advExtension)
+	return &out
+}
+
+func (fr *FilterRel) Clone() Rel {
+	out := *fr
+	out.RelCommon = fr.RelCommon.clone()
+	out.input = cloneRel(fr.input)
+	out.cond = cloneExpr(fr.cond)
+	out.advExtension = cloneProto(fr.advExtension)
+	return &out
+}
+
+func (s *SetRel) Clone() Rel {
+	out := *s
+	out.RelCommon = s.RelCommon.clone()
+	out.inputs = cloneRels(s.inputs)
+	out.advExtension = cloneProto(s.advExtension)
+	return &out
+}
+
+func (es *ExtensionSingleRel) Clone() Rel {
+	out := *es
+	out.RelCommon = es.RelCommon.clone()
+	out.input = cloneRel(es.input)
+	out.detail = cloneProto(es.detail)
+	out.schema = cloneSchema(es.schema)
+	return &out
+}
+
+func (el *ExtensionLeafRel) Clone() Rel {
+	out := *el
+	out.RelCommon = el.RelCommon.clone()
+	out.detail = cloneProto(el.detail)
+	out.schema = cloneSchema(el.schema)
+	return &out
+}
+
+func (em *ExtensionMultiRel) Clone() Rel {
+	out := *em
+	out.RelCommon = em.RelCommon.clone()
+	out.inputs = cloneRels(em.inputs)
+	out.detail = cloneProto(em.detail)
+	out.schema = cloneSchema(em.schema)
+	return &out
+}
+
+func (hr *HashJoinRel) Clone() Rel {
+	out := *hr
+	out.RelCommon = hr.RelCommon.clone()
+	out.left, out.right = cloneRel(hr.left), cloneRel(hr.right)
+	out.leftKeys, out.rightKeys = cloneFieldRefs(hr.leftKeys), cloneFieldRefs(hr.rightKeys)
+	out.postJoinFilter = cloneExpr(hr.postJoinFilter)
+	out.advExtension = cloneProto(hr.advExtension)
+	return &out
+}
+
+func (mr *MergeJoinRel) Clone() Rel {
+	out := *mr
+	out.RelCommon = mr.RelCommon.clone()
+	out.left, out.right = cloneRel(mr.left), cloneRel(mr.right)
+	out.leftKeys, out.rightKeys = cloneFieldRefs(mr.leftKeys), cloneFieldRefs(mr.rightKeys)
+	out.postJoinFilter = cloneExpr(mr.postJoinFilter)
+	out.advExtension = cloneProto(mr.advExtension)
+	return &out
+}
+
+func (nl *NestedLoopJoinRel) Clone() Rel {
+	out := *nl
+	out.RelCommon = nl.RelCommon.clone()
+	out.left, out.right = cloneRel(nl.left), cloneRel(nl.right)
+	out.expr = cloneExpr(nl.expr)
+	out.advExtension = cloneProto(nl.advExtension)
+	return &out
+}
+
+func (w *NamedTableWriteRel) Clone() Rel {
+	out := *w
+	out.RelCommon = w.RelCommon.clone()
+	out.input = cloneRel(w.input)
+	out.names = slices.Clone(w.names)
+	out.tableSchema = cloneNamedStruct(w.tableSchema)
+	out.advExtension = cloneProto(w.advExtension)
+	return &out
+}
+
+func (d *DdlRel) Clone() Rel {
+	out := *d
+	out.RelCommon = d.RelCommon.clone()
+	out.names = slices.Clone(d.names)
+	out.tableSchema = cloneNamedStruct(d.tableSchema)
+	out.tableDefaults = slices.Clone(d.tableDefaults)
+	out.viewDefinition = cloneRel(d.viewDefinition)
+	out.advExtension = cloneProto(d.advExtension)
+	return &out
+}
+
+func (ex *ExchangeRel) Clone() Rel {
+	out := *ex
+	out.RelCommon = ex.RelCommon.clone()
+	out.input = cloneRel(ex.input)
+	switch s := ex.scheme.(type) {
+	case *ExchangeScatterByFields:
+		out.scheme = &ExchangeScatterByFields{Fields: slices.Clone(s.Fields)}
+	case *ExchangeSingleTarget:
+		out.scheme = &ExchangeSingleTarget{Expr: cloneExpr(s.Expr)}
+	case *ExchangeMultiTarget:
+		out.scheme = &ExchangeMultiTarget{Expr: cloneExpr(s.Expr), ConstrainedToCount: s.ConstrainedToCount}
+	case *ExchangeRoundRobin:
+		out.scheme = &ExchangeRoundRobin{Exact: s.Exact}
+	case *ExchangeBroadcast:
+		out.scheme = &ExchangeBroadcast{}
+	}
+	if ex.targets != nil {
+		out.targets = make([]*proto.ExchangeRel_ExchangeTarget, len(ex.targets))
+		for i, t := range ex.targets {
+			out.targets[i] = cloneProto(t)
+		}
+	}
+	out.advExtension = cloneProto(ex.advExtension)
+	return &out
+}
+
+func (ex *ExpandRel) Clone() Rel {
+	out := *ex
+	out.RelCommon = ex.RelCommon.clone()
+	out.input = cloneRel(ex.input)
+	if ex.fields != nil {
+		out.fields = make([]ExpandField, len(ex.fields))
+		for i, f := range ex.fields {
+			switch f := f.(type) {
+			case *SwitchingField:
+				out.fields[i] = &SwitchingField{Duplicates: cloneExprs(f.Duplicates)}
+			case *ConsistentField:
+				out.fields[i] = &ConsistentField{Expr: cloneExpr(f.Expr)}
+			default:
+				out.fields[i] = f
+			}
+		}
+	}
+	return &out
+}
+
+func (r *ReferenceRel) Clone() Rel {
+	out := *r
+	out.RelCommon = r.RelCommon.clone()
+	out.recordType = cloneStruct(r.recordType)
+	return &out
+}
@@ -0,0 +1,64 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package plan_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/substrait-io/substrait-go/expr"
+	"github.com/substrait-io/substrait-go/plan"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestPlanClone(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	p, err := b.Plan(buildJoinOverFilter(t, b), []string{"a", "x"})
+	require.NoError(t, err)
+
+	expected, err := p.ToProto()
+	require.NoError(t, err)
+
+	clone := p.Clone()
+	cloned, err := clone.ToProto()
+	require.NoError(t, err)
+	assert.Truef(t, proto.Equal(expected, cloned), "expected: %s\ngot: %s",
+		protojson.Format(expected), protojson.Format(cloned))
+
+	// modifying the clone leaves the original plan unchanged
+	join := clone.GetRoots()[0].Input().(*plan.JoinRel)
+	join.Left().(*plan.NamedTableReadRel).Names()[0] = "changed"
+	join.OutputMapping()[1] = 1
+	join.Expr().(*expr.FieldReference).Reference.(*expr.StructFieldRef).Field = 0
+	join.Right().(*plan.FilterRel).Input().(*plan.NamedTableReadRel).BaseSchema().Names[0] = "changed"
+	clone.GetRoots()[0].Names()[0] = "changed"
+
+	actual, err := p.ToProto()
+	require.NoError(t, err)
+	assert.Truef(t, proto.Equal(expected, actual), "expected: %s\ngot: %s",
+		protojson.Format(expected), protojson.Format(actual))
+
+	cloned, err = clone.ToProto()
+	require.NoError(t, err)
+	assert.False(t, proto.Equal(expected, cloned))
+	assert.Equal(t, []string{"changed"}, cloned.Relations[0].GetRoot().Input.GetJoin().Left.GetRead().GetNamedTable().Names)
+}
+
+func TestRelCloneWithFunctions(t *testing.T) {
+	p := buildArithmeticPlan(t)
+	project := p.GetRoots()[0].Input()
+
+	clone := project.Clone()
+	assert.NotSame(t, project, clone)
+	assert.NotSame(t, project.GetInputs()[0], clone.GetInputs()[0])
+	assert.True(t, proto.Equal(project.ToProto(), clone.ToProto()))
+
+	orig := project.(*plan.ProjectRel).Expressions()
+	for i, e := range clone.(*plan.ProjectRel).Expressions() {
+		// each function refers to a field, so none of them are shared
+		assert.NotSame(t, orig[i], e)
+		assert.True(t, orig[i].Equals(e))
+	}
+}
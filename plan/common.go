@@ -3,9 +3,12 @@
 package plan
 
 import (
+	"fmt"
+
 	"github.com/substrait-io/substrait-go/extensions"
 	"github.com/substrait-io/substrait-go/proto"
 	"github.com/substrait-io/substrait-go/types"
+	"golang.org/x/exp/slices"
 )
 
 type (
@@ -23,8 +26,8 @@ type RelCommon struct {
 }
 
 func (rc *RelCommon) fromProtoCommon(c *proto.RelCommon) {
-	rc.hint = c.Hint
-	rc.advExtension = c.AdvancedExtension
+	rc.hint = c.GetHint()
+	rc.advExtension = c.GetAdvancedExtension()
 
 	if emit, ok := c.GetEmitKind().(*proto.RelCommon_Emit_); ok {
 		rc.mapping = emit.Emit.OutputMapping
@@ -53,14 +56,108 @@ func (rc *RelCommon) Remap(initial types.StructType) types.StructType {
 
 func (rc *RelCommon) OutputMapping() []int32 { return rc.mapping }
 
+func (rc *RelCommon) setOutputMapping(mapping []int32) { rc.mapping = mapping }
+
+// RelHint is the hint of a relation, which describes the expected
+// output of the relation to help the consumer plan its execution, such
+// as with a cost model, without affecting the results.
+//
+// The hint of this version of Substrait has a single alias for the
+// relation rather than a list of names.
+type RelHint struct {
+	// RowCount is the estimated number of records output by the relation.
+	RowCount float64
+	// RecordSize is the estimated average size of a record in bytes.
+	RecordSize float64
+	// Alias is the name of the relation, such as for qualifying its
+	// columns or for debugging.
+	Alias string
+	// Constraint is the optional runtime constraint of the relation.
+	Constraint *RuntimeConstraint
+}
+
+// RelHintFromProto returns the RelHint of the hint protobuf, the zero
+// RelHint if h is nil.
+func RelHintFromProto(h *Hint) RelHint {
+	return RelHint{
+		RowCount:   h.GetStats().GetRowCount(),
+		RecordSize: h.GetStats().GetRecordSize(),
+		Alias:      h.GetAlias(),
+		Constraint: h.GetConstraint(),
+	}
+}
+
+// ToProto returns the hint as the protobuf of the hint of RelCommon.
+// The stats are only included if either of them are set.
+func (h RelHint) ToProto() *Hint {
+	out := &Hint{Alias: h.Alias, Constraint: h.Constraint}
+	if h.RowCount != 0 || h.RecordSize != 0 {
+		out.Stats = &Stats{RowCount: h.RowCount, RecordSize: h.RecordSize}
+	}
+	return out
+}
+
+// mappableRel is satisfied by pointers to the relation types, all of which
+// embed RelCommon.
+type mappableRel[T any] interface {
+	*T
+	Rel
+	setOutputMapping([]int32)
+	setHint(*Hint)
+}
+
+// withOutputMapping implements Rel.WithOutputMapping by validating the
+// mapping against the record type of the relation and returning a shallow
+// copy of it with the new mapping.
+func withOutputMapping[T any, P mappableRel[T]](rel P, mapping []int32) (Rel, error) {
+	ncols := int32(len(rel.RecordType().Types))
+	for _, idx := range mapping {
+		if idx < 0 || idx >= ncols {
+			return nil, fmt.Errorf("%w: index %d, relation has %d columns",
+				errOutputMappingOutOfRange, idx, ncols)
+		}
+	}
+
+	out := P(new(T))
+	*out = *rel
+	out.setOutputMapping(slices.Clone(mapping))
+	return out, nil
+}
+
 func (rc *RelCommon) GetAdvancedExtension() *extensions.AdvancedExtension {
 	return rc.advExtension
 }
 
+// AdvancedExtension returns the advanced extension of the common fields
+// of the relation, which carries the optimizations and enhancements added
+// by the producer of the plan. For relations which have an advanced
+// extension of their own, that one is returned by GetAdvancedExtension
+// instead.
+func (rc *RelCommon) AdvancedExtension() *extensions.AdvancedExtension {
+	return rc.advExtension
+}
+
+// SetAdvancedExtension replaces the advanced extension of the common
+// fields of the relation, see AdvancedExtension.
+func (rc *RelCommon) SetAdvancedExtension(ext *extensions.AdvancedExtension) {
+	rc.advExtension = ext
+}
+
 func (rc *RelCommon) Hint() *Hint {
 	return rc.hint
 }
 
+func (rc *RelCommon) setHint(hint *Hint) { rc.hint = hint }
+
+// withHint implements Rel.WithHint by returning a shallow copy of the
+// relation with the new hint.
+func withHint[T any, P mappableRel[T]](rel P, hint RelHint) Rel {
+	out := P(new(T))
+	*out = *rel
+	out.setHint(hint.ToProto())
+	return out
+}
+
 func (rc *RelCommon) toProto() *proto.RelCommon {
 	ret := &proto.RelCommon{
 		Hint:              rc.hint,
@@ -0,0 +1,282 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package plan
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/substrait-io/substrait-go/proto"
+	"golang.org/x/exp/slices"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// DifferenceKind is the kind of a Difference between two plans.
+type DifferenceKind int8
+
+const (
+	// DiffRelationCount is a difference in the number of relations of
+	// the plans.
+	DiffRelationCount DifferenceKind = iota
+	// DiffRoot is a relation which is a root in one plan but not in the
+	// other.
+	DiffRoot
+	// DiffRootNames is a difference in the output names of a root.
+	DiffRootNames
+	// DiffRelKind is a difference in the kind of a relation, such as a
+	// JoinRel in place of a CrossRel. The inputs of the relations aren't
+	// compared.
+	DiffRelKind
+	// DiffInputCount is a difference in the number of inputs of a
+	// relation, such as the inputs of a SetRel.
+	DiffInputCount
+	// DiffSchema is a difference in the schema read by a relation.
+	DiffSchema
+	// DiffExpression is a difference in the expressions of a relation,
+	// such as its condition or the arguments of its measures.
+	DiffExpression
+	// DiffProperty is a difference in any other property of a relation,
+	// such as its join type, its output mapping or its hint.
+	DiffProperty
+)
+
+var differenceKindNames = [...]string{
+	DiffRelationCount: "relation count",
+	DiffRoot:          "root",
+	DiffRootNames:     "root names",
+	DiffRelKind:       "relation kind",
+	DiffInputCount:    "input count",
+	DiffSchema:        "schema",
+	DiffExpression:    "expression",
+	DiffProperty:      "property",
+}
+
+func (k DifferenceKind) String() string {
+	if k < 0 || int(k) >= len(differenceKindNames) {
+		return "DifferenceKind(" + strconv.Itoa(int(k)) + ")"
+	}
+	return differenceKindNames[k]
+}
+
+// Difference is a semantic difference between two plans found by Diff.
+type Difference struct {
+	Kind DifferenceKind
+	// Path identifies the relation which differs in the same way as the
+	// errors of Plan.Validate, such as
+	// "relations[0]:JoinRel/input[1]:FilterRel", using the kinds of the
+	// relations of the first plan. It is the index of the relation alone,
+	// such as "relations[0]", for the differences of the relations of the
+	// plan themselves and empty for DiffRelationCount.
+	Path string
+	// Fields are the names of the fields of the protobuf message of the
+	// relation which differ, such as "type" for the join type of a
+	// JoinRel, for DiffSchema, DiffExpression and DiffProperty.
+	Fields []string
+	// Left and Right describe the differing values in the first and the
+	// second plan.
+	Left, Right string
+}
+
+func (d Difference) String() string {
+	if d.Path == "" {
+		return fmt.Sprintf("%s differs: %s != %s", d.Kind, d.Left, d.Right)
+	}
+	return fmt.Sprintf("%s: %s differs: %s != %s", d.Path, d.Kind, d.Left, d.Right)
+}
+
+// Diff returns the semantic differences between the plans, such as a
+// relation of a different kind or with a different expression, rather
+// than the differences between their protobuf messages. Both plans are
+// serialized with the same anchors for each extension URI and
+// declaration first, so the anchors they were assigned are ignored, as
+// are the version, the expected type URLs and the advanced extensions of
+// the plans. The result is empty if there is no difference.
+//
+// The relations of the plans and the relation trees are compared
+// pairwise, along with the inputs of each pair of relations of the same
+// kind. At most one Difference is reported for each pair, listing all
+// of the fields of the relation which differ, and its Kind is the first
+// of DiffSchema, DiffExpression and DiffProperty which applies to one of
+// them. A difference in the output of a relation which only follows
+// from a difference in its inputs, such as a column which becomes
+// nullable, isn't reported again.
+//
+// An error is returned if either plan can't be serialized or if its
+// extensions can't be resolved by its collection once renumbered.
+func Diff(a, b *Plan) ([]Difference, error) {
+	ids := declaredIDs(a.extensions.ToProto())
+	other := declaredIDs(b.extensions.ToProto())
+	ids.uris = append(ids.uris, other.uris...)
+	ids.funcs = append(ids.funcs, other.funcs...)
+	ids.typs = append(ids.typs, other.typs...)
+	ids.typeVars = append(ids.typeVars, other.typeVars...)
+	assigner := ids.anchors()
+
+	lhs, err := withAnchors(a, assigner)
+	if err != nil {
+		return nil, err
+	}
+	rhs, err := withAnchors(b, assigner)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []Difference
+	if len(lhs.relations) != len(rhs.relations) {
+		out = append(out, Difference{
+			Kind:  DiffRelationCount,
+			Left:  strconv.Itoa(len(lhs.relations)),
+			Right: strconv.Itoa(len(rhs.relations)),
+		})
+	}
+
+	for i := 0; i < len(lhs.relations) && i < len(rhs.relations); i++ {
+		path := fmt.Sprintf("relations[%d]", i)
+		l, r := lhs.relations[i], rhs.relations[i]
+		switch {
+		case l.IsRoot() != r.IsRoot():
+			out = append(out, Difference{
+				Kind:  DiffRoot,
+				Path:  path,
+				Left:  strconv.FormatBool(l.IsRoot()),
+				Right: strconv.FormatBool(r.IsRoot()),
+			})
+		case l.IsRoot():
+			if !slices.Equal(l.root.names, r.root.names) {
+				out = append(out, Difference{
+					Kind:  DiffRootNames,
+					Path:  path,
+					Left:  "[" + strings.Join(l.root.names, ", ") + "]",
+					Right: "[" + strings.Join(r.root.names, ", ") + "]",
+				})
+			}
+			out = diffRel(out, path, l.root.input, r.root.input)
+		default:
+			out = diffRel(out, path, l.rel, r.rel)
+		}
+	}
+	return out, nil
+}
+
+// withAnchors returns the plan read back from its protobuf with the
+// anchors chosen by the assigner.
+func withAnchors(p *Plan, assigner AnchorAssigner) (*Plan, error) {
+	out, err := p.ToProtoWithAnchors(assigner)
+	if err != nil {
+		return nil, err
+	}
+	return FromProto(out, p.reg.Collection())
+}
+
+// diffRel appends the differences between the relation trees l and r to
+// out.
+func diffRel(out []Difference, prefix string, l, r Rel) []Difference {
+	path := relPath(prefix, relName(l))
+	if relName(l) != relName(r) {
+		return append(out, Difference{Kind: DiffRelKind, Path: path, Left: relName(l), Right: relName(r)})
+	}
+
+	lin, rin := l.GetInputs(), r.GetInputs()
+	if len(lin) != len(rin) {
+		out = append(out, Difference{
+			Kind:  DiffInputCount,
+			Path:  path,
+			Left:  strconv.Itoa(len(lin)),
+			Right: strconv.Itoa(len(rin)),
+		})
+	} else if d, ok := diffRelFields(l, r); ok {
+		d.Path = path
+		out = append(out, d)
+	}
+
+	for i := 0; i < len(lin) && i < len(rin); i++ {
+		out = diffRel(out, inputPath(path, i), lin[i], rin[i])
+	}
+	return out
+}
+
+var relDescriptor = (&proto.Rel{}).ProtoReflect().Descriptor()
+
+// diffRelFields compares the fields of the protobuf messages of the
+// relations of the same kind, other than their inputs.
+func diffRelFields(l, r Rel) (Difference, bool) {
+	lm, rm := relMessage(l), relMessage(r)
+	lOnly, rOnly := lm.New(), rm.New()
+
+	out := Difference{Kind: DiffProperty}
+	fields := lm.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if fd.Message() != nil && fd.Message().FullName() == relDescriptor.FullName() {
+			continue
+		}
+		if lm.Has(fd) == rm.Has(fd) && lm.Get(fd).Equal(rm.Get(fd)) {
+			continue
+		}
+
+		out.Fields = append(out.Fields, string(fd.Name()))
+		if kind := fieldDifferenceKind(fd); kind < out.Kind {
+			out.Kind = kind
+		}
+		if lm.Has(fd) {
+			lOnly.Set(fd, lm.Get(fd))
+		}
+		if rm.Has(fd) {
+			rOnly.Set(fd, rm.Get(fd))
+		}
+	}
+
+	if len(out.Fields) == 0 {
+		return Difference{}, false
+	}
+	out.Left = prototext.MarshalOptions{}.Format(lOnly.Interface())
+	out.Right = prototext.MarshalOptions{}.Format(rOnly.Interface())
+	return out, true
+}
+
+// relMessage returns the message of the relation type set in the
+// protobuf of the relation, such as the JoinRel of a JoinRel.
+func relMessage(rel Rel) protoreflect.Message {
+	m := rel.ToProto().ProtoReflect()
+	return m.Get(m.WhichOneof(relDescriptor.Oneofs().ByName("rel_type"))).Message()
+}
+
+// fieldDifferenceKind returns the kind of a difference in the field of
+// a relation message.
+func fieldDifferenceKind(fd protoreflect.FieldDescriptor) DifferenceKind {
+	switch {
+	case fd.Name() == "base_schema":
+		return DiffSchema
+	case fd.Message() != nil && containsExpression(fd.Message(), map[protoreflect.FullName]bool{}):
+		return DiffExpression
+	}
+	return DiffProperty
+}
+
+var expressionName = (&proto.Expression{}).ProtoReflect().Descriptor().FullName()
+
+// containsExpression reports whether the message is an Expression or
+// may contain one.
+func containsExpression(md protoreflect.MessageDescriptor, seen map[protoreflect.FullName]bool) bool {
+	if md.FullName() == expressionName {
+		return true
+	}
+	if seen[md.FullName()] {
+		return false
+	}
+	seen[md.FullName()] = true
+
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if fd.IsMap() {
+			fd = fd.MapValue()
+		}
+		if fd.Message() != nil && containsExpression(fd.Message(), seen) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,130 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package plan_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/substrait-io/substrait-go/expr"
+	"github.com/substrait-io/substrait-go/extensions"
+	"github.com/substrait-io/substrait-go/plan"
+)
+
+func buildJoinPlan(t *testing.T, joinType plan.JoinType) *plan.Plan {
+	b := plan.NewBuilderDefault()
+	join := buildJoinOverFilter(t, b)
+
+	// rebuild the join of buildJoinOverFilter with the given join type
+	j := join.(*plan.JoinRel)
+	out, err := b.JoinRemap(j.Left(), j.Right(), j.Expr(), joinType, j.OutputMapping())
+	require.NoError(t, err)
+	p, err := b.Plan(out, []string{"a", "x"})
+	require.NoError(t, err)
+	return p
+}
+
+func TestDiffJoinType(t *testing.T) {
+	inner := buildJoinPlan(t, plan.JoinTypeInner)
+	diffs, err := plan.Diff(inner, buildJoinPlan(t, plan.JoinTypeInner))
+	require.NoError(t, err)
+	assert.Empty(t, diffs)
+
+	diffs, err = plan.Diff(inner, buildJoinPlan(t, plan.JoinTypeLeft))
+	require.NoError(t, err)
+	require.Len(t, diffs, 1)
+	assert.Equal(t, plan.DiffProperty, diffs[0].Kind)
+	assert.Equal(t, "relations[0]:JoinRel", diffs[0].Path)
+	assert.Equal(t, []string{"type"}, diffs[0].Fields)
+	assert.Contains(t, diffs[0].Left, "JOIN_TYPE_INNER")
+	assert.Contains(t, diffs[0].Right, "JOIN_TYPE_LEFT")
+	assert.Contains(t, diffs[0].String(), "relations[0]:JoinRel: property differs: ")
+}
+
+func TestDiffIgnoresAnchors(t *testing.T) {
+	p := buildArithmeticPlan(t)
+	out, err := p.ToProtoWithAnchors(nameAnchors{
+		arithmeticURI:        7,
+		"abs:fp32":           40,
+		"add:fp32_fp32":      10,
+		"multiply:fp32_fp32": 30,
+		"subtract:fp32_fp32": 20,
+	})
+	require.NoError(t, err)
+	other, err := plan.FromProto(out, &extensions.DefaultCollection)
+	require.NoError(t, err)
+
+	diffs, err := plan.Diff(p, other)
+	require.NoError(t, err)
+	assert.Empty(t, diffs)
+}
+
+func TestDiff(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	scan := b.NamedScan([]string{"test"}, baseSchema2)
+	scanA := b.NamedScan([]string{"test"}, baseSchema)
+	refY, err := b.RootFieldRef(scan, 1)
+	require.NoError(t, err)
+
+	filter, err := b.Filter(scan, refY)
+	require.NoError(t, err)
+	filterTrue, err := b.Filter(scan, expr.NewPrimitiveLiteral(true, false))
+	require.NoError(t, err)
+	fetch, err := b.Fetch(scan, 0, 10)
+	require.NoError(t, err)
+	fetchA, err := b.Fetch(scanA, 0, 10)
+	require.NoError(t, err)
+
+	build := func(root plan.Rel, names ...string) *plan.Plan {
+		p, err := b.Plan(root, names)
+		require.NoError(t, err)
+		return p
+	}
+
+	tests := []struct {
+		name     string
+		lhs, rhs *plan.Plan
+		expected []plan.Difference
+	}{
+		{"kind", build(filter, "x", "y"), build(fetch, "x", "y"), []plan.Difference{
+			{Kind: plan.DiffRelKind, Path: "relations[0]:FilterRel", Left: "FilterRel", Right: "FetchRel"},
+		}},
+		{"root names", build(scan, "x", "y"), build(scan, "x", "z"), []plan.Difference{
+			{Kind: plan.DiffRootNames, Path: "relations[0]", Left: "[x, y]", Right: "[x, z]"},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diffs, err := plan.Diff(tt.lhs, tt.rhs)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, diffs)
+		})
+	}
+
+	diffs, err := plan.Diff(build(filter, "x", "y"), build(filterTrue, "x", "y"))
+	require.NoError(t, err)
+	require.Len(t, diffs, 1)
+	assert.Equal(t, plan.DiffExpression, diffs[0].Kind)
+	assert.Equal(t, "relations[0]:FilterRel", diffs[0].Path)
+	assert.Equal(t, []string{"condition"}, diffs[0].Fields)
+
+	// the difference in the schema is reported for the read only
+	diffs, err = plan.Diff(build(fetch, "x", "y"), build(fetchA, "x", "y"))
+	require.NoError(t, err)
+	require.Len(t, diffs, 1)
+	assert.Equal(t, plan.DiffSchema, diffs[0].Kind)
+	assert.Equal(t, "relations[0]:FetchRel/input[0]:NamedTableReadRel", diffs[0].Path)
+	assert.Equal(t, []string{"base_schema"}, diffs[0].Fields)
+
+	single := build(scan, "x", "y")
+	_, err = b.AddRelation(scan)
+	require.NoError(t, err)
+	diffs, err = plan.Diff(single, build(scan, "x", "y"))
+	require.NoError(t, err)
+	assert.Equal(t, []plan.Difference{
+		{Kind: plan.DiffRelationCount, Left: "1", Right: "2"},
+		{Kind: plan.DiffRoot, Path: "relations[0]", Left: "true", Right: "false"},
+	}, diffs)
+}
@@ -0,0 +1,86 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package plan
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ToDOT renders the relation trees of the plan as a Graphviz DOT
+// digraph for debugging. Each relation is a node labeled with its kind
+// and key attributes, such as the table names of a read or the type and
+// condition of a join, with an edge from each relation to each of its
+// inputs. The output mapping (emit) of a relation, if it has one, is
+// used as the label of the edge leading to it. The root of each tree is
+// drawn as a separate node with a double border listing the output
+// names of the plan, relations which are not roots are drawn with a
+// dashed border instead.
+func ToDOT(p *Plan) string {
+	w := dotWriter{}
+	w.b.WriteString("digraph plan {\n")
+	w.b.WriteString("  node [shape=box];\n")
+
+	for _, r := range p.relations {
+		if !r.IsRoot() {
+			w.writeRel(r.rel, "style=dashed")
+			continue
+		}
+
+		id := w.nextID()
+		fmt.Fprintf(&w.b, "  %s [label=%s, peripheries=2];\n", id,
+			dotQuote("root\nnames=["+strings.Join(r.root.names, ", ")+"]"))
+		w.writeEdge(id, r.root.input, w.writeRel(r.root.input, ""))
+	}
+
+	w.b.WriteString("}\n")
+	return w.b.String()
+}
+
+type dotWriter struct {
+	b     strings.Builder
+	count int
+}
+
+func (w *dotWriter) nextID() string {
+	id := fmt.Sprintf("n%d", w.count)
+	w.count++
+	return id
+}
+
+// writeRel writes the node for the relation and the subtrees of its
+// inputs, returning the id of the node.
+func (w *dotWriter) writeRel(rel Rel, style string) string {
+	id := w.nextID()
+	kind, attrs := describeRel(rel)
+	label := kind
+	if len(attrs) > 0 {
+		label += "\n" + strings.Join(attrs, "\n")
+	}
+
+	if style != "" {
+		style = ", " + style
+	}
+	fmt.Fprintf(&w.b, "  %s [label=%s%s];\n", id, dotQuote(label), style)
+
+	for _, in := range rel.GetInputs() {
+		w.writeEdge(id, in, w.writeRel(in, ""))
+	}
+	return id
+}
+
+func (w *dotWriter) writeEdge(parent string, child Rel, childID string) {
+	if mapping := child.OutputMapping(); mapping != nil {
+		fmt.Fprintf(&w.b, "  %s -> %s [label=%s];\n", parent, childID,
+			dotQuote("emit="+formatMapping(mapping)))
+		return
+	}
+	fmt.Fprintf(&w.b, "  %s -> %s;\n", parent, childID)
+}
+
+// dotQuote returns s as a quoted DOT string, with each line of s left
+// justified.
+func dotQuote(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\l`).Replace(s)
+	return `"` + s + `\l"`
+}
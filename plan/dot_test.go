@@ -0,0 +1,61 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package plan_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/substrait-io/substrait-go/plan"
+)
+
+var (
+	dotNode = regexp.MustCompile(`(?m)^  n\d+ \[`)
+	dotEdge = regexp.MustCompile(`(?m)^  n\d+ -> n\d+`)
+)
+
+func TestToDOT(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	left := b.NamedScan([]string{"test"}, baseSchema)
+	right := b.NamedScan([]string{"test2"}, baseSchema2)
+
+	cond, err := b.JoinedRecordFieldRef(left, right, 3)
+	require.NoError(t, err)
+
+	join, err := b.JoinAndFilter(left, right, cond, cond, plan.JoinTypeInner)
+	require.NoError(t, err)
+
+	p, err := b.Plan(join, []string{"a", "b", "c", "d"})
+	require.NoError(t, err)
+
+	dot := plan.ToDOT(p)
+	assert.Regexp(t, `^digraph plan \{\n`, dot)
+	// the root, the join and both reads
+	assert.Len(t, dotNode.FindAllString(dot, -1), 4)
+	assert.Len(t, dotEdge.FindAllString(dot, -1), 3)
+	assert.Contains(t, dot, `n0 [label="root\lnames=[a, b, c, d]\l", peripheries=2];`)
+	assert.Contains(t, dot, `label="join\ltype=INNER\l`)
+	assert.Contains(t, dot, `read\ltable=[test2]\l`)
+	assert.Contains(t, dot, "n0 -> n1;")
+}
+
+func TestToDOTEmit(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	join := buildJoinOverFilter(t, b)
+	p, err := b.Plan(join, []string{"a", "x"})
+	require.NoError(t, err)
+
+	dot := plan.ToDOT(p)
+	assert.Len(t, dotNode.FindAllString(dot, -1), 5)
+	assert.Contains(t, dot, `n0 -> n1 [label="emit=[0, 2]\l"];`)
+	assert.Contains(t, dot, `filter\lcondition=.field(1) => boolean\l`)
+
+	// relations other than the root are drawn dashed
+	p, err = b.Plan(join, []string{"a", "x"}, b.NamedScan([]string{"other"}, baseSchema))
+	require.NoError(t, err)
+	dot = plan.ToDOT(p)
+	assert.Len(t, dotNode.FindAllString(dot, -1), 6)
+	assert.Contains(t, dot, `n5 [label="read\ltable=[other]\lschema=struct<string, fp32>\l", style=dashed];`)
+}
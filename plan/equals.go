@@ -0,0 +1,262 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package plan
+
+import (
+	"sort"
+
+	"github.com/substrait-io/substrait-go/expr"
+	"github.com/substrait-io/substrait-go/extensions"
+	"github.com/substrait-io/substrait-go/proto"
+	extensionspb "github.com/substrait-io/substrait-go/proto/extensions"
+	"golang.org/x/exp/slices"
+	pb "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Equals reports whether the plans have the same semantic content,
+// regardless of the anchors assigned to their extensions. The plans are
+// equal if they have:
+//
+//   - the same relations, in the same order, each either a root with the
+//     same output names or a plain relation tree, where the relation
+//     trees are compared as described for Rel.Equals
+//   - the same extension URIs and declarations, matched by URI and name
+//   - the same advanced extension
+//
+// The version of the plans, including the producer, and their expected
+// type URLs are not compared.
+func (p *Plan) Equals(other *Plan) bool {
+	if p == nil || other == nil {
+		return p == other
+	}
+
+	lhs, err := p.ToProtoWithAnchors(newCanonicalAnchors(p.extensions.ToProto()))
+	if err != nil {
+		return false
+	}
+	rhs, err := other.ToProtoWithAnchors(newCanonicalAnchors(other.extensions.ToProto()))
+	if err != nil {
+		return false
+	}
+
+	for _, out := range []*proto.Plan{lhs, rhs} {
+		out.Version, out.ExpectedTypeUrls = nil, nil
+		sortExtensions(out)
+	}
+	return pb.Equal(lhs, rhs)
+}
+
+// sortExtensions sorts the extension URIs of the plan by their anchors,
+// and the declarations by their kind and then anchor.
+func sortExtensions(p *proto.Plan) {
+	sort.Slice(p.ExtensionUris, func(i, j int) bool {
+		return p.ExtensionUris[i].ExtensionUriAnchor < p.ExtensionUris[j].ExtensionUriAnchor
+	})
+
+	key := func(d *extensionspb.SimpleExtensionDeclaration) (int, uint32) {
+		switch m := d.MappingType.(type) {
+		case *extensionspb.SimpleExtensionDeclaration_ExtensionType_:
+			return 0, m.ExtensionType.TypeAnchor
+		case *extensionspb.SimpleExtensionDeclaration_ExtensionTypeVariation_:
+			return 1, m.ExtensionTypeVariation.TypeVariationAnchor
+		case *extensionspb.SimpleExtensionDeclaration_ExtensionFunction_:
+			return 2, m.ExtensionFunction.FunctionAnchor
+		}
+		return 3, 0
+	}
+	sort.Slice(p.Extensions, func(i, j int) bool {
+		ki, ai := key(p.Extensions[i])
+		kj, aj := key(p.Extensions[j])
+		if ki != kj {
+			return ki < kj
+		}
+		return ai < aj
+	})
+}
+
+// canonicalAnchors is an AnchorAssigner numbering the extension URIs and
+// each kind of declaration in sorted order, so that plans with the same
+// extensions are assigned the same anchors.
+type canonicalAnchors struct {
+	uris                  map[string]uint32
+	funcs, typs, typeVars map[extensions.ID]uint32
+}
+
+func newCanonicalAnchors(uris []*extensionspb.SimpleExtensionURI, decls []*extensionspb.SimpleExtensionDeclaration) canonicalAnchors {
+	return declaredIDs(uris, decls).anchors()
+}
+
+// extensionIDs are the URIs and the IDs of each kind of declaration of
+// the extensions of one or more plans.
+type extensionIDs struct {
+	uris                  []string
+	funcs, typs, typeVars []extensions.ID
+}
+
+// declaredIDs returns the URIs and declarations of the extensions of a
+// plan, identified by their names rather than their anchors.
+func declaredIDs(uris []*extensionspb.SimpleExtensionURI, decls []*extensionspb.SimpleExtensionDeclaration) extensionIDs {
+	var out extensionIDs
+	uriNames := make(map[uint32]string, len(uris))
+	for _, u := range uris {
+		uriNames[u.ExtensionUriAnchor] = u.Uri
+		out.uris = append(out.uris, u.Uri)
+	}
+
+	for _, d := range decls {
+		switch m := d.MappingType.(type) {
+		case *extensionspb.SimpleExtensionDeclaration_ExtensionFunction_:
+			f := m.ExtensionFunction
+			out.funcs = append(out.funcs, extensions.ID{URI: uriNames[f.ExtensionUriReference], Name: f.Name})
+		case *extensionspb.SimpleExtensionDeclaration_ExtensionType_:
+			t := m.ExtensionType
+			out.typs = append(out.typs, extensions.ID{URI: uriNames[t.ExtensionUriReference], Name: t.Name})
+		case *extensionspb.SimpleExtensionDeclaration_ExtensionTypeVariation_:
+			tv := m.ExtensionTypeVariation
+			out.typeVars = append(out.typeVars, extensions.ID{URI: uriNames[tv.ExtensionUriReference], Name: tv.Name})
+		}
+	}
+	return out
+}
+
+// anchors numbers the URIs and each kind of declaration in sorted order.
+func (ids extensionIDs) anchors() canonicalAnchors {
+	sortedURIs := slices.Clone(ids.uris)
+	sort.Strings(sortedURIs)
+	sortedURIs = slices.Compact(sortedURIs)
+
+	out := canonicalAnchors{
+		uris:     make(map[string]uint32, len(sortedURIs)),
+		funcs:    sortedAnchors(ids.funcs),
+		typs:     sortedAnchors(ids.typs),
+		typeVars: sortedAnchors(ids.typeVars),
+	}
+	for i, u := range sortedURIs {
+		out.uris[u] = uint32(i + 1)
+	}
+	return out
+}
+
+// sortedAnchors sorts the ids and assigns each distinct one the anchor
+// following its position in the sorted slice.
+func sortedAnchors(ids []extensions.ID) map[extensions.ID]uint32 {
+	sortIDs(ids)
+	ids = slices.Compact(ids)
+	out := make(map[extensions.ID]uint32, len(ids))
+	for i, id := range ids {
+		out[id] = uint32(i + 1)
+	}
+	return out
+}
+
+func sortIDs(ids []extensions.ID) {
+	sort.Slice(ids, func(i, j int) bool {
+		if ids[i].URI != ids[j].URI {
+			return ids[i].URI < ids[j].URI
+		}
+		return ids[i].Name < ids[j].Name
+	})
+}
+
+func (c canonicalAnchors) URIAnchor(uri string) uint32                 { return c.uris[uri] }
+func (c canonicalAnchors) FuncAnchor(id extensions.ID) uint32          { return c.funcs[id] }
+func (c canonicalAnchors) TypeAnchor(id extensions.ID) uint32          { return c.typs[id] }
+func (c canonicalAnchors) TypeVariationAnchor(id extensions.ID) uint32 { return c.typeVars[id] }
+
+// relEquals implements Rel.Equals by comparing the protobuf of the
+// relation trees after renumbering the functions called by each in the
+// order of their sorted IDs.
+func relEquals(lhs, rhs Rel) bool {
+	if lhs == nil || rhs == nil {
+		return lhs == nil && rhs == nil
+	}
+
+	lhsIDs, rhsIDs := relFuncIDs(lhs), relFuncIDs(rhs)
+	sorted := sortedIDs(lhsIDs)
+	if !slices.Equal(sorted, sortedIDs(rhsIDs)) {
+		return false
+	}
+
+	anchors := sortedAnchors(sorted)
+	lhsProto, rhsProto := lhs.ToProto(), rhs.ToProto()
+	return canonicalFuncRefs(lhsProto, lhsIDs, anchors) &&
+		canonicalFuncRefs(rhsProto, rhsIDs, anchors) &&
+		pb.Equal(lhsProto, rhsProto)
+}
+
+// canonicalFuncRefs replaces the function anchors in rel, whose IDs are
+// given by ids, with the anchors assigned to the IDs. It returns false
+// if a function has several anchors, which can't be made canonical.
+func canonicalFuncRefs(rel *proto.Rel, ids map[uint32]extensions.ID, anchors map[extensions.ID]uint32) bool {
+	var remap anchorRemap
+	for anchor, id := range ids {
+		if err := remap.add("function", id.Name, anchor, anchors[id]); err != nil {
+			return false
+		}
+	}
+
+	remapAnchors(rel.ProtoReflect(), map[protoreflect.Name]anchorRemap{
+		"function_reference": remap,
+	})
+	return true
+}
+
+// sortedIDs returns the distinct IDs in ids in sorted order.
+func sortedIDs(ids map[uint32]extensions.ID) []extensions.ID {
+	out := make([]extensions.ID, 0, len(ids))
+	for _, id := range ids {
+		if !slices.Contains(out, id) {
+			out = append(out, id)
+		}
+	}
+	sortIDs(out)
+	return out
+}
+
+// relFuncIDs returns the IDs of the functions called by the expressions
+// of the relation tree, including those of the relations of subqueries,
+// keyed by their anchors.
+func relFuncIDs(rel Rel) map[uint32]extensions.ID {
+	ids := make(map[uint32]extensions.ID)
+	collectFuncIDs(rel, ids)
+	return ids
+}
+
+func collectFuncIDs(rel Rel, ids map[uint32]extensions.ID) {
+	var collect expr.VisitFunc
+	collect = func(e expr.Expression) expr.Expression {
+		switch f := e.(type) {
+		case nil:
+			return nil
+		case *expr.ScalarFunction:
+			ids[f.FuncRef()] = f.ID()
+		case *expr.WindowFunction:
+			ids[f.FuncRef()] = f.ID()
+		case *expr.InPredicate:
+			if r, ok := f.Haystack.(Rel); ok {
+				collectFuncIDs(r, ids)
+			}
+		case *expr.SetPredicate:
+			if r, ok := f.Tuples.(Rel); ok {
+				collectFuncIDs(r, ids)
+			}
+		}
+		return e.Visit(collect)
+	}
+
+	Walk(rel, func(r Rel) bool {
+		_, _ = r.CopyWithExpressionRewrite(func(e expr.Expression) (expr.Expression, error) {
+			return collect(e), nil
+		}, r.GetInputs()...)
+
+		// the aggregate functions of the measures aren't expressions, only
+		// their arguments are rewritten
+		if agg, ok := r.(*AggregateRel); ok {
+			for _, m := range agg.measures {
+				ids[m.measure.FuncRef()] = m.measure.ID()
+			}
+		}
+		return true
+	})
+}
@@ -0,0 +1,79 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package plan_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/substrait-io/substrait-go/extensions"
+	"github.com/substrait-io/substrait-go/plan"
+	"github.com/substrait-io/substrait-go/types"
+)
+
+func TestPlanEqualsIgnoresAnchors(t *testing.T) {
+	p := buildArithmeticPlan(t)
+
+	out, err := p.ToProtoWithAnchors(nameAnchors{
+		arithmeticURI:        7,
+		"abs:fp32":           40,
+		"add:fp32_fp32":      10,
+		"multiply:fp32_fp32": 30,
+		"subtract:fp32_fp32": 20,
+	})
+	require.NoError(t, err)
+	// the version of the plan is shared with the other plans of the
+	// builder, so it is replaced rather than modified
+	out.Version = &types.Version{MinorNumber: out.Version.MinorNumber, Producer: "another producer"}
+
+	other, err := plan.FromProto(out, &extensions.DefaultCollection)
+	require.NoError(t, err)
+
+	orig, err := p.ToProto()
+	require.NoError(t, err)
+	require.NotEqual(t, orig.Extensions[0].GetExtensionFunction().FunctionAnchor,
+		out.Extensions[0].GetExtensionFunction().FunctionAnchor)
+
+	assert.True(t, p.Equals(other))
+	assert.True(t, other.Equals(p))
+	assert.True(t, p.Equals(p.Clone()))
+
+	root, otherRoot := p.GetRoots()[0].Input(), other.GetRoots()[0].Input()
+	assert.True(t, root.Equals(otherRoot))
+	assert.True(t, otherRoot.Equals(root))
+	assert.True(t, root.GetInputs()[0].Equals(otherRoot.GetInputs()[0]))
+}
+
+func TestPlanEqualsDifferences(t *testing.T) {
+	p := buildArithmeticPlan(t)
+	project := p.GetRoots()[0].Input().(*plan.ProjectRel)
+
+	b := plan.NewBuilderDefault()
+	scan := b.NamedScan([]string{"test"}, baseSchema)
+	ref, err := b.RootFieldRef(scan, 1)
+	require.NoError(t, err)
+
+	// the same columns, but the last one calls add rather than subtract
+	exprs := project.Expressions()
+	add, err := b.ScalarFn(arithmeticURI, "add", nil, exprs[1], ref)
+	require.NoError(t, err)
+	changed, err := b.Project(scan, exprs[0], exprs[1], add)
+	require.NoError(t, err)
+	assert.False(t, project.Equals(changed))
+
+	other, err := b.Plan(changed, []string{"a", "b", "c", "d", "e"})
+	require.NoError(t, err)
+	assert.False(t, p.Equals(other))
+
+	remapped, err := project.WithOutputMapping([]int32{0, 1, 2, 3, 4})
+	require.NoError(t, err)
+	assert.False(t, project.Equals(remapped))
+
+	renamed, err := b.Plan(project, []string{"a", "b", "c", "d", "f"})
+	require.NoError(t, err)
+	assert.False(t, p.Equals(renamed))
+
+	assert.False(t, project.Equals(nil))
+	assert.False(t, p.Equals(nil))
+}
@@ -0,0 +1,117 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package plan
+
+import (
+	"errors"
+	"fmt"
+
+	substraitgo "github.com/substrait-io/substrait-go"
+	"github.com/substrait-io/substrait-go/proto"
+)
+
+// RelError is a problem found in a relation by FromProto, RelFromProto
+// or Plan.Validate. It identifies the relation by its kind and by its
+// path from the root of the plan, using the names of the relation
+// types such as "NamedTableReadRel" and
+// "relations[0]:JoinRel/input[0]:NamedTableReadRel".
+//
+// A RelError matches substraitgo.ErrInvalidRel with errors.Is, as well
+// as the error it wraps, and can be retrieved with errors.As.
+type RelError struct {
+	Kind string
+	Path string
+	Err  error
+}
+
+func (e *RelError) Error() string {
+	return fmt.Sprintf("%s: %s: %s", substraitgo.ErrInvalidRel, e.Path, e.Err)
+}
+
+func (e *RelError) Unwrap() []error {
+	return []error{substraitgo.ErrInvalidRel, e.Err}
+}
+
+// relPath appends the kind of a relation to the path leading to it,
+// such as "relations[0]" or "relations[0]:JoinRel/input[1]".
+func relPath(prefix, kind string) string {
+	if prefix == "" || kind == "" {
+		return prefix + kind
+	}
+	return prefix + ":" + kind
+}
+
+// inputPath returns the path leading to input i of the relation at path.
+func inputPath(path string, i int) string {
+	return fmt.Sprintf("%s/input[%d]", path, i)
+}
+
+// protoRelKind returns the name of the relation type rel is converted
+// to, such as "NamedTableReadRel", or an empty string if no relation
+// type is set.
+func protoRelKind(rel *proto.Rel) string {
+	switch r := rel.GetRelType().(type) {
+	case *proto.Rel_Read:
+		switch r.Read.GetReadType().(type) {
+		case *proto.ReadRel_ExtensionTable_:
+			return "ExtensionTableReadRel"
+		case *proto.ReadRel_LocalFiles_:
+			return "LocalFileReadRel"
+		case *proto.ReadRel_NamedTable_:
+			return "NamedTableReadRel"
+		case *proto.ReadRel_VirtualTable_:
+			return "VirtualTableReadRel"
+		}
+	case *proto.Rel_Write:
+		if _, ok := r.Write.GetWriteType().(*proto.WriteRel_NamedTable); ok {
+			return "NamedTableWriteRel"
+		}
+	}
+
+	m := rel.ProtoReflect()
+	fd := m.WhichOneof(m.Descriptor().Oneofs().ByName("rel_type"))
+	if fd == nil {
+		return ""
+	}
+	return string(fd.Message().Name())
+}
+
+// wrapRelError returns err as a RelError for the relation of the given
+// kind at path, unless it already contains the RelError of an input.
+func wrapRelError(kind, path string, err error) error {
+	var relErr *RelError
+	if errors.As(err, &relErr) {
+		return err
+	}
+	return &RelError{Kind: kind, Path: path, Err: err}
+}
+
+// checkOutputMapping returns an error if an index of the output mapping
+// of rel is out of range for its record type. Extension relations read
+// from protobuf have no schema, so their record type isn't known and
+// their mapping isn't checked.
+func checkOutputMapping(rel Rel) error {
+	switch r := rel.(type) {
+	case *ExtensionLeafRel:
+		if r.schema == nil {
+			return nil
+		}
+	case *ExtensionSingleRel:
+		if r.schema == nil {
+			return nil
+		}
+	case *ExtensionMultiRel:
+		if r.schema == nil {
+			return nil
+		}
+	}
+
+	ncols := len(rel.RecordType().Types)
+	for _, idx := range rel.OutputMapping() {
+		if idx < 0 || int(idx) >= ncols {
+			return fmt.Errorf("output mapping index %d out of range, relation has %d columns",
+				idx, ncols)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package plan
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/substrait-io/substrait-go/extensions"
+	"github.com/substrait-io/substrait-go/proto"
+	extensionspb "github.com/substrait-io/substrait-go/proto/extensions"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// jsonFileOptions are the options used by WriteJSONFile, producing the
+// indented protobuf JSON representation of a plan using the JSON names
+// of its fields.
+var jsonFileOptions = protojson.MarshalOptions{Multiline: true, Indent: "  "}
+
+// ReadJSONFile reads a plan from a file containing its protobuf JSON
+// representation, such as one written by WriteJSONFile, resolving its
+// extensions using the provided collection like FromProto. If c is nil,
+// the extensions bundled with this package, extensions.DefaultCollection,
+// are used.
+//
+// Errors reading the file are returned as is, wrapped with the path of
+// the file. Otherwise the errors are those of UnmarshalJSON, wrapped
+// with the path of the file.
+func ReadJSONFile(path string, c *extensions.Collection) (*Plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading plan: %w", err)
+	}
+
+	if c == nil {
+		c = &extensions.DefaultCollection
+	}
+	out, err := UnmarshalJSON(data, c)
+	if err != nil {
+		return nil, fmt.Errorf("plan in %s: %w", path, err)
+	}
+	return out, nil
+}
+
+// WriteJSONFile writes the indented protobuf JSON representation of the
+// plan to the file at path, creating or truncating it. Enum values are
+// written using their names, as for MarshalJSON.
+func WriteJSONFile(path string, p *Plan) error {
+	out, err := p.ToProto()
+	if err != nil {
+		return err
+	}
+
+	data, err := jsonFileOptions.Marshal(out)
+	if err != nil {
+		return fmt.Errorf("encoding plan as JSON: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("writing plan: %w", err)
+	}
+	return nil
+}
+
+// missingExtensionURI returns the first URI which is referenced by an
+// extension declaration of the plan but isn't loaded in the collection.
+func missingExtensionURI(p *proto.Plan, c *extensions.Collection) (string, bool) {
+	uris := make(map[uint32]string, len(p.ExtensionUris))
+	for _, u := range p.ExtensionUris {
+		uris[u.ExtensionUriAnchor] = u.Uri
+	}
+
+	for _, d := range p.Extensions {
+		var ref uint32
+		switch m := d.MappingType.(type) {
+		case *extensionspb.SimpleExtensionDeclaration_ExtensionFunction_:
+			ref = m.ExtensionFunction.ExtensionUriReference
+		case *extensionspb.SimpleExtensionDeclaration_ExtensionType_:
+			ref = m.ExtensionType.ExtensionUriReference
+		case *extensionspb.SimpleExtensionDeclaration_ExtensionTypeVariation_:
+			ref = m.ExtensionTypeVariation.ExtensionUriReference
+		default:
+			continue
+		}
+
+		if uri, ok := uris[ref]; ok && !c.URILoaded(uri) {
+			return uri, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,74 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package plan_test
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	substraitgo "github.com/substrait-io/substrait-go"
+	"github.com/substrait-io/substrait-go/extensions"
+	"github.com/substrait-io/substrait-go/plan"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestJSONFileRoundTrip(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	p, err := b.Plan(buildJoinOverFilter(t, b), []string{"a", "x"})
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "join.json")
+	require.NoError(t, plan.WriteJSONFile(path, p))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"JOIN_TYPE_INNER"`)
+
+	result, err := plan.ReadJSONFile(path, nil)
+	require.NoError(t, err)
+
+	expected, err := p.ToProto()
+	require.NoError(t, err)
+	actual, err := result.ToProto()
+	require.NoError(t, err)
+	assert.Truef(t, proto.Equal(expected, actual), "expected: %s\ngot: %s", expected, actual)
+}
+
+func TestReadJSONFileErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := plan.ReadJSONFile(filepath.Join(dir, "missing.json"), nil)
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	assert.ErrorContains(t, err, "missing.json")
+
+	invalid := filepath.Join(dir, "invalid.json")
+	require.NoError(t, os.WriteFile(invalid, []byte(`{"relations": [`), 0o644))
+	_, err = plan.ReadJSONFile(invalid, nil)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "plan in "+invalid+": invalid argument: invalid plan JSON: ")
+
+	unknown := filepath.Join(dir, "unknown.json")
+	require.NoError(t, os.WriteFile(unknown, []byte(`{
+		"extensionUris": [{"extensionUriAnchor": 1, "uri": "https://example.com/unknown.yaml"}],
+		"extensions": [{"extensionFunction": {"extensionUriReference": 1, "functionAnchor": 1, "name": "foo:i32"}}],
+		"relations": [{"root": {"input": {"read": {
+			"baseSchema": {"names": ["a"], "struct": {"types": [{"i32": {"nullability": "NULLABILITY_REQUIRED"}}]}},
+			"namedTable": {"names": ["t"]}
+		}}, "names": ["a"]}}]
+	}`), 0o644))
+	_, err = plan.ReadJSONFile(unknown, nil)
+	assert.ErrorIs(t, err, substraitgo.ErrNotFound)
+	assert.ErrorContains(t, err, "https://example.com/unknown.yaml")
+
+	// UnmarshalJSON reports the missing extensions the same way
+	data, err := os.ReadFile(unknown)
+	require.NoError(t, err)
+	_, err = plan.UnmarshalJSON(data, &extensions.DefaultCollection)
+	assert.ErrorIs(t, err, substraitgo.ErrNotFound)
+	assert.EqualError(t, err, "not found: plan declares extensions from https://example.com/unknown.yaml, "+
+		"which is not loaded in the collection")
+}
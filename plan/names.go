@@ -0,0 +1,279 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package plan
+
+import (
+	"fmt"
+	"strconv"
+
+	substraitgo "github.com/substrait-io/substrait-go"
+	"github.com/substrait-io/substrait-go/expr"
+	"github.com/substrait-io/substrait-go/types"
+	"golang.org/x/exp/slices"
+)
+
+// namedRecordType implements Rel.NamedRecordType, falling back to
+// positional names for all of the columns if the names derived for the
+// relation don't line up with its record type.
+func namedRecordType(rel Rel) types.NamedStruct {
+	names := []string{}
+	for _, c := range recordColumns(rel) {
+		names = append(names, c...)
+	}
+	return types.NamedStruct{Names: names, Struct: rel.RecordType()}
+}
+
+// recordColumns returns the names of each column of the RecordType of
+// rel, as for relColumns, using positional names if those derived for
+// the relation don't line up with its record type.
+func recordColumns(rel Rel) [][]string {
+	typeList := rel.RecordType().Types
+	cols := relColumns(rel)
+	if len(cols) != len(typeList) {
+		cols = positionalColumns(typeList)
+	}
+	return cols
+}
+
+// selectByName implements Rel.SelectByName.
+func selectByName(rel Rel, names []string) (Rel, error) {
+	mapping, err := selectMapping(recordColumns(rel), names)
+	if err != nil {
+		return nil, err
+	}
+	return rel.WithOutputMapping(mapping)
+}
+
+// selectMapping returns the output mapping selecting the columns with
+// the given names, in order, from the columns cols.
+func selectMapping(cols [][]string, names []string) ([]int32, error) {
+	mapping := make([]int32, len(names))
+	for i, name := range names {
+		mapping[i] = -1
+		for j, c := range cols {
+			if c[0] != name {
+				continue
+			}
+			if mapping[i] != -1 {
+				return nil, fmt.Errorf("%w: column name %q is ambiguous, matches columns %d and %d",
+					substraitgo.ErrInvalidArg, name, mapping[i], j)
+			}
+			mapping[i] = int32(j)
+		}
+
+		if mapping[i] == -1 {
+			return nil, fmt.Errorf("%w: no column named %q", substraitgo.ErrInvalidArg, name)
+		}
+	}
+	return mapping, nil
+}
+
+// positionalName is the name given to the field at index i of a struct
+// when the field has no known name.
+func positionalName(i int) string { return "field_" + strconv.Itoa(i) }
+
+// nestedNames returns positional names, in depth-first order, for the
+// fields of the structs nested within t.
+func nestedNames(t types.Type) []string {
+	var out []string
+	switch t := t.(type) {
+	case *types.StructType:
+		for i, f := range t.Types {
+			out = append(out, positionalName(i))
+			out = append(out, nestedNames(f)...)
+		}
+	case *types.ListType:
+		out = nestedNames(t.Type)
+	case *types.MapType:
+		out = append(nestedNames(t.Key), nestedNames(t.Value)...)
+	}
+	return out
+}
+
+// positionalColumn returns the names for a column of type t which has
+// no known name and is at index i of the record.
+func positionalColumn(t types.Type, i int) []string {
+	return append([]string{positionalName(i)}, nestedNames(t)...)
+}
+
+func positionalColumns(typeList []types.Type) [][]string {
+	out := make([][]string, len(typeList))
+	for i, t := range typeList {
+		out[i] = positionalColumn(t, i)
+	}
+	return out
+}
+
+// namedColumns splits the depth-first names of the schema into the
+// names of each of its columns, returning nil if there aren't as many
+// names as the schema requires.
+func namedColumns(schema types.NamedStruct) [][]string {
+	out := make([][]string, len(schema.Struct.Types))
+	names := schema.Names
+	for i, t := range schema.Struct.Types {
+		n := 1 + len(nestedNames(t))
+		if len(names) < n {
+			return nil
+		}
+		out[i], names = names[:n:n], names[n:]
+	}
+	if len(names) != 0 {
+		return nil
+	}
+	return out
+}
+
+// outputColumns returns the names of the columns of rel after its
+// output mapping is applied.
+func outputColumns(rel Rel) [][]string {
+	cols := recordColumns(rel)
+	mapping := rel.OutputMapping()
+	if mapping == nil {
+		return cols
+	}
+
+	out := make([][]string, len(mapping))
+	for i, m := range mapping {
+		if int(m) >= len(cols) {
+			return nil
+		}
+		out[i] = cols[m]
+	}
+	return out
+}
+
+// exprColumn returns the names for a column computed by e at index i of
+// the record. A reference to a column of the input keeps the name of the
+// column, given by input, other expressions have positional names.
+func exprColumn(e expr.Expression, input [][]string, i int) []string {
+	if ref, ok := e.(*expr.FieldReference); ok && ref.Root == expr.RootReference {
+		if f, ok := ref.Reference.(*expr.StructFieldRef); ok && f.Child == nil &&
+			f.Field >= 0 && int(f.Field) < len(input) {
+			return input[f.Field]
+		}
+	}
+	return positionalColumn(e.GetType(), i)
+}
+
+// joinColumns returns the names of the columns output by a join of the
+// given type, mirroring joinRecordType.
+func joinColumns(left, right Rel, joinType JoinType) [][]string {
+	switch joinType {
+	case JoinTypeLeftSemi, JoinTypeLeftAnti:
+		return outputColumns(left)
+	case JoinTypeRightSemi, JoinTypeRightAnti:
+		return outputColumns(right)
+	}
+	return append(slices.Clip(outputColumns(left)), outputColumns(right)...)
+}
+
+func readColumns(r ReadRel) [][]string {
+	base := namedColumns(r.BaseSchema())
+	if r.Projection() == nil || base == nil {
+		return base
+	}
+
+	recordType := r.RecordType()
+	sel := r.Projection().Select()
+	if len(sel) != len(recordType.Types) {
+		return nil
+	}
+
+	out := make([][]string, len(sel))
+	for i, item := range sel {
+		if item.Field() < 0 || int(item.Field()) >= len(base) {
+			return nil
+		}
+		out[i] = base[item.Field()]
+		if item.Child() != nil {
+			// the nested fields may be a subset of those of the base
+			// schema, so they're named by position
+			out[i] = append([]string{out[i][0]}, nestedNames(recordType.Types[i])...)
+		}
+	}
+	return out
+}
+
+// relColumns returns the names of each column of the RecordType of rel,
+// before its output mapping is applied. The names of a column are the
+// name of the column followed by the depth-first names of its nested
+// struct fields. It may return nil if the names can't be determined.
+func relColumns(rel Rel) [][]string {
+	switch r := rel.(type) {
+	case ReadRel:
+		return readColumns(r)
+	case *ProjectRel:
+		input := outputColumns(r.input)
+		out := slices.Clip(recordColumns(r.input))
+		for _, e := range r.exprs {
+			out = append(out, exprColumn(e, input, len(out)))
+		}
+		return out
+	case *FilterRel:
+		return recordColumns(r.input)
+	case *FetchRel:
+		return recordColumns(r.input)
+	case *SortRel:
+		return recordColumns(r.input)
+	case *AggregateRel:
+		input := outputColumns(r.input)
+		var out [][]string
+		for _, e := range r.GroupingExpressions() {
+			out = append(out, exprColumn(e, input, len(out)))
+		}
+		for _, m := range r.measures {
+			out = append(out, positionalColumn(m.measure.GetType(), len(out)))
+		}
+		if len(r.groups) > 1 {
+			out = append(out, positionalColumn(&types.Int32Type{}, len(out)))
+		}
+		return out
+	case *JoinRel:
+		return joinColumns(r.left, r.right, r.joinType)
+	case *CrossRel:
+		return joinColumns(r.left, r.right, JoinTypeInner)
+	case *HashJoinRel:
+		return joinColumns(r.left, r.right, r.joinType.JoinType())
+	case *MergeJoinRel:
+		return joinColumns(r.left, r.right, r.joinType.JoinType())
+	case *NestedLoopJoinRel:
+		return joinColumns(r.left, r.right, r.joinType)
+	case *SetRel:
+		return outputColumns(r.inputs[0])
+	case *ExchangeRel:
+		return outputColumns(r.input)
+	case *ExpandRel:
+		input := outputColumns(r.input)
+		var out [][]string
+		for _, f := range r.fields {
+			switch f := f.(type) {
+			case *ConsistentField:
+				out = append(out, exprColumn(f.Expr, input, len(out)))
+			case *SwitchingField:
+				out = append(out, exprColumn(f.Duplicates[0], input, len(out)))
+			}
+		}
+		if len(out) < len(input) {
+			out = append(out, input[len(out):]...)
+		}
+		return append(out, positionalColumn(&types.Int64Type{}, len(out)))
+	case *ExtensionSingleRel:
+		if r.schema != nil {
+			return namedColumns(*r.schema)
+		}
+		return recordColumns(r.input)
+	case *ExtensionLeafRel:
+		if r.schema != nil {
+			return namedColumns(*r.schema)
+		}
+	case *ExtensionMultiRel:
+		if r.schema != nil {
+			return namedColumns(*r.schema)
+		}
+	case *NamedTableWriteRel:
+		if r.output == WriteOutputModifiedRecords {
+			return namedColumns(r.tableSchema)
+		}
+	}
+	return positionalColumns(rel.RecordType().Types)
+}
@@ -0,0 +1,433 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package plan
+
+import (
+	"fmt"
+
+	substraitgo "github.com/substrait-io/substrait-go"
+	"github.com/substrait-io/substrait-go/expr"
+	"github.com/substrait-io/substrait-go/types"
+	"golang.org/x/exp/slices"
+)
+
+// PushDownFetch returns a copy of the plan with each FetchRel moved
+// below the ProjectRels directly beneath it, so that fewer records are
+// projected. The plan itself is unchanged.
+//
+// A projection computes each of its output records from a single input
+// record, so fetching before projecting returns the same records. This
+// doesn't hold for a projection with a window function, whose result
+// depends on the other records of its partition, so a fetch is never
+// moved below such a projection. Neither is it moved below any other
+// kind of relation, such as a FilterRel, AggregateRel or SortRel, which
+// would change which records are returned. A FetchRel with an output
+// mapping is left in place.
+func PushDownFetch(p *Plan) (*Plan, error) {
+	return rewritePlan(p, func(root Rel) (Rel, error) {
+		return Transform(root, func(r Rel) (Rel, error) {
+			if fetch, ok := r.(*FetchRel); ok {
+				return pushDownFetch(fetch), nil
+			}
+			return r, nil
+		})
+	})
+}
+
+func pushDownFetch(f *FetchRel) Rel {
+	proj, ok := f.input.(*ProjectRel)
+	if !ok || f.mapping != nil || slices.ContainsFunc(proj.exprs, containsWindowFunc) {
+		return f
+	}
+
+	fetch := *f
+	fetch.input = proj.input
+	project := *proj
+	project.input = pushDownFetch(&fetch)
+	return &project
+}
+
+// containsWindowFunc reports whether e or any of its subexpressions is
+// a window function.
+func containsWindowFunc(e expr.Expression) bool {
+	found := false
+	var visit expr.VisitFunc
+	visit = func(e expr.Expression) expr.Expression {
+		if found || e == nil {
+			return e
+		}
+		if _, ok := e.(*expr.WindowFunction); ok {
+			found = true
+			return e
+		}
+		return e.Visit(visit)
+	}
+	visit(e)
+	return found
+}
+
+// PruneColumns returns a copy of the plan in which the read relations
+// only produce the columns used by the relations above them, by setting
+// the projection of the reads, and in which the expressions of
+// projections which aren't used are removed. The field references of
+// the relations between the reads and the root are updated to match,
+// the output of each relation of the plan is unchanged. The plan itself
+// is unchanged.
+//
+// The pass is conservative: columns are only pruned through chains of
+// ProjectRel, FilterRel, SortRel and FetchRel down to a read without an
+// output mapping or projection. The inputs of any other kind of
+// relation, such as a join or an aggregate, and of a FilterRel, SortRel
+// or FetchRel with an output mapping, are left as they are, as are all
+// of the columns of an input when an expression of the relation above
+// it contains a subquery, which may refer to them.
+func PruneColumns(p *Plan) (*Plan, error) {
+	return rewritePlan(p, func(root Rel) (Rel, error) {
+		need := make([]bool, len(root.Remap(root.RecordType()).Types))
+		for i := range need {
+			need[i] = true
+		}
+		out, _, err := pruneColumns(root, need)
+		return out, err
+	})
+}
+
+// pruneColumns returns rel rewritten to only produce the columns of its
+// output for which need is true, along with the new index of each
+// column of its output, -1 for those which were removed. Columns which
+// aren't needed may remain.
+func pruneColumns(rel Rel, need []bool) (Rel, []int32, error) {
+	switch r := rel.(type) {
+	case *NamedTableReadRel:
+		proj, remap := pruneRead(&r.baseReadRel, need)
+		if proj == nil {
+			return r, remap, nil
+		}
+		out := *r
+		out.projection = proj
+		return &out, remap, nil
+	case *LocalFileReadRel:
+		proj, remap := pruneRead(&r.baseReadRel, need)
+		if proj == nil {
+			return r, remap, nil
+		}
+		out := *r
+		out.projection = proj
+		return &out, remap, nil
+	case *FilterRel:
+		if r.mapping != nil {
+			break
+		}
+		input, remap, cond, err := pruneInput(r.input, need, r.cond)
+		if err != nil || input == r.input {
+			return r, identityMapping(len(need)), err
+		}
+		out := *r
+		out.input, out.cond = input, cond[0]
+		return &out, remap, nil
+	case *SortRel:
+		if r.mapping != nil {
+			break
+		}
+		exprs := make([]expr.Expression, len(r.sorts))
+		for i, s := range r.sorts {
+			exprs[i] = s.Expr
+		}
+		input, remap, exprs, err := pruneInput(r.input, need, exprs...)
+		if err != nil || input == r.input {
+			return r, identityMapping(len(need)), err
+		}
+		out := *r
+		out.input, out.sorts = input, slices.Clone(r.sorts)
+		for i, e := range exprs {
+			out.sorts[i].Expr = e
+		}
+		return &out, remap, nil
+	case *FetchRel:
+		if r.mapping != nil {
+			break
+		}
+		input, remap, _, err := pruneInput(r.input, need)
+		if err != nil || input == r.input {
+			return r, identityMapping(len(need)), err
+		}
+		out := *r
+		out.input = input
+		return &out, remap, nil
+	case *ProjectRel:
+		return pruneProject(r, need)
+	}
+
+	return rel, identityMapping(len(need)), nil
+}
+
+// pruneInput prunes input to the columns in need and those referenced
+// by exprs, returning the pruned input, the new indexes of its columns
+// and exprs with their references updated to match.
+func pruneInput(input Rel, need []bool, exprs ...expr.Expression) (Rel, []int32, []expr.Expression, error) {
+	need = slices.Clone(need)
+	for _, e := range exprs {
+		markReferences(e, need)
+	}
+
+	out, remap, err := pruneColumns(input, need)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	schema := outputType(out)
+	if exprs, err = remapReferences(exprs, remap, &schema); err != nil {
+		return nil, nil, nil, err
+	}
+	return out, remap, exprs, nil
+}
+
+// pruneRead returns the projection selecting the columns of the read in
+// need, nil if the read is left as is, and the new indexes of them. A
+// read with an output mapping or a projection is left as is, and at
+// least one column is kept.
+func pruneRead(b *baseReadRel, need []bool) (*expr.MaskExpression, []int32) {
+	if b.mapping != nil || b.projection != nil || !slices.Contains(need, false) {
+		return nil, identityMapping(len(need))
+	}
+
+	if !slices.Contains(need, true) {
+		need = slices.Clone(need)
+		need[0] = true
+	}
+
+	remap := make([]int32, len(need))
+	var sel expr.MaskStructSelect
+	for i, n := range need {
+		remap[i] = -1
+		if n {
+			remap[i] = int32(len(sel))
+			sel = append(sel, expr.NewMaskStructItem(int32(i), nil))
+		}
+	}
+	return expr.NewMaskExpression(sel, false), remap
+}
+
+// pruneProject removes the expressions of the projection which produce
+// columns which aren't in need and prunes its input to the columns used
+// by the remaining ones.
+func pruneProject(p *ProjectRel, need []bool) (Rel, []int32, error) {
+	ninput := len(p.input.Remap(p.input.RecordType()).Types)
+	direct := make([]bool, ninput+len(p.exprs))
+	if p.mapping == nil {
+		copy(direct, need)
+	} else {
+		// the output mapping is kept as is, so each of the columns it
+		// selects is needed
+		for _, m := range p.mapping {
+			direct[m] = true
+		}
+	}
+
+	var exprs []expr.Expression
+	for i, e := range p.exprs {
+		if direct[ninput+i] {
+			exprs = append(exprs, e)
+		}
+	}
+	if len(exprs) == 0 {
+		// a projection must have at least one expression
+		direct[ninput] = true
+		exprs = append(exprs, p.exprs[0])
+	}
+
+	input, inRemap, exprs, err := pruneInput(p.input, direct[:ninput], exprs...)
+	if err != nil {
+		return nil, nil, err
+	}
+	if input == p.input && len(exprs) == len(p.exprs) {
+		return p, identityMapping(len(need)), nil
+	}
+
+	// the new index of each column produced by the projection before its
+	// output mapping is applied
+	newInput := int32(len(input.Remap(input.RecordType()).Types))
+	directRemap := append(slices.Clip(inRemap), make([]int32, len(p.exprs))...)
+	next := newInput
+	for i := range p.exprs {
+		directRemap[ninput+i] = -1
+		if direct[ninput+i] {
+			directRemap[ninput+i], next = next, next+1
+		}
+	}
+
+	out := *p
+	out.input, out.exprs = input, exprs
+	if p.mapping == nil {
+		return &out, directRemap, nil
+	}
+
+	out.mapping = make([]int32, len(p.mapping))
+	for i, m := range p.mapping {
+		out.mapping[i] = directRemap[m]
+	}
+	return &out, identityMapping(len(need)), nil
+}
+
+// markReferences sets need for each column of the input referenced by
+// e, or for all of them if e contains a reference other than to a field
+// of the input or a subquery.
+func markReferences(e expr.Expression, need []bool) {
+	all := func() {
+		for i := range need {
+			need[i] = true
+		}
+	}
+
+	var visit expr.VisitFunc
+	visit = func(e expr.Expression) expr.Expression {
+		switch e := e.(type) {
+		case nil:
+			return e
+		case *expr.FieldReference:
+			if e.Root != expr.RootReference {
+				break
+			}
+			if f, ok := e.Reference.(*expr.StructFieldRef); ok && f.Field >= 0 && int(f.Field) < len(need) {
+				need[f.Field] = true
+			} else {
+				all()
+			}
+		case *expr.InPredicate, *expr.SetPredicate:
+			all()
+			return e
+		}
+		return e.Visit(visit)
+	}
+	visit(e)
+}
+
+// remapReferences returns exprs with the references to the fields of
+// the input replaced by references to their new index in remap, which
+// are resolved against schema.
+func remapReferences(exprs []expr.Expression, remap []int32, schema *types.StructType) ([]expr.Expression, error) {
+	var (
+		err   error
+		visit expr.VisitFunc
+	)
+	visit = func(e expr.Expression) expr.Expression {
+		ref, ok := e.(*expr.FieldReference)
+		if !ok || err != nil {
+			if e == nil || err != nil {
+				return e
+			}
+			return e.Visit(visit)
+		}
+
+		f, ok := ref.Reference.(*expr.StructFieldRef)
+		if ref.Root != expr.RootReference || !ok || f.Field < 0 ||
+			int(f.Field) >= len(remap) || remap[f.Field] == f.Field {
+			return e
+		}
+
+		var out *expr.FieldReference
+		out, err = expr.NewRootFieldRef(&expr.StructFieldRef{Field: remap[f.Field], Child: f.Child}, schema)
+		return out
+	}
+
+	out := make([]expr.Expression, len(exprs))
+	for i, e := range exprs {
+		out[i] = visit(e)
+	}
+	return out, err
+}
+
+// SwapJoinInputs returns a copy of the JoinRel j with its left and right
+// inputs swapped, such as for reordering joins. The field references of
+// its condition and post join filter are updated to match, a left join
+// becomes a right join and vice versa, and the copy has an output
+// mapping restoring the order of the columns, so that its output is the
+// same as that of j. The relation itself is unchanged.
+//
+// An error wrapping substraitgo.ErrInvalidArg is returned if j isn't a
+// JoinRel, or if its condition or post join filter contains a subquery,
+// whose references to the fields of the join aren't rewritten. The
+// inputs of semi, anti and single joins can't be swapped, since the
+// right variants of those aren't supported, which is reported as an
+// error wrapping substraitgo.ErrNotImplemented.
+func SwapJoinInputs(j Rel) (Rel, error) {
+	join, ok := j.(*JoinRel)
+	if !ok {
+		return nil, fmt.Errorf("%w: can only swap the inputs of a JoinRel, not %T",
+			substraitgo.ErrInvalidArg, j)
+	}
+
+	out := *join
+	switch join.joinType {
+	case JoinTypeInner, JoinTypeOuter:
+	case JoinTypeLeft:
+		out.joinType = JoinTypeRight
+	case JoinTypeRight:
+		out.joinType = JoinTypeLeft
+	default:
+		return nil, fmt.Errorf("%w: cannot swap the inputs of a join of type %s",
+			substraitgo.ErrNotImplemented, join.joinType)
+	}
+
+	exprs := []expr.Expression{join.expr, join.postJoinFilter}
+	if slices.ContainsFunc(exprs, containsSubquery) {
+		return nil, fmt.Errorf("%w: cannot swap the inputs of a join whose condition contains a subquery",
+			substraitgo.ErrInvalidArg)
+	}
+
+	// the index of each field of the joined record in the swapped one
+	nleft, nright := len(outputType(join.left).Types), len(outputType(join.right).Types)
+	remap := make([]int32, nleft+nright)
+	for i := range remap {
+		if i < nleft {
+			remap[i] = int32(nright + i)
+		} else {
+			remap[i] = int32(i - nleft)
+		}
+	}
+
+	out.left, out.right = join.right, join.left
+	joined := out.JoinedRecordType()
+	exprs, err := remapReferences(exprs, remap, &joined)
+	if err != nil {
+		return nil, err
+	}
+	out.expr, out.postJoinFilter = exprs[0], exprs[1]
+
+	out.mapping = remap
+	if join.mapping != nil {
+		out.mapping = make([]int32, len(join.mapping))
+		for i, m := range join.mapping {
+			out.mapping[i] = remap[m]
+		}
+	}
+	return &out, nil
+}
+
+// containsSubquery reports whether e or any of its subexpressions is a
+// subquery.
+func containsSubquery(e expr.Expression) bool {
+	found := false
+	var visit expr.VisitFunc
+	visit = func(e expr.Expression) expr.Expression {
+		switch e.(type) {
+		case nil:
+			return e
+		case *expr.InPredicate, *expr.SetPredicate:
+			found = true
+		}
+		if found {
+			return e
+		}
+		return e.Visit(visit)
+	}
+	visit(e)
+	return found
+}
+
+func identityMapping(n int) []int32 {
+	out := make([]int32, n)
+	for i := range out {
+		out[i] = int32(i)
+	}
+	return out
+}
@@ -0,0 +1,205 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package plan_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	substraitgo "github.com/substrait-io/substrait-go"
+	"github.com/substrait-io/substrait-go/expr"
+	"github.com/substrait-io/substrait-go/extensions"
+	"github.com/substrait-io/substrait-go/plan"
+	"github.com/substrait-io/substrait-go/types"
+)
+
+func TestPushDownFetch(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	scan := b.NamedScan([]string{"test"}, baseSchema2)
+	ref, err := b.RootFieldRef(scan, 1)
+	require.NoError(t, err)
+	inner, err := b.Project(scan, ref)
+	require.NoError(t, err)
+	outer, err := b.Project(inner, expr.NewPrimitiveLiteral(int32(1), false))
+	require.NoError(t, err)
+	fetch, err := b.Fetch(outer, 5, 10)
+	require.NoError(t, err)
+
+	p, err := b.Plan(fetch, []string{"x", "y", "y2", "one"})
+	require.NoError(t, err)
+
+	result, err := plan.PushDownFetch(p)
+	require.NoError(t, err)
+
+	// the fetch is moved below both projections, the output is unchanged
+	root := result.GetRoots()[0].Input()
+	proj, ok := root.(*plan.ProjectRel)
+	require.True(t, ok, "expected a ProjectRel, got %T", root)
+	assert.Len(t, proj.Expressions(), 1)
+	proj, ok = proj.Input().(*plan.ProjectRel)
+	require.True(t, ok)
+	pushed, ok := proj.Input().(*plan.FetchRel)
+	require.True(t, ok, "expected a FetchRel, got %T", proj.Input())
+	assert.EqualValues(t, 5, pushed.Offset())
+	assert.EqualValues(t, 10, pushed.Count())
+	assert.Same(t, scan, pushed.Input())
+	assert.Equal(t, p.GetRoots()[0].RecordType(), result.GetRoots()[0].RecordType())
+
+	assert.Same(t, fetch, p.GetRoots()[0].Input())
+}
+
+func TestPushDownFetchNotBelowFilter(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	scan := b.NamedScan([]string{"test"}, baseSchema2)
+	ref, err := b.RootFieldRef(scan, 1)
+	require.NoError(t, err)
+	filter, err := b.Filter(scan, ref)
+	require.NoError(t, err)
+	proj, err := b.Project(filter, expr.NewPrimitiveLiteral(int32(1), false))
+	require.NoError(t, err)
+	fetch, err := b.Fetch(proj, 0, 10)
+	require.NoError(t, err)
+
+	p, err := b.Plan(fetch, []string{"x", "y", "one"})
+	require.NoError(t, err)
+
+	result, err := plan.PushDownFetch(p)
+	require.NoError(t, err)
+
+	// the fetch moves below the projection but stops at the filter
+	root, ok := result.GetRoots()[0].Input().(*plan.ProjectRel)
+	require.True(t, ok)
+	pushed, ok := root.Input().(*plan.FetchRel)
+	require.True(t, ok)
+	assert.Same(t, filter, pushed.Input())
+
+	// a fetch directly over a filter is left as is
+	fetch, err = b.Fetch(filter, 0, 10)
+	require.NoError(t, err)
+	p, err = b.Plan(fetch, []string{"x", "y"})
+	require.NoError(t, err)
+	result, err = plan.PushDownFetch(p)
+	require.NoError(t, err)
+	assert.Same(t, fetch, result.GetRoots()[0].Input())
+}
+
+func TestPruneColumns(t *testing.T) {
+	schema := types.NamedStruct{Names: []string{"a", "b", "c", "d"},
+		Struct: types.StructType{
+			Nullability: types.NullabilityRequired,
+			Types: []types.Type{
+				&types.Int32Type{Nullability: types.NullabilityRequired},
+				&types.Float32Type{Nullability: types.NullabilityRequired},
+				&types.BooleanType{Nullability: types.NullabilityRequired},
+				&types.StringType{Nullability: types.NullabilityRequired},
+			},
+		}}
+
+	b := plan.NewBuilderDefault()
+	scan := b.NamedScan([]string{"test"}, schema)
+	c, err := b.RootFieldRef(scan, 2)
+	require.NoError(t, err)
+	filter, err := b.Filter(scan, c)
+	require.NoError(t, err)
+	d, err := b.RootFieldRef(filter, 3)
+	require.NoError(t, err)
+	a, err := b.RootFieldRef(filter, 0)
+	require.NoError(t, err)
+	proj, err := b.ProjectRemap(filter, []int32{4, 5}, d, a)
+	require.NoError(t, err)
+
+	p, err := b.Plan(proj, []string{"d", "a"})
+	require.NoError(t, err)
+
+	result, err := plan.PruneColumns(p)
+	require.NoError(t, err)
+	require.NoError(t, result.Validate())
+
+	// the scan only produces a, c and d, and the references above it
+	// are updated to match without changing the output of the plan
+	root := result.GetRoots()[0]
+	assert.Equal(t, p.GetRoots()[0].RecordType(), root.RecordType())
+	pruned := root.Input().(*plan.ProjectRel)
+	assert.Equal(t, []int32{3, 4}, pruned.OutputMapping())
+	assert.Equal(t, ".field(2) => string, .field(0) => i32",
+		pruned.Expressions()[0].String()+", "+pruned.Expressions()[1].String())
+	prunedFilter := pruned.Input().(*plan.FilterRel)
+	assert.Equal(t, ".field(1) => boolean", prunedFilter.Condition().String())
+	prunedScan := prunedFilter.Input().(*plan.NamedTableReadRel)
+	assert.Equal(t, schema, prunedScan.BaseSchema())
+	assert.Len(t, prunedScan.RecordType().Types, 3)
+	assert.Equal(t, []types.Type{
+		&types.Int32Type{Nullability: types.NullabilityRequired},
+		&types.BooleanType{Nullability: types.NullabilityRequired},
+		&types.StringType{Nullability: types.NullabilityRequired},
+	}, prunedScan.RecordType().Types)
+
+	// the original plan is unchanged
+	assert.Same(t, proj, p.GetRoots()[0].Input())
+	assert.Nil(t, scan.Projection())
+
+	// the pruned plan survives a protobuf round trip
+	pb, err := result.ToProto()
+	require.NoError(t, err)
+	_, err = plan.FromProto(pb, &extensions.DefaultCollection)
+	require.NoError(t, err)
+}
+
+func TestPruneColumnsJoinUnchanged(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	p, err := b.Plan(buildJoinOverFilter(t, b), []string{"a", "x"})
+	require.NoError(t, err)
+
+	result, err := plan.PruneColumns(p)
+	require.NoError(t, err)
+	assert.Same(t, p.GetRoots()[0].Input(), result.GetRoots()[0].Input())
+}
+
+func TestSwapJoinInputs(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	join := buildJoinOverFilter(t, b)
+	j := join.(*plan.JoinRel)
+
+	swapped, err := plan.SwapJoinInputs(join)
+	require.NoError(t, err)
+	s := swapped.(*plan.JoinRel)
+	assert.Same(t, j.Right(), s.Left())
+	assert.Same(t, j.Left(), s.Right())
+	assert.Equal(t, plan.JoinTypeInner, s.Type())
+	assert.Equal(t, []int32{2, 0}, s.OutputMapping())
+	assert.Equal(t, join.Remap(join.RecordType()), swapped.Remap(swapped.RecordType()))
+
+	ref, ok := s.Expr().(*expr.FieldReference)
+	require.True(t, ok)
+	assert.Equal(t, int32(1), ref.Reference.(*expr.StructFieldRef).Field)
+
+	p, err := b.Plan(swapped, []string{"a", "x"})
+	require.NoError(t, err)
+	assert.NoError(t, p.Validate())
+
+	back, err := plan.SwapJoinInputs(swapped)
+	require.NoError(t, err)
+	assert.True(t, back.Equals(join))
+
+	left, err := b.JoinRemap(j.Left(), j.Right(), j.Expr(), plan.JoinTypeLeft, j.OutputMapping())
+	require.NoError(t, err)
+	swapped, err = plan.SwapJoinInputs(left)
+	require.NoError(t, err)
+	assert.Equal(t, plan.JoinTypeRight, swapped.(*plan.JoinRel).Type())
+	assert.Equal(t, left.Remap(left.RecordType()), swapped.Remap(swapped.RecordType()))
+}
+
+func TestSwapJoinInputsErrors(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	join := buildJoinOverFilter(t, b)
+	j := join.(*plan.JoinRel)
+
+	_, err := plan.SwapJoinInputs(j.Right())
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+
+	semi, err := b.JoinRemap(j.Left(), j.Right(), j.Expr(), plan.JoinTypeLeftSemi, []int32{0})
+	require.NoError(t, err)
+	_, err = plan.SwapJoinInputs(semi)
+	assert.ErrorIs(t, err, substraitgo.ErrNotImplemented)
+}
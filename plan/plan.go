@@ -15,6 +15,7 @@ import (
 	"github.com/substrait-io/substrait-go/proto"
 	"github.com/substrait-io/substrait-go/types"
 	"golang.org/x/exp/slices"
+	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/types/known/anypb"
 )
 
@@ -26,6 +27,10 @@ var CurrentVersion = types.Version{
 }
 
 func init() {
+	expr.RegisterRelFromProto(func(rel *proto.Rel, reg expr.ExtensionRegistry) (expr.Rel, error) {
+		return RelFromProto(rel, reg)
+	})
+
 	if info, ok := debug.ReadBuildInfo(); ok {
 		for _, dep := range info.Deps {
 			if strings.HasPrefix(dep.Path, "github.com/substrait-io/substrait-go") {
@@ -57,11 +62,18 @@ type Relation struct {
 }
 
 func (r *Relation) FromProto(p *proto.PlanRel, reg expr.ExtensionRegistry) error {
+	return r.fromProto(p, reg, nil, "")
+}
+
+// fromProto converts the plan relation like FromProto, resolving any
+// ReferenceRel against refs and reporting problems with paths starting
+// with path.
+func (r *Relation) fromProto(p *proto.PlanRel, reg expr.ExtensionRegistry, refs []Relation, path string) error {
 	r.root, r.rel = nil, nil
 
 	switch rel := p.RelType.(type) {
 	case *proto.PlanRel_Rel:
-		input, err := RelFromProto(rel.Rel, reg)
+		input, err := relFromProto(rel.Rel, reg, refs, path)
 		if err != nil {
 			return err
 		}
@@ -69,7 +81,7 @@ func (r *Relation) FromProto(p *proto.PlanRel, reg expr.ExtensionRegistry) error
 		r.rel = input
 		return nil
 	case *proto.PlanRel_Root:
-		input, err := RelFromProto(rel.Root.Input, reg)
+		input, err := relFromProto(rel.Root.Input, reg, refs, path)
 		if err != nil {
 			return err
 		}
@@ -92,6 +104,16 @@ func (r *Relation) IsRoot() bool {
 func (r *Relation) Root() *Root { return r.root }
 func (r *Relation) Rel() Rel    { return r.rel }
 
+// outputType returns the record type produced by the relation tree,
+// after applying the output mapping of its topmost relation.
+func (r *Relation) outputType() types.StructType {
+	rel := r.rel
+	if r.IsRoot() {
+		rel = r.root.input
+	}
+	return rel.Remap(rel.RecordType())
+}
+
 func (r *Relation) ToProto() *proto.PlanRel {
 	if r.IsRoot() {
 		return r.root.ToProtoPlanRel()
@@ -126,7 +148,9 @@ type Plan struct {
 	reg expr.ExtensionRegistry
 }
 
-// Version returns the substrait version of the plan.
+// Version returns the substrait version of the plan along with the
+// producer that created it. For a plan read with FromProto, this is the
+// version recorded in the protobuf plan.
 func (p *Plan) Version() Version { return p.version }
 
 // ExtensionRegistry returns the set of registered extensions for this plan
@@ -181,6 +205,31 @@ func (p *Plan) GetNonRootRelations() (rels []Rel) {
 	return rels
 }
 
+// ReferencedFunctions returns the IDs of the scalar, aggregate and window
+// functions called anywhere in the relations of the plan, including
+// within the arguments of other functions and within subqueries, such
+// as for checking that a consumer supports all of them. The functions
+// are identified by their names without the argument types of their
+// compound names, so that each function is returned once regardless of
+// how many of its variants are called. The IDs are sorted by URI and
+// then name.
+func (p *Plan) ReferencedFunctions() []extensions.ID {
+	ids := make(map[uint32]extensions.ID)
+	for _, r := range p.relations {
+		if r.IsRoot() {
+			collectFuncIDs(r.root.input, ids)
+		} else {
+			collectFuncIDs(r.rel, ids)
+		}
+	}
+
+	for anchor, id := range ids {
+		id.Name, _, _ = strings.Cut(id.Name, ":")
+		ids[anchor] = id
+	}
+	return sortedIDs(ids)
+}
+
 func FromProto(plan *proto.Plan, c *extensions.Collection) (*Plan, error) {
 	ret := &Plan{
 		version:          plan.Version,
@@ -192,19 +241,35 @@ func FromProto(plan *proto.Plan, c *extensions.Collection) (*Plan, error) {
 
 	ret.reg = expr.NewExtensionRegistry(ret.extensions, c)
 	for i, r := range plan.Relations {
-		if err := ret.relations[i].FromProto(r, ret.reg); err != nil {
+		if err := ret.relations[i].fromProto(r, ret.reg, ret.relations[:i], fmt.Sprintf("relations[%d]", i)); err != nil {
 			return nil, err
 		}
 	}
+	if err := checkReferences(ret.relations); err != nil {
+		return nil, err
+	}
 
 	return ret, nil
 }
 
+// ToProto returns the protobuf representation of the plan. The extension
+// URIs and declarations are numbered in the order they were first used
+// while building the plan, or as they were numbered in the protobuf the
+// plan was read from, and are sorted by their anchors. Serializing the
+// same plan repeatedly therefore always produces the same anchors, use
+// ToProtoWithAnchors to choose them explicitly.
+//
+// An error wrapping substraitgo.ErrInvalidArg is returned if the plan
+// contains an expr.Parameter, which must be bound with BindParameters
+// first, since there is no declared type for its serialized form.
 func (p *Plan) ToProto() (*proto.Plan, error) {
 	uris, decls := p.extensions.ToProto()
 	relations := make([]*proto.PlanRel, len(p.relations))
 	for i, r := range p.relations {
 		relations[i] = r.ToProto()
+		if err := checkBound(relations[i]); err != nil {
+			return nil, err
+		}
 	}
 	return &proto.Plan{
 		Version:            p.version,
@@ -216,6 +281,38 @@ func (p *Plan) ToProto() (*proto.Plan, error) {
 	}, nil
 }
 
+// MarshalJSON returns the protobuf JSON representation of the plan, see
+// protojson.Marshal. Enum values are written using their names. This
+// allows a plan to be passed directly to json.Marshal.
+func (p *Plan) MarshalJSON() ([]byte, error) {
+	out, err := p.ToProto()
+	if err != nil {
+		return nil, err
+	}
+	return protojson.Marshal(out)
+}
+
+// UnmarshalJSON decodes a plan from its protobuf JSON representation,
+// such as the output of MarshalJSON, resolving its extensions using the
+// provided collection like FromProto.
+//
+// Invalid JSON is reported as an error wrapping substraitgo.ErrInvalidArg
+// and the error of protojson.Unmarshal, and an extension declared by the
+// plan whose URI isn't loaded in the collection as an error wrapping
+// substraitgo.ErrNotFound.
+func UnmarshalJSON(data []byte, c *extensions.Collection) (*Plan, error) {
+	var p proto.Plan
+	if err := protojson.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("%w: invalid plan JSON: %w", substraitgo.ErrInvalidArg, err)
+	}
+
+	if uri, ok := missingExtensionURI(&p, c); ok {
+		return nil, fmt.Errorf("%w: plan declares extensions from %s, which is not loaded in the collection",
+			substraitgo.ErrNotFound, uri)
+	}
+	return FromProto(&p, c)
+}
+
 // Root is a relation with output field names.
 // This is used as the root of a Rel tree.
 type Root struct {
@@ -260,6 +357,9 @@ type Rel interface {
 	//
 	// This includes things such as Stats and Runtime constraints.
 	Hint() *Hint
+	// WithHint returns a copy of the relation with its hint replaced by
+	// the given one, leaving this relation unchanged.
+	WithHint(hint RelHint) Rel
 	// OutputMapping is optional and may be nil. If this is nil, then
 	// the result of this relation is the direct output as is (with no
 	// reordering or projection of columns). Otherwise this is a slice
@@ -270,6 +370,13 @@ type Rel interface {
 	// result should be 3 columns consisting of the 5th, 2nd and 1st
 	// output columns from the underlying relation.
 	OutputMapping() []int32
+	// WithOutputMapping returns a copy of the relation with the output
+	// mapping replaced, leaving this relation unchanged. A nil mapping
+	// makes the output of the copy direct. Each index must be within
+	// the underlying RecordType of the relation, otherwise an error
+	// wrapping substraitgo.ErrInvalidRel is returned. The output type of
+	// the copy, see Remap, reflects the new mapping.
+	WithOutputMapping(mapping []int32) (Rel, error)
 	// Remap utilizes the OutputMapping to construct the expected result
 	// record type from the output RecordType of the underlying Relation.
 	// If the output mapping is nil, then this just returns the input
@@ -278,13 +385,45 @@ type Rel interface {
 	// RecordType returns the output record type of the underlying relation
 	// as a struct type.
 	RecordType() types.StructType
+	// NamedRecordType returns the RecordType of the relation along with
+	// names for its columns, which are propagated from the base schema of
+	// the reads and table schemas through the relations above them. The
+	// outputs of joins are named by concatenating the names of their
+	// inputs, and a projected or grouping expression which references a
+	// column of the input keeps the name of that column. Columns, and
+	// nested struct fields, whose names aren't known are given positional
+	// names, such as "field_2" for the third column.
+	NamedRecordType() types.NamedStruct
+	// SelectByName returns a copy of the relation with the output mapping
+	// selecting the columns with the given names, in the given order,
+	// where the names are those of NamedRecordType. An error wrapping
+	// substraitgo.ErrInvalidArg is returned if a name doesn't match any
+	// column or matches more than one.
+	SelectByName(names []string) (Rel, error)
 
 	GetAdvancedExtension() *extensions.AdvancedExtension
+	// AdvancedExtension returns the advanced extension of the common
+	// fields of the relation, and SetAdvancedExtension replaces it.
+	AdvancedExtension() *extensions.AdvancedExtension
+	SetAdvancedExtension(*extensions.AdvancedExtension)
+
 	ToProto() *proto.Rel
 	ToProtoPlanRel() *proto.PlanRel
 
 	// Copy creates a copy of this relation with new inputs
 	Copy(newInputs ...Rel) (Rel, error)
+	// Equals reports whether this relation tree has the same content as
+	// other: the relations are of the same kinds with the same schemas,
+	// expressions, output mappings, hints, advanced extensions and other
+	// properties, and their inputs are equal in turn. The functions called
+	// by the expressions are compared by their extension IDs rather than
+	// their anchors, so the relations may come from plans with different
+	// anchors. User defined types are compared by their anchors.
+	Equals(other Rel) bool
+	// Clone returns a deep copy of this relation and its inputs, sharing
+	// no slices, schemas or modifiable expressions with it, so that the
+	// copy can be modified without affecting the original.
+	Clone() Rel
 
 	// GetInputs returns a list of zero or more inputs for this relation
 	GetInputs() []Rel
@@ -294,7 +433,46 @@ type Rel interface {
 	CopyWithExpressionRewrite(rewriteFunc RewriteFunc, newInputs ...Rel) (Rel, error)
 }
 
+// RelFromProto converts the protobuf relation tree into a Rel. Problems
+// found in the tree are reported as a *RelError identifying the
+// relation they were found in.
 func RelFromProto(rel *proto.Rel, reg expr.ExtensionRegistry) (Rel, error) {
+	return relFromProto(rel, reg, nil, "")
+}
+
+// relFromProto converts the relation tree like RelFromProto, resolving
+// any ReferenceRel within it against refs, the plan relations which
+// precede the one being converted. path is the path leading to rel,
+// which the relation and its inputs extend.
+func relFromProto(rel *proto.Rel, reg expr.ExtensionRegistry, refs []Relation, path string) (Rel, error) {
+	kind := protoRelKind(rel)
+	path = relPath(path, kind)
+
+	out, err := convertRelFromProto(rel, reg, refs, path)
+	if err != nil {
+		return nil, wrapRelError(kind, path, err)
+	}
+	return out, nil
+}
+
+// inputRelFromProto converts an input of another relation like
+// relFromProto. The output type of an input is needed to convert the
+// relation consuming it, so an output mapping which is out of range for
+// the input is reported here rather than left for Plan.Validate.
+func inputRelFromProto(rel *proto.Rel, reg expr.ExtensionRegistry, refs []Relation, path string) (Rel, error) {
+	in, err := relFromProto(rel, reg, refs, path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkOutputMapping(in); err != nil {
+		kind := protoRelKind(rel)
+		return nil, &RelError{Kind: kind, Path: relPath(path, kind), Err: err}
+	}
+	return in, nil
+}
+
+func convertRelFromProto(rel *proto.Rel, reg expr.ExtensionRegistry, refs []Relation, path string) (Rel, error) {
 	switch rel := rel.RelType.(type) {
 	case *proto.Rel_Read:
 		var out ReadRel
@@ -332,12 +510,12 @@ func RelFromProto(rel *proto.Rel, reg expr.ExtensionRegistry) (Rel, error) {
 
 		return out, nil
 	case *proto.Rel_Filter:
-		input, err := RelFromProto(rel.Filter.Input, reg)
+		input, err := inputRelFromProto(rel.Filter.Input, reg, refs, inputPath(path, 0))
 		if err != nil {
 			return nil, fmt.Errorf("error getting input to FilterRel: %w", err)
 		}
 
-		base := input.RecordType()
+		base := input.Remap(input.RecordType())
 		cond, err := expr.ExprFromProto(rel.Filter.Condition, &base, reg)
 		if err != nil {
 			return nil, fmt.Errorf("error getting condition for FilterRel: %w", err)
@@ -352,7 +530,7 @@ func RelFromProto(rel *proto.Rel, reg expr.ExtensionRegistry) (Rel, error) {
 
 		return out, nil
 	case *proto.Rel_Fetch:
-		input, err := RelFromProto(rel.Fetch.Input, reg)
+		input, err := inputRelFromProto(rel.Fetch.Input, reg, refs, inputPath(path, 0))
 		if err != nil {
 			return nil, fmt.Errorf("error getting input to FetchRel: %w", err)
 		}
@@ -368,12 +546,12 @@ func RelFromProto(rel *proto.Rel, reg expr.ExtensionRegistry) (Rel, error) {
 		}
 		return out, nil
 	case *proto.Rel_Aggregate:
-		input, err := RelFromProto(rel.Aggregate.Input, reg)
+		input, err := inputRelFromProto(rel.Aggregate.Input, reg, refs, inputPath(path, 0))
 		if err != nil {
 			return nil, fmt.Errorf("error getting input to AggregateRel: %w", err)
 		}
 
-		base := input.RecordType()
+		base := input.Remap(input.RecordType())
 		groups := make([][]expr.Expression, len(rel.Aggregate.Groupings))
 		for i, g := range rel.Aggregate.Groupings {
 			groups[i] = make([]expr.Expression, len(g.GroupingExpressions))
@@ -410,12 +588,12 @@ func RelFromProto(rel *proto.Rel, reg expr.ExtensionRegistry) (Rel, error) {
 		out.fromProtoCommon(rel.Aggregate.Common)
 		return out, nil
 	case *proto.Rel_Sort:
-		input, err := RelFromProto(rel.Sort.Input, reg)
+		input, err := inputRelFromProto(rel.Sort.Input, reg, refs, inputPath(path, 0))
 		if err != nil {
 			return nil, fmt.Errorf("error getting input to SortRel: %w", err)
 		}
 
-		base := input.RecordType()
+		base := input.Remap(input.RecordType())
 		sorts := make([]expr.SortField, len(rel.Sort.Sorts))
 		for i, s := range rel.Sort.Sorts {
 			sorts[i], err = expr.SortFieldFromProto(s, &base, reg)
@@ -440,12 +618,12 @@ func RelFromProto(rel *proto.Rel, reg expr.ExtensionRegistry) (Rel, error) {
 			return nil, fmt.Errorf("%w: JoinRel must not have unspecified join type", substraitgo.ErrInvalidRel)
 		}
 
-		left, err := RelFromProto(rel.Join.Left, reg)
+		left, err := inputRelFromProto(rel.Join.Left, reg, refs, inputPath(path, 0))
 		if err != nil {
 			return nil, fmt.Errorf("error getting left input to JoinRel: %w", err)
 		}
 
-		right, err := RelFromProto(rel.Join.Right, reg)
+		right, err := inputRelFromProto(rel.Join.Right, reg, refs, inputPath(path, 1))
 		if err != nil {
 			return nil, fmt.Errorf("error getting right input to JoinRel: %w", err)
 		}
@@ -473,12 +651,12 @@ func RelFromProto(rel *proto.Rel, reg expr.ExtensionRegistry) (Rel, error) {
 
 		return out, nil
 	case *proto.Rel_Project:
-		input, err := RelFromProto(rel.Project.Input, reg)
+		input, err := inputRelFromProto(rel.Project.Input, reg, refs, inputPath(path, 0))
 		if err != nil {
 			return nil, fmt.Errorf("error getting input to ProjectRel: %w", err)
 		}
 
-		baseSchema := input.RecordType()
+		baseSchema := input.Remap(input.RecordType())
 
 		exprs := make([]expr.Expression, len(rel.Project.Expressions))
 		for i, e := range rel.Project.Expressions {
@@ -508,7 +686,7 @@ func RelFromProto(rel *proto.Rel, reg expr.ExtensionRegistry) (Rel, error) {
 
 		var err error
 		for i, r := range rel.Set.Inputs {
-			inputs[i], err = RelFromProto(r, reg)
+			inputs[i], err = inputRelFromProto(r, reg, refs, inputPath(path, i))
 			if err != nil {
 				return nil, fmt.Errorf("error getting input %d for SetRel: %w", i, err)
 			}
@@ -536,7 +714,7 @@ func RelFromProto(rel *proto.Rel, reg expr.ExtensionRegistry) (Rel, error) {
 
 		return out, nil
 	case *proto.Rel_ExtensionSingle:
-		input, err := RelFromProto(rel.ExtensionSingle.Input, reg)
+		input, err := inputRelFromProto(rel.ExtensionSingle.Input, reg, refs, inputPath(path, 0))
 		if err != nil {
 			return nil, fmt.Errorf("error getting input to ExtensionSingle: %w", err)
 		}
@@ -552,7 +730,7 @@ func RelFromProto(rel *proto.Rel, reg expr.ExtensionRegistry) (Rel, error) {
 		inputs := make([]Rel, len(rel.ExtensionMulti.Inputs))
 		var err error
 		for i, r := range rel.ExtensionMulti.Inputs {
-			inputs[i], err = RelFromProto(r, reg)
+			inputs[i], err = inputRelFromProto(r, reg, refs, inputPath(path, i))
 			if err != nil {
 				return nil, fmt.Errorf("error getting input %d for ExtensionMultiRel: %w", i, err)
 			}
@@ -573,12 +751,12 @@ func RelFromProto(rel *proto.Rel, reg expr.ExtensionRegistry) (Rel, error) {
 
 		return out, nil
 	case *proto.Rel_Cross:
-		left, err := RelFromProto(rel.Cross.Left, reg)
+		left, err := inputRelFromProto(rel.Cross.Left, reg, refs, inputPath(path, 0))
 		if err != nil {
 			return nil, fmt.Errorf("error getting left input to CrossRel: %w", err)
 		}
 
-		right, err := RelFromProto(rel.Cross.Right, reg)
+		right, err := inputRelFromProto(rel.Cross.Right, reg, refs, inputPath(path, 1))
 		if err != nil {
 			return nil, fmt.Errorf("error getting right input to CrossRel: %w", err)
 		}
@@ -591,12 +769,12 @@ func RelFromProto(rel *proto.Rel, reg expr.ExtensionRegistry) (Rel, error) {
 		out.fromProtoCommon(rel.Cross.Common)
 		return out, nil
 	case *proto.Rel_HashJoin:
-		left, err := RelFromProto(rel.HashJoin.Left, reg)
+		left, err := inputRelFromProto(rel.HashJoin.Left, reg, refs, inputPath(path, 0))
 		if err != nil {
 			return nil, fmt.Errorf("error getting left input to HashJoinRel: %w", err)
 		}
 
-		right, err := RelFromProto(rel.HashJoin.Right, reg)
+		right, err := inputRelFromProto(rel.HashJoin.Right, reg, refs, inputPath(path, 1))
 		if err != nil {
 			return nil, fmt.Errorf("error getting right input to HashJoin: %w", err)
 		}
@@ -606,7 +784,7 @@ func RelFromProto(rel *proto.Rel, reg expr.ExtensionRegistry) (Rel, error) {
 				substraitgo.ErrInvalidRel, len(rel.HashJoin.LeftKeys), len(rel.HashJoin.RightKeys))
 		}
 
-		leftBase, rightBase := left.RecordType(), right.RecordType()
+		leftBase, rightBase := left.Remap(left.RecordType()), right.Remap(right.RecordType())
 
 		leftKeys := make([]*expr.FieldReference, len(rel.HashJoin.LeftKeys))
 		for i, k := range rel.HashJoin.LeftKeys {
@@ -639,7 +817,7 @@ func RelFromProto(rel *proto.Rel, reg expr.ExtensionRegistry) (Rel, error) {
 		out.fromProtoCommon(rel.HashJoin.Common)
 
 		if rel.HashJoin.PostJoinFilter != nil {
-			base := out.RecordType()
+			base := out.JoinedRecordType()
 			out.postJoinFilter, err = expr.ExprFromProto(rel.HashJoin.PostJoinFilter, &base, reg)
 			if err != nil {
 				return nil, fmt.Errorf("error getting post join filter for HashJoinRel: %w", err)
@@ -648,14 +826,14 @@ func RelFromProto(rel *proto.Rel, reg expr.ExtensionRegistry) (Rel, error) {
 
 		return out, nil
 	case *proto.Rel_MergeJoin:
-		left, err := RelFromProto(rel.MergeJoin.Left, reg)
+		left, err := inputRelFromProto(rel.MergeJoin.Left, reg, refs, inputPath(path, 0))
 		if err != nil {
 			return nil, fmt.Errorf("error getting left input to MergeJoinRel: %w", err)
 		}
 
-		right, err := RelFromProto(rel.MergeJoin.Right, reg)
+		right, err := inputRelFromProto(rel.MergeJoin.Right, reg, refs, inputPath(path, 1))
 		if err != nil {
-			return nil, fmt.Errorf("error getting right input to HashJoin: %w", err)
+			return nil, fmt.Errorf("error getting right input to MergeJoinRel: %w", err)
 		}
 
 		if len(rel.MergeJoin.LeftKeys) != len(rel.MergeJoin.RightKeys) {
@@ -663,7 +841,7 @@ func RelFromProto(rel *proto.Rel, reg expr.ExtensionRegistry) (Rel, error) {
 				substraitgo.ErrInvalidRel, len(rel.MergeJoin.LeftKeys), len(rel.MergeJoin.RightKeys))
 		}
 
-		leftBase, rightBase := left.RecordType(), right.RecordType()
+		leftBase, rightBase := left.Remap(left.RecordType()), right.Remap(right.RecordType())
 
 		leftKeys := make([]*expr.FieldReference, len(rel.MergeJoin.LeftKeys))
 		for i, k := range rel.MergeJoin.LeftKeys {
@@ -685,7 +863,7 @@ func RelFromProto(rel *proto.Rel, reg expr.ExtensionRegistry) (Rel, error) {
 			return nil, fmt.Errorf("%w: must have same number of keys in left and right keys for merge join", substraitgo.ErrInvalidRel)
 		}
 
-		out := &HashJoinRel{
+		out := &MergeJoinRel{
 			left:         left,
 			right:        right,
 			leftKeys:     leftKeys,
@@ -696,7 +874,7 @@ func RelFromProto(rel *proto.Rel, reg expr.ExtensionRegistry) (Rel, error) {
 		out.fromProtoCommon(rel.MergeJoin.Common)
 
 		if rel.MergeJoin.PostJoinFilter != nil {
-			base := out.RecordType()
+			base := out.JoinedRecordType()
 			out.postJoinFilter, err = expr.ExprFromProto(rel.MergeJoin.PostJoinFilter, &base, reg)
 			if err != nil {
 				return nil, fmt.Errorf("error getting post join filter for MergeJoin: %w", err)
@@ -704,6 +882,187 @@ func RelFromProto(rel *proto.Rel, reg expr.ExtensionRegistry) (Rel, error) {
 		}
 
 		return out, nil
+	case *proto.Rel_NestedLoopJoin:
+		left, err := inputRelFromProto(rel.NestedLoopJoin.Left, reg, refs, inputPath(path, 0))
+		if err != nil {
+			return nil, fmt.Errorf("error getting left input to NestedLoopJoinRel: %w", err)
+		}
+
+		right, err := inputRelFromProto(rel.NestedLoopJoin.Right, reg, refs, inputPath(path, 1))
+		if err != nil {
+			return nil, fmt.Errorf("error getting right input to NestedLoopJoinRel: %w", err)
+		}
+
+		out := &NestedLoopJoinRel{
+			left:         left,
+			right:        right,
+			joinType:     HashMergeJoinType(rel.NestedLoopJoin.Type).JoinType(),
+			advExtension: rel.NestedLoopJoin.AdvancedExtension,
+		}
+		out.fromProtoCommon(rel.NestedLoopJoin.Common)
+
+		if rel.NestedLoopJoin.Expression != nil {
+			base := out.JoinedRecordType()
+			out.expr, err = expr.ExprFromProto(rel.NestedLoopJoin.Expression, &base, reg)
+			if err != nil {
+				return nil, fmt.Errorf("error getting expression for NestedLoopJoinRel: %w", err)
+			}
+		}
+
+		return out, nil
+	case *proto.Rel_Write:
+		named, ok := rel.Write.WriteType.(*proto.WriteRel_NamedTable)
+		if !ok {
+			return nil, fmt.Errorf("%w: unsupported write type %T for WriteRel",
+				substraitgo.ErrNotImplemented, rel.Write.WriteType)
+		}
+
+		input, err := inputRelFromProto(rel.Write.Input, reg, refs, inputPath(path, 0))
+		if err != nil {
+			return nil, fmt.Errorf("error getting input to WriteRel: %w", err)
+		}
+
+		out := &NamedTableWriteRel{
+			input:        input,
+			names:        named.NamedTable.Names,
+			tableSchema:  types.NewNamedStructFromProto(rel.Write.TableSchema),
+			op:           rel.Write.Op,
+			output:       rel.Write.Output,
+			advExtension: named.NamedTable.AdvancedExtension,
+		}
+		out.fromProtoCommon(rel.Write.Common)
+
+		return out, nil
+	case *proto.Rel_Ddl:
+		named, ok := rel.Ddl.WriteType.(*proto.DdlRel_NamedObject)
+		if !ok {
+			return nil, fmt.Errorf("%w: unsupported write type %T for DdlRel",
+				substraitgo.ErrNotImplemented, rel.Ddl.WriteType)
+		}
+
+		out := &DdlRel{
+			names:        named.NamedObject.Names,
+			tableSchema:  types.NewNamedStructFromProto(rel.Ddl.TableSchema),
+			object:       rel.Ddl.Object,
+			op:           rel.Ddl.Op,
+			advExtension: named.NamedObject.AdvancedExtension,
+		}
+		out.fromProtoCommon(rel.Ddl.Common)
+
+		if rel.Ddl.TableDefaults != nil {
+			out.tableDefaults = expr.StructLiteralFromProto(rel.Ddl.TableDefaults)
+		}
+
+		if rel.Ddl.ViewDefinition != nil {
+			def, err := inputRelFromProto(rel.Ddl.ViewDefinition, reg, refs, inputPath(path, 0))
+			if err != nil {
+				return nil, fmt.Errorf("error getting view definition of DdlRel: %w", err)
+			}
+			out.viewDefinition = def
+		}
+
+		return out, nil
+	case *proto.Rel_Exchange:
+		input, err := inputRelFromProto(rel.Exchange.Input, reg, refs, inputPath(path, 0))
+		if err != nil {
+			return nil, fmt.Errorf("error getting input to ExchangeRel: %w", err)
+		}
+
+		out := &ExchangeRel{
+			input:          input,
+			partitionCount: rel.Exchange.PartitionCount,
+			targets:        rel.Exchange.Targets,
+			advExtension:   rel.Exchange.AdvancedExtension,
+		}
+		out.fromProtoCommon(rel.Exchange.Common)
+
+		base := out.RecordType()
+		switch kind := rel.Exchange.ExchangeKind.(type) {
+		case *proto.ExchangeRel_ScatterByFields:
+			fields := make([]int32, len(kind.ScatterByFields.Fields))
+			for i, f := range kind.ScatterByFields.Fields {
+				sf := f.GetDirectReference().GetStructField()
+				if f.GetRootReference() == nil || sf == nil || sf.Child != nil {
+					return nil, fmt.Errorf("%w: ExchangeRel scatter field %d must be a direct reference to a field of the input",
+						substraitgo.ErrNotImplemented, i)
+				}
+				fields[i] = sf.Field
+			}
+			out.scheme = &ExchangeScatterByFields{Fields: fields}
+		case *proto.ExchangeRel_SingleTarget:
+			e, err := expr.ExprFromProto(kind.SingleTarget.Expression, &base, reg)
+			if err != nil {
+				return nil, fmt.Errorf("error getting single target expression for ExchangeRel: %w", err)
+			}
+			out.scheme = &ExchangeSingleTarget{Expr: e}
+		case *proto.ExchangeRel_MultiTarget:
+			e, err := expr.ExprFromProto(kind.MultiTarget.Expression, &base, reg)
+			if err != nil {
+				return nil, fmt.Errorf("error getting multi target expression for ExchangeRel: %w", err)
+			}
+			out.scheme = &ExchangeMultiTarget{Expr: e,
+				ConstrainedToCount: kind.MultiTarget.ConstrainedToCount}
+		case *proto.ExchangeRel_RoundRobin_:
+			out.scheme = &ExchangeRoundRobin{Exact: kind.RoundRobin.Exact}
+		case *proto.ExchangeRel_Broadcast_:
+			out.scheme = &ExchangeBroadcast{}
+		default:
+			return nil, fmt.Errorf("%w: missing exchange kind for ExchangeRel", substraitgo.ErrInvalidRel)
+		}
+
+		return out, nil
+	case *proto.Rel_Expand:
+		input, err := inputRelFromProto(rel.Expand.Input, reg, refs, inputPath(path, 0))
+		if err != nil {
+			return nil, fmt.Errorf("error getting input to ExpandRel: %w", err)
+		}
+
+		base := input.Remap(input.RecordType())
+		fields := make([]ExpandField, len(rel.Expand.Fields))
+		for i, f := range rel.Expand.Fields {
+			switch ft := f.FieldType.(type) {
+			case *proto.ExpandRel_ExpandField_SwitchingField:
+				dups := make([]expr.Expression, len(ft.SwitchingField.Duplicates))
+				for j, d := range ft.SwitchingField.Duplicates {
+					if dups[j], err = expr.ExprFromProto(d, &base, reg); err != nil {
+						return nil, fmt.Errorf("error getting duplicate %d of field %d for ExpandRel: %w", j, i, err)
+					}
+				}
+				if len(dups) == 0 {
+					return nil, fmt.Errorf("%w: switching field %d of ExpandRel has no duplicates",
+						substraitgo.ErrInvalidRel, i)
+				}
+				fields[i] = &SwitchingField{Duplicates: dups}
+			case *proto.ExpandRel_ExpandField_ConsistentField:
+				e, err := expr.ExprFromProto(ft.ConsistentField, &base, reg)
+				if err != nil {
+					return nil, fmt.Errorf("error getting field %d for ExpandRel: %w", i, err)
+				}
+				fields[i] = &ConsistentField{Expr: e}
+			default:
+				return nil, fmt.Errorf("%w: missing field type for field %d of ExpandRel",
+					substraitgo.ErrInvalidRel, i)
+			}
+		}
+
+		out := &ExpandRel{
+			input:  input,
+			fields: fields,
+		}
+		out.fromProtoCommon(rel.Expand.Common)
+
+		return out, nil
+	case *proto.Rel_Reference:
+		ordinal := rel.Reference.SubtreeOrdinal
+		if ordinal < 0 || int(ordinal) >= len(refs) {
+			return nil, fmt.Errorf("%w: ReferenceRel subtree ordinal %d must refer to one of the %d preceding plan relations",
+				substraitgo.ErrInvalidRel, ordinal, len(refs))
+		}
+
+		return &ReferenceRel{
+			ordinal:    ordinal,
+			recordType: refs[ordinal].outputType(),
+		}, nil
 	case nil:
 		return nil, fmt.Errorf("%w: got nil", substraitgo.ErrInvalidRel)
 	}
@@ -5,6 +5,8 @@ package plan_test
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -15,8 +17,11 @@ import (
 	"github.com/substrait-io/substrait-go/plan"
 	substraitproto "github.com/substrait-io/substrait-go/proto"
 	"github.com/substrait-io/substrait-go/types"
+	"github.com/substrait-io/substrait-go/types/parser"
 	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
 const versionStruct = `"version": {
@@ -119,6 +124,25 @@ func checkRoundTrip(t *testing.T, expectedJSON string, p *plan.Plan) {
 
 	assert.Truef(t, proto.Equal(protoPlan, roundTripProto), "plan expected: %s\ngot: %s",
 		protojson.Format(protoPlan), protojson.Format(roundTripProto))
+
+	checkJSONRoundTrip(t, expectedJSON)
+}
+
+func checkJSONRoundTrip(t *testing.T, expectedJSON string) {
+	t.Helper()
+	var expectedProto substraitproto.Plan
+	require.NoError(t, protojson.Unmarshal([]byte(expectedJSON), &expectedProto))
+
+	p, err := plan.UnmarshalJSON([]byte(expectedJSON), &extensions.DefaultCollection)
+	require.NoError(t, err)
+
+	data, err := p.MarshalJSON()
+	require.NoError(t, err)
+
+	var actualProto substraitproto.Plan
+	require.NoError(t, protojson.Unmarshal(data, &actualProto))
+	assert.Truef(t, proto.Equal(&expectedProto, &actualProto), "JSON expected: %s\ngot: %s",
+		expectedJSON, data)
 }
 
 func TestAggregateRelPlan(t *testing.T) {
@@ -210,6 +234,132 @@ func TestAggregateRelPlan(t *testing.T) {
 	checkRoundTrip(t, expectedJSON, p)
 }
 
+func TestReferencedFunctions(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	scan := b.NamedScan([]string{"test"}, baseSchema)
+	ref := expr.MustExpr(b.RootFieldRef(scan, 1))
+	add, err := b.ScalarFn(arithmeticURI, "add", nil, ref, ref)
+	require.NoError(t, err)
+	gt, err := b.ScalarFn(extensions.SubstraitDefaultURIPrefix+"functions_comparison.yaml", "gt", nil,
+		add, expr.NewPrimitiveLiteral(float32(1), false))
+	require.NoError(t, err)
+	filter, err := b.Filter(scan, gt)
+	require.NoError(t, err)
+	project, err := b.Project(filter, add)
+	require.NoError(t, err)
+	aggCount, err := b.AggregateFn(extensions.SubstraitDefaultURIPrefix+"functions_aggregate_generic.yaml",
+		"count", nil)
+	require.NoError(t, err)
+	agg, err := b.AggregateColumns(project, []plan.AggRelMeasure{b.Measure(aggCount, nil)}, 0)
+	require.NoError(t, err)
+
+	p, err := b.Plan(agg, []string{"val", "cnt"})
+	require.NoError(t, err)
+	assert.Equal(t, []extensions.ID{
+		{URI: extensions.SubstraitDefaultURIPrefix + "functions_aggregate_generic.yaml", Name: "count"},
+		{URI: arithmeticURI, Name: "add"},
+		{URI: extensions.SubstraitDefaultURIPrefix + "functions_comparison.yaml", Name: "gt"},
+	}, p.ReferencedFunctions())
+}
+
+func TestBindParameters(t *testing.T) {
+	const comparisonURI = extensions.SubstraitDefaultURIPrefix + "functions_comparison.yaml"
+	b := plan.NewBuilderDefault()
+	scan := b.NamedScan([]string{"test"}, baseSchema)
+	fp32Param, err := expr.NewParameter(0, &types.Float32Type{Nullability: types.NullabilityNullable})
+	require.NoError(t, err)
+	strParam, err := expr.NewParameter(1, &types.StringType{Nullability: types.NullabilityNullable})
+	require.NoError(t, err)
+
+	gt, err := b.ScalarFn(comparisonURI, "gt", nil, expr.MustExpr(b.RootFieldRef(scan, 1)), fp32Param)
+	require.NoError(t, err)
+	eq, err := b.ScalarFn(comparisonURI, "equal", nil, expr.MustExpr(b.RootFieldRef(scan, 0)), strParam)
+	require.NoError(t, err)
+	cond, err := b.ScalarFn(extensions.SubstraitDefaultURIPrefix+"functions_boolean.yaml", "and", nil, gt, eq)
+	require.NoError(t, err)
+	filter, err := b.Filter(scan, cond)
+	require.NoError(t, err)
+	p, err := b.Plan(filter, []string{"a", "b"})
+	require.NoError(t, err)
+
+	bound, err := plan.BindParameters(p, []expr.Literal{
+		expr.NewPrimitiveLiteral(float32(1.5), false),
+		expr.NewPrimitiveLiteral("foo", false),
+	})
+	require.NoError(t, err)
+	boundCond := bound.GetRoots()[0].Input().(*plan.FilterRel).Condition().String()
+	assert.Contains(t, boundCond, "fp32(1.5)")
+	assert.Contains(t, boundCond, "string(foo)")
+	assert.NotContains(t, boundCond, "$")
+
+	// the original plan keeps its parameters
+	assert.Same(t, cond, p.GetRoots()[0].Input().(*plan.FilterRel).Condition())
+
+	_, err = plan.BindParameters(p, []expr.Literal{
+		expr.NewPrimitiveLiteral(int32(1), false),
+		expr.NewPrimitiveLiteral("foo", false),
+	})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "cannot bind value of type i32 to parameter 0 of type fp32?")
+
+	_, err = plan.BindParameters(p, []expr.Literal{expr.NewPrimitiveLiteral(float32(1.5), false)})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "no value for parameter 1, got 1 values")
+}
+
+func TestBindParametersMeasuresAndSubqueries(t *testing.T) {
+	const comparisonURI = extensions.SubstraitDefaultURIPrefix + "functions_comparison.yaml"
+	b := plan.NewBuilderDefault()
+	scan := b.NamedScan([]string{"test"}, baseSchema)
+	fp32Param, err := expr.NewParameter(0, &types.Float32Type{Nullability: types.NullabilityNullable})
+	require.NoError(t, err)
+	boolParam, err := expr.NewParameter(1, &types.BooleanType{Nullability: types.NullabilityNullable})
+	require.NoError(t, err)
+
+	sum, err := b.AggregateFn(extensions.SubstraitDefaultURIPrefix+"functions_arithmetic.yaml", "sum", nil, fp32Param)
+	require.NoError(t, err)
+	agg, err := b.AggregateColumns(scan, []plan.AggRelMeasure{b.Measure(sum, boolParam)}, 0)
+	require.NoError(t, err)
+
+	// a parameter within the relation of a subquery
+	subScan := b.NamedScan([]string{"sub"}, baseSchema)
+	gt, err := b.ScalarFn(comparisonURI, "gt", nil, expr.MustExpr(b.RootFieldRef(subScan, 1)), fp32Param)
+	require.NoError(t, err)
+	subFilter, err := b.Filter(subScan, gt)
+	require.NoError(t, err)
+	exists, err := expr.NewSetPredicate(expr.SetPredicateOpExists, subFilter)
+	require.NoError(t, err)
+	filter, err := b.Filter(agg, exists)
+	require.NoError(t, err)
+	p, err := b.Plan(filter, []string{"a", "sum"})
+	require.NoError(t, err)
+
+	// a plan with unbound parameters can't be serialized
+	_, err = p.ToProto()
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "parameter 0 of type fp32? is not bound")
+
+	bound, err := plan.BindParameters(p, []expr.Literal{
+		expr.NewPrimitiveLiteral(float32(1.5), false),
+		expr.NewPrimitiveLiteral(true, false),
+	})
+	require.NoError(t, err)
+	boundFilter := bound.GetRoots()[0].Input().(*plan.FilterRel)
+	boundAgg := boundFilter.Input().(*plan.AggregateRel)
+	measure := boundAgg.Measures()[0]
+	assert.Equal(t, "sum(fp32(1.5)) => fp64?", measure.Measure().String())
+	assert.Equal(t, "boolean(true)", measure.Filter().String())
+
+	boundSub := boundFilter.Condition().(*expr.SetPredicate).Tuples.(*plan.FilterRel)
+	assert.Contains(t, boundSub.Condition().String(), "fp32(1.5)")
+
+	_, err = bound.ToProto()
+	assert.NoError(t, err)
+
+	// the original plan keeps its parameters
+	assert.Same(t, agg, p.GetRoots()[0].Input().(*plan.FilterRel).Input())
+}
+
 func TestAggregateNoGrouping(t *testing.T) {
 	b := plan.NewBuilderDefault()
 	aggCount, err := b.AggregateFn(extensions.SubstraitDefaultURIPrefix+"functions_aggregate_generic.yaml",
@@ -225,6 +375,30 @@ func TestAggregateNoGrouping(t *testing.T) {
 	assert.Equal(t, "NSTRUCT<cnt: i64>", p.GetRoots()[0].RecordType().String())
 }
 
+func TestAggregateGroupingOnly(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	scan := b.NamedScan([]string{"test"}, baseSchema)
+
+	distinct, err := b.AggregateColumns(scan, nil, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "struct<string>", outputString(distinct))
+
+	p, err := b.Plan(distinct, []string{"val"})
+	require.NoError(t, err)
+	assert.Equal(t, "NSTRUCT<val: string>", p.GetRoots()[0].RecordType().String())
+	require.NoError(t, p.Validate())
+
+	protoPlan, err := p.ToProto()
+	require.NoError(t, err)
+	agg := protoPlan.Relations[0].GetRoot().Input.GetAggregate()
+	require.NotNil(t, agg)
+	assert.Empty(t, agg.Measures)
+
+	roundTrip, err := plan.FromProto(protoPlan, &extensions.DefaultCollection)
+	require.NoError(t, err)
+	assert.Equal(t, "NSTRUCT<val: string>", roundTrip.GetRoots()[0].RecordType().String())
+}
+
 func TestAggregateRelErrors(t *testing.T) {
 	b := plan.NewBuilderDefault()
 	_, err := b.AggregateColumns(nil, nil)
@@ -456,6 +630,41 @@ func TestFetchRelErrors(t *testing.T) {
 	_, err = b.FetchRemap(scan, 0, 0, []int32{2})
 	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
 	assert.ErrorContains(t, err, "output mapping index out of range")
+
+	_, err = b.Fetch(scan, -1, 10)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "fetch offset must not be negative, got -1")
+
+	_, err = b.Fetch(scan, 0, -2)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "fetch count must not be negative, got -2")
+}
+
+func TestFetchCountAll(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	scan := b.NamedScan([]string{"test"}, baseSchema)
+
+	fetch, err := b.Fetch(scan, 10, plan.FetchCountAll)
+	require.NoError(t, err)
+	assert.EqualValues(t, 10, fetch.Offset())
+	assert.Equal(t, plan.FetchCountAll, fetch.Count())
+
+	p, err := b.Plan(fetch, []string{"a", "b"})
+	require.NoError(t, err)
+
+	protoPlan, err := p.ToProto()
+	require.NoError(t, err)
+	assert.EqualValues(t, -1, protoPlan.Relations[0].GetRoot().Input.GetFetch().Count)
+
+	roundTrip, err := plan.FromProto(protoPlan, &extensions.DefaultCollection)
+	require.NoError(t, err)
+	rt := roundTrip.GetRoots()[0].Input().(*plan.FetchRel)
+	assert.EqualValues(t, 10, rt.Offset())
+	assert.Equal(t, plan.FetchCountAll, rt.Count())
+
+	text, err := plan.ToText(p)
+	require.NoError(t, err)
+	assert.Contains(t, text, "fetch offset=10 count=ALL\n")
 }
 
 func TestFilterRelation(t *testing.T) {
@@ -732,6 +941,114 @@ func TestJoinAndFilterRelation(t *testing.T) {
 	checkRoundTrip(t, expectedJSON, p)
 }
 
+func TestEquiJoinCondition(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	left := b.NamedScan([]string{"test"}, baseSchema)
+	right := b.NamedScan([]string{"test2"}, types.NamedStruct{
+		Names: []string{"x", "y"},
+		Struct: types.StructType{
+			Nullability: types.NullabilityRequired,
+			Types: []types.Type{
+				&types.Float32Type{Nullability: types.NullabilityNullable},
+				&types.StringType{Nullability: types.NullabilityRequired},
+			},
+		},
+	})
+
+	cond, err := b.EquiJoinCondition(left, right, []int32{0, 1}, []int32{1, 0})
+	require.NoError(t, err)
+	assert.Equal(t, "and(equal(.field(0) => string, .field(3) => string) => boolean, "+
+		"equal(.field(1) => fp32, .field(2) => fp32?) => boolean?) => boolean?", cond.String())
+
+	join, err := b.Join(left, right, cond, plan.JoinTypeInner)
+	require.NoError(t, err)
+	p, err := b.Plan(join, []string{"a", "b", "x", "y"})
+	require.NoError(t, err)
+	checkRelRoundTrip(t, p)
+
+	single, err := b.EquiJoinCondition(left, right, []int32{1}, []int32{0})
+	require.NoError(t, err)
+	assert.Equal(t, "equal", single.(*expr.ScalarFunction).Name())
+
+	_, err = b.EquiJoinCondition(left, right, []int32{0, 1}, []int32{1})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "got 2 and 1")
+	_, err = b.EquiJoinCondition(left, right, []int32{2}, []int32{0})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "left key 2 out of range")
+	_, err = b.EquiJoinCondition(left, right, []int32{0}, []int32{0})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "cannot compare left key 0 of type string to right key 0 of type fp32?")
+}
+
+func TestNamedRecordType(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	scan := b.NamedScan([]string{"test"}, baseSchema)
+
+	cond, err := b.ScalarFn(extensions.SubstraitDefaultURIPrefix+"functions_comparison.yaml", "is_not_null", nil,
+		expr.MustExpr(b.RootFieldRef(scan, 0)))
+	require.NoError(t, err)
+	filter, err := b.Filter(scan, cond)
+	require.NoError(t, err)
+	assert.Equal(t, "NSTRUCT<a: string, b: fp32>", filter.NamedRecordType().String())
+
+	project, err := b.ProjectRemap(filter, []int32{2, 1, 3},
+		expr.MustExpr(b.RootFieldRef(filter, 1)), expr.NewPrimitiveLiteral(int64(1), false))
+	require.NoError(t, err)
+	assert.Equal(t, "NSTRUCT<a: string, b: fp32, b: fp32, field_3: i64>", project.NamedRecordType().String())
+
+	join, err := b.Join(project, b.NamedScan([]string{"test2"}, baseSchema2),
+		expr.NewPrimitiveLiteral(true, false), plan.JoinTypeLeft)
+	require.NoError(t, err)
+	assert.Equal(t, "NSTRUCT<b: fp32, b: fp32, field_3: i64, x: i32?, y: boolean?>", join.NamedRecordType().String())
+}
+
+func TestSelectByName(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	scan, err := b.NamedScanSelect([]string{"test"}, baseSchema, []string{"b", "a"})
+	require.NoError(t, err)
+	assert.Equal(t, []int32{1, 0}, scan.OutputMapping())
+	remapped := scan.Remap(scan.RecordType())
+	assert.Equal(t, "struct<fp32, string>", remapped.String())
+
+	_, err = b.NamedScanSelect([]string{"test"}, baseSchema, []string{"c"})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, `no column named "c"`)
+
+	cross, err := b.Cross(b.NamedScan([]string{"test"}, baseSchema), b.NamedScan([]string{"test"}, baseSchema))
+	require.NoError(t, err)
+	_, err = cross.SelectByName([]string{"a"})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, `column name "a" is ambiguous, matches columns 0 and 2`)
+
+	project, err := b.Project(b.NamedScan([]string{"test"}, baseSchema), expr.NewPrimitiveLiteral(int64(1), false))
+	require.NoError(t, err)
+	selected, err := project.SelectByName([]string{"field_2", "a"})
+	require.NoError(t, err)
+	remapped = selected.Remap(selected.RecordType())
+	assert.Equal(t, "struct<i64, string>", remapped.String())
+	assert.Nil(t, project.OutputMapping())
+}
+
+func TestRelHint(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	scan := b.NamedScan([]string{"test"}, baseSchema)
+	hinted := scan.WithHint(plan.RelHint{RowCount: 1000, RecordSize: 24, Alias: "t"})
+	assert.Nil(t, scan.Hint())
+
+	p, err := b.Plan(hinted, []string{"a", "b"})
+	require.NoError(t, err)
+
+	rt := checkRelRoundTrip(t, p)
+	require.NotNil(t, rt.Hint())
+	assert.Equal(t, float64(1000), rt.Hint().GetStats().GetRowCount())
+	assert.Equal(t, plan.RelHint{RowCount: 1000, RecordSize: 24, Alias: "t"}, plan.RelHintFromProto(rt.Hint()))
+
+	noStats := plan.RelHint{Alias: "t"}.ToProto()
+	assert.Nil(t, noStats.Stats)
+	assert.Equal(t, plan.RelHint{}, plan.RelHintFromProto(nil))
+}
+
 func TestJoinRelationError(t *testing.T) {
 	b := plan.NewBuilderDefault()
 	left := b.NamedScan([]string{"test"}, baseSchema)
@@ -1308,6 +1625,55 @@ func TestProjectErrors(t *testing.T) {
 	assert.NoError(t, err, "Expected expression mapping to be in-bounds")
 }
 
+func TestRemappedInputRecordType(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	scan := b.NamedScan([]string{"test"}, baseSchema)
+	ref, err := b.RootFieldRef(scan, 1)
+	require.NoError(t, err)
+	proj, err := b.ProjectRemap(scan, []int32{2, 0}, ref)
+	require.NoError(t, err)
+
+	// the first column of the remapped projection is the fp32 expression
+	first, err := b.NestedFieldRef(proj, []expr.ReferenceSegment{&expr.StructFieldRef{Field: 0}})
+	require.NoError(t, err)
+
+	fetch, err := b.Fetch(proj, 0, 10)
+	require.NoError(t, err)
+	sort, err := b.Sort(proj, expr.SortField{Expr: first, Kind: types.SortAscNullsLast})
+	require.NoError(t, err)
+	filter, err := b.Filter(proj, expr.NewPrimitiveLiteral(true, false))
+	require.NoError(t, err)
+	project, err := b.Project(proj, first)
+	require.NoError(t, err)
+
+	tests := []struct {
+		rel      plan.Rel
+		names    []string
+		expected string
+	}{
+		{fetch, []string{"b", "a"}, "NSTRUCT<b: fp32, a: string>"},
+		{sort, []string{"b", "a"}, "NSTRUCT<b: fp32, a: string>"},
+		{filter, []string{"b", "a"}, "NSTRUCT<b: fp32, a: string>"},
+		{project, []string{"b", "a", "c"}, "NSTRUCT<b: fp32, a: string, c: fp32>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%T", tt.rel), func(t *testing.T) {
+			p, err := b.Plan(tt.rel, tt.names)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, p.GetRoots()[0].RecordType().String())
+			assert.NoError(t, p.Validate())
+
+			protoPlan, err := p.ToProto()
+			require.NoError(t, err)
+			roundTrip, err := plan.FromProto(protoPlan, &extensions.DefaultCollection)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, roundTrip.GetRoots()[0].RecordType().String())
+			assert.NoError(t, roundTrip.Validate())
+		})
+	}
+}
+
 func TestSetRelations(t *testing.T) {
 	const expectedJSON = `{
 		` + versionStruct + `,
@@ -1518,3 +1884,2793 @@ func TestSetRelErrors(t *testing.T) {
 	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
 	assert.ErrorContains(t, err, "output mapping index out of range")
 }
+
+func TestInPredicateSubquery(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	outer := b.NamedScan([]string{"outer"}, baseSchema2)
+	subquery, err := b.NamedScanRemap([]string{"inner"}, baseSchema2, []int32{0})
+	require.NoError(t, err)
+
+	needle, err := b.RootFieldRef(outer, 0)
+	require.NoError(t, err)
+
+	pred, err := expr.NewInPredicate([]expr.Expression{needle}, subquery)
+	require.NoError(t, err)
+	assert.Equal(t, "boolean", pred.GetType().String())
+	assert.Equal(t, ".field(0) => i32 IN (subquery: struct<i32>)", pred.String())
+
+	protoExpr := pred.ToProto()
+	inPred := protoExpr.GetSubquery().GetInPredicate()
+	require.NotNil(t, inPred)
+	assert.Len(t, inPred.Needles, 1)
+	assert.NotNil(t, inPred.Haystack.GetRead().GetNamedTable())
+
+	outerType := outer.RecordType()
+	reg := expr.NewEmptyExtensionRegistry(&extensions.DefaultCollection)
+	roundTrip, err := expr.ExprFromProto(protoExpr, &outerType, reg)
+	require.NoError(t, err)
+	assert.True(t, pred.Equals(roundTrip))
+	assert.True(t, proto.Equal(protoExpr, roundTrip.ToProto()))
+
+	filter, err := b.Filter(outer, pred)
+	require.NoError(t, err)
+	assert.Same(t, pred, filter.Condition())
+
+	_, err = expr.NewInPredicate([]expr.Expression{needle, needle}, subquery)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidExpr)
+	assert.ErrorContains(t, err, "in predicate has 2 needles but subquery outputs 1 columns")
+
+	strRef, err := b.RootFieldRef(b.NamedScan([]string{"test"}, baseSchema), 0)
+	require.NoError(t, err)
+	_, err = expr.NewInPredicate([]expr.Expression{strRef}, subquery)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidExpr)
+	assert.ErrorContains(t, err, "in predicate needle 0 has type string, subquery column has type i32")
+}
+
+func TestSetPredicateSubquery(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	subquery := b.NamedScan([]string{"test"}, baseSchema2)
+
+	for _, op := range []expr.SetPredicateOp{expr.SetPredicateOpExists, expr.SetPredicateOpUnique} {
+		pred, err := expr.NewSetPredicate(op, subquery)
+		require.NoError(t, err)
+		assert.Equal(t, "boolean", pred.GetType().String())
+
+		protoExpr := pred.ToProto()
+		assert.Equal(t, op, protoExpr.GetSubquery().GetSetPredicate().GetPredicateOp())
+
+		reg := expr.NewEmptyExtensionRegistry(&extensions.DefaultCollection)
+		roundTrip, err := expr.ExprFromProto(protoExpr, nil, reg)
+		require.NoError(t, err)
+		assert.True(t, pred.Equals(roundTrip))
+	}
+
+	pred, _ := expr.NewSetPredicate(expr.SetPredicateOpExists, subquery)
+	assert.Equal(t, "EXISTS(subquery: struct<i32, boolean>)", pred.String())
+
+	_, err := expr.NewSetPredicate(expr.SetPredicateOpUnspecified, subquery)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidExpr)
+
+	_, err = expr.NewSetPredicate(expr.SetPredicateOpExists, nil)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidExpr)
+}
+
+func TestNestedFieldRef(t *testing.T) {
+	schema, err := parser.ParseNamedStruct(
+		"NSTRUCT<id: i64, items: list<struct<name: string, qty: i32?>>, attrs: map<string, i64?>>")
+	require.NoError(t, err)
+
+	b := plan.NewBuilderDefault()
+	scan := b.NamedScan([]string{"test"}, schema)
+
+	// items[2].qty
+	ref, err := b.NestedFieldRef(scan, []expr.ReferenceSegment{
+		expr.NewStructFieldRef(1), expr.NewListElemRef(2), expr.NewStructFieldRef(1)})
+	require.NoError(t, err)
+	assert.Equal(t, ".field(1).[2].field(1) => i32?", ref.String())
+
+	direct := ref.ToProto().GetSelection().GetDirectReference()
+	assert.EqualValues(t, 1, direct.GetStructField().GetField())
+	assert.EqualValues(t, 2, direct.GetStructField().GetChild().GetListElement().GetOffset())
+	assert.EqualValues(t, 1, direct.GetStructField().GetChild().GetListElement().GetChild().GetStructField().GetField())
+
+	reg := expr.NewEmptyExtensionRegistry(&extensions.DefaultCollection)
+	roundTrip, err := expr.ExprFromProto(ref.ToProto(), &schema.Struct, reg)
+	require.NoError(t, err)
+	assert.True(t, ref.Equals(roundTrip))
+	assert.True(t, ref.GetType().Equals(roundTrip.GetType()))
+
+	// attrs["key"]
+	ref, err = b.NestedFieldRef(scan, []expr.ReferenceSegment{
+		expr.NewStructFieldRef(2), expr.NewMapKeyRef(expr.NewPrimitiveLiteral("key", false))})
+	require.NoError(t, err)
+	assert.Equal(t, ".field(2).[string(key)] => i64?", ref.String())
+
+	proj, err := b.Project(scan, ref)
+	require.NoError(t, err)
+	assert.Equal(t, "i64?", proj.RecordType().Types[3].String())
+
+	tests := []struct {
+		name     string
+		segments []expr.ReferenceSegment
+		err      string
+	}{
+		{"no segments", nil, "must provide at least one reference segment"},
+		{"out of range", []expr.ReferenceSegment{expr.NewStructFieldRef(3)},
+			"segment 0: struct field index 3 out of range, only 3 fields"},
+		{"nested out of range", []expr.ReferenceSegment{expr.NewStructFieldRef(1), expr.NewListElemRef(0), expr.NewStructFieldRef(2)},
+			"segment 2: struct field index 2 out of range, only 2 fields"},
+		{"list of non-list", []expr.ReferenceSegment{expr.NewStructFieldRef(0), expr.NewListElemRef(0)},
+			"segment 1: cannot reference list element of type i64"},
+		{"map of non-map", []expr.ReferenceSegment{expr.NewStructFieldRef(1), expr.NewMapKeyRef(expr.NewPrimitiveLiteral("key", false))},
+			"segment 1: cannot reference map key of type list<struct<string, i32?>>"},
+		{"map key type", []expr.ReferenceSegment{expr.NewStructFieldRef(2), expr.NewMapKeyRef(expr.NewPrimitiveLiteral(int32(1), false))},
+			"segment 1: map key i32(1) does not match key type string"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := b.NestedFieldRef(scan, tt.segments)
+			assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
+
+func TestOuterFieldRef(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	outer := b.NamedScan([]string{"outer"}, baseSchema2)
+
+	ref, err := b.OuterFieldRef(outer, 2, 1)
+	require.NoError(t, err)
+	assert.Equal(t, expr.OuterReference(2), ref.Root)
+	assert.Equal(t, &types.BooleanType{Nullability: types.NullabilityRequired}, ref.GetType())
+	assert.Equal(t, "[outerRef:2].field(1) => boolean", ref.String())
+
+	fieldRef := ref.ToProto().GetSelection()
+	assert.EqualValues(t, 2, fieldRef.GetOuterReference().GetStepsOut())
+	assert.EqualValues(t, 1, fieldRef.GetDirectReference().GetStructField().GetField())
+
+	// the type is taken from the output of the outer relation
+	project, err := b.ProjectRemap(outer, []int32{1}, expr.NewPrimitiveLiteral("foo", false))
+	require.NoError(t, err)
+	ref, err = b.OuterFieldRef(project, 1, 0)
+	require.NoError(t, err)
+	assert.Equal(t, &types.BooleanType{Nullability: types.NullabilityRequired}, ref.GetType())
+
+	_, err = b.OuterFieldRef(outer, 0, 1)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "outer reference steps must be > 0, got 0")
+
+	_, err = b.OuterFieldRef(outer, -1, 1)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+
+	_, err = b.OuterFieldRef(outer, 1, -1)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+
+	_, err = b.OuterFieldRef(outer, 1, 2)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "cannot create field ref index 2, only 2 fields in outer rel")
+
+	_, err = b.OuterFieldRef(nil, 1, 0)
+	assert.Error(t, err)
+}
+
+func TestOuterFieldRefInSubquery(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	outer := b.NamedScan([]string{"outer"}, baseSchema2)
+	inner := b.NamedScan([]string{"inner"}, baseSchema2)
+
+	// EXISTS (SELECT * FROM inner WHERE outer.y), with the outer column as
+	// the condition itself
+	outerY, err := b.OuterFieldRef(outer, 1, 1)
+	require.NoError(t, err)
+	filter, err := b.Filter(inner, outerY)
+	require.NoError(t, err)
+
+	// SELECT outer.x + inner.x FROM inner
+	outerX, err := b.OuterFieldRef(outer, 1, 0)
+	require.NoError(t, err)
+	add, err := b.ScalarFn(extensions.SubstraitDefaultURIPrefix+"functions_arithmetic.yaml", "add", nil,
+		outerX, expr.MustExpr(b.RootFieldRef(filter, 0)))
+	require.NoError(t, err)
+	project, err := b.Project(filter, add)
+	require.NoError(t, err)
+	assert.Equal(t, "i32", project.RecordType().Types[2].String())
+
+	exists, err := expr.NewSetPredicate(expr.SetPredicateOpExists, project)
+	require.NoError(t, err)
+	top, err := b.Filter(outer, exists)
+	require.NoError(t, err)
+	p, err := b.Plan(top, []string{"x", "y"})
+	require.NoError(t, err)
+	require.NoError(t, p.Validate())
+	protoPlan, err := p.ToProto()
+	require.NoError(t, err)
+
+	// reading the plan back resolves the types of the outer references
+	// against the relation enclosing the subquery
+	roundTrip, err := plan.FromProto(protoPlan, &extensions.DefaultCollection)
+	require.NoError(t, err)
+	assert.NoError(t, roundTrip.Validate())
+	rtExists := roundTrip.GetRoots()[0].Input().(*plan.FilterRel).Condition().(*expr.SetPredicate)
+	rtProject := rtExists.Tuples.(*plan.ProjectRel)
+	rtFilter := rtProject.GetInputs()[0].(*plan.FilterRel)
+	assert.Equal(t, outerY.GetType(), rtFilter.Condition().GetType())
+	assert.Equal(t, "i32", rtProject.RecordType().Types[2].String())
+	roundTripProto, err := roundTrip.ToProto()
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(protoPlan, roundTripProto))
+
+	// without the enclosing relation the type of an outer reference is
+	// unknown, which is reported rather than used as a condition
+	rtInner, err := plan.RelFromProto(protoPlan.Relations[0].GetRoot().GetInput().GetFilter().
+		GetCondition().GetSubquery().GetSetPredicate().GetTuples().GetProject().GetInput(), roundTrip.ExtensionRegistry())
+	require.NoError(t, err)
+	assert.Nil(t, rtInner.(*plan.FilterRel).Condition().GetType())
+	unknown, err := expr.NewOuterFieldRef(1, expr.NewStructFieldRef(1), nil)
+	require.NoError(t, err)
+	_, err = b.Filter(inner, unknown)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "condition for Filter Relation must yield boolean, not unknown type")
+	_, err = expr.NewInPredicate([]expr.Expression{unknown}, inner)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidExpr)
+}
+
+func TestCorrelatedSubquery(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	outer := b.NamedScan([]string{"outer"}, baseSchema2)
+	inner := b.NamedScan([]string{"inner"}, baseSchema2)
+
+	// EXISTS (SELECT * FROM inner WHERE inner.x = outer.x)
+	outerType := outer.RecordType()
+	outerRef, err := expr.NewOuterFieldRef(1, expr.NewStructFieldRef(0), &outerType)
+	require.NoError(t, err)
+	assert.Equal(t, "[outerRef:1].field(0) => i32", outerRef.String())
+
+	innerRef, err := b.RootFieldRef(inner, 0)
+	require.NoError(t, err)
+
+	cond, err := b.ScalarFn(extensions.SubstraitDefaultURIPrefix+"functions_comparison.yaml",
+		"equal", nil, innerRef, outerRef)
+	require.NoError(t, err)
+
+	filter, err := b.Filter(inner, cond)
+	require.NoError(t, err)
+
+	pred, err := expr.NewSetPredicate(expr.SetPredicateOpExists, filter)
+	require.NoError(t, err)
+
+	protoExpr := pred.ToProto()
+	condProto := protoExpr.GetSubquery().GetSetPredicate().GetTuples().GetFilter().GetCondition()
+	outerProto := condProto.GetScalarFunction().GetArguments()[1].GetValue().GetSelection()
+	assert.EqualValues(t, 1, outerProto.GetOuterReference().GetStepsOut())
+
+	top, err := b.Filter(outer, pred)
+	require.NoError(t, err)
+	p, err := b.Plan(top, []string{"x", "y"})
+	require.NoError(t, err)
+
+	protoPlan, err := p.ToProto()
+	require.NoError(t, err)
+
+	roundTrip, err := plan.FromProto(protoPlan, &extensions.DefaultCollection)
+	require.NoError(t, err)
+	roundTripFilter := roundTrip.GetRoots()[0].Input().(*plan.FilterRel)
+	assert.True(t, pred.Equals(roundTripFilter.Condition()))
+
+	roundTripProto, err := roundTrip.ToProto()
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(protoPlan, roundTripProto))
+}
+
+func TestExtensionRelations(t *testing.T) {
+	detail, err := anypb.New(wrapperspb.String("custom operator"))
+	require.NoError(t, err)
+
+	outSchema, err := parser.ParseNamedStruct("NSTRUCT<id: i64, score: fp64?>")
+	require.NoError(t, err)
+
+	b := plan.NewBuilderDefault()
+	left := b.NamedScan([]string{"test"}, baseSchema)
+	right := b.NamedScan([]string{"test2"}, baseSchema2)
+
+	leaf, err := b.ExtensionLeaf(detail, outSchema)
+	require.NoError(t, err)
+	rt := leaf.RecordType()
+	assert.Equal(t, "struct<i64, fp64?>", rt.String())
+	assert.Empty(t, leaf.GetInputs())
+
+	single, err := b.ExtensionSingleRemap(leaf, detail, outSchema, []int32{1})
+	require.NoError(t, err)
+	rt = single.RecordType()
+	assert.Equal(t, "struct<i64, fp64?>", rt.String())
+	rt = single.Remap(single.RecordType())
+	assert.Equal(t, "struct<fp64?>", rt.String())
+
+	multi, err := b.ExtensionMulti([]plan.Rel{left, right, single}, detail, outSchema)
+	require.NoError(t, err)
+	assert.Len(t, multi.GetInputs(), 3)
+	rt = multi.RecordType()
+	assert.Equal(t, "struct<i64, fp64?>", rt.String())
+
+	p, err := b.Plan(multi, []string{"id", "score"})
+	require.NoError(t, err)
+	assert.Equal(t, "NSTRUCT<id: i64, score: fp64?>", p.GetRoots()[0].RecordType().String())
+
+	protoPlan, err := p.ToProto()
+	require.NoError(t, err)
+
+	multiProto := protoPlan.Relations[0].GetRoot().GetInput().GetExtensionMulti()
+	require.NotNil(t, multiProto)
+	require.Len(t, multiProto.Inputs, 3)
+	singleProto := multiProto.Inputs[2].GetExtensionSingle()
+	require.NotNil(t, singleProto)
+	assert.Equal(t, []int32{1}, singleProto.GetCommon().GetEmit().GetOutputMapping())
+	require.NotNil(t, singleProto.GetInput().GetExtensionLeaf())
+
+	roundTrip, err := plan.FromProto(protoPlan, &extensions.DefaultCollection)
+	require.NoError(t, err)
+
+	roundTripProto, err := roundTrip.ToProto()
+	require.NoError(t, err)
+	assert.Truef(t, proto.Equal(protoPlan, roundTripProto), "plan expected: %s\ngot: %s",
+		protojson.Format(protoPlan), protojson.Format(roundTripProto))
+
+	var payload wrapperspb.StringValue
+	rtMulti := roundTrip.GetRoots()[0].Input().(*plan.ExtensionMultiRel)
+	require.NoError(t, rtMulti.Detail().UnmarshalTo(&payload))
+	assert.Equal(t, "custom operator", payload.Value)
+
+	rtLeaf := rtMulti.Inputs()[2].(*plan.ExtensionSingleRel).Input().(*plan.ExtensionLeafRel)
+	require.NoError(t, rtLeaf.Detail().UnmarshalTo(&payload))
+	assert.Equal(t, "custom operator", payload.Value)
+}
+
+func TestExtensionRelationErrors(t *testing.T) {
+	detail, err := anypb.New(wrapperspb.String("custom operator"))
+	require.NoError(t, err)
+
+	b := plan.NewBuilderDefault()
+	scan := b.NamedScan([]string{"test"}, baseSchema)
+
+	_, err = b.ExtensionLeafRemap(detail, baseSchema, []int32{2})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+	assert.ErrorContains(t, err, "output mapping index out of range")
+
+	_, err = b.ExtensionSingle(nil, detail, baseSchema)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+	assert.ErrorContains(t, err, "input Relation must not be nil")
+
+	_, err = b.ExtensionSingleRemap(scan, detail, baseSchema, []int32{-1})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+	assert.ErrorContains(t, err, "output mapping index out of range")
+
+	_, err = b.ExtensionMulti([]plan.Rel{scan, nil}, detail, baseSchema)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+	assert.ErrorContains(t, err, "input Relation must not be nil")
+}
+
+func TestNamedScanWithFilter(t *testing.T) {
+	const expectedJSON = `{
+		` + versionStruct + `,
+		"relations": [
+			{
+				"root": {
+					"input": {
+						"read": {
+							"common": {"emit": {"outputMapping": [1]}},
+							"baseSchema": {
+								"names": ["x", "y"],
+								"struct": {
+									"types": [
+										{"i32": { "nullability": "NULLABILITY_REQUIRED"}},
+										{"bool": { "nullability": "NULLABILITY_REQUIRED"}}
+									],
+									"nullability": "NULLABILITY_REQUIRED"
+								}
+							},
+							"filter": {
+								"selection": {
+									"rootReference": {},
+									"directReference": { "structField": { "field": 1 }}
+								}
+							},
+							"projection": {
+								"select": {
+									"structItems": [{"field": 0}, {"field": 1}]
+								},
+								"maintainSingularStruct": true
+							},
+							"namedTable": { "names": [ "test" ]}
+						}
+					},
+					"names": ["b"]
+				}
+			}
+		]
+	}`
+
+	b := plan.NewBuilderDefault()
+	schemaRef := b.NamedScan([]string{"test"}, baseSchema2)
+	ref, err := b.RootFieldRef(schemaRef, 1)
+	require.NoError(t, err)
+
+	scan, err := b.NamedScanWithFilter([]string{"test"}, baseSchema2, ref)
+	require.NoError(t, err)
+	assert.Same(t, ref, scan.Filter())
+	assert.Nil(t, scan.Projection())
+
+	proj := expr.NewMaskExpression(expr.MaskStructSelect{
+		expr.NewMaskStructItem(0, nil), expr.NewMaskStructItem(1, nil)}, true)
+	scan, err = b.NamedScanWithOptionsRemap([]string{"test"}, baseSchema2,
+		plan.ScanOptions{Filter: ref, Projection: proj}, []int32{1})
+	require.NoError(t, err)
+
+	p, err := b.Plan(scan, []string{"b"})
+	require.NoError(t, err)
+	assert.NoError(t, p.Validate())
+	assert.Equal(t, "NSTRUCT<b: boolean>", p.GetRoots()[0].RecordType().String())
+
+	protoPlan, err := p.ToProto()
+	require.NoError(t, err)
+
+	var expectedProto substraitproto.Plan
+	require.NoError(t, protojson.Unmarshal([]byte(expectedJSON), &expectedProto))
+	assert.Truef(t, proto.Equal(expectedProto.Relations[0], protoPlan.Relations[0]),
+		"JSON expected: %s\ngot: %s", protojson.Format(&expectedProto), protojson.Format(protoPlan))
+
+	roundTrip, err := plan.FromProto(protoPlan, &extensions.DefaultCollection)
+	require.NoError(t, err)
+	rtScan := roundTrip.GetRoots()[0].Input().(*plan.NamedTableReadRel)
+	assert.True(t, ref.Equals(rtScan.Filter()))
+	assert.True(t, proj.Equals(rtScan.Projection()))
+
+	roundTripProto, err := roundTrip.ToProto()
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(protoPlan, roundTripProto))
+}
+
+func TestNamedScanWithFilters(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	schemaRef := b.NamedScan([]string{"test"}, baseSchema2)
+	refX, err := b.RootFieldRef(schemaRef, 0)
+	require.NoError(t, err)
+	refY, err := b.RootFieldRef(schemaRef, 1)
+	require.NoError(t, err)
+	// x > 10 may only be partially applied while reading
+	gt, err := b.ScalarFn(extensions.SubstraitDefaultURIPrefix+"functions_comparison.yaml", "gt", nil,
+		refX, expr.NewPrimitiveLiteral(int32(10), false))
+	require.NoError(t, err)
+
+	scan, err := b.NamedScanWithFilters([]string{"test"}, baseSchema2, refY, gt)
+	require.NoError(t, err)
+	assert.Same(t, refY, scan.Filter())
+	assert.Same(t, gt, scan.BestEffortFilter())
+
+	p, err := b.Plan(scan, []string{"x", "y"})
+	require.NoError(t, err)
+	assert.NoError(t, p.Validate())
+
+	protoPlan, err := p.ToProto()
+	require.NoError(t, err)
+	read := protoPlan.Relations[0].GetRoot().GetInput().GetRead()
+	assert.True(t, proto.Equal(refY.ToProto(), read.GetFilter()))
+	assert.True(t, proto.Equal(gt.ToProto(), read.GetBestEffortFilter()))
+
+	roundTrip, err := plan.FromProto(protoPlan, &extensions.DefaultCollection)
+	require.NoError(t, err)
+	rtScan := roundTrip.GetRoots()[0].Input().(*plan.NamedTableReadRel)
+	assert.True(t, refY.Equals(rtScan.Filter()))
+	assert.True(t, gt.Equals(rtScan.BestEffortFilter()))
+
+	// either filter may be omitted
+	scan, err = b.NamedScanWithFilters([]string{"test"}, baseSchema2, nil, gt)
+	require.NoError(t, err)
+	assert.Nil(t, scan.Filter())
+	assert.Same(t, gt, scan.BestEffortFilter())
+	scan, err = b.NamedScanWithFilters([]string{"test"}, baseSchema2, refY, nil)
+	require.NoError(t, err)
+	assert.Same(t, refY, scan.Filter())
+	assert.Nil(t, scan.BestEffortFilter())
+
+	_, err = b.NamedScanWithFilters([]string{"test"}, baseSchema2, nil, nil)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+	_, err = b.NamedScanWithFilters([]string{"test"}, baseSchema2, refY, refX)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "best effort filter for Read Relation must yield boolean, not i32")
+	_, err = b.NamedScanWithFilters([]string{"test"}, baseSchema2, refX, gt)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "filter for Read Relation must yield boolean, not i32")
+}
+
+func TestNamedScanProjection(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	wide, err := parser.ParseNamedStruct("NSTRUCT<a: string, b: fp32, c: i32?>")
+	require.NoError(t, err)
+
+	// even with a single field the read produces a struct
+	proj := expr.NewMaskExpression(expr.MaskStructSelect{expr.NewMaskStructItem(2, nil)}, false)
+	scan, err := b.NamedScanWithOptions([]string{"wide"}, wide, plan.ScanOptions{Projection: proj})
+	require.NoError(t, err)
+	rt := scan.RecordType()
+	assert.Equal(t, "struct<i32?>", rt.String())
+
+	ref, err := b.RootFieldRef(scan, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "i32?", ref.GetType().String())
+}
+
+func TestNamedScanWithFilterErrors(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	wide, err := parser.ParseNamedStruct("NSTRUCT<a: string, b: fp32, c: boolean>")
+	require.NoError(t, err)
+	wideScan := b.NamedScan([]string{"wide"}, wide)
+
+	_, err = b.NamedScanWithFilter([]string{"test"}, baseSchema, nil)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+	assert.ErrorContains(t, err, "cannot use nil filter in read relation")
+
+	strRef, err := b.RootFieldRef(wideScan, 0)
+	require.NoError(t, err)
+	_, err = b.NamedScanWithFilter([]string{"test"}, baseSchema, strRef)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "filter for Read Relation must yield boolean, not string")
+
+	_, err = b.NamedScanWithOptions([]string{"test"}, baseSchema, plan.ScanOptions{BestEffortFilter: strRef})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "best effort filter for Read Relation must yield boolean, not string")
+
+	// boolean field which doesn't exist in the narrower schema
+	boolRef, err := b.RootFieldRef(wideScan, 2)
+	require.NoError(t, err)
+	_, err = b.NamedScanWithFilter([]string{"test"}, baseSchema, boolRef)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+	assert.ErrorContains(t, err, "filter: field reference index 2 out of range, input has 2 fields")
+
+	proj := expr.NewMaskExpression(expr.MaskStructSelect{expr.NewMaskStructItem(2, nil)}, true)
+	_, err = b.NamedScanWithOptions([]string{"test"}, baseSchema, plan.ScanOptions{Projection: proj})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "invalid projection for Read Relation")
+
+	proj = expr.NewMaskExpression(expr.MaskStructSelect{expr.NewMaskStructItem(1, nil)}, true)
+	_, err = b.NamedScanWithOptionsRemap([]string{"test"}, baseSchema, plan.ScanOptions{Projection: proj}, []int32{1})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+	assert.ErrorContains(t, err, "output mapping index out of range")
+}
+
+func TestNamedWriteInsert(t *testing.T) {
+	const expectedJSON = `{
+		"root": {
+			"input": {
+				"write": {
+					"common": {"direct": {}},
+					"namedTable": { "names": [ "target" ]},
+					"tableSchema": {
+						"names": ["x", "y"],
+						"struct": {
+							"types": [
+								{"i32": { "nullability": "NULLABILITY_NULLABLE"}},
+								{"bool": { "nullability": "NULLABILITY_REQUIRED"}}
+							],
+							"nullability": "NULLABILITY_REQUIRED"
+						}
+					},
+					"op": "WRITE_OP_INSERT",
+					"input": {
+						"filter": {
+							"common": {"direct": {}},
+							"input": {
+								"read": {
+									"common": {"direct": {}},
+									"baseSchema": {
+										"names": ["x", "y"],
+										"struct": {
+											"types": [
+												{"i32": { "nullability": "NULLABILITY_REQUIRED"}},
+												{"bool": { "nullability": "NULLABILITY_REQUIRED"}}
+											],
+											"nullability": "NULLABILITY_REQUIRED"
+										}
+									},
+									"namedTable": { "names": [ "test" ]}
+								}
+							},
+							"condition": {
+								"selection": {
+									"rootReference": {},
+									"directReference": { "structField": { "field": 1 }}
+								}
+							}
+						}
+					},
+					"output": "OUTPUT_MODE_MODIFIED_RECORDS"
+				}
+			},
+			"names": ["x", "y"]
+		}
+	}`
+
+	target, err := parser.ParseNamedStruct("NSTRUCT<x: i32?, y: boolean>")
+	require.NoError(t, err)
+
+	b := plan.NewBuilderDefault()
+	scan := b.NamedScan([]string{"test"}, baseSchema2)
+	ref, err := b.RootFieldRef(scan, 1)
+	require.NoError(t, err)
+	filter, err := b.Filter(scan, ref)
+	require.NoError(t, err)
+
+	insert, err := b.NamedWrite(filter, []string{"target"}, target, plan.WriteOpInsert)
+	require.NoError(t, err)
+	assert.Equal(t, plan.WriteOutputNoOutput, insert.OutputMode())
+	rt := insert.RecordType()
+	assert.Empty(t, rt.Types)
+
+	insert, err = b.NamedWriteWithOutput(filter, []string{"target"}, target,
+		plan.WriteOpInsert, plan.WriteOutputModifiedRecords)
+	require.NoError(t, err)
+
+	p, err := b.Plan(insert, []string{"x", "y"})
+	require.NoError(t, err)
+	assert.Equal(t, "NSTRUCT<x: i32?, y: boolean>", p.GetRoots()[0].RecordType().String())
+
+	protoPlan, err := p.ToProto()
+	require.NoError(t, err)
+
+	var expectedProto substraitproto.PlanRel
+	require.NoError(t, protojson.Unmarshal([]byte(expectedJSON), &expectedProto))
+	assert.Truef(t, proto.Equal(&expectedProto, protoPlan.Relations[0]),
+		"JSON expected: %s\ngot: %s", protojson.Format(&expectedProto), protojson.Format(protoPlan.Relations[0]))
+
+	roundTrip, err := plan.FromProto(protoPlan, &extensions.DefaultCollection)
+	require.NoError(t, err)
+	write := roundTrip.GetRoots()[0].Input().(*plan.NamedTableWriteRel)
+	assert.Equal(t, plan.WriteOpInsert, write.Op())
+	assert.Equal(t, plan.WriteOutputModifiedRecords, write.OutputMode())
+	assert.Equal(t, []string{"target"}, write.Names())
+	assert.IsType(t, (*plan.FilterRel)(nil), write.Input())
+
+	roundTripProto, err := roundTrip.ToProto()
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(protoPlan, roundTripProto))
+}
+
+func TestNamedWriteErrors(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	scan := b.NamedScan([]string{"test"}, baseSchema2)
+
+	_, err := b.NamedWrite(nil, []string{"target"}, baseSchema2, plan.WriteOpInsert)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+	assert.ErrorContains(t, err, "input Relation must not be nil")
+
+	_, err = b.NamedWrite(scan, []string{"target"}, baseSchema2, plan.WriteOpUnspecified)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "invalid operation WRITE_OP_UNSPECIFIED for write relation")
+
+	_, err = b.NamedWriteWithOutput(scan, []string{"target"}, baseSchema2, plan.WriteOpDelete, plan.WriteOutputUnspecified)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "invalid output mode OUTPUT_MODE_UNSPECIFIED for write relation")
+
+	_, err = b.NamedWrite(scan, []string{"target"}, baseSchema, plan.WriteOpUpdate)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+	assert.ErrorContains(t, err, "input record type struct<i32, boolean> does not match table schema struct<string, fp32>")
+
+	// remapped input must match as well
+	remapped, err := b.NamedScanRemap([]string{"test"}, baseSchema2, []int32{0})
+	require.NoError(t, err)
+	_, err = b.NamedWrite(remapped, []string{"target"}, baseSchema2, plan.WriteOpCtas)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+	assert.ErrorContains(t, err, "input record type struct<i32> does not match table schema")
+}
+
+func TestExchangeRelation(t *testing.T) {
+	const expectedJSON = `{
+		"root": {
+			"input": {
+				"exchange": {
+					"common": {"direct": {}},
+					"input": {
+						"read": {
+							"common": {"direct": {}},
+							"baseSchema": {
+								"names": ["a", "b"],
+								"struct": {
+									"types": [
+										{"string": { "nullability": "NULLABILITY_REQUIRED"}},
+										{"fp32": { "nullability": "NULLABILITY_REQUIRED"}}
+									],
+									"nullability": "NULLABILITY_REQUIRED"
+								}
+							},
+							"namedTable": { "names": [ "test" ]}
+						}
+					},
+					"partitionCount": 8,
+					"scatterByFields": {
+						"fields": [{
+							"rootReference": {},
+							"directReference": { "structField": { "field": 1 }}
+						}]
+					}
+				}
+			},
+			"names": ["a", "b"]
+		}
+	}`
+
+	b := plan.NewBuilderDefault()
+	scan := b.NamedScan([]string{"test"}, baseSchema)
+
+	hash, err := b.Exchange(scan, 8, &plan.ExchangeScatterByFields{Fields: []int32{1}})
+	require.NoError(t, err)
+	rt := hash.RecordType()
+	assert.Equal(t, "struct<string, fp32>", rt.String())
+
+	p, err := b.Plan(hash, []string{"a", "b"})
+	require.NoError(t, err)
+	assert.NoError(t, p.Validate())
+
+	protoPlan, err := p.ToProto()
+	require.NoError(t, err)
+
+	var expectedProto substraitproto.PlanRel
+	require.NoError(t, protojson.Unmarshal([]byte(expectedJSON), &expectedProto))
+	assert.Truef(t, proto.Equal(&expectedProto, protoPlan.Relations[0]),
+		"JSON expected: %s\ngot: %s", protojson.Format(&expectedProto), protojson.Format(protoPlan.Relations[0]))
+
+	roundTrip, err := plan.FromProto(protoPlan, &extensions.DefaultCollection)
+	require.NoError(t, err)
+	exchange := roundTrip.GetRoots()[0].Input().(*plan.ExchangeRel)
+	assert.EqualValues(t, 8, exchange.PartitionCount())
+	assert.Equal(t, &plan.ExchangeScatterByFields{Fields: []int32{1}}, exchange.Scheme())
+
+	roundTripProto, err := roundTrip.ToProto()
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(protoPlan, roundTripProto))
+
+	// broadcast with the result remapped
+	broadcast, err := b.ExchangeRemap(scan, 4, &plan.ExchangeBroadcast{}, []int32{1})
+	require.NoError(t, err)
+	assert.Equal(t, []int32{1}, broadcast.OutputMapping())
+
+	p, err = b.Plan(broadcast, []string{"b"})
+	require.NoError(t, err)
+	assert.Equal(t, "NSTRUCT<b: fp32>", p.GetRoots()[0].RecordType().String())
+
+	protoPlan, err = p.ToProto()
+	require.NoError(t, err)
+	exchangeProto := protoPlan.Relations[0].GetRoot().GetInput().GetExchange()
+	require.NotNil(t, exchangeProto)
+	assert.NotNil(t, exchangeProto.GetBroadcast())
+	assert.EqualValues(t, 4, exchangeProto.PartitionCount)
+
+	roundTrip, err = plan.FromProto(protoPlan, &extensions.DefaultCollection)
+	require.NoError(t, err)
+	exchange = roundTrip.GetRoots()[0].Input().(*plan.ExchangeRel)
+	assert.IsType(t, (*plan.ExchangeBroadcast)(nil), exchange.Scheme())
+	assert.EqualValues(t, 4, exchange.PartitionCount())
+
+	roundTripProto, err = roundTrip.ToProto()
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(protoPlan, roundTripProto))
+
+	// remaining schemes round trip as well
+	target, err := b.RootFieldRef(scan, 0)
+	require.NoError(t, err)
+	for _, scheme := range []plan.ExchangeScheme{
+		&plan.ExchangeRoundRobin{Exact: true},
+		&plan.ExchangeSingleTarget{Expr: expr.NewPrimitiveLiteral(int32(2), false)},
+		&plan.ExchangeMultiTarget{Expr: target, ConstrainedToCount: true},
+	} {
+		ex, err := b.Exchange(scan, 2, scheme)
+		require.NoError(t, err)
+		rel, err := plan.RelFromProto(ex.ToProto(), expr.NewEmptyExtensionRegistry(&extensions.DefaultCollection))
+		require.NoError(t, err)
+		assert.True(t, proto.Equal(ex.ToProto(), rel.ToProto()))
+	}
+}
+
+func TestExchangeRelationErrors(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	scan := b.NamedScan([]string{"test"}, baseSchema)
+
+	_, err := b.Exchange(nil, 2, &plan.ExchangeBroadcast{})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+	assert.ErrorContains(t, err, "input Relation must not be nil")
+
+	_, err = b.Exchange(scan, -1, &plan.ExchangeBroadcast{})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "partition count for exchange relation must not be negative, got -1")
+
+	_, err = b.Exchange(scan, 2, &plan.ExchangeScatterByFields{Fields: []int32{0, 2}})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "scatter field index 2 out of range for exchange relation, input has 2 fields")
+
+	_, err = b.Exchange(scan, 2, &plan.ExchangeScatterByFields{})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "must provide at least one field to scatter by")
+
+	_, err = b.Exchange(scan, 2, &plan.ExchangeSingleTarget{})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "target expression for exchange relation must not be nil")
+
+	_, err = b.Exchange(scan, 2, nil)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "unknown exchange scheme")
+
+	_, err = b.ExchangeRemap(scan, 2, &plan.ExchangeRoundRobin{}, []int32{5})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+	assert.ErrorContains(t, err, "output mapping index out of range")
+}
+
+func TestExpandRelationRollup(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	scan := b.NamedScan([]string{"test"}, baseSchema)
+
+	refA, err := b.RootFieldRef(scan, 0)
+	require.NoError(t, err)
+	refB, err := b.RootFieldRef(scan, 1)
+	require.NoError(t, err)
+	nullA := &expr.NullLiteral{Type: &types.StringType{Nullability: types.NullabilityNullable}}
+	nullB := &expr.NullLiteral{Type: &types.Float32Type{Nullability: types.NullabilityNullable}}
+
+	// ROLLUP(a, b) => GROUPING SETS ((a, b), (a), ())
+	fields := []plan.ExpandField{
+		&plan.SwitchingField{Duplicates: []expr.Expression{refA, refA, nullA}},
+		&plan.SwitchingField{Duplicates: []expr.Expression{refB, nullB, nullB}},
+	}
+	expand, err := b.Expand(scan, fields)
+	require.NoError(t, err)
+	assert.Equal(t, 3, expand.NumDuplicates())
+
+	rt := expand.RecordType()
+	assert.Equal(t, "struct<string?, fp32?, i64>", rt.String())
+
+	p, err := b.Plan(expand, []string{"a", "b", "grouping_id"})
+	require.NoError(t, err)
+	assert.NoError(t, p.Validate())
+
+	protoPlan, err := p.ToProto()
+	require.NoError(t, err)
+	expandProto := protoPlan.Relations[0].GetRoot().GetInput().GetExpand()
+	require.NotNil(t, expandProto)
+	require.Len(t, expandProto.Fields, 2)
+	for _, f := range expandProto.Fields {
+		assert.Len(t, f.GetSwitchingField().GetDuplicates(), 3)
+	}
+
+	roundTrip, err := plan.FromProto(protoPlan, &extensions.DefaultCollection)
+	require.NoError(t, err)
+	rtExpand := roundTrip.GetRoots()[0].Input().(*plan.ExpandRel)
+	rt = rtExpand.RecordType()
+	assert.Equal(t, "struct<string?, fp32?, i64>", rt.String())
+	assert.Equal(t, "NSTRUCT<a: string?, b: fp32?, grouping_id: i64>", roundTrip.GetRoots()[0].RecordType().String())
+
+	roundTripProto, err := roundTrip.ToProto()
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(protoPlan, roundTripProto))
+
+	// consistent fields and input fields beyond those defined pass through
+	expand, err = b.ExpandRemap(scan, []plan.ExpandField{&plan.ConsistentField{Expr: refA}}, []int32{2, 1})
+	require.NoError(t, err)
+	rt = expand.RecordType()
+	assert.Equal(t, "struct<string, fp32, i64>", rt.String())
+	assert.Equal(t, 1, expand.NumDuplicates())
+}
+
+func TestExpandRelationErrors(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	scan := b.NamedScan([]string{"test"}, baseSchema)
+	refA, err := b.RootFieldRef(scan, 0)
+	require.NoError(t, err)
+	refB, err := b.RootFieldRef(scan, 1)
+	require.NoError(t, err)
+
+	_, err = b.Expand(nil, []plan.ExpandField{&plan.ConsistentField{Expr: refA}})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+
+	_, err = b.Expand(scan, nil)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "must provide at least one field for expand relation")
+
+	_, err = b.Expand(scan, []plan.ExpandField{
+		&plan.SwitchingField{Duplicates: []expr.Expression{refA, refA}},
+		&plan.SwitchingField{Duplicates: []expr.Expression{refB}},
+	})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "switching field 1 for expand relation has 1 duplicates, expected 2")
+
+	_, err = b.Expand(scan, []plan.ExpandField{
+		&plan.SwitchingField{Duplicates: []expr.Expression{refA, refB}},
+	})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "must have the same type, got string and fp32")
+
+	_, err = b.Expand(scan, []plan.ExpandField{&plan.SwitchingField{}})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "switching field 0 for expand relation has no duplicates")
+
+	_, err = b.Expand(scan, []plan.ExpandField{&plan.ConsistentField{}})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "consistent field 0 for expand relation is nil")
+
+	_, err = b.ExpandRemap(scan, []plan.ExpandField{&plan.ConsistentField{Expr: refA}}, []int32{3})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+	assert.ErrorContains(t, err, "output mapping index out of range")
+}
+
+func TestAggregateGroupingSets(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	aggCount, err := b.AggregateFn(extensions.SubstraitDefaultURIPrefix+"functions_aggregate_generic.yaml",
+		"count", nil)
+	require.NoError(t, err)
+	scan := b.NamedScan([]string{"test"}, baseSchema)
+
+	refA, err := b.RootFieldRef(scan, 0)
+	require.NoError(t, err)
+	refB, err := b.RootFieldRef(scan, 1)
+	require.NoError(t, err)
+
+	// GROUPING SETS ((a, b), (b, a), (a), ())
+	sets := [][]expr.Expression{{refA, refB}, {refB, refA}, {refA}, {}}
+	root, err := b.AggregateGroupingSets(scan, sets, []plan.AggRelMeasure{b.Measure(aggCount, nil)})
+	require.NoError(t, err)
+	assert.Len(t, root.Groupings(), 4)
+	assert.Len(t, root.GroupingExpressions(), 2)
+	assert.Equal(t, [][]uint32{{0, 1}, {1, 0}, {0}, {}}, root.GroupingReferences())
+
+	// a and b are null in the records of the sets they are missing from,
+	// and the index of the grouping set is appended
+	p, err := b.Plan(root, []string{"a", "b", "cnt", "set"})
+	require.NoError(t, err)
+	assert.NoError(t, p.Validate())
+	assert.Equal(t, "NSTRUCT<a: string?, b: fp32?, cnt: i64, set: i32>", p.GetRoots()[0].RecordType().String())
+
+	protoPlan, err := p.ToProto()
+	require.NoError(t, err)
+	aggProto := protoPlan.Relations[0].GetRoot().GetInput().GetAggregate()
+	require.NotNil(t, aggProto)
+	require.Len(t, aggProto.Groupings, 4)
+	for i, set := range sets {
+		assert.Len(t, aggProto.Groupings[i].GroupingExpressions, len(set))
+	}
+
+	roundTrip, err := plan.FromProto(protoPlan, &extensions.DefaultCollection)
+	require.NoError(t, err)
+	rtAgg := roundTrip.GetRoots()[0].Input().(*plan.AggregateRel)
+	assert.Equal(t, root.GroupingReferences(), rtAgg.GroupingReferences())
+	for i, set := range rtAgg.Groupings() {
+		require.Len(t, set, len(sets[i]))
+		for j, e := range set {
+			assert.True(t, sets[i][j].Equals(e))
+		}
+	}
+
+	roundTripProto, err := roundTrip.ToProto()
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(protoPlan, roundTripProto))
+}
+
+func TestAggregateGroupingSetsErrors(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	scan := b.NamedScan([]string{"test"}, baseSchema)
+	wide, err := parser.ParseNamedStruct("NSTRUCT<a: string, b: fp32, c: i32>")
+	require.NoError(t, err)
+	outOfRange, err := b.RootFieldRef(b.NamedScan([]string{"wide"}, wide), 2)
+	require.NoError(t, err)
+	refA, err := b.RootFieldRef(scan, 0)
+	require.NoError(t, err)
+
+	_, err = b.AggregateGroupingSets(nil, [][]expr.Expression{{refA}}, nil)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+	assert.ErrorContains(t, err, "input Relation must not be nil")
+
+	_, err = b.AggregateGroupingSets(scan, nil, nil)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+	assert.ErrorContains(t, err, "must have at least one grouping set or measure")
+
+	_, err = b.AggregateGroupingSets(scan, [][]expr.Expression{{refA, nil}}, nil)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+	assert.ErrorContains(t, err, "grouping set 0 contains nil expression at index 1")
+
+	_, err = b.AggregateGroupingSets(scan, [][]expr.Expression{{refA}, {outOfRange}}, nil)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+	assert.ErrorContains(t, err, "grouping set 1 expression 0: field reference index 2 out of range, input has 2 fields")
+
+	// the output is a and the grouping set index
+	agg, err := b.AggregateGroupingSetsRemap(scan, []int32{1}, [][]expr.Expression{{refA}, {refA}}, nil)
+	require.NoError(t, err)
+	out := agg.Remap(agg.RecordType())
+	assert.Equal(t, "struct<i32>", out.String())
+
+	_, err = b.AggregateGroupingSetsRemap(scan, []int32{2}, [][]expr.Expression{{refA}, {refA}}, nil)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+	assert.ErrorContains(t, err, "output mapping index out of range")
+
+	// the width of the output counts the distinct grouping expressions
+	_, err = b.AggregateExprsRemap(scan, []int32{1}, nil, []expr.Expression{refA}, []expr.Expression{refA})
+	assert.NoError(t, err)
+	_, err = b.AggregateExprsRemap(scan, []int32{2}, nil, []expr.Expression{refA}, []expr.Expression{refA})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+}
+
+func TestAggregateSingleGroupingSet(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	scan := b.NamedScan([]string{"test"}, baseSchema)
+
+	// a single grouping adds no index column and keeps the nullability
+	agg, err := b.AggregateColumns(scan, nil, 0, 1)
+	require.NoError(t, err)
+	assert.Len(t, agg.Groupings(), 1)
+	out := agg.RecordType()
+	assert.Equal(t, "struct<string, fp32>", out.String())
+
+	_, err = b.AggregateColumnsRemap(scan, []int32{2}, nil, 0, 1)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+}
+
+func TestAggregateMeasureArgsAndFilter(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	scan := b.NamedScan([]string{"test"}, baseSchema2)
+	refX, err := b.RootFieldRef(scan, 0)
+	require.NoError(t, err)
+	refY, err := b.RootFieldRef(scan, 1)
+	require.NoError(t, err)
+	sum, err := b.AggregateFn(arithmeticURI, "sum", nil, refX)
+	require.NoError(t, err)
+
+	// SELECT sum(x) FILTER (WHERE y)
+	agg, err := b.AggregateColumns(scan, []plan.AggRelMeasure{b.Measure(sum, refY)})
+	require.NoError(t, err)
+
+	p, err := b.Plan(agg, []string{"total"})
+	require.NoError(t, err)
+	assert.NoError(t, p.Validate())
+
+	protoPlan, err := p.ToProto()
+	require.NoError(t, err)
+	measures := protoPlan.Relations[0].GetRoot().GetInput().GetAggregate().GetMeasures()
+	require.Len(t, measures, 1)
+	args := measures[0].GetMeasure().GetArguments()
+	require.Len(t, args, 1)
+	assert.True(t, proto.Equal(refX.ToProto(), args[0].GetValue()))
+	assert.True(t, proto.Equal(refY.ToProto(), measures[0].GetFilter()))
+
+	roundTrip, err := plan.FromProto(protoPlan, &extensions.DefaultCollection)
+	require.NoError(t, err)
+	rtMeasure := roundTrip.GetRoots()[0].Input().(*plan.AggregateRel).Measures()[0]
+	assert.True(t, refY.Equals(rtMeasure.Filter()))
+	assert.True(t, refX.Equals(rtMeasure.Measure().Arg(0).(expr.Expression)))
+
+	// the filter must be a boolean
+	_, err = b.AggregateColumns(scan, []plan.AggRelMeasure{b.Measure(sum, refX)})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "filter of measure 0 for AggregateRel must yield boolean, not i32")
+
+	// the filter and the arguments are checked against the input
+	wide, err := parser.ParseNamedStruct("NSTRUCT<a: string, b: fp32, c: boolean, d: i32>")
+	require.NoError(t, err)
+	wideScan := b.NamedScan([]string{"wide"}, wide)
+	refC, err := b.RootFieldRef(wideScan, 2)
+	require.NoError(t, err)
+	refD, err := b.RootFieldRef(wideScan, 3)
+	require.NoError(t, err)
+	sumD, err := b.AggregateFn(arithmeticURI, "sum", nil, refD)
+	require.NoError(t, err)
+
+	_, err = b.AggregateColumns(scan, []plan.AggRelMeasure{b.Measure(sum, refC)})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+	assert.ErrorContains(t, err, "measure 0 filter")
+	_, err = b.AggregateColumns(scan, []plan.AggRelMeasure{b.Measure(sumD, nil)})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+	assert.ErrorContains(t, err, "measure 0 argument 0")
+}
+
+func TestAggregateMeasureArgsOverInput(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	schema, err := parser.ParseNamedStruct("NSTRUCT<a: i32, b: i64, c: fp64>")
+	require.NoError(t, err)
+	scan := b.NamedScan([]string{"test"}, schema)
+	refA, err := b.RootFieldRef(scan, 0)
+	require.NoError(t, err)
+	refB, err := b.RootFieldRef(scan, 1)
+	require.NoError(t, err)
+	sumB, err := b.AggregateFn(arithmeticURI, "sum", nil, refB)
+	require.NoError(t, err)
+	maxA, err := b.AggregateFn(arithmeticURI, "max", nil, refA)
+	require.NoError(t, err)
+
+	// SELECT a, sum(b), max(a) GROUP BY a, the measures refer to the
+	// fields of the input rather than the output of the aggregate, and
+	// may refer to the grouping columns too
+	agg, err := b.AggregateColumns(scan, []plan.AggRelMeasure{
+		b.Measure(sumB, nil), b.Measure(maxA, nil)}, 0)
+	require.NoError(t, err)
+	p, err := b.Plan(agg, []string{"a", "total", "largest"})
+	require.NoError(t, err)
+	assert.NoError(t, p.Validate())
+	assert.Equal(t, "NSTRUCT<a: i32, total: i64?, largest: i32?>", p.GetRoots()[0].RecordType().String())
+
+	protoPlan, err := p.ToProto()
+	require.NoError(t, err)
+	roundTrip, err := plan.FromProto(protoPlan, &extensions.DefaultCollection)
+	require.NoError(t, err)
+	assert.NoError(t, roundTrip.Validate())
+	assert.Equal(t, p.GetRoots()[0].RecordType(), roundTrip.GetRoots()[0].RecordType())
+
+	rtAgg := roundTrip.GetRoots()[0].Input().(*plan.AggregateRel)
+	assert.True(t, refB.Equals(rtAgg.Measures()[0].Measure().Arg(0).(expr.Expression)))
+	assert.True(t, refA.Equals(rtAgg.Measures()[1].Measure().Arg(0).(expr.Expression)))
+
+	// an argument beyond the fields of the input is rejected even though
+	// the output of the aggregate has as many columns
+	wide, err := parser.ParseNamedStruct("NSTRUCT<a: i32, b: i64, c: fp64, d: i64>")
+	require.NoError(t, err)
+	refD, err := b.RootFieldRef(b.NamedScan([]string{"wide"}, wide), 3)
+	require.NoError(t, err)
+	sumD, err := b.AggregateFn(arithmeticURI, "sum", nil, refD)
+	require.NoError(t, err)
+	_, err = b.AggregateColumns(scan, []plan.AggRelMeasure{
+		b.Measure(sumB, nil), b.Measure(maxA, nil), b.Measure(sumD, nil)}, 0)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+	assert.ErrorContains(t, err, "measure 2 argument 0: field reference index 3 out of range, input has 3 fields")
+}
+
+func TestAggregateGroupBy(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	aggCount, err := b.AggregateFn(extensions.SubstraitDefaultURIPrefix+"functions_aggregate_generic.yaml",
+		"count", nil)
+	require.NoError(t, err)
+	scan := b.NamedScan([]string{"test"}, baseSchema2)
+	refX, err := b.RootFieldRef(scan, 0)
+	require.NoError(t, err)
+	refY, err := b.RootFieldRef(scan, 1)
+	require.NoError(t, err)
+	bucket, err := b.ScalarFn(arithmeticURI, "divide", nil, refX, expr.NewPrimitiveLiteral(int32(10), false))
+	require.NoError(t, err)
+
+	// GROUP BY x / 10, y
+	agg, err := b.AggregateGroupBy(scan, []expr.Expression{bucket, refY},
+		[]plan.AggRelMeasure{b.Measure(aggCount, nil)})
+	require.NoError(t, err)
+	require.Len(t, agg.Groupings(), 1)
+	assert.Equal(t, []expr.Expression{bucket, refY}, agg.GroupingExpressions())
+
+	p, err := b.Plan(agg, []string{"bucket", "y", "cnt"})
+	require.NoError(t, err)
+	assert.NoError(t, p.Validate())
+	assert.Equal(t, "NSTRUCT<bucket: i32, y: boolean, cnt: i64>", p.GetRoots()[0].RecordType().String())
+
+	protoPlan, err := p.ToProto()
+	require.NoError(t, err)
+	aggProto := protoPlan.Relations[0].GetRoot().GetInput().GetAggregate()
+	require.Len(t, aggProto.Groupings, 1)
+	require.Len(t, aggProto.Groupings[0].GroupingExpressions, 2)
+	assert.NotNil(t, aggProto.Groupings[0].GroupingExpressions[0].GetScalarFunction())
+
+	roundTrip, err := plan.FromProto(protoPlan, &extensions.DefaultCollection)
+	require.NoError(t, err)
+	rtAgg := roundTrip.GetRoots()[0].Input().(*plan.AggregateRel)
+	assert.True(t, bucket.Equals(rtAgg.GroupingExpressions()[0]))
+
+	// without groupings the measures are computed over all records
+	total, err := b.AggregateGroupBy(scan, nil, []plan.AggRelMeasure{b.Measure(aggCount, nil)})
+	require.NoError(t, err)
+	assert.Empty(t, total.Groupings())
+	assert.Equal(t, "struct<i64>", outputString(total))
+
+	_, err = b.AggregateGroupBy(scan, nil, nil)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+
+	wide, err := parser.ParseNamedStruct("NSTRUCT<a: string, b: fp32, c: i32>")
+	require.NoError(t, err)
+	outOfRange, err := b.RootFieldRef(b.NamedScan([]string{"wide"}, wide), 2)
+	require.NoError(t, err)
+	_, err = b.AggregateGroupBy(scan, []expr.Expression{refX, outOfRange}, nil)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+	assert.ErrorContains(t, err, "expression 1: field reference index 2 out of range, input has 2 fields")
+}
+
+func TestAggregateMeasureWithOptions(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	scan := b.NamedScan([]string{"test"}, baseSchema)
+	refA, err := b.RootFieldRef(scan, 0)
+	require.NoError(t, err)
+	refB, err := b.RootFieldRef(scan, 1)
+	require.NoError(t, err)
+
+	sum, err := b.AggregateFn(extensions.SubstraitDefaultURIPrefix+"functions_arithmetic.yaml",
+		"sum", nil, refB)
+	require.NoError(t, err)
+	strAgg, err := b.AggregateFn(extensions.SubstraitDefaultURIPrefix+"functions_string.yaml",
+		"string_agg", nil, refA, expr.NewPrimitiveLiteral(",", false))
+	require.NoError(t, err)
+
+	// SUM(DISTINCT b), string_agg(a, ',' ORDER BY b DESC)
+	distinct := b.MeasureWithOptions(sum, nil, types.AggInvocationDistinct)
+	ordered := b.MeasureWithOptions(strAgg, nil, types.AggInvocationAll,
+		expr.SortField{Expr: refB, Kind: types.SortDescNullsFirst})
+
+	// the original functions are left unchanged
+	assert.Equal(t, types.AggInvocationAll, sum.Invocation())
+	assert.Empty(t, strAgg.Sorts)
+
+	agg, err := b.AggregateColumns(scan, []plan.AggRelMeasure{distinct, ordered})
+	require.NoError(t, err)
+
+	p, err := b.Plan(agg, []string{"total", "agg"})
+	require.NoError(t, err)
+	assert.NoError(t, p.Validate())
+
+	protoPlan, err := p.ToProto()
+	require.NoError(t, err)
+	measures := protoPlan.Relations[0].GetRoot().GetInput().GetAggregate().GetMeasures()
+	require.Len(t, measures, 2)
+	assert.Equal(t, substraitproto.AggregateFunction_AGGREGATION_INVOCATION_DISTINCT, measures[0].Measure.Invocation)
+	assert.Empty(t, measures[0].Measure.Sorts)
+	assert.Equal(t, substraitproto.AggregateFunction_AGGREGATION_INVOCATION_ALL, measures[1].Measure.Invocation)
+	require.Len(t, measures[1].Measure.Sorts, 1)
+	assert.Equal(t, substraitproto.SortField_SORT_DIRECTION_DESC_NULLS_FIRST, measures[1].Measure.Sorts[0].GetDirection())
+
+	roundTrip, err := plan.FromProto(protoPlan, &extensions.DefaultCollection)
+	require.NoError(t, err)
+	rtMeasures := roundTrip.GetRoots()[0].Input().(*plan.AggregateRel).Measures()
+	assert.Equal(t, types.AggInvocationDistinct, rtMeasures[0].Measure().Invocation())
+	require.Len(t, rtMeasures[1].Measure().Sorts, 1)
+	assert.True(t, refB.Equals(rtMeasures[1].Measure().Sorts[0].Expr))
+
+	roundTripProto, err := roundTrip.ToProto()
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(protoPlan, roundTripProto))
+}
+
+func TestAggregateMeasureWithPhase(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	scan := b.NamedScan([]string{"test"}, baseSchema)
+	refB, err := b.RootFieldRef(scan, 1)
+	require.NoError(t, err)
+
+	count, err := b.AggregateFn(extensions.SubstraitDefaultURIPrefix+"functions_aggregate_generic.yaml",
+		"count", nil)
+	require.NoError(t, err)
+	avg, err := b.AggregateFn(extensions.SubstraitDefaultURIPrefix+"functions_arithmetic.yaml",
+		"avg", nil, refB)
+	require.NoError(t, err)
+	assert.Equal(t, "fp32?", avg.GetType().String())
+
+	// the intermediate type of count is the same as its final i64,
+	// while the intermediate type of avg is a running sum and count
+	partialCount, err := b.MeasureWithPhase(count, nil, types.AggPhaseInitialToIntermediate)
+	require.NoError(t, err)
+	partialAvg, err := b.MeasureWithPhase(avg, nil, types.AggPhaseInitialToIntermediate)
+	require.NoError(t, err)
+	assert.Equal(t, types.AggPhaseInitialToIntermediate, partialCount.Measure().Phase())
+	assert.Equal(t, "i64", partialCount.Measure().GetType().String())
+	assert.Equal(t, "struct<fp64, i64>", partialAvg.Measure().GetType().String())
+
+	// the original function is left unchanged
+	assert.Equal(t, types.AggPhaseInitialToResult, avg.Phase())
+	assert.Equal(t, "fp32?", avg.GetType().String())
+
+	agg, err := b.AggregateColumns(scan, []plan.AggRelMeasure{partialCount, partialAvg}, 0)
+	require.NoError(t, err)
+	rt := agg.RecordType()
+	assert.Equal(t, "struct<string, i64, struct<fp64, i64>>", rt.String())
+
+	p, err := b.Plan(agg, []string{"a", "count", "avg", "sum", "n"})
+	require.NoError(t, err)
+	assert.NoError(t, p.Validate())
+
+	protoPlan, err := p.ToProto()
+	require.NoError(t, err)
+	measures := protoPlan.Relations[0].GetRoot().GetInput().GetAggregate().GetMeasures()
+	require.Len(t, measures, 2)
+	for _, m := range measures {
+		assert.Equal(t, substraitproto.AggregationPhase_AGGREGATION_PHASE_INITIAL_TO_INTERMEDIATE, m.Measure.Phase)
+	}
+	assert.NotNil(t, measures[1].Measure.OutputType.GetStruct())
+
+	roundTrip, err := plan.FromProto(protoPlan, &extensions.DefaultCollection)
+	require.NoError(t, err)
+	rtAgg := roundTrip.GetRoots()[0].Input().(*plan.AggregateRel)
+	for _, m := range rtAgg.Measures() {
+		assert.Equal(t, types.AggPhaseInitialToIntermediate, m.Measure().Phase())
+	}
+	rtType := rtAgg.RecordType()
+	assert.True(t, rt.Equals(&rtType))
+
+	roundTripProto, err := roundTrip.ToProto()
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(protoPlan, roundTripProto))
+
+	// the final phase produces the return type again
+	final, err := b.MeasureWithPhase(avg, nil, types.AggPhaseIntermediateToResult)
+	require.NoError(t, err)
+	assert.Equal(t, "fp32?", final.Measure().GetType().String())
+}
+
+func TestAggregateMeasureWithPhaseErrors(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	count, err := b.AggregateFn(extensions.SubstraitDefaultURIPrefix+"functions_aggregate_generic.yaml",
+		"count", nil)
+	require.NoError(t, err)
+
+	_, err = b.MeasureWithPhase(count, nil, types.AggregationPhase(10))
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "invalid aggregation phase")
+
+	_, err = b.MeasureWithPhase(nil, nil, types.AggPhaseInitialToIntermediate)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+
+	// variance is not decomposable, so has no intermediate type to produce
+	scan := b.NamedScan([]string{"test"}, baseSchema)
+	refB, err := b.RootFieldRef(scan, 1)
+	require.NoError(t, err)
+	variance, err := b.AggregateFn(extensions.SubstraitDefaultURIPrefix+"functions_arithmetic.yaml",
+		"variance", nil, refB)
+	require.NoError(t, err)
+	_, err = b.MeasureWithPhase(variance, nil, types.AggPhaseInitialToIntermediate)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidType)
+	assert.ErrorContains(t, err, "has no intermediate type")
+
+	m, err := b.MeasureWithPhase(variance, nil, types.AggPhaseInitialToResult)
+	require.NoError(t, err)
+	assert.Equal(t, "fp32?", m.Measure().GetType().String())
+}
+
+func TestAggregateMeasureWithOptionsErrors(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	scan := b.NamedScan([]string{"test"}, baseSchema)
+	wide, err := parser.ParseNamedStruct("NSTRUCT<a: string, b: fp32, c: i32>")
+	require.NoError(t, err)
+	outOfRange, err := b.RootFieldRef(b.NamedScan([]string{"wide"}, wide), 2)
+	require.NoError(t, err)
+
+	count, err := b.AggregateFn(extensions.SubstraitDefaultURIPrefix+"functions_aggregate_generic.yaml",
+		"count", nil)
+	require.NoError(t, err)
+
+	m := b.MeasureWithOptions(count, nil, types.AggInvocationAll,
+		expr.SortField{Expr: outOfRange, Kind: types.SortAscNullsFirst})
+	_, err = b.AggregateColumns(scan, []plan.AggRelMeasure{m}, 0)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+	assert.ErrorContains(t, err, "measure 0 sort 0: field reference index 2 out of range, input has 2 fields")
+
+	_, err = b.AggregateExprs(scan, []plan.AggRelMeasure{m})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+
+	m = b.MeasureWithOptions(count, nil, types.AggInvocationAll, expr.SortField{})
+	_, err = b.AggregateColumns(scan, []plan.AggRelMeasure{m})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "sort field 0 of measure 0 has nil expression")
+
+	m = b.MeasureWithOptions(count, nil, types.AggregationInvocation(10))
+	_, err = b.AggregateColumns(scan, []plan.AggRelMeasure{m})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "invalid invocation 10 for measure 0")
+}
+
+func TestFunctionOptions(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	scan := b.NamedScan([]string{"test"}, baseSchema2)
+	ref, err := b.RootFieldRef(scan, 0)
+	require.NoError(t, err)
+
+	add, err := b.ScalarFn(arithmeticURI, "add",
+		[]*types.FunctionOption{types.NewFunctionOption("overflow", "ERROR", "SATURATE")}, ref, ref)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ERROR", "SATURATE"}, add.GetOption("overflow"))
+
+	sum, err := b.AggregateFn(arithmeticURI, "sum",
+		[]*types.FunctionOption{types.NewFunctionOption("OVERFLOW", "silent")}, ref)
+	require.NoError(t, err)
+
+	project, err := b.Project(scan, add)
+	require.NoError(t, err)
+	agg, err := b.AggregateColumns(project, []plan.AggRelMeasure{b.Measure(sum, nil)})
+	require.NoError(t, err)
+
+	p, err := b.Plan(agg, []string{"total"})
+	require.NoError(t, err)
+	root := checkRelRoundTrip(t, p).(*plan.AggregateRel)
+	assert.Equal(t, []string{"silent"}, root.Measures()[0].Measure().GetOption("OVERFLOW"))
+	rtAdd := root.GetInputs()[0].(*plan.ProjectRel).Expressions()[0].(*expr.ScalarFunction)
+	assert.Equal(t, []string{"ERROR", "SATURATE"}, rtAdd.GetOption("overflow"))
+
+	_, err = b.ScalarFn(arithmeticURI, "add",
+		[]*types.FunctionOption{types.NewFunctionOption("rounding", "TIE_TO_EVEN")}, ref, ref)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, `function add:i32_i32 has no option "rounding"`)
+
+	_, err = b.ScalarFn(arithmeticURI, "add",
+		[]*types.FunctionOption{types.NewFunctionOption("overflow", "WRAP")}, ref, ref)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, `invalid value "WRAP" for option overflow of function add:i32_i32`)
+
+	_, err = b.AggregateFn(arithmeticURI, "sum",
+		[]*types.FunctionOption{types.NewFunctionOption("overflow", "WRAP")}, ref)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+}
+
+func TestEnumArgs(t *testing.T) {
+	const datetimeURI = extensions.SubstraitDefaultURIPrefix + "functions_datetime.yaml"
+
+	b := plan.NewBuilderDefault()
+	schema, err := parser.ParseNamedStruct("NSTRUCT<ts: timestamp>")
+	require.NoError(t, err)
+	scan := b.NamedScan([]string{"events"}, schema)
+	ref, err := b.RootFieldRef(scan, 0)
+	require.NoError(t, err)
+
+	extract, err := b.ScalarFn(datetimeURI, "extract", nil, types.Enum("YEAR"), ref)
+	require.NoError(t, err)
+	assert.Equal(t, "extract:req_ts", extract.CompoundName())
+	assert.Equal(t, "YEAR", extract.ToProto().GetScalarFunction().Arguments[0].GetEnum())
+
+	project, err := b.Project(scan, extract)
+	require.NoError(t, err)
+	p, err := b.Plan(project, []string{"ts", "year"})
+	require.NoError(t, err)
+
+	root := checkRelRoundTrip(t, p).(*plan.ProjectRel)
+	rtExtract := root.Expressions()[0].(*expr.ScalarFunction)
+	assert.Equal(t, types.Enum("YEAR"), rtExtract.Arg(0))
+
+	_, err = b.ScalarFn(datetimeURI, "extract", nil, types.Enum("FORTNIGHT"), ref)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, `invalid value "FORTNIGHT" for enum argument 0 of function extract:req_ts`)
+}
+
+func TestMergedCollectionRoundTrip(t *testing.T) {
+	const uri = "http://localhost/custom.yaml"
+	custom, err := extensions.LoadCollection(uri, strings.NewReader(`---
+scalar_functions:
+  - name: "is_even"
+    impls:
+      - args:
+          - name: x
+            value: i32
+        return: boolean
+`))
+	require.NoError(t, err)
+	merged := extensions.NewMergedCollection(&extensions.DefaultCollection, custom)
+
+	b := plan.NewBuilder(merged)
+	scan := b.NamedScan([]string{"test"}, baseSchema2)
+	refX, err := b.RootFieldRef(scan, 0)
+	require.NoError(t, err)
+	isEven, err := b.ScalarFn(uri, "is_even", nil, refX)
+	require.NoError(t, err)
+	filter, err := b.Filter(scan, isEven)
+	require.NoError(t, err)
+	p, err := b.Plan(filter, []string{"x", "y"})
+	require.NoError(t, err)
+
+	protoPlan, err := p.ToProto()
+	require.NoError(t, err)
+
+	roundTrip, err := plan.FromProto(protoPlan, merged)
+	require.NoError(t, err)
+	assert.NoError(t, roundTrip.Validate())
+	cond := roundTrip.GetRoots()[0].Input().(*plan.FilterRel).Condition()
+	assert.Equal(t, "is_even", cond.(*expr.ScalarFunction).Name())
+
+	roundTripProto, err := roundTrip.ToProto()
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(protoPlan, roundTripProto))
+}
+
+func TestTypeArgs(t *testing.T) {
+	const uri = "http://localhost/custom.yaml"
+	custom, err := extensions.LoadCollection(uri, strings.NewReader(`---
+scalar_functions:
+  - name: "format_as"
+    impls:
+      - args:
+          - name: x
+            value: i32
+          - name: t
+            type: string
+        return: string
+`))
+	require.NoError(t, err)
+	merged := extensions.NewMergedCollection(&extensions.DefaultCollection, custom)
+
+	b := plan.NewBuilder(merged)
+	scan := b.NamedScan([]string{"test"}, baseSchema2)
+	refX, err := b.RootFieldRef(scan, 0)
+	require.NoError(t, err)
+
+	strType := &types.StringType{Nullability: types.NullabilityRequired}
+	formatAs, err := b.ScalarFn(uri, "format_as", nil, refX, strType)
+	require.NoError(t, err)
+	assert.Equal(t, "format_as:i32_type", formatAs.CompoundName())
+	assert.Equal(t, "string", formatAs.GetType().String())
+
+	args := formatAs.ToProto().GetScalarFunction().Arguments
+	require.Len(t, args, 2)
+	assert.NotNil(t, args[0].GetValue())
+	require.IsType(t, &substraitproto.FunctionArgument_Type{}, args[1].ArgType)
+	assert.NotNil(t, args[1].GetType().GetString_())
+
+	project, err := b.Project(scan, formatAs)
+	require.NoError(t, err)
+	p, err := b.Plan(project, []string{"x", "y", "z"})
+	require.NoError(t, err)
+
+	protoPlan, err := p.ToProto()
+	require.NoError(t, err)
+	roundTrip, err := plan.FromProto(protoPlan, merged)
+	require.NoError(t, err)
+	assert.NoError(t, roundTrip.Validate())
+	fn := roundTrip.GetRoots()[0].Input().(*plan.ProjectRel).Expressions()[0].(*expr.ScalarFunction)
+	assert.True(t, strType.Equals(fn.Arg(1).(types.Type)))
+
+	roundTripProto, err := roundTrip.ToProto()
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(protoPlan, roundTripProto))
+
+	_, err = b.ScalarFn(uri, "format_as:i32_type", nil, refX, &types.Int64Type{})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidType)
+	assert.ErrorContains(t, err, "arg #1 (t) should be a type matching string, got i64")
+
+	_, err = b.ScalarFn(uri, "format_as:i32_type", nil, refX, refX)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidType)
+	assert.ErrorContains(t, err, "arg #1 should be a type, got a value")
+
+	_, err = b.ScalarFn(uri, "format_as:i32_type", nil, &types.Int32Type{}, strType)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidType)
+	assert.ErrorContains(t, err, "arg #0 should not be a type")
+}
+
+func TestPlanJSON(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	join := buildJoinOverFilter(t, b)
+	p, err := b.Plan(join, []string{"a", "x"})
+	require.NoError(t, err)
+
+	data, err := json.Marshal(p)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"type":"JOIN_TYPE_INNER"`)
+
+	roundTrip, err := plan.UnmarshalJSON(data, &extensions.DefaultCollection)
+	require.NoError(t, err)
+
+	expected, err := p.ToProto()
+	require.NoError(t, err)
+	actual, err := roundTrip.ToProto()
+	require.NoError(t, err)
+	assert.Truef(t, proto.Equal(expected, actual), "expected: %s\ngot: %s",
+		protojson.Format(expected), protojson.Format(actual))
+
+	_, err = plan.UnmarshalJSON([]byte(`{"relations": 5}`), &extensions.DefaultCollection)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+}
+
+func TestRelCommonAdvancedExtension(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	scan := b.NamedScan([]string{"test"}, baseSchema)
+	p, err := b.Plan(scan, []string{"a", "b"})
+	require.NoError(t, err)
+
+	protoPlan, err := p.ToProto()
+	require.NoError(t, err)
+
+	enhancement, err := anypb.New(wrapperspb.String("opaque engine data"))
+	require.NoError(t, err)
+	optimization, err := anypb.New(wrapperspb.Int64(42))
+	require.NoError(t, err)
+	ext := &extensions.AdvancedExtension{
+		Enhancement:  enhancement,
+		Optimization: []*anypb.Any{optimization},
+	}
+	read := protoPlan.Relations[0].GetRoot().Input.GetRead()
+	read.Common.AdvancedExtension = ext
+
+	roundTrip, err := plan.FromProto(protoPlan, &extensions.DefaultCollection)
+	require.NoError(t, err)
+	rel := roundTrip.GetRoots()[0].Input()
+	assert.Truef(t, proto.Equal(ext, rel.AdvancedExtension()), "got: %s", rel.AdvancedExtension())
+	assert.Nil(t, rel.GetAdvancedExtension())
+
+	out, err := roundTrip.ToProto()
+	require.NoError(t, err)
+	actual := out.Relations[0].GetRoot().Input.GetRead().Common.AdvancedExtension
+	assert.Equal(t, enhancement.TypeUrl, actual.Enhancement.TypeUrl)
+	assert.Equal(t, enhancement.Value, actual.Enhancement.Value)
+	require.Len(t, actual.Optimization, 1)
+	assert.Equal(t, optimization.Value, actual.Optimization[0].Value)
+
+	// set on a relation built by hand
+	scan.SetAdvancedExtension(ext)
+	assert.Same(t, ext, scan.AdvancedExtension())
+	assert.Truef(t, proto.Equal(ext, scan.ToProto().GetRead().Common.AdvancedExtension),
+		"got: %s", scan.ToProto().GetRead().Common)
+
+	// a relation without any common fields is read as is
+	filter := &substraitproto.Rel{RelType: &substraitproto.Rel_Filter{Filter: &substraitproto.FilterRel{
+		Input:     out.Relations[0].GetRoot().Input,
+		Condition: expr.NewPrimitiveLiteral(true, false).ToProto(),
+	}}}
+	rel, err = plan.RelFromProto(filter, roundTrip.ExtensionRegistry())
+	require.NoError(t, err)
+	assert.Nil(t, rel.AdvancedExtension())
+}
+
+func TestWithOutputMapping(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	scan := b.NamedScan([]string{"test"}, baseSchema)
+	assert.Nil(t, scan.OutputMapping())
+
+	remapped, err := scan.WithOutputMapping([]int32{1, 1, 0})
+	require.NoError(t, err)
+	assert.Equal(t, []int32{1, 1, 0}, remapped.OutputMapping())
+	assert.Equal(t, "struct<fp32, fp32, string>", outputString(remapped))
+	assert.Equal(t, []int32{1, 1, 0}, remapped.ToProto().GetRead().Common.GetEmit().OutputMapping)
+	// the original is unchanged
+	assert.Nil(t, scan.OutputMapping())
+	assert.Equal(t, "struct<string, fp32>", outputString(scan))
+
+	direct, err := remapped.WithOutputMapping(nil)
+	require.NoError(t, err)
+	assert.Nil(t, direct.OutputMapping())
+	assert.Equal(t, "struct<string, fp32>", outputString(direct))
+	assert.NotNil(t, direct.ToProto().GetRead().Common.GetDirect())
+
+	_, err = scan.WithOutputMapping([]int32{0, 2})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+	assert.ErrorContains(t, err, "index 2, relation has 2 columns")
+	_, err = scan.WithOutputMapping([]int32{-1})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+
+	// relations with inputs are copied too
+	join := buildJoinOverFilter(t, b)
+	pruned, err := join.WithOutputMapping([]int32{3})
+	require.NoError(t, err)
+	assert.IsType(t, &plan.JoinRel{}, pruned)
+	assert.Equal(t, join.GetInputs(), pruned.GetInputs())
+	assert.Equal(t, "struct<boolean>", outputString(pruned))
+	assert.Equal(t, []int32{0, 2}, join.OutputMapping())
+}
+
+func outputString(rel plan.Rel) string {
+	out := rel.Remap(rel.RecordType())
+	return out.String()
+}
+
+func TestDdlCreateTable(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	ddl, err := b.Ddl(plan.DdlOpCreate, plan.DdlObjectTable, baseSchema, []string{"main", "test"})
+	require.NoError(t, err)
+
+	assert.Equal(t, plan.DdlOpCreate, ddl.Op())
+	assert.Equal(t, plan.DdlObjectTable, ddl.Object())
+	assert.Equal(t, []string{"main", "test"}, ddl.Names())
+	assert.Empty(t, ddl.GetInputs())
+	assert.Nil(t, ddl.ViewDefinition())
+	assert.Empty(t, ddl.RecordType().Types)
+
+	p, err := b.Plan(ddl, []string{})
+	require.NoError(t, err)
+	require.NoError(t, p.Validate())
+
+	protoPlan, err := p.ToProto()
+	require.NoError(t, err)
+	ddlProto := protoPlan.Relations[0].GetRoot().Input.GetDdl()
+	require.NotNil(t, ddlProto)
+	assert.Equal(t, substraitproto.DdlRel_DDL_OP_CREATE, ddlProto.Op)
+	assert.Equal(t, substraitproto.DdlRel_DDL_OBJECT_TABLE, ddlProto.Object)
+	assert.Equal(t, []string{"main", "test"}, ddlProto.GetNamedObject().Names)
+	assert.Equal(t, []string{"a", "b"}, ddlProto.TableSchema.Names)
+
+	roundTrip, err := plan.FromProto(protoPlan, &extensions.DefaultCollection)
+	require.NoError(t, err)
+	rt, ok := roundTrip.GetRoots()[0].Input().(*plan.DdlRel)
+	require.True(t, ok)
+	assert.Equal(t, plan.DdlOpCreate, rt.Op())
+	assert.Equal(t, plan.DdlObjectTable, rt.Object())
+	assert.Equal(t, []string{"main", "test"}, rt.Names())
+	schema := rt.TableSchema()
+	assert.Equal(t, "NSTRUCT<a: string, b: fp32>", schema.String())
+
+	again, err := roundTrip.ToProto()
+	require.NoError(t, err)
+	assert.Truef(t, proto.Equal(protoPlan, again), "expected: %s\ngot: %s",
+		protojson.Format(protoPlan), protojson.Format(again))
+}
+
+func TestDdlView(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	scan := b.NamedScan([]string{"test"}, baseSchema)
+	ddl, err := b.DdlView(plan.DdlOpCreateOrReplace, baseSchema, []string{"v"}, scan)
+	require.NoError(t, err)
+	assert.Equal(t, plan.DdlObjectView, ddl.Object())
+	assert.Equal(t, []plan.Rel{scan}, ddl.GetInputs())
+
+	p, err := b.Plan(ddl, []string{})
+	require.NoError(t, err)
+	protoPlan, err := p.ToProto()
+	require.NoError(t, err)
+	assert.NotNil(t, protoPlan.Relations[0].GetRoot().Input.GetDdl().ViewDefinition.GetRead())
+
+	roundTrip, err := plan.FromProto(protoPlan, &extensions.DefaultCollection)
+	require.NoError(t, err)
+	again, err := roundTrip.ToProto()
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(protoPlan, again))
+
+	drop, err := b.DdlView(plan.DdlOpDropIfExist, baseSchema, []string{"v"}, nil)
+	require.NoError(t, err)
+	assert.Empty(t, drop.GetInputs())
+}
+
+func TestDdlErrors(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	tests := []struct {
+		name string
+		fn   func() error
+		err  error
+		msg  string
+	}{
+		{"no schema", func() error {
+			_, err := b.Ddl(plan.DdlOpCreate, plan.DdlObjectTable, types.NamedStruct{}, []string{"t"})
+			return err
+		}, substraitgo.ErrInvalidRel, "ddl relation requires a schema"},
+		{"no names", func() error {
+			_, err := b.Ddl(plan.DdlOpDrop, plan.DdlObjectTable, baseSchema, nil)
+			return err
+		}, substraitgo.ErrInvalidArg, "requires the name of the DDL_OBJECT_TABLE"},
+		{"unspecified op", func() error {
+			_, err := b.Ddl(plan.DdlOpUnspecified, plan.DdlObjectTable, baseSchema, []string{"t"})
+			return err
+		}, substraitgo.ErrInvalidArg, "invalid operation"},
+		{"unspecified object", func() error {
+			_, err := b.Ddl(plan.DdlOpCreate, plan.DdlObjectUnspecified, baseSchema, []string{"t"})
+			return err
+		}, substraitgo.ErrInvalidArg, "invalid object"},
+		{"mismatched view", func() error {
+			_, err := b.DdlView(plan.DdlOpCreate, baseSchema, []string{"v"},
+				b.NamedScan([]string{"test2"}, baseSchema2))
+			return err
+		}, substraitgo.ErrInvalidRel, "view definition record type struct<i32, boolean> does not match schema struct<string, fp32>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.fn()
+			assert.ErrorIs(t, err, tt.err)
+			assert.ErrorContains(t, err, tt.msg)
+		})
+	}
+}
+
+func checkRelRoundTrip(t *testing.T, p *plan.Plan) plan.Rel {
+	t.Helper()
+	protoPlan, err := p.ToProto()
+	require.NoError(t, err)
+
+	roundTrip, err := plan.FromProto(protoPlan, &extensions.DefaultCollection)
+	require.NoError(t, err)
+	require.NoError(t, roundTrip.Validate())
+
+	again, err := roundTrip.ToProto()
+	require.NoError(t, err)
+	assert.Truef(t, proto.Equal(protoPlan, again), "expected: %s\ngot: %s",
+		protojson.Format(protoPlan), protojson.Format(again))
+	return roundTrip.GetRoots()[0].Input()
+}
+
+func TestHashJoin(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	left := b.NamedScan([]string{"left"}, baseSchema)
+	right := b.NamedScan([]string{"right"}, baseSchema)
+
+	leftKey, err := b.RootFieldRef(left, 0)
+	require.NoError(t, err)
+	rightKey, err := b.RootFieldRef(right, 0)
+	require.NoError(t, err)
+
+	join, err := b.HashJoin(left, right, []expr.Expression{leftKey}, []expr.Expression{rightKey}, plan.JoinTypeInner)
+	require.NoError(t, err)
+	assert.Equal(t, plan.HashMergeInner, join.Type())
+	assert.Equal(t, "struct<string, fp32, string, fp32>", outputString(join))
+
+	p, err := b.Plan(join, []string{"a", "b", "c", "d"})
+	require.NoError(t, err)
+	rt := checkRelRoundTrip(t, p)
+	require.IsType(t, &plan.HashJoinRel{}, rt)
+	assert.Equal(t, plan.HashMergeInner, rt.(*plan.HashJoinRel).Type())
+	assert.Len(t, rt.(*plan.HashJoinRel).LeftKeys(), 1)
+
+	leftJoin, err := b.HashJoin(left, right, []expr.Expression{leftKey}, []expr.Expression{rightKey}, plan.JoinTypeLeft)
+	require.NoError(t, err)
+	assert.Equal(t, "struct<string, fp32, string?, fp32?>", outputString(leftJoin))
+
+	semi, err := b.HashJoin(left, right, []expr.Expression{leftKey}, []expr.Expression{rightKey}, plan.JoinTypeLeftSemi)
+	require.NoError(t, err)
+	assert.Equal(t, plan.HashMergeLeftSemi, semi.Type())
+	assert.Equal(t, "struct<string, fp32>", outputString(semi))
+
+	p, err = b.Plan(semi, []string{"a", "b"})
+	require.NoError(t, err)
+	checkRelRoundTrip(t, p)
+}
+
+func TestMergeJoin(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	left := b.NamedScan([]string{"left"}, baseSchema)
+	right := b.NamedScan([]string{"right"}, baseSchema)
+
+	var leftKeys, rightKeys []expr.Expression
+	for i := int32(0); i < 2; i++ {
+		lk, err := b.RootFieldRef(left, i)
+		require.NoError(t, err)
+		rk, err := b.RootFieldRef(right, i)
+		require.NoError(t, err)
+		leftKeys, rightKeys = append(leftKeys, lk), append(rightKeys, rk)
+	}
+
+	join, err := b.MergeJoin(left, right, leftKeys, rightKeys, plan.JoinTypeOuter)
+	require.NoError(t, err)
+	assert.Equal(t, plan.HashMergeOuter, join.Type())
+	assert.Equal(t, "struct<string?, fp32?, string?, fp32?>", outputString(join))
+
+	p, err := b.Plan(join, []string{"a", "b", "c", "d"})
+	require.NoError(t, err)
+	rt := checkRelRoundTrip(t, p)
+	require.IsType(t, &plan.MergeJoinRel{}, rt)
+	assert.Len(t, rt.(*plan.MergeJoinRel).RightKeys(), 2)
+	assert.Equal(t, "struct<string?, fp32?, string?, fp32?>", outputString(rt))
+}
+
+func TestNestedLoopJoin(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	left := b.NamedScan([]string{"left"}, baseSchema)
+	right := b.NamedScan([]string{"right"}, baseSchema2)
+
+	cond, err := b.JoinedRecordFieldRef(left, right, 3)
+	require.NoError(t, err)
+
+	join, err := b.NestedLoopJoin(left, right, cond, plan.JoinTypeRight)
+	require.NoError(t, err)
+	assert.Equal(t, plan.JoinTypeRight, join.Type())
+	assert.Equal(t, "struct<string?, fp32?, i32, boolean>", outputString(join))
+
+	p, err := b.Plan(join, []string{"a", "b", "x", "y"})
+	require.NoError(t, err)
+	rt := checkRelRoundTrip(t, p)
+	require.IsType(t, &plan.NestedLoopJoinRel{}, rt)
+	assert.Equal(t, plan.JoinTypeRight, rt.(*plan.NestedLoopJoinRel).Type())
+	assert.True(t, cond.Equals(rt.(*plan.NestedLoopJoinRel).Expr()))
+
+	// without an expression it is a cartesian join
+	cross, err := b.NestedLoopJoin(left, right, nil, plan.JoinTypeInner)
+	require.NoError(t, err)
+	assert.Equal(t, "boolean(true)", cross.Expr().String())
+	assert.Nil(t, cross.ToProto().GetNestedLoopJoin().Expression)
+	p, err = b.Plan(cross, []string{"a", "b", "x", "y"})
+	require.NoError(t, err)
+	checkRelRoundTrip(t, p)
+}
+
+func TestPhysicalJoinErrors(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	left := b.NamedScan([]string{"left"}, baseSchema)
+	right := b.NamedScan([]string{"right"}, baseSchema2)
+
+	leftStr, err := b.RootFieldRef(left, 0)
+	require.NoError(t, err)
+	leftFloat, err := b.RootFieldRef(left, 1)
+	require.NoError(t, err)
+	rightInt, err := b.RootFieldRef(right, 0)
+	require.NoError(t, err)
+
+	keys := func(e ...expr.Expression) []expr.Expression { return e }
+
+	_, err = b.HashJoin(nil, right, keys(leftStr), keys(rightInt), plan.JoinTypeInner)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+
+	_, err = b.HashJoin(left, right, keys(leftStr, leftFloat), keys(rightInt), plan.JoinTypeInner)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "mismatched number of keys for hash join, left: 2, right: 1")
+
+	_, err = b.MergeJoin(left, right, nil, nil, plan.JoinTypeInner)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "merge join requires at least one key")
+
+	_, err = b.MergeJoin(left, right, keys(leftStr), keys(rightInt), plan.JoinTypeInner)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "merge join key 0 has mismatched types string and i32")
+
+	_, err = b.HashJoin(left, right, keys(leftStr), keys(expr.NewPrimitiveLiteral("x", false)), plan.JoinTypeInner)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidExpr)
+	assert.ErrorContains(t, err, "right join key 0 must be a root field reference")
+
+	_, err = b.HashJoin(left, right, keys(leftStr), keys(rightInt), plan.JoinTypeUnspecified)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "invalid join type JOIN_TYPE_UNSPECIFIED for hash join")
+
+	_, err = b.NestedLoopJoin(left, right, leftStr, plan.JoinTypeInner)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "must yield boolean, not string")
+
+	_, err = b.NestedLoopJoin(left, right, nil, plan.JoinTypeUnspecified)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+}
+
+func TestLocalFilesTextOptions(t *testing.T) {
+	textOpts := &substraitproto.ReadRel_LocalFiles_FileOrFiles_DelimiterSeparatedTextReadOptions{
+		FieldDelimiter:     "|",
+		MaxLineSize:        4096,
+		Quote:              "\"",
+		HeaderLinesToSkip:  1,
+		Escape:             "\\",
+		ValueTreatedAsNull: proto.String(""),
+	}
+	protoPlan := &substraitproto.Plan{
+		Version: &substraitproto.Version{MajorNumber: 0, MinorNumber: 29},
+		Relations: []*substraitproto.PlanRel{{
+			RelType: &substraitproto.PlanRel_Root{Root: &substraitproto.RelRoot{
+				Names: []string{"a", "b"},
+				Input: &substraitproto.Rel{RelType: &substraitproto.Rel_Read{Read: &substraitproto.ReadRel{
+					Common: &substraitproto.RelCommon{EmitKind: &substraitproto.RelCommon_Direct_{
+						Direct: &substraitproto.RelCommon_Direct{}}},
+					BaseSchema: baseSchema.ToProto(),
+					ReadType: &substraitproto.ReadRel_LocalFiles_{LocalFiles: &substraitproto.ReadRel_LocalFiles{
+						Items: []*substraitproto.ReadRel_LocalFiles_FileOrFiles{{
+							PathType:   &substraitproto.ReadRel_LocalFiles_FileOrFiles_UriFile{UriFile: "file:///data/test.csv"},
+							FileFormat: &substraitproto.ReadRel_LocalFiles_FileOrFiles_Text{Text: textOpts},
+						}},
+					}},
+				}}},
+			}},
+		}},
+	}
+
+	p, err := plan.FromProto(protoPlan, &extensions.DefaultCollection)
+	require.NoError(t, err)
+	read, ok := p.GetRoots()[0].Input().(*plan.LocalFileReadRel)
+	require.True(t, ok)
+	item := read.Item(0)
+	assert.Equal(t, plan.URIFile, item.PathType)
+	require.IsType(t, &plan.TextReadOptions{}, item.Format)
+	format := item.Format.(*plan.TextReadOptions)
+	assert.Equal(t, "|", format.FieldDelimiter)
+	assert.EqualValues(t, 1, format.HeaderLinesToSkip)
+
+	again, err := p.ToProto()
+	require.NoError(t, err)
+	assert.Truef(t, proto.Equal(protoPlan, again), "expected: %s\ngot: %s",
+		protojson.Format(protoPlan), protojson.Format(again))
+
+	// the clone doesn't share the options
+	clone := read.Clone().(*plan.LocalFileReadRel)
+	clone.Item(0).Format.(*plan.TextReadOptions).FieldDelimiter = ","
+	assert.Equal(t, "|", format.FieldDelimiter)
+}
+
+func TestNamedScanWithAdvancedExtension(t *testing.T) {
+	snapshot, err := anypb.New(wrapperspb.Int64(3051729675574597004))
+	require.NoError(t, err)
+
+	b := plan.NewBuilderDefault()
+	scan := b.NamedScanWithAdvancedExtension([]string{"db", "events"}, baseSchema, snapshot)
+	assert.Same(t, snapshot, scan.GetAdvancedExtension().Enhancement)
+	assert.Nil(t, b.NamedScanWithAdvancedExtension([]string{"test"}, baseSchema, nil).GetAdvancedExtension())
+
+	p, err := b.Plan(scan, []string{"a", "b"})
+	require.NoError(t, err)
+	protoPlan, err := p.ToProto()
+	require.NoError(t, err)
+	read := protoPlan.Relations[0].GetRoot().Input.GetRead()
+	assert.True(t, proto.Equal(snapshot, read.AdvancedExtension.Enhancement))
+
+	roundTrip, err := plan.FromProto(protoPlan, &extensions.DefaultCollection)
+	require.NoError(t, err)
+	rtScan := roundTrip.GetRoots()[0].Input().(*plan.NamedTableReadRel)
+	assert.True(t, proto.Equal(snapshot, rtScan.GetAdvancedExtension().Enhancement))
+	again, err := roundTrip.ToProto()
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(protoPlan, again))
+}
+
+func TestReferenceRel(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	scan := b.NamedScan([]string{"test"}, baseSchema)
+	shared, err := b.AddRelation(scan)
+	require.NoError(t, err)
+	assert.EqualValues(t, 0, shared)
+
+	ref, err := b.Reference(shared)
+	require.NoError(t, err)
+	assert.EqualValues(t, 0, ref.SubtreeOrdinal())
+	assert.Equal(t, "struct<string, fp32>", outputString(ref))
+
+	col, err := b.RootFieldRef(ref, 1)
+	require.NoError(t, err)
+	fetch, err := b.Fetch(ref, 0, 10)
+	require.NoError(t, err)
+	first, err := b.AddRoot(fetch, []string{"a", "b"})
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, first)
+
+	project, err := b.Project(ref, col)
+	require.NoError(t, err)
+	p, err := b.Plan(project, []string{"a", "b", "c"})
+	require.NoError(t, err)
+
+	rels := p.Relations()
+	require.Len(t, rels, 3)
+	assert.False(t, rels[0].IsRoot())
+	assert.Same(t, scan, rels[0].Rel())
+	require.Len(t, p.GetRoots(), 2)
+	assert.Equal(t, []string{"a", "b"}, p.GetRoots()[0].Names())
+
+	protoPlan, err := p.ToProto()
+	require.NoError(t, err)
+	require.Len(t, protoPlan.Relations, 3)
+	assert.NotNil(t, protoPlan.Relations[0].GetRel().GetRead())
+	assert.EqualValues(t, 0, protoPlan.Relations[1].GetRoot().Input.GetFetch().Input.GetReference().SubtreeOrdinal)
+	assert.EqualValues(t, 0, protoPlan.Relations[2].GetRoot().Input.GetProject().Input.GetReference().SubtreeOrdinal)
+
+	roundTrip, err := plan.FromProto(protoPlan, &extensions.DefaultCollection)
+	require.NoError(t, err)
+	require.NoError(t, roundTrip.Validate())
+	again, err := roundTrip.ToProto()
+	require.NoError(t, err)
+	assert.Truef(t, proto.Equal(protoPlan, again), "expected: %s\ngot: %s",
+		protojson.Format(protoPlan), protojson.Format(again))
+
+	rtProject := roundTrip.GetRoots()[1].Input()
+	require.IsType(t, &plan.ReferenceRel{}, rtProject.GetInputs()[0])
+	assert.Equal(t, "struct<string, fp32, fp32>", outputString(rtProject))
+}
+
+func TestReferenceRelErrors(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	_, err := b.Reference(0)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "reference ordinal 0 out of range, 0 relations have been added")
+
+	_, err = b.AddRelation(nil)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+
+	scan := b.NamedScan([]string{"test"}, baseSchema)
+	_, err = b.AddRoot(scan, []string{"a"})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+
+	ordinal, err := b.AddRelation(scan)
+	require.NoError(t, err)
+	ref, err := b.Reference(ordinal)
+	require.NoError(t, err)
+	_, err = ref.WithOutputMapping([]int32{1})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+
+	// a reference may only refer to a preceding relation
+	protoPlan, err := b.Plan(ref, []string{"a", "b"})
+	require.NoError(t, err)
+	out, err := protoPlan.ToProto()
+	require.NoError(t, err)
+	out.Relations[0], out.Relations[1] = out.Relations[1], out.Relations[0]
+	_, err = plan.FromProto(out, &extensions.DefaultCollection)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+	assert.ErrorContains(t, err, "subtree ordinal 0 must refer to one of the 0 preceding plan relations")
+}
+
+func TestReferenceRelCycle(t *testing.T) {
+	// references produced by another builder aren't limited to the
+	// relations already added to this one
+	other := plan.NewBuilderDefault()
+	for i := 0; i < 2; i++ {
+		_, err := other.AddRelation(other.NamedScan([]string{"test"}, baseSchema))
+		require.NoError(t, err)
+	}
+	refToSecond, err := other.Reference(1)
+	require.NoError(t, err)
+
+	b := plan.NewBuilderDefault()
+	fetch, err := b.Fetch(refToSecond, 0, 10)
+	require.NoError(t, err)
+	first, err := b.AddRelation(fetch)
+	require.NoError(t, err)
+	refToFirst, err := b.Reference(first)
+	require.NoError(t, err)
+	filter, err := b.Filter(refToFirst, expr.NewPrimitiveLiteral(true, false))
+	require.NoError(t, err)
+	_, err = b.AddRelation(filter)
+	require.NoError(t, err)
+
+	_, err = b.Plan(b.NamedScan([]string{"test"}, baseSchema), []string{"a", "b"})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+	assert.ErrorContains(t, err, "plan relations form a reference cycle: relations[0] -> relations[1] -> relations[0]")
+
+	_, err = b.MultiRootPlan([]plan.Rel{refToFirst}, [][]string{{"a", "b"}})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+
+	_, err = plan.NewBuilderDefault().Plan(refToSecond, []string{"a", "b"})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+	assert.ErrorContains(t, err, "ReferenceRel in relations[0] has subtree ordinal 1, but the plan has 1 relations")
+}
+
+func TestMultiRootPlan(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	scan := b.NamedScan([]string{"test"}, baseSchema)
+	scan2 := b.NamedScan([]string{"test2"}, baseSchema2)
+
+	p, err := b.MultiRootPlan([]plan.Rel{scan2, scan}, [][]string{{"x", "y"}, {"a", "b"}})
+	require.NoError(t, err)
+	require.NoError(t, p.Validate())
+
+	protoPlan, err := p.ToProto()
+	require.NoError(t, err)
+	require.Len(t, protoPlan.Relations, 2)
+	assert.Equal(t, []string{"x", "y"}, protoPlan.Relations[0].GetRoot().Names)
+	assert.Equal(t, []string{"a", "b"}, protoPlan.Relations[1].GetRoot().Names)
+
+	roundTrip, err := plan.FromProto(protoPlan, &extensions.DefaultCollection)
+	require.NoError(t, err)
+	roots := roundTrip.GetRoots()
+	require.Len(t, roots, 2)
+	assert.Equal(t, []string{"test2"}, roots[0].Input().(*plan.NamedTableReadRel).Names())
+	assert.Equal(t, []string{"x", "y"}, roots[0].Names())
+	assert.Equal(t, []string{"test"}, roots[1].Input().(*plan.NamedTableReadRel).Names())
+	assert.Equal(t, []string{"a", "b"}, roots[1].Names())
+
+	roundTripProto, err := roundTrip.ToProto()
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(protoPlan, roundTripProto))
+
+	_, err = b.MultiRootPlan([]plan.Rel{scan, scan2}, [][]string{{"a", "b"}})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "got 2 root relations but 1 lists of names")
+
+	_, err = b.MultiRootPlan([]plan.Rel{scan, scan2}, [][]string{{"a", "b"}, {"x"}})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+	assert.ErrorContains(t, err, "root 1: invalid relation: mismatched number of names")
+
+	_, err = b.MultiRootPlan(nil, nil)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+}
+
+func TestFilterInList(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	scan := b.NamedScan([]string{"test"}, baseSchema2)
+	x, err := b.RootFieldRef(scan, 0)
+	require.NoError(t, err)
+
+	// WHERE x IN (1, 2, 3)
+	in, err := b.InList(x, expr.NewPrimitiveLiteral[int32](1, false),
+		expr.NewPrimitiveLiteral[int32](2, false), expr.NewPrimitiveLiteral[int32](3, false))
+	require.NoError(t, err)
+	filter, err := b.Filter(scan, in)
+	require.NoError(t, err)
+
+	p, err := b.Plan(filter, []string{"x", "y"})
+	require.NoError(t, err)
+	root := checkRelRoundTrip(t, p).(*plan.FilterRel)
+	assert.Equal(t, ".field(0) => i32 IN [i32(1),i32(2),i32(3)]", root.Condition().String())
+
+	_, err = b.InList(x, expr.NewPrimitiveLiteral("a", false))
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidExpr)
+}
+
+func TestBuilderReset(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	scan := b.NamedScan([]string{"test"}, baseSchema2)
+	refX, err := b.RootFieldRef(scan, 0)
+	require.NoError(t, err)
+	sum, err := b.AggregateFn(arithmeticURI, "sum", nil, refX)
+	require.NoError(t, err)
+	agg, err := b.AggregateColumns(scan, []plan.AggRelMeasure{b.Measure(sum, nil)})
+	require.NoError(t, err)
+	_, err = b.AddRelation(b.NamedScan([]string{"other"}, baseSchema))
+	require.NoError(t, err)
+
+	first, err := b.Plan(agg, []string{"total"})
+	require.NoError(t, err)
+	firstProto, err := first.ToProto()
+	require.NoError(t, err)
+	require.Len(t, firstProto.ExtensionUris, 1)
+	assert.Equal(t, arithmeticURI, firstProto.ExtensionUris[0].Uri)
+	assert.Len(t, firstProto.Relations, 2)
+
+	b.Reset()
+
+	scan = b.NamedScan([]string{"test"}, baseSchema2)
+	count, err := b.AggregateFn(extensions.SubstraitDefaultURIPrefix+"functions_aggregate_generic.yaml",
+		"count", nil)
+	require.NoError(t, err)
+	agg, err = b.AggregateColumns(scan, []plan.AggRelMeasure{b.Measure(count, nil)})
+	require.NoError(t, err)
+
+	second, err := b.Plan(agg, []string{"cnt"})
+	require.NoError(t, err)
+	assert.NoError(t, second.Validate())
+	secondProto, err := second.ToProto()
+	require.NoError(t, err)
+	require.Len(t, secondProto.ExtensionUris, 1)
+	assert.Equal(t, extensions.SubstraitDefaultURIPrefix+"functions_aggregate_generic.yaml",
+		secondProto.ExtensionUris[0].Uri)
+	require.Len(t, secondProto.Extensions, 1)
+	assert.Equal(t, "count:", secondProto.Extensions[0].GetExtensionFunction().GetName())
+	assert.Len(t, secondProto.Relations, 1)
+
+	// the first plan is unaffected by the functions used after the reset
+	afterProto, err := first.ToProto()
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(firstProto, afterProto))
+	assert.NoError(t, first.Validate())
+}
+
+func TestConcurrentBuilders(t *testing.T) {
+	// each goroutine uses its own builder, run with -race to check that
+	// the builders don't share any mutable state
+	const n = 8
+	build := func(i int) (*substraitproto.Plan, error) {
+		b := plan.NewBuilderDefault()
+		scan := b.NamedScan([]string{fmt.Sprintf("table%d", i)}, baseSchema2)
+		refX, err := b.RootFieldRef(scan, 0)
+		if err != nil {
+			return nil, err
+		}
+		add, err := b.ScalarFn(arithmeticURI, "add", nil, refX, expr.NewPrimitiveLiteral(int32(i), false))
+		if err != nil {
+			return nil, err
+		}
+		proj, err := b.ProjectRemap(scan, []int32{2}, add)
+		if err != nil {
+			return nil, err
+		}
+		p, err := b.Plan(proj, []string{"x"})
+		if err != nil {
+			return nil, err
+		}
+		return p.ToProto()
+	}
+
+	var (
+		wg      sync.WaitGroup
+		results [n]*substraitproto.Plan
+		errs    [n]error
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = build(i)
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < n; i++ {
+		require.NoError(t, errs[i])
+		expected, err := build(i)
+		require.NoError(t, err)
+		assert.Truef(t, proto.Equal(expected, results[i]), "plan %d: expected %s\ngot %s",
+			i, expected, results[i])
+
+		require.Len(t, results[i].ExtensionUris, 1)
+		assert.Equal(t, arithmeticURI, results[i].ExtensionUris[0].Uri)
+		project := results[i].Relations[0].GetRoot().GetInput().GetProject()
+		assert.Equal(t, []string{fmt.Sprintf("table%d", i)},
+			project.GetInput().GetRead().GetNamedTable().GetNames())
+		assert.EqualValues(t, i, project.GetExpressions()[0].GetScalarFunction().
+			GetArguments()[1].GetValue().GetLiteral().GetI32())
+	}
+}
+
+func TestNestedStructNames(t *testing.T) {
+	schema := types.NamedStruct{Names: []string{"a", "b", "x", "y"},
+		Struct: types.StructType{
+			Nullability: types.NullabilityRequired,
+			Types: []types.Type{
+				&types.Int32Type{Nullability: types.NullabilityRequired},
+				&types.StructType{
+					Nullability: types.NullabilityRequired,
+					Types: []types.Type{
+						&types.Int32Type{Nullability: types.NullabilityRequired},
+						&types.StringType{Nullability: types.NullabilityRequired},
+					},
+				},
+			},
+		}}
+	const expected = "NSTRUCT<a: i32, b: struct<x: i32, y: string>>"
+
+	b := plan.NewBuilderDefault()
+	scan := b.NamedScan([]string{"test"}, schema)
+	assert.Len(t, scan.RecordType().Types, 2)
+	assert.Equal(t, expected, scan.NamedRecordType().String())
+
+	p, err := b.Plan(scan, []string{"a", "b", "x", "y"})
+	require.NoError(t, err)
+	assert.NoError(t, p.Validate())
+	assert.Equal(t, []string{"a", "b", "x", "y"}, p.GetRoots()[0].Names())
+	assert.Equal(t, expected, p.GetRoots()[0].RecordType().String())
+
+	protoPlan, err := p.ToProto()
+	require.NoError(t, err)
+	root := protoPlan.Relations[0].GetRoot()
+	assert.Equal(t, []string{"a", "b", "x", "y"}, root.Names)
+	assert.Equal(t, schema.Names, root.GetInput().GetRead().GetBaseSchema().GetNames())
+
+	roundTrip, err := plan.FromProto(protoPlan, &extensions.DefaultCollection)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "x", "y"}, roundTrip.GetRoots()[0].Names())
+	assert.Equal(t, expected, roundTrip.GetRoots()[0].RecordType().String())
+	rtScan := roundTrip.GetRoots()[0].Input().(*plan.NamedTableReadRel)
+	assert.Equal(t, schema, rtScan.BaseSchema())
+	assert.Equal(t, expected, rtScan.NamedRecordType().String())
+
+	_, err = b.Plan(scan, []string{"a", "b", "x"})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+	assert.EqualError(t, err, "invalid relation: expected 4 names for flattened record type, got 3")
+	// names for only the top level fields aren't enough
+	_, err = b.Plan(scan, []string{"a", "b"})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+	assert.EqualError(t, err, "invalid relation: expected 4 names for flattened record type, got 2")
+	_, err = b.Plan(scan, []string{"a"})
+	assert.EqualError(t, err, "invalid relation: expected 4 names for flattened record type, got 1")
+
+	// without nested structs the names are those of the columns
+	_, err = b.Plan(b.NamedScan([]string{"flat"}, baseSchema), []string{"a", "b", "c"})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+	assert.EqualError(t, err, "invalid relation: mismatched number of names and result record columns, got 3 expected 2")
+}
+
+func TestTypeVariation(t *testing.T) {
+	const uri = "http://localhost/variations.yaml"
+	c, err := extensions.LoadCollection(uri, strings.NewReader(`---
+type_variations:
+  - parent: i32
+    name: u32
+    description: an unsigned 32-bit integer
+    functions: SEPARATE
+`))
+	require.NoError(t, err)
+
+	b := plan.NewBuilder(c)
+	u32, err := b.TypeVariation(uri, "u32")
+	require.NoError(t, err)
+	again, err := b.TypeVariation(uri, "u32")
+	require.NoError(t, err)
+	assert.Equal(t, u32, again)
+
+	_, err = b.TypeVariation(uri, "u64")
+	assert.ErrorIs(t, err, substraitgo.ErrNotFound)
+
+	unsigned := &types.Int32Type{Nullability: types.NullabilityRequired, TypeVariationRef: u32}
+	schema, err := types.NewNamedStruct([]string{"id"}, []types.Type{unsigned})
+	require.NoError(t, err)
+	scan := b.NamedScan([]string{"test"}, schema)
+	lit := &expr.PrimitiveLiteral[int32]{Value: 5, Type: unsigned}
+	proj, err := b.ProjectRemap(scan, []int32{1}, lit)
+	require.NoError(t, err)
+
+	p, err := b.Plan(proj, []string{"five"})
+	require.NoError(t, err)
+	assert.NoError(t, p.Validate())
+
+	protoPlan, err := p.ToProto()
+	require.NoError(t, err)
+	require.Len(t, protoPlan.Extensions, 1)
+	decl := protoPlan.Extensions[0].GetExtensionTypeVariation()
+	require.NotNil(t, decl)
+	assert.Equal(t, "u32", decl.Name)
+	assert.Equal(t, u32, decl.TypeVariationAnchor)
+	projProto := protoPlan.Relations[0].GetRoot().GetInput().GetProject()
+	assert.Equal(t, u32, projProto.Expressions[0].GetLiteral().TypeVariationReference)
+	assert.Equal(t, u32, projProto.GetInput().GetRead().GetBaseSchema().GetStruct().Types[0].GetI32().TypeVariationReference)
+
+	roundTrip, err := plan.FromProto(protoPlan, c)
+	require.NoError(t, err)
+	rtProj := roundTrip.GetRoots()[0].Input().(*plan.ProjectRel)
+	assert.True(t, lit.Equals(rtProj.Expressions()[0]))
+	rtType := rtProj.Expressions()[0].GetType()
+	assert.Equal(t, u32, rtType.GetTypeVariationReference())
+
+	// the variation is resolved by name against the collection
+	reg := roundTrip.ExtensionRegistry()
+	variation, ok := reg.LookupTypeVariation(rtType.GetTypeVariationReference())
+	require.True(t, ok)
+	assert.Equal(t, "u32", variation.Name)
+	assert.Equal(t, extensions.TypeVariationSeparateFuncs, variation.Functions)
+}
+
+func TestResolveUserDefinedType(t *testing.T) {
+	const uri = "http://localhost/vectors.yaml"
+	c, err := extensions.LoadCollection(uri, strings.NewReader(`---
+types:
+  - name: vector
+    parameters:
+      - name: T
+        type: dataType
+      - name: dimensions
+        type: integer
+        min: 1
+        optional: true
+`))
+	require.NoError(t, err)
+
+	b := plan.NewBuilder(c)
+	fp32 := &types.Float32Type{Nullability: types.NullabilityRequired}
+	vec, err := b.ResolveUserDefinedType(uri, "vector",
+		[]types.TypeParam{&types.DataTypeParameter{Type: fp32}, types.IntegerParameter(3)},
+		types.NullabilityRequired)
+	require.NoError(t, err)
+	assert.Equal(t, "u!vector<fp32, 3>", vec.String())
+	assert.Equal(t, "u!vector", vec.ShortString())
+	assert.Equal(t, "u!vector?<fp32, 3>", vec.WithNullability(types.NullabilityNullable).String())
+
+	schema, err := types.NewNamedStruct([]string{"id", "embedding"},
+		[]types.Type{&types.Int64Type{Nullability: types.NullabilityRequired}, vec})
+	require.NoError(t, err)
+	p, err := b.Plan(b.NamedScan([]string{"test"}, schema), []string{"id", "embedding"})
+	require.NoError(t, err)
+	assert.NoError(t, p.Validate())
+
+	protoPlan, err := p.ToProto()
+	require.NoError(t, err)
+	require.Len(t, protoPlan.Extensions, 1)
+	decl := protoPlan.Extensions[0].GetExtensionType()
+	require.NotNil(t, decl)
+	assert.Equal(t, "vector", decl.Name)
+	udt := protoPlan.Relations[0].GetRoot().GetInput().GetRead().GetBaseSchema().GetStruct().Types[1].GetUserDefined()
+	require.NotNil(t, udt)
+	assert.Equal(t, decl.TypeAnchor, udt.TypeReference)
+	require.Len(t, udt.TypeParameters, 2)
+	assert.NotNil(t, udt.TypeParameters[0].GetDataType().GetFp32())
+	assert.EqualValues(t, 3, udt.TypeParameters[1].GetInteger())
+
+	roundTrip, err := plan.FromProto(protoPlan, c)
+	require.NoError(t, err)
+	assert.NoError(t, roundTrip.Validate())
+	rtType := roundTrip.GetRoots()[0].RecordType().Struct.Types[1]
+	assert.True(t, vec.Equals(rtType))
+	reg := roundTrip.ExtensionRegistry()
+	rtDecl, ok := reg.LookupType(rtType.(*types.UserDefinedType).TypeReference)
+	require.True(t, ok)
+	assert.Equal(t, "vector", rtDecl.Name)
+
+	// the dimensions are optional
+	_, err = b.ResolveUserDefinedType(uri, "vector",
+		[]types.TypeParam{&types.DataTypeParameter{Type: fp32}}, types.NullabilityRequired)
+	assert.NoError(t, err)
+	_, err = b.ResolveUserDefinedType(uri, "vector", nil, types.NullabilityRequired)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "user defined type vector takes 1 to 2 parameters, got 0")
+	_, err = b.ResolveUserDefinedType(uri, "matrix", nil, types.NullabilityRequired)
+	assert.ErrorIs(t, err, substraitgo.ErrNotFound)
+
+	// a reference to a type which isn't declared is reported by Validate
+	unknown := &types.UserDefinedType{Nullability: types.NullabilityRequired, TypeReference: 99}
+	schema, err = types.NewNamedStruct([]string{"x"}, []types.Type{unknown})
+	require.NoError(t, err)
+	p, err = b.Plan(b.NamedScan([]string{"test"}, schema), []string{"x"})
+	require.NoError(t, err)
+	err = p.Validate()
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+	assert.ErrorContains(t, err, "base schema: user defined type (anchor 99) not found in extension collection")
+}
+
+func TestIdentityMappingIsDirect(t *testing.T) {
+	assert.True(t, plan.IsIdentityMapping([]int32{0, 1}, 2))
+	assert.False(t, plan.IsIdentityMapping(nil, 0))
+	assert.False(t, plan.IsIdentityMapping([]int32{0}, 2))
+	assert.False(t, plan.IsIdentityMapping([]int32{1, 0}, 2))
+	assert.False(t, plan.IsIdentityMapping([]int32{0, 1, 1}, 2))
+
+	b := plan.NewBuilderDefault()
+	scan, err := b.NamedScanRemap([]string{"test"}, baseSchema, []int32{0, 1})
+	require.NoError(t, err)
+	assert.Nil(t, scan.OutputMapping())
+	assert.NotNil(t, scan.ToProto().GetRead().GetCommon().GetDirect())
+
+	p, err := b.Plan(scan, []string{"a", "b"})
+	require.NoError(t, err)
+	data, err := p.MarshalJSON()
+	require.NoError(t, err)
+	// protojson output isn't stable in its whitespace
+	compact := strings.Join(strings.Fields(string(data)), "")
+	assert.Contains(t, compact, `"common":{"direct":{}}`)
+	assert.NotContains(t, compact, `"emit"`)
+
+	// a mapping which reorders or drops columns is kept
+	filter, err := b.FilterRemap(scan, expr.NewPrimitiveLiteral(true, false), []int32{1, 0})
+	require.NoError(t, err)
+	assert.Equal(t, []int32{1, 0}, filter.OutputMapping())
+
+	b.SetKeepIdentityMappings(true)
+	scan, err = b.NamedScanRemap([]string{"test"}, baseSchema, []int32{0, 1})
+	require.NoError(t, err)
+	assert.Equal(t, []int32{0, 1}, scan.OutputMapping())
+	assert.Equal(t, []int32{0, 1}, scan.ToProto().GetRead().GetCommon().GetEmit().GetOutputMapping())
+}
+
+func TestNullableBooleanConditions(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	schema, err := types.NewNamedStruct([]string{"s", "flag"}, []types.Type{
+		&types.StringType{Nullability: types.NullabilityRequired},
+		&types.BooleanType{Nullability: types.NullabilityNullable},
+	})
+	require.NoError(t, err)
+	scan := b.NamedScan([]string{"test"}, schema)
+
+	flag, err := b.RootFieldRef(scan, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "boolean?", flag.GetType().String())
+
+	filter, err := b.Filter(scan, flag)
+	require.NoError(t, err)
+	assert.Same(t, flag, filter.Condition())
+
+	other := b.NamedScan([]string{"other"}, baseSchema2)
+	joinFlag, err := b.JoinedRecordFieldRef(scan, other, 1)
+	require.NoError(t, err)
+	join, err := b.JoinAndFilter(scan, other, joinFlag, joinFlag, plan.JoinTypeInner)
+	require.NoError(t, err)
+
+	p, err := b.Plan(join, []string{"s", "flag", "x", "y"})
+	require.NoError(t, err)
+	assert.NoError(t, p.Validate())
+
+	// conditions of any other type are still rejected
+	str, err := b.RootFieldRef(scan, 0)
+	require.NoError(t, err)
+	_, err = b.Filter(scan, str)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "condition for Filter Relation must yield boolean, not string")
+	_, err = b.Join(scan, other, str, plan.JoinTypeInner)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "condition for Join Relation must yield boolean, not string")
+}
+
+func TestVirtualTableScanFunc(t *testing.T) {
+	const rowCount = 10000
+	b := plan.NewBuilderDefault()
+	schema, err := types.NewNamedStruct([]string{"id", "name"}, []types.Type{
+		&types.Int64Type{Nullability: types.NullabilityRequired},
+		&types.StringType{Nullability: types.NullabilityNullable},
+	})
+	require.NoError(t, err)
+
+	calls := 0
+	gen := func(i int) ([]expr.Literal, error) {
+		calls++
+		return []expr.Literal{
+			expr.NewPrimitiveLiteral(int64(i), false),
+			expr.NewPrimitiveLiteral(fmt.Sprintf("row %d", i), false),
+		}, nil
+	}
+	vt, err := b.VirtualTableScanFunc(schema, rowCount, gen)
+	require.NoError(t, err)
+	assert.Equal(t, rowCount, calls, "each row is generated once to check it")
+	assert.Equal(t, rowCount, vt.RowCount())
+	assert.Equal(t, schema, vt.NamedRecordType())
+
+	p, err := b.Plan(vt, []string{"id", "name"})
+	require.NoError(t, err)
+	assert.NoError(t, p.Validate())
+	protoPlan, err := p.ToProto()
+	require.NoError(t, err)
+	assert.Equal(t, rowCount, calls, "the rows aren't generated again")
+	values := protoPlan.Relations[0].GetRoot().GetInput().GetRead().GetVirtualTable().GetValues()
+	require.Len(t, values, rowCount)
+	for _, i := range []int{0, 1234, rowCount - 1} {
+		assert.EqualValues(t, i, values[i].Fields[0].GetI64())
+		assert.Equal(t, fmt.Sprintf("row %d", i), values[i].Fields[1].GetString_())
+	}
+
+	// the result reads back as a plain virtual table
+	roundTrip, err := plan.FromProto(protoPlan, &extensions.DefaultCollection)
+	require.NoError(t, err)
+	rtTable := roundTrip.GetRoots()[0].Input().(*plan.VirtualTableReadRel)
+	assert.Equal(t, rowCount, rtTable.RowCount())
+	assert.True(t, vt.Equals(rtTable))
+
+	_, err = b.VirtualTableScanFunc(schema, 2, func(i int) ([]expr.Literal, error) {
+		return []expr.Literal{expr.NewPrimitiveLiteral(int64(i), false)}, nil
+	})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+	assert.ErrorContains(t, err, "row 0 of virtual table has 1 values, schema has 2 fields")
+	_, err = b.VirtualTableScanFunc(schema, 2, func(i int) ([]expr.Literal, error) {
+		return []expr.Literal{expr.NewPrimitiveLiteral(int32(i), false), expr.NewPrimitiveLiteral("x", true)}, nil
+	})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+	assert.ErrorContains(t, err, "found i32 in col 0 of row 0 of virtual table, expected i64")
+	_, err = b.VirtualTableScanFunc(schema, 2, func(i int) ([]expr.Literal, error) {
+		return nil, substraitgo.ErrNotFound
+	})
+	assert.ErrorIs(t, err, substraitgo.ErrNotFound)
+	_, err = b.VirtualTableScanFunc(schema, -1, gen)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+
+	// the rows are retained, so a generator failing after the table was
+	// constructed isn't called again
+	failing := false
+	vt, err = b.VirtualTableScanFunc(schema, 2, func(i int) ([]expr.Literal, error) {
+		if failing {
+			return nil, substraitgo.ErrNotFound
+		}
+		return gen(i)
+	})
+	require.NoError(t, err)
+	failing = true
+	p, err = b.Plan(vt, []string{"id", "name"})
+	require.NoError(t, err)
+	_, err = p.ToProto()
+	assert.NoError(t, err)
+	assert.Len(t, vt.Values(), 2)
+	assert.True(t, vt.Equals(vt))
+}
+
+func TestComparisonSortField(t *testing.T) {
+	const uri = "http://localhost/collations.yaml"
+	custom, err := extensions.LoadCollection(uri, strings.NewReader(`---
+scalar_functions:
+  - name: "compare_ci"
+    description: "case insensitive comparison"
+    impls:
+      - args:
+          - name: a
+            value: string
+          - name: b
+            value: string
+        return: i32
+`))
+	require.NoError(t, err)
+	merged := extensions.NewMergedCollection(&extensions.DefaultCollection, custom)
+
+	b := plan.NewBuilder(merged)
+	// use another function first so the comparison function's anchor isn't 1
+	scan := b.NamedScan([]string{"test"}, baseSchema)
+	refB, err := b.RootFieldRef(scan, 1)
+	require.NoError(t, err)
+	abs, err := b.ScalarFn(arithmeticURI, "abs", nil, refB)
+	require.NoError(t, err)
+	project, err := b.Project(scan, abs)
+	require.NoError(t, err)
+
+	refA, err := b.RootFieldRef(project, 0)
+	require.NoError(t, err)
+	sortField, err := b.ComparisonSortField(refA, uri, "compare_ci")
+	require.NoError(t, err)
+	assert.Equal(t, types.FunctionRef(b.GetFunctionRef(uri, "compare_ci:str_str")), sortField.Kind)
+
+	sort, err := b.Sort(project, sortField)
+	require.NoError(t, err)
+	p, err := b.Plan(sort, []string{"a", "b", "abs"})
+	require.NoError(t, err)
+	assert.NoError(t, p.Validate())
+
+	protoPlan, err := p.ToProto()
+	require.NoError(t, err)
+	protoSort := protoPlan.Relations[0].GetRoot().GetInput().GetSort().Sorts[0]
+	anchor := protoSort.GetComparisonFunctionReference()
+	assert.EqualValues(t, 2, anchor)
+	var names []string
+	for _, d := range protoPlan.Extensions {
+		if d.GetExtensionFunction().GetFunctionAnchor() == anchor {
+			names = append(names, d.GetExtensionFunction().Name)
+		}
+	}
+	assert.Equal(t, []string{"compare_ci:str_str"}, names)
+
+	roundTrip, err := plan.FromProto(protoPlan, merged)
+	require.NoError(t, err)
+	assert.NoError(t, roundTrip.Validate())
+	rtSort := roundTrip.GetRoots()[0].Input().(*plan.SortRel)
+	assert.Equal(t, types.FunctionRef(anchor), rtSort.Sorts()[0].Kind)
+	rtProto, err := roundTrip.ToProto()
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(protoPlan, rtProto))
+
+	_, err = b.ComparisonSortField(refA, uri, "compare_cs")
+	assert.ErrorIs(t, err, substraitgo.ErrNotFound)
+	byCompoundName, err := b.ComparisonSortField(refA, uri, "compare_ci:str_str")
+	require.NoError(t, err)
+	assert.Equal(t, sortField, byCompoundName)
+
+	// a comparison function anchor which isn't declared is reported by Validate
+	sort, err = b.Sort(project, expr.SortField{Expr: refA, Kind: types.FunctionRef(42)})
+	require.NoError(t, err)
+	p, err = b.Plan(sort, []string{"a", "b", "abs"})
+	require.NoError(t, err)
+	err = p.Validate()
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+	assert.ErrorContains(t, err, "sort 0: comparison function (anchor 42) not found in extension collection")
+}
+
+func TestPlanVersion(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	scan := b.NamedScan([]string{"test"}, baseSchema)
+
+	defaultPlan, err := b.Plan(scan, []string{"a", "b"})
+	require.NoError(t, err)
+	assert.Equal(t, plan.CurrentVersion.Producer, defaultPlan.Version().GetProducer())
+
+	b.SetProducer("my-producer")
+	b.SetGitHash("0123456789abcdef0123456789abcdef01234567")
+	b.SetVersion(0, 42, 1)
+	p, err := b.PlanWithTypes(scan, []string{"a", "b"}, []string{"type.googleapis.com/google.protobuf.Empty"})
+	require.NoError(t, err)
+
+	// plans already built keep the version they were built with
+	assert.Equal(t, plan.CurrentVersion.Producer, defaultPlan.Version().GetProducer())
+	assert.Equal(t, "my-producer", p.Version().GetProducer())
+
+	protoPlan, err := p.ToProto()
+	require.NoError(t, err)
+	assert.Equal(t, "my-producer", protoPlan.Version.Producer)
+	assert.Equal(t, "0123456789abcdef0123456789abcdef01234567", protoPlan.Version.GitHash)
+	assert.EqualValues(t, 42, protoPlan.Version.MinorNumber)
+	assert.Equal(t, []string{"type.googleapis.com/google.protobuf.Empty"}, protoPlan.ExpectedTypeUrls)
+
+	roundTrip, err := plan.FromProto(protoPlan, &extensions.DefaultCollection)
+	require.NoError(t, err)
+	v := roundTrip.Version()
+	assert.Equal(t, "my-producer", v.GetProducer())
+	assert.Equal(t, "0123456789abcdef0123456789abcdef01234567", v.GetGitHash())
+	assert.EqualValues(t, 0, v.GetMajorNumber())
+	assert.EqualValues(t, 42, v.GetMinorNumber())
+	assert.EqualValues(t, 1, v.GetPatchNumber())
+	assert.Equal(t, []string{"type.googleapis.com/google.protobuf.Empty"}, roundTrip.ExpectedTypeURLs())
+
+	again, err := roundTrip.ToProto()
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(protoPlan, again))
+}
+
+func TestCrossRelNullability(t *testing.T) {
+	left := types.NamedStruct{Names: []string{"a", "b"},
+		Struct: types.StructType{
+			Nullability: types.NullabilityRequired,
+			Types: []types.Type{
+				&types.StringType{Nullability: types.NullabilityRequired},
+				&types.Float32Type{Nullability: types.NullabilityNullable},
+			},
+		}}
+	right := types.NamedStruct{Names: []string{"x", "y", "z"},
+		Struct: types.StructType{
+			Nullability: types.NullabilityRequired,
+			Types: []types.Type{
+				&types.Int32Type{Nullability: types.NullabilityNullable},
+				&types.BooleanType{Nullability: types.NullabilityRequired},
+				&types.Int64Type{Nullability: types.NullabilityRequired},
+			},
+		}}
+
+	b := plan.NewBuilderDefault()
+	leftScan := b.NamedScan([]string{"left"}, left)
+	rightScan, err := b.NamedScanRemap([]string{"right"}, right, []int32{1, 0})
+	require.NoError(t, err)
+
+	cross, err := b.Cross(leftScan, rightScan)
+	require.NoError(t, err)
+	// the remapped fields of the right side are used, and a cross join
+	// doesn't change the nullability of any field
+	assert.Equal(t, "struct<string, fp32?, boolean, i32?>", outputString(cross))
+
+	p, err := b.Plan(cross, []string{"a", "b", "y", "x"})
+	require.NoError(t, err)
+	require.NoError(t, p.Validate())
+	assert.Equal(t, "NSTRUCT<a: string, b: fp32?, y: boolean, x: i32?>", p.GetRoots()[0].RecordType().String())
+
+	// the schema of the scan is left unchanged
+	assert.Equal(t, "struct<string, fp32?>", outputString(leftScan))
+
+	ref, err := b.JoinedRecordFieldRef(leftScan, rightScan, 3)
+	require.NoError(t, err)
+	assert.Equal(t, "i32?", ref.GetType().String())
+
+	p, err = b.Plan(cross, []string{"a", "b", "y", "x"})
+	require.NoError(t, err)
+	rt := checkRelRoundTrip(t, p)
+	assert.Equal(t, "struct<string, fp32?, boolean, i32?>", outputString(rt))
+
+	// unlike a left join, which makes the fields of the right side nullable
+	cond := expr.NewPrimitiveLiteral(true, false)
+	join, err := b.Join(leftScan, rightScan, cond, plan.JoinTypeLeft)
+	require.NoError(t, err)
+	assert.Equal(t, "struct<string, fp32?, boolean?, i32?>", outputString(join))
+}
@@ -0,0 +1,86 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package plan
+
+import (
+	"fmt"
+	"strings"
+
+	substraitgo "github.com/substrait-io/substrait-go"
+	"golang.org/x/exp/slices"
+)
+
+// checkReferences returns an error wrapping substraitgo.ErrInvalidRel if
+// a ReferenceRel within the relations has an ordinal which is out of
+// range, or if the references between the relations form a cycle, such
+// as a relation which refers to itself. The error identifies the
+// relations forming the cycle.
+func checkReferences(relations []Relation) error {
+	refs := make([][]int32, len(relations))
+	for i := range relations {
+		r := relations[i].rel
+		if relations[i].IsRoot() {
+			r = relations[i].root.input
+		}
+
+		var err error
+		Walk(r, func(r Rel) bool {
+			ref, ok := r.(*ReferenceRel)
+			if !ok || err != nil {
+				return err == nil
+			}
+			if ref.ordinal < 0 || int(ref.ordinal) >= len(relations) {
+				err = fmt.Errorf("%w: ReferenceRel in relations[%d] has subtree ordinal %d, but the plan has %d relations",
+					substraitgo.ErrInvalidRel, i, ref.ordinal, len(relations))
+				return false
+			}
+			refs[i] = append(refs[i], ref.ordinal)
+			return true
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := make([]int, len(relations))
+	var path []int32
+	var visit func(i int32) error
+	visit = func(i int32) error {
+		switch state[i] {
+		case visited:
+			return nil
+		case visiting:
+			cycle := path[slices.Index(path, i):]
+			names := make([]string, 0, len(cycle)+1)
+			for _, c := range append(cycle, i) {
+				names = append(names, fmt.Sprintf("relations[%d]", c))
+			}
+			return fmt.Errorf("%w: plan relations form a reference cycle: %s",
+				substraitgo.ErrInvalidRel, strings.Join(names, " -> "))
+		}
+
+		state[i] = visiting
+		path = append(path, i)
+		for _, ref := range refs[i] {
+			if err := visit(ref); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[i] = visited
+		return nil
+	}
+
+	for i := range relations {
+		if err := visit(int32(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
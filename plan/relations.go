@@ -91,8 +91,21 @@ func (b *baseReadRel) fromProtoReadRel(rel *proto.ReadRel, reg expr.ExtensionReg
 	return nil
 }
 
+// RecordType returns the schema of the data produced by the read, which is
+// the base schema with the projection, if any, applied to it.
 func (b *baseReadRel) RecordType() types.StructType {
-	return b.baseSchema.Struct
+	if b.projection == nil {
+		return b.baseSchema.Struct
+	}
+
+	// the projection of a read always produces a struct regardless of
+	// whether maintain singular struct is set. An invalid projection is
+	// ignored here and left for Plan.Validate to report.
+	out, err := b.projection.Select().GetType(&b.baseSchema.Struct)
+	if err != nil {
+		return b.baseSchema.Struct
+	}
+	return *out.(*types.StructType)
 }
 
 func (b *baseReadRel) BaseSchema() types.NamedStruct                       { return b.baseSchema }
@@ -144,6 +157,22 @@ func (b *baseReadRel) updateFilters(filters []expr.Expression) {
 	b.filter, b.bestEffortFilter = filters[0], filters[1]
 }
 
+// ScanOptions are the optional portions of a read relation which allow
+// filtering and projection to be pushed down into the scan of the data.
+type ScanOptions struct {
+	// Filter, if not nil, must yield a boolean when evaluated against
+	// the base schema of the read and determines which records are
+	// returned.
+	Filter expr.Expression
+	// BestEffortFilter, if not nil, must also yield a boolean. Unlike
+	// Filter, records not matching it may still be returned, so it
+	// must be re-applied by the consumer if needed.
+	BestEffortFilter expr.Expression
+	// Projection, if not nil, selects the subset of the base schema
+	// which is produced by the read.
+	Projection *expr.MaskExpression
+}
+
 // NamedTableReadRel is a named scan of a base table. The list of strings
 // that make up the names are to represent namespacing (e.g. mydb.mytable).
 // This assumes a shared catalog between systems exchanging a message.
@@ -183,6 +212,26 @@ func (n *NamedTableReadRel) ToProto() *proto.Rel {
 	}
 }
 
+func (n *NamedTableReadRel) WithOutputMapping(mapping []int32) (Rel, error) {
+	return withOutputMapping(n, mapping)
+}
+
+func (n *NamedTableReadRel) WithHint(hint RelHint) Rel {
+	return withHint(n, hint)
+}
+
+func (n *NamedTableReadRel) Equals(other Rel) bool {
+	return relEquals(n, other)
+}
+
+func (n *NamedTableReadRel) NamedRecordType() types.NamedStruct {
+	return namedRecordType(n)
+}
+
+func (n *NamedTableReadRel) SelectByName(names []string) (Rel, error) {
+	return selectByName(n, names)
+}
+
 func (n *NamedTableReadRel) Copy(_ ...Rel) (Rel, error) {
 	return n, nil
 }
@@ -211,6 +260,11 @@ func (v *VirtualTableReadRel) Values() []expr.StructLiteralValue {
 	return v.values
 }
 
+// RowCount returns the number of rows of the table.
+func (v *VirtualTableReadRel) RowCount() int {
+	return len(v.values)
+}
+
 func (v *VirtualTableReadRel) ToProto() *proto.Rel {
 	readRel := v.toReadRelProto()
 	values := make([]*proto.Expression_Literal_Struct, len(v.values))
@@ -238,6 +292,26 @@ func (v *VirtualTableReadRel) ToProtoPlanRel() *proto.PlanRel {
 	}
 }
 
+func (v *VirtualTableReadRel) WithOutputMapping(mapping []int32) (Rel, error) {
+	return withOutputMapping(v, mapping)
+}
+
+func (v *VirtualTableReadRel) WithHint(hint RelHint) Rel {
+	return withHint(v, hint)
+}
+
+func (v *VirtualTableReadRel) Equals(other Rel) bool {
+	return relEquals(v, other)
+}
+
+func (v *VirtualTableReadRel) NamedRecordType() types.NamedStruct {
+	return namedRecordType(v)
+}
+
+func (v *VirtualTableReadRel) SelectByName(names []string) (Rel, error) {
+	return selectByName(v, names)
+}
+
 func (v *VirtualTableReadRel) Copy(_ ...Rel) (Rel, error) {
 	return v, nil
 }
@@ -288,6 +362,26 @@ func (e *ExtensionTableReadRel) ToProtoPlanRel() *proto.PlanRel {
 	}
 }
 
+func (e *ExtensionTableReadRel) WithOutputMapping(mapping []int32) (Rel, error) {
+	return withOutputMapping(e, mapping)
+}
+
+func (e *ExtensionTableReadRel) WithHint(hint RelHint) Rel {
+	return withHint(e, hint)
+}
+
+func (e *ExtensionTableReadRel) Equals(other Rel) bool {
+	return relEquals(e, other)
+}
+
+func (e *ExtensionTableReadRel) NamedRecordType() types.NamedStruct {
+	return namedRecordType(e)
+}
+
+func (e *ExtensionTableReadRel) SelectByName(names []string) (Rel, error) {
+	return selectByName(e, names)
+}
+
 func (e *ExtensionTableReadRel) Copy(_ ...Rel) (Rel, error) {
 	return e, nil
 }
@@ -327,6 +421,11 @@ type (
 	OrcReadOptions       proto.ReadRel_LocalFiles_FileOrFiles_OrcReadOptions
 	DwrfReadOptions      proto.ReadRel_LocalFiles_FileOrFiles_DwrfReadOptions
 	ExtensionReadOptions anypb.Any
+	// TextReadOptions describes delimiter separated text files such as
+	// CSV: the field delimiter, quote and escape characters, the number
+	// of header lines to skip, the maximum line size and the value, if
+	// any, which is read as null.
+	TextReadOptions proto.ReadRel_LocalFiles_FileOrFiles_DelimiterSeparatedTextReadOptions
 
 	FileFormat interface {
 		isFileFormat()
@@ -338,6 +437,7 @@ func (*ArrowReadOptions) isFileFormat()     {}
 func (*OrcReadOptions) isFileFormat()       {}
 func (*DwrfReadOptions) isFileFormat()      {}
 func (*ExtensionReadOptions) isFileFormat() {}
+func (*TextReadOptions) isFileFormat()      {}
 
 // FileOrFiles represents the contents of a LocalFiles table. Many files
 // consist of indivisible chunks (e.g. parquet row groups or CSV rows).
@@ -382,6 +482,8 @@ func (f *FileOrFiles) fromProto(p *proto.ReadRel_LocalFiles_FileOrFiles) {
 		f.Format = (*OrcReadOptions)(format.Orc)
 	case *proto.ReadRel_LocalFiles_FileOrFiles_Parquet:
 		f.Format = (*ParquetReadOptions)(format.Parquet)
+	case *proto.ReadRel_LocalFiles_FileOrFiles_Text:
+		f.Format = (*TextReadOptions)(format.Text)
 	}
 }
 
@@ -423,6 +525,10 @@ func (f *FileOrFiles) ToProto() *proto.ReadRel_LocalFiles_FileOrFiles {
 		ret.FileFormat = &proto.ReadRel_LocalFiles_FileOrFiles_Extension{
 			Extension: (*anypb.Any)(fm),
 		}
+	case *TextReadOptions:
+		ret.FileFormat = &proto.ReadRel_LocalFiles_FileOrFiles_Text{
+			Text: (*proto.ReadRel_LocalFiles_FileOrFiles_DelimiterSeparatedTextReadOptions)(fm),
+		}
 	}
 	return ret
 }
@@ -471,6 +577,26 @@ func (lf *LocalFileReadRel) ToProtoPlanRel() *proto.PlanRel {
 	}
 }
 
+func (lf *LocalFileReadRel) WithOutputMapping(mapping []int32) (Rel, error) {
+	return withOutputMapping(lf, mapping)
+}
+
+func (lf *LocalFileReadRel) WithHint(hint RelHint) Rel {
+	return withHint(lf, hint)
+}
+
+func (lf *LocalFileReadRel) Equals(other Rel) bool {
+	return relEquals(lf, other)
+}
+
+func (lf *LocalFileReadRel) NamedRecordType() types.NamedStruct {
+	return namedRecordType(lf)
+}
+
+func (lf *LocalFileReadRel) SelectByName(names []string) (Rel, error) {
+	return selectByName(lf, names)
+}
+
 func (lf *LocalFileReadRel) Copy(_ ...Rel) (Rel, error) {
 	return lf, nil
 }
@@ -500,7 +626,7 @@ type ProjectRel struct {
 }
 
 func (p *ProjectRel) RecordType() types.StructType {
-	initial := p.input.RecordType()
+	initial := p.input.Remap(p.input.RecordType())
 	output := slices.Grow(slices.Clone(initial.Types), len(p.exprs))
 
 	for _, e := range p.exprs {
@@ -548,6 +674,26 @@ func (p *ProjectRel) GetInputs() []Rel {
 	return []Rel{p.input}
 }
 
+func (p *ProjectRel) WithOutputMapping(mapping []int32) (Rel, error) {
+	return withOutputMapping(p, mapping)
+}
+
+func (p *ProjectRel) WithHint(hint RelHint) Rel {
+	return withHint(p, hint)
+}
+
+func (p *ProjectRel) Equals(other Rel) bool {
+	return relEquals(p, other)
+}
+
+func (p *ProjectRel) NamedRecordType() types.NamedStruct {
+	return namedRecordType(p)
+}
+
+func (p *ProjectRel) SelectByName(names []string) (Rel, error) {
+	return selectByName(p, names)
+}
+
 func (p *ProjectRel) Copy(newInputs ...Rel) (Rel, error) {
 	if len(newInputs) != 1 {
 		return nil, substraitgo.ErrInvalidInputCount
@@ -608,52 +754,67 @@ type JoinRel struct {
 }
 
 func (j *JoinRel) RecordType() types.StructType {
-	var typeList []types.Type
 	switch j.joinType {
-	case JoinTypeInner:
-		return j.JoinedRecordType()
-	case JoinTypeLeftSemi:
-		return j.left.Remap(j.left.RecordType())
-	case JoinTypeOuter:
-		typeList = j.JoinedRecordType().Types
-		for i, t := range typeList {
-			typeList[i] = t.WithNullability(types.NullabilityNullable)
-		}
-	case JoinTypeLeft, JoinTypeLeftSingle:
-		left := j.left.Remap(j.left.RecordType())
-		right := j.right.Remap(j.right.RecordType())
-		typeList = make([]types.Type, 0, len(left.Types)+len(right.Types))
-		typeList = append(typeList, left.Types...)
-		for _, r := range right.Types {
-			typeList = append(typeList, r.WithNullability(types.NullabilityNullable))
-		}
-	case JoinTypeRight:
-		left := j.left.Remap(j.left.RecordType())
-		right := j.right.Remap(j.right.RecordType())
-		typeList = make([]types.Type, 0, len(left.Types)+len(right.Types))
-		for _, l := range left.Types {
-			typeList = append(typeList, l.WithNullability(types.NullabilityNullable))
-		}
-		typeList = append(typeList, right.Types...)
-	case JoinTypeLeftAnti:
-		typeList = j.left.RecordType().Types
+	case JoinTypeUnspecified:
+		return types.StructType{Nullability: proto.Type_NULLABILITY_REQUIRED}
 	case JoinTypeRightSemi, JoinTypeRightAnti, JoinTypeRightSingle:
 		panic(fmt.Sprintf("join type: %v not supported", j.joinType))
 	}
 
+	return joinRecordType(j.left, j.right, j.joinType)
+}
+
+// joinedRecordType returns the concatenation of the output record types
+// of the left and right inputs of a join.
+func joinedRecordType(left, right Rel) types.StructType {
+	// the types of the left side are clipped so that appending doesn't
+	// write to the schema of the input
 	return types.StructType{
 		Nullability: proto.Type_NULLABILITY_REQUIRED,
-		Types:       typeList,
+		Types:       append(slices.Clip(left.Remap(left.RecordType()).Types), right.Remap(right.RecordType()).Types...),
 	}
 }
 
-func (j *JoinRel) JoinedRecordType() types.StructType {
+// joinRecordType returns the output record type of a join of the left
+// and right inputs, which depends on the join type. The semi and anti
+// joins only output the records of one side, while the outer joins make
+// the fields of the side which may not match nullable.
+func joinRecordType(left, right Rel, joinType JoinType) types.StructType {
+	nullable := func(typeList []types.Type) []types.Type {
+		out := make([]types.Type, len(typeList))
+		for i, t := range typeList {
+			out[i] = t.WithNullability(types.NullabilityNullable)
+		}
+		return out
+	}
+
+	leftType, rightType := left.Remap(left.RecordType()), right.Remap(right.RecordType())
+	var typeList []types.Type
+	switch joinType {
+	case JoinTypeLeftSemi, JoinTypeLeftAnti:
+		return leftType
+	case JoinTypeRightSemi, JoinTypeRightAnti:
+		return rightType
+	case JoinTypeOuter:
+		typeList = append(nullable(leftType.Types), nullable(rightType.Types)...)
+	case JoinTypeLeft, JoinTypeLeftSingle:
+		typeList = append(slices.Clip(leftType.Types), nullable(rightType.Types)...)
+	case JoinTypeRight, JoinTypeRightSingle:
+		typeList = append(nullable(leftType.Types), rightType.Types...)
+	default:
+		return joinedRecordType(left, right)
+	}
+
 	return types.StructType{
 		Nullability: proto.Type_NULLABILITY_REQUIRED,
-		Types:       append(j.left.Remap(j.left.RecordType()).Types, j.right.Remap(j.right.RecordType()).Types...),
+		Types:       typeList,
 	}
 }
 
+func (j *JoinRel) JoinedRecordType() types.StructType {
+	return joinedRecordType(j.left, j.right)
+}
+
 func (j *JoinRel) Left() Rel             { return j.left }
 func (j *JoinRel) Right() Rel            { return j.right }
 func (j *JoinRel) Expr() expr.Expression { return j.expr }
@@ -701,6 +862,26 @@ func (j *JoinRel) GetInputs() []Rel {
 	return []Rel{j.left, j.right}
 }
 
+func (j *JoinRel) WithOutputMapping(mapping []int32) (Rel, error) {
+	return withOutputMapping(j, mapping)
+}
+
+func (j *JoinRel) WithHint(hint RelHint) Rel {
+	return withHint(j, hint)
+}
+
+func (j *JoinRel) Equals(other Rel) bool {
+	return relEquals(j, other)
+}
+
+func (j *JoinRel) NamedRecordType() types.NamedStruct {
+	return namedRecordType(j)
+}
+
+func (j *JoinRel) SelectByName(names []string) (Rel, error) {
+	return selectByName(j, names)
+}
+
 func (j *JoinRel) Copy(newInputs ...Rel) (Rel, error) {
 	if len(newInputs) != 2 {
 		return nil, substraitgo.ErrInvalidInputCount
@@ -739,11 +920,12 @@ type CrossRel struct {
 	advExtension *extensions.AdvancedExtension
 }
 
+// RecordType returns the output fields of the left input followed by
+// those of the right input, after applying their output mappings. Every
+// record of one side is paired with every record of the other, so the
+// nullability of the fields is unchanged.
 func (c *CrossRel) RecordType() types.StructType {
-	return types.StructType{
-		Nullability: proto.Type_NULLABILITY_REQUIRED,
-		Types:       append(c.left.RecordType().Types, c.right.RecordType().Types...),
-	}
+	return joinedRecordType(c.left, c.right)
 }
 
 func (c *CrossRel) Left() Rel  { return c.left }
@@ -777,6 +959,26 @@ func (c *CrossRel) GetInputs() []Rel {
 	return []Rel{c.left, c.right}
 }
 
+func (c *CrossRel) WithOutputMapping(mapping []int32) (Rel, error) {
+	return withOutputMapping(c, mapping)
+}
+
+func (c *CrossRel) WithHint(hint RelHint) Rel {
+	return withHint(c, hint)
+}
+
+func (c *CrossRel) Equals(other Rel) bool {
+	return relEquals(c, other)
+}
+
+func (c *CrossRel) NamedRecordType() types.NamedStruct {
+	return namedRecordType(c)
+}
+
+func (c *CrossRel) SelectByName(names []string) (Rel, error) {
+	return selectByName(c, names)
+}
+
 func (c *CrossRel) Copy(newInputs ...Rel) (Rel, error) {
 	if len(newInputs) != 2 {
 		return nil, substraitgo.ErrInvalidInputCount
@@ -793,6 +995,10 @@ func (c *CrossRel) CopyWithExpressionRewrite(_ RewriteFunc, newInputs ...Rel) (R
 	return c.Copy(newInputs...)
 }
 
+// FetchCountAll is the count of a FetchRel which returns all of the
+// records after the offset.
+const FetchCountAll int64 = -1
+
 // FetchRel is a relational operator representing LIMIT/OFFSET or
 // TOP type semantics.
 type FetchRel struct {
@@ -803,10 +1009,14 @@ type FetchRel struct {
 	advExtension  *extensions.AdvancedExtension
 }
 
-func (f *FetchRel) RecordType() types.StructType { return f.input.RecordType() }
+func (f *FetchRel) RecordType() types.StructType { return f.input.Remap(f.input.RecordType()) }
 func (f *FetchRel) Input() Rel                   { return f.input }
 func (f *FetchRel) Offset() int64                { return f.offset }
-func (f *FetchRel) Count() int64                 { return f.count }
+
+// Count returns the number of records to return, or FetchCountAll if
+// all of the records after the offset are returned.
+func (f *FetchRel) Count() int64 { return f.count }
+
 func (f *FetchRel) GetAdvancedExtension() *extensions.AdvancedExtension {
 	return f.advExtension
 }
@@ -837,6 +1047,26 @@ func (f *FetchRel) GetInputs() []Rel {
 	return []Rel{f.input}
 }
 
+func (f *FetchRel) WithOutputMapping(mapping []int32) (Rel, error) {
+	return withOutputMapping(f, mapping)
+}
+
+func (f *FetchRel) WithHint(hint RelHint) Rel {
+	return withHint(f, hint)
+}
+
+func (f *FetchRel) Equals(other Rel) bool {
+	return relEquals(f, other)
+}
+
+func (f *FetchRel) NamedRecordType() types.NamedStruct {
+	return namedRecordType(f)
+}
+
+func (f *FetchRel) SelectByName(names []string) (Rel, error) {
+	return selectByName(f, names)
+}
+
 func (f *FetchRel) Copy(newInputs ...Rel) (Rel, error) {
 	if len(newInputs) != 1 {
 		return nil, substraitgo.ErrInvalidInputCount
@@ -889,18 +1119,31 @@ type AggregateRel struct {
 	advExtension *extensions.AdvancedExtension
 }
 
+// RecordType returns a column for each of the GroupingExpressions,
+// followed by the measures. A grouping column is nullable if it isn't
+// part of every grouping set, since it is null in the records of the
+// sets it is missing from. With more than one grouping set, a required
+// i32 column is added last, holding the index of the grouping set each
+// record was produced by.
 func (ar *AggregateRel) RecordType() types.StructType {
-	groupTypes := make([]types.Type, 0, len(ar.groups)+len(ar.measures))
-	for _, g := range ar.groups {
-		for _, e := range g {
-			groupTypes = append(groupTypes, e.GetType())
+	exprs, refs := ar.groupingReferences()
+	groupTypes := make([]types.Type, 0, len(exprs)+len(ar.measures)+1)
+	for i, e := range exprs {
+		t := e.GetType()
+		if slices.ContainsFunc(refs, func(set []uint32) bool { return !slices.Contains(set, uint32(i)) }) {
+			t = t.WithNullability(types.NullabilityNullable)
 		}
+		groupTypes = append(groupTypes, t)
 	}
 
 	for _, m := range ar.measures {
 		groupTypes = append(groupTypes, m.measure.GetType())
 	}
 
+	if len(ar.groups) > 1 {
+		groupTypes = append(groupTypes, &types.Int32Type{Nullability: types.NullabilityRequired})
+	}
+
 	return types.StructType{
 		Nullability: proto.Type_NULLABILITY_REQUIRED,
 		Types:       groupTypes,
@@ -913,6 +1156,39 @@ func (ar *AggregateRel) Input() Rel { return ar.input }
 // be calculated for.
 func (ar *AggregateRel) Groupings() [][]expr.Expression { return ar.groups }
 func (ar *AggregateRel) Measures() []AggRelMeasure      { return ar.measures }
+
+// GroupingExpressions returns the distinct expressions across all of the
+// groupings in the order they first appear. The output of the relation
+// consists of a column for each of these followed by the measures, see
+// RecordType.
+func (ar *AggregateRel) GroupingExpressions() []expr.Expression {
+	exprs, _ := ar.groupingReferences()
+	return exprs
+}
+
+// GroupingReferences returns, for each grouping, the indices of its
+// expressions within GroupingExpressions.
+func (ar *AggregateRel) GroupingReferences() [][]uint32 {
+	_, refs := ar.groupingReferences()
+	return refs
+}
+
+func (ar *AggregateRel) groupingReferences() ([]expr.Expression, [][]uint32) {
+	var exprs []expr.Expression
+	refs := make([][]uint32, len(ar.groups))
+	for i, g := range ar.groups {
+		refs[i] = make([]uint32, len(g))
+		for j, e := range g {
+			idx := slices.IndexFunc(exprs, e.Equals)
+			if idx == -1 {
+				idx = len(exprs)
+				exprs = append(exprs, e)
+			}
+			refs[i][j] = uint32(idx)
+		}
+	}
+	return exprs, refs
+}
 func (ar *AggregateRel) GetAdvancedExtension() *extensions.AdvancedExtension {
 	return ar.advExtension
 }
@@ -959,6 +1235,26 @@ func (ar *AggregateRel) GetInputs() []Rel {
 	return []Rel{ar.input}
 }
 
+func (ar *AggregateRel) WithOutputMapping(mapping []int32) (Rel, error) {
+	return withOutputMapping(ar, mapping)
+}
+
+func (ar *AggregateRel) WithHint(hint RelHint) Rel {
+	return withHint(ar, hint)
+}
+
+func (ar *AggregateRel) Equals(other Rel) bool {
+	return relEquals(ar, other)
+}
+
+func (ar *AggregateRel) NamedRecordType() types.NamedStruct {
+	return namedRecordType(ar)
+}
+
+func (ar *AggregateRel) SelectByName(names []string) (Rel, error) {
+	return selectByName(ar, names)
+}
+
 func (ar *AggregateRel) Copy(newInputs ...Rel) (Rel, error) {
 	if len(newInputs) != 1 {
 		return nil, substraitgo.ErrInvalidInputCount
@@ -991,8 +1287,11 @@ func (ar *AggregateRel) CopyWithExpressionRewrite(rewriteFunc RewriteFunc, newIn
 		if newMeasures[i].filter, err = rewriteFunc(m.filter); err != nil {
 			return nil, err
 		}
-		measuresAreEqual = measuresAreEqual && newMeasures[i].filter == m.filter
-		newMeasures[i].measure = m.measure
+		if newMeasures[i].measure, err = rewriteMeasure(m.measure, rewriteFunc); err != nil {
+			return nil, err
+		}
+		measuresAreEqual = measuresAreEqual && newMeasures[i].filter == m.filter &&
+			newMeasures[i].measure == m.measure
 	}
 	if groupsAreEqual && measuresAreEqual && newInputs[0] == ar.input {
 		return ar, nil
@@ -1000,9 +1299,34 @@ func (ar *AggregateRel) CopyWithExpressionRewrite(rewriteFunc RewriteFunc, newIn
 	aggregate := *ar
 	aggregate.input = newInputs[0]
 	aggregate.groups = newGroups
+	aggregate.measures = newMeasures
 	return &aggregate, nil
 }
 
+// rewriteMeasure returns the aggregate function with each of its
+// expression arguments and sort expressions rewritten by rewriteFunc.
+func rewriteMeasure(f *expr.AggregateFunction, rewriteFunc RewriteFunc) (*expr.AggregateFunction, error) {
+	if f == nil {
+		return nil, nil
+	}
+
+	var err error
+	out := f.Visit(func(e expr.Expression) expr.Expression {
+		if err != nil {
+			return e
+		}
+		var after expr.Expression
+		if after, err = rewriteFunc(e); err != nil {
+			return e
+		}
+		return after
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // SortRel is an ORDER BY relational operator, describing a base relation,
 // it includes a list of fields to sort on.
 type SortRel struct {
@@ -1013,7 +1337,7 @@ type SortRel struct {
 	advExtension *extensions.AdvancedExtension
 }
 
-func (sr *SortRel) RecordType() types.StructType { return sr.input.RecordType() }
+func (sr *SortRel) RecordType() types.StructType { return sr.input.Remap(sr.input.RecordType()) }
 func (sr *SortRel) Input() Rel                   { return sr.input }
 func (sr *SortRel) Sorts() []expr.SortField      { return sr.sorts }
 func (sr *SortRel) GetAdvancedExtension() *extensions.AdvancedExtension {
@@ -1049,6 +1373,26 @@ func (sr *SortRel) GetInputs() []Rel {
 	return []Rel{sr.input}
 }
 
+func (sr *SortRel) WithOutputMapping(mapping []int32) (Rel, error) {
+	return withOutputMapping(sr, mapping)
+}
+
+func (sr *SortRel) WithHint(hint RelHint) Rel {
+	return withHint(sr, hint)
+}
+
+func (sr *SortRel) Equals(other Rel) bool {
+	return relEquals(sr, other)
+}
+
+func (sr *SortRel) NamedRecordType() types.NamedStruct {
+	return namedRecordType(sr)
+}
+
+func (sr *SortRel) SelectByName(names []string) (Rel, error) {
+	return selectByName(sr, names)
+}
+
 func (sr *SortRel) Copy(newInputs ...Rel) (Rel, error) {
 	if len(newInputs) != 1 {
 		return nil, substraitgo.ErrInvalidInputCount
@@ -1091,7 +1435,7 @@ type FilterRel struct {
 	advExtension *extensions.AdvancedExtension
 }
 
-func (fr *FilterRel) RecordType() types.StructType { return fr.input.RecordType() }
+func (fr *FilterRel) RecordType() types.StructType { return fr.input.Remap(fr.input.RecordType()) }
 func (fr *FilterRel) Input() Rel                   { return fr.input }
 func (fr *FilterRel) Condition() expr.Expression   { return fr.cond }
 func (fr *FilterRel) GetAdvancedExtension() *extensions.AdvancedExtension {
@@ -1123,6 +1467,26 @@ func (fr *FilterRel) GetInputs() []Rel {
 	return []Rel{fr.input}
 }
 
+func (fr *FilterRel) WithOutputMapping(mapping []int32) (Rel, error) {
+	return withOutputMapping(fr, mapping)
+}
+
+func (fr *FilterRel) WithHint(hint RelHint) Rel {
+	return withHint(fr, hint)
+}
+
+func (fr *FilterRel) Equals(other Rel) bool {
+	return relEquals(fr, other)
+}
+
+func (fr *FilterRel) NamedRecordType() types.NamedStruct {
+	return namedRecordType(fr)
+}
+
+func (fr *FilterRel) SelectByName(names []string) (Rel, error) {
+	return selectByName(fr, names)
+}
+
 func (fr *FilterRel) Copy(newInputs ...Rel) (Rel, error) {
 	if len(newInputs) != 1 {
 		return nil, substraitgo.ErrInvalidInputCount
@@ -1206,6 +1570,26 @@ func (s *SetRel) GetInputs() []Rel {
 	return s.inputs
 }
 
+func (s *SetRel) WithOutputMapping(mapping []int32) (Rel, error) {
+	return withOutputMapping(s, mapping)
+}
+
+func (s *SetRel) WithHint(hint RelHint) Rel {
+	return withHint(s, hint)
+}
+
+func (s *SetRel) Equals(other Rel) bool {
+	return relEquals(s, other)
+}
+
+func (s *SetRel) NamedRecordType() types.NamedStruct {
+	return namedRecordType(s)
+}
+
+func (s *SetRel) SelectByName(names []string) (Rel, error) {
+	return selectByName(s, names)
+}
+
 func (s *SetRel) Copy(newInputs ...Rel) (Rel, error) {
 	set := *s
 	set.inputs = newInputs
@@ -1220,14 +1604,23 @@ func (s *SetRel) CopyWithExpressionRewrite(_ RewriteFunc, newInputs ...Rel) (Rel
 }
 
 // ExtensionSingleRel is a stub to support extensions with a single input.
+// As the detail is opaque, the output schema is taken from the schema
+// provided when it was built, if any, otherwise it is assumed to be the
+// same as the input. The schema is not serialized.
 type ExtensionSingleRel struct {
 	RelCommon
 
 	input  Rel
 	detail *anypb.Any
+	schema *types.NamedStruct
 }
 
-func (es *ExtensionSingleRel) RecordType() types.StructType { return es.input.RecordType() }
+func (es *ExtensionSingleRel) RecordType() types.StructType {
+	if es.schema != nil {
+		return es.schema.Struct
+	}
+	return es.input.RecordType()
+}
 
 func (es *ExtensionSingleRel) Input() Rel         { return es.input }
 func (es *ExtensionSingleRel) Detail() *anypb.Any { return es.detail }
@@ -1256,6 +1649,26 @@ func (es *ExtensionSingleRel) GetInputs() []Rel {
 	return []Rel{es.input}
 }
 
+func (es *ExtensionSingleRel) WithOutputMapping(mapping []int32) (Rel, error) {
+	return withOutputMapping(es, mapping)
+}
+
+func (es *ExtensionSingleRel) WithHint(hint RelHint) Rel {
+	return withHint(es, hint)
+}
+
+func (es *ExtensionSingleRel) Equals(other Rel) bool {
+	return relEquals(es, other)
+}
+
+func (es *ExtensionSingleRel) NamedRecordType() types.NamedStruct {
+	return namedRecordType(es)
+}
+
+func (es *ExtensionSingleRel) SelectByName(names []string) (Rel, error) {
+	return selectByName(es, names)
+}
+
 func (es *ExtensionSingleRel) Copy(newInputs ...Rel) (Rel, error) {
 	if len(newInputs) != 1 {
 		return nil, substraitgo.ErrInvalidInputCount
@@ -1273,14 +1686,23 @@ func (es *ExtensionSingleRel) CopyWithExpressionRewrite(_ RewriteFunc, newInputs
 }
 
 // ExtensionLeafRel is a stub to support extensions with zero inputs.
+// As the detail is opaque, the output schema is taken from the schema
+// provided when it was built, if any. The schema is not serialized.
 type ExtensionLeafRel struct {
 	RelCommon
 
 	detail *anypb.Any
+	schema *types.NamedStruct
+}
+
+func (el *ExtensionLeafRel) RecordType() types.StructType {
+	if el.schema != nil {
+		return el.schema.Struct
+	}
+	return types.StructType{}
 }
 
-func (el *ExtensionLeafRel) RecordType() types.StructType { return types.StructType{} }
-func (el *ExtensionLeafRel) Detail() *anypb.Any           { return el.detail }
+func (el *ExtensionLeafRel) Detail() *anypb.Any { return el.detail }
 
 func (el *ExtensionLeafRel) ToProto() *proto.Rel {
 	return &proto.Rel{
@@ -1305,6 +1727,26 @@ func (el *ExtensionLeafRel) GetInputs() []Rel {
 	return []Rel{}
 }
 
+func (el *ExtensionLeafRel) WithOutputMapping(mapping []int32) (Rel, error) {
+	return withOutputMapping(el, mapping)
+}
+
+func (el *ExtensionLeafRel) WithHint(hint RelHint) Rel {
+	return withHint(el, hint)
+}
+
+func (el *ExtensionLeafRel) Equals(other Rel) bool {
+	return relEquals(el, other)
+}
+
+func (el *ExtensionLeafRel) NamedRecordType() types.NamedStruct {
+	return namedRecordType(el)
+}
+
+func (el *ExtensionLeafRel) SelectByName(names []string) (Rel, error) {
+	return selectByName(el, names)
+}
+
 func (el *ExtensionLeafRel) Copy(_ ...Rel) (Rel, error) {
 	return el, nil
 }
@@ -1314,16 +1756,25 @@ func (el *ExtensionLeafRel) CopyWithExpressionRewrite(_ RewriteFunc, _ ...Rel) (
 }
 
 // ExtensionMultiRel is a stub to support extensions with multiple inputs.
+// As the detail is opaque, the output schema is taken from the schema
+// provided when it was built, if any. The schema is not serialized.
 type ExtensionMultiRel struct {
 	RelCommon
 
 	inputs []Rel
 	detail *anypb.Any
+	schema *types.NamedStruct
+}
+
+func (em *ExtensionMultiRel) RecordType() types.StructType {
+	if em.schema != nil {
+		return em.schema.Struct
+	}
+	return types.StructType{}
 }
 
-func (em *ExtensionMultiRel) RecordType() types.StructType { return types.StructType{} }
-func (em *ExtensionMultiRel) Inputs() []Rel                { return em.inputs }
-func (em *ExtensionMultiRel) Detail() *anypb.Any           { return em.detail }
+func (em *ExtensionMultiRel) Inputs() []Rel      { return em.inputs }
+func (em *ExtensionMultiRel) Detail() *anypb.Any { return em.detail }
 
 func (em *ExtensionMultiRel) ToProto() *proto.Rel {
 	inputs := make([]*proto.Rel, len(em.inputs))
@@ -1353,6 +1804,26 @@ func (em *ExtensionMultiRel) GetInputs() []Rel {
 	return em.inputs
 }
 
+func (em *ExtensionMultiRel) WithOutputMapping(mapping []int32) (Rel, error) {
+	return withOutputMapping(em, mapping)
+}
+
+func (em *ExtensionMultiRel) WithHint(hint RelHint) Rel {
+	return withHint(em, hint)
+}
+
+func (em *ExtensionMultiRel) Equals(other Rel) bool {
+	return relEquals(em, other)
+}
+
+func (em *ExtensionMultiRel) NamedRecordType() types.NamedStruct {
+	return namedRecordType(em)
+}
+
+func (em *ExtensionMultiRel) SelectByName(names []string) (Rel, error) {
+	return selectByName(em, names)
+}
+
 func (em *ExtensionMultiRel) Copy(newInputs ...Rel) (Rel, error) {
 	proj := *em
 	proj.inputs = newInputs
@@ -1378,8 +1849,33 @@ const (
 	HashMergeRightSemi
 	HashMergeLeftAnti
 	HashMergeRightAnti
+	HashMergeLeftSingle
+	HashMergeRightSingle
 )
 
+var hashMergeJoinTypes = map[JoinType]HashMergeJoinType{
+	JoinTypeInner:       HashMergeInner,
+	JoinTypeOuter:       HashMergeOuter,
+	JoinTypeLeft:        HashMergeLeft,
+	JoinTypeRight:       HashMergeRight,
+	JoinTypeLeftSemi:    HashMergeLeftSemi,
+	JoinTypeRightSemi:   HashMergeRightSemi,
+	JoinTypeLeftAnti:    HashMergeLeftAnti,
+	JoinTypeRightAnti:   HashMergeRightAnti,
+	JoinTypeLeftSingle:  HashMergeLeftSingle,
+	JoinTypeRightSingle: HashMergeRightSingle,
+}
+
+// JoinType returns the equivalent logical join type.
+func (t HashMergeJoinType) JoinType() JoinType {
+	for jt, ht := range hashMergeJoinTypes {
+		if ht == t {
+			return jt
+		}
+	}
+	return JoinTypeUnspecified
+}
+
 // HashJoinRel represents a relational operator to build a hash table out
 // of the right input based on a set of join keys. It will then probe
 // the hash table for incoming inputs, finding matches.
@@ -1393,11 +1889,16 @@ type HashJoinRel struct {
 	advExtension        *extensions.AdvancedExtension
 }
 
+// RecordType returns the output record type of the join, which depends
+// on the join type in the same way as for JoinRel.
 func (hr *HashJoinRel) RecordType() types.StructType {
-	return types.StructType{
-		Nullability: proto.Type_NULLABILITY_REQUIRED,
-		Types:       append(hr.left.Remap(hr.left.RecordType()).Types, hr.right.Remap(hr.right.RecordType()).Types...),
-	}
+	return joinRecordType(hr.left, hr.right, hr.joinType.JoinType())
+}
+
+// JoinedRecordType returns the concatenation of the output record types
+// of the left and right inputs, which the post join filter refers to.
+func (hr *HashJoinRel) JoinedRecordType() types.StructType {
+	return joinedRecordType(hr.left, hr.right)
 }
 
 func (hr *HashJoinRel) Left() Rel                         { return hr.left }
@@ -1457,6 +1958,26 @@ func (hr *HashJoinRel) GetInputs() []Rel {
 	return []Rel{hr.left, hr.right}
 }
 
+func (hr *HashJoinRel) WithOutputMapping(mapping []int32) (Rel, error) {
+	return withOutputMapping(hr, mapping)
+}
+
+func (hr *HashJoinRel) WithHint(hint RelHint) Rel {
+	return withHint(hr, hint)
+}
+
+func (hr *HashJoinRel) Equals(other Rel) bool {
+	return relEquals(hr, other)
+}
+
+func (hr *HashJoinRel) NamedRecordType() types.NamedStruct {
+	return namedRecordType(hr)
+}
+
+func (hr *HashJoinRel) SelectByName(names []string) (Rel, error) {
+	return selectByName(hr, names)
+}
+
 func (hr *HashJoinRel) Copy(newInputs ...Rel) (Rel, error) {
 	if len(newInputs) != 2 {
 		return nil, substraitgo.ErrInvalidInputCount
@@ -1496,11 +2017,16 @@ type MergeJoinRel struct {
 	advExtension        *extensions.AdvancedExtension
 }
 
+// RecordType returns the output record type of the join, which depends
+// on the join type in the same way as for JoinRel.
 func (mr *MergeJoinRel) RecordType() types.StructType {
-	return types.StructType{
-		Nullability: proto.Type_NULLABILITY_REQUIRED,
-		Types:       append(mr.left.Remap(mr.left.RecordType()).Types, mr.right.Remap(mr.right.RecordType()).Types...),
-	}
+	return joinRecordType(mr.left, mr.right, mr.joinType.JoinType())
+}
+
+// JoinedRecordType returns the concatenation of the output record types
+// of the left and right inputs, which the post join filter refers to.
+func (mr *MergeJoinRel) JoinedRecordType() types.StructType {
+	return joinedRecordType(mr.left, mr.right)
 }
 
 func (mr *MergeJoinRel) Left() Rel                         { return mr.left }
@@ -1560,17 +2086,37 @@ func (mr *MergeJoinRel) GetInputs() []Rel {
 	return []Rel{mr.left, mr.right}
 }
 
-func (mr *MergeJoinRel) Copy(newInputs ...Rel) (Rel, error) {
-	if len(newInputs) != 2 {
-		return nil, substraitgo.ErrInvalidInputCount
-	}
-	merge := *mr
-	merge.left, merge.right = newInputs[0], newInputs[1]
-	return &merge, nil
+func (mr *MergeJoinRel) WithOutputMapping(mapping []int32) (Rel, error) {
+	return withOutputMapping(mr, mapping)
 }
 
-func (mr *MergeJoinRel) CopyWithExpressionRewrite(rewriteFunc RewriteFunc, newInputs ...Rel) (Rel, error) {
-	if len(newInputs) != 2 {
+func (mr *MergeJoinRel) WithHint(hint RelHint) Rel {
+	return withHint(mr, hint)
+}
+
+func (mr *MergeJoinRel) Equals(other Rel) bool {
+	return relEquals(mr, other)
+}
+
+func (mr *MergeJoinRel) NamedRecordType() types.NamedStruct {
+	return namedRecordType(mr)
+}
+
+func (mr *MergeJoinRel) SelectByName(names []string) (Rel, error) {
+	return selectByName(mr, names)
+}
+
+func (mr *MergeJoinRel) Copy(newInputs ...Rel) (Rel, error) {
+	if len(newInputs) != 2 {
+		return nil, substraitgo.ErrInvalidInputCount
+	}
+	merge := *mr
+	merge.left, merge.right = newInputs[0], newInputs[1]
+	return &merge, nil
+}
+
+func (mr *MergeJoinRel) CopyWithExpressionRewrite(rewriteFunc RewriteFunc, newInputs ...Rel) (Rel, error) {
+	if len(newInputs) != 2 {
 		return nil, substraitgo.ErrInvalidInputCount
 	}
 	newExpr, err := rewriteFunc(mr.postJoinFilter)
@@ -1586,6 +2132,854 @@ func (mr *MergeJoinRel) CopyWithExpressionRewrite(rewriteFunc RewriteFunc, newIn
 	return &merge, nil
 }
 
+// NestedLoopJoinRel is a physical join operator which compares every
+// record of the left input with every record of the right input using
+// the join expression. It supports the same join types as JoinRel.
+type NestedLoopJoinRel struct {
+	RelCommon
+
+	left, right  Rel
+	expr         expr.Expression
+	joinType     JoinType
+	advExtension *extensions.AdvancedExtension
+}
+
+// RecordType returns the output record type of the join, which depends
+// on the join type in the same way as for JoinRel.
+func (nl *NestedLoopJoinRel) RecordType() types.StructType {
+	return joinRecordType(nl.left, nl.right, nl.joinType)
+}
+
+// JoinedRecordType returns the concatenation of the output record types
+// of the left and right inputs, which the join expression refers to.
+func (nl *NestedLoopJoinRel) JoinedRecordType() types.StructType {
+	return joinedRecordType(nl.left, nl.right)
+}
+
+func (nl *NestedLoopJoinRel) Left() Rel  { return nl.left }
+func (nl *NestedLoopJoinRel) Right() Rel { return nl.right }
+
+// Expr returns the join expression, which is the literal true for a
+// cartesian join if no expression was given.
+func (nl *NestedLoopJoinRel) Expr() expr.Expression {
+	if nl.expr == nil {
+		return defFilter
+	}
+	return nl.expr
+}
+func (nl *NestedLoopJoinRel) Type() JoinType { return nl.joinType }
+func (nl *NestedLoopJoinRel) GetAdvancedExtension() *extensions.AdvancedExtension {
+	return nl.advExtension
+}
+
+func (nl *NestedLoopJoinRel) ToProto() *proto.Rel {
+	outRel := &proto.NestedLoopJoinRel{
+		Common:            nl.toProto(),
+		Left:              nl.left.ToProto(),
+		Right:             nl.right.ToProto(),
+		Type:              proto.NestedLoopJoinRel_JoinType(hashMergeJoinTypes[nl.joinType]),
+		AdvancedExtension: nl.advExtension,
+	}
+
+	if nl.expr != nil {
+		outRel.Expression = nl.expr.ToProto()
+	}
+
+	return &proto.Rel{
+		RelType: &proto.Rel_NestedLoopJoin{
+			NestedLoopJoin: outRel,
+		},
+	}
+}
+
+func (nl *NestedLoopJoinRel) ToProtoPlanRel() *proto.PlanRel {
+	return &proto.PlanRel{
+		RelType: &proto.PlanRel_Rel{
+			Rel: nl.ToProto(),
+		},
+	}
+}
+
+func (nl *NestedLoopJoinRel) GetInputs() []Rel {
+	return []Rel{nl.left, nl.right}
+}
+
+func (nl *NestedLoopJoinRel) WithOutputMapping(mapping []int32) (Rel, error) {
+	return withOutputMapping(nl, mapping)
+}
+
+func (nl *NestedLoopJoinRel) WithHint(hint RelHint) Rel {
+	return withHint(nl, hint)
+}
+
+func (nl *NestedLoopJoinRel) Equals(other Rel) bool {
+	return relEquals(nl, other)
+}
+
+func (nl *NestedLoopJoinRel) NamedRecordType() types.NamedStruct {
+	return namedRecordType(nl)
+}
+
+func (nl *NestedLoopJoinRel) SelectByName(names []string) (Rel, error) {
+	return selectByName(nl, names)
+}
+
+func (nl *NestedLoopJoinRel) Copy(newInputs ...Rel) (Rel, error) {
+	if len(newInputs) != 2 {
+		return nil, substraitgo.ErrInvalidInputCount
+	}
+	join := *nl
+	join.left, join.right = newInputs[0], newInputs[1]
+	return &join, nil
+}
+
+func (nl *NestedLoopJoinRel) CopyWithExpressionRewrite(rewriteFunc RewriteFunc, newInputs ...Rel) (Rel, error) {
+	if len(newInputs) != 2 {
+		return nil, substraitgo.ErrInvalidInputCount
+	}
+
+	newExpr := nl.expr
+	if nl.expr != nil {
+		var err error
+		if newExpr, err = rewriteFunc(nl.expr); err != nil {
+			return nil, err
+		}
+	}
+
+	if newExpr == nl.expr && slices.Equal(newInputs, nl.GetInputs()) {
+		return nl, nil
+	}
+	join := *nl
+	join.left, join.right = newInputs[0], newInputs[1]
+	join.expr = newExpr
+	return &join, nil
+}
+
+type WriteOp = proto.WriteRel_WriteOp
+
+const (
+	WriteOpUnspecified = proto.WriteRel_WRITE_OP_UNSPECIFIED
+	WriteOpInsert      = proto.WriteRel_WRITE_OP_INSERT
+	WriteOpDelete      = proto.WriteRel_WRITE_OP_DELETE
+	WriteOpUpdate      = proto.WriteRel_WRITE_OP_UPDATE
+	WriteOpCtas        = proto.WriteRel_WRITE_OP_CTAS
+)
+
+type WriteOutputMode = proto.WriteRel_OutputMode
+
+const (
+	WriteOutputUnspecified     = proto.WriteRel_OUTPUT_MODE_UNSPECIFIED
+	WriteOutputNoOutput        = proto.WriteRel_OUTPUT_MODE_NO_OUTPUT
+	WriteOutputModifiedRecords = proto.WriteRel_OUTPUT_MODE_MODIFIED_RECORDS
+)
+
+// NamedTableWriteRel is a relational operator which modifies the named
+// table by inserting, deleting or updating the records provided by its
+// input, or creates the table from them (CTAS). The input records must
+// match the schema of the table. Depending on the output mode, it
+// either produces no records or the records which were modified.
+type NamedTableWriteRel struct {
+	RelCommon
+
+	input        Rel
+	names        []string
+	tableSchema  types.NamedStruct
+	op           WriteOp
+	output       WriteOutputMode
+	advExtension *extensions.AdvancedExtension
+}
+
+func (w *NamedTableWriteRel) RecordType() types.StructType {
+	if w.output == WriteOutputModifiedRecords {
+		return w.tableSchema.Struct
+	}
+	return types.StructType{Nullability: types.NullabilityRequired, Types: []types.Type{}}
+}
+
+func (w *NamedTableWriteRel) Input() Rel                     { return w.input }
+func (w *NamedTableWriteRel) Names() []string                { return w.names }
+func (w *NamedTableWriteRel) TableSchema() types.NamedStruct { return w.tableSchema }
+func (w *NamedTableWriteRel) Op() WriteOp                    { return w.op }
+func (w *NamedTableWriteRel) OutputMode() WriteOutputMode    { return w.output }
+
+func (w *NamedTableWriteRel) NamedTableAdvancedExtension() *extensions.AdvancedExtension {
+	return w.advExtension
+}
+
+func (w *NamedTableWriteRel) ToProto() *proto.Rel {
+	return &proto.Rel{
+		RelType: &proto.Rel_Write{
+			Write: &proto.WriteRel{
+				Common: w.toProto(),
+				WriteType: &proto.WriteRel_NamedTable{
+					NamedTable: &proto.NamedObjectWrite{
+						Names:             w.names,
+						AdvancedExtension: w.advExtension,
+					},
+				},
+				TableSchema: w.tableSchema.ToProto(),
+				Op:          w.op,
+				Input:       w.input.ToProto(),
+				Output:      w.output,
+			},
+		},
+	}
+}
+
+func (w *NamedTableWriteRel) ToProtoPlanRel() *proto.PlanRel {
+	return &proto.PlanRel{
+		RelType: &proto.PlanRel_Rel{
+			Rel: w.ToProto(),
+		},
+	}
+}
+
+func (w *NamedTableWriteRel) GetInputs() []Rel {
+	return []Rel{w.input}
+}
+
+func (w *NamedTableWriteRel) WithOutputMapping(mapping []int32) (Rel, error) {
+	return withOutputMapping(w, mapping)
+}
+
+func (w *NamedTableWriteRel) WithHint(hint RelHint) Rel {
+	return withHint(w, hint)
+}
+
+func (w *NamedTableWriteRel) Equals(other Rel) bool {
+	return relEquals(w, other)
+}
+
+func (w *NamedTableWriteRel) NamedRecordType() types.NamedStruct {
+	return namedRecordType(w)
+}
+
+func (w *NamedTableWriteRel) SelectByName(names []string) (Rel, error) {
+	return selectByName(w, names)
+}
+
+func (w *NamedTableWriteRel) Copy(newInputs ...Rel) (Rel, error) {
+	if len(newInputs) != 1 {
+		return nil, substraitgo.ErrInvalidInputCount
+	}
+	write := *w
+	write.input = newInputs[0]
+	return &write, nil
+}
+
+func (w *NamedTableWriteRel) CopyWithExpressionRewrite(_ RewriteFunc, newInputs ...Rel) (Rel, error) {
+	if slices.Equal(newInputs, w.GetInputs()) {
+		return w, nil
+	}
+	return w.Copy(newInputs...)
+}
+
+type DdlOp = proto.DdlRel_DdlOp
+
+const (
+	DdlOpUnspecified     = proto.DdlRel_DDL_OP_UNSPECIFIED
+	DdlOpCreate          = proto.DdlRel_DDL_OP_CREATE
+	DdlOpCreateOrReplace = proto.DdlRel_DDL_OP_CREATE_OR_REPLACE
+	DdlOpAlter           = proto.DdlRel_DDL_OP_ALTER
+	DdlOpDrop            = proto.DdlRel_DDL_OP_DROP
+	DdlOpDropIfExist     = proto.DdlRel_DDL_OP_DROP_IF_EXIST
+)
+
+type DdlObject = proto.DdlRel_DdlObject
+
+const (
+	DdlObjectUnspecified = proto.DdlRel_DDL_OBJECT_UNSPECIFIED
+	DdlObjectTable       = proto.DdlRel_DDL_OBJECT_TABLE
+	DdlObjectView        = proto.DdlRel_DDL_OBJECT_VIEW
+)
+
+// DdlRel is a relational operator which changes the definition of the
+// named table or view, such as creating, altering or dropping it. The
+// schema is the after-image of the object, for a view created from a
+// query the relation defining the view is its only input. It produces
+// no records.
+type DdlRel struct {
+	RelCommon
+
+	names          []string
+	tableSchema    types.NamedStruct
+	tableDefaults  expr.StructLiteralValue
+	object         DdlObject
+	op             DdlOp
+	viewDefinition Rel
+	advExtension   *extensions.AdvancedExtension
+}
+
+func (d *DdlRel) RecordType() types.StructType {
+	return types.StructType{Nullability: types.NullabilityRequired, Types: []types.Type{}}
+}
+
+func (d *DdlRel) Names() []string                { return d.names }
+func (d *DdlRel) TableSchema() types.NamedStruct { return d.tableSchema }
+func (d *DdlRel) Object() DdlObject              { return d.object }
+func (d *DdlRel) Op() DdlOp                      { return d.op }
+
+// TableDefaults returns the default values of the columns of the table,
+// if any were given.
+func (d *DdlRel) TableDefaults() expr.StructLiteralValue { return d.tableDefaults }
+
+// ViewDefinition returns the relation defining the view, or nil if there
+// isn't one.
+func (d *DdlRel) ViewDefinition() Rel { return d.viewDefinition }
+
+func (d *DdlRel) NamedObjectAdvancedExtension() *extensions.AdvancedExtension {
+	return d.advExtension
+}
+
+func (d *DdlRel) ToProto() *proto.Rel {
+	out := &proto.DdlRel{
+		Common: d.toProto(),
+		WriteType: &proto.DdlRel_NamedObject{
+			NamedObject: &proto.NamedObjectWrite{
+				Names:             d.names,
+				AdvancedExtension: d.advExtension,
+			},
+		},
+		TableSchema: d.tableSchema.ToProto(),
+		Object:      d.object,
+		Op:          d.op,
+	}
+	if d.tableDefaults != nil {
+		out.TableDefaults = d.tableDefaults.ToProto()
+	}
+	if d.viewDefinition != nil {
+		out.ViewDefinition = d.viewDefinition.ToProto()
+	}
+
+	return &proto.Rel{
+		RelType: &proto.Rel_Ddl{Ddl: out},
+	}
+}
+
+func (d *DdlRel) ToProtoPlanRel() *proto.PlanRel {
+	return &proto.PlanRel{
+		RelType: &proto.PlanRel_Rel{
+			Rel: d.ToProto(),
+		},
+	}
+}
+
+func (d *DdlRel) GetInputs() []Rel {
+	if d.viewDefinition == nil {
+		return []Rel{}
+	}
+	return []Rel{d.viewDefinition}
+}
+
+func (d *DdlRel) WithOutputMapping(mapping []int32) (Rel, error) {
+	return withOutputMapping(d, mapping)
+}
+
+func (d *DdlRel) WithHint(hint RelHint) Rel {
+	return withHint(d, hint)
+}
+
+func (d *DdlRel) Equals(other Rel) bool {
+	return relEquals(d, other)
+}
+
+func (d *DdlRel) NamedRecordType() types.NamedStruct {
+	return namedRecordType(d)
+}
+
+func (d *DdlRel) SelectByName(names []string) (Rel, error) {
+	return selectByName(d, names)
+}
+
+func (d *DdlRel) Copy(newInputs ...Rel) (Rel, error) {
+	if len(newInputs) != len(d.GetInputs()) {
+		return nil, substraitgo.ErrInvalidInputCount
+	}
+	if len(newInputs) == 0 {
+		return d, nil
+	}
+	ddl := *d
+	ddl.viewDefinition = newInputs[0]
+	return &ddl, nil
+}
+
+func (d *DdlRel) CopyWithExpressionRewrite(_ RewriteFunc, newInputs ...Rel) (Rel, error) {
+	if slices.Equal(newInputs, d.GetInputs()) {
+		return d, nil
+	}
+	return d.Copy(newInputs...)
+}
+
+// ExchangeScheme describes how an ExchangeRel distributes the records of
+// its input across partitions. It is one of ExchangeScatterByFields,
+// ExchangeSingleTarget, ExchangeMultiTarget, ExchangeRoundRobin or
+// ExchangeBroadcast.
+type ExchangeScheme interface {
+	isExchangeScheme()
+}
+
+// ExchangeScatterByFields distributes records by hashing the values of
+// the listed fields of the input.
+type ExchangeScatterByFields struct {
+	Fields []int32
+}
+
+// ExchangeSingleTarget sends each record to the single partition
+// identified by evaluating Expr, which must yield an integer.
+type ExchangeSingleTarget struct {
+	Expr expr.Expression
+}
+
+// ExchangeMultiTarget sends each record to all of the partitions
+// identified by evaluating Expr, which must yield a list of integers.
+type ExchangeMultiTarget struct {
+	Expr               expr.Expression
+	ConstrainedToCount bool
+}
+
+// ExchangeRoundRobin distributes records across the partitions in turn,
+// either exactly per record or approximately.
+type ExchangeRoundRobin struct {
+	Exact bool
+}
+
+// ExchangeBroadcast sends every record to all partitions.
+type ExchangeBroadcast struct{}
+
+func (*ExchangeScatterByFields) isExchangeScheme() {}
+func (*ExchangeSingleTarget) isExchangeScheme()    {}
+func (*ExchangeMultiTarget) isExchangeScheme()     {}
+func (*ExchangeRoundRobin) isExchangeScheme()      {}
+func (*ExchangeBroadcast) isExchangeScheme()       {}
+
+// ExchangeRel is a physical relational operator which redistributes the
+// records of its input across a number of partitions according to its
+// scheme. The records themselves are unchanged.
+type ExchangeRel struct {
+	RelCommon
+
+	input          Rel
+	partitionCount int32
+	scheme         ExchangeScheme
+	targets        []*proto.ExchangeRel_ExchangeTarget
+	advExtension   *extensions.AdvancedExtension
+}
+
+func (ex *ExchangeRel) RecordType() types.StructType {
+	return ex.input.Remap(ex.input.RecordType())
+}
+
+func (ex *ExchangeRel) Input() Rel             { return ex.input }
+func (ex *ExchangeRel) PartitionCount() int32  { return ex.partitionCount }
+func (ex *ExchangeRel) Scheme() ExchangeScheme { return ex.scheme }
+
+// Targets returns the optional list of targets of the exchange, which
+// are not interpreted by this package.
+func (ex *ExchangeRel) Targets() []*proto.ExchangeRel_ExchangeTarget { return ex.targets }
+
+func (ex *ExchangeRel) GetAdvancedExtension() *extensions.AdvancedExtension {
+	return ex.advExtension
+}
+
+func (ex *ExchangeRel) ToProto() *proto.Rel {
+	out := &proto.ExchangeRel{
+		Common:            ex.toProto(),
+		Input:             ex.input.ToProto(),
+		PartitionCount:    ex.partitionCount,
+		Targets:           ex.targets,
+		AdvancedExtension: ex.advExtension,
+	}
+
+	switch s := ex.scheme.(type) {
+	case *ExchangeScatterByFields:
+		fields := make([]*proto.Expression_FieldReference, len(s.Fields))
+		for i, f := range s.Fields {
+			ref := expr.FieldReference{Reference: expr.NewStructFieldRef(f), Root: expr.RootReference}
+			fields[i] = ref.ToProtoFieldRef()
+		}
+		out.ExchangeKind = &proto.ExchangeRel_ScatterByFields{
+			ScatterByFields: &proto.ExchangeRel_ScatterFields{Fields: fields},
+		}
+	case *ExchangeSingleTarget:
+		out.ExchangeKind = &proto.ExchangeRel_SingleTarget{
+			SingleTarget: &proto.ExchangeRel_SingleBucketExpression{
+				Expression: s.Expr.ToProto(),
+			},
+		}
+	case *ExchangeMultiTarget:
+		out.ExchangeKind = &proto.ExchangeRel_MultiTarget{
+			MultiTarget: &proto.ExchangeRel_MultiBucketExpression{
+				Expression:         s.Expr.ToProto(),
+				ConstrainedToCount: s.ConstrainedToCount,
+			},
+		}
+	case *ExchangeRoundRobin:
+		out.ExchangeKind = &proto.ExchangeRel_RoundRobin_{
+			RoundRobin: &proto.ExchangeRel_RoundRobin{Exact: s.Exact},
+		}
+	case *ExchangeBroadcast:
+		out.ExchangeKind = &proto.ExchangeRel_Broadcast_{
+			Broadcast: &proto.ExchangeRel_Broadcast{},
+		}
+	}
+
+	return &proto.Rel{
+		RelType: &proto.Rel_Exchange{Exchange: out},
+	}
+}
+
+func (ex *ExchangeRel) ToProtoPlanRel() *proto.PlanRel {
+	return &proto.PlanRel{
+		RelType: &proto.PlanRel_Rel{
+			Rel: ex.ToProto(),
+		},
+	}
+}
+
+func (ex *ExchangeRel) GetInputs() []Rel {
+	return []Rel{ex.input}
+}
+
+func (ex *ExchangeRel) WithOutputMapping(mapping []int32) (Rel, error) {
+	return withOutputMapping(ex, mapping)
+}
+
+func (ex *ExchangeRel) WithHint(hint RelHint) Rel {
+	return withHint(ex, hint)
+}
+
+func (ex *ExchangeRel) Equals(other Rel) bool {
+	return relEquals(ex, other)
+}
+
+func (ex *ExchangeRel) NamedRecordType() types.NamedStruct {
+	return namedRecordType(ex)
+}
+
+func (ex *ExchangeRel) SelectByName(names []string) (Rel, error) {
+	return selectByName(ex, names)
+}
+
+func (ex *ExchangeRel) Copy(newInputs ...Rel) (Rel, error) {
+	if len(newInputs) != 1 {
+		return nil, substraitgo.ErrInvalidInputCount
+	}
+	exchange := *ex
+	exchange.input = newInputs[0]
+	return &exchange, nil
+}
+
+func (ex *ExchangeRel) CopyWithExpressionRewrite(rewriteFunc RewriteFunc, newInputs ...Rel) (Rel, error) {
+	if len(newInputs) != 1 {
+		return nil, substraitgo.ErrInvalidInputCount
+	}
+
+	scheme := ex.scheme
+	switch s := ex.scheme.(type) {
+	case *ExchangeSingleTarget:
+		e, err := rewriteFunc(s.Expr)
+		if err != nil {
+			return nil, err
+		}
+		if e != s.Expr {
+			scheme = &ExchangeSingleTarget{Expr: e}
+		}
+	case *ExchangeMultiTarget:
+		e, err := rewriteFunc(s.Expr)
+		if err != nil {
+			return nil, err
+		}
+		if e != s.Expr {
+			scheme = &ExchangeMultiTarget{Expr: e, ConstrainedToCount: s.ConstrainedToCount}
+		}
+	}
+
+	if newInputs[0] == ex.input && scheme == ex.scheme {
+		return ex, nil
+	}
+	exchange := *ex
+	exchange.input = newInputs[0]
+	exchange.scheme = scheme
+	return &exchange, nil
+}
+
+// ExpandField defines an output field of an ExpandRel, it is either a
+// *SwitchingField or a *ConsistentField.
+type ExpandField interface {
+	isExpandField()
+	// GetType returns the type of the output field.
+	GetType() types.Type
+	ToProto() *proto.ExpandRel_ExpandField
+}
+
+// SwitchingField is an ExpandRel field which takes the value of a
+// different expression for each duplicate of a record, such as a
+// grouping column which is nulled out for some grouping sets. All of
+// the duplicates must have the same type, the output field is nullable
+// if any of them are nullable.
+type SwitchingField struct {
+	Duplicates []expr.Expression
+}
+
+func (*SwitchingField) isExpandField() {}
+
+func (s *SwitchingField) GetType() types.Type {
+	out := s.Duplicates[0].GetType()
+	for _, d := range s.Duplicates[1:] {
+		if d.GetType().GetNullability() == types.NullabilityNullable {
+			return out.WithNullability(types.NullabilityNullable)
+		}
+	}
+	return out
+}
+
+func (s *SwitchingField) ToProto() *proto.ExpandRel_ExpandField {
+	dups := make([]*proto.Expression, len(s.Duplicates))
+	for i, d := range s.Duplicates {
+		dups[i] = d.ToProto()
+	}
+	return &proto.ExpandRel_ExpandField{
+		FieldType: &proto.ExpandRel_ExpandField_SwitchingField{
+			SwitchingField: &proto.ExpandRel_SwitchingField{Duplicates: dups},
+		},
+	}
+}
+
+// ConsistentField is an ExpandRel field which has the same value for
+// every duplicate of a record.
+type ConsistentField struct {
+	Expr expr.Expression
+}
+
+func (*ConsistentField) isExpandField()        {}
+func (c *ConsistentField) GetType() types.Type { return c.Expr.GetType() }
+
+func (c *ConsistentField) ToProto() *proto.ExpandRel_ExpandField {
+	return &proto.ExpandRel_ExpandField{
+		FieldType: &proto.ExpandRel_ExpandField_ConsistentField{
+			ConsistentField: c.Expr.ToProto(),
+		},
+	}
+}
+
+// ExpandRel duplicates each record of its input, emitting one record per
+// alternative of its switching fields, as is needed to implement
+// GROUPING SETS, ROLLUP and CUBE. The output consists of the expand
+// fields, followed by any input fields beyond the number of expand
+// fields unchanged, and finally an i64 column holding the zero-based
+// index of the duplicate which produced the record.
+type ExpandRel struct {
+	RelCommon
+
+	input  Rel
+	fields []ExpandField
+}
+
+func (ex *ExpandRel) RecordType() types.StructType {
+	initial := ex.input.Remap(ex.input.RecordType())
+	output := make([]types.Type, 0, max(len(ex.fields), len(initial.Types))+1)
+	for _, f := range ex.fields {
+		output = append(output, f.GetType())
+	}
+	if len(ex.fields) < len(initial.Types) {
+		output = append(output, initial.Types[len(ex.fields):]...)
+	}
+	output = append(output, &types.Int64Type{Nullability: types.NullabilityRequired})
+
+	return types.StructType{
+		Nullability: types.NullabilityRequired,
+		Types:       output,
+	}
+}
+
+func (ex *ExpandRel) Input() Rel            { return ex.input }
+func (ex *ExpandRel) Fields() []ExpandField { return ex.fields }
+
+// NumDuplicates returns the number of records emitted for each input
+// record, which is the number of alternatives of the switching fields.
+func (ex *ExpandRel) NumDuplicates() int {
+	for _, f := range ex.fields {
+		if s, ok := f.(*SwitchingField); ok {
+			return len(s.Duplicates)
+		}
+	}
+	return 1
+}
+
+func (ex *ExpandRel) ToProto() *proto.Rel {
+	fields := make([]*proto.ExpandRel_ExpandField, len(ex.fields))
+	for i, f := range ex.fields {
+		fields[i] = f.ToProto()
+	}
+
+	return &proto.Rel{
+		RelType: &proto.Rel_Expand{
+			Expand: &proto.ExpandRel{
+				Common: ex.toProto(),
+				Input:  ex.input.ToProto(),
+				Fields: fields,
+			},
+		},
+	}
+}
+
+func (ex *ExpandRel) ToProtoPlanRel() *proto.PlanRel {
+	return &proto.PlanRel{
+		RelType: &proto.PlanRel_Rel{
+			Rel: ex.ToProto(),
+		},
+	}
+}
+
+func (ex *ExpandRel) GetInputs() []Rel {
+	return []Rel{ex.input}
+}
+
+func (ex *ExpandRel) WithOutputMapping(mapping []int32) (Rel, error) {
+	return withOutputMapping(ex, mapping)
+}
+
+func (ex *ExpandRel) WithHint(hint RelHint) Rel {
+	return withHint(ex, hint)
+}
+
+func (ex *ExpandRel) Equals(other Rel) bool {
+	return relEquals(ex, other)
+}
+
+func (ex *ExpandRel) NamedRecordType() types.NamedStruct {
+	return namedRecordType(ex)
+}
+
+func (ex *ExpandRel) SelectByName(names []string) (Rel, error) {
+	return selectByName(ex, names)
+}
+
+func (ex *ExpandRel) Copy(newInputs ...Rel) (Rel, error) {
+	if len(newInputs) != 1 {
+		return nil, substraitgo.ErrInvalidInputCount
+	}
+	expand := *ex
+	expand.input = newInputs[0]
+	return &expand, nil
+}
+
+func (ex *ExpandRel) CopyWithExpressionRewrite(rewriteFunc RewriteFunc, newInputs ...Rel) (Rel, error) {
+	if len(newInputs) != 1 {
+		return nil, substraitgo.ErrInvalidInputCount
+	}
+
+	changed := newInputs[0] != ex.input
+	fields := make([]ExpandField, len(ex.fields))
+	for i, f := range ex.fields {
+		switch f := f.(type) {
+		case *SwitchingField:
+			dups := make([]expr.Expression, len(f.Duplicates))
+			for j, d := range f.Duplicates {
+				var err error
+				if dups[j], err = rewriteFunc(d); err != nil {
+					return nil, err
+				}
+			}
+			if slices.Equal(dups, f.Duplicates) {
+				fields[i] = f
+			} else {
+				fields[i], changed = &SwitchingField{Duplicates: dups}, true
+			}
+		case *ConsistentField:
+			e, err := rewriteFunc(f.Expr)
+			if err != nil {
+				return nil, err
+			}
+			if e == f.Expr {
+				fields[i] = f
+			} else {
+				fields[i], changed = &ConsistentField{Expr: e}, true
+			}
+		}
+	}
+
+	if !changed {
+		return ex, nil
+	}
+	expand := *ex
+	expand.input = newInputs[0]
+	expand.fields = fields
+	return &expand, nil
+}
+
+// ReferenceRel is a relation which refers to one of the other relations
+// of the plan by its ordinal in the list of plan relations, allowing a
+// subplan to be shared by multiple relation trees. Its output is the
+// output of the referenced relation tree.
+type ReferenceRel struct {
+	RelCommon
+
+	ordinal    int32
+	recordType types.StructType
+}
+
+// SubtreeOrdinal is the index of the referenced relation within the
+// list of relations of the plan.
+func (r *ReferenceRel) SubtreeOrdinal() int32 { return r.ordinal }
+
+func (r *ReferenceRel) RecordType() types.StructType { return r.recordType }
+
+func (r *ReferenceRel) ToProto() *proto.Rel {
+	return &proto.Rel{
+		RelType: &proto.Rel_Reference{
+			Reference: &proto.ReferenceRel{
+				SubtreeOrdinal: r.ordinal,
+			},
+		},
+	}
+}
+
+func (r *ReferenceRel) ToProtoPlanRel() *proto.PlanRel {
+	return &proto.PlanRel{
+		RelType: &proto.PlanRel_Rel{
+			Rel: r.ToProto(),
+		},
+	}
+}
+
+func (r *ReferenceRel) GetInputs() []Rel {
+	return []Rel{}
+}
+
+// WithOutputMapping only accepts a nil mapping, as a ReferenceRel has
+// no common fields in which to serialize one.
+func (r *ReferenceRel) WithOutputMapping(mapping []int32) (Rel, error) {
+	if mapping != nil {
+		return nil, fmt.Errorf("%w: ReferenceRel cannot have an output mapping", substraitgo.ErrInvalidRel)
+	}
+	return r, nil
+}
+
+// WithHint returns the relation unchanged, as a ReferenceRel has no
+// common fields in which to serialize a hint.
+func (r *ReferenceRel) WithHint(RelHint) Rel { return r }
+
+func (r *ReferenceRel) Equals(other Rel) bool {
+	return relEquals(r, other)
+}
+
+func (r *ReferenceRel) NamedRecordType() types.NamedStruct {
+	return namedRecordType(r)
+}
+
+func (r *ReferenceRel) SelectByName(names []string) (Rel, error) {
+	return selectByName(r, names)
+}
+
+func (r *ReferenceRel) Copy(_ ...Rel) (Rel, error) {
+	return r, nil
+}
+
+func (r *ReferenceRel) CopyWithExpressionRewrite(_ RewriteFunc, _ ...Rel) (Rel, error) {
+	return r, nil
+}
+
 var (
 	_ Rel = (*NamedTableReadRel)(nil)
 	_ Rel = (*VirtualTableReadRel)(nil)
@@ -1604,6 +2998,12 @@ var (
 	_ Rel = (*ExtensionMultiRel)(nil)
 	_ Rel = (*HashJoinRel)(nil)
 	_ Rel = (*MergeJoinRel)(nil)
+	_ Rel = (*NestedLoopJoinRel)(nil)
+	_ Rel = (*NamedTableWriteRel)(nil)
+	_ Rel = (*DdlRel)(nil)
+	_ Rel = (*ExchangeRel)(nil)
+	_ Rel = (*ExpandRel)(nil)
+	_ Rel = (*ReferenceRel)(nil)
 
 	_ MultiRel = (*SetRel)(nil)
 	_ MultiRel = (*ExtensionMultiRel)(nil)
@@ -1612,6 +3012,7 @@ var (
 	_ BiRel = (*CrossRel)(nil)
 	_ BiRel = (*HashJoinRel)(nil)
 	_ BiRel = (*MergeJoinRel)(nil)
+	_ BiRel = (*NestedLoopJoinRel)(nil)
 
 	_ SingleInputRel = (*ProjectRel)(nil)
 	_ SingleInputRel = (*FetchRel)(nil)
@@ -1619,4 +3020,7 @@ var (
 	_ SingleInputRel = (*FilterRel)(nil)
 	_ SingleInputRel = (*SortRel)(nil)
 	_ SingleInputRel = (*ExtensionSingleRel)(nil)
+	_ SingleInputRel = (*NamedTableWriteRel)(nil)
+	_ SingleInputRel = (*ExchangeRel)(nil)
+	_ SingleInputRel = (*ExpandRel)(nil)
 )
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	substraitgo "github.com/substrait-io/substrait-go"
 	"github.com/substrait-io/substrait-go/expr"
 	"github.com/substrait-io/substrait-go/types"
 )
@@ -317,3 +318,20 @@ func TestRelations_Copy(t *testing.T) {
 		})
 	}
 }
+
+func TestWalkCycle(t *testing.T) {
+	filter := &FilterRel{input: createVirtualTableReadRel(1), cond: createPrimitiveBool(true)}
+	fetch := &FetchRel{input: filter, count: 1}
+	filter.input = fetch
+
+	var visited []Rel
+	Walk(fetch, func(r Rel) bool {
+		visited = append(visited, r)
+		return true
+	})
+	assert.Equal(t, []Rel{fetch, filter}, visited)
+
+	_, err := Transform(fetch, func(r Rel) (Rel, error) { return r, nil })
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+	assert.ErrorContains(t, err, "relation tree contains a cycle")
+}
@@ -0,0 +1,290 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package plan
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/substrait-io/substrait-go/expr"
+)
+
+// ToText returns a human readable text representation of the plan,
+// intended for debugging and for comparing plans in tests. It lists the
+// extensions used by the plan, grouped by the URI they are declared in,
+// followed by each relation tree with one relation per line and the
+// inputs of a relation indented below it. For example:
+//
+//	extensions:
+//	  uri https://github.com/substrait-io/substrait/blob/main/extensions/functions_aggregate_generic.yaml
+//	    function count:
+//	relations:
+//	  root names=[val, cnt]
+//	    aggregate groupings=[[.field(0) => string]] measures=[count() => i64]
+//	      read table=[test] schema=struct<string, fp32>
+//
+// Functions are identified by their extension URI and name rather than
+// their anchors, so the text doesn't depend on the order in which the
+// functions were added to the plan. Expressions are written using their
+// String methods.
+func ToText(p *Plan) (string, error) {
+	out, err := p.ToProto()
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	if len(out.ExtensionUris) > 0 {
+		b.WriteString("extensions:\n")
+	}
+	for _, u := range out.ExtensionUris {
+		fmt.Fprintf(&b, "  uri %s\n", u.Uri)
+		for _, d := range out.Extensions {
+			switch {
+			case d.GetExtensionType().GetExtensionUriReference() == u.ExtensionUriAnchor:
+				fmt.Fprintf(&b, "    type %s\n", d.GetExtensionType().Name)
+			case d.GetExtensionTypeVariation().GetExtensionUriReference() == u.ExtensionUriAnchor:
+				fmt.Fprintf(&b, "    type_variation %s\n", d.GetExtensionTypeVariation().Name)
+			case d.GetExtensionFunction().GetExtensionUriReference() == u.ExtensionUriAnchor:
+				fmt.Fprintf(&b, "    function %s\n", d.GetExtensionFunction().Name)
+			}
+		}
+	}
+
+	b.WriteString("relations:\n")
+	for _, r := range p.relations {
+		if !r.IsRoot() {
+			writeRelText(&b, r.rel, 1)
+			continue
+		}
+
+		fmt.Fprintf(&b, "  root names=[%s]\n", strings.Join(r.root.names, ", "))
+		writeRelText(&b, r.root.input, 2)
+	}
+
+	return b.String(), nil
+}
+
+func writeRelText(b *strings.Builder, rel Rel, depth int) {
+	kind, attrs := describeRel(rel)
+	b.WriteString(strings.Repeat("  ", depth))
+	b.WriteString(kind)
+	for _, a := range attrs {
+		b.WriteByte(' ')
+		b.WriteString(a)
+	}
+	if mapping := rel.OutputMapping(); mapping != nil {
+		b.WriteString(" emit=")
+		b.WriteString(formatMapping(mapping))
+	}
+	b.WriteByte('\n')
+
+	for _, in := range rel.GetInputs() {
+		writeRelText(b, in, depth+1)
+	}
+}
+
+func formatMapping(mapping []int32) string {
+	out := make([]string, len(mapping))
+	for i, m := range mapping {
+		out[i] = strconv.Itoa(int(m))
+	}
+	return "[" + strings.Join(out, ", ") + "]"
+}
+
+func formatExprs(exprs []expr.Expression) string {
+	out := make([]string, len(exprs))
+	for i, e := range exprs {
+		out[i] = e.String()
+	}
+	return "[" + strings.Join(out, ", ") + "]"
+}
+
+func formatSorts(sorts []expr.SortField) string {
+	out := make([]string, len(sorts))
+	for i, s := range sorts {
+		out[i] = fmt.Sprintf("%s %s", s.Expr, strings.TrimPrefix(fmt.Sprint(s.Kind), "SORT_DIRECTION_"))
+	}
+	return "[" + strings.Join(out, ", ") + "]"
+}
+
+func formatFieldRefs(refs []*expr.FieldReference) string {
+	exprs := make([]expr.Expression, len(refs))
+	for i, r := range refs {
+		exprs[i] = r
+	}
+	return formatExprs(exprs)
+}
+
+var hashMergeJoinTypeNames = [...]string{
+	HashMergeUnspecified: "UNSPECIFIED",
+	HashMergeInner:       "INNER",
+	HashMergeOuter:       "OUTER",
+	HashMergeLeft:        "LEFT",
+	HashMergeRight:       "RIGHT",
+	HashMergeLeftSemi:    "LEFT_SEMI",
+	HashMergeRightSemi:   "RIGHT_SEMI",
+	HashMergeLeftAnti:    "LEFT_ANTI",
+	HashMergeRightAnti:   "RIGHT_ANTI",
+	HashMergeLeftSingle:  "LEFT_SINGLE",
+	HashMergeRightSingle: "RIGHT_SINGLE",
+}
+
+func hashMergeJoinTypeName(t HashMergeJoinType) string {
+	if int(t) < 0 || int(t) >= len(hashMergeJoinTypeNames) {
+		return strconv.Itoa(int(t))
+	}
+	return hashMergeJoinTypeNames[t]
+}
+
+// describeRel returns the kind of the relation and its key attributes
+// formatted as key=value, excluding its inputs and output mapping.
+func describeRel(rel Rel) (string, []string) {
+	var attrs []string
+	add := func(key string, value any) {
+		attrs = append(attrs, fmt.Sprintf("%s=%v", key, value))
+	}
+
+	readAttrs := func(r ReadRel) {
+		schema := r.BaseSchema()
+		add("schema", &schema.Struct)
+		if r.Filter() != nil {
+			add("filter", r.Filter())
+		}
+		if r.BestEffortFilter() != nil {
+			add("best_effort_filter", r.BestEffortFilter())
+		}
+		if r.Projection() != nil {
+			add("projection", r.Projection())
+		}
+	}
+
+	switch r := rel.(type) {
+	case *NamedTableReadRel:
+		add("table", "["+strings.Join(r.Names(), ", ")+"]")
+		readAttrs(r)
+		return "read", attrs
+	case *VirtualTableReadRel:
+		add("values", r.RowCount())
+		readAttrs(r)
+		return "read", attrs
+	case *ExtensionTableReadRel:
+		add("detail", r.Detail().GetTypeUrl())
+		readAttrs(r)
+		return "read", attrs
+	case *LocalFileReadRel:
+		readAttrs(r)
+		return "read", attrs
+	case *ProjectRel:
+		add("exprs", formatExprs(r.Expressions()))
+		return "project", attrs
+	case *FilterRel:
+		add("condition", r.Condition())
+		return "filter", attrs
+	case *FetchRel:
+		add("offset", r.Offset())
+		if r.Count() == FetchCountAll {
+			add("count", "ALL")
+		} else {
+			add("count", r.Count())
+		}
+		return "fetch", attrs
+	case *SortRel:
+		add("sorts", formatSorts(r.Sorts()))
+		return "sort", attrs
+	case *AggregateRel:
+		groups := make([]string, len(r.Groupings()))
+		for i, g := range r.Groupings() {
+			groups[i] = formatExprs(g)
+		}
+		add("groupings", "["+strings.Join(groups, ", ")+"]")
+
+		measures := make([]string, len(r.Measures()))
+		for i, m := range r.Measures() {
+			measures[i] = m.Measure().String()
+			if m.filter != nil {
+				measures[i] += " filter " + m.filter.String()
+			}
+		}
+		add("measures", "["+strings.Join(measures, ", ")+"]")
+		return "aggregate", attrs
+	case *JoinRel:
+		add("type", strings.TrimPrefix(r.Type().String(), "JOIN_TYPE_"))
+		add("expr", r.Expr())
+		if r.PostJoinFilter() != nil {
+			add("post_join_filter", r.PostJoinFilter())
+		}
+		return "join", attrs
+	case *CrossRel:
+		return "cross", attrs
+	case *HashJoinRel:
+		add("type", hashMergeJoinTypeName(r.Type()))
+		add("left_keys", formatFieldRefs(r.LeftKeys()))
+		add("right_keys", formatFieldRefs(r.RightKeys()))
+		if r.PostJoinFilter() != nil {
+			add("post_join_filter", r.PostJoinFilter())
+		}
+		return "hash_join", attrs
+	case *MergeJoinRel:
+		add("type", hashMergeJoinTypeName(r.Type()))
+		add("left_keys", formatFieldRefs(r.LeftKeys()))
+		add("right_keys", formatFieldRefs(r.RightKeys()))
+		if r.PostJoinFilter() != nil {
+			add("post_join_filter", r.PostJoinFilter())
+		}
+		return "merge_join", attrs
+	case *NestedLoopJoinRel:
+		add("type", strings.TrimPrefix(r.Type().String(), "JOIN_TYPE_"))
+		add("expr", r.Expr())
+		return "nested_loop_join", attrs
+	case *SetRel:
+		add("op", strings.TrimPrefix(r.Op().String(), "SET_OP_"))
+		return "set", attrs
+	case *NamedTableWriteRel:
+		add("table", "["+strings.Join(r.Names(), ", ")+"]")
+		add("op", strings.TrimPrefix(r.Op().String(), "WRITE_OP_"))
+		add("output", strings.TrimPrefix(r.OutputMode().String(), "OUTPUT_MODE_"))
+		return "write", attrs
+	case *DdlRel:
+		add("op", strings.TrimPrefix(r.Op().String(), "DDL_OP_"))
+		add("object", strings.TrimPrefix(r.Object().String(), "DDL_OBJECT_"))
+		add("names", "["+strings.Join(r.Names(), ", ")+"]")
+		schema := r.TableSchema()
+		add("schema", &schema.Struct)
+		return "ddl", attrs
+	case *ExchangeRel:
+		add("partitions", r.PartitionCount())
+		switch s := r.Scheme().(type) {
+		case *ExchangeScatterByFields:
+			add("scatter_by_fields", formatMapping(s.Fields))
+		case *ExchangeSingleTarget:
+			add("single_target", s.Expr)
+		case *ExchangeMultiTarget:
+			add("multi_target", s.Expr)
+		case *ExchangeRoundRobin:
+			add("round_robin_exact", s.Exact)
+		case *ExchangeBroadcast:
+			add("broadcast", true)
+		}
+		return "exchange", attrs
+	case *ExpandRel:
+		add("fields", len(r.Fields()))
+		add("duplicates", r.NumDuplicates())
+		return "expand", attrs
+	case *ReferenceRel:
+		add("ordinal", r.SubtreeOrdinal())
+		return "reference", attrs
+	case *ExtensionSingleRel:
+		add("detail", r.Detail().GetTypeUrl())
+		return "extension_single", attrs
+	case *ExtensionLeafRel:
+		add("detail", r.Detail().GetTypeUrl())
+		return "extension_leaf", attrs
+	case *ExtensionMultiRel:
+		add("detail", r.Detail().GetTypeUrl())
+		return "extension_multi", attrs
+	}
+
+	return relName(rel), attrs
+}
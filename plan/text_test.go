@@ -0,0 +1,70 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package plan_test
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/substrait-io/substrait-go/extensions"
+	"github.com/substrait-io/substrait-go/plan"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files in testdata")
+
+func checkGolden(t *testing.T, name, actual string) {
+	path := filepath.Join("testdata", name)
+	if *updateGolden {
+		require.NoError(t, os.WriteFile(path, []byte(actual), 0o644))
+	}
+
+	expected, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), actual)
+}
+
+func TestToTextAggregate(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	aggCount, err := b.AggregateFn(extensions.SubstraitDefaultURIPrefix+"functions_aggregate_generic.yaml",
+		"count", nil)
+	require.NoError(t, err)
+	scan := b.NamedScan([]string{"test"}, baseSchema)
+	root, err := b.AggregateColumns(scan, []plan.AggRelMeasure{b.Measure(aggCount, nil)}, 0)
+	require.NoError(t, err)
+
+	p, err := b.Plan(root, []string{"val", "cnt"})
+	require.NoError(t, err)
+
+	text, err := plan.ToText(p)
+	require.NoError(t, err)
+	checkGolden(t, "aggregate.golden", text)
+}
+
+func TestToTextJoin(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	left := b.NamedScan([]string{"test"}, baseSchema)
+	right := b.NamedScan([]string{"test2"}, baseSchema2)
+
+	cond, err := b.JoinedRecordFieldRef(left, right, 3)
+	require.NoError(t, err)
+
+	join, err := b.JoinAndFilter(left, right, cond, cond, plan.JoinTypeInner)
+	require.NoError(t, err)
+
+	p, err := b.Plan(join, []string{"a", "b", "c", "d"})
+	require.NoError(t, err)
+
+	text, err := plan.ToText(p)
+	require.NoError(t, err)
+	checkGolden(t, "join.golden", text)
+
+	// the text doesn't depend on the anchors of the functions
+	text, err = plan.ToText(buildArithmeticPlan(t))
+	require.NoError(t, err)
+	assert.Contains(t, text, "  uri "+arithmeticURI+"\n    function abs:fp32\n")
+	assert.NotContains(t, text, "anchor")
+}
@@ -0,0 +1,396 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package plan
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/substrait-io/substrait-go/expr"
+	"github.com/substrait-io/substrait-go/types"
+)
+
+// Validate walks each of the relation trees in the plan and checks
+// invariants which are not necessarily enforced when a plan is built
+// by hand or deserialized, including that:
+//
+//   - every root field reference is within the schema of its input
+//   - every function reference, including the comparison functions of
+//     sort fields, resolves in the extension collection
+//   - every user defined type of a read schema, literal or cast
+//     resolves in the extension collection
+//   - every output mapping (emit) index is within range
+//   - filter and join conditions yield a boolean
+//   - the number of root names matches the output of the root relation
+//
+// Relations used as subqueries within expressions are validated too.
+// Rather than stopping at the first problem, all problems are collected
+// and returned together as a single error (see errors.Join). Each one
+// is a *RelError, wrapping substraitgo.ErrInvalidRel, whose message is
+// prefixed with the path to the relation it was found in, such as
+// "relations[0]:ProjectRel/input[0]:FilterRel".
+// Validate returns nil if no problems were found.
+func (p *Plan) Validate() error {
+	v := validator{reg: &p.reg}
+	for i, r := range p.relations {
+		path := fmt.Sprintf("relations[%d]", i)
+		if !r.IsRoot() {
+			v.validateRel(path, r.rel)
+			continue
+		}
+
+		if !v.validateRel(path, r.root.input) {
+			continue
+		}
+
+		expected := types.CountNames(r.root.input.Remap(r.root.input.RecordType()).Types)
+		if len(r.root.names) != expected {
+			v.addErr(path, "root has %d names, expected %d", len(r.root.names), expected)
+		}
+	}
+
+	return errors.Join(v.errs...)
+}
+
+type validator struct {
+	reg  *expr.ExtensionRegistry
+	errs []error
+}
+
+func (v *validator) addErr(path, format string, args ...any) {
+	v.errs = append(v.errs, &RelError{
+		Kind: pathKind(path),
+		Path: path,
+		Err:  fmt.Errorf(format, args...),
+	})
+}
+
+// pathKind returns the name of the relation type at the end of a path
+// such as "relations[0]:ProjectRel/input[0]:FilterRel", or an empty
+// string if the path ends with a nil input.
+func pathKind(path string) string {
+	_, kind, ok := strings.Cut(path[strings.LastIndexByte(path, '/')+1:], ":")
+	if !ok {
+		return ""
+	}
+	return kind
+}
+
+func relName(rel Rel) string {
+	t := reflect.TypeOf(rel)
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Name()
+}
+
+func outputType(rel Rel) types.StructType {
+	return rel.Remap(rel.RecordType())
+}
+
+// validateRel checks the relation and all of its inputs. It returns false
+// if the relation is invalid to the point that its output type cannot be
+// determined.
+func (v *validator) validateRel(prefix string, rel Rel) bool {
+	if rel == nil {
+		v.addErr(prefix, "relation is nil")
+		return false
+	}
+
+	path := relPath(prefix, relName(rel))
+	ok := true
+	for i, in := range rel.GetInputs() {
+		if !v.validateRel(inputPath(path, i), in) {
+			ok = false
+		}
+	}
+
+	if !ok {
+		return false
+	}
+
+	if j, isJoin := rel.(*JoinRel); isJoin {
+		switch j.Type() {
+		case JoinTypeRightSemi, JoinTypeRightAnti, JoinTypeRightSingle:
+			v.addErr(path, "join type %s is not supported", j.Type())
+			return false
+		}
+	}
+
+	v.validateExprs(path, rel)
+
+	ncols := len(rel.RecordType().Types)
+	for _, idx := range rel.OutputMapping() {
+		if idx < 0 || int(idx) >= ncols {
+			v.addErr(path, "output mapping index %d out of range, relation has %d columns",
+				idx, ncols)
+			ok = false
+		}
+	}
+
+	return ok
+}
+
+func (v *validator) validateExprs(path string, rel Rel) {
+	switch r := rel.(type) {
+	case ReadRel:
+		base := r.BaseSchema().Struct
+		v.checkType(path, "base schema", &base)
+		if r.Filter() != nil {
+			v.checkCondition(path, "filter", r.Filter(), &base)
+		}
+		if r.BestEffortFilter() != nil {
+			v.checkCondition(path, "best effort filter", r.BestEffortFilter(), &base)
+		}
+		if r.Projection() != nil {
+			if _, err := r.Projection().GetType(&base); err != nil {
+				v.addErr(path, "projection: %s", err)
+			}
+		}
+	case *ProjectRel:
+		in := outputType(r.input)
+		for i, e := range r.exprs {
+			v.checkExpr(path, fmt.Sprintf("expression %d", i), e, &in)
+		}
+	case *FilterRel:
+		in := outputType(r.input)
+		v.checkCondition(path, "condition", r.cond, &in)
+	case *JoinRel:
+		in := r.JoinedRecordType()
+		v.checkCondition(path, "join condition", r.expr, &in)
+		if r.postJoinFilter != nil {
+			v.checkCondition(path, "post join filter", r.postJoinFilter, &in)
+		}
+	case *HashJoinRel:
+		v.checkJoinKeys(path, r.left, r.right, r.leftKeys, r.rightKeys)
+		if r.postJoinFilter != nil {
+			in := r.JoinedRecordType()
+			v.checkCondition(path, "post join filter", r.postJoinFilter, &in)
+		}
+	case *MergeJoinRel:
+		v.checkJoinKeys(path, r.left, r.right, r.leftKeys, r.rightKeys)
+		if r.postJoinFilter != nil {
+			in := r.JoinedRecordType()
+			v.checkCondition(path, "post join filter", r.postJoinFilter, &in)
+		}
+	case *NestedLoopJoinRel:
+		if r.expr != nil {
+			in := r.JoinedRecordType()
+			v.checkCondition(path, "join expression", r.expr, &in)
+		}
+	case *AggregateRel:
+		in := outputType(r.input)
+		for i, g := range r.groups {
+			for j, e := range g {
+				v.checkExpr(path, fmt.Sprintf("grouping %d expression %d", i, j), e, &in)
+			}
+		}
+		for i, m := range r.measures {
+			what := fmt.Sprintf("measure %d", i)
+			v.checkAggregate(path, what, m.measure, &in)
+			if m.filter != nil {
+				v.checkCondition(path, what+" filter", m.filter, &in)
+			}
+		}
+	case *SortRel:
+		in := outputType(r.input)
+		for i, s := range r.sorts {
+			what := fmt.Sprintf("sort %d", i)
+			v.checkExpr(path, what, s.Expr, &in)
+			v.checkSortKind(path, what, s.Kind)
+		}
+	case *ExchangeRel:
+		in := outputType(r.input)
+		switch s := r.scheme.(type) {
+		case *ExchangeScatterByFields:
+			for _, f := range s.Fields {
+				if f < 0 || int(f) >= len(in.Types) {
+					v.addErr(path, "scatter field index %d out of range, input has %d fields",
+						f, len(in.Types))
+				}
+			}
+		case *ExchangeSingleTarget:
+			v.checkExpr(path, "target expression", s.Expr, &in)
+		case *ExchangeMultiTarget:
+			v.checkExpr(path, "target expression", s.Expr, &in)
+		}
+	case *ExpandRel:
+		in := outputType(r.input)
+		for i, f := range r.fields {
+			switch f := f.(type) {
+			case *SwitchingField:
+				for j, d := range f.Duplicates {
+					v.checkExpr(path, fmt.Sprintf("field %d duplicate %d", i, j), d, &in)
+				}
+			case *ConsistentField:
+				v.checkExpr(path, fmt.Sprintf("field %d", i), f.Expr, &in)
+			}
+		}
+	case *SetRel:
+		if len(r.inputs) == 0 {
+			break
+		}
+		ncols := len(outputType(r.inputs[0]).Types)
+		for i, in := range r.inputs[1:] {
+			if n := len(outputType(in).Types); n != ncols {
+				v.addErr(path, "set input %d has %d columns, expected %d", i+1, n, ncols)
+			}
+		}
+	}
+}
+
+func (v *validator) checkJoinKeys(path string, left, right Rel, leftKeys, rightKeys []*expr.FieldReference) {
+	l, r := outputType(left), outputType(right)
+	for i, k := range leftKeys {
+		v.checkExpr(path, fmt.Sprintf("left key %d", i), k, &l)
+	}
+	for i, k := range rightKeys {
+		v.checkExpr(path, fmt.Sprintf("right key %d", i), k, &r)
+	}
+}
+
+func (v *validator) checkCondition(path, what string, e expr.Expression, schema *types.StructType) {
+	if e == nil {
+		v.addErr(path, "%s is nil", what)
+		return
+	}
+
+	if t := e.GetType(); !isBoolean(t) {
+		v.addErr(path, "%s must yield boolean, not %s", what, typeString(t))
+	}
+	v.checkExpr(path, what, e, schema)
+}
+
+func (v *validator) checkAggregate(path, what string, fn *expr.AggregateFunction, schema *types.StructType) {
+	if fn == nil {
+		v.addErr(path, "%s is nil", what)
+		return
+	}
+
+	if _, ok := v.reg.LookupAggregateFunction(fn.FuncRef()); !ok {
+		v.addErr(path, "%s: aggregate function %s (anchor %d) not found in extension collection",
+			what, fn.Name(), fn.FuncRef())
+	}
+
+	for i := 0; i < fn.NArgs(); i++ {
+		if arg, ok := fn.Arg(i).(expr.Expression); ok {
+			v.checkExpr(path, what, arg, schema)
+		}
+	}
+
+	for i, s := range fn.Sorts {
+		if s.Expr == nil {
+			v.addErr(path, "%s sort %d is nil", what, i)
+			continue
+		}
+		v.checkExpr(path, fmt.Sprintf("%s sort %d", what, i), s.Expr, schema)
+		v.checkSortKind(path, fmt.Sprintf("%s sort %d", what, i), s.Kind)
+	}
+}
+
+// checkSortKind checks that the custom comparison function of a sort
+// field, if it has one in place of a direction, resolves in the
+// extension collection.
+func (v *validator) checkSortKind(path, what string, kind types.SortKind) {
+	ref, ok := kind.(types.FunctionRef)
+	if !ok {
+		return
+	}
+	if _, ok := v.reg.LookupScalarFunction(uint32(ref)); !ok {
+		v.addErr(path, "%s: comparison function (anchor %d) not found in extension collection", what, ref)
+	}
+}
+
+// checkType checks that each user defined type within t, including those
+// nested within other types and their parameters, resolves in the
+// extension collection.
+func (v *validator) checkType(path, what string, t types.Type) {
+	switch t := t.(type) {
+	case *types.StructType:
+		for _, f := range t.Types {
+			v.checkType(path, what, f)
+		}
+	case *types.ListType:
+		v.checkType(path, what, t.Type)
+	case *types.MapType:
+		v.checkType(path, what, t.Key)
+		v.checkType(path, what, t.Value)
+	case *types.UserDefinedType:
+		if _, ok := v.reg.LookupType(t.TypeReference); !ok {
+			v.addErr(path, "%s: user defined type (anchor %d) not found in extension collection",
+				what, t.TypeReference)
+		}
+		for _, p := range t.TypeParameters {
+			if dt, ok := p.(*types.DataTypeParameter); ok {
+				v.checkType(path, what, dt.Type)
+			}
+		}
+	}
+}
+
+func (v *validator) checkExpr(path, what string, e expr.Expression, schema *types.StructType) {
+	var visit expr.VisitFunc
+	visit = func(e expr.Expression) expr.Expression {
+		switch e := e.(type) {
+		case *expr.FieldReference:
+			v.checkFieldRef(path, what, e, schema)
+		case *expr.ScalarFunction:
+			if _, ok := v.reg.LookupScalarFunction(e.FuncRef()); !ok {
+				v.addErr(path, "%s: scalar function %s (anchor %d) not found in extension collection",
+					what, e.Name(), e.FuncRef())
+			}
+		case *expr.WindowFunction:
+			if _, ok := v.reg.LookupWindowFunction(e.FuncRef()); !ok {
+				v.addErr(path, "%s: window function %s (anchor %d) not found in extension collection",
+					what, e.Name(), e.FuncRef())
+			}
+		case expr.Literal:
+			v.checkType(path, what, e.GetType())
+		case *expr.Cast:
+			v.checkType(path, what, e.Type)
+		case *expr.InPredicate:
+			if r, ok := e.Haystack.(Rel); ok {
+				v.validateRel(path+"/subquery", r)
+			}
+		case *expr.SetPredicate:
+			if r, ok := e.Tuples.(Rel); ok {
+				v.validateRel(path+"/subquery", r)
+			}
+		}
+		return e.Visit(visit)
+	}
+
+	visit(e)
+}
+
+func (v *validator) checkFieldRef(path, what string, ref *expr.FieldReference, schema *types.StructType) {
+	// only references to the input record can be checked here, outer
+	// references and references to other expressions are resolved
+	// elsewhere.
+	if ref.Root != expr.RootReference {
+		return
+	}
+
+	switch r := ref.Reference.(type) {
+	case *expr.StructFieldRef:
+		if r.Field < 0 || int(r.Field) >= len(schema.Types) {
+			v.addErr(path, "%s: field reference index %d out of range, input has %d fields",
+				what, r.Field, len(schema.Types))
+			return
+		}
+
+		if _, err := r.GetType(schema); err != nil {
+			v.addErr(path, "%s: invalid field reference %s: %s", what, r, err)
+		}
+	case expr.ReferenceSegment:
+		if _, err := r.GetType(schema); err != nil {
+			v.addErr(path, "%s: invalid field reference %s: %s", what, r, err)
+		}
+	case *expr.MaskExpression:
+		if _, err := r.GetType(schema); err != nil {
+			v.addErr(path, "%s: invalid field reference %s: %s", what, r, err)
+		}
+	}
+}
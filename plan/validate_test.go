@@ -0,0 +1,198 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package plan_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	substraitgo "github.com/substrait-io/substrait-go"
+	"github.com/substrait-io/substrait-go/expr"
+	"github.com/substrait-io/substrait-go/extensions"
+	"github.com/substrait-io/substrait-go/plan"
+	"github.com/substrait-io/substrait-go/proto"
+	"github.com/substrait-io/substrait-go/types"
+	"github.com/substrait-io/substrait-go/types/parser"
+)
+
+func TestValidateValidPlan(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	left := b.NamedScan([]string{"left"}, baseSchema)
+	right := b.NamedScan([]string{"right"}, baseSchema2)
+
+	ref, err := b.RootFieldRef(right, 1)
+	require.NoError(t, err)
+	filter, err := b.Filter(right, ref)
+	require.NoError(t, err)
+
+	cond, err := b.JoinedRecordFieldRef(left, filter, 3)
+	require.NoError(t, err)
+	join, err := b.Join(left, filter, cond, plan.JoinTypeInner)
+	require.NoError(t, err)
+
+	f32, err := b.RootFieldRef(join, 1)
+	require.NoError(t, err)
+	abs, err := b.ScalarFn(extensions.SubstraitDefaultURIPrefix+"functions_arithmetic.yaml", "abs", nil, f32)
+	require.NoError(t, err)
+	proj, err := b.ProjectRemap(join, []int32{0, 4}, abs)
+	require.NoError(t, err)
+
+	p, err := b.Plan(proj, []string{"a", "b"})
+	require.NoError(t, err)
+	assert.NoError(t, p.Validate())
+}
+
+func TestValidateErrors(t *testing.T) {
+	wideSchema, err := parser.ParseNamedStruct("NSTRUCT<a: string, b: fp32, c: i32>")
+	require.NoError(t, err)
+
+	b := plan.NewBuilderDefault()
+	scan := b.NamedScan([]string{"test"}, baseSchema)
+	wide := b.NamedScan([]string{"wide"}, wideSchema)
+
+	// field reference to the wider relation, used against the narrower one
+	badRef, err := b.RootFieldRef(wide, 2)
+	require.NoError(t, err)
+
+	// function whose anchor isn't registered with the builder
+	otherReg := expr.NewEmptyExtensionRegistry(&extensions.DefaultCollection)
+	customFn, err := expr.NewCustomScalarFunc(otherReg,
+		extensions.NewScalarFuncVariant(extensions.ID{URI: "urn:custom", Name: "custom"}),
+		&types.BooleanType{}, nil, badRef)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		build  func(t *testing.T) *plan.Plan
+		errors []string
+	}{
+		{"field ref out of range", func(t *testing.T) *plan.Plan {
+			proj, err := b.Project(scan, badRef)
+			require.NoError(t, err)
+			p, err := b.Plan(proj, []string{"a", "b", "c"})
+			require.NoError(t, err)
+			return p
+		}, []string{
+			"invalid relation: relations[0]:ProjectRel: expression 0: field reference index 2 out of range, input has 2 fields",
+		}},
+		{"non-boolean filter", func(t *testing.T) *plan.Plan {
+			cond, err := b.RootFieldRef(wide, 0)
+			require.NoError(t, err)
+			// the builder would reject this, so construct a valid filter
+			// and then rewrite the condition
+			filter, err := b.Filter(scan, expr.NewPrimitiveLiteral(true, false))
+			require.NoError(t, err)
+			rel, err := filter.CopyWithExpressionRewrite(func(expr.Expression) (expr.Expression, error) {
+				return cond, nil
+			}, scan)
+			require.NoError(t, err)
+			p, err := b.Plan(rel, []string{"a", "b"})
+			require.NoError(t, err)
+			return p
+		}, []string{
+			"invalid relation: relations[0]:FilterRel: condition must yield boolean, not string",
+		}},
+		{"unknown function and nested errors", func(t *testing.T) *plan.Plan {
+			filter, err := b.Filter(scan, customFn)
+			require.NoError(t, err)
+			proj, err := b.Project(filter, badRef)
+			require.NoError(t, err)
+			p, err := b.Plan(proj, []string{"a", "b", "c"})
+			require.NoError(t, err)
+			return p
+		}, []string{
+			"invalid relation: relations[0]:ProjectRel/input[0]:FilterRel: condition: scalar function custom (anchor 1) not found in extension collection",
+			"invalid relation: relations[0]:ProjectRel/input[0]:FilterRel: condition: field reference index 2 out of range, input has 2 fields",
+			"invalid relation: relations[0]:ProjectRel: expression 0: field reference index 2 out of range, input has 2 fields",
+		}},
+		{"emit out of range", func(t *testing.T) *plan.Plan {
+			fetch, err := b.Fetch(scan, 0, 10)
+			require.NoError(t, err)
+			p, err := b.Plan(fetch, []string{"a", "b"})
+			require.NoError(t, err)
+
+			protoPlan, err := p.ToProto()
+			require.NoError(t, err)
+			protoPlan.Relations[0].GetRoot().Input.GetFetch().Common.EmitKind = &proto.RelCommon_Emit_{
+				Emit: &proto.RelCommon_Emit{OutputMapping: []int32{1, 5}},
+			}
+			protoPlan.Relations[0].GetRoot().Names = []string{"a", "b"}
+
+			p, err = plan.FromProto(protoPlan, &extensions.DefaultCollection)
+			require.NoError(t, err)
+			return p
+		}, []string{
+			"invalid relation: relations[0]:FetchRel: output mapping index 5 out of range, relation has 2 columns",
+		}},
+		{"root names for only the top level fields", func(t *testing.T) *plan.Plan {
+			nested, err := parser.ParseNamedStruct("NSTRUCT<a: string, b: STRUCT<x: i32, y: i32>>")
+			require.NoError(t, err)
+			p, err := b.Plan(b.NamedScan([]string{"nested"}, nested), []string{"a", "b", "x", "y"})
+			require.NoError(t, err)
+
+			protoPlan, err := p.ToProto()
+			require.NoError(t, err)
+			protoPlan.Relations[0].GetRoot().Names = []string{"a", "b"}
+
+			p, err = plan.FromProto(protoPlan, &extensions.DefaultCollection)
+			require.NoError(t, err)
+			return p
+		}, []string{
+			"invalid relation: relations[0]: root has 2 names, expected 4",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.build(t).Validate()
+			require.Error(t, err)
+			assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+			assert.Equal(t, tt.errors, strings.Split(err.Error(), "\n"))
+		})
+	}
+}
+
+func TestRelErrorPath(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	left := b.NamedScan([]string{"left"}, baseSchema)
+	right := b.NamedScan([]string{"right"}, baseSchema2)
+	join, err := b.Join(left, right, expr.NewPrimitiveLiteral(true, false), plan.JoinTypeInner)
+	require.NoError(t, err)
+	ref, err := b.RootFieldRef(join, 0)
+	require.NoError(t, err)
+	proj, err := b.Project(join, ref)
+	require.NoError(t, err)
+	p, err := b.Plan(proj, []string{"a", "b", "x", "y", "c"})
+	require.NoError(t, err)
+
+	protoPlan, err := p.ToProto()
+	require.NoError(t, err)
+	read := protoPlan.Relations[0].GetRoot().Input.GetProject().Input.GetJoin().Left.GetRead()
+	read.Common.EmitKind = &proto.RelCommon_Emit_{
+		Emit: &proto.RelCommon_Emit{OutputMapping: []int32{0, 7}},
+	}
+
+	_, err = plan.FromProto(protoPlan, &extensions.DefaultCollection)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidRel)
+	var relErr *plan.RelError
+	require.True(t, errors.As(err, &relErr))
+	assert.Equal(t, "NamedTableReadRel", relErr.Kind)
+	assert.Equal(t, "relations[0]:ProjectRel/input[0]:JoinRel/input[0]:NamedTableReadRel", relErr.Path)
+	assert.EqualError(t, relErr, "invalid relation: relations[0]:ProjectRel/input[0]:JoinRel/input[0]:NamedTableReadRel: "+
+		"output mapping index 7 out of range, relation has 2 columns")
+
+	// Validate reports the path in the same form
+	filter, err := b.Filter(left, expr.NewPrimitiveLiteral(true, false))
+	require.NoError(t, err)
+	bad, err := b.Project(filter, expr.MustExpr(b.RootFieldRef(join, 3)))
+	require.NoError(t, err)
+	p, err = b.Plan(bad, []string{"a", "b", "c"})
+	require.NoError(t, err)
+	err = p.Validate()
+	require.True(t, errors.As(err, &relErr))
+	assert.Equal(t, "ProjectRel", relErr.Kind)
+	assert.Equal(t, "relations[0]:ProjectRel", relErr.Path)
+}
@@ -0,0 +1,114 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package plan
+
+import (
+	"fmt"
+
+	substraitgo "github.com/substrait-io/substrait-go"
+	"golang.org/x/exp/slices"
+)
+
+// Walk performs a pre-order traversal of the relation tree rooted at
+// root, calling visit for each relation before any of its inputs. If
+// visit returns false, the inputs of that relation are not visited but
+// the traversal continues with its siblings. Relations which are only
+// referenced from within expressions, such as subqueries, are not
+// visited. A relation which is one of its own inputs, directly or
+// indirectly, is only visited once on each path from the root.
+func Walk(root Rel, visit func(Rel) bool) {
+	walk(root, visit, map[Rel]struct{}{})
+}
+
+// walk implements Walk, skipping any relation which is already on the
+// path from the root so that a tree which has been made to contain
+// itself can't cause an endless traversal.
+func walk(root Rel, visit func(Rel) bool, path map[Rel]struct{}) {
+	if root == nil {
+		return
+	}
+	if _, ok := path[root]; ok || !visit(root) {
+		return
+	}
+
+	path[root] = struct{}{}
+	for _, in := range root.GetInputs() {
+		walk(in, visit, path)
+	}
+	delete(path, root)
+}
+
+// Transform rebuilds the relation tree rooted at root from the bottom
+// up. The inputs of each relation are transformed first, if any of them
+// were replaced then the relation is copied with the new inputs (see
+// Rel.Copy), and then fn is called with the resulting relation. The
+// relation returned by fn takes its place in the tree, returning the
+// relation unchanged leaves it as is.
+//
+// Transform does not adjust the output mapping (emit) of any relation,
+// so a replacement must preserve the output type of the relation it
+// replaces, including the remapping of its columns, or the expressions
+// of the relations above it may no longer be valid. The first error
+// returned by fn or encountered while copying is returned, and an error
+// wrapping substraitgo.ErrInvalidRel is returned if a relation is one of
+// its own inputs, directly or indirectly.
+func Transform(root Rel, fn func(Rel) (Rel, error)) (Rel, error) {
+	return transform(root, fn, map[Rel]struct{}{})
+}
+
+func transform(root Rel, fn func(Rel) (Rel, error), path map[Rel]struct{}) (Rel, error) {
+	if _, ok := path[root]; ok {
+		return nil, fmt.Errorf("%w: relation tree contains a cycle through %T",
+			substraitgo.ErrInvalidRel, root)
+	}
+
+	inputs := root.GetInputs()
+	if len(inputs) > 0 {
+		path[root] = struct{}{}
+		defer delete(path, root)
+
+		newInputs := make([]Rel, len(inputs))
+		changed := false
+		for i, in := range inputs {
+			var err error
+			if newInputs[i], err = transform(in, fn, path); err != nil {
+				return nil, err
+			}
+			changed = changed || newInputs[i] != in
+		}
+
+		if changed {
+			var err error
+			if root, err = root.Copy(newInputs...); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return fn(root)
+}
+
+// rewritePlan returns a copy of the plan with the tree of each of its
+// relations, or the input of each root, replaced by the result of
+// calling fn with it. The plan itself is unchanged.
+func rewritePlan(p *Plan, fn func(Rel) (Rel, error)) (*Plan, error) {
+	out := *p
+	out.relations = slices.Clone(p.relations)
+	for i, r := range out.relations {
+		if r.IsRoot() {
+			input, err := fn(r.root.input)
+			if err != nil {
+				return nil, err
+			}
+			out.relations[i].root = &Root{input: input, names: r.root.names}
+			continue
+		}
+
+		rel, err := fn(r.rel)
+		if err != nil {
+			return nil, err
+		}
+		out.relations[i].rel = rel
+	}
+	return &out, nil
+}
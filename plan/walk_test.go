@@ -0,0 +1,103 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package plan_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/substrait-io/substrait-go/plan"
+)
+
+func buildJoinOverFilter(t *testing.T, b plan.Builder) plan.Rel {
+	left := b.NamedScan([]string{"left"}, baseSchema)
+	right := b.NamedScan([]string{"right"}, baseSchema2)
+
+	ref, err := b.RootFieldRef(right, 1)
+	require.NoError(t, err)
+	filter, err := b.Filter(right, ref)
+	require.NoError(t, err)
+
+	cond, err := b.JoinedRecordFieldRef(left, filter, 3)
+	require.NoError(t, err)
+	join, err := b.JoinRemap(left, filter, cond, plan.JoinTypeInner, []int32{0, 2})
+	require.NoError(t, err)
+	return join
+}
+
+func TestWalk(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	join := buildJoinOverFilter(t, b)
+
+	var visited []string
+	plan.Walk(join, func(r plan.Rel) bool {
+		switch r := r.(type) {
+		case *plan.JoinRel:
+			visited = append(visited, "join")
+		case *plan.FilterRel:
+			visited = append(visited, "filter")
+		case *plan.NamedTableReadRel:
+			visited = append(visited, "read:"+r.Names()[0])
+		}
+		return true
+	})
+	assert.Equal(t, []string{"join", "read:left", "filter", "read:right"}, visited)
+
+	// stop descending below the filter
+	count := 0
+	plan.Walk(join, func(r plan.Rel) bool {
+		count++
+		_, isFilter := r.(*plan.FilterRel)
+		return !isFilter
+	})
+	assert.Equal(t, 3, count)
+}
+
+func TestTransform(t *testing.T) {
+	b := plan.NewBuilderDefault()
+	join := buildJoinOverFilter(t, b)
+
+	out, err := plan.Transform(join, func(r plan.Rel) (plan.Rel, error) {
+		if n, ok := r.(*plan.NamedTableReadRel); ok && n.Names()[0] == "right" {
+			return b.NamedScanRemap([]string{"renamed"}, n.BaseSchema(), n.OutputMapping())
+		}
+		return r, nil
+	})
+	require.NoError(t, err)
+
+	var names []string
+	plan.Walk(out, func(r plan.Rel) bool {
+		if n, ok := r.(*plan.NamedTableReadRel); ok {
+			names = append(names, n.Names()[0])
+		}
+		return true
+	})
+	assert.Equal(t, []string{"left", "renamed"}, names)
+	assert.Equal(t, join.OutputMapping(), out.OutputMapping())
+	expected, actual := join.RecordType(), out.RecordType()
+	assert.Truef(t, expected.Equals(&actual), "expected: %s\ngot: %s", &expected, &actual)
+
+	// the original tree is left untouched
+	plan.Walk(join, func(r plan.Rel) bool {
+		if n, ok := r.(*plan.NamedTableReadRel); ok {
+			assert.NotEqual(t, "renamed", n.Names()[0])
+		}
+		return true
+	})
+
+	// returning the relation as is keeps the same tree
+	same, err := plan.Transform(join, func(r plan.Rel) (plan.Rel, error) { return r, nil })
+	require.NoError(t, err)
+	assert.Same(t, join, same)
+
+	errBoom := errors.New("boom")
+	_, err = plan.Transform(join, func(r plan.Rel) (plan.Rel, error) {
+		if _, ok := r.(*plan.FilterRel); ok {
+			return nil, errBoom
+		}
+		return r, nil
+	})
+	assert.ErrorIs(t, err, errBoom)
+}
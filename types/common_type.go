@@ -0,0 +1,205 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package types
+
+import (
+	"fmt"
+
+	substraitgo "github.com/substrait-io/substrait-go"
+)
+
+const maxDecimalPrecision = 38
+
+// GetCommonType returns the least common type that both a and b can be
+// represented as, such as for the inputs of a set operation or the
+// branches of an if/then expression.
+//
+// Types with the same base type unify to that type, taking the widest
+// nullability (nullable if either input is nullable). Integers widen
+// to the larger integer width and floating point types to the larger
+// width, decimals widen to fit both the integral digits and the scale
+// of either input, varchars widen to the longer length and precision
+// timestamps to the finer precision. Lists, maps and structs are
+// unified element-wise. Any other combination of types, including
+// differing type variations, is incompatible and results in an error
+// wrapping substraitgo.ErrInvalidArg.
+func GetCommonType(a, b Type) (Type, error) {
+	if a == nil || b == nil {
+		return nil, fmt.Errorf("%w: cannot determine common type of nil type",
+			substraitgo.ErrInvalidArg)
+	}
+
+	if a.GetTypeVariationReference() != b.GetTypeVariationReference() {
+		return nil, fmt.Errorf("%w: no common type for %s and %s, type variations differ",
+			substraitgo.ErrInvalidArg, a, b)
+	}
+
+	out, err := commonBaseType(a, b)
+	if err != nil {
+		return nil, err
+	}
+
+	nullability := NullabilityRequired
+	if a.GetNullability() == NullabilityNullable || b.GetNullability() == NullabilityNullable {
+		nullability = NullabilityNullable
+	}
+	return out.WithNullability(nullability), nil
+}
+
+// GetCommonTypeN returns the least common type of all of the provided
+// types by repeatedly applying GetCommonType. At least one type must
+// be provided.
+func GetCommonTypeN(typs ...Type) (Type, error) {
+	if len(typs) == 0 {
+		return nil, fmt.Errorf("%w: cannot determine common type of zero types",
+			substraitgo.ErrInvalidArg)
+	}
+
+	out := typs[0]
+	if out == nil {
+		return nil, fmt.Errorf("%w: cannot determine common type of nil type",
+			substraitgo.ErrInvalidArg)
+	}
+
+	for _, t := range typs[1:] {
+		var err error
+		if out, err = GetCommonType(out, t); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func integerWidth(t Type) int {
+	switch t.(type) {
+	case *Int8Type:
+		return 8
+	case *Int16Type:
+		return 16
+	case *Int32Type:
+		return 32
+	case *Int64Type:
+		return 64
+	}
+	return 0
+}
+
+func floatWidth(t Type) int {
+	switch t.(type) {
+	case *Float32Type:
+		return 32
+	case *Float64Type:
+		return 64
+	}
+	return 0
+}
+
+// commonBaseType determines the common type of a and b without regard
+// to their top level nullability, which is handled by GetCommonType.
+func commonBaseType(a, b Type) (Type, error) {
+	noCommon := func() error {
+		return fmt.Errorf("%w: no common type for %s and %s",
+			substraitgo.ErrInvalidArg, a, b)
+	}
+
+	if wa, wb := integerWidth(a), integerWidth(b); wa != 0 && wb != 0 {
+		if wa >= wb {
+			return a, nil
+		}
+		return b, nil
+	}
+
+	if wa, wb := floatWidth(a), floatWidth(b); wa != 0 && wb != 0 {
+		if wa >= wb {
+			return a, nil
+		}
+		return b, nil
+	}
+
+	switch a := a.(type) {
+	case *DecimalType:
+		b, ok := b.(*DecimalType)
+		if !ok {
+			return nil, noCommon()
+		}
+
+		scale := max(a.Scale, b.Scale)
+		integral := max(a.Precision-a.Scale, b.Precision-b.Scale)
+		if integral+scale > maxDecimalPrecision {
+			return nil, fmt.Errorf("%w: no common type for %s and %s, requires precision %d which exceeds %d",
+				substraitgo.ErrInvalidArg, a, b, integral+scale, maxDecimalPrecision)
+		}
+		return &DecimalType{Precision: integral + scale, Scale: scale,
+			TypeVariationRef: a.TypeVariationRef}, nil
+	case *VarCharType:
+		b, ok := b.(*VarCharType)
+		if !ok {
+			return nil, noCommon()
+		}
+		return &VarCharType{Length: max(a.Length, b.Length),
+			TypeVariationRef: a.TypeVariationRef}, nil
+	case *PrecisionTimestampType:
+		b, ok := b.(*PrecisionTimestampType)
+		if !ok {
+			return nil, noCommon()
+		}
+		out := NewPrecisionTimestampType(max(a.Precision, b.Precision))
+		out.TypeVariationRef = a.TypeVariationRef
+		return out, nil
+	case *PrecisionTimestampTzType:
+		b, ok := b.(*PrecisionTimestampTzType)
+		if !ok {
+			return nil, noCommon()
+		}
+		out := NewPrecisionTimestampTzType(max(a.Precision, b.Precision))
+		out.TypeVariationRef = a.TypeVariationRef
+		return out, nil
+	case *ListType:
+		b, ok := b.(*ListType)
+		if !ok {
+			return nil, noCommon()
+		}
+
+		elem, err := GetCommonType(a.Type, b.Type)
+		if err != nil {
+			return nil, fmt.Errorf("list element: %w", err)
+		}
+		return &ListType{Type: elem, TypeVariationRef: a.TypeVariationRef}, nil
+	case *MapType:
+		b, ok := b.(*MapType)
+		if !ok {
+			return nil, noCommon()
+		}
+
+		key, err := GetCommonType(a.Key, b.Key)
+		if err != nil {
+			return nil, fmt.Errorf("map key: %w", err)
+		}
+
+		value, err := GetCommonType(a.Value, b.Value)
+		if err != nil {
+			return nil, fmt.Errorf("map value: %w", err)
+		}
+		return &MapType{Key: key, Value: value,
+			TypeVariationRef: a.TypeVariationRef}, nil
+	case *StructType:
+		b, ok := b.(*StructType)
+		if !ok || len(a.Types) != len(b.Types) {
+			return nil, noCommon()
+		}
+
+		fields := make([]Type, len(a.Types))
+		for i := range a.Types {
+			var err error
+			if fields[i], err = GetCommonType(a.Types[i], b.Types[i]); err != nil {
+				return nil, fmt.Errorf("struct field %d: %w", i, err)
+			}
+		}
+		return &StructType{Types: fields, TypeVariationRef: a.TypeVariationRef}, nil
+	}
+
+	if !EqualsIgnoreNullability(a, b) {
+		return nil, noCommon()
+	}
+	return a, nil
+}
@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package types_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	substraitgo "github.com/substrait-io/substrait-go"
+	. "github.com/substrait-io/substrait-go/types"
+	"github.com/substrait-io/substrait-go/types/parser"
+)
+
+func TestGetCommonType(t *testing.T) {
+	tests := []struct {
+		a, b, expected string
+	}{
+		{"i32", "i64", "i64"},
+		{"i64", "i8?", "i64?"},
+		{"i16", "i16", "i16"},
+		{"fp32?", "fp64", "fp64?"},
+		{"string", "string?", "string?"},
+		{"boolean?", "boolean?", "boolean?"},
+		{"char<5>", "char<5>?", "char<5>?"},
+		{"varchar<5>", "varchar<10>", "varchar<10>"},
+		{"decimal<10,2>", "decimal<12,5>", "decimal<13,5>"},
+		{"decimal<38,0>", "decimal<38,0>?", "decimal<38,0>?"},
+		{"precision_timestamp<3>", "precision_timestamp<6>", "precision_timestamp<6>"},
+		{"list<i32>", "list?<i64?>", "list?<i64?>"},
+		{"map<string, i8>", "map<string?, i32>", "map<string?, i32>"},
+		{"struct<i32, varchar<3>>", "struct<i64?, varchar<8>>?", "struct?<i64?, varchar<8>>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+","+tt.b, func(t *testing.T) {
+			a, err := parser.ParseType(tt.a)
+			require.NoError(t, err)
+			b, err := parser.ParseType(tt.b)
+			require.NoError(t, err)
+			expected, err := parser.ParseType(tt.expected)
+			require.NoError(t, err)
+
+			out, err := GetCommonType(a, b)
+			require.NoError(t, err)
+			assert.Truef(t, expected.Equals(out), "expected: %s\ngot: %s", expected, out)
+
+			// common type resolution is symmetric
+			out, err = GetCommonType(b, a)
+			require.NoError(t, err)
+			assert.Truef(t, expected.Equals(out), "expected: %s\ngot: %s", expected, out)
+		})
+	}
+}
+
+func TestGetCommonTypeErrors(t *testing.T) {
+	tests := []struct {
+		a, b, err string
+	}{
+		{"string", "i32", "no common type for string and i32"},
+		{"i32", "fp64", "no common type for i32 and fp64"},
+		{"char<5>", "char<6>", "no common type for char<5> and char<6>"},
+		{"decimal<38,0>", "decimal<38,10>", "requires precision 48 which exceeds 38"},
+		{"list<i32>", "list<string>", "list element: invalid argument: no common type for i32 and string"},
+		{"map<string, i32>", "map<i32, i32>", "map key: invalid argument"},
+		{"struct<i32>", "struct<i32, i32>", "no common type for struct<i32> and struct<i32, i32>"},
+		{"struct<i32, date>", "struct<i32, time>", "struct field 1: invalid argument"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+","+tt.b, func(t *testing.T) {
+			a, err := parser.ParseType(tt.a)
+			require.NoError(t, err)
+			b, err := parser.ParseType(tt.b)
+			require.NoError(t, err)
+
+			_, err = GetCommonType(a, b)
+			assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+
+	_, err := GetCommonType(&Int32Type{}, nil)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+
+	_, err = GetCommonType(&Int32Type{TypeVariationRef: 1}, &Int32Type{})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "type variations differ")
+}
+
+func TestGetCommonTypeN(t *testing.T) {
+	out, err := GetCommonTypeN(
+		&Int8Type{Nullability: NullabilityRequired},
+		&Int32Type{Nullability: NullabilityNullable},
+		&Int16Type{Nullability: NullabilityRequired})
+	require.NoError(t, err)
+	assert.Equal(t, "i32?", out.String())
+
+	out, err = GetCommonTypeN(&StringType{Nullability: NullabilityRequired})
+	require.NoError(t, err)
+	assert.Equal(t, "string", out.String())
+
+	_, err = GetCommonTypeN()
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+
+	_, err = GetCommonTypeN(&Int8Type{}, &StringType{}, &Int64Type{})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidArg)
+	assert.ErrorContains(t, err, "no common type for i8 and string")
+}
@@ -0,0 +1,44 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package types
+
+// EqualsIgnoreNullability reports whether a and b are the same type
+// when the nullability of each is disregarded. The comparison recurses
+// into the element type of lists, the key and value types of maps and
+// the field types of structs, so that for example list<i32> and
+// list?<i32?> are considered equal. Every other property of the
+// types, such as parameters and type variation references, must
+// match exactly.
+func EqualsIgnoreNullability(a, b Type) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	if a.GetTypeVariationReference() != b.GetTypeVariationReference() {
+		return false
+	}
+
+	switch a := a.(type) {
+	case *ListType:
+		b, ok := b.(*ListType)
+		return ok && EqualsIgnoreNullability(a.Type, b.Type)
+	case *MapType:
+		b, ok := b.(*MapType)
+		return ok && EqualsIgnoreNullability(a.Key, b.Key) &&
+			EqualsIgnoreNullability(a.Value, b.Value)
+	case *StructType:
+		b, ok := b.(*StructType)
+		if !ok || len(a.Types) != len(b.Types) {
+			return false
+		}
+
+		for i := range a.Types {
+			if !EqualsIgnoreNullability(a.Types[i], b.Types[i]) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return a.WithNullability(NullabilityRequired).Equals(b.WithNullability(NullabilityRequired))
+}
@@ -0,0 +1,56 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package types_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	. "github.com/substrait-io/substrait-go/types"
+	"github.com/substrait-io/substrait-go/types/parser"
+)
+
+func TestEqualsIgnoreNullability(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected bool
+	}{
+		{"i32", "i32?", true},
+		{"i32", "i64", false},
+		{"varchar<10>?", "varchar<10>", true},
+		{"varchar<10>", "varchar<11>", false},
+		{"decimal<10,2>", "decimal?<10,2>", true},
+		{"decimal<10,2>", "decimal<10,3>", false},
+		{"precision_timestamp<3>", "precision_timestamp?<3>", true},
+		{"precision_timestamp<3>", "precision_timestamp_tz<3>", false},
+		{"list<i32>", "list<i32?>", true},
+		{"list<i32>", "list?<i32?>", true},
+		{"list<i32>", "list<i64>", false},
+		{"map<string, list<i8>>", "map?<string?, list<i8?>?>", true},
+		{"map<string, i8>", "map<string, i16>", false},
+		{"struct<i32, struct<string, list<date>>>", "struct?<i32?, struct<string?, list?<date?>>?>", true},
+		{"struct<i32, struct<string, list<date>>>", "struct<i32, struct<string, list<time>>>", false},
+		{"struct<i32, string>", "struct<i32>", false},
+		{"list<i32>", "struct<i32>", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+","+tt.b, func(t *testing.T) {
+			a, err := parser.ParseType(tt.a)
+			require.NoError(t, err)
+			b, err := parser.ParseType(tt.b)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.expected, EqualsIgnoreNullability(a, b))
+			assert.Equal(t, tt.expected, EqualsIgnoreNullability(b, a))
+			if !tt.expected {
+				assert.False(t, a.Equals(b))
+			}
+		})
+	}
+
+	assert.False(t, EqualsIgnoreNullability(&Int32Type{TypeVariationRef: 1}, &Int32Type{}))
+	assert.False(t, EqualsIgnoreNullability(&Int32Type{}, nil))
+	assert.True(t, EqualsIgnoreNullability(nil, nil))
+}
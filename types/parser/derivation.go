@@ -0,0 +1,485 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package parser
+
+import (
+	"fmt"
+
+	substraitgo "github.com/substrait-io/substrait-go"
+	"github.com/substrait-io/substrait-go/types"
+)
+
+const maxDecimalPrecision = 38
+
+// Evaluate returns the concrete type described by this type expression,
+// which may be a ReturnProgram, substituting the parameter values as
+// bound from the argument types by BindParameters.
+func (t TypeExpression) Evaluate(params map[string]any) (types.Type, error) {
+	env := make(map[string]any, len(params))
+	for k, v := range params {
+		env[k] = v
+	}
+
+	v, err := evalExpr(t.Expr, env)
+	if err != nil {
+		return nil, err
+	}
+
+	typ, ok := v.(types.Type)
+	if !ok {
+		return nil, fmt.Errorf("%w: type derivation must result in a type, not %v",
+			substraitgo.ErrInvalidExpr, v)
+	}
+	return typ, nil
+}
+
+// BindParameters matches the type expression of a function argument, such
+// as decimal<P1,S1> or list<any1>, against the actual type of the argument
+// and records the values of its parameters in params. It is an error if a
+// parameter is already bound to a different value or the structure of the
+// types differs.
+func BindParameters(param TypeExpression, actual types.Type, params map[string]any) error {
+	t, ok := param.Expr.(*Type)
+	if !ok || actual == nil {
+		return nil
+	}
+
+	mismatch := func() error {
+		return fmt.Errorf("%w: cannot bind %s to %s",
+			substraitgo.ErrInvalidType, t, actual)
+	}
+
+	switch def := t.TypeDef.(type) {
+	case *anyType:
+		name := string(def.TypeName)
+		bound, ok := params[name]
+		if !ok {
+			params[name] = actual.WithNullability(types.NullabilityRequired)
+			return nil
+		}
+
+		// a bare "any" is not required to be the same type for each use
+		if bt, isType := bound.(types.Type); name != "any" && (!isType || !types.EqualsIgnoreNullability(bt, actual)) {
+			return mismatch()
+		}
+	case *decimalType:
+		dec, ok := actual.(*types.DecimalType)
+		if !ok {
+			return mismatch()
+		}
+		if !bindInt(def.Precision, dec.Precision, params) || !bindInt(def.Scale, dec.Scale, params) {
+			return mismatch()
+		}
+	case *lengthType:
+		var v int32
+		switch a := actual.(type) {
+		case interface{ GetLength() int32 }:
+			v = a.GetLength()
+		case *types.PrecisionTimestampType:
+			v = a.GetPrecisionProtoVal()
+		case *types.PrecisionTimestampTzType:
+			v = a.GetPrecisionProtoVal()
+		default:
+			return mismatch()
+		}
+		if !bindInt(def.NumericParam, v, params) {
+			return mismatch()
+		}
+	case *listType:
+		l, ok := actual.(*types.ListType)
+		if !ok {
+			return mismatch()
+		}
+		return BindParameters(def.ElemType, l.Type, params)
+	case *mapType:
+		m, ok := actual.(*types.MapType)
+		if !ok {
+			return mismatch()
+		}
+		if err := BindParameters(def.Key, m.Key, params); err != nil {
+			return err
+		}
+		return BindParameters(def.Value, m.Value, params)
+	case *structType:
+		s, ok := actual.(*types.StructType)
+		if !ok || len(s.Types) != len(def.Fields) {
+			return mismatch()
+		}
+		for i, f := range def.Fields {
+			if err := BindParameters(f.Type, s.Types[i], params); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func bindInt(param TypeExpression, v int32, params map[string]any) bool {
+	name, ok := param.Expr.(*ParamName)
+	if !ok {
+		return true
+	}
+
+	if bound, ok := params[name.Name]; ok {
+		return bound == int64(v)
+	}
+	params[name.Name] = int64(v)
+	return true
+}
+
+// evaluator is implemented by the expressions of the type grammar. The
+// result of evaluating one is an int64, a bool or a types.Type.
+type evaluator interface {
+	eval(env map[string]any) (any, error)
+}
+
+// typeEvaluator is implemented by each Def.
+type typeEvaluator interface {
+	evalType(env map[string]any) (types.Type, error)
+}
+
+func evalExpr(e Expression, env map[string]any) (any, error) {
+	ev, ok := e.(evaluator)
+	if !ok {
+		return nil, fmt.Errorf("%w: cannot evaluate %s in type derivation",
+			substraitgo.ErrNotImplemented, e)
+	}
+	return ev.eval(env)
+}
+
+func nullability(nullable bool) types.Nullability {
+	if nullable {
+		return types.NullabilityNullable
+	}
+	return types.NullabilityRequired
+}
+
+func evalInt(e Expression, env map[string]any) (int64, error) {
+	v, err := evalExpr(e, env)
+	if err != nil {
+		return 0, err
+	}
+	i, ok := v.(int64)
+	if !ok {
+		return 0, fmt.Errorf("%w: expected integer in type derivation, got %v",
+			substraitgo.ErrInvalidExpr, v)
+	}
+	return i, nil
+}
+
+func evalBool(e Expression, env map[string]any) (bool, error) {
+	v, err := evalExpr(e, env)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("%w: expected boolean in type derivation, got %v",
+			substraitgo.ErrInvalidExpr, v)
+	}
+	return b, nil
+}
+
+func evalType(e Expression, env map[string]any) (types.Type, error) {
+	v, err := evalExpr(e, env)
+	if err != nil {
+		return nil, err
+	}
+	t, ok := v.(types.Type)
+	if !ok {
+		return nil, fmt.Errorf("%w: expected type in type derivation, got %v",
+			substraitgo.ErrInvalidExpr, v)
+	}
+	return t, nil
+}
+
+func (r *ReturnProgram) eval(env map[string]any) (any, error) {
+	for _, a := range r.Assignments {
+		v, err := evalExpr(a.Value, env)
+		if err != nil {
+			return nil, err
+		}
+		env[a.Name] = v
+	}
+	return evalExpr(r.FinalType, env)
+}
+
+func (e *IfElse) eval(env map[string]any) (any, error) {
+	cond, err := evalBool(e.IfExpr, env)
+	if err != nil {
+		return nil, err
+	}
+	if cond {
+		return evalExpr(e.ThenExpr, env)
+	}
+	return evalExpr(e.ElseExpr, env)
+}
+
+// binaryPrecedence is the precedence of each binary operator, higher
+// binding more tightly.
+var binaryPrecedence = map[string]int{
+	"||": 1,
+	"&&": 2,
+	"==": 3, "!=": 3, "<": 3, "<=": 3, ">": 3, ">=": 3,
+	"+": 4, "-": 4,
+	"*": 5, "/": 5,
+}
+
+// binaryExpr is a single operator of a BinaryOp applied to its operands.
+type binaryExpr struct {
+	op       string
+	lhs, rhs Expression
+}
+
+func (e *binaryExpr) String() string {
+	return "(" + e.lhs.String() + " " + e.op + " " + e.rhs.String() + ")"
+}
+
+// tree combines the chain of operands according to the precedence of the
+// operators, which are left associative.
+func (b *BinaryOp) tree() Expression {
+	operands, ops := []Expression{b.Left}, []string{}
+	reduce := func() {
+		n := len(operands)
+		operands = append(operands[:n-2], &binaryExpr{op: ops[len(ops)-1], lhs: operands[n-2], rhs: operands[n-1]})
+		ops = ops[:len(ops)-1]
+	}
+
+	for _, r := range b.Rest {
+		for len(ops) > 0 && binaryPrecedence[ops[len(ops)-1]] >= binaryPrecedence[r.Op] {
+			reduce()
+		}
+		ops, operands = append(ops, r.Op), append(operands, r.Right)
+	}
+	for len(ops) > 0 {
+		reduce()
+	}
+	return operands[0]
+}
+
+func (b *BinaryOp) eval(env map[string]any) (any, error) {
+	return evalExpr(b.tree(), env)
+}
+
+func (e *binaryExpr) eval(env map[string]any) (any, error) {
+	switch e.op {
+	case "&&", "||":
+		lhs, err := evalBool(e.lhs, env)
+		if err != nil {
+			return nil, err
+		}
+		if lhs == (e.op == "||") {
+			return lhs, nil
+		}
+		return evalBool(e.rhs, env)
+	case "==", "!=":
+		lhs, err := evalExpr(e.lhs, env)
+		if err != nil {
+			return nil, err
+		}
+		rhs, err := evalExpr(e.rhs, env)
+		if err != nil {
+			return nil, err
+		}
+
+		var equal bool
+		if lt, ok := lhs.(types.Type); ok {
+			rt, ok := rhs.(types.Type)
+			equal = ok && lt.Equals(rt)
+		} else {
+			equal = lhs == rhs
+		}
+		return equal == (e.op == "=="), nil
+	}
+
+	lhs, err := evalInt(e.lhs, env)
+	if err != nil {
+		return nil, err
+	}
+	rhs, err := evalInt(e.rhs, env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.op {
+	case "+":
+		return lhs + rhs, nil
+	case "-":
+		return lhs - rhs, nil
+	case "*":
+		return lhs * rhs, nil
+	case "/":
+		if rhs == 0 {
+			return nil, fmt.Errorf("%w: division by zero in type derivation",
+				substraitgo.ErrInvalidExpr)
+		}
+		return lhs / rhs, nil
+	case "<":
+		return lhs < rhs, nil
+	case "<=":
+		return lhs <= rhs, nil
+	case ">":
+		return lhs > rhs, nil
+	default: // ">="
+		return lhs >= rhs, nil
+	}
+}
+
+func (u *UnaryOp) eval(env map[string]any) (any, error) {
+	if u.Op == "!" {
+		b, err := evalBool(u.Operand, env)
+		return !b, err
+	}
+	i, err := evalInt(u.Operand, env)
+	return -i, err
+}
+
+func (f *FunctionCall) eval(env map[string]any) (any, error) {
+	if f.Name != "min" && f.Name != "max" {
+		return nil, fmt.Errorf("%w: unknown function %s in type derivation",
+			substraitgo.ErrInvalidExpr, f.Name)
+	}
+
+	var out int64
+	for i, a := range f.Args {
+		v, err := evalInt(a, env)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 || (f.Name == "min" && v < out) || (f.Name == "max" && v > out) {
+			out = v
+		}
+	}
+	return out, nil
+}
+
+func (p *ParenExpression) eval(env map[string]any) (any, error) {
+	return evalExpr(p.Expr, env)
+}
+
+func (l *IntegerLiteral) eval(map[string]any) (any, error) { return int64(l.Value), nil }
+
+func (p *ParamName) eval(env map[string]any) (any, error) {
+	if v, ok := env[p.Name]; ok {
+		return v, nil
+	}
+
+	switch p.Name {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	return nil, fmt.Errorf("%w: unbound parameter %s in type derivation",
+		substraitgo.ErrInvalidExpr, p.Name)
+}
+
+func (t *Type) eval(env map[string]any) (any, error) {
+	ev, ok := t.TypeDef.(typeEvaluator)
+	if !ok {
+		return nil, fmt.Errorf("%w: cannot evaluate %s in type derivation",
+			substraitgo.ErrNotImplemented, t)
+	}
+	return ev.evalType(env)
+}
+
+func (t *nonParamType) evalType(map[string]any) (types.Type, error) {
+	return t.RetType()
+}
+
+func (t anyType) evalType(env map[string]any) (types.Type, error) {
+	v, ok := env[string(t.TypeName)]
+	if !ok {
+		return nil, fmt.Errorf("%w: unbound parameter %s in type derivation",
+			substraitgo.ErrInvalidExpr, t.TypeName)
+	}
+
+	typ, ok := v.(types.Type)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is not a type in type derivation",
+			substraitgo.ErrInvalidExpr, t.TypeName)
+	}
+	if t.Nullability {
+		return typ.WithNullability(types.NullabilityNullable), nil
+	}
+	return typ, nil
+}
+
+func (l *listType) evalType(env map[string]any) (types.Type, error) {
+	elem, err := evalType(l.ElemType.Expr, env)
+	if err != nil {
+		return nil, err
+	}
+	return &types.ListType{Nullability: nullability(l.Optional()), Type: elem}, nil
+}
+
+func (m *mapType) evalType(env map[string]any) (types.Type, error) {
+	key, err := evalType(m.Key.Expr, env)
+	if err != nil {
+		return nil, err
+	}
+	value, err := evalType(m.Value.Expr, env)
+	if err != nil {
+		return nil, err
+	}
+	return &types.MapType{Nullability: nullability(m.Optional()), Key: key, Value: value}, nil
+}
+
+func (t *structType) evalType(env map[string]any) (types.Type, error) {
+	fields := make([]types.Type, len(t.Fields))
+	for i, f := range t.Fields {
+		var err error
+		if fields[i], err = evalType(f.Type.Expr, env); err != nil {
+			return nil, err
+		}
+	}
+	return &types.StructType{Nullability: nullability(t.Optional()), Types: fields}, nil
+}
+
+func (d *decimalType) evalType(env map[string]any) (types.Type, error) {
+	p, err := evalInt(d.Precision.Expr, env)
+	if err != nil {
+		return nil, err
+	}
+	s, err := evalInt(d.Scale.Expr, env)
+	if err != nil {
+		return nil, err
+	}
+
+	if p < 1 || p > maxDecimalPrecision || s < 0 || s > p {
+		return nil, fmt.Errorf("%w: invalid decimal<%d,%d>, the precision must be between 1 and %d and the scale between 0 and the precision",
+			substraitgo.ErrInvalidType, p, s, maxDecimalPrecision)
+	}
+	return &types.DecimalType{Nullability: nullability(d.Optional()),
+		Precision: int32(p), Scale: int32(s)}, nil
+}
+
+func (p *lengthType) evalType(env map[string]any) (types.Type, error) {
+	v, err := evalInt(p.NumericParam.Expr, env)
+	if err != nil {
+		return nil, err
+	}
+
+	n := nullability(p.Optional())
+	switch p.TypeName {
+	case "precision_timestamp", "precision_timestamp_tz", "interval_compound":
+		prec, err := types.ProtoToTimePrecision(int32(v))
+		if err != nil {
+			return nil, err
+		}
+		switch p.TypeName {
+		case "precision_timestamp":
+			return types.NewPrecisionTimestampType(prec).WithNullability(n), nil
+		case "precision_timestamp_tz":
+			return types.NewPrecisionTimestampTzType(prec).WithNullability(n), nil
+		}
+		return types.NewIntervalCompoundType().WithPrecision(prec).WithNullability(n), nil
+	}
+
+	typ, err := types.FixedTypeNameToType(types.TypeName(p.TypeName))
+	if err != nil {
+		return nil, err
+	}
+	return typ.WithLength(int32(v)).WithNullability(n), nil
+}
@@ -0,0 +1,117 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package parser_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	substraitgo "github.com/substrait-io/substrait-go"
+	"github.com/substrait-io/substrait-go/types"
+	"github.com/substrait-io/substrait-go/types/parser"
+)
+
+func TestDerivation(t *testing.T) {
+	p, err := parser.New()
+	require.NoError(t, err)
+
+	params := map[string]any{
+		"P1": int64(10), "S1": int64(2), "P2": int64(12), "S2": int64(4),
+		"L1":   int64(5),
+		"any1": &types.StringType{Nullability: types.NullabilityRequired},
+	}
+
+	tests := []struct {
+		src, expected string
+	}{
+		{"i32", "i32"},
+		{"fp64?", "fp64?"},
+		{"DECIMAL<P1, S1>", "decimal<10,2>"},
+		{"decimal?<P1 + P2, max(S1, S2)>", "decimal?<22,4>"},
+		{"varchar<L1 * 2>", "varchar<10>"},
+		{"list<any1?>", "list<string?>"},
+		{"struct<any1, i64>", "struct<string, i64>"},
+		{"map<any1, decimal<38, S1>>", "map<string, decimal<38,2>>"},
+		{"a = P1 - S1; b = P2 - S2\nDECIMAL<min(38, max(a, b) + S2), S2>", "decimal<12,4>"},
+		{"x = P1 > P2 ? 1 : 2\ndecimal<x, 0>", "decimal<2,0>"},
+		{"x = (P1 > 5 && !(S1 == 2)) || L1 >= 5\nx ? i8 : i16", "i8"},
+		{"decimal<(P1 > 5) ? P1 : 5, -S1 + 4>", "decimal<10,2>"},
+		{"precision_timestamp<S1 * 3>", "precision_timestamp<6>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			d, err := p.ParseString(tt.src)
+			require.NoError(t, err)
+
+			out, err := d.Evaluate(params)
+			require.NoError(t, err)
+			expected, err := parser.ParseType(tt.expected)
+			require.NoError(t, err)
+			assert.Truef(t, expected.Equals(out), "expected: %s\ngot: %s", expected, out)
+		})
+	}
+}
+
+func TestDerivationErrors(t *testing.T) {
+	p, err := parser.New()
+	require.NoError(t, err)
+
+	params := map[string]any{"P": int64(10), "any1": &types.Int32Type{}}
+
+	tests := []struct {
+		src, err string
+	}{
+		{"decimal<P, S>", "unbound parameter S"},
+		{"P + 1", "type derivation must result in a type"},
+		{"decimal<P, 20>", "invalid decimal<10,20>"},
+		{"decimal<any1, 2>", "expected integer in type derivation"},
+		{"list<P>", "expected type in type derivation"},
+		{"P ? i8 : i16", "expected boolean in type derivation"},
+		{"decimal<P / 0, 0>", "division by zero"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			d, err := p.ParseString(tt.src)
+			require.NoError(t, err)
+
+			_, err = d.Evaluate(params)
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+
+	for _, src := range []string{"decimal<P, 2", "x = \ni32", "i32 i64", "decimal<P, 2> % 3", "max(1, 2", "map<i32>"} {
+		_, err := p.ParseString(src)
+		assert.Errorf(t, err, "%s", src)
+	}
+}
+
+func TestBindParameters(t *testing.T) {
+	p, err := parser.New()
+	require.NoError(t, err)
+
+	bind := func(expr string, actual types.Type, params map[string]any) error {
+		te, err := p.ParseString(expr)
+		require.NoError(t, err)
+		return parser.BindParameters(*te, actual, params)
+	}
+
+	params := make(map[string]any)
+	require.NoError(t, bind("decimal<P1,S1>", &types.DecimalType{Precision: 10, Scale: 2}, params))
+	require.NoError(t, bind("varchar<L1>", &types.VarCharType{Length: 20}, params))
+	require.NoError(t, bind("list<any1>", &types.ListType{Type: &types.Int64Type{Nullability: types.NullabilityNullable}}, params))
+	require.NoError(t, bind("any1", &types.Int64Type{}, params))
+	assert.Equal(t, int64(10), params["P1"])
+	assert.Equal(t, int64(2), params["S1"])
+	assert.Equal(t, int64(20), params["L1"])
+	assert.Equal(t, "i64", params["any1"].(types.Type).String())
+
+	err = bind("decimal<P1,S2>", &types.DecimalType{Precision: 12, Scale: 2}, params)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidType)
+	err = bind("any1", &types.StringType{}, params)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidType)
+	err = bind("list<i32>", &types.Int32Type{}, params)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidType)
+}
@@ -0,0 +1,137 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	substraitgo "github.com/substrait-io/substrait-go"
+	"github.com/substrait-io/substrait-go/types"
+)
+
+// ParseType parses a type string, in the format of the extension files
+// or the String methods of the concrete types such as "i32?",
+// "decimal<10,2>" or "list?<struct<i64, varchar<10>?>>", into the
+// corresponding concrete type. A '?' either directly after the type
+// name or after the closing '>' of its parameters marks the type as
+// nullable; otherwise it is required.
+func ParseType(s string) (types.Type, error) {
+	t, err := parseConcreteType(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if names, _ := fieldNames(t, nil, 0); len(names) > 0 {
+		return nil, fmt.Errorf("%w: unexpected field name %q in %s",
+			substraitgo.ErrInvalidType, names[0], s)
+	}
+	return concreteType(t, s)
+}
+
+// ParseNamedStruct parses a string in the format produced by
+// NamedStruct.String, such as "NSTRUCT<a: i32, b: struct<c: string?>>",
+// into a NamedStruct. As with the protobuf representation the field
+// names of any nested structs are collected in depth-first order.
+func ParseNamedStruct(s string) (types.NamedStruct, error) {
+	trimmed := strings.TrimSpace(s)
+	if len(trimmed) < len("nstruct") || !strings.EqualFold(trimmed[:len("nstruct")], "nstruct") {
+		return types.NamedStruct{}, fmt.Errorf("%w: expected NSTRUCT in %q",
+			substraitgo.ErrInvalidType, s)
+	}
+
+	t, err := parseConcreteType("struct" + trimmed[len("nstruct"):])
+	if err != nil {
+		return types.NamedStruct{}, err
+	}
+
+	names, unnamed := fieldNames(t, nil, 0)
+	if unnamed > 0 {
+		return types.NamedStruct{}, fmt.Errorf("%w: every field of %q must be named",
+			substraitgo.ErrInvalidType, s)
+	}
+
+	out, err := concreteType(t, s)
+	if err != nil {
+		return types.NamedStruct{}, err
+	}
+
+	st, ok := out.(*types.StructType)
+	if !ok || st.Nullability != types.NullabilityRequired {
+		return types.NamedStruct{}, fmt.Errorf("%w: expected NSTRUCT in %q",
+			substraitgo.ErrInvalidType, s)
+	}
+	return types.NamedStruct{Names: names, Struct: *st}, nil
+}
+
+// NewNamedStructFromStrings returns a required NamedStruct with the
+// given names and a field for each of the type strings, which are parsed
+// with ParseType. As with the protobuf representation the names include
+// the names of any nested struct fields in depth-first order.
+func NewNamedStructFromStrings(names []string, typeStrings []string) (types.NamedStruct, error) {
+	fields := make([]types.Type, len(typeStrings))
+	for i, s := range typeStrings {
+		var err error
+		if fields[i], err = ParseType(s); err != nil {
+			return types.NamedStruct{}, err
+		}
+	}
+
+	return types.NewNamedStruct(names, fields)
+}
+
+func parseConcreteType(s string) (*Type, error) {
+	exp, err := defaultParser.ParseString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", substraitgo.ErrInvalidType, err)
+	}
+
+	t, ok := exp.Expr.(*Type)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q is not a type", substraitgo.ErrInvalidType, s)
+	}
+	return t, nil
+}
+
+// concreteType returns the concrete type described by t, which was
+// parsed from s.
+func concreteType(t *Type, s string) (types.Type, error) {
+	out, err := t.RetType()
+	if err != nil && !errors.Is(err, substraitgo.ErrInvalidType) {
+		return nil, fmt.Errorf("%w: %q is not a concrete type: %s",
+			substraitgo.ErrInvalidType, s, err)
+	}
+	return out, err
+}
+
+// fieldNames appends the names of the fields of any structs within t to
+// names in depth-first order, returning them along with the number of
+// fields which have no name.
+func fieldNames(t *Type, names []string, unnamed int) ([]string, int) {
+	var nested []TypeExpression
+	switch def := t.TypeDef.(type) {
+	case *listType:
+		nested = []TypeExpression{def.ElemType}
+	case *mapType:
+		nested = []TypeExpression{def.Key, def.Value}
+	case *structType:
+		for _, f := range def.Fields {
+			if f.Name == "" {
+				unnamed++
+			} else {
+				names = append(names, f.Name)
+			}
+			if ft, ok := f.Type.Expr.(*Type); ok {
+				names, unnamed = fieldNames(ft, names, unnamed)
+			}
+		}
+	}
+
+	for _, e := range nested {
+		if et, ok := e.Expr.(*Type); ok {
+			names, unnamed = fieldNames(et, names, unnamed)
+		}
+	}
+	return names, unnamed
+}
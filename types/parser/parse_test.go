@@ -0,0 +1,205 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package parser_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	substraitgo "github.com/substrait-io/substrait-go"
+	. "github.com/substrait-io/substrait-go/types"
+	"github.com/substrait-io/substrait-go/types/parser"
+)
+
+func TestParseTypeRoundTrip(t *testing.T) {
+	tests := []Type{
+		&BooleanType{Nullability: NullabilityRequired},
+		&Int8Type{Nullability: NullabilityNullable},
+		&Int16Type{Nullability: NullabilityRequired},
+		&Int32Type{Nullability: NullabilityNullable},
+		&Int64Type{Nullability: NullabilityRequired},
+		&Float32Type{Nullability: NullabilityRequired},
+		&Float64Type{Nullability: NullabilityNullable},
+		&StringType{Nullability: NullabilityRequired},
+		&BinaryType{Nullability: NullabilityNullable},
+		&DateType{Nullability: NullabilityRequired},
+		&TimeType{Nullability: NullabilityRequired},
+		&TimestampType{Nullability: NullabilityNullable},
+		&TimestampTzType{Nullability: NullabilityRequired},
+		&IntervalYearType{Nullability: NullabilityRequired},
+		&IntervalDayType{Nullability: NullabilityNullable},
+		&UUIDType{Nullability: NullabilityNullable},
+		&FixedCharType{Length: 5, Nullability: NullabilityRequired},
+		&VarCharType{Length: 15, Nullability: NullabilityNullable},
+		&FixedBinaryType{Length: 10, Nullability: NullabilityRequired},
+		&DecimalType{Precision: 38, Scale: 10, Nullability: NullabilityNullable},
+		NewPrecisionTimestampType(PrecisionMicroSeconds).WithNullability(NullabilityRequired),
+		NewPrecisionTimestampTzType(PrecisionNanoSeconds).WithNullability(NullabilityNullable),
+		NewIntervalCompoundType().WithPrecision(PrecisionMilliSeconds).WithNullability(NullabilityRequired),
+		NewIntervalYearToMonthType().WithNullability(NullabilityNullable),
+		&ListType{Nullability: NullabilityNullable,
+			Type: &Int32Type{Nullability: NullabilityRequired}},
+		&MapType{Nullability: NullabilityRequired,
+			Key:   &StringType{Nullability: NullabilityRequired},
+			Value: &ListType{Nullability: NullabilityNullable, Type: &DecimalType{Precision: 10, Scale: 2, Nullability: NullabilityNullable}}},
+		&StructType{Nullability: NullabilityRequired, Types: []Type{}},
+		&StructType{Nullability: NullabilityNullable, Types: []Type{
+			&Int64Type{Nullability: NullabilityRequired},
+			&StructType{Nullability: NullabilityRequired, Types: []Type{
+				&VarCharType{Length: 10, Nullability: NullabilityNullable},
+				&MapType{Nullability: NullabilityNullable,
+					Key:   &Int8Type{Nullability: NullabilityRequired},
+					Value: &FixedCharType{Length: 3, Nullability: NullabilityRequired}},
+			}},
+			&ListType{Nullability: NullabilityRequired,
+				Type: &ListType{Nullability: NullabilityNullable, Type: &BooleanType{Nullability: NullabilityNullable}}},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.String(), func(t *testing.T) {
+			out, err := parser.ParseType(tt.String())
+			require.NoError(t, err)
+			assert.Truef(t, tt.Equals(out), "expected: %s\ngot: %s", tt, out)
+		})
+	}
+}
+
+func TestParseTypeVariants(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Type
+	}{
+		{"  i32 ", &Int32Type{Nullability: NullabilityRequired}},
+		{"I64?", &Int64Type{Nullability: NullabilityNullable}},
+		{"BOOLEAN", &BooleanType{Nullability: NullabilityRequired}},
+		{"string?", &StringType{Nullability: NullabilityNullable}},
+		{"varchar<10>?", &VarCharType{Length: 10, Nullability: NullabilityNullable}},
+		{"fixedchar<4>", &FixedCharType{Length: 4, Nullability: NullabilityRequired}},
+		{"decimal < 10 , 2 >?", &DecimalType{Precision: 10, Scale: 2, Nullability: NullabilityNullable}},
+		{"precision_timestamp<3>", NewPrecisionTimestampType(PrecisionMilliSeconds).WithNullability(NullabilityRequired)},
+		{"list<\n  i8?\n>?", &ListType{Nullability: NullabilityNullable, Type: &Int8Type{Nullability: NullabilityNullable}}},
+		{"map<string,i32>", &MapType{Nullability: NullabilityRequired,
+			Key: &StringType{Nullability: NullabilityRequired}, Value: &Int32Type{Nullability: NullabilityRequired}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			out, err := parser.ParseType(tt.input)
+			require.NoError(t, err)
+			assert.Truef(t, tt.expected.Equals(out), "expected: %s\ngot: %s", tt.expected, out)
+		})
+	}
+}
+
+func TestParseTypeErrors(t *testing.T) {
+	tests := []struct {
+		input, err string
+	}{
+		{"", `unexpected token "<EOF>"`},
+		{"i31", `"i31" is not a type`},
+		{"list<i32", `unexpected token "<EOF>"`},
+		{"list<>", `unexpected token ">"`},
+		{"map<i32>", `unexpected token ">"`},
+		{"decimal<10>", `unexpected token ">"`},
+		{"decimal", `unexpected token "<EOF>"`},
+		{"decimal<39,2>", "invalid decimal<39,2>"},
+		{"decimal<10,11>", "invalid decimal<10,11>"},
+		{"decimal<P,S>", `"decimal<P,S>" is not a concrete type`},
+		{"varchar<x>", `"varchar<x>" is not a concrete type`},
+		{"varchar<99999999999>", "value out of range"},
+		{"precision_timestamp<10>", "invalid TimePrecision value 10"},
+		{"any1", "any1 is not a concrete type"},
+		{"i32??", `unexpected token "<EOF>"`},
+		{"struct<a: i32>", `unexpected field name "a"`},
+		{"i32 i64", `unexpected token "i64"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := parser.ParseType(tt.input)
+			assert.ErrorIs(t, err, substraitgo.ErrInvalidType)
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
+
+func TestParseNamedStruct(t *testing.T) {
+	tests := []NamedStruct{
+		{Names: []string{"a", "b"}, Struct: StructType{
+			Nullability: NullabilityRequired,
+			Types: []Type{
+				&Int32Type{Nullability: NullabilityRequired},
+				&StringType{Nullability: NullabilityNullable},
+			},
+		}},
+		{Names: []string{"a", "b", "c", "d", "e", "f"}, Struct: StructType{
+			Nullability: NullabilityRequired,
+			Types: []Type{
+				&StructType{Nullability: NullabilityNullable, Types: []Type{
+					&DecimalType{Precision: 10, Scale: 2, Nullability: NullabilityRequired},
+					&Int64Type{Nullability: NullabilityNullable},
+				}},
+				&ListType{Nullability: NullabilityRequired, Type: &StructType{
+					Nullability: NullabilityRequired, Types: []Type{
+						&VarCharType{Length: 5, Nullability: NullabilityNullable},
+					}}},
+				&MapType{Nullability: NullabilityNullable,
+					Key:   &StringType{Nullability: NullabilityRequired},
+					Value: &Float64Type{Nullability: NullabilityRequired}},
+			},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.String(), func(t *testing.T) {
+			out, err := parser.ParseNamedStruct(tt.String())
+			require.NoError(t, err)
+			assert.Equal(t, tt.Names, out.Names)
+			assert.Truef(t, tt.Struct.Equals(&out.Struct), "expected: %s\ngot: %s", tt, out)
+		})
+	}
+
+	// field names may be the same as type names
+	out, err := parser.ParseNamedStruct("nstruct<date: date, list: list<struct<T: i32?>>>")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"date", "list", "T"}, out.Names)
+	assert.Equal(t, "NSTRUCT<date: date, list: list<struct<T: i32?>>>", out.String())
+
+	_, err = parser.ParseNamedStruct("struct<a: i32>")
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidType)
+	assert.ErrorContains(t, err, `expected NSTRUCT in "struct<a: i32>"`)
+
+	_, err = parser.ParseNamedStruct("NSTRUCT<i32>")
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidType)
+	assert.ErrorContains(t, err, `every field of "NSTRUCT<i32>" must be named`)
+}
+
+func TestNewNamedStructFromStrings(t *testing.T) {
+	expected := NamedStruct{Names: []string{"a", "b"},
+		Struct: StructType{
+			Nullability: NullabilityRequired,
+			Types: []Type{
+				&StringType{Nullability: NullabilityRequired},
+				&Float32Type{Nullability: NullabilityRequired},
+			},
+		}}
+
+	out, err := parser.NewNamedStructFromStrings([]string{"a", "b"}, []string{"string", "fp32"})
+	require.NoError(t, err)
+	assert.Equal(t, expected, out)
+
+	nested, err := parser.NewNamedStructFromStrings([]string{"a", "b", "c", "d"},
+		[]string{"list<struct<i32, date?>>?", "i64"})
+	require.NoError(t, err)
+	assert.Equal(t, "NSTRUCT<a: list<struct<b: i32, c: date?>>?, d: i64>", nested.String())
+
+	_, err = parser.NewNamedStructFromStrings([]string{"a"}, []string{"list<struct<i32, date?>>"})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidType)
+	assert.ErrorContains(t, err, "got 1 names for fields requiring 3")
+
+	_, err = parser.NewNamedStructFromStrings([]string{"a"}, []string{"foo"})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidType)
+	assert.ErrorContains(t, err, `"foo" is not a type`)
+}
@@ -4,7 +4,9 @@ package parser
 
 import (
 	"errors"
+	"fmt"
 	"io"
+	"reflect"
 	"strconv"
 	"strings"
 
@@ -15,7 +17,13 @@ import (
 	"github.com/substrait-io/substrait-go/types/integer_parameters"
 )
 
-var defaultParser *Parser
+var defaultParser = func() *Parser {
+	p, err := New()
+	if err != nil {
+		panic(err)
+	}
+	return p
+}()
 
 type TypeExpression struct {
 	Expr Expression `parser:"@@"`
@@ -42,10 +50,6 @@ func (t *TypeExpression) UnmarshalYAML(fn func(interface{}) error) error {
 		return err
 	}
 
-	if defaultParser == nil {
-		defaultParser, _ = New()
-	}
-
 	switch v := alias.(type) {
 	case string:
 		exp, err := defaultParser.ParseString(v)
@@ -63,7 +67,153 @@ type Expression interface {
 	String() string
 }
 
-// TODO: implement UnaryOp, BinaryOp, IfElse, and ReturnProgram
+// ReturnProgram is a type derivation program, such as the return type
+// of the decimal arithmetic functions:
+//
+//	init_scale = max(S1,S2)
+//	init_prec = init_scale + max(P1 - S1, P2 - S2) + 1
+//	prec = min(init_prec, 38)
+//	scale = init_prec > 38 ? max(init_scale - (init_prec - 38), 6) : init_scale
+//	DECIMAL<prec, scale>
+//
+// It is a sequence of assignments, optionally separated by semicolons,
+// followed by the expression giving the resulting type.
+type ReturnProgram struct {
+	Assignments []*Assignment `parser:"@@ @@*"`
+	FinalType   Expression    `parser:"@@"`
+}
+
+func (r *ReturnProgram) String() string {
+	var b strings.Builder
+	for _, a := range r.Assignments {
+		b.WriteString(a.String())
+		b.WriteByte('\n')
+	}
+	b.WriteString(r.FinalType.String())
+	return b.String()
+}
+
+// Assignment binds the value of an expression to a name for the rest of
+// a ReturnProgram.
+type Assignment struct {
+	Name  string     `parser:"@Identifier '='"`
+	Value Expression `parser:"@@ ';'?"`
+}
+
+func (a *Assignment) String() string { return a.Name + " = " + a.Value.String() }
+
+// IfElse is the conditional operator, cond ? then : else.
+//
+// Every expression other than a ReturnProgram is parsed as an IfElse
+// whose condition is a BinaryOp, rather than trying each kind of
+// expression in turn which would parse the operands again for each.
+// Those without an operator are then replaced by their operand, see
+// simplify.
+type IfElse struct {
+	IfExpr   condition  `parser:"@@"`
+	ThenExpr Expression `parser:"('?' @@"`
+	ElseExpr Expression `parser:"':' @@)?"`
+}
+
+func (e *IfElse) String() string {
+	return e.IfExpr.String() + " ? " + e.ThenExpr.String() + " : " + e.ElseExpr.String()
+}
+
+// BinaryOp is a chain of operands joined by the arithmetic (+, -, *, /),
+// comparison and boolean (&&, ||) operators. The operators are applied
+// according to their precedence when it is evaluated.
+type BinaryOp struct {
+	Left operand        `parser:"@@"`
+	Rest []*BinaryOpRHS `parser:"@@*"`
+}
+
+// BinaryOpRHS is an operator of a BinaryOp along with its right operand.
+type BinaryOpRHS struct {
+	Op    string  `parser:"@('+' | '-' | '*' | '/' | '<=' | '>=' | '<' | '>' | '==' | '!=' | '&&' | '||')"`
+	Right operand `parser:"@@"`
+}
+
+func (b *BinaryOp) String() string {
+	var sb strings.Builder
+	sb.WriteString(b.Left.String())
+	for _, r := range b.Rest {
+		sb.WriteString(" " + r.Op + " ")
+		sb.WriteString(r.Right.String())
+	}
+	return sb.String()
+}
+
+// UnaryOp is the negation of an integer or of a boolean.
+type UnaryOp struct {
+	Op      string  `parser:"@('-' | '!')"`
+	Operand operand `parser:"@@"`
+}
+
+func (u *UnaryOp) String() string { return u.Op + u.Operand.String() }
+
+// FunctionCall is a call of the min or max function.
+type FunctionCall struct {
+	Name string       `parser:"@Identifier '('"`
+	Args []Expression `parser:"@@ (',' @@)* ')'"`
+}
+
+func (f *FunctionCall) String() string {
+	args := make([]string, len(f.Args))
+	for i, a := range f.Args {
+		args[i] = a.String()
+	}
+	return f.Name + "(" + strings.Join(args, ", ") + ")"
+}
+
+// ParenExpression is an expression in parentheses.
+type ParenExpression struct {
+	Expr Expression `parser:"'(' @@ ')'"`
+}
+
+func (p *ParenExpression) String() string { return "(" + p.Expr.String() + ")" }
+
+// condition is an expression which may be the condition of an IfElse,
+// and operand one which may be an operand of a BinaryOp. They're
+// distinct from Expression so that the grammar isn't left recursive.
+type (
+	condition interface{ Expression }
+	operand   interface{ Expression }
+)
+
+// simplify replaces each IfElse and BinaryOp of the parsed expression
+// which has no operator with its operand, so that a type is parsed as a
+// *Type and an integer as an *IntegerLiteral.
+func simplify(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if !v.IsNil() {
+			simplify(v.Elem())
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			simplify(v.Field(i))
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			simplify(v.Index(i))
+		}
+	case reflect.Interface:
+		if v.IsNil() {
+			return
+		}
+		simplify(v.Elem())
+		switch e := v.Interface().(type) {
+		case *IfElse:
+			if e.ThenExpr == nil {
+				v.Set(reflect.ValueOf(e.IfExpr))
+			}
+		case *BinaryOp:
+			if len(e.Rest) == 0 {
+				v.Set(reflect.ValueOf(e.Left))
+			}
+		}
+	}
+}
 
 type ParamName struct {
 	Name string `parser:"@Identifier"`
@@ -109,15 +259,27 @@ type Def interface {
 	// ArgType indicates argument type
 	ArgType() (types.FuncDefArgType, error)
 	Optional() bool
-	// TODO RetType indicates return type
-	// This should be replaced with TypeDerivation method. Currently it just returns concrete type
+	// RetType indicates return type, which must be concrete. Types
+	// with parameters are derived by TypeExpression.Evaluate instead.
 	RetType() (types.Type, error)
 }
 
 type typename string
 
+// typeNameAliases maps the names written by the String methods of the
+// concrete types to the names used in the extension files.
+var typeNameAliases = map[typename]typename{
+	"char":                 "fixedchar",
+	"precisiontimestamp":   "precision_timestamp",
+	"precisiontimestamptz": "precision_timestamp_tz",
+	"intervalcompound":     "interval_compound",
+}
+
 func (t *typename) Capture(values []string) error {
 	*t = typename(strings.ToLower(values[0]))
+	if alias, ok := typeNameAliases[*t]; ok {
+		*t = alias
+	}
 	return nil
 }
 
@@ -142,11 +304,9 @@ func (t *nonParamType) ShortType() string {
 }
 
 func (t *nonParamType) RetType() (types.Type, error) {
-	var n types.Nullability
-	if t.Nullability {
-		n = types.NullabilityNullable
-	} else {
-		n = types.NullabilityRequired
+	n := nullability(t.Nullability)
+	if t.TypeName == "intervalyeartomonth" {
+		return types.NewIntervalYearToMonthType().WithNullability(n), nil
 	}
 	typ, err := types.SimpleTypeNameToType(types.TypeName(t.TypeName))
 	if err == nil {
@@ -171,50 +331,29 @@ func (t *nonParamType) ArgType() (types.FuncDefArgType, error) {
 }
 
 type listType struct {
-	Nullability bool           `parser:"'list' @'?'?"`
-	ElemType    TypeExpression `parser:"'<' @@ '>'"`
+	Nullability         bool           `parser:"'list' @'?'?"`
+	ElemType            TypeExpression `parser:"'<' @@ '>'"`
+	TrailingNullability bool           `parser:"@'?'?"`
 }
 
 func (*listType) ShortType() string { return "list" }
 
 func (l *listType) String() string {
 	var opt string
-	if l.Nullability {
+	if l.Optional() {
 		opt = "?"
 	}
 	return "list" + opt + "<" + l.ElemType.Expr.String() + ">"
 }
 
-func (l *listType) Optional() bool { return false }
+func (l *listType) Optional() bool { return l.Nullability || l.TrailingNullability }
 
 func (l *listType) RetType() (types.Type, error) {
-	var n types.Nullability
-	if l.Nullability {
-		n = types.NullabilityNullable
-	} else {
-		n = types.NullabilityRequired
-	}
-	if t, ok := l.ElemType.Expr.(*Type); ok {
-		ret, err := t.RetType()
-		if err != nil {
-			return nil, err
-		}
-		return &types.ListType{
-			Nullability: n,
-			Type:        ret,
-		}, nil
-	}
-
-	return nil, substraitgo.ErrNotImplemented
+	return l.evalType(nil)
 }
 
 func (l *listType) ArgType() (types.FuncDefArgType, error) {
-	var n types.Nullability
-	if l.Nullability {
-		n = types.NullabilityNullable
-	} else {
-		n = types.NullabilityRequired
-	}
+	n := nullability(l.Optional())
 	if t, ok := l.ElemType.Expr.(*Type); ok {
 		ret, err := t.ArgType()
 		if err != nil {
@@ -229,8 +368,10 @@ func (l *listType) ArgType() (types.FuncDefArgType, error) {
 }
 
 type lengthType struct {
-	TypeName     string         `parser:"@LengthType '<'"`
-	NumericParam TypeExpression `parser:"@@ '>'"`
+	TypeName            typename       `parser:"@LengthType"`
+	Nullability         bool           `parser:"@'?'? '<'"`
+	NumericParam        TypeExpression `parser:"@@ '>'"`
+	TrailingNullability bool           `parser:"@'?'?"`
 }
 
 func (p *lengthType) ShortType() string {
@@ -241,32 +382,28 @@ func (p *lengthType) ShortType() string {
 		return "prets"
 	case "precision_timestamp_tz":
 		return "pretstz"
+	case "interval_compound":
+		return "intrcomp"
 	}
 	return ""
 }
 
 func (p *lengthType) String() string {
-	return p.TypeName + "<" + p.NumericParam.Expr.String() + ">"
+	var opt string
+	if p.Optional() {
+		opt = "?"
+	}
+	return string(p.TypeName) + opt + "<" + p.NumericParam.Expr.String() + ">"
 }
 
-func (p *lengthType) Optional() bool { return false }
+func (p *lengthType) Optional() bool { return p.Nullability || p.TrailingNullability }
 
 func (p *lengthType) RetType() (types.Type, error) {
-	var n types.Nullability
-	lit, ok := p.NumericParam.Expr.(*IntegerLiteral)
-	if !ok {
-		return nil, substraitgo.ErrNotImplemented
-	}
-
-	typ, err := types.FixedTypeNameToType(types.TypeName(p.TypeName))
-	if err != nil {
-		return nil, err
-	}
-	return typ.WithLength(lit.Value).WithNullability(n), nil
+	return p.evalType(nil)
 }
 
 func (p *lengthType) ArgType() (types.FuncDefArgType, error) {
-	var n types.Nullability
+	n := nullability(p.Optional())
 
 	var leafParam integer_parameters.IntegerParameter
 	switch t := p.NumericParam.Expr.(type) {
@@ -277,7 +414,7 @@ func (p *lengthType) ArgType() (types.FuncDefArgType, error) {
 	default:
 		return nil, substraitgo.ErrNotImplemented
 	}
-	typ, err := getParameterizedTypeSingleParam(p.TypeName, leafParam, n)
+	typ, err := getParameterizedTypeSingleParam(string(p.TypeName), leafParam, n)
 	if err != nil {
 		return nil, err
 	}
@@ -302,30 +439,26 @@ func getParameterizedTypeSingleParam(typeName string, leafParam integer_paramete
 }
 
 type decimalType struct {
-	Nullability bool           `parser:"'decimal' @'?'?"`
-	Precision   TypeExpression `parser:"'<' @@"`
-	Scale       TypeExpression `parser:"',' @@ '>'"`
+	Nullability         bool           `parser:"'decimal' @'?'?"`
+	Precision           TypeExpression `parser:"'<' @@"`
+	Scale               TypeExpression `parser:"',' @@ '>'"`
+	TrailingNullability bool           `parser:"@'?'?"`
 }
 
 func (*decimalType) ShortType() string { return "dec" }
 
 func (d *decimalType) String() string {
 	var opt string
-	if d.Nullability {
+	if d.Optional() {
 		opt = "?"
 	}
 	return "decimal" + opt + "<" + d.Precision.Expr.String() + "," + d.Scale.Expr.String() + ">"
 }
 
-func (d *decimalType) Optional() bool { return d.Nullability }
+func (d *decimalType) Optional() bool { return d.Nullability || d.TrailingNullability }
 
 func (d *decimalType) ArgType() (types.FuncDefArgType, error) {
-	var n types.Nullability
-	if d.Nullability {
-		n = types.NullabilityNullable
-	} else {
-		n = types.NullabilityRequired
-	}
+	n := nullability(d.Optional())
 	var precision integer_parameters.IntegerParameter
 	if pi, ok := d.Precision.Expr.(*IntegerLiteral); ok {
 		precision = integer_parameters.NewConcreteIntParam(pi.Value)
@@ -350,30 +483,20 @@ func (d *decimalType) ArgType() (types.FuncDefArgType, error) {
 }
 
 func (d *decimalType) RetType() (types.Type, error) {
-	var n types.Nullability
-	if d.Nullability {
-		n = types.NullabilityNullable
-	} else {
-		n = types.NullabilityRequired
-	}
-	p, ok := d.Precision.Expr.(*IntegerLiteral)
-	if !ok {
-		return nil, substraitgo.ErrNotImplemented
-	}
-	s, ok := d.Scale.Expr.(*IntegerLiteral)
-	if !ok {
-		return nil, substraitgo.ErrNotImplemented
-	}
-	return &types.DecimalType{
-		Nullability: n,
-		Precision:   p.Value,
-		Scale:       s.Value,
-	}, nil
+	return d.evalType(nil)
 }
 
 type structType struct {
-	Nullability bool             `parser:"'struct' @'?'?"`
-	Types       []TypeExpression `parser:"'<' @@ (',' @@)* '>'"`
+	Nullability         bool          `parser:"'struct' @'?'?"`
+	Fields              []structField `parser:"'<' (@@ (',' @@)*)? '>'"`
+	TrailingNullability bool          `parser:"@'?'?"`
+}
+
+// structField is a field of a struct type, which has a name when the
+// struct is part of a NamedStruct such as "NSTRUCT<a: struct<b: i32>>".
+type structField struct {
+	Name string         `parser:"(@(Identifier | Template | AnyType | Boolean | IntType | FPType | Temporal | BinaryType | LengthType | ParamType) ':')?"`
+	Type TypeExpression `parser:"@@"`
 }
 
 func (*structType) ShortType() string { return "struct" }
@@ -381,58 +504,36 @@ func (*structType) ShortType() string { return "struct" }
 func (s *structType) String() string {
 	var b strings.Builder
 	b.WriteString("struct")
-	if s.Nullability {
+	if s.Optional() {
 		b.WriteByte('?')
 	}
 	b.WriteByte('<')
-	for i, t := range s.Types {
+	for i, f := range s.Fields {
 		if i != 0 {
 			b.WriteString(", ")
 		}
-		b.WriteString(t.Expr.String())
+		if f.Name != "" {
+			b.WriteString(f.Name)
+			b.WriteString(": ")
+		}
+		b.WriteString(f.Type.Expr.String())
 	}
 	b.WriteString(">")
 	return b.String()
 }
 
-func (t *structType) Optional() bool { return t.Nullability }
+func (t *structType) Optional() bool { return t.Nullability || t.TrailingNullability }
 
 func (t *structType) RetType() (types.Type, error) {
-	var n types.Nullability
-	if t.Nullability {
-		n = types.NullabilityNullable
-	} else {
-		n = types.NullabilityRequired
-	}
-	var err error
-	typeList := make([]types.Type, len(t.Types))
-	for i, typ := range t.Types {
-		tp, ok := typ.Expr.(*Type)
-		if !ok {
-			return nil, substraitgo.ErrNotImplemented
-		}
-
-		if typeList[i], err = tp.RetType(); err != nil {
-			return nil, err
-		}
-	}
-	return &types.StructType{
-		Nullability: n,
-		Types:       typeList,
-	}, nil
+	return t.evalType(nil)
 }
 
 func (t *structType) ArgType() (types.FuncDefArgType, error) {
-	var n types.Nullability
-	if t.Nullability {
-		n = types.NullabilityNullable
-	} else {
-		n = types.NullabilityRequired
-	}
+	n := nullability(t.Optional())
 	var err error
-	typeList := make([]types.FuncDefArgType, len(t.Types))
-	for i, typ := range t.Types {
-		tp, ok := typ.Expr.(*Type)
+	typeList := make([]types.FuncDefArgType, len(t.Fields))
+	for i, f := range t.Fields {
+		tp, ok := f.Type.Expr.(*Type)
 		if !ok {
 			return nil, substraitgo.ErrNotImplemented
 		}
@@ -448,64 +549,30 @@ func (t *structType) ArgType() (types.FuncDefArgType, error) {
 }
 
 type mapType struct {
-	Nullability bool           `parser:"'map' @'?'?"`
-	Key         TypeExpression `parser:"'<' @@"`
-	Value       TypeExpression `parser:"',' @@ '>'"`
+	Nullability         bool           `parser:"'map' @'?'?"`
+	Key                 TypeExpression `parser:"'<' @@"`
+	Value               TypeExpression `parser:"',' @@ '>'"`
+	TrailingNullability bool           `parser:"@'?'?"`
 }
 
 func (*mapType) ShortType() string { return "map" }
 
 func (m *mapType) String() string {
 	var opt string
-	if m.Nullability {
+	if m.Optional() {
 		opt = "?"
 	}
 	return "map" + opt + "<" + m.Key.Expr.String() + ", " + m.Value.Expr.String() + ">"
 }
 
-func (m *mapType) Optional() bool { return m.Nullability }
+func (m *mapType) Optional() bool { return m.Nullability || m.TrailingNullability }
 
 func (m *mapType) RetType() (types.Type, error) {
-	var n types.Nullability
-	if m.Nullability {
-		n = types.NullabilityNullable
-	} else {
-		n = types.NullabilityRequired
-	}
-
-	k, ok := m.Key.Expr.(*Type)
-	if !ok {
-		return nil, substraitgo.ErrNotImplemented
-	}
-
-	v, ok := m.Value.Expr.(*Type)
-	if !ok {
-		return nil, substraitgo.ErrNotImplemented
-	}
-
-	key, err := k.RetType()
-	if err != nil {
-		return nil, err
-	}
-
-	value, err := v.RetType()
-	if err != nil {
-		return nil, err
-	}
-	return &types.MapType{
-		Key:         key,
-		Value:       value,
-		Nullability: n,
-	}, nil
+	return m.evalType(nil)
 }
 
 func (m *mapType) ArgType() (types.FuncDefArgType, error) {
-	var n types.Nullability
-	if m.Nullability {
-		n = types.NullabilityNullable
-	} else {
-		n = types.NullabilityRequired
-	}
+	n := nullability(m.Optional())
 
 	k, ok := m.Key.Expr.(*Type)
 	if !ok {
@@ -572,24 +639,25 @@ func (t anyType) ArgType() (types.FuncDefArgType, error) {
 }
 
 func (t anyType) RetType() (types.Type, error) {
-	panic("any type can't be in return type")
+	return nil, fmt.Errorf("%w: %s is not a concrete type", substraitgo.ErrInvalidType, t)
 }
 
 var (
 	def = lexer.MustSimple([]lexer.SimpleRule{
-		{Name: "whitespace", Pattern: `[ \t]+`},
-		{Name: "Template", Pattern: `T`},
-		{Name: "AnyType", Pattern: `any[\d]?`},
-		{Name: "Boolean", Pattern: `(?i)boolean`},
-		{Name: "IntType", Pattern: `i(8|16|32|64)`},
-		{Name: "FPType", Pattern: `fp(32|64)`},
-		{Name: "Temporal", Pattern: `timestamp(_tz)?|date|time|interval_day|interval_year`},
-		{Name: "BinaryType", Pattern: `string|binary|uuid`},
-		{Name: "LengthType", Pattern: `fixedchar|varchar|fixedbinary|precision_timestamp_tz|precision_timestamp`},
-		{Name: "Int", Pattern: `[-+]?\d+`},
-		{Name: "ParamType", Pattern: `(?i)(struct|list|decimal|map)`},
+		{Name: "whitespace", Pattern: `\s+`},
+		{Name: "Template", Pattern: `T\b`},
+		{Name: "AnyType", Pattern: `any[\d]?\b`},
+		{Name: "Boolean", Pattern: `(?i)boolean\b`},
+		{Name: "IntType", Pattern: `(?i)i(8|16|32|64)\b`},
+		{Name: "FPType", Pattern: `(?i)fp(32|64)\b`},
+		{Name: "Temporal", Pattern: `(?i)(timestamp(_tz)?|date|time|interval_day|interval_year|intervalyeartomonth)\b`},
+		{Name: "BinaryType", Pattern: `(?i)(string|binary|uuid)\b`},
+		{Name: "LengthType", Pattern: `(?i)(fixedchar|char|varchar|fixedbinary|precision_timestamp_tz|precision_timestamp|precisiontimestamptz|precisiontimestamp|interval_compound|intervalcompound)\b`},
+		{Name: "Int", Pattern: `\d+`},
+		{Name: "ParamType", Pattern: `(?i)(struct|list|decimal|map)\b`},
 		{Name: "Identifier", Pattern: `[a-zA-Z_$][a-zA-Z_$0-9]*`},
-		{Name: "Ident", Pattern: `([a-zA-Z_]\w*)|[><,?]`},
+		{Name: "Operator", Pattern: `&&|\|\||==|!=|<=|>=|[-+*/!=();]`},
+		{Name: "Ident", Pattern: `([a-zA-Z_]\w*)|[><,?:]`},
 	})
 )
 
@@ -598,24 +666,34 @@ type Parser struct {
 }
 
 func (p *Parser) Parse(r io.Reader) (*TypeExpression, error) {
-	return p.parser.Parse("expression", r)
+	return simplified(p.parser.Parse("expression", r))
 }
 
 func (p *Parser) ParseString(str string) (*TypeExpression, error) {
-	return p.parser.ParseString("expression", str)
+	return simplified(p.parser.ParseString("expression", str))
 }
 
 func (p *Parser) ParseBytes(expr []byte) (*TypeExpression, error) {
-	return p.parser.ParseBytes("expression", expr)
+	return simplified(p.parser.ParseBytes("expression", expr))
+}
+
+func simplified(t *TypeExpression, err error) (*TypeExpression, error) {
+	if err != nil {
+		return nil, err
+	}
+	simplify(reflect.ValueOf(t))
+	return t, nil
 }
 
 func New() (*Parser, error) {
 	parser, err := participle.Build[TypeExpression](
-		participle.Union[Expression](&Type{}, &IntegerLiteral{}, &ParamName{}),
+		participle.Union[Expression](&ReturnProgram{}, &IfElse{}),
+		participle.Union[condition](&BinaryOp{}),
+		participle.Union[operand](&UnaryOp{}, &FunctionCall{}, &ParenExpression{}, &Type{}, &IntegerLiteral{}, &ParamName{}),
 		participle.Union[Def](&anyType{}, &nonParamType{}, &mapType{}, &listType{}, &structType{}, &lengthType{}, &decimalType{}),
 		participle.CaseInsensitive("Boolean", "ParamType", "IntType", "FPType", "Temporal", "BinaryType", "LengthType"),
 		participle.Lexer(def),
-		participle.UseLookahead(3),
+		participle.UseLookahead(participle.MaxLookahead),
 	)
 	if err != nil {
 		return nil, err
@@ -417,6 +417,14 @@ type (
 	}
 )
 
+// NewFunctionOption returns a FunctionOption setting the named option of
+// a function call, with the values in order of preference such as
+// NewFunctionOption("overflow", "ERROR") or
+// NewFunctionOption("rounding", "TIE_TO_EVEN", "TIE_AWAY_FROM_ZERO").
+func NewFunctionOption(name string, preferences ...string) *FunctionOption {
+	return &FunctionOption{Name: name, Preference: preferences}
+}
+
 // TypeToProto properly constructs the appropriate protobuf message
 // for the given type.
 func TypeToProto(t Type) *proto.Type {
@@ -527,10 +535,23 @@ func TypeToProto(t Type) *proto.Type {
 		return t.ToProto()
 	case *MapType:
 		return t.ToProto()
+	case *UserDefinedType:
+		return t.ToProto()
 	}
 	panic("unimplemented type")
 }
 
+// NullLiteralToProto constructs the protobuf message for a typed null
+// literal of the given type, with the null oneof holding the proto form
+// of the type as returned by TypeToProto.
+func NullLiteralToProto(t Type) *proto.Expression_Literal {
+	return &proto.Expression_Literal{
+		Nullable:               true,
+		TypeVariationReference: t.GetTypeVariationReference(),
+		LiteralType:            &proto.Expression_Literal_Null{Null: TypeToProto(t)},
+	}
+}
+
 type primitiveTypeIFace interface {
 	bool | int8 | int16 | ~int32 | ~int64 |
 		float32 | float64 | ~string |
@@ -1143,11 +1164,18 @@ func TypeParamFromProto(p *proto.Type_Parameter) TypeParam {
 	return nil
 }
 
+// UserDefinedType is a type declared by an extension, identified by
+// the anchor of its declaration in the plan, TypeReference.
 type UserDefinedType struct {
 	Nullability      Nullability
 	TypeVariationRef uint32
 	TypeReference    uint32
 	TypeParameters   []TypeParam
+
+	// Name is the name of the type in its extension, if known, such as
+	// when it's built by plan.Builder. It's only used by String and
+	// ShortString, it isn't serialized and is ignored by Equals.
+	Name string
 }
 
 func (*UserDefinedType) isRootRef() {}
@@ -1206,13 +1234,55 @@ func (t *UserDefinedType) ToProtoFuncArg() *proto.FunctionArgument {
 	}
 }
 
-// exists for meeting the interface, but the correct short name for
-// a user defined type is "u!name" which requires looking up the
-// type first via the type reference to find the name.
-func (*UserDefinedType) ShortString() string { return "" }
+// ShortString returns "u!name" if the name of the type is known, which
+// otherwise requires looking up the type via its type reference, and an
+// empty string if it isn't.
+func (t *UserDefinedType) ShortString() string {
+	if t.Name == "" {
+		return ""
+	}
+	return "u!" + t.Name
+}
 
+// String returns the type as "u!name<params>", such as "u!point?<3>",
+// if the name of the type is known.
 func (t *UserDefinedType) String() string {
-	return "user_defined_type"
+	if t.Name == "" {
+		return "user_defined_type"
+	}
+
+	var b strings.Builder
+	b.WriteString(t.ShortString())
+	b.WriteString(strNullable(t))
+	if len(t.TypeParameters) > 0 {
+		b.WriteByte('<')
+		for i, p := range t.TypeParameters {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(typeParamString(p))
+		}
+		b.WriteByte('>')
+	}
+	return b.String()
+}
+
+func typeParamString(p TypeParam) string {
+	switch p := p.(type) {
+	case NullParameter:
+		return "null"
+	case *DataTypeParameter:
+		return p.Type.String()
+	case BooleanParameter:
+		return strconv.FormatBool(bool(p))
+	case IntegerParameter:
+		return strconv.FormatInt(int64(p), 10)
+	case EnumParameter:
+		return string(p)
+	case StringParameter:
+		return strconv.Quote(string(p))
+	}
+	return fmt.Sprint(p)
 }
 
 func (e Enum) ToProtoFuncArg() *proto.FunctionArgument {
@@ -1310,3 +1380,91 @@ func (n NamedStruct) String() string {
 
 	return b.String()
 }
+
+// NewNamedStruct returns a NamedStruct with the given names and a field
+// of each of the given types. As with the protobuf representation the
+// names include the names of any nested struct fields in depth-first
+// order, an error wrapping substraitgo.ErrInvalidType is returned if
+// their number doesn't match the fields or if a type is nil. The struct
+// is required unless a nullability is given.
+func NewNamedStruct(names []string, fields []Type, nullability ...Nullability) (NamedStruct, error) {
+	for i, f := range fields {
+		if f == nil {
+			return NamedStruct{}, fmt.Errorf("%w: type of field %d is nil",
+				substraitgo.ErrInvalidType, i)
+		}
+	}
+
+	if expected := CountNames(fields); len(names) != expected {
+		return NamedStruct{}, fmt.Errorf("%w: got %d names for fields requiring %d",
+			substraitgo.ErrInvalidType, len(names), expected)
+	}
+
+	out := NamedStruct{
+		Names: names,
+		Struct: StructType{
+			Nullability: NullabilityRequired,
+			Types:       fields,
+		},
+	}
+	if len(nullability) > 0 {
+		out.Struct.Nullability = nullability[0]
+	}
+	return out, nil
+}
+
+// CountNames returns the number of names a NamedStruct needs for the
+// fields, which includes the names of the fields of any nested structs,
+// also within lists and maps.
+func CountNames(fields []Type) int {
+	n := 0
+	for _, f := range fields {
+		n += 1 + countNestedNames(f)
+	}
+	return n
+}
+
+func countNestedNames(t Type) int {
+	switch t := t.(type) {
+	case *StructType:
+		return CountNames(t.Types)
+	case *ListType:
+		return countNestedNames(t.Type)
+	case *MapType:
+		return countNestedNames(t.Key) + countNestedNames(t.Value)
+	}
+	return 0
+}
+
+// AllNullable returns a copy of the NamedStruct with all of its fields
+// made nullable, including the fields, elements, keys and values of any
+// nested types. The nullability of the struct itself is unchanged.
+func AllNullable(s NamedStruct) NamedStruct {
+	s.Struct.Types = allNullable(s.Struct.Types)
+	return s
+}
+
+func allNullable(types []Type) []Type {
+	out := make([]Type, len(types))
+	for i, t := range types {
+		switch t := t.(type) {
+		case *StructType:
+			cp := *t
+			cp.Types = allNullable(t.Types)
+			out[i] = &cp
+		case *ListType:
+			cp := *t
+			cp.Type = allNullable([]Type{t.Type})[0]
+			out[i] = &cp
+		case *MapType:
+			cp := *t
+			kv := allNullable([]Type{t.Key, t.Value})
+			cp.Key, cp.Value = kv[0], kv[1]
+			out[i] = &cp
+		default:
+			out[i] = t
+		}
+		out[i] = out[i].WithNullability(NullabilityNullable)
+	}
+	return out
+}
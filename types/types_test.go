@@ -7,8 +7,12 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	substraitgo "github.com/substrait-io/substrait-go"
 	. "github.com/substrait-io/substrait-go/types"
 	"github.com/substrait-io/substrait-go/types/integer_parameters"
+	"github.com/substrait-io/substrait-go/types/parser"
+	"google.golang.org/protobuf/proto"
 )
 
 func TestTypeToString(t *testing.T) {
@@ -100,6 +104,31 @@ func TestTypeRoundtrip(t *testing.T) {
 	}
 }
 
+func TestTypeToProtoParameterized(t *testing.T) {
+	list := &ListType{Nullability: NullabilityRequired,
+		Type: &StringType{Nullability: NullabilityNullable}}
+	dec := &DecimalType{Nullability: NullabilityRequired, Precision: 10, Scale: 2}
+
+	listProto := TypeToProto(list)
+	assert.Equal(t, NullabilityRequired, listProto.GetList().Nullability)
+	assert.Equal(t, NullabilityNullable, listProto.GetList().Type.GetString_().Nullability)
+	assert.True(t, list.Equals(TypeFromProto(listProto)))
+	assert.Equal(t, "list<string?>", TypeFromProto(listProto).String())
+
+	decProto := TypeToProto(dec)
+	assert.EqualValues(t, 10, decProto.GetDecimal().Precision)
+	assert.EqualValues(t, 2, decProto.GetDecimal().Scale)
+	assert.True(t, dec.Equals(TypeFromProto(decProto)))
+	assert.Equal(t, "decimal<10,2>", TypeFromProto(decProto).String())
+
+	for _, typ := range []Type{list, dec} {
+		lit := NullLiteralToProto(typ)
+		assert.True(t, lit.Nullable)
+		assert.True(t, proto.Equal(TypeToProto(typ), lit.GetNull()))
+		assert.True(t, typ.Equals(TypeFromProto(lit.GetNull())))
+	}
+}
+
 func TestGetTypeNameToTypeMap(t *testing.T) {
 	typeMap := GetTypeNameToTypeMap()
 	tests := []struct {
@@ -423,3 +452,63 @@ func TestMatchParameterizedNestedTypeResultMatch(t *testing.T) {
 func markNullable(t FuncDefArgType) FuncDefArgType {
 	return t.SetNullability(NullabilityNullable)
 }
+
+func TestNewNamedStruct(t *testing.T) {
+	fields := []Type{
+		&StringType{Nullability: NullabilityRequired},
+		&StructType{Nullability: NullabilityNullable, Types: []Type{
+			&Int32Type{Nullability: NullabilityRequired},
+			&DateType{Nullability: NullabilityNullable},
+		}},
+	}
+
+	out, err := NewNamedStruct([]string{"a", "b", "c", "d"}, fields)
+	require.NoError(t, err)
+	assert.Equal(t, NamedStruct{Names: []string{"a", "b", "c", "d"},
+		Struct: StructType{Nullability: NullabilityRequired, Types: fields}}, out)
+	assert.Equal(t, "NSTRUCT<a: string, b: struct<c: i32, d: date?>?>", out.String())
+
+	nullable, err := NewNamedStruct([]string{"a", "b", "c", "d"}, fields, NullabilityNullable)
+	require.NoError(t, err)
+	assert.Equal(t, NullabilityNullable, nullable.Struct.Nullability)
+
+	_, err = NewNamedStruct([]string{"a", "b"}, fields)
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidType)
+	assert.ErrorContains(t, err, "got 2 names for fields requiring 4")
+
+	_, err = NewNamedStruct([]string{"a", "b"}, []Type{&StringType{}, nil})
+	assert.ErrorIs(t, err, substraitgo.ErrInvalidType)
+	assert.ErrorContains(t, err, "type of field 1 is nil")
+}
+
+func TestCountNames(t *testing.T) {
+	pair := &StructType{Types: []Type{&Int32Type{}, &StringType{}}}
+	tests := []struct {
+		fields   []Type
+		expected int
+	}{
+		{nil, 0},
+		{[]Type{&Int32Type{}, &StringType{}}, 2},
+		{[]Type{pair}, 3},
+		{[]Type{&StructType{Types: []Type{pair, &DateType{}}}}, 5},
+		{[]Type{&ListType{Type: pair}}, 3},
+		{[]Type{&MapType{Key: &StringType{}, Value: pair}}, 3},
+		{[]Type{&MapType{Key: pair, Value: &ListType{Type: pair}}, &Int64Type{}}, 6},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, CountNames(tt.fields), (&StructType{Types: tt.fields}).String())
+	}
+}
+
+func TestAllNullable(t *testing.T) {
+	s, err := parser.ParseNamedStruct("NSTRUCT<a: i32, b: struct<c: string, d: list<map<string, date>>>>")
+	require.NoError(t, err)
+
+	out := AllNullable(s)
+	assert.Equal(t, "NSTRUCT<a: i32?, b: struct<c: string?, d: list<map<string?,date?>?>?>?>", out.String())
+	assert.Equal(t, NullabilityRequired, out.Struct.Nullability)
+
+	// the original struct is unchanged
+	assert.Equal(t, "NSTRUCT<a: i32, b: struct<c: string, d: list<map<string,date>>>>", s.String())
+}